@@ -5,9 +5,12 @@ import (
 	"discobox/internal/balancer"
 	"discobox/internal/circuit"
 	"discobox/internal/config"
+	"discobox/internal/logging"
 	"discobox/internal/middleware"
+	"discobox/internal/middleware/auth"
 	"discobox/internal/proxy"
 	"discobox/internal/router"
+	"discobox/internal/server"
 	"discobox/internal/storage"
 	"discobox/internal/types"
 	"discobox/pkg/api"
@@ -15,12 +18,14 @@ import (
 	"flag"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
 	"os"
 	"os/signal"
 	"strings"
+	"sync"
 	"syscall"
 
 	_ "github.com/mattn/go-sqlite3" // SQLite driver
@@ -55,7 +60,7 @@ func main() {
 	defer zapLogger.Sync()
 
 	// Wrap zap logger to implement types.Logger
-	logger := wrapZapLogger(zapLogger)
+	logger := logging.WrapZap(zapLogger)
 
 	// Load configuration
 	loader := config.NewLoader(*configFile, logger)
@@ -78,22 +83,62 @@ func main() {
 	}
 
 	// Setup signal handling
-	_, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
 	// Start servers
-	errChan := make(chan error, 3)
+	errChan := make(chan error, len(cfg.ListenAddrs)+3)
 
-	// Main proxy server
-	go func() {
-		logger.Info("Starting proxy server", "addr", cfg.ListenAddr)
-		if err := app.proxyServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			errChan <- fmt.Errorf("proxy server error: %w", err)
+	// Pick up renewed certificates without a restart
+	if app.tlsManager != nil {
+		if err := app.tlsManager.WatchCertificates(ctx); err != nil {
+			logger.Error("Failed to watch TLS certificates", "error", err)
 		}
-	}()
+	}
+
+	// Keep storage in sync with the config directory
+	if app.dirLoader != nil {
+		watchCtx, stopWatch := context.WithCancel(ctx)
+		if err := app.dirLoader.Watch(watchCtx); err != nil {
+			logger.Error("Failed to watch config directory", "error", err)
+		}
+		app.workers = append(app.workers, stopWatch)
+	}
+
+	// Main proxy server, serving every listen address with the same handler.
+	// Shutdown closes all of its listeners.
+	addrs := cfg.ProxyListenAddrs()
+	logger.Info("Starting proxy server", "addrs", addrs, "tls", app.tlsManager != nil)
+	listeners, err := server.Listen(addrs, cfg.MaxConnections, cfg.MaxConnectionsMode)
+	if err != nil {
+		logger.Error("Failed to start proxy server", "error", err)
+		os.Exit(1)
+	}
+	for _, listener := range listeners {
+		go func(listener net.Listener) {
+			var err error
+			if app.tlsManager != nil {
+				// Certificates come from the TLS config's GetCertificate
+				err = app.proxyServer.ServeTLS(listener, "", "")
+			} else {
+				err = app.proxyServer.Serve(listener)
+			}
+			if err != nil && err != http.ErrServerClosed {
+				errChan <- fmt.Errorf("proxy server error on %s: %w", listener.Addr(), err)
+			}
+		}(listener)
+	}
+
+	// HTTP/3 server alongside the TCP listener
+	if app.http3Server != nil {
+		if err := app.http3Server.Start(ctx); err != nil {
+			logger.Error("Failed to start HTTP/3 server", "error", err)
+			os.Exit(1)
+		}
+	}
 
 	// API server
 	if app.apiServer != nil {
@@ -121,15 +166,35 @@ func main() {
 
 	logger.Info("Starting graceful shutdown")
 
-	// Shutdown servers
-	if err := app.proxyServer.Shutdown(shutdownCtx); err != nil {
-		logger.Error("Proxy server shutdown error", "error", err)
+	// Servers finish their in-flight requests before the workers and storage
+	// those requests rely on go away
+	shutdown := &server.Shutdown{
+		Workers: app.workers,
+		Storage: app.storage,
+		Logger:  logger,
+	}
+	shutdown.Intake = append(shutdown.Intake,
+		server.ShutdownStep{Name: "proxy server", Stop: app.proxyServer.Shutdown},
+		server.ShutdownStep{Name: "upgraded connections", Stop: func(ctx context.Context) error {
+			// Shutdown doesn't wait for upgraded connections such as WebSockets
+			upgradedCtx, upgradedCancel := context.WithTimeout(ctx, cfg.LongLived.ShutdownGrace)
+			defer upgradedCancel()
+			if err := app.proxy.CloseUpgraded(upgradedCtx); err != nil {
+				logger.Warn("Closed upgraded connections that outlived the shutdown grace period", "grace", cfg.LongLived.ShutdownGrace)
+			}
+			return nil
+		}},
+	)
+	if app.http3Server != nil {
+		shutdown.Intake = append(shutdown.Intake, server.ShutdownStep{Name: "HTTP/3 server", Stop: app.http3Server.Stop})
 	}
-
 	if app.apiServer != nil {
-		if err := app.apiServer.Shutdown(shutdownCtx); err != nil {
-			logger.Error("API server shutdown error", "error", err)
-		}
+		shutdown.Intake = append(shutdown.Intake, server.ShutdownStep{Name: "API server", Stop: app.apiServer.Shutdown})
+	}
+
+	if err := shutdown.Run(shutdownCtx); err != nil {
+		logger.Error("Shutdown completed with errors", "error", err)
+		return
 	}
 
 	logger.Info("Shutdown completed successfully")
@@ -137,8 +202,13 @@ func main() {
 
 type application struct {
 	proxyServer *http.Server
+	proxy       *proxy.Proxy
 	apiServer   *http.Server
+	tlsManager  *server.TLSManager
+	http3Server *server.HTTP3Server
 	storage     types.Storage
+	dirLoader   *config.DirLoader
+	workers     []func() // Background loops stopped before storage closes
 	logger      types.Logger
 }
 
@@ -155,11 +225,27 @@ func initializeApp(cfg *types.ProxyConfig, logger types.Logger, loader *config.L
 		// Don't fail startup - bootstrap data is optional
 	}
 
-	// Initialize load balancer
-	lb, err := initLoadBalancer(cfg, logger)
+	// Reconcile services and routes declared in the config directory
+	var dirLoader *config.DirLoader
+	if cfg.ConfigDir != "" {
+		dirLoader = config.NewDirLoader(cfg.ConfigDir, store, logger)
+		if err := dirLoader.Reconcile(context.Background()); err != nil {
+			logger.Error("Failed to load config directory", "dir", cfg.ConfigDir, "error", err)
+		}
+	}
+
+	// Initialize load balancer. A reload can replace it, so stopping goes
+	// through stopCurrentLB, which stops whichever balancer is in use.
+	lb, stopLB, err := initLoadBalancer(cfg, logger)
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize load balancer: %w", err)
 	}
+	var stopLBMu sync.Mutex
+	stopCurrentLB := func() {
+		stopLBMu.Lock()
+		defer stopLBMu.Unlock()
+		stopLB()
+	}
 
 	// Initialize health checker
 	healthChecker := circuit.NewHealthChecker(
@@ -167,50 +253,134 @@ func initializeApp(cfg *types.ProxyConfig, logger types.Logger, loader *config.L
 		cfg.HealthCheck.Timeout,
 		cfg.HealthCheck.FailThreshold,
 		cfg.HealthCheck.PassThreshold,
+		cfg.HealthCheck.MaxProbes,
 		logger,
 	)
 
-	// Initialize circuit breaker
-	var breaker types.CircuitBreaker
-	if cfg.CircuitBreaker.Enabled {
-		breaker = circuit.NewCircuitBreaker(
-			cfg.CircuitBreaker.FailureThreshold,
-			cfg.CircuitBreaker.SuccessThreshold,
-			cfg.CircuitBreaker.Timeout,
+	// Initialize passive outlier ejection
+	var outliers types.OutlierDetector
+	if cfg.HealthCheck.Outlier.Enabled {
+		outliers = circuit.NewOutlierDetector(
+			cfg.HealthCheck.Outlier.ConsecutiveErrors,
+			cfg.HealthCheck.Outlier.Window,
+			cfg.HealthCheck.Outlier.BaseEjectionTime,
+			cfg.HealthCheck.Outlier.MaxEjectionTime,
+			logger,
 		)
 	}
 
+	// Initialize weighted health scoring
+	var healthScorer types.HealthScorer
+	if cfg.HealthCheck.Score.Enabled {
+		healthScorer = circuit.NewHealthScorer(
+			cfg.HealthCheck.Score.Samples,
+			cfg.HealthCheck.Score.LatencyThreshold,
+		)
+	}
+
+	// Initialize per-service circuit breakers
+	breakers := newCircuitBreakers(cfg)
+
 	// Initialize router
 	routerImpl := router.NewRouter(store, logger)
 
 	// Initialize URL rewriter
 	rewriter := proxy.NewURLRewriter()
 
+	// Initialize backend DNS resolution; without caching or custom servers
+	// the transport dials through the system resolver directly
+	var resolver *proxy.Resolver
+	if dns := cfg.Transport.DNS; dns.CacheTTL > 0 || len(dns.Servers) > 0 {
+		resolver = proxy.NewResolver(proxy.NewDNSLookup(dns.Servers, cfg.Transport.DialTimeout), dns.CacheTTL, dns.RefreshInterval, logger)
+		resolver.Start()
+	}
+
 	// Initialize transport
-	transport := proxy.NewTransport(*cfg)
+	transport := proxy.NewTransport(*cfg, resolver)
 
 	// Initialize proxy
 	reverseProxy := proxy.New(proxy.Options{
-		LoadBalancer:   lb,
-		HealthChecker:  healthChecker,
-		CircuitBreaker: breaker,
-		Router:         routerImpl,
-		Rewriter:       rewriter,
-		Transport:      transport,
-		Logger:         logger,
-		Storage:        store,
+		LoadBalancer:    lb,
+		HealthChecker:   healthChecker,
+		CircuitBreakers: breakers,
+		Router:          routerImpl,
+		Rewriter:        rewriter,
+		Transport:       transport,
+		Logger:          logger,
+		Storage:         store,
+
+		ExemptLongLived:      cfg.LongLived.ExemptTimeouts,
+		LongLivedIdleTimeout: cfg.LongLived.IdleTimeout,
+		RetryAfter:           cfg.HealthCheck.RetryAfter,
+		OutlierDetector:      outliers,
+		HealthScorer:         healthScorer,
+		BufferSize:           cfg.Transport.BufferSize,
+		MaxDecompressedSize:  cfg.Middleware.Decompression.MaxSize,
+		DefaultServiceID:     cfg.DefaultServiceID,
+		ForwardedPrefix:      cfg.ForwardedPrefix,
+		LogSelection:         cfg.LoadBalancing.LogDecisions,
+		ErrorFormat:          cfg.ErrorFormat,
+		DrainTimeout:         cfg.DrainTimeout,
 	})
 
-	// Build middleware chain
-	proxyHandler := buildMiddlewareChain(cfg, reverseProxy, logger)
+	// Access logs go to their own rotating file when one is configured
+	accessLogger := logger
+	var accessLogFile *middleware.AccessLogFile
+	if cfg.Logging.AccessLogFile.Path != "" {
+		accessLogFile = middleware.NewAccessLogFile(*cfg)
+		accessLogger = accessLogFile.Logger()
+	}
+
+	// Build middleware chain. A reload replaces it, so stopping goes through
+	// stopCurrentChain, which stops whichever chain is in use.
+	proxyHandler, stopChain := buildMiddlewareChain(cfg, reverseProxy, routerImpl, store, accessLogger)
+	var stopChainMu sync.Mutex
+	stopCurrentChain := func() {
+		stopChainMu.Lock()
+		defer stopChainMu.Unlock()
+		stopChain()
+	}
 
 	// Initialize proxy server (NO UI HERE - just proxy)
 	proxyServer := &http.Server{
-		Addr:         cfg.ListenAddr,
-		Handler:      proxyHandler,
-		ReadTimeout:  cfg.ReadTimeout,
-		WriteTimeout: cfg.WriteTimeout,
-		IdleTimeout:  cfg.IdleTimeout,
+		Addr:           cfg.ListenAddr,
+		ReadTimeout:    cfg.ReadTimeout,
+		WriteTimeout:   cfg.WriteTimeout,
+		IdleTimeout:    cfg.IdleTimeout,
+		MaxHeaderBytes: cfg.MaxHeaderBytes,
+	}
+
+	// Count in-flight proxy requests so the instance can be drained before a restart
+	drainer := server.NewDrainer(proxyServer)
+	proxyServer.Handler = drainer.Handler(proxyHandler)
+
+	// Initialize TLS termination if enabled
+	var tlsManager *server.TLSManager
+	if cfg.TLS.Enabled {
+		tlsManager, err = server.NewTLSManager(cfg, logger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize TLS: %w", err)
+		}
+
+		tlsConfig, err := tlsManager.CreateTLSConfig()
+		if err != nil {
+			return nil, fmt.Errorf("failed to create TLS config: %w", err)
+		}
+		proxyServer.TLSConfig = tlsConfig
+	}
+
+	// Initialize HTTP/3 if enabled, sharing the proxy's handler chain and certificates
+	var http3Server *server.HTTP3Server
+	if cfg.HTTP3.Enabled {
+		// Resolve the handler per request so config reloads apply to HTTP/3 too
+		h3Handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			proxyServer.Handler.ServeHTTP(w, r)
+		})
+
+		http3Server, err = server.NewHTTP3Server(cfg, h3Handler, tlsManager, logger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize HTTP/3: %w", err)
+		}
 	}
 
 	// Initialize API server if enabled
@@ -221,33 +391,56 @@ func initializeApp(cfg *types.ProxyConfig, logger types.Logger, loader *config.L
 		// Set config loader so API can reload config
 		apiHandler.SetConfigLoader(loader)
 
+		// Expose live proxy internals to admins
+		apiHandler.SetRuntimeInspector(reverseProxy)
+
+		// Let rolling restarts drain the proxy through the admin API
+		apiHandler.SetDrainer(drainer)
+
+		// Let operators take endpoints out of rotation by hand
+		apiHandler.SetHealthOverrider(reverseProxy)
+
+		// Deliver password reset tokens; forgot password stays off without a webhook
+		if webhook := cfg.API.PasswordReset.WebhookURL; webhook != "" {
+			apiHandler.SetResetTokenNotifier(api.WebhookResetNotifier(webhook, cfg.API.PasswordReset.Timeout))
+		}
+
 		// Set reload callback to update running proxy
 		apiHandler.SetReloadCallback(func(newConfig *types.ProxyConfig) error {
 			// Rebuild middleware chain with new config
-			newProxyHandler := buildMiddlewareChain(newConfig, reverseProxy, logger)
-			proxyServer.Handler = newProxyHandler
+			// The access log file is kept from startup
+			newProxyHandler, newStopChain := buildMiddlewareChain(newConfig, reverseProxy, routerImpl, store, accessLogger)
+			proxyServer.Handler = drainer.Handler(newProxyHandler)
+
+			// The old chain's background loops go with it
+			stopChainMu.Lock()
+			stopOldChain := stopChain
+			stopChain = newStopChain
+			stopChainMu.Unlock()
+			stopOldChain()
 
 			// Update load balancer if algorithm changed
 			if newConfig.LoadBalancing.Algorithm != cfg.LoadBalancing.Algorithm {
-				newLB, err := initLoadBalancer(newConfig, logger)
+				newLB, newStopLB, err := initLoadBalancer(newConfig, logger)
 				if err != nil {
 					return fmt.Errorf("failed to update load balancer: %w", err)
 				}
 				reverseProxy.UpdateLoadBalancer(newLB)
-			}
 
-			// Update circuit breaker if settings changed
-			if newConfig.CircuitBreaker.Enabled {
-				newBreaker := circuit.NewCircuitBreaker(
-					newConfig.CircuitBreaker.FailureThreshold,
-					newConfig.CircuitBreaker.SuccessThreshold,
-					newConfig.CircuitBreaker.Timeout,
-				)
-				reverseProxy.UpdateCircuitBreaker(newBreaker)
-			} else {
-				reverseProxy.UpdateCircuitBreaker(nil)
+				// The old balancer's background loops go with it
+				stopLBMu.Lock()
+				stopOldLB := stopLB
+				stopLB = newStopLB
+				stopLBMu.Unlock()
+				stopOldLB()
 			}
 
+			// Route unmatched requests to the new default service, if any
+			reverseProxy.UpdateDefaultService(newConfig.DefaultServiceID)
+
+			// Rebuild circuit breakers with the new global settings
+			reverseProxy.UpdateCircuitBreakers(newCircuitBreakers(newConfig))
+
 			// Update the config pointer AFTER successful updates
 			*cfg = *newConfig
 
@@ -260,89 +453,194 @@ func initializeApp(cfg *types.ProxyConfig, logger types.Logger, loader *config.L
 
 		// Add UI to API server if enabled
 		if cfg.UI.Enabled {
-			// Mount UI at root on the API server
-			uiHandler := &spaHandler{
-				fs: discobox_ui.GetFileSystem(),
+			// Mount UI on the API server, from ui.dir when set
+			uiFS, err := discobox_ui.FileSystem(cfg.UI.Dir)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load UI: %w", err)
 			}
+			uiHandler := discobox_ui.NewHandler(uiFS, cfg.UI.Path)
 
-			// Create a new mux that combines API and UI
-			combinedMux := http.NewServeMux()
-			combinedMux.Handle("/api/", apiRouter)
-			combinedMux.Handle("/health", apiRouter)
-			combinedMux.Handle("/prometheus/metrics", apiRouter)
-			combinedMux.Handle("/", uiHandler)
+			// Combine API and UI, both under ui.path
+			combinedMux := discobox_ui.Mount(apiRouter, uiHandler, cfg.UI.Path)
 
 			apiServer = &http.Server{
-				Addr:         cfg.API.Addr,
-				Handler:      combinedMux,
-				ReadTimeout:  cfg.ReadTimeout,
-				WriteTimeout: cfg.WriteTimeout,
-				IdleTimeout:  cfg.IdleTimeout,
+				Addr:           cfg.API.Addr,
+				Handler:        combinedMux,
+				ReadTimeout:    cfg.ReadTimeout,
+				WriteTimeout:   cfg.WriteTimeout,
+				IdleTimeout:    cfg.IdleTimeout,
+				MaxHeaderBytes: cfg.MaxHeaderBytes,
 			}
 		} else {
 			apiServer = &http.Server{
-				Addr:         cfg.API.Addr,
-				Handler:      apiRouter,
-				ReadTimeout:  cfg.ReadTimeout,
-				WriteTimeout: cfg.WriteTimeout,
-				IdleTimeout:  cfg.IdleTimeout,
+				Addr:           cfg.API.Addr,
+				Handler:        apiRouter,
+				ReadTimeout:    cfg.ReadTimeout,
+				WriteTimeout:   cfg.WriteTimeout,
+				IdleTimeout:    cfg.IdleTimeout,
+				MaxHeaderBytes: cfg.MaxHeaderBytes,
 			}
 		}
 	}
 
+	// Components that run their own goroutines against storage. The health
+	// checker and router are only known by interface, so their stop methods
+	// are asserted here and a missing one fails startup loudly.
+	workers := []func(){
+		healthChecker.(interface{ Stop() }).Stop,
+		func() { routerImpl.(io.Closer).Close() },
+		stopCurrentLB,
+		stopCurrentChain,
+		reverseProxy.Stop,
+	}
+	if resolver != nil {
+		workers = append(workers, resolver.Stop)
+	}
+	if accessLogFile != nil {
+		workers = append(workers, func() { accessLogFile.Close() })
+	}
+
 	return &application{
 		proxyServer: proxyServer,
+		proxy:       reverseProxy,
 		apiServer:   apiServer,
+		tlsManager:  tlsManager,
+		http3Server: http3Server,
 		storage:     store,
+		dirLoader:   dirLoader,
+		workers:     workers,
 		logger:      logger,
 	}, nil
 }
 
-func buildMiddlewareChain(cfg *types.ProxyConfig, handler http.Handler, logger types.Logger) http.Handler {
+// newCircuitBreakers creates a circuit breaker per service from the global
+// settings, or returns nil when circuit breaking is disabled
+func newCircuitBreakers(cfg *types.ProxyConfig) types.CircuitBreakerSet {
+	if !cfg.CircuitBreaker.Enabled {
+		return nil
+	}
+	return circuit.NewMultiCircuitBreaker(circuit.CircuitBreakerSettings{
+		FailureThreshold: cfg.CircuitBreaker.FailureThreshold,
+		SuccessThreshold: cfg.CircuitBreaker.SuccessThreshold,
+		Timeout:          cfg.CircuitBreaker.Timeout,
+		ServerErrors:     cfg.CircuitBreaker.ServerErrors,
+	})
+}
+
+// buildMiddlewareChain wraps handler in the configured middleware. The
+// returned func stops the goroutines of every middleware that runs one.
+func buildMiddlewareChain(cfg *types.ProxyConfig, handler http.Handler, routes types.Router, store types.Storage, accessLogger types.Logger) (http.Handler, func()) {
 	chain := middleware.NewChain()
+	var stops []func()
 
-	// Security headers (outermost)
+	// Resolve the client IP once, before anything identifies clients by it
+	chain.Use(middleware.ClientIP(cfg.TrustedProxyDepth))
+
+	// Debug headers, timing everything further in
+	if cfg.Debug.Enabled {
+		chain.Use(middleware.DebugHeaders(*cfg, store))
+	}
+
+	// Advertise HTTP/3 to TCP clients
+	if cfg.HTTP3.Enabled && cfg.TLS.Enabled {
+		chain.Use(server.AltSvc(cfg))
+	}
+
+	// Note the global middleware the matched route disables
+	chain.Use(middleware.RouteDisabled(routes))
+
+	// Security headers
 	if cfg.Middleware.Headers.Security {
-		chain.Use(middleware.SecurityHeaders())
+		chain.Use(middleware.Disableable(middleware.NameSecurityHeaders, middleware.SecurityHeaders()))
+	}
+
+	// Reject oversized request headers before any other work is done
+	if cfg.Middleware.HeaderLimits.MaxCount > 0 || cfg.Middleware.HeaderLimits.MaxLength > 0 {
+		chain.Use(middleware.Disableable(middleware.NameHeaderLimits, middleware.HeaderLimits(*cfg)))
 	}
 
-	// CORS
-	if cfg.Middleware.CORS.Enabled {
-		chain.Use(middleware.CORS(*cfg))
+	// CORS, with per-route overrides from route metadata
+	chain.Use(middleware.Disableable(middleware.NameCORS, middleware.RouteCORS(*cfg, routes)))
+
+	// Identity from an upstream auth gateway; spoofed headers are stripped.
+	// Routes can't disable this, or they would let spoofed identities through.
+	if cfg.Middleware.Auth.TrustedHeader.Enabled {
+		chain.Use(auth.TrustedHeader(*cfg))
 	}
 
 	// Access logging
 	if cfg.Logging.AccessLogs {
-		chain.Use(middleware.AccessLogging(logger))
+		chain.Use(middleware.Disableable(middleware.NameAccessLog, middleware.AccessLogging(accessLogger)))
 	}
 
 	// Metrics
 	if cfg.Metrics.Enabled {
-		chain.Use(middleware.Metrics())
+		chain.Use(middleware.Disableable(middleware.NameMetrics, middleware.Metrics()))
+	}
+
+	// Bound every request, so no handler runs unchecked
+	if cfg.Middleware.RequestTimeout > 0 {
+		chain.Use(middleware.Disableable(middleware.NameTimeout, middleware.Timeout(*cfg)))
 	}
 
 	// Rate limiting
 	if cfg.RateLimit.Enabled {
-		chain.Use(middleware.RateLimit(*cfg))
+		rateLimit, stopRateLimit := middleware.RateLimitWithStop(*cfg, routes, store)
+		chain.Use(middleware.Disableable(middleware.NameRateLimit, rateLimit))
+		stops = append(stops, stopRateLimit)
 	}
 
+	// Shed low priority requests first when overloaded
+	if cfg.Middleware.LoadShedding.Enabled {
+		chain.Use(middleware.Disableable(middleware.NameLoadShedding, middleware.LoadShedding(*cfg, routes)))
+	}
+
+	// Per-route concurrency caps from route metadata
+	chain.Use(middleware.Disableable(middleware.NameConcurrency, middleware.RouteConcurrency(routes)))
+
 	// Compression
 	if cfg.Middleware.Compression.Enabled {
-		chain.Use(middleware.Compression(*cfg))
+		chain.Use(middleware.Disableable(middleware.NameCompression, middleware.Compression(*cfg)))
 	}
 
-	// Custom headers (innermost, closest to proxy)
+	// Replay responses for retried POSTs carrying an Idempotency-Key. Inside
+	// compression, so responses are kept uncompressed and every replay is
+	// encoded for the client asking.
+	if cfg.Middleware.Idempotency.Enabled {
+		chain.Use(middleware.Disableable(middleware.NameIdempotency, middleware.Idempotency(*cfg)))
+	}
+
+	// Custom headers
 	if len(cfg.Middleware.Headers.Custom) > 0 {
-		chain.Use(middleware.CustomHeaders(cfg.Middleware.Headers.Custom))
+		chain.Use(middleware.Disableable(middleware.NameCustomHeaders, middleware.CustomHeaders(cfg.Middleware.Headers.Custom)))
 	}
 
-	return chain.Then(handler)
+	// Retries (innermost, closest to proxy)
+	if cfg.Retry.Enabled {
+		retryConfig := middleware.DefaultRetryConfig()
+		retryConfig.MaxAttempts = cfg.Retry.MaxAttempts
+		retryConfig.InitialDelay = cfg.Retry.InitialDelay
+		retryConfig.MaxDelay = cfg.Retry.MaxDelay
+		chain.Use(middleware.Disableable(middleware.NameRetry, middleware.Retry(retryConfig)))
+	}
+
+	stop := func() {
+		for _, stop := range stops {
+			stop()
+		}
+	}
+	return chain.Then(handler), stop
 }
 
 func initStorage(cfg *types.ProxyConfig, logger types.Logger) (types.Storage, error) {
 	switch cfg.Storage.Type {
 	case "sqlite":
-		return storage.NewSQLite(cfg.Storage.DSN, logger)
+		store, err := storage.NewSQLite(cfg.Storage.DSN, logger)
+		if err != nil {
+			return nil, err
+		}
+		// The proxy looks up services on every request; serve them from memory
+		return storage.WithCache(store), nil
 	case "memory":
 		return storage.NewMemory(), nil
 	default:
@@ -350,8 +648,11 @@ func initStorage(cfg *types.ProxyConfig, logger types.Logger) (types.Storage, er
 	}
 }
 
-func initLoadBalancer(cfg *types.ProxyConfig, _ types.Logger) (types.LoadBalancer, error) {
+// initLoadBalancer builds the configured balancer and its wrappers. The
+// returned func stops the goroutines of every layer that runs one.
+func initLoadBalancer(cfg *types.ProxyConfig, _ types.Logger) (types.LoadBalancer, func(), error) {
 	var lb types.LoadBalancer
+	var stops []func()
 
 	switch cfg.LoadBalancing.Algorithm {
 	case "round_robin":
@@ -362,20 +663,65 @@ func initLoadBalancer(cfg *types.ProxyConfig, _ types.Logger) (types.LoadBalance
 		lb = balancer.NewLeastConnections()
 	case "ip_hash":
 		lb = balancer.NewIPHash()
+	case "least_time":
+		lb = balancer.NewLeastTime()
 	default:
-		return nil, fmt.Errorf("unknown load balancing algorithm: %s", cfg.LoadBalancing.Algorithm)
+		return nil, nil, fmt.Errorf("unknown load balancing algorithm: %s", cfg.LoadBalancing.Algorithm)
+	}
+
+	// Keep traffic in the caller's zone; sessions wrap this so new ones are
+	// placed in-zone too
+	if cfg.LoadBalancing.ZonePreference.Enabled {
+		lb = balancer.NewZonePreference(
+			lb,
+			cfg.LoadBalancing.ZonePreference.Header,
+			cfg.LoadBalancing.ZonePreference.DefaultZone,
+		)
 	}
 
 	// Wrap with sticky sessions if enabled
 	if cfg.LoadBalancing.Sticky.Enabled {
-		lb = balancer.NewStickySession(
+		newSticky := balancer.NewStickySession
+		if cfg.LoadBalancing.Sticky.Weighted {
+			newSticky = balancer.NewWeightedStickySession
+		}
+		lb = newSticky(
 			lb,
 			cfg.LoadBalancing.Sticky.CookieName,
 			cfg.LoadBalancing.Sticky.TTL,
 		)
+		stops = append(stops, lb.(interface{ Stop() }).Stop)
+	}
+
+	// Pin WebSocket upgrades so reconnects return to the same backend
+	if cfg.LoadBalancing.WebSocketAffinity.Enabled {
+		lb = balancer.NewUpgradeAffinity(
+			lb,
+			cfg.LoadBalancing.WebSocketAffinity.CookieName,
+			cfg.LoadBalancing.WebSocketAffinity.TTL,
+		)
+		stops = append(stops, lb.(interface{ Stop() }).Stop)
+	}
+
+	// Outermost, so the proxy reports every response to it
+	if cfg.LoadBalancing.AdaptiveWeights.Enabled {
+		adaptive := balancer.NewAdaptiveWeights(
+			lb,
+			cfg.LoadBalancing.AdaptiveWeights.Interval,
+			cfg.LoadBalancing.AdaptiveWeights.Samples,
+			cfg.LoadBalancing.AdaptiveWeights.MinWeight,
+			cfg.LoadBalancing.AdaptiveWeights.MaxWeight,
+		)
+		stops = append(stops, adaptive.Stop)
+		lb = adaptive
 	}
 
-	return lb, nil
+	stop := func() {
+		for _, stop := range stops {
+			stop()
+		}
+	}
+	return lb, stop, nil
 }
 
 func initLogger() (*zap.Logger, error) {
@@ -388,49 +734,9 @@ func initLogger() (*zap.Logger, error) {
 	return config.Build()
 }
 
-// wrapZapLogger wraps zap.Logger to implement types.Logger
-func wrapZapLogger(zap *zap.Logger) types.Logger {
-	return &zapLoggerWrapper{zap: zap}
-}
-
-type zapLoggerWrapper struct {
-	zap *zap.Logger
-}
-
-func (z *zapLoggerWrapper) Debug(msg string, fields ...any) {
-	z.zap.Debug(msg, z.fieldsToZap(fields)...)
-}
-
-func (z *zapLoggerWrapper) Info(msg string, fields ...any) {
-	z.zap.Info(msg, z.fieldsToZap(fields)...)
-}
-
-func (z *zapLoggerWrapper) Warn(msg string, fields ...any) {
-	z.zap.Warn(msg, z.fieldsToZap(fields)...)
-}
-
-func (z *zapLoggerWrapper) Error(msg string, fields ...any) {
-	z.zap.Error(msg, z.fieldsToZap(fields)...)
-}
-
-func (z *zapLoggerWrapper) With(fields ...any) types.Logger {
-	return &zapLoggerWrapper{zap: z.zap.With(z.fieldsToZap(fields)...)}
-}
-
-func (z *zapLoggerWrapper) fieldsToZap(fields []any) []zap.Field {
-	var zapFields []zap.Field
-	for i := 0; i < len(fields); i += 2 {
-		if i+1 < len(fields) {
-			key, ok := fields[i].(string)
-			if ok {
-				zapFields = append(zapFields, zap.Any(key, fields[i+1]))
-			}
-		}
-	}
-	return zapFields
-}
-
-// uiProxyHandler serves UI when no proxy route matches
+// uiProxyHandler serves UI when no proxy route matches. A configured default
+// service takes unmatched requests inside the proxy, so the UI only sees them
+// when there is none.
 type uiProxyHandler struct {
 	proxy  http.Handler
 	ui     http.Handler
@@ -439,7 +745,7 @@ type uiProxyHandler struct {
 
 func (h *uiProxyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// Check if this is an API request that should be proxied to the API server
-	if strings.HasPrefix(r.URL.Path, "/api/") || r.URL.Path == "/health" {
+	if strings.HasPrefix(r.URL.Path, "/api/") || r.URL.Path == "/health" || r.URL.Path == "/livez" || r.URL.Path == "/readyz" {
 		// Proxy to API server
 		apiURL, _ := url.Parse("http://localhost:8081")
 		proxy := httputil.NewSingleHostReverseProxy(apiURL)
@@ -490,6 +796,11 @@ func (rw *interceptResponseWriter) Header() http.Header {
 }
 
 func (rw *interceptResponseWriter) WriteHeader(code int) {
+	// Interim responses such as 100 Continue go straight to the client
+	if types.IsInterimStatus(code) {
+		rw.ResponseWriter.WriteHeader(code)
+		return
+	}
 	rw.statusCode = code
 	if code == http.StatusNotFound {
 		// Start intercepting if we get a 404
@@ -532,65 +843,3 @@ func (rw *interceptResponseWriter) flush() {
 		rw.ResponseWriter.Write(rw.body)
 	}
 }
-
-// spaHandler serves the SPA UI, returning index.html for non-existent paths
-type spaHandler struct {
-	fs http.FileSystem
-}
-
-func (h *spaHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	// Try to open the requested file
-	path := r.URL.Path
-	if path == "/" {
-		path = "/index.html"
-	}
-
-	file, err := h.fs.Open(path)
-	if err != nil {
-		// If file doesn't exist, serve index.html for client-side routing
-		file, err = h.fs.Open("/index.html")
-		if err != nil {
-			http.Error(w, "index.html not found", http.StatusInternalServerError)
-			return
-		}
-		// Set path back to "/" so http.ServeContent doesn't get confused
-		r.URL.Path = "/"
-	}
-	defer file.Close()
-
-	// Get file info
-	stat, err := file.Stat()
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	// Serve the file
-	if stat.IsDir() {
-		// If it's a directory, try index.html
-		indexFile, err := h.fs.Open(path + "/index.html")
-		if err != nil {
-			http.Error(w, "Not found", http.StatusNotFound)
-			return
-		}
-		defer indexFile.Close()
-
-		indexStat, err := indexFile.Stat()
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-
-		if seeker, ok := indexFile.(io.ReadSeeker); ok {
-			http.ServeContent(w, r, path+"/index.html", indexStat.ModTime(), seeker)
-		} else {
-			http.Error(w, "File not seekable", http.StatusInternalServerError)
-		}
-	} else {
-		if seeker, ok := file.(io.ReadSeeker); ok {
-			http.ServeContent(w, r, path, stat.ModTime(), seeker)
-		} else {
-			http.Error(w, "File not seekable", http.StatusInternalServerError)
-		}
-	}
-}
@@ -0,0 +1,220 @@
+package balancer
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"discobox/internal/types"
+)
+
+// AdaptiveWeights wraps a load balancer and periodically sets the weight of
+// each server from an exponentially weighted moving average of its latency
+// and error rate. Within a service, the backend answering fastest without
+// errors gets the maximum weight and the others a proportional share, so
+// weighted balancers send faster backends more traffic.
+type AdaptiveWeights struct {
+	base      types.LoadBalancer
+	alpha     float64
+	minWeight int
+	maxWeight int
+
+	mu      sync.Mutex
+	stats   map[string]*adaptiveStats
+	pools   map[string]string // Server ID to the first server ID it was selected with
+	weights map[string]int    // Weights last set, by server ID
+
+	ticker   *time.Ticker
+	stopCh   chan struct{}
+	stopOnce sync.Once
+}
+
+type adaptiveStats struct {
+	errorRate float64 // average of 1 for failures and 0 for successes
+	latency   float64 // average response time in nanoseconds
+}
+
+// NewAdaptiveWeights wraps base, recomputing weights every interval from
+// roughly the last samples responses of each server. Weights stay between
+// minWeight and maxWeight.
+func NewAdaptiveWeights(base types.LoadBalancer, interval time.Duration, samples, minWeight, maxWeight int) *AdaptiveWeights {
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	if samples < 1 {
+		samples = 1
+	}
+	if minWeight < 1 {
+		minWeight = 1
+	}
+	if maxWeight < minWeight {
+		maxWeight = minWeight
+	}
+
+	aw := &AdaptiveWeights{
+		base:      base,
+		alpha:     2 / float64(samples+1),
+		minWeight: minWeight,
+		maxWeight: maxWeight,
+		stats:     make(map[string]*adaptiveStats),
+		pools:     make(map[string]string),
+		weights:   make(map[string]int),
+		ticker:    time.NewTicker(interval),
+		stopCh:    make(chan struct{}),
+	}
+
+	go aw.loop()
+
+	return aw
+}
+
+// Select records which servers share a pool and delegates to the base balancer
+func (aw *AdaptiveWeights) Select(ctx context.Context, req *http.Request, servers []*types.Server) (*types.Server, error) {
+	aw.recordPool(servers)
+	return aw.base.Select(ctx, req, servers)
+}
+
+// SelectWithReason selects like Select and passes on the base balancer's
+// explanation, if it gives one
+func (aw *AdaptiveWeights) SelectWithReason(ctx context.Context, req *http.Request, servers []*types.Server) (*types.Server, *types.SelectionReason, error) {
+	aw.recordPool(servers)
+	return selectFrom(ctx, aw.base, req, servers, true)
+}
+
+// recordPool notes that servers share a pool, keyed by the first server
+func (aw *AdaptiveWeights) recordPool(servers []*types.Server) {
+	if len(servers) > 0 {
+		pool := servers[0].ID
+
+		aw.mu.Lock()
+		for _, server := range servers {
+			aw.pools[server.ID] = pool
+		}
+		aw.mu.Unlock()
+	}
+}
+
+// Add adds a new server to the pool
+func (aw *AdaptiveWeights) Add(server *types.Server) error {
+	return aw.base.Add(server)
+}
+
+// Remove removes a server from the pool and forgets its history
+func (aw *AdaptiveWeights) Remove(serverID string) error {
+	aw.mu.Lock()
+	delete(aw.stats, serverID)
+	delete(aw.pools, serverID)
+	delete(aw.weights, serverID)
+	aw.mu.Unlock()
+
+	return aw.base.Remove(serverID)
+}
+
+// UpdateWeight updates server weight. The next recompute replaces it once the
+// server has responses on record.
+func (aw *AdaptiveWeights) UpdateWeight(serverID string, weight int) error {
+	return aw.base.UpdateWeight(serverID, weight)
+}
+
+// ObserveLatency passes response times on to the base balancer if it uses them
+func (aw *AdaptiveWeights) ObserveLatency(serverID string, latency time.Duration) {
+	if observer, ok := aw.base.(types.LatencyObserver); ok {
+		observer.ObserveLatency(serverID, latency)
+	}
+}
+
+// ObserveResult records a response or failure and how long it took
+func (aw *AdaptiveWeights) ObserveResult(serverID string, failed bool, latency time.Duration) {
+	aw.mu.Lock()
+	defer aw.mu.Unlock()
+
+	sample := 0.0
+	if failed {
+		sample = 1
+	}
+
+	stats, exists := aw.stats[serverID]
+	if !exists {
+		aw.stats[serverID] = &adaptiveStats{errorRate: sample, latency: float64(latency)}
+		return
+	}
+
+	stats.errorRate += aw.alpha * (sample - stats.errorRate)
+	stats.latency += aw.alpha * (float64(latency) - stats.latency)
+}
+
+// Recompute sets the weight of every server with responses on record. Each
+// server's throughput, its success rate over its average latency, is
+// compared with the best in its pool.
+func (aw *AdaptiveWeights) Recompute() {
+	aw.mu.Lock()
+
+	throughput := make(map[string]float64, len(aw.stats))
+	best := make(map[string]float64)
+	for serverID, stats := range aw.stats {
+		pool, known := aw.pools[serverID]
+		if !known {
+			continue
+		}
+		latency := math.Max(stats.latency, 1)
+		throughput[serverID] = (1 - stats.errorRate) / latency
+		best[pool] = math.Max(best[pool], throughput[serverID])
+	}
+
+	changed := make(map[string]int)
+	for serverID, value := range throughput {
+		weight := aw.minWeight
+		if top := best[aw.pools[serverID]]; top > 0 {
+			weight = int(math.Round(float64(aw.maxWeight) * value / top))
+		}
+		weight = max(aw.minWeight, min(aw.maxWeight, weight))
+
+		if current, exists := aw.weights[serverID]; !exists || current != weight {
+			aw.weights[serverID] = weight
+			changed[serverID] = weight
+		}
+	}
+
+	aw.mu.Unlock()
+
+	// Servers the base balancer hasn't seen yet are picked up next time
+	for serverID, weight := range changed {
+		if err := aw.base.UpdateWeight(serverID, weight); err != nil {
+			aw.mu.Lock()
+			delete(aw.weights, serverID)
+			aw.mu.Unlock()
+		}
+	}
+}
+
+// Weight returns the weight last set for a server, if any
+func (aw *AdaptiveWeights) Weight(serverID string) (int, bool) {
+	aw.mu.Lock()
+	defer aw.mu.Unlock()
+
+	weight, exists := aw.weights[serverID]
+	return weight, exists
+}
+
+// Stop stops recomputing weights
+func (aw *AdaptiveWeights) Stop() {
+	aw.stopOnce.Do(func() {
+		close(aw.stopCh)
+	})
+}
+
+// loop recomputes weights on every tick until stopped
+func (aw *AdaptiveWeights) loop() {
+	defer aw.ticker.Stop()
+
+	for {
+		select {
+		case <-aw.ticker.C:
+			aw.Recompute()
+		case <-aw.stopCh:
+			return
+		}
+	}
+}
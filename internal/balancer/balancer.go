@@ -2,10 +2,24 @@
 package balancer
 
 import (
+	"context"
+	"net/http"
 	"net/url"
 	"discobox/internal/types"
 )
 
+// selectFrom selects a server with base. When explain is set and base
+// implements types.SelectionExplainer it also returns base's reason, so
+// balancers that wrap another one can pass explanations through.
+func selectFrom(ctx context.Context, base types.LoadBalancer, req *http.Request, servers []*types.Server, explain bool) (*types.Server, *types.SelectionReason, error) {
+	if explainer, ok := base.(types.SelectionExplainer); ok && explain {
+		return explainer.SelectWithReason(ctx, req, servers)
+	}
+	
+	server, err := base.Select(ctx, req, servers)
+	return server, nil, err
+}
+
 // NewServer creates a new server instance from a service endpoint
 func NewServer(endpoint string, serviceID string, weight int) (*types.Server, error) {
 	u, err := url.Parse(endpoint)
@@ -32,6 +46,7 @@ func ServersFromService(service *types.Service) ([]*types.Server, error) {
 			return nil, err
 		}
 		server.MaxConns = service.MaxConns
+		server.HealthCheck = service.HealthCheck
 		servers = append(servers, server)
 	}
 	
@@ -5,26 +5,57 @@ import (
 	"discobox/internal/types"
 	"fmt"
 	"hash/crc32"
-	"net"
 	"net/http"
 	"sort"
 	"sync"
 	"sync/atomic"
+	"time"
 )
 
-// ipHash implements IP hash load balancing with consistent hashing
+const (
+	// ipHashForgetAfter is how long a server that was only ever passed to
+	// Select, rather than added, stays on the ring without being passed again
+	ipHashForgetAfter = 10 * time.Minute
+	// ipHashPruneInterval is how often servers past ipHashForgetAfter are
+	// looked for
+	ipHashPruneInterval = time.Minute
+)
+
+// ipHash implements IP hash load balancing with consistent hashing. Servers
+// get virtual nodes on the ring in proportion to their weight, so bigger
+// backends own more of the key space. Pools passed to Select share one ring;
+// a client goes to the first server of its pool at or after its place on it.
 type ipHash struct {
 	mu           sync.RWMutex
-	servers      map[string]*types.Server
+	servers      map[string]*ringMember
+	weights      map[string]int // Weights set by UpdateWeight, by server ID
 	ring         *consistentHash
+	lastPrune    atomic.Int64 // Unix nanoseconds of the last prune
 	fallbackFunc func(context.Context, *http.Request, []*types.Server) (*types.Server, error)
 }
 
+// ringMember is a server on the ring
+type ringMember struct {
+	server   *types.Server
+	weight   int          // Weight the server is on the ring with
+	added    bool         // Added with Add, so kept until Remove
+	lastSeen atomic.Int64 // Unix nanoseconds the server was last passed to Select
+}
+
+// seen notes the server was passed to Select at now. The time is only kept
+// to the prune interval, which is all prune needs.
+func (m *ringMember) seen(now time.Time) {
+	if now.UnixNano()-m.lastSeen.Load() >= int64(ipHashPruneInterval) {
+		m.lastSeen.Store(now.UnixNano())
+	}
+}
+
 // NewIPHash creates a new IP hash load balancer
 func NewIPHash() types.LoadBalancer {
 	return &ipHash{
-		servers:      make(map[string]*types.Server),
-		ring:         newConsistentHash(150), // 150 virtual nodes per server
+		servers:      make(map[string]*ringMember),
+		weights:      make(map[string]int),
+		ring:         newConsistentHash(150), // 150 virtual nodes per unit of weight
 		fallbackFunc: NewRoundRobin().Select, // Fallback to round-robin
 	}
 }
@@ -36,51 +67,130 @@ func (ih *ipHash) Select(ctx context.Context, req *http.Request, servers []*type
 	}
 	
 	// Get client IP
-	clientIP := getClientIP(req)
+	clientIP := types.ClientIP(req)
 	if clientIP == "" {
 		// Fallback if we can't determine client IP
 		return ih.fallbackFunc(ctx, req, servers)
 	}
 	
+	now := time.Now()
+	ih.syncRing(servers, now)
+	ih.prune(now)
+	
 	ih.mu.RLock()
 	defer ih.mu.RUnlock()
 	
-	// Get server ID from consistent hash
-	serverID := ih.ring.Get(clientIP)
-	
-	// Find the server in the provided list
-	for _, server := range servers {
-		if server.ID == serverID && server.Healthy {
-			// Check max connections
-			if server.MaxConns > 0 && atomic.LoadInt64(&server.ActiveConns) >= int64(server.MaxConns) {
-				// Try next server in the ring
-				return ih.selectNextAvailable(ctx, req, servers, clientIP)
+	// Walk the ring from the client's place until a server of this pool can
+	// take the request, or every one of them has been tried. Most walks end
+	// at the pool's first node, so the pool is only indexed if they don't.
+	var selected *types.Server
+	var untried map[string]*types.Server
+	ih.ring.Walk(clientIP, func(node string) bool {
+		var server *types.Server
+		if untried == nil {
+			for _, candidate := range servers {
+				if candidate.ID == node {
+					server = candidate
+					break
+				}
 			}
-			return server, nil
+		} else {
+			server = untried[node]
+		}
+		if server == nil {
+			return false
 		}
+		
+		// Check health and max connections
+		if server.Healthy && (server.MaxConns == 0 || atomic.LoadInt64(&server.ActiveConns) < int64(server.MaxConns)) {
+			selected = server
+			return true
+		}
+		
+		if untried == nil {
+			untried = make(map[string]*types.Server, len(servers))
+			for _, candidate := range servers {
+				untried[candidate.ID] = candidate
+			}
+		}
+		delete(untried, node)
+		return len(untried) == 0
+	})
+	
+	if selected != nil {
+		return selected, nil
 	}
 	
-	// types.Server not found or unhealthy, try next in ring
-	return ih.selectNextAvailable(ctx, req, servers, clientIP)
+	// No available servers in hash ring, fallback
+	return ih.fallbackFunc(ctx, req, servers)
 }
 
-// selectNextAvailable finds the next available server in the hash ring
-func (ih *ipHash) selectNextAvailable(ctx context.Context, req *http.Request, servers []*types.Server, key string) (*types.Server, error) {
-	serverIDs := ih.ring.GetN(key, len(servers))
+// syncRing puts servers on the ring, or resizes them there, when they are
+// missing or their weight changed, and notes that they were seen at now.
+// Servers absent from the list keep their place, since callers may select
+// from part of a pool, until prune forgets them.
+func (ih *ipHash) syncRing(servers []*types.Server, now time.Time) {
+	ih.mu.RLock()
+	inSync := true
+	for _, server := range servers {
+		member, known := ih.servers[server.ID]
+		if !known || member.weight != ringWeight(ih.weightOf(server)) {
+			inSync = false
+			break
+		}
+		member.seen(now)
+	}
+	ih.mu.RUnlock()
 	
-	for _, serverID := range serverIDs {
-		for _, server := range servers {
-			if server.ID == serverID && server.Healthy {
-				// Check max connections
-				if server.MaxConns == 0 || atomic.LoadInt64(&server.ActiveConns) < int64(server.MaxConns) {
-					return server, nil
-				}
-			}
+	if inSync {
+		return
+	}
+	
+	ih.mu.Lock()
+	defer ih.mu.Unlock()
+	
+	weights := make(map[string]int, len(servers))
+	for _, server := range servers {
+		member, known := ih.servers[server.ID]
+		if !known {
+			member = &ringMember{server: server}
+			ih.servers[server.ID] = member
 		}
+		member.seen(now)
+		member.weight = ringWeight(ih.weightOf(server))
+		weights[server.ID] = member.weight
+	}
+	ih.ring.SetWeights(weights)
+}
+
+// prune takes servers that haven't been passed to Select for
+// ipHashForgetAfter, and weren't added with Add, off the ring. It looks at
+// most once every ipHashPruneInterval.
+func (ih *ipHash) prune(now time.Time) {
+	last := ih.lastPrune.Load()
+	if now.UnixNano()-last < int64(ipHashPruneInterval) || !ih.lastPrune.CompareAndSwap(last, now.UnixNano()) {
+		return
 	}
 	
-	// No available servers in hash ring, fallback
-	return ih.fallbackFunc(ctx, req, servers)
+	ih.mu.Lock()
+	defer ih.mu.Unlock()
+	
+	for id, member := range ih.servers {
+		if !member.added && now.Sub(time.Unix(0, member.lastSeen.Load())) >= ipHashForgetAfter {
+			delete(ih.servers, id)
+			delete(ih.weights, id)
+			ih.ring.Remove(id)
+		}
+	}
+}
+
+// weightOf returns the weight set by UpdateWeight, else the server's own;
+// the caller holds the lock
+func (ih *ipHash) weightOf(server *types.Server) int {
+	if weight, ok := ih.weights[server.ID]; ok {
+		return weight
+	}
+	return server.Weight
 }
 
 // Add adds a new server to the pool
@@ -92,8 +202,9 @@ func (ih *ipHash) Add(server *types.Server) error {
 	ih.mu.Lock()
 	defer ih.mu.Unlock()
 	
-	ih.servers[server.ID] = server
-	ih.ring.Add(server.ID)
+	member := &ringMember{server: server, added: true, weight: ringWeight(ih.weightOf(server))}
+	ih.servers[server.ID] = member
+	ih.ring.SetWeights(map[string]int{server.ID: member.weight})
 	
 	return nil
 }
@@ -104,57 +215,37 @@ func (ih *ipHash) Remove(serverID string) error {
 	defer ih.mu.Unlock()
 	
 	delete(ih.servers, serverID)
+	delete(ih.weights, serverID)
 	ih.ring.Remove(serverID)
 	
 	return nil
 }
 
-// UpdateWeight updates server weight (affects virtual nodes)
+// UpdateWeight updates server weight, adding or removing only the virtual
+// nodes the difference calls for so most keys stay where they are
 func (ih *ipHash) UpdateWeight(serverID string, weight int) error {
+	if weight < 0 {
+		return types.ErrInvalidWeight
+	}
+	
 	ih.mu.Lock()
 	defer ih.mu.Unlock()
 	
-	if server, exists := ih.servers[serverID]; exists {
-		server.Weight = weight
-		// Update virtual nodes based on weight
-		ih.ring.UpdateWeight(serverID, weight)
-	}
-	
-	return nil
-}
-
-// getClientIP extracts the client IP from the request
-func getClientIP(req *http.Request) string {
-	// Check X-Forwarded-For header first
-	xff := req.Header.Get("X-Forwarded-For")
-	if xff != "" {
-		// Take the first IP in the chain
-		if idx := indexByte(xff, ','); idx != -1 {
-			xff = xff[:idx]
-		}
-		if ip := net.ParseIP(trimSpace(xff)); ip != nil {
-			return ip.String()
-		}
+	member, exists := ih.servers[serverID]
+	if !exists {
+		return types.ErrServerNotFound
 	}
 	
-	// Check X-Real-IP header
-	if xri := req.Header.Get("X-Real-IP"); xri != "" {
-		if ip := net.ParseIP(xri); ip != nil {
-			return ip.String()
-		}
-	}
+	ih.weights[serverID] = weight
+	member.weight = ringWeight(weight)
+	ih.ring.SetWeights(map[string]int{serverID: weight})
 	
-	// Fall back to RemoteAddr
-	host, _, err := net.SplitHostPort(req.RemoteAddr)
-	if err != nil {
-		// RemoteAddr might be just an IP without port
-		return req.RemoteAddr
-	}
-	
-	return host
+	return nil
 }
 
-// consistentHash implements consistent hashing
+// consistentHash implements consistent hashing. A node of weight w has
+// replicas*w virtual nodes, numbered from 0, so a weight change only adds or
+// removes the highest-numbered ones.
 type consistentHash struct {
 	mu           sync.RWMutex
 	replicas     int
@@ -173,24 +264,30 @@ func newConsistentHash(replicas int) *consistentHash {
 	}
 }
 
-// Add adds a node to the hash ring
-func (ch *consistentHash) Add(node string) {
+// ringWeight is the weight a node is placed on the ring with; every node
+// gets at least one share
+func ringWeight(weight int) int {
+	if weight <= 0 {
+		return 1
+	}
+	return weight
+}
+
+// SetWeights adds nodes to the ring or changes their weight
+func (ch *consistentHash) SetWeights(weights map[string]int) {
 	ch.mu.Lock()
 	defer ch.mu.Unlock()
 	
-	weight := ch.nodeWeights[node]
-	if weight <= 0 {
-		weight = 1
+	changed := false
+	for node, weight := range weights {
+		if ch.resizeLocked(node, ringWeight(weight)) {
+			changed = true
+		}
 	}
 	
-	// Add virtual nodes based on weight
-	virtualNodes := ch.replicas * weight
-	for i := 0; i < virtualNodes; i++ {
-		hash := ch.hash(fmt.Sprintf("%s:%d", node, i))
-		ch.circle[hash] = node
+	if changed {
+		ch.updateSortedHashes()
 	}
-	
-	ch.updateSortedHashes()
 }
 
 // Remove removes a node from the hash ring
@@ -198,61 +295,44 @@ func (ch *consistentHash) Remove(node string) {
 	ch.mu.Lock()
 	defer ch.mu.Unlock()
 	
-	weight := ch.nodeWeights[node]
-	if weight <= 0 {
-		weight = 1
+	if ch.resizeLocked(node, 0) {
+		ch.updateSortedHashes()
 	}
-	
-	// Remove virtual nodes
-	virtualNodes := ch.replicas * weight
-	for i := 0; i < virtualNodes; i++ {
-		hash := ch.hash(fmt.Sprintf("%s:%d", node, i))
-		delete(ch.circle, hash)
-	}
-	
 	delete(ch.nodeWeights, node)
-	ch.updateSortedHashes()
 }
 
-// UpdateWeight updates the weight of a node
-func (ch *consistentHash) UpdateWeight(node string, weight int) {
-	ch.mu.Lock()
-	defer ch.mu.Unlock()
-	
-	// Remove old virtual nodes
-	oldWeight := ch.nodeWeights[node]
-	if oldWeight <= 0 {
-		oldWeight = 1
+// resizeLocked adds or removes a node's virtual nodes so it has weight
+// shares, reporting whether the circle changed; the caller holds the lock
+func (ch *consistentHash) resizeLocked(node string, weight int) bool {
+	old, exists := ch.nodeWeights[node]
+	if exists && old == weight {
+		return false
 	}
 	
-	virtualNodes := ch.replicas * oldWeight
-	for i := 0; i < virtualNodes; i++ {
-		hash := ch.hash(fmt.Sprintf("%s:%d", node, i))
-		delete(ch.circle, hash)
-	}
-	
-	// Add new virtual nodes with new weight
-	if weight <= 0 {
-		weight = 1
+	from, to := old*ch.replicas, weight*ch.replicas
+	for i := from; i < to; i++ {
+		ch.circle[ch.hash(fmt.Sprintf("%s:%d", node, i))] = node
 	}
-	ch.nodeWeights[node] = weight
-	
-	virtualNodes = ch.replicas * weight
-	for i := 0; i < virtualNodes; i++ {
+	for i := to; i < from; i++ {
 		hash := ch.hash(fmt.Sprintf("%s:%d", node, i))
-		ch.circle[hash] = node
+		// Leave a colliding virtual node of another node in place
+		if ch.circle[hash] == node {
+			delete(ch.circle, hash)
+		}
 	}
 	
-	ch.updateSortedHashes()
+	ch.nodeWeights[node] = weight
+	return from != to
 }
 
-// Get returns the node for a given key
-func (ch *consistentHash) Get(key string) string {
+// Walk calls visit with the node of each virtual node on the ring, starting
+// at key's place and going round once, until visit returns true
+func (ch *consistentHash) Walk(key string, visit func(node string) bool) {
 	ch.mu.RLock()
 	defer ch.mu.RUnlock()
 	
-	if len(ch.circle) == 0 {
-		return ""
+	if len(ch.sortedHashes) == 0 {
+		return
 	}
 	
 	hash := ch.hash(key)
@@ -262,48 +342,12 @@ func (ch *consistentHash) Get(key string) string {
 		return ch.sortedHashes[i] >= hash
 	})
 	
-	// Wrap around if necessary
-	if idx == len(ch.sortedHashes) {
-		idx = 0
-	}
-	
-	return ch.circle[ch.sortedHashes[idx]]
-}
-
-// GetN returns N nodes for a given key
-func (ch *consistentHash) GetN(key string, n int) []string {
-	ch.mu.RLock()
-	defer ch.mu.RUnlock()
-	
-	if len(ch.circle) == 0 {
-		return nil
-	}
-	
-	if n > len(ch.nodeWeights) {
-		n = len(ch.nodeWeights)
-	}
-	
-	hash := ch.hash(key)
-	nodes := make([]string, 0, n)
-	seen := make(map[string]bool)
-	
-	// Find starting position
-	idx := sort.Search(len(ch.sortedHashes), func(i int) bool {
-		return ch.sortedHashes[i] >= hash
-	})
-	
-	// Collect n unique nodes
-	for i := 0; i < len(ch.sortedHashes) && len(nodes) < n; i++ {
-		actualIdx := (idx + i) % len(ch.sortedHashes)
-		node := ch.circle[ch.sortedHashes[actualIdx]]
-		
-		if !seen[node] {
-			seen[node] = true
-			nodes = append(nodes, node)
+	for i := 0; i < len(ch.sortedHashes); i++ {
+		// Wrap around if necessary
+		if visit(ch.circle[ch.sortedHashes[(idx+i)%len(ch.sortedHashes)]]) {
+			return
 		}
 	}
-	
-	return nodes
 }
 
 // hash generates a hash for a key
@@ -322,29 +366,3 @@ func (ch *consistentHash) updateSortedHashes() {
 	})
 	ch.sortedHashes = hashes
 }
-
-// Helper functions
-
-func indexByte(s string, b byte) int {
-	for i := 0; i < len(s); i++ {
-		if s[i] == b {
-			return i
-		}
-	}
-	return -1
-}
-
-func trimSpace(s string) string {
-	start := 0
-	end := len(s)
-	
-	for start < end && (s[start] == ' ' || s[start] == '\t') {
-		start++
-	}
-	
-	for start < end && (s[end-1] == ' ' || s[end-1] == '\t') {
-		end--
-	}
-	
-	return s[start:end]
-}
@@ -3,6 +3,7 @@ package balancer
 import (
 	"context"
 	"discobox/internal/types"
+	"fmt"
 	"math"
 	"net/http"
 	"sync"
@@ -25,6 +26,23 @@ func NewLeastConnections() types.LoadBalancer {
 
 // Select returns the server with the least active connections
 func (lc *leastConnections) Select(ctx context.Context, req *http.Request, servers []*types.Server) (*types.Server, error) {
+	return lc.pick(servers, nil)
+}
+
+// SelectWithReason selects like Select and reports every candidate's
+// connection count
+func (lc *leastConnections) SelectWithReason(ctx context.Context, req *http.Request, servers []*types.Server) (*types.Server, *types.SelectionReason, error) {
+	reason := &types.SelectionReason{
+		Algorithm:  "least_conn",
+		Candidates: make([]types.SelectionCandidate, 0, len(servers)),
+	}
+	server, err := lc.pick(servers, reason)
+	return server, reason, err
+}
+
+// pick selects a server, recording candidates and the outcome in reason
+// when it is non-nil
+func (lc *leastConnections) pick(servers []*types.Server, reason *types.SelectionReason) (*types.Server, error) {
 	if len(servers) == 0 {
 		return nil, types.ErrNoHealthyBackends
 	}
@@ -34,15 +52,19 @@ func (lc *leastConnections) Select(ctx context.Context, req *http.Request, serve
 	var eligibleServers []*types.Server
 	
 	for _, server := range servers {
-		// Skip unhealthy servers
-		if !server.Healthy {
-			continue
-		}
-		
 		activeConns := atomic.LoadInt64(&server.ActiveConns)
 		
-		// Check max connections limit
-		if server.MaxConns > 0 && activeConns >= int64(server.MaxConns) {
+		// Skip unhealthy servers and those at their max connections limit
+		eligible := server.Healthy && (server.MaxConns <= 0 || activeConns < int64(server.MaxConns))
+		if reason != nil {
+			reason.Candidates = append(reason.Candidates, types.SelectionCandidate{
+				ServerID:    server.ID,
+				ActiveConns: activeConns,
+				Weight:      server.Weight,
+				Eligible:    eligible,
+			})
+		}
+		if !eligible {
 			continue
 		}
 		
@@ -56,11 +78,17 @@ func (lc *leastConnections) Select(ctx context.Context, req *http.Request, serve
 	}
 	
 	if len(eligibleServers) == 0 {
+		if reason != nil {
+			reason.Reason = "no healthy server under its connection limit"
+		}
 		return nil, types.ErrNoHealthyBackends
 	}
 	
 	// If only one server has minimum connections, return it
 	if len(eligibleServers) == 1 {
+		if reason != nil {
+			reason.Reason = fmt.Sprintf("fewest active connections (%d)", minConnections)
+		}
 		return eligibleServers[0], nil
 	}
 	
@@ -69,6 +97,9 @@ func (lc *leastConnections) Select(ctx context.Context, req *http.Request, serve
 	count := atomic.AddUint64(&lc.counter, 1)
 	index := (count - 1) % uint64(len(eligibleServers))
 	
+	if reason != nil {
+		reason.Reason = fmt.Sprintf("round robin among %d servers tied at %d active connections", len(eligibleServers), minConnections)
+	}
 	return eligibleServers[index], nil
 }
 
@@ -0,0 +1,190 @@
+package balancer
+
+import (
+	"context"
+	"discobox/internal/types"
+	"math"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// leastTimeDecay is the weight of a new latency sample in the moving average
+	leastTimeDecay = 0.3
+
+	// leastTimeStaleAfter is how long a latency estimate is trusted without new
+	// samples. A backend that was once slow and stopped getting traffic is
+	// treated as unknown again so it gets probed instead of starving forever.
+	leastTimeStaleAfter = 10 * time.Second
+)
+
+// leastTime implements least-time load balancing: each server is scored by
+// its moving-average response time multiplied by its outstanding requests
+// plus one, and the lowest score wins
+type leastTime struct {
+	mu        sync.RWMutex
+	servers   map[string]*types.Server
+	latencies map[string]*latencyEstimate
+	counter   uint64 // For round-robin when scores are equal
+}
+
+type latencyEstimate struct {
+	ewma    float64 // seconds
+	updated time.Time
+}
+
+// NewLeastTime creates a new least-time load balancer. Response times are
+// fed in by the proxy through ObserveLatency.
+func NewLeastTime() types.LoadBalancer {
+	return &leastTime{
+		servers:   make(map[string]*types.Server),
+		latencies: make(map[string]*latencyEstimate),
+	}
+}
+
+// ObserveLatency folds a response time into the server's moving average
+func (lt *leastTime) ObserveLatency(serverID string, latency time.Duration) {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+
+	sample := latency.Seconds()
+	estimate, exists := lt.latencies[serverID]
+	if !exists {
+		lt.latencies[serverID] = &latencyEstimate{ewma: sample, updated: time.Now()}
+		return
+	}
+
+	estimate.ewma = leastTimeDecay*sample + (1-leastTimeDecay)*estimate.ewma
+	estimate.updated = time.Now()
+}
+
+// Select returns the server expected to respond soonest
+func (lt *leastTime) Select(ctx context.Context, req *http.Request, servers []*types.Server) (*types.Server, error) {
+	if len(servers) == 0 {
+		return nil, types.ErrNoHealthyBackends
+	}
+
+	latencies := lt.currentLatencies(servers)
+
+	minScore := math.MaxFloat64
+	var eligibleServers []*types.Server
+
+	for i, server := range servers {
+		// Skip unhealthy servers
+		if !server.Healthy {
+			continue
+		}
+
+		activeConns := atomic.LoadInt64(&server.ActiveConns)
+
+		// Check max connections limit
+		if server.MaxConns > 0 && activeConns >= int64(server.MaxConns) {
+			continue
+		}
+
+		score := latencies[i] * float64(activeConns+1)
+
+		if score < minScore {
+			minScore = score
+			eligibleServers = []*types.Server{server}
+		} else if score == minScore {
+			eligibleServers = append(eligibleServers, server)
+		}
+	}
+
+	if len(eligibleServers) == 0 {
+		return nil, types.ErrNoHealthyBackends
+	}
+
+	if len(eligibleServers) == 1 {
+		return eligibleServers[0], nil
+	}
+
+	// Use round-robin between servers with equal scores
+	count := atomic.AddUint64(&lt.counter, 1)
+	index := (count - 1) % uint64(len(eligibleServers))
+
+	return eligibleServers[index], nil
+}
+
+// currentLatencies returns the latency estimate for each server. Servers
+// without a fresh estimate get the average of the others, so new backends
+// are neither flooded nor ignored.
+func (lt *leastTime) currentLatencies(servers []*types.Server) []float64 {
+	lt.mu.RLock()
+	defer lt.mu.RUnlock()
+
+	now := time.Now()
+	latencies := make([]float64, len(servers))
+	known := make([]bool, len(servers))
+
+	var sum float64
+	var count int
+	for i, server := range servers {
+		estimate, exists := lt.latencies[server.ID]
+		if !exists || now.Sub(estimate.updated) > leastTimeStaleAfter {
+			continue
+		}
+		latencies[i] = estimate.ewma
+		known[i] = true
+		sum += estimate.ewma
+		count++
+	}
+
+	fallback := 1.0
+	if count > 0 {
+		fallback = sum / float64(count)
+	}
+
+	for i := range latencies {
+		if !known[i] {
+			latencies[i] = fallback
+		}
+	}
+
+	return latencies
+}
+
+// Add adds a new server to the pool
+func (lt *leastTime) Add(server *types.Server) error {
+	if server == nil || server.ID == "" {
+		return types.ErrInvalidRequest
+	}
+
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+
+	lt.servers[server.ID] = server
+	return nil
+}
+
+// Remove removes a server from the pool
+func (lt *leastTime) Remove(serverID string) error {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+
+	delete(lt.servers, serverID)
+	delete(lt.latencies, serverID)
+	return nil
+}
+
+// UpdateWeight updates server weight
+func (lt *leastTime) UpdateWeight(serverID string, weight int) error {
+	if weight < 0 {
+		return types.ErrInvalidWeight
+	}
+
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+
+	server, exists := lt.servers[serverID]
+	if !exists {
+		return types.ErrServerNotFound
+	}
+
+	server.Weight = weight
+
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 	"crypto/rand"
 	"discobox/internal/types"
 	"encoding/hex"
+	"errors"
 	"net/http"
 	"sync"
 	"time"
@@ -19,6 +20,11 @@ type stickySession struct {
 	sessions   map[string]*sessionEntry
 	ticker     *time.Ticker
 	stopCh     chan struct{}
+	
+	// weighted keeps new sessions off servers with zero weight and applies
+	// runtime weight overrides before delegating to the base balancer
+	weighted bool
+	weights  map[string]int
 }
 
 type sessionEntry struct {
@@ -50,8 +56,35 @@ func NewStickySession(base types.LoadBalancer, cookieName string, ttl time.Durat
 	return ss
 }
 
+// NewWeightedStickySession creates a sticky session load balancer that
+// assigns new sessions by weight. Existing sessions stay pinned to their
+// server; a server whose weight drops to zero receives no new sessions and
+// drains as its sessions expire. Weight changes made through UpdateWeight
+// rebalance new sessions immediately.
+func NewWeightedStickySession(base types.LoadBalancer, cookieName string, ttl time.Duration) types.LoadBalancer {
+	ss := NewStickySession(base, cookieName, ttl).(*stickySession)
+	ss.weighted = true
+	ss.weights = make(map[string]int)
+	
+	return ss
+}
+
 // Select returns a server based on session affinity
 func (ss *stickySession) Select(ctx context.Context, req *http.Request, servers []*types.Server) (*types.Server, error) {
+	server, _, err := ss.pick(ctx, req, servers, false)
+	return server, err
+}
+
+// SelectWithReason selects like Select and passes on the base balancer's
+// explanation when it made the choice. Sessions already pinned to a server
+// come with no reason.
+func (ss *stickySession) SelectWithReason(ctx context.Context, req *http.Request, servers []*types.Server) (*types.Server, *types.SelectionReason, error) {
+	return ss.pick(ctx, req, servers, true)
+}
+
+// pick returns the session's server or selects a new one, asking the base
+// balancer to explain its choice when explain is set
+func (ss *stickySession) pick(ctx context.Context, req *http.Request, servers []*types.Server, explain bool) (*types.Server, *types.SelectionReason, error) {
 	// Check for existing session
 	cookie, err := req.Cookie(ss.cookieName)
 	if err == nil && cookie.Value != "" {
@@ -69,7 +102,7 @@ func (ss *stickySession) Select(ctx context.Context, req *http.Request, servers
 					session.expiresAt = time.Now().Add(ss.ttl)
 					ss.mu.Unlock()
 					
-					return server, nil
+					return server, nil, nil
 				}
 			}
 		} else {
@@ -85,16 +118,20 @@ func (ss *stickySession) Select(ctx context.Context, req *http.Request, servers
 					}
 					ss.mu.Unlock()
 					
-					return server, nil
+					return server, nil, nil
 				}
 			}
 		}
 	}
 	
 	// No valid session, select new server
-	server, err := ss.base.Select(ctx, req, servers)
+	if ss.weighted {
+		servers = ss.eligibleServers(servers)
+	}
+	
+	server, reason, err := selectFrom(ctx, ss.base, req, servers, explain)
 	if err != nil {
-		return nil, err
+		return nil, reason, err
 	}
 	
 	// Create new session using server ID as session ID for compatibility with tests
@@ -119,7 +156,7 @@ func (ss *stickySession) Select(ctx context.Context, req *http.Request, servers
 	// The proxy implementation should check if sticky sessions are enabled and
 	// set the appropriate cookie with the server ID after proxying the request.
 	
-	return server, nil
+	return server, reason, nil
 }
 
 // Add adds a new server to the pool
@@ -136,6 +173,7 @@ func (ss *stickySession) Remove(serverID string) error {
 			delete(ss.sessions, sessionID)
 		}
 	}
+	delete(ss.weights, serverID)
 	ss.mu.Unlock()
 	
 	return ss.base.Remove(serverID)
@@ -143,7 +181,58 @@ func (ss *stickySession) Remove(serverID string) error {
 
 // UpdateWeight updates server weight
 func (ss *stickySession) UpdateWeight(serverID string, weight int) error {
-	return ss.base.UpdateWeight(serverID, weight)
+	if !ss.weighted {
+		return ss.base.UpdateWeight(serverID, weight)
+	}
+	
+	if weight < 0 {
+		return types.ErrInvalidWeight
+	}
+	
+	ss.mu.Lock()
+	ss.weights[serverID] = weight
+	ss.mu.Unlock()
+	
+	// The override applies even if the base balancer was never told about
+	// the server, since servers are usually passed to Select directly
+	if err := ss.base.UpdateWeight(serverID, weight); err != nil && !errors.Is(err, types.ErrServerNotFound) {
+		return err
+	}
+	
+	return nil
+}
+
+// ObserveLatency passes response times on to the base balancer if it uses them
+func (ss *stickySession) ObserveLatency(serverID string, latency time.Duration) {
+	if observer, ok := ss.base.(types.LatencyObserver); ok {
+		observer.ObserveLatency(serverID, latency)
+	}
+}
+
+// eligibleServers applies weight overrides to copies of servers and returns
+// those that may receive new sessions, leaving the caller's servers as they
+// are. Only servers explicitly set to zero weight are excluded.
+func (ss *stickySession) eligibleServers(servers []*types.Server) []*types.Server {
+	ss.mu.RLock()
+	defer ss.mu.RUnlock()
+	
+	eligible := make([]*types.Server, 0, len(servers))
+	for _, server := range servers {
+		weight, ok := ss.weights[server.ID]
+		if !ok {
+			// Unset weights fall back to the base balancer's default
+			eligible = append(eligible, server)
+			continue
+		}
+		
+		if weight > 0 {
+			weighted := *server
+			weighted.Weight = weight
+			eligible = append(eligible, &weighted)
+		}
+	}
+	
+	return eligible
 }
 
 // cleanupLoop periodically removes expired sessions
@@ -218,10 +307,24 @@ func NewIPStickySession(base types.LoadBalancer, ttl time.Duration) types.LoadBa
 
 // Select returns a server based on client IP affinity
 func (iss *IPStickySession) Select(ctx context.Context, req *http.Request, servers []*types.Server) (*types.Server, error) {
-	clientIP := getClientIP(req)
+	server, _, err := iss.pick(ctx, req, servers, false)
+	return server, err
+}
+
+// SelectWithReason selects like Select and passes on the base balancer's
+// explanation when it made the choice. Clients already pinned to a server
+// come with no reason.
+func (iss *IPStickySession) SelectWithReason(ctx context.Context, req *http.Request, servers []*types.Server) (*types.Server, *types.SelectionReason, error) {
+	return iss.pick(ctx, req, servers, true)
+}
+
+// pick returns the client's server or selects a new one, asking the base
+// balancer to explain its choice when explain is set
+func (iss *IPStickySession) pick(ctx context.Context, req *http.Request, servers []*types.Server, explain bool) (*types.Server, *types.SelectionReason, error) {
+	clientIP := types.ClientIP(req)
 	if clientIP == "" {
 		// Can't determine IP, fall back to base balancer
-		return iss.base.Select(ctx, req, servers)
+		return selectFrom(ctx, iss.base, req, servers, explain)
 	}
 	
 	// Check for existing session
@@ -238,15 +341,15 @@ func (iss *IPStickySession) Select(ctx context.Context, req *http.Request, serve
 				session.expiresAt = time.Now().Add(iss.ttl)
 				iss.mu.Unlock()
 				
-				return server, nil
+				return server, nil, nil
 			}
 		}
 	}
 	
 	// No valid session, select new server
-	server, err := iss.base.Select(ctx, req, servers)
+	server, reason, err := selectFrom(ctx, iss.base, req, servers, explain)
 	if err != nil {
-		return nil, err
+		return nil, reason, err
 	}
 	
 	// Create new session
@@ -257,7 +360,7 @@ func (iss *IPStickySession) Select(ctx context.Context, req *http.Request, serve
 	}
 	iss.mu.Unlock()
 	
-	return server, nil
+	return server, reason, nil
 }
 
 // Add adds a new server to the pool
@@ -284,6 +387,13 @@ func (iss *IPStickySession) UpdateWeight(serverID string, weight int) error {
 	return iss.base.UpdateWeight(serverID, weight)
 }
 
+// ObserveLatency passes response times on to the base balancer if it uses them
+func (iss *IPStickySession) ObserveLatency(serverID string, latency time.Duration) {
+	if observer, ok := iss.base.(types.LatencyObserver); ok {
+		observer.ObserveLatency(serverID, latency)
+	}
+}
+
 // cleanupLoop periodically removes expired sessions
 func (iss *IPStickySession) cleanupLoop() {
 	for {
@@ -0,0 +1,182 @@
+package balancer
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"discobox/internal/types"
+)
+
+// upgradeAffinity pins protocol upgrades, such as WebSocket handshakes, to
+// the backend the client last upgraded on. Other requests go straight to the
+// base balancer.
+type upgradeAffinity struct {
+	base       types.LoadBalancer
+	cookieName string
+	ttl        time.Duration
+	mu         sync.Mutex
+	sessions   map[string]*sessionEntry
+	ticker     *time.Ticker
+	stopCh     chan struct{}
+}
+
+// NewUpgradeAffinity creates a load balancer that sends a client's upgrade
+// requests back to the same backend while it stays healthy, so reconnecting
+// WebSocket clients find their server-side state again. Clients are
+// identified by cookieName when the request carries that cookie and by
+// client IP otherwise. Pins expire after ttl without a reconnect.
+func NewUpgradeAffinity(base types.LoadBalancer, cookieName string, ttl time.Duration) types.LoadBalancer {
+	if ttl <= 0 {
+		ttl = 30 * time.Minute
+	}
+
+	ua := &upgradeAffinity{
+		base:       base,
+		cookieName: cookieName,
+		ttl:        ttl,
+		sessions:   make(map[string]*sessionEntry),
+		ticker:     time.NewTicker(5 * time.Minute),
+		stopCh:     make(chan struct{}),
+	}
+
+	go ua.cleanupLoop()
+
+	return ua
+}
+
+// Select returns the pinned backend for upgrade requests, falling back to
+// the base balancer for new clients, unhealthy pins and plain requests
+func (ua *upgradeAffinity) Select(ctx context.Context, req *http.Request, servers []*types.Server) (*types.Server, error) {
+	server, _, err := ua.pick(ctx, req, servers, false)
+	return server, err
+}
+
+// SelectWithReason selects like Select and passes on the base balancer's
+// explanation when it made the choice. Pinned backends come with no reason.
+func (ua *upgradeAffinity) SelectWithReason(ctx context.Context, req *http.Request, servers []*types.Server) (*types.Server, *types.SelectionReason, error) {
+	return ua.pick(ctx, req, servers, true)
+}
+
+// pick returns the pinned backend or selects and pins a new one, asking the
+// base balancer to explain its choice when explain is set
+func (ua *upgradeAffinity) pick(ctx context.Context, req *http.Request, servers []*types.Server, explain bool) (*types.Server, *types.SelectionReason, error) {
+	if req.Header.Get("Upgrade") == "" {
+		return selectFrom(ctx, ua.base, req, servers, explain)
+	}
+
+	key := ua.clientKey(req)
+	if key == "" {
+		return selectFrom(ctx, ua.base, req, servers, explain)
+	}
+
+	now := time.Now()
+	ua.mu.Lock()
+	session, exists := ua.sessions[key]
+	if exists && session.expiresAt.After(now) {
+		for _, server := range servers {
+			if server.ID == session.serverID && server.Healthy {
+				session.expiresAt = now.Add(ua.ttl)
+				ua.mu.Unlock()
+				return server, nil, nil
+			}
+		}
+	}
+	ua.mu.Unlock()
+
+	server, reason, err := selectFrom(ctx, ua.base, req, servers, explain)
+	if err != nil {
+		return nil, reason, err
+	}
+
+	ua.mu.Lock()
+	ua.sessions[key] = &sessionEntry{
+		serverID:  server.ID,
+		expiresAt: now.Add(ua.ttl),
+	}
+	ua.mu.Unlock()
+
+	return server, reason, nil
+}
+
+// clientKey identifies the client and the endpoint it upgrades on, so one
+// client's sockets to different services are pinned independently
+func (ua *upgradeAffinity) clientKey(req *http.Request) string {
+	client := ""
+	if ua.cookieName != "" {
+		if cookie, err := req.Cookie(ua.cookieName); err == nil && cookie.Value != "" {
+			client = "cookie:" + cookie.Value
+		}
+	}
+	if client == "" {
+		ip := types.ClientIP(req)
+		if ip == "" {
+			return ""
+		}
+		client = "ip:" + ip
+	}
+
+	return client + "|" + req.Host + req.URL.Path
+}
+
+// Add adds a new server to the pool
+func (ua *upgradeAffinity) Add(server *types.Server) error {
+	return ua.base.Add(server)
+}
+
+// Remove removes a server from the pool
+func (ua *upgradeAffinity) Remove(serverID string) error {
+	ua.mu.Lock()
+	for key, session := range ua.sessions {
+		if session.serverID == serverID {
+			delete(ua.sessions, key)
+		}
+	}
+	ua.mu.Unlock()
+
+	return ua.base.Remove(serverID)
+}
+
+// UpdateWeight updates server weight
+func (ua *upgradeAffinity) UpdateWeight(serverID string, weight int) error {
+	return ua.base.UpdateWeight(serverID, weight)
+}
+
+// ObserveLatency passes response times on to the base balancer if it uses them
+func (ua *upgradeAffinity) ObserveLatency(serverID string, latency time.Duration) {
+	if observer, ok := ua.base.(types.LatencyObserver); ok {
+		observer.ObserveLatency(serverID, latency)
+	}
+}
+
+// cleanupLoop periodically removes expired pins
+func (ua *upgradeAffinity) cleanupLoop() {
+	for {
+		select {
+		case <-ua.ticker.C:
+			ua.cleanup()
+		case <-ua.stopCh:
+			ua.ticker.Stop()
+			return
+		}
+	}
+}
+
+// cleanup removes expired pins
+func (ua *upgradeAffinity) cleanup() {
+	ua.mu.Lock()
+	defer ua.mu.Unlock()
+
+	now := time.Now()
+	for key, session := range ua.sessions {
+		if session.expiresAt.Before(now) {
+			delete(ua.sessions, key)
+		}
+	}
+}
+
+// Stop stops the cleanup goroutine
+func (ua *upgradeAffinity) Stop() {
+	close(ua.stopCh)
+}
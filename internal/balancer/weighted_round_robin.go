@@ -4,6 +4,7 @@ import (
 	"context"
 	"discobox/internal/types"
 	"net/http"
+	"net/url"
 	"sync"
 	"sync/atomic"
 )
@@ -12,7 +13,9 @@ import (
 type weightedRoundRobin struct {
 	mu              sync.RWMutex
 	servers         map[string]*types.Server
-	weightedServers []*types.Server // Expanded list based on weights
+	weightedServers []int          // Expanded list of positions in builtFrom, based on weights
+	builtFrom       []serverState  // Servers the weighted list was built from
+	weights         map[string]int // Weights set by UpdateWeight, by server ID
 	counter         uint64
 	totalWeight     int
 }
@@ -21,28 +24,41 @@ type weightedRoundRobin struct {
 func NewWeightedRoundRobin() types.LoadBalancer {
 	return &weightedRoundRobin{
 		servers:         make(map[string]*types.Server),
-		weightedServers: make([]*types.Server, 0),
+		weightedServers: make([]int, 0),
+		weights:         make(map[string]int),
 	}
 }
 
-// Select returns the next server based on weights
+// Select returns the next server based on weights. The weighted list is
+// rebuilt only when the servers' IDs, URLs, weights or health change, so
+// callers may pass fresh copies of the same pool on every call.
 func (wrr *weightedRoundRobin) Select(ctx context.Context, req *http.Request, servers []*types.Server) (*types.Server, error) {
 	if len(servers) == 0 {
 		return nil, types.ErrNoHealthyBackends
 	}
 	
-	// Build weighted list if needed
 	wrr.mu.RLock()
-	needsRebuild := len(wrr.weightedServers) == 0
+	if len(wrr.weightedServers) > 0 && !wrr.isStale(servers) {
+		defer wrr.mu.RUnlock()
+		return wrr.selectLocked(servers)
+	}
 	wrr.mu.RUnlock()
 	
-	if needsRebuild {
-		wrr.rebuildWeightedList(servers)
-	}
+	// Build weighted list, unless another caller already has
+	wrr.mu.Lock()
+	defer wrr.mu.Unlock()
 	
-	wrr.mu.RLock()
-	defer wrr.mu.RUnlock()
+	if len(wrr.weightedServers) == 0 || wrr.isStale(servers) {
+		wrr.rebuildLocked(servers)
+	}
 	
+	return wrr.selectLocked(servers)
+}
+
+// selectLocked picks the next server from the weighted list, which the caller
+// holds a lock on and has checked was built from servers. The server returned
+// is the caller's own, so its connection count is current.
+func (wrr *weightedRoundRobin) selectLocked(servers []*types.Server) (*types.Server, error) {
 	if len(wrr.weightedServers) == 0 {
 		return nil, types.ErrNoHealthyBackends
 	}
@@ -53,7 +69,7 @@ func (wrr *weightedRoundRobin) Select(ctx context.Context, req *http.Request, se
 		count := atomic.AddUint64(&wrr.counter, 1)
 		index := (count - 1) % uint64(len(wrr.weightedServers))
 		
-		selected := wrr.weightedServers[index]
+		selected := servers[wrr.weightedServers[index]]
 		
 		// Check if server is healthy
 		if !selected.Healthy {
@@ -81,7 +97,8 @@ func (wrr *weightedRoundRobin) Add(server *types.Server) error {
 	defer wrr.mu.Unlock()
 	
 	wrr.servers[server.ID] = server
-	wrr.weightedServers = nil // Force rebuild on next select
+	delete(wrr.weights, server.ID) // The added server's own weight applies
+	wrr.weightedServers = nil      // Force rebuild on next select
 	
 	return nil
 }
@@ -92,12 +109,15 @@ func (wrr *weightedRoundRobin) Remove(serverID string) error {
 	defer wrr.mu.Unlock()
 	
 	delete(wrr.servers, serverID)
+	delete(wrr.weights, serverID)
 	wrr.weightedServers = nil // Force rebuild on next select
 	
 	return nil
 }
 
-// UpdateWeight updates server weight
+// UpdateWeight updates server weight. The weighted list is rebuilt straight
+// away, and the weight takes precedence over the one on servers passed to
+// Select until the server is added or removed again.
 func (wrr *weightedRoundRobin) UpdateWeight(serverID string, weight int) error {
 	if weight < 0 {
 		return types.ErrInvalidWeight
@@ -107,36 +127,65 @@ func (wrr *weightedRoundRobin) UpdateWeight(serverID string, weight int) error {
 	defer wrr.mu.Unlock()
 	
 	server, exists := wrr.servers[serverID]
-	if !exists {
+	if !exists && !wrr.selectedFrom(serverID) {
 		return types.ErrServerNotFound
 	}
 	
-	server.Weight = weight
-	wrr.weightedServers = nil // Force rebuild on next select
+	if server != nil {
+		server.Weight = weight
+	}
+	wrr.weights[serverID] = weight
+	
+	// Rebuild from the servers last selected from
+	if len(wrr.builtFrom) > 0 {
+		servers := make([]*types.Server, len(wrr.builtFrom))
+		for i, state := range wrr.builtFrom {
+			servers[i] = state.server
+		}
+		wrr.rebuildLocked(servers)
+	}
 	
 	return nil
 }
 
-// rebuildWeightedList rebuilds the weighted server list
-func (wrr *weightedRoundRobin) rebuildWeightedList(servers []*types.Server) {
-	wrr.mu.Lock()
-	defer wrr.mu.Unlock()
-	
+// selectedFrom reports whether the weighted list was built from a server
+func (wrr *weightedRoundRobin) selectedFrom(serverID string) bool {
+	for _, state := range wrr.builtFrom {
+		if state.server.ID == serverID {
+			return true
+		}
+	}
+	return false
+}
+
+// weightOf returns a server's weight, preferring one set by UpdateWeight
+func (wrr *weightedRoundRobin) weightOf(server *types.Server) int {
+	if weight, ok := wrr.weights[server.ID]; ok {
+		return weight
+	}
+	return server.Weight
+}
+
+// rebuildLocked rebuilds the weighted server list; the caller holds the lock
+func (wrr *weightedRoundRobin) rebuildLocked(servers []*types.Server) {
 	// Clear existing list
-	wrr.weightedServers = make([]*types.Server, 0)
+	wrr.weightedServers = make([]int, 0)
+	wrr.builtFrom = make([]serverState, 0, len(servers))
 	wrr.totalWeight = 0
 	
 	// Build new weighted list
-	for _, server := range servers {
+	for pos, server := range servers {
+		weight := wrr.weightOf(server)
+		wrr.builtFrom = append(wrr.builtFrom, serverState{server, weight, server.Healthy})
+		
 		if server.Healthy {
-			weight := server.Weight
 			if weight <= 0 {
 				weight = 1 // Default weight
 			}
 			
-			// Add server to list 'weight' times
+			// Add server's position to list 'weight' times
 			for i := 0; i < weight; i++ {
-				wrr.weightedServers = append(wrr.weightedServers, server)
+				wrr.weightedServers = append(wrr.weightedServers, pos)
 			}
 			
 			wrr.totalWeight += weight
@@ -144,10 +193,46 @@ func (wrr *weightedRoundRobin) rebuildWeightedList(servers []*types.Server) {
 	}
 }
 
+// serverState records the server, weight and health the weighted list was
+// built from
+type serverState struct {
+	server  *types.Server
+	weight  int
+	healthy bool
+}
+
+// isStale reports whether the weighted list was built from a different set of
+// servers, or from servers whose weight or health has since changed. Servers
+// are matched by ID and URL rather than identity, as callers may pass copies.
+func (wrr *weightedRoundRobin) isStale(servers []*types.Server) bool {
+	if len(servers) != len(wrr.builtFrom) {
+		return true
+	}
+	
+	for i, server := range servers {
+		state := wrr.builtFrom[i]
+		if state.server.ID != server.ID || !sameURL(state.server.URL, server.URL) ||
+			state.weight != wrr.weightOf(server) || state.healthy != server.Healthy {
+			return true
+		}
+	}
+	
+	return false
+}
+
+// sameURL reports whether two server URLs are the same
+func sameURL(a, b *url.URL) bool {
+	if a == b {
+		return true
+	}
+	return a != nil && b != nil && *a == *b
+}
+
 // smoothWeightedRoundRobin implements smooth weighted round-robin
 type smoothWeightedRoundRobin struct {
 	mu      sync.RWMutex
 	servers map[string]*weightedServer
+	weights map[string]int // Weights set by UpdateWeight, by server ID
 }
 
 type weightedServer struct {
@@ -160,6 +245,7 @@ type weightedServer struct {
 func NewSmoothWeightedRoundRobin() types.LoadBalancer {
 	return &smoothWeightedRoundRobin{
 		servers: make(map[string]*weightedServer),
+		weights: make(map[string]int),
 	}
 }
 
@@ -223,15 +309,19 @@ func (swrr *smoothWeightedRoundRobin) updateServers(servers []*types.Server) {
 	
 	// Add or update servers
 	for _, server := range servers {
+		weight := server.Weight
+		if override, ok := swrr.weights[server.ID]; ok {
+			weight = override
+		}
+		
 		if ws, exists := swrr.servers[server.ID]; exists {
 			// Update existing server
 			ws.Server = server
-			if server.Weight > 0 {
-				ws.effectiveWeight = server.Weight
+			if weight > 0 {
+				ws.effectiveWeight = weight
 			}
 		} else {
 			// Add new server
-			weight := server.Weight
 			if weight <= 0 {
 				weight = 1
 			}
@@ -253,6 +343,8 @@ func (swrr *smoothWeightedRoundRobin) Add(server *types.Server) error {
 	swrr.mu.Lock()
 	defer swrr.mu.Unlock()
 	
+	delete(swrr.weights, server.ID) // The added server's own weight applies
+	
 	weight := server.Weight
 	if weight <= 0 {
 		weight = 1
@@ -273,10 +365,13 @@ func (swrr *smoothWeightedRoundRobin) Remove(serverID string) error {
 	defer swrr.mu.Unlock()
 	
 	delete(swrr.servers, serverID)
+	delete(swrr.weights, serverID)
 	return nil
 }
 
-// UpdateWeight updates server weight
+// UpdateWeight updates server weight. It applies from the next Select and
+// takes precedence over the weight on servers passed to Select until the
+// server is added or removed again.
 func (swrr *smoothWeightedRoundRobin) UpdateWeight(serverID string, weight int) error {
 	if weight < 0 {
 		return types.ErrInvalidWeight
@@ -295,6 +390,12 @@ func (swrr *smoothWeightedRoundRobin) UpdateWeight(serverID string, weight int)
 	}
 	ws.effectiveWeight = weight
 	ws.Server.Weight = weight
+	swrr.weights[serverID] = weight
+	
+	// Start a fresh cycle so the new ratio isn't skewed by earlier picks
+	for _, other := range swrr.servers {
+		other.currentWeight = 0
+	}
 	
 	return nil
 }
@@ -0,0 +1,94 @@
+package balancer
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"discobox/internal/types"
+)
+
+// zonePreference keeps traffic within the caller's zone when it can
+type zonePreference struct {
+	base        types.LoadBalancer
+	header      string
+	defaultZone string
+}
+
+// NewZonePreference creates a load balancer that hands the base balancer
+// only the healthy backends tagged with the request's zone, falling back to
+// every backend when none are. The zone comes from the header named header,
+// or defaultZone when the request doesn't carry it; with neither, requests
+// go straight to the base balancer.
+func NewZonePreference(base types.LoadBalancer, header, defaultZone string) types.LoadBalancer {
+	return &zonePreference{
+		base:        base,
+		header:      http.CanonicalHeaderKey(header),
+		defaultZone: defaultZone,
+	}
+}
+
+// Select returns a server from the request's zone if one is healthy
+func (zp *zonePreference) Select(ctx context.Context, req *http.Request, servers []*types.Server) (*types.Server, error) {
+	server, _, err := zp.pick(ctx, req, servers, false)
+	return server, err
+}
+
+// SelectWithReason selects like Select and passes on the base balancer's
+// explanation, if it gives one
+func (zp *zonePreference) SelectWithReason(ctx context.Context, req *http.Request, servers []*types.Server) (*types.Server, *types.SelectionReason, error) {
+	return zp.pick(ctx, req, servers, true)
+}
+
+// pick narrows servers to the request's zone and selects with the base
+// balancer, asking it to explain the choice when explain is set
+func (zp *zonePreference) pick(ctx context.Context, req *http.Request, servers []*types.Server, explain bool) (*types.Server, *types.SelectionReason, error) {
+	zone := zp.requestZone(req)
+	if zone == "" {
+		return selectFrom(ctx, zp.base, req, servers, explain)
+	}
+
+	local := make([]*types.Server, 0, len(servers))
+	for _, server := range servers {
+		if server.Healthy && server.Metadata[types.TagZone] == zone {
+			local = append(local, server)
+		}
+	}
+	if len(local) == 0 {
+		return selectFrom(ctx, zp.base, req, servers, explain)
+	}
+
+	return selectFrom(ctx, zp.base, req, local, explain)
+}
+
+// requestZone returns the zone the request should be served from
+func (zp *zonePreference) requestZone(req *http.Request) string {
+	if zp.header != "" {
+		if zone := req.Header.Get(zp.header); zone != "" {
+			return zone
+		}
+	}
+	return zp.defaultZone
+}
+
+// Add adds a new server to the pool
+func (zp *zonePreference) Add(server *types.Server) error {
+	return zp.base.Add(server)
+}
+
+// Remove removes a server from the pool
+func (zp *zonePreference) Remove(serverID string) error {
+	return zp.base.Remove(serverID)
+}
+
+// UpdateWeight updates server weight
+func (zp *zonePreference) UpdateWeight(serverID string, weight int) error {
+	return zp.base.UpdateWeight(serverID, weight)
+}
+
+// ObserveLatency passes response times on to the base balancer if it uses them
+func (zp *zonePreference) ObserveLatency(serverID string, latency time.Duration) {
+	if observer, ok := zp.base.(types.LatencyObserver); ok {
+		observer.ObserveLatency(serverID, latency)
+	}
+}
@@ -77,6 +77,7 @@ func (cb *circuitBreaker) Reset() {
 type MultiCircuitBreaker struct {
 	mu       sync.RWMutex
 	breakers map[string]types.CircuitBreaker
+	applied  map[string]CircuitBreakerSettings // Settings each breaker was built with
 	settings CircuitBreakerSettings
 }
 
@@ -85,23 +86,57 @@ type CircuitBreakerSettings struct {
 	FailureThreshold int
 	SuccessThreshold int
 	Timeout          time.Duration
+	ServerErrors     bool // Count 5xx responses as failures; not overridable per service
+}
+
+// Override returns the settings with the non-zero fields of a service's
+// circuit breaker config applied
+func (s CircuitBreakerSettings) Override(c *types.CircuitBreakerConfig) CircuitBreakerSettings {
+	if c == nil {
+		return s
+	}
+	if c.FailureThreshold > 0 {
+		s.FailureThreshold = c.FailureThreshold
+	}
+	if c.SuccessThreshold > 0 {
+		s.SuccessThreshold = c.SuccessThreshold
+	}
+	if c.Timeout > 0 {
+		s.Timeout = c.Timeout
+	}
+	return s
 }
 
 // NewMultiCircuitBreaker creates a circuit breaker manager
 func NewMultiCircuitBreaker(settings CircuitBreakerSettings) *MultiCircuitBreaker {
 	return &MultiCircuitBreaker{
 		breakers: make(map[string]types.CircuitBreaker),
+		applied:  make(map[string]CircuitBreakerSettings),
 		settings: settings,
 	}
 }
 
 // GetBreaker returns a circuit breaker for the given service
 func (m *MultiCircuitBreaker) GetBreaker(serviceID string) types.CircuitBreaker {
+	return m.breaker(serviceID, m.settings)
+}
+
+// ForService returns the circuit breaker for a service, honouring its
+// overrides. Changing a service's overrides replaces its breaker, which
+// starts out closed.
+func (m *MultiCircuitBreaker) ForService(service *types.Service) types.CircuitBreaker {
+	return m.breaker(service.ID, m.settings.Override(service.CircuitBreaker))
+}
+
+// breaker returns the service's breaker if it was built with settings,
+// creating a new one otherwise
+func (m *MultiCircuitBreaker) breaker(serviceID string, settings CircuitBreakerSettings) types.CircuitBreaker {
 	m.mu.RLock()
 	breaker, exists := m.breakers[serviceID]
+	current := m.applied[serviceID]
 	m.mu.RUnlock()
 
-	if exists {
+	if exists && current == settings {
 		return breaker
 	}
 
@@ -110,17 +145,18 @@ func (m *MultiCircuitBreaker) GetBreaker(serviceID string) types.CircuitBreaker
 	defer m.mu.Unlock()
 
 	// Double-check after acquiring write lock
-	if breaker, exists := m.breakers[serviceID]; exists {
+	if breaker, exists := m.breakers[serviceID]; exists && m.applied[serviceID] == settings {
 		return breaker
 	}
 
 	breaker = NewCircuitBreaker(
-		m.settings.FailureThreshold,
-		m.settings.SuccessThreshold,
-		m.settings.Timeout,
+		settings.FailureThreshold,
+		settings.SuccessThreshold,
+		settings.Timeout,
 	)
 
 	m.breakers[serviceID] = breaker
+	m.applied[serviceID] = settings
 	return breaker
 }
 
@@ -129,6 +165,12 @@ func (m *MultiCircuitBreaker) RemoveBreaker(serviceID string) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	delete(m.breakers, serviceID)
+	delete(m.applied, serviceID)
+}
+
+// ServerErrors reports whether responses with a 5xx status count as failures
+func (m *MultiCircuitBreaker) ServerErrors() bool {
+	return m.settings.ServerErrors
 }
 
 // GetAllStates returns the states of all circuit breakers
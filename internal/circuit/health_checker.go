@@ -3,6 +3,8 @@ package circuit
 import (
 	"context"
 	"fmt"
+	"io"
+	"strings"
 	"sync"
 	"time"
 
@@ -20,6 +22,7 @@ type healthChecker struct {
 	passThreshold int
 	logger        types.Logger
 	client        *http.Client
+	probes        chan struct{} // Slots for probes in flight; nil is unbounded
 	mu            sync.RWMutex
 	healthStatus  map[string]*healthInfo
 	stopCh        chan struct{}
@@ -37,8 +40,14 @@ type healthInfo struct {
 	totalFailures    int64
 }
 
-// NewHealthChecker creates a new health checker
-func NewHealthChecker(interval, timeout time.Duration, failThreshold, passThreshold int, logger types.Logger) types.HealthChecker {
+// NewHealthChecker creates a new health checker. At most maxProbes active
+// checks run at once, the rest waiting their turn; 0 leaves them unbounded.
+func NewHealthChecker(interval, timeout time.Duration, failThreshold, passThreshold, maxProbes int, logger types.Logger) types.HealthChecker {
+	var probes chan struct{}
+	if maxProbes > 0 {
+		probes = make(chan struct{}, maxProbes)
+	}
+
 	return &healthChecker{
 		interval:      interval,
 		timeout:       timeout,
@@ -51,6 +60,7 @@ func NewHealthChecker(interval, timeout time.Duration, failThreshold, passThresh
 				return http.ErrUseLastResponse // Don't follow redirects
 			},
 		},
+		probes:       probes,
 		healthStatus: make(map[string]*healthInfo),
 		stopCh:       make(chan struct{}),
 	}
@@ -65,6 +75,18 @@ func (hc *healthChecker) Check(ctx context.Context, server *types.Server) error
 	}
 	defer atomic.StoreInt32(&info.checkInProgress, 0)
 
+	// Wait for a free probe slot
+	if hc.probes != nil {
+		select {
+		case hc.probes <- struct{}{}:
+			defer func() { <-hc.probes }()
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-hc.stopCh:
+			return nil
+		}
+	}
+
 	// Build health check URL
 	healthURL := server.URL.String()
 	if server.Metadata["health_path"] != "" {
@@ -89,20 +111,50 @@ func (hc *healthChecker) Check(ctx context.Context, server *types.Server) error
 	}
 	defer resp.Body.Close()
 
-	// Check status code
-	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-		hc.recordSuccess(server.ID)
-		hc.logger.Debug("health check passed",
-			"server_id", server.ID,
-			"url", healthURL,
-			"status", resp.StatusCode,
-			"duration", duration,
-		)
+	// Check the response against the server's criteria
+	if err := checkHealthResponse(resp, server.HealthCheck); err != nil {
+		return hc.recordFailure(server.ID, err)
+	}
+
+	hc.recordSuccess(server.ID)
+	hc.logger.Debug("health check passed",
+		"server_id", server.ID,
+		"url", healthURL,
+		"status", resp.StatusCode,
+		"duration", duration,
+	)
+	return nil
+}
+
+// maxHealthBodySize bounds how much of a health check body is searched
+const maxHealthBodySize = 64 << 10
+
+// checkHealthResponse returns an error unless resp has an accepted status
+// and, when criteria require it, a body containing the expected substring
+// and a JSON body with the expected value at the configured path
+func checkHealthResponse(resp *http.Response, criteria *types.HealthCheckConfig) error {
+	if !criteria.AcceptsStatus(resp.StatusCode) {
+		return fmt.Errorf("unhealthy status: %d", resp.StatusCode)
+	}
+
+	if criteria == nil || (criteria.BodyContains == "" && criteria.JSONPath == "") {
 		return nil
 	}
 
-	err = fmt.Errorf("unhealthy status: %d", resp.StatusCode)
-	return hc.recordFailure(server.ID, err)
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxHealthBodySize))
+	if err != nil {
+		return fmt.Errorf("failed to read health check body: %w", err)
+	}
+	if criteria.BodyContains != "" && !strings.Contains(string(body), criteria.BodyContains) {
+		return fmt.Errorf("health check body does not contain %q", criteria.BodyContains)
+	}
+	if criteria.JSONPath != "" {
+		if err := criteria.MatchesJSON(body); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 // Watch continuously monitors server health
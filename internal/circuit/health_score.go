@@ -0,0 +1,81 @@
+package circuit
+
+import (
+	"sync"
+	"time"
+
+	"discobox/internal/types"
+)
+
+// healthScorer rates backends from an exponentially weighted moving average
+// of their error rate and response time. Recent responses count the most, so
+// a backend's score recovers as it starts answering again.
+type healthScorer struct {
+	alpha            float64
+	latencyThreshold time.Duration
+	mu               sync.Mutex
+	hosts            map[string]*scoreInfo
+}
+
+type scoreInfo struct {
+	errorRate float64 // average of 1 for failures and 0 for successes
+	latency   float64 // average response time in nanoseconds
+}
+
+// NewHealthScorer creates a health scorer averaging over roughly the last
+// samples responses. A backend's score is its success rate, scaled down by
+// latencyThreshold over its average latency once that exceeds the threshold.
+// A latencyThreshold of zero ignores latency.
+func NewHealthScorer(samples int, latencyThreshold time.Duration) types.HealthScorer {
+	if samples < 1 {
+		samples = 1
+	}
+
+	return &healthScorer{
+		alpha:            2 / float64(samples+1),
+		latencyThreshold: latencyThreshold,
+		hosts:            make(map[string]*scoreInfo),
+	}
+}
+
+// RecordResult records a response or failure and how long it took
+func (hs *healthScorer) RecordResult(serverID string, failed bool, latency time.Duration) {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+
+	sample := 0.0
+	if failed {
+		sample = 1
+	}
+
+	info, exists := hs.hosts[serverID]
+	if !exists {
+		hs.hosts[serverID] = &scoreInfo{errorRate: sample, latency: float64(latency)}
+		return
+	}
+
+	info.errorRate += hs.alpha * (sample - info.errorRate)
+	info.latency += hs.alpha * (float64(latency) - info.latency)
+}
+
+// Score returns the backend's health from 0 (failing) to 1 (healthy).
+// Backends without recorded results score 1.
+func (hs *healthScorer) Score(serverID string) float64 {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+
+	info, exists := hs.hosts[serverID]
+	if !exists {
+		return 1
+	}
+
+	score := 1 - info.errorRate
+	if hs.latencyThreshold > 0 && info.latency > float64(hs.latencyThreshold) {
+		score *= float64(hs.latencyThreshold) / info.latency
+	}
+
+	if score < 0 {
+		return 0
+	}
+	return score
+}
@@ -0,0 +1,151 @@
+package circuit
+
+import (
+	"sync"
+	"time"
+
+	"discobox/internal/types"
+)
+
+// outlierDetector ejects backends that return consecutive errors within a
+// window. An ejected backend is re-admitted after its ejection time and its
+// next request acts as a probe: a failure ejects it again for longer, a
+// success returns it to the pool.
+type outlierDetector struct {
+	consecutiveErrors int
+	window            time.Duration
+	baseEjectionTime  time.Duration
+	maxEjectionTime   time.Duration
+	logger            types.Logger
+	mu                sync.Mutex
+	hosts             map[string]*outlierInfo
+}
+
+type outlierInfo struct {
+	consecutiveFails int
+	firstFailure     time.Time // start of the current failure streak
+	ejections        int       // ejections since the backend was last stable
+	ejectedUntil     time.Time // zero once a probe has passed
+	readmittedAt     time.Time
+}
+
+// probing reports whether the ejection has run out but no request has yet
+// shown whether the backend recovered
+func (info *outlierInfo) probing(now time.Time) bool {
+	return !info.ejectedUntil.IsZero() && !now.Before(info.ejectedUntil)
+}
+
+// NewOutlierDetector creates a passive outlier detector. A backend is ejected
+// after consecutiveErrors failures within window, for baseEjectionTime times
+// the number of times it has been ejected, capped at maxEjectionTime.
+func NewOutlierDetector(consecutiveErrors int, window, baseEjectionTime, maxEjectionTime time.Duration, logger types.Logger) types.OutlierDetector {
+	if maxEjectionTime < baseEjectionTime {
+		maxEjectionTime = baseEjectionTime
+	}
+
+	return &outlierDetector{
+		consecutiveErrors: consecutiveErrors,
+		window:            window,
+		baseEjectionTime:  baseEjectionTime,
+		maxEjectionTime:   maxEjectionTime,
+		logger:            logger,
+		hosts:             make(map[string]*outlierInfo),
+	}
+}
+
+// RecordSuccess records a request the backend answered
+func (od *outlierDetector) RecordSuccess(serverID string) {
+	od.mu.Lock()
+	defer od.mu.Unlock()
+
+	info, exists := od.hosts[serverID]
+	if !exists {
+		return
+	}
+
+	info.consecutiveFails = 0
+
+	now := time.Now()
+	if info.probing(now) {
+		info.ejectedUntil = time.Time{}
+		info.readmittedAt = now
+		od.logger.Info("ejected server passed probe, re-admitted",
+			"server_id", serverID,
+			"ejections", info.ejections,
+		)
+	}
+}
+
+// RecordFailure records a 5xx response or connection error
+func (od *outlierDetector) RecordFailure(serverID string, err error) {
+	od.mu.Lock()
+	defer od.mu.Unlock()
+
+	info, exists := od.hosts[serverID]
+	if !exists {
+		info = &outlierInfo{}
+		od.hosts[serverID] = info
+	}
+
+	now := time.Now()
+
+	// Requests that were in flight when the backend was ejected don't count
+	if now.Before(info.ejectedUntil) {
+		return
+	}
+
+	// A failed probe sends the backend straight back out
+	if info.probing(now) {
+		od.eject(serverID, info, now, err)
+		return
+	}
+
+	if info.consecutiveFails == 0 || now.Sub(info.firstFailure) > od.window {
+		info.consecutiveFails = 0
+		info.firstFailure = now
+	}
+	info.consecutiveFails++
+
+	if info.consecutiveFails >= od.consecutiveErrors {
+		od.eject(serverID, info, now, err)
+	}
+}
+
+// IsEjected reports whether the backend is currently out of the pool
+func (od *outlierDetector) IsEjected(serverID string) bool {
+	od.mu.Lock()
+	defer od.mu.Unlock()
+
+	info, exists := od.hosts[serverID]
+	if !exists {
+		return false
+	}
+
+	// Once the ejection runs out traffic flows again, probing the backend
+	return time.Now().Before(info.ejectedUntil)
+}
+
+// eject removes a backend from the pool. Ejection time grows with each
+// ejection and resets once the backend has stayed in the pool for the
+// maximum ejection time.
+func (od *outlierDetector) eject(serverID string, info *outlierInfo, now time.Time, err error) {
+	if !info.probing(now) && !info.readmittedAt.IsZero() && now.Sub(info.readmittedAt) > od.maxEjectionTime {
+		info.ejections = 0
+	}
+	info.ejections++
+
+	duration := od.baseEjectionTime * time.Duration(info.ejections)
+	if duration > od.maxEjectionTime {
+		duration = od.maxEjectionTime
+	}
+
+	info.ejectedUntil = now.Add(duration)
+	info.consecutiveFails = 0
+
+	od.logger.Warn("server ejected",
+		"server_id", serverID,
+		"ejections", info.ejections,
+		"duration", duration,
+		"error", err,
+	)
+}
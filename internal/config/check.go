@@ -0,0 +1,117 @@
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"discobox/internal/types"
+)
+
+// Check validates cfg as Validate does, then looks past the document at
+// what starting with it would need: the certificate, key and CA files it
+// names load, and the storage DSN parses for a storage type the server can
+// open. Unlike Validate it reports every problem it finds, each against the
+// field it concerns where that is known.
+func Check(cfg *types.ProxyConfig) []types.ValidationError {
+	var problems []types.ValidationError
+	add := func(field, format string, args ...any) {
+		problems = append(problems, types.ValidationError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if cfg.TLS.Enabled && !cfg.TLS.AutoCert && cfg.TLS.CertFile != "" && cfg.TLS.KeyFile != "" {
+		if _, err := tls.LoadX509KeyPair(cfg.TLS.CertFile, cfg.TLS.KeyFile); err != nil {
+			add("tls.cert_file", "failed to load tls.cert_file and tls.key_file: %v", err)
+		}
+	}
+	if cfg.TLS.Enabled {
+		for i, cert := range cfg.TLS.Certificates {
+			if cert.CertFile == "" || cert.KeyFile == "" {
+				continue
+			}
+			if _, err := tls.LoadX509KeyPair(cert.CertFile, cert.KeyFile); err != nil {
+				add(fmt.Sprintf("tls.certificates[%d]", i), "failed to load tls.certificates[%d]: %v", i, err)
+			}
+		}
+	}
+	if cfg.TLS.Enabled && cfg.TLS.ClientCAFile != "" {
+		if err := checkCAFile(cfg.TLS.ClientCAFile); err != nil {
+			add("tls.client_ca_file", "invalid tls.client_ca_file: %v", err)
+		}
+	}
+
+	if cfg.Middleware.Auth.JWT.Enabled && cfg.Middleware.Auth.JWT.KeyFile != "" {
+		if _, err := os.Stat(cfg.Middleware.Auth.JWT.KeyFile); err != nil {
+			add("middleware.auth.jwt.key_file", "invalid middleware.auth.jwt.key_file: %v", err)
+		}
+	}
+	if admin := cfg.API.AdminAuth; cfg.API.Enabled && admin.Type == "jwt" && admin.JWT.KeyFile != "" {
+		if _, err := os.Stat(admin.JWT.KeyFile); err != nil {
+			add("api.admin_auth.jwt.key_file", "invalid api.admin_auth.jwt.key_file: %v", err)
+		}
+	}
+
+	switch cfg.Storage.Type {
+	case "sqlite":
+		if err := checkSQLiteDSN(cfg.Storage.DSN); err != nil {
+			add("storage.dsn", "invalid storage.dsn: %v", err)
+		}
+	case "etcd":
+		add("storage.type", "etcd storage is not supported by the server yet")
+	}
+
+	// Validate stops at the first problem; skip it when the checks above
+	// already reported that field
+	if err := Validate(cfg); err != nil {
+		var field string
+		var fieldErr *FieldError
+		if errors.As(err, &fieldErr) {
+			field = fieldErr.Field
+		}
+		reported := false
+		for _, problem := range problems {
+			if field != "" && problem.Field == field {
+				reported = true
+				break
+			}
+		}
+		if !reported {
+			add(field, "%s", err.Error())
+		}
+	}
+
+	return problems
+}
+
+// checkCAFile reports whether path holds at least one PEM certificate
+func checkCAFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if !x509.NewCertPool().AppendCertsFromPEM(data) {
+		return fmt.Errorf("no PEM certificates in %s", path)
+	}
+	return nil
+}
+
+// checkSQLiteDSN parses a SQLite DSN: a file path or file: URI with
+// optional query parameters. An empty DSN uses the default database path.
+func checkSQLiteDSN(dsn string) error {
+	if dsn == "" {
+		return nil
+	}
+
+	path, query, _ := strings.Cut(strings.TrimPrefix(dsn, "file:"), "?")
+	if path == "" {
+		return fmt.Errorf("no database path in %q", dsn)
+	}
+	if _, err := url.ParseQuery(query); err != nil {
+		return fmt.Errorf("failed to parse parameters: %w", err)
+	}
+	return nil
+}
@@ -5,74 +5,150 @@ import (
 	"github.com/spf13/viper"
 )
 
-// setDefaults sets default configuration values
-func setDefaults() {
+// setDefaults sets default configuration values on v
+func setDefaults(v *viper.Viper) {
 	// Server defaults
-	viper.SetDefault("listen_addr", ":8080")
-	viper.SetDefault("read_timeout", "30s")
-	viper.SetDefault("write_timeout", "30s")
-	viper.SetDefault("idle_timeout", "120s")
-	viper.SetDefault("shutdown_timeout", "30s")
+	v.SetDefault("listen_addr", ":8080")
+	v.SetDefault("read_timeout", "30s")
+	v.SetDefault("write_timeout", "30s")
+	v.SetDefault("idle_timeout", "120s")
+	v.SetDefault("shutdown_timeout", "30s")
+	v.SetDefault("drain_timeout", "30s")
+	v.SetDefault("max_header_bytes", 1<<20)
+	v.SetDefault("max_connections", 0)
+	v.SetDefault("max_connections_mode", "wait")
+	v.SetDefault("trusted_proxy_depth", -1)
+
+	// Long-lived connection defaults
+	v.SetDefault("long_lived.exempt_timeouts", true)
+	v.SetDefault("long_lived.idle_timeout", "1h")
+	v.SetDefault("long_lived.shutdown_grace", "5s")
 
 	// TLS defaults
-	viper.SetDefault("tls.enabled", false)
-	viper.SetDefault("tls.min_version", "1.2")
+	v.SetDefault("tls.enabled", false)
+	v.SetDefault("tls.min_version", "1.2")
 
 	// HTTP/2 defaults
-	viper.SetDefault("http2.enabled", true)
+	v.SetDefault("http2.enabled", true)
 
 	// Transport defaults
-	viper.SetDefault("transport.max_idle_conns", 100)
-	viper.SetDefault("transport.max_idle_conns_per_host", 10)
-	viper.SetDefault("transport.max_conns_per_host", 100)
-	viper.SetDefault("transport.idle_conn_timeout", "90s")
-	viper.SetDefault("transport.dial_timeout", "30s")
-	viper.SetDefault("transport.keep_alive", "30s")
-	viper.SetDefault("transport.buffer_size", 32768)
+	v.SetDefault("transport.max_idle_conns", 100)
+	v.SetDefault("transport.max_idle_conns_per_host", 10)
+	v.SetDefault("transport.max_conns_per_host", 100)
+	v.SetDefault("transport.idle_conn_timeout", "90s")
+	v.SetDefault("transport.dial_timeout", "30s")
+	v.SetDefault("transport.keep_alive", "30s")
+	v.SetDefault("transport.response_header_timeout", "30s")
+	v.SetDefault("transport.buffer_size", 32768)
+	v.SetDefault("transport.dns.cache_ttl", "0s")
+	v.SetDefault("transport.dns.refresh_interval", "0s")
+
+	// Routing defaults
+	v.SetDefault("default_service_id", "")
+	v.SetDefault("forwarded_prefix", true)
+	v.SetDefault("error_format", "auto")
 
 	// Load balancing defaults
-	viper.SetDefault("load_balancing.algorithm", "round_robin")
-	viper.SetDefault("load_balancing.sticky.enabled", false)
-	viper.SetDefault("load_balancing.sticky.cookie_name", "lb_session")
-	viper.SetDefault("load_balancing.sticky.ttl", "30m")
+	v.SetDefault("load_balancing.algorithm", "round_robin")
+	v.SetDefault("load_balancing.sticky.enabled", false)
+	v.SetDefault("load_balancing.sticky.cookie_name", "lb_session")
+	v.SetDefault("load_balancing.sticky.ttl", "30m")
+	v.SetDefault("load_balancing.sticky.weighted", false)
+	v.SetDefault("load_balancing.websocket_affinity.enabled", false)
+	v.SetDefault("load_balancing.websocket_affinity.cookie_name", "")
+	v.SetDefault("load_balancing.websocket_affinity.ttl", "30m")
+	v.SetDefault("load_balancing.zone_preference.enabled", false)
+	v.SetDefault("load_balancing.zone_preference.header", "X-Client-Zone")
+	v.SetDefault("load_balancing.zone_preference.default_zone", "")
+	v.SetDefault("load_balancing.log_decisions", false)
+	v.SetDefault("load_balancing.adaptive_weights.enabled", false)
+	v.SetDefault("load_balancing.adaptive_weights.interval", "10s")
+	v.SetDefault("load_balancing.adaptive_weights.samples", 20)
+	v.SetDefault("load_balancing.adaptive_weights.min_weight", 1)
+	v.SetDefault("load_balancing.adaptive_weights.max_weight", 10)
 
 	// Health check defaults
-	viper.SetDefault("health_check.interval", "10s")
-	viper.SetDefault("health_check.timeout", "5s")
-	viper.SetDefault("health_check.fail_threshold", 3)
-	viper.SetDefault("health_check.pass_threshold", 2)
+	v.SetDefault("health_check.interval", "10s")
+	v.SetDefault("health_check.timeout", "5s")
+	v.SetDefault("health_check.fail_threshold", 3)
+	v.SetDefault("health_check.pass_threshold", 2)
+	v.SetDefault("health_check.retry_after", "10s")
+	v.SetDefault("health_check.max_probes", 32)
+	v.SetDefault("health_check.outlier.enabled", false)
+	v.SetDefault("health_check.outlier.consecutive_errors", 5)
+	v.SetDefault("health_check.outlier.window", "30s")
+	v.SetDefault("health_check.outlier.base_ejection_time", "30s")
+	v.SetDefault("health_check.outlier.max_ejection_time", "5m")
+	v.SetDefault("health_check.score.enabled", false)
+	v.SetDefault("health_check.score.samples", 20)
+	v.SetDefault("health_check.score.latency_threshold", "1s")
 
 	// Circuit breaker defaults
-	viper.SetDefault("circuit_breaker.enabled", true)
-	viper.SetDefault("circuit_breaker.failure_threshold", 5)
-	viper.SetDefault("circuit_breaker.success_threshold", 2)
-	viper.SetDefault("circuit_breaker.timeout", "60s")
+	v.SetDefault("circuit_breaker.enabled", true)
+	v.SetDefault("circuit_breaker.failure_threshold", 5)
+	v.SetDefault("circuit_breaker.success_threshold", 2)
+	v.SetDefault("circuit_breaker.timeout", "60s")
+	v.SetDefault("circuit_breaker.server_errors", false)
+
+	// Retry defaults
+	v.SetDefault("retry.enabled", false)
+	v.SetDefault("retry.max_attempts", 3)
+	v.SetDefault("retry.initial_delay", "100ms")
+	v.SetDefault("retry.max_delay", "5s")
 
 	// Rate limiting defaults
-	viper.SetDefault("rate_limit.enabled", false)
-	viper.SetDefault("rate_limit.rps", 100)
-	viper.SetDefault("rate_limit.burst", 200)
+	v.SetDefault("rate_limit.enabled", false)
+	v.SetDefault("rate_limit.rps", 100)
+	v.SetDefault("rate_limit.burst", 200)
 
 	// Middleware defaults
-	viper.SetDefault("middleware.compression.enabled", true)
-	viper.SetDefault("middleware.compression.level", 5)
-	viper.SetDefault("middleware.headers.security", true)
+	v.SetDefault("middleware.request_timeout", "0s")
+	v.SetDefault("middleware.compression.enabled", true)
+	v.SetDefault("middleware.compression.level", 5)
+	v.SetDefault("middleware.compression.min_size", 1024)
+	v.SetDefault("middleware.decompression.max_size", 10*1024*1024)
+	v.SetDefault("middleware.header_limits.max_count", 0)
+	v.SetDefault("middleware.header_limits.max_length", 0)
+	v.SetDefault("middleware.idempotency.enabled", false)
+	v.SetDefault("middleware.idempotency.ttl", "24h")
+	v.SetDefault("middleware.load_shedding.enabled", false)
+	v.SetDefault("middleware.load_shedding.max_concurrent", 0)
+	v.SetDefault("middleware.load_shedding.max_latency", "0s")
+	v.SetDefault("middleware.load_shedding.header", "X-QoS-Class")
+	v.SetDefault("middleware.load_shedding.default_class", "normal")
+	v.SetDefault("middleware.headers.security", true)
+	v.SetDefault("middleware.auth.trusted_header.enabled", false)
+	v.SetDefault("middleware.auth.trusted_header.user_header", "X-Authenticated-User")
+	v.SetDefault("middleware.auth.trusted_header.role_header", "X-Authenticated-Role")
 
 	// Logging defaults
-	viper.SetDefault("logging.level", "info")
-	viper.SetDefault("logging.format", "json")
-	viper.SetDefault("logging.access_logs", true)
+	v.SetDefault("logging.level", "info")
+	v.SetDefault("logging.format", "json")
+	v.SetDefault("logging.access_logs", true)
+	v.SetDefault("logging.access_log_file.max_size", 100)
+	v.SetDefault("logging.access_log_file.max_backups", 5)
+	v.SetDefault("logging.access_log_file.max_age", 30)
+	v.SetDefault("logging.access_log_file.compress", false)
+	v.SetDefault("logging.access_log_file.rotate_interval", "0s")
 
 	// Metrics defaults
-	viper.SetDefault("metrics.enabled", true)
-	viper.SetDefault("metrics.path", "/metrics")
+	v.SetDefault("metrics.enabled", true)
+	v.SetDefault("metrics.path", "/metrics")
+
+	// Debug header defaults
+	v.SetDefault("debug.enabled", false)
+	v.SetDefault("debug.admin_only", true)
 
 	// Storage defaults
-	viper.SetDefault("storage.type", "sqlite")
-	viper.SetDefault("storage.dsn", "discobox.db")
+	v.SetDefault("storage.type", "sqlite")
+	v.SetDefault("storage.dsn", "discobox.db")
 
 	// API defaults
-	viper.SetDefault("api.enabled", true)
-	viper.SetDefault("api.addr", ":8081")
-	viper.SetDefault("api.auth", false)
+	v.SetDefault("api.enabled", true)
+	v.SetDefault("api.addr", ":8081")
+	v.SetDefault("api.auth", false)
+	v.SetDefault("api.probe_endpoints", false)
+	v.SetDefault("api.storage_failure.mode", "closed")
+	v.SetDefault("api.storage_failure.grace_period", "30s")
+	v.SetDefault("api.password_reset.timeout", "5s")
 }
@@ -0,0 +1,310 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+
+	"discobox/internal/types"
+)
+
+// MetadataManagedBy is the metadata key marking services and routes that
+// came from the config directory. Only marked objects are ever deleted.
+const MetadataManagedBy = "managed_by"
+
+// managedByConfigDir is the MetadataManagedBy value for config directory objects
+const managedByConfigDir = "config_dir"
+
+// DirLoader keeps storage in sync with a directory of *.yml files. Each file
+// has the same services and routes lists as the main configuration file.
+type DirLoader struct {
+	dir     string
+	storage types.Storage
+	logger  types.Logger
+	mu      sync.Mutex // Serializes reconciles
+}
+
+// NewDirLoader creates a loader for the services and routes in dir
+func NewDirLoader(dir string, storage types.Storage, logger types.Logger) *DirLoader {
+	return &DirLoader{
+		dir:     dir,
+		storage: storage,
+		logger:  logger,
+	}
+}
+
+// Load reads the services and routes declared in the directory. A file that
+// can't be read fails the whole load, so its objects aren't mistaken for
+// removed ones.
+func (d *DirLoader) Load() ([]*types.Service, []*types.Route, error) {
+	files, err := filepath.Glob(filepath.Join(d.dir, "*.yml"))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list %s: %w", d.dir, err)
+	}
+
+	var services []*types.Service
+	var routes []*types.Route
+	serviceFiles := make(map[string]string)
+	routeFiles := make(map[string]string)
+
+	for _, file := range files {
+		v := viper.New()
+		v.SetConfigFile(file)
+		v.SetConfigType("yaml")
+		if err := v.ReadInConfig(); err != nil {
+			return nil, nil, fmt.Errorf("failed to read %s: %w", file, err)
+		}
+
+		servicesRaw, _ := v.Get("services").([]any)
+		for _, svcRaw := range servicesRaw {
+			svcMap, ok := svcRaw.(map[string]any)
+			if !ok {
+				continue
+			}
+			service := parseService(svcMap, d.logger)
+			if service.ID == "" {
+				return nil, nil, fmt.Errorf("service without an id in %s", file)
+			}
+			if other, exists := serviceFiles[service.ID]; exists {
+				return nil, nil, fmt.Errorf("service %s is declared in both %s and %s", service.ID, other, file)
+			}
+			serviceFiles[service.ID] = file
+			services = append(services, service)
+		}
+
+		routesRaw, _ := v.Get("routes").([]any)
+		for _, routeRaw := range routesRaw {
+			routeMap, ok := routeRaw.(map[string]any)
+			if !ok {
+				continue
+			}
+			route := parseRoute(routeMap, d.logger)
+			if route.ID == "" {
+				return nil, nil, fmt.Errorf("route without an id in %s", file)
+			}
+			if other, exists := routeFiles[route.ID]; exists {
+				return nil, nil, fmt.Errorf("route %s is declared in both %s and %s", route.ID, other, file)
+			}
+			routeFiles[route.ID] = file
+			routes = append(routes, route)
+		}
+	}
+
+	return services, routes, nil
+}
+
+// Reconcile creates, updates and deletes services and routes so storage
+// matches the directory. Objects created through the API are left alone
+// unless a file declares the same ID, in which case the file wins.
+func (d *DirLoader) Reconcile(ctx context.Context) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	services, routes, err := d.Load()
+	if err != nil {
+		return err
+	}
+
+	existingServices, err := d.storage.ListServices(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list services: %w", err)
+	}
+	existingRoutes, err := d.storage.ListRoutes(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list routes: %w", err)
+	}
+
+	currentServices := make(map[string]*types.Service, len(existingServices))
+	for _, service := range existingServices {
+		currentServices[service.ID] = service
+	}
+	currentRoutes := make(map[string]*types.Route, len(existingRoutes))
+	for _, route := range existingRoutes {
+		currentRoutes[route.ID] = route
+	}
+
+	var errs []error
+
+	// Services first so new routes have something to point at
+	wantServices := make(map[string]bool, len(services))
+	for _, service := range services {
+		wantServices[service.ID] = true
+		if service.Metadata == nil {
+			service.Metadata = make(map[string]string)
+		}
+		service.Metadata[MetadataManagedBy] = managedByConfigDir
+
+		current, exists := currentServices[service.ID]
+		switch {
+		case !exists:
+			if err := d.storage.CreateService(ctx, service); err != nil {
+				errs = append(errs, fmt.Errorf("failed to create service %s: %w", service.ID, err))
+				continue
+			}
+			d.logger.Info("created service from config directory", "id", service.ID)
+		case !serviceUnchanged(current, service):
+			if err := d.storage.UpdateService(ctx, service); err != nil {
+				errs = append(errs, fmt.Errorf("failed to update service %s: %w", service.ID, err))
+				continue
+			}
+			d.logger.Info("updated service from config directory", "id", service.ID)
+		}
+	}
+
+	wantRoutes := make(map[string]bool, len(routes))
+	for _, route := range routes {
+		wantRoutes[route.ID] = true
+		if route.Metadata == nil {
+			route.Metadata = make(map[string]any)
+		}
+		route.Metadata[MetadataManagedBy] = managedByConfigDir
+
+		current, exists := currentRoutes[route.ID]
+		switch {
+		case !exists:
+			if err := d.storage.CreateRoute(ctx, route); err != nil {
+				errs = append(errs, fmt.Errorf("failed to create route %s: %w", route.ID, err))
+				continue
+			}
+			d.logger.Info("created route from config directory", "id", route.ID, "service", route.ServiceID)
+		case !routeUnchanged(current, route):
+			if err := d.storage.UpdateRoute(ctx, route); err != nil {
+				errs = append(errs, fmt.Errorf("failed to update route %s: %w", route.ID, err))
+				continue
+			}
+			d.logger.Info("updated route from config directory", "id", route.ID, "service", route.ServiceID)
+		}
+	}
+
+	// Routes go before services, which may take their routes with them
+	for _, route := range existingRoutes {
+		if wantRoutes[route.ID] || route.Metadata[MetadataManagedBy] != managedByConfigDir {
+			continue
+		}
+		if err := d.storage.DeleteRoute(ctx, route.ID); err != nil && !errors.Is(err, types.ErrRouteNotFound) {
+			errs = append(errs, fmt.Errorf("failed to delete route %s: %w", route.ID, err))
+			continue
+		}
+		d.logger.Info("deleted route removed from config directory", "id", route.ID)
+	}
+
+	for _, service := range existingServices {
+		if wantServices[service.ID] || service.Metadata[MetadataManagedBy] != managedByConfigDir {
+			continue
+		}
+		if err := d.storage.DeleteService(ctx, service.ID); err != nil && !errors.Is(err, types.ErrServiceNotFound) {
+			errs = append(errs, fmt.Errorf("failed to delete service %s: %w", service.ID, err))
+			continue
+		}
+		d.logger.Info("deleted service removed from config directory", "id", service.ID)
+	}
+
+	return errors.Join(errs...)
+}
+
+// Watch reconciles whenever a *.yml file in the directory changes, until
+// ctx is cancelled
+func (d *DirLoader) Watch(ctx context.Context) error {
+	fsWatcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create file watcher: %w", err)
+	}
+	if err := fsWatcher.Add(d.dir); err != nil {
+		fsWatcher.Close()
+		return fmt.Errorf("failed to watch %s: %w", d.dir, err)
+	}
+
+	go func() {
+		defer fsWatcher.Close()
+
+		// Debounce timer so a batch of file changes reconciles once
+		var debounceTimer *time.Timer
+		debounceDuration := 500 * time.Millisecond
+
+		for {
+			select {
+			case <-ctx.Done():
+				if debounceTimer != nil {
+					debounceTimer.Stop()
+				}
+				return
+
+			case event, ok := <-fsWatcher.Events:
+				if !ok {
+					return
+				}
+				if event.Op == fsnotify.Chmod || filepath.Ext(event.Name) != ".yml" {
+					continue
+				}
+
+				d.logger.Debug("Config directory changed", "file", event.Name, "op", event.Op)
+
+				if debounceTimer != nil {
+					debounceTimer.Stop()
+				}
+				debounceTimer = time.AfterFunc(debounceDuration, func() {
+					if err := d.Reconcile(ctx); err != nil {
+						d.logger.Error("Failed to reconcile config directory", "dir", d.dir, "error", err)
+					}
+				})
+
+			case err, ok := <-fsWatcher.Errors:
+				if !ok {
+					return
+				}
+				d.logger.Error("Config directory watcher error", "error", err)
+			}
+		}
+	}()
+
+	d.logger.Info("Watching config directory", "dir", d.dir)
+	return nil
+}
+
+// serviceUnchanged reports whether updating current to desired would be a
+// no-op, ignoring the fields storage maintains
+func serviceUnchanged(current, desired *types.Service) bool {
+	candidate := *desired
+	candidate.Version = current.Version
+	candidate.CreatedAt = current.CreatedAt
+	candidate.UpdatedAt = current.UpdatedAt
+	return reflect.DeepEqual(&candidate, current)
+}
+
+// routeUnchanged reports whether updating current to desired would be a
+// no-op, ignoring the fields storage maintains. Metadata is compared as
+// storage keeps it, so an int from the file matches the float64 that
+// comes back from JSON.
+func routeUnchanged(current, desired *types.Route) bool {
+	candidate := *desired
+	candidate.Version = current.Version
+	candidate.Metadata = normalizeMetadata(desired.Metadata)
+	stored := *current
+	stored.Metadata = normalizeMetadata(current.Metadata)
+	return reflect.DeepEqual(&candidate, &stored)
+}
+
+// normalizeMetadata returns route metadata after a JSON round trip, with
+// empty metadata as nil
+func normalizeMetadata(metadata map[string]any) map[string]any {
+	if len(metadata) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return metadata
+	}
+	var normalized map[string]any
+	if err := json.Unmarshal(data, &normalized); err != nil {
+		return metadata
+	}
+	return normalized
+}
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"os"
@@ -48,7 +49,7 @@ func (l *Loader) LoadConfig() (*types.ProxyConfig, error) {
 	viper.AutomaticEnv()
 
 	// Set defaults
-	setDefaults()
+	setDefaults(viper.GetViper())
 
 	// Read configuration
 	if err := viper.ReadInConfig(); err != nil {
@@ -80,7 +81,7 @@ func LoadFromBytes(data []byte, format string) (*types.ProxyConfig, error) {
 	viper.SetConfigType(format)
 
 	// Set defaults
-	setDefaults()
+	setDefaults(viper.GetViper())
 
 	// Read from bytes
 	if err := viper.ReadConfig(strings.NewReader(string(data))); err != nil {
@@ -101,6 +102,38 @@ func LoadFromBytes(data []byte, format string) (*types.ProxyConfig, error) {
 	return &cfg, nil
 }
 
+// Parse reads a configuration document without validating it. Defaults and
+// DISCOBOX_ environment overrides apply as they do in LoadConfig, but the
+// process-wide configuration is left untouched.
+func Parse(data []byte, format string) (*types.ProxyConfig, error) {
+	v := viper.New()
+	v.SetConfigType(format)
+	v.SetEnvPrefix("DISCOBOX")
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.AutomaticEnv()
+	setDefaults(v)
+
+	if err := v.ReadConfig(bytes.NewReader(data)); err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	var cfg types.ProxyConfig
+	if err := v.Unmarshal(&cfg); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
+	}
+
+	return &cfg, nil
+}
+
+// ConfigFile returns the path of the configuration file, once one has been
+// found when no path was given
+func (l *Loader) ConfigFile() string {
+	if l.configPath != "" {
+		return l.configPath
+	}
+	return viper.ConfigFileUsed()
+}
+
 // SaveConfig saves the configuration to file
 func (l *Loader) SaveConfig(cfg *types.ProxyConfig) error {
 	// Validate before saving
@@ -153,57 +186,7 @@ func (l *Loader) LoadBootstrapData(storage types.Storage) error {
 					continue
 				}
 
-				service := &types.Service{}
-
-				// Parse service fields
-				if id, ok := svcMap["id"].(string); ok {
-					service.ID = id
-				}
-				if name, ok := svcMap["name"].(string); ok {
-					service.Name = name
-				}
-
-				// Parse endpoints
-				if endpointsRaw, ok := svcMap["endpoints"].([]any); ok {
-					for _, ep := range endpointsRaw {
-						if endpoint, ok := ep.(string); ok {
-							service.Endpoints = append(service.Endpoints, endpoint)
-						}
-					}
-				}
-
-				if healthPath, ok := svcMap["health_path"].(string); ok {
-					service.HealthPath = healthPath
-				}
-				if weight, ok := svcMap["weight"].(int); ok {
-					service.Weight = weight
-				}
-				if maxConns, ok := svcMap["max_conns"].(int); ok {
-					service.MaxConns = maxConns
-				}
-				if stripPrefix, ok := svcMap["strip_prefix"].(bool); ok {
-					service.StripPrefix = stripPrefix
-				}
-				if active, ok := svcMap["active"].(bool); ok {
-					service.Active = active
-				}
-
-				// Parse timeout
-				if timeoutStr, ok := svcMap["timeout"].(string); ok {
-					if duration, err := time.ParseDuration(timeoutStr); err == nil {
-						service.Timeout = duration
-					}
-				}
-
-				// Parse metadata
-				if metadataRaw, ok := svcMap["metadata"].(map[string]any); ok {
-					service.Metadata = make(map[string]string)
-					for k, v := range metadataRaw {
-						if strVal, ok := v.(string); ok {
-							service.Metadata[k] = strVal
-						}
-					}
-				}
+				service := parseService(svcMap, l.logger)
 
 				// Check if service exists
 				if _, err := storage.GetService(ctx, service.ID); err != nil {
@@ -229,38 +212,7 @@ func (l *Loader) LoadBootstrapData(storage types.Storage) error {
 					continue
 				}
 
-				route := &types.Route{}
-
-				// Parse route fields
-				if id, ok := routeMap["id"].(string); ok {
-					route.ID = id
-				}
-				if priority, ok := routeMap["priority"].(int); ok {
-					route.Priority = priority
-				}
-				if host, ok := routeMap["host"].(string); ok {
-					route.Host = host
-				}
-				if pathPrefix, ok := routeMap["path_prefix"].(string); ok {
-					route.PathPrefix = pathPrefix
-				}
-				if serviceID, ok := routeMap["service_id"].(string); ok {
-					route.ServiceID = serviceID
-				}
-
-				// Parse middlewares
-				if middlewaresRaw, ok := routeMap["middlewares"].([]any); ok {
-					for _, mw := range middlewaresRaw {
-						if middleware, ok := mw.(string); ok {
-							route.Middlewares = append(route.Middlewares, middleware)
-						}
-					}
-				}
-
-				// Parse metadata
-				if metadataRaw, ok := routeMap["metadata"].(map[string]any); ok {
-					route.Metadata = metadataRaw
-				}
+				route := parseRoute(routeMap, l.logger)
 
 				// Check if route exists
 				if _, err := storage.GetRoute(ctx, route.ID); err != nil {
@@ -278,6 +230,284 @@ func (l *Loader) LoadBootstrapData(storage types.Storage) error {
 	return nil
 }
 
+// parseService builds a service from its map form in a configuration file
+func parseService(svcMap map[string]any, logger types.Logger) *types.Service {
+	service := &types.Service{}
+
+	// Parse service fields
+	if id, ok := svcMap["id"].(string); ok {
+		service.ID = id
+	}
+	if name, ok := svcMap["name"].(string); ok {
+		service.Name = name
+	}
+
+	// Parse endpoints
+	if endpointsRaw, ok := svcMap["endpoints"].([]any); ok {
+		for _, ep := range endpointsRaw {
+			endpoint, ok := ep.(string)
+			if !ok {
+				continue
+			}
+			if err := types.ValidateEndpoint(endpoint); err != nil {
+				logger.Warn("ignoring invalid endpoint", "service", service.ID, "endpoint", endpoint, "error", err)
+				continue
+			}
+			service.Endpoints = append(service.Endpoints, endpoint)
+		}
+	}
+
+	if healthPath, ok := svcMap["health_path"].(string); ok {
+		service.HealthPath = healthPath
+	}
+	if weight, ok := svcMap["weight"].(int); ok {
+		service.Weight = weight
+	}
+	if maxConns, ok := svcMap["max_conns"].(int); ok {
+		service.MaxConns = maxConns
+	}
+	if stripPrefix, ok := svcMap["strip_prefix"].(bool); ok {
+		service.StripPrefix = stripPrefix
+	}
+	if upstreamScheme, ok := svcMap["upstream_scheme"].(string); ok {
+		if err := validateUpstreamScheme(upstreamScheme); err != nil {
+			logger.Warn("ignoring invalid upstream scheme", "service", service.ID, "error", err)
+		} else {
+			service.UpstreamScheme = upstreamScheme
+		}
+	}
+	if active, ok := svcMap["active"].(bool); ok {
+		service.Active = active
+	}
+
+	// Parse timeout
+	if timeoutStr, ok := svcMap["timeout"].(string); ok {
+		if duration, err := time.ParseDuration(timeoutStr); err == nil {
+			service.Timeout = duration
+		}
+	}
+
+	// Parse active health check criteria
+	if healthRaw, ok := svcMap["health_check"].(map[string]any); ok {
+		service.HealthCheck = &types.HealthCheckConfig{}
+		if codesRaw, ok := healthRaw["status_codes"].([]any); ok {
+			for _, code := range codesRaw {
+				if codeInt, ok := code.(int); ok {
+					service.HealthCheck.StatusCodes = append(service.HealthCheck.StatusCodes, codeInt)
+				}
+			}
+		}
+		if bodyContains, ok := healthRaw["body_contains"].(string); ok {
+			service.HealthCheck.BodyContains = bodyContains
+		}
+		if jsonPath, ok := healthRaw["json_path"].(string); ok {
+			service.HealthCheck.JSONPath = jsonPath
+		}
+		if jsonEquals, ok := healthRaw["json_equals"]; ok {
+			service.HealthCheck.JSONEquals = fmt.Sprint(jsonEquals)
+		}
+	}
+
+	// Parse circuit breaker overrides
+	if breakerRaw, ok := svcMap["circuit_breaker"].(map[string]any); ok {
+		service.CircuitBreaker = &types.CircuitBreakerConfig{}
+		if threshold, ok := breakerRaw["failure_threshold"].(int); ok {
+			service.CircuitBreaker.FailureThreshold = threshold
+		}
+		if threshold, ok := breakerRaw["success_threshold"].(int); ok {
+			service.CircuitBreaker.SuccessThreshold = threshold
+		}
+		if timeoutStr, ok := breakerRaw["timeout"].(string); ok {
+			if duration, err := time.ParseDuration(timeoutStr); err == nil {
+				service.CircuitBreaker.Timeout = duration
+			}
+		}
+	}
+
+	// Parse upstream TLS
+	if tlsRaw, ok := svcMap["tls"].(map[string]any); ok {
+		service.TLS = &types.TLSConfig{}
+		if enabled, ok := tlsRaw["enabled"].(bool); ok {
+			service.TLS.Enabled = enabled
+		}
+		if skip, ok := tlsRaw["insecure_skip_verify"].(bool); ok {
+			service.TLS.InsecureSkipVerify = skip
+		}
+		if serverName, ok := tlsRaw["server_name"].(string); ok {
+			service.TLS.ServerName = serverName
+		}
+		if rootCAsRaw, ok := tlsRaw["root_cas"].([]any); ok {
+			for _, ca := range rootCAsRaw {
+				if caStr, ok := ca.(string); ok {
+					service.TLS.RootCAs = append(service.TLS.RootCAs, caStr)
+				}
+			}
+		}
+		if clientCert, ok := tlsRaw["client_cert"].(string); ok {
+			service.TLS.ClientCert = clientCert
+		}
+		if clientKey, ok := tlsRaw["client_key"].(string); ok {
+			service.TLS.ClientKey = clientKey
+		}
+	}
+
+	// Parse metadata
+	if metadataRaw, ok := svcMap["metadata"].(map[string]any); ok {
+		service.Metadata = make(map[string]string)
+		for k, v := range metadataRaw {
+			if strVal, ok := v.(string); ok {
+				service.Metadata[k] = strVal
+			}
+		}
+	}
+
+	// Parse per-endpoint tags, keyed by endpoint URL
+	if tagsRaw, ok := svcMap["endpoint_tags"].(map[string]any); ok {
+		service.EndpointTags = make(map[string]map[string]string)
+		for endpoint, raw := range tagsRaw {
+			endpointTags, ok := raw.(map[string]any)
+			if !ok {
+				continue
+			}
+			tags := make(map[string]string)
+			for k, v := range endpointTags {
+				if strVal, ok := v.(string); ok {
+					tags[k] = strVal
+				}
+			}
+			service.EndpointTags[endpoint] = tags
+		}
+	}
+
+	return service
+}
+
+// parseRoute builds a route from its map form in a configuration file
+func parseRoute(routeMap map[string]any, logger types.Logger) *types.Route {
+	route := &types.Route{}
+
+	// Parse route fields
+	if id, ok := routeMap["id"].(string); ok {
+		route.ID = id
+	}
+	if group, ok := routeMap["group"].(string); ok {
+		route.Group = group
+	}
+	if priority, ok := routeMap["priority"].(int); ok {
+		route.Priority = priority
+	}
+	if host, ok := routeMap["host"].(string); ok {
+		route.Host = host
+	}
+	if pathPrefix, ok := routeMap["path_prefix"].(string); ok {
+		route.PathPrefix = pathPrefix
+	}
+	if contentType, ok := routeMap["content_type"].(string); ok {
+		route.ContentType = contentType
+	}
+	if suffixesRaw, ok := routeMap["path_suffixes"].([]any); ok {
+		for _, s := range suffixesRaw {
+			if suffix, ok := s.(string); ok {
+				route.PathSuffixes = append(route.PathSuffixes, suffix)
+			}
+		}
+	}
+	if serviceID, ok := routeMap["service_id"].(string); ok {
+		route.ServiceID = serviceID
+	}
+
+	// Parse middlewares
+	if middlewaresRaw, ok := routeMap["middlewares"].([]any); ok {
+		for _, mw := range middlewaresRaw {
+			if middleware, ok := mw.(string); ok {
+				route.Middlewares = append(route.Middlewares, middleware)
+			}
+		}
+	}
+
+	if disabledRaw, ok := routeMap["disabled_middlewares"].([]any); ok {
+		for _, mw := range disabledRaw {
+			if middleware, ok := mw.(string); ok {
+				route.DisabledMiddlewares = append(route.DisabledMiddlewares, middleware)
+			}
+		}
+	}
+
+	if stripPathPrefix, ok := routeMap["strip_path_prefix"].(string); ok {
+		route.StripPathPrefix = stripPathPrefix
+	}
+	if addPathPrefix, ok := routeMap["add_path_prefix"].(string); ok {
+		route.AddPathPrefix = addPathPrefix
+	}
+	if upstreamScheme, ok := routeMap["upstream_scheme"].(string); ok {
+		if err := validateUpstreamScheme(upstreamScheme); err != nil {
+			logger.Warn("ignoring invalid upstream scheme", "route", route.ID, "error", err)
+		} else {
+			route.UpstreamScheme = upstreamScheme
+		}
+	}
+	if maxResponseBytes, ok := routeMap["max_response_bytes"].(int); ok {
+		route.MaxResponseBytes = int64(maxResponseBytes)
+	}
+
+	// Parse TLS connection criteria
+	if sni, ok := routeMap["sni"].(string); ok {
+		route.SNI = sni
+	}
+	if subject, ok := routeMap["client_cert_subject"].(string); ok {
+		route.ClientCertSubject = subject
+	}
+
+	// Parse redirect handling
+	if redirectsRaw, ok := routeMap["redirects"].(map[string]any); ok {
+		route.Redirects = &types.RedirectPolicy{}
+		if mode, ok := redirectsRaw["mode"].(string); ok {
+			route.Redirects.Mode = mode
+		}
+		if maxHops, ok := redirectsRaw["max_hops"].(int); ok {
+			route.Redirects.MaxHops = maxHops
+		}
+	}
+
+	// Parse request hedging
+	if hedgingRaw, ok := routeMap["hedging"].(map[string]any); ok {
+		if delayStr, ok := hedgingRaw["delay"].(string); ok {
+			if delay, err := time.ParseDuration(delayStr); err == nil && delay > 0 {
+				route.Hedging = &types.HedgePolicy{Delay: delay}
+			} else {
+				logger.Warn("ignoring invalid hedging delay", "route", route.ID, "delay", delayStr)
+			}
+		}
+	}
+
+	// Parse early hints
+	if hintsRaw, ok := routeMap["early_hints"].([]any); ok {
+		for _, hint := range hintsRaw {
+			if link, ok := hint.(string); ok {
+				route.EarlyHints = append(route.EarlyHints, link)
+			}
+		}
+	}
+
+	// Parse canary traffic split
+	if canaryRaw, ok := routeMap["canary"].(map[string]any); ok {
+		serviceID, _ := canaryRaw["service_id"].(string)
+		weight, _ := canaryRaw["weight"].(int)
+		if serviceID != "" && weight >= 0 && weight <= 100 {
+			route.Canary = &types.CanaryPolicy{ServiceID: serviceID, Weight: weight}
+		} else {
+			logger.Warn("ignoring invalid canary", "route", route.ID, "service_id", serviceID, "weight", weight)
+		}
+	}
+
+	// Parse metadata
+	if metadataRaw, ok := routeMap["metadata"].(map[string]any); ok {
+		route.Metadata = metadataRaw
+	}
+
+	return route
+}
+
 // bootstrapAdminUser creates an admin user if none exists
 func (l *Loader) bootstrapAdminUser(storage types.Storage) error {
 	ctx := context.Background()
@@ -3,101 +3,291 @@ package config
 import (
 	"fmt"
 	"net"
+	"net/url"
+	"os"
 	"strings"
 	
+	"discobox/internal/middleware"
+	"discobox/internal/middleware/auth"
 	"discobox/internal/types"
 )
 
-// Validate validates a ProxyConfig
+// validAlgorithms are the load balancing algorithms the proxy can build
+var validAlgorithms = map[string]bool{
+	"round_robin": true,
+	"weighted":    true,
+	"least_conn":  true,
+	"ip_hash":     true,
+	"least_time":  true,
+}
+
+// Validate validates a ProxyConfig. The first problem found is returned as
+// a *FieldError naming the field.
 func Validate(cfg *types.ProxyConfig) error {
 	// Validate listen address
 	if cfg.ListenAddr == "" {
-		return fmt.Errorf("listen_addr is required")
+		return fieldError("listen_addr", "listen_addr is required")
 	}
 	
 	if _, _, err := net.SplitHostPort(cfg.ListenAddr); err != nil {
 		// Try adding default port
 		if _, _, err := net.SplitHostPort(cfg.ListenAddr + ":80"); err != nil {
-			return fmt.Errorf("invalid listen_addr: %w", err)
+			return fieldError("listen_addr", "invalid listen_addr: %w", err)
 		}
 	}
 	
+	if err := validateListenAddrs(cfg); err != nil {
+		return err
+	}
+	
 	// Validate timeouts
 	if cfg.ReadTimeout <= 0 {
-		return fmt.Errorf("read_timeout must be positive")
+		return fieldError("read_timeout", "read_timeout must be positive")
 	}
 	
 	if cfg.WriteTimeout <= 0 {
-		return fmt.Errorf("write_timeout must be positive")
+		return fieldError("write_timeout", "write_timeout must be positive")
 	}
 	
-	// Validate load balancing
-	validAlgorithms := map[string]bool{
-		"round_robin": true,
-		"weighted":    true,
-		"least_conn":  true,
-		"ip_hash":     true,
+	if cfg.DrainTimeout < 0 {
+		return fieldError("drain_timeout", "drain_timeout must not be negative")
+	}
+	
+	if cfg.LongLived.IdleTimeout < 0 {
+		return fieldError("long_lived.idle_timeout", "long_lived.idle_timeout must not be negative")
+	}
+	
+	if cfg.LongLived.ShutdownGrace < 0 {
+		return fieldError("long_lived.shutdown_grace", "long_lived.shutdown_grace must not be negative")
+	}
+	
+	// Validate header limits
+	if cfg.MaxHeaderBytes < 0 {
+		return fieldError("max_header_bytes", "max_header_bytes must not be negative")
+	}
+	
+	// Validate the services and routes directory
+	if cfg.ConfigDir != "" {
+		info, err := os.Stat(cfg.ConfigDir)
+		if err != nil {
+			return fieldError("config_dir", "invalid config_dir: %w", err)
+		}
+		if !info.IsDir() {
+			return fieldError("config_dir", "config_dir %s is not a directory", cfg.ConfigDir)
+		}
+	}
+	
+	// Validate connection limit
+	if cfg.MaxConnections < 0 {
+		return fieldError("max_connections", "max_connections must not be negative")
+	}
+	switch cfg.MaxConnectionsMode {
+	case "", "wait", "refuse":
+	default:
+		return fieldError("max_connections_mode", "invalid max_connections_mode: %s (must be wait or refuse)", cfg.MaxConnectionsMode)
+	}
+	if cfg.TrustedProxyDepth < -1 {
+		return fieldError("trusted_proxy_depth", "trusted_proxy_depth must be -1 or more")
+	}
+	
+	if cfg.Middleware.RequestTimeout < 0 {
+		return fieldError("middleware.request_timeout", "middleware.request_timeout must not be negative")
+	}
+	
+	if cfg.Middleware.HeaderLimits.MaxCount < 0 || cfg.Middleware.HeaderLimits.MaxLength < 0 {
+		return fieldError("middleware.header_limits", "middleware.header_limits values must not be negative")
+	}
+	
+	if shedding := cfg.Middleware.LoadShedding; shedding.Enabled {
+		if shedding.MaxConcurrent < 0 || shedding.MaxLatency < 0 {
+			return fieldError("middleware.load_shedding", "middleware.load_shedding limits must not be negative")
+		}
+		if shedding.MaxConcurrent == 0 && shedding.MaxLatency == 0 {
+			return fieldError("middleware.load_shedding", "middleware.load_shedding needs max_concurrent or max_latency")
+		}
+		switch shedding.DefaultClass {
+		case "", "high", "normal", "low":
+		default:
+			return fieldError("middleware.load_shedding.default_class", "invalid middleware.load_shedding.default_class: %s (must be high, normal or low)", shedding.DefaultClass)
+		}
+	}
+	
+	// Validate transport
+	if cfg.Transport.BufferSize <= 0 {
+		return fieldError("transport.buffer_size", "transport.buffer_size must be positive")
+	}
+	if cfg.Transport.ResponseHeaderTimeout < 0 {
+		return fieldError("transport.response_header_timeout", "transport.response_header_timeout must not be negative")
+	}
+	if cfg.Transport.DNS.CacheTTL < 0 || cfg.Transport.DNS.RefreshInterval < 0 {
+		return fieldError("transport.dns", "transport.dns durations must not be negative")
+	}
+	for _, server := range cfg.Transport.DNS.Servers {
+		if server == "" {
+			return fieldError("transport.dns.servers", "transport.dns.servers entries must not be empty")
+		}
+	}
+	
+	// Validate error format
+	switch cfg.ErrorFormat {
+	case "auto", "json", "text":
+	default:
+		return fieldError("error_format", "invalid error_format: %s (must be auto, json or text)", cfg.ErrorFormat)
 	}
 	
+	// Validate load balancing
 	if !validAlgorithms[cfg.LoadBalancing.Algorithm] {
-		return fmt.Errorf("invalid load balancing algorithm: %s", cfg.LoadBalancing.Algorithm)
+		return fieldError("load_balancing.algorithm", "invalid load_balancing.algorithm: %s", cfg.LoadBalancing.Algorithm)
+	}
+	
+	if adaptive := cfg.LoadBalancing.AdaptiveWeights; adaptive.Enabled {
+		if adaptive.Interval <= 0 {
+			return fieldError("load_balancing.adaptive_weights.interval", "load_balancing.adaptive_weights.interval must be positive")
+		}
+		
+		if adaptive.Samples <= 0 {
+			return fieldError("load_balancing.adaptive_weights.samples", "load_balancing.adaptive_weights.samples must be positive")
+		}
+		
+		if adaptive.MinWeight < 1 || adaptive.MaxWeight < adaptive.MinWeight {
+			return fieldError("load_balancing.adaptive_weights", "load_balancing.adaptive_weights needs 1 <= min_weight <= max_weight")
+		}
 	}
 	
 	// Validate health check
 	if cfg.HealthCheck.Interval <= 0 {
-		return fmt.Errorf("health_check.interval must be positive")
+		return fieldError("health_check.interval", "health_check.interval must be positive")
 	}
 	
 	if cfg.HealthCheck.Timeout <= 0 {
-		return fmt.Errorf("health_check.timeout must be positive")
+		return fieldError("health_check.timeout", "health_check.timeout must be positive")
 	}
 	
 	if cfg.HealthCheck.Timeout >= cfg.HealthCheck.Interval {
-		return fmt.Errorf("health_check.timeout must be less than interval")
+		return fieldError("health_check.timeout", "health_check.timeout must be less than interval")
 	}
 	
 	if cfg.HealthCheck.FailThreshold <= 0 {
-		return fmt.Errorf("health_check.fail_threshold must be positive")
+		return fieldError("health_check.fail_threshold", "health_check.fail_threshold must be positive")
 	}
 	
 	if cfg.HealthCheck.PassThreshold <= 0 {
-		return fmt.Errorf("health_check.pass_threshold must be positive")
+		return fieldError("health_check.pass_threshold", "health_check.pass_threshold must be positive")
+	}
+	
+	if cfg.HealthCheck.RetryAfter < 0 {
+		return fieldError("health_check.retry_after", "health_check.retry_after must not be negative")
+	}
+	
+	if cfg.HealthCheck.MaxProbes < 0 {
+		return fieldError("health_check.max_probes", "health_check.max_probes must not be negative")
+	}
+	
+	if cfg.HealthCheck.Outlier.Enabled {
+		if cfg.HealthCheck.Outlier.ConsecutiveErrors <= 0 {
+			return fieldError("health_check.outlier.consecutive_errors", "health_check.outlier.consecutive_errors must be positive")
+		}
+		
+		if cfg.HealthCheck.Outlier.Window <= 0 {
+			return fieldError("health_check.outlier.window", "health_check.outlier.window must be positive")
+		}
+		
+		if cfg.HealthCheck.Outlier.BaseEjectionTime <= 0 {
+			return fieldError("health_check.outlier.base_ejection_time", "health_check.outlier.base_ejection_time must be positive")
+		}
+		
+		if cfg.HealthCheck.Outlier.MaxEjectionTime < cfg.HealthCheck.Outlier.BaseEjectionTime {
+			return fieldError("health_check.outlier.max_ejection_time", "health_check.outlier.max_ejection_time must be >= base_ejection_time")
+		}
+	}
+	
+	if cfg.HealthCheck.Score.Enabled {
+		if cfg.HealthCheck.Score.Samples <= 0 {
+			return fieldError("health_check.score.samples", "health_check.score.samples must be positive")
+		}
+		
+		if cfg.HealthCheck.Score.LatencyThreshold < 0 {
+			return fieldError("health_check.score.latency_threshold", "health_check.score.latency_threshold must not be negative")
+		}
 	}
 	
 	// Validate circuit breaker
 	if cfg.CircuitBreaker.Enabled {
 		if cfg.CircuitBreaker.FailureThreshold <= 0 {
-			return fmt.Errorf("circuit_breaker.failure_threshold must be positive")
+			return fieldError("circuit_breaker.failure_threshold", "circuit_breaker.failure_threshold must be positive")
 		}
 		
 		if cfg.CircuitBreaker.SuccessThreshold <= 0 {
-			return fmt.Errorf("circuit_breaker.success_threshold must be positive")
+			return fieldError("circuit_breaker.success_threshold", "circuit_breaker.success_threshold must be positive")
 		}
 		
 		if cfg.CircuitBreaker.Timeout <= 0 {
-			return fmt.Errorf("circuit_breaker.timeout must be positive")
+			return fieldError("circuit_breaker.timeout", "circuit_breaker.timeout must be positive")
+		}
+	}
+	
+	// Validate retries
+	if cfg.Retry.Enabled {
+		if cfg.Retry.MaxAttempts <= 0 {
+			return fieldError("retry.max_attempts", "retry.max_attempts must be positive")
+		}
+		
+		if cfg.Retry.InitialDelay < 0 || cfg.Retry.MaxDelay < cfg.Retry.InitialDelay {
+			return fieldError("retry.max_delay", "retry.max_delay must be >= retry.initial_delay")
 		}
 	}
 	
 	// Validate rate limiting
 	if cfg.RateLimit.Enabled {
 		if cfg.RateLimit.RPS <= 0 {
-			return fmt.Errorf("rate_limit.rps must be positive")
+			return fieldError("rate_limit.rps", "rate_limit.rps must be positive")
 		}
 		
 		if cfg.RateLimit.Burst < cfg.RateLimit.RPS {
-			return fmt.Errorf("rate_limit.burst must be >= rps")
+			return fieldError("rate_limit.burst", "rate_limit.burst must be >= rps")
+		}
+		
+		keyBy := cfg.RateLimit.KeyBy
+		if header, ok := strings.CutPrefix(keyBy, middleware.RateLimitKeyHeaderPrefix); ok {
+			if strings.TrimSpace(header) == "" {
+				return fieldError("rate_limit.key_by", "rate_limit.key_by needs a header name, e.g. header:X-API-Key")
+			}
+		} else if keyBy != "" && keyBy != middleware.RateLimitKeyIP && keyBy != middleware.RateLimitKeyRoute {
+			return fieldError("rate_limit.key_by", "invalid rate_limit.key_by %q: must be ip, route or header:<name>", keyBy)
+		}
+	}
+	
+	if cfg.Middleware.Decompression.MaxSize <= 0 {
+		return fieldError("middleware.decompression.max_size", "middleware.decompression.max_size must be positive")
+	}
+	
+	// Validate trusted header authentication
+	if cfg.Middleware.Auth.TrustedHeader.Enabled {
+		if len(cfg.Middleware.Auth.TrustedHeader.TrustedProxies) == 0 {
+			return fieldError("middleware.auth.trusted_header.trusted_proxies", "middleware.auth.trusted_header.trusted_proxies is required when enabled")
+		}
+		
+		for _, entry := range cfg.Middleware.Auth.TrustedHeader.TrustedProxies {
+			if _, err := auth.ParseTrustedProxy(entry); err != nil {
+				return fieldError("middleware.auth.trusted_header.trusted_proxies", "invalid middleware.auth.trusted_header.trusted_proxies entry %q: %w", entry, err)
+			}
 		}
 	}
 	
 	// Validate TLS
 	if cfg.TLS.Enabled {
 		if !cfg.TLS.AutoCert && (cfg.TLS.CertFile == "" || cfg.TLS.KeyFile == "") {
-			return fmt.Errorf("tls.cert_file and tls.key_file are required when auto_cert is disabled")
+			return fieldError("tls.cert_file", "tls.cert_file and tls.key_file are required when auto_cert is disabled")
 		}
 		
 		if cfg.TLS.AutoCert && len(cfg.TLS.Domains) == 0 {
-			return fmt.Errorf("tls.domains are required when auto_cert is enabled")
+			return fieldError("tls.domains", "tls.domains are required when auto_cert is enabled")
+		}
+		
+		for i, cert := range cfg.TLS.Certificates {
+			if cert.CertFile == "" || cert.KeyFile == "" {
+				return fieldError(fmt.Sprintf("tls.certificates[%d]", i), "tls.certificates[%d]: cert_file and key_file are required", i)
+			}
 		}
 		
 		validVersions := map[string]bool{
@@ -108,10 +298,15 @@ func Validate(cfg *types.ProxyConfig) error {
 		}
 		
 		if !validVersions[cfg.TLS.MinVersion] {
-			return fmt.Errorf("invalid tls.min_version: %s", cfg.TLS.MinVersion)
+			return fieldError("tls.min_version", "invalid tls.min_version: %s", cfg.TLS.MinVersion)
 		}
 	}
 	
+	// HTTP/3 runs over QUIC, which always uses TLS
+	if cfg.HTTP3.Enabled && !cfg.TLS.Enabled {
+		return fieldError("http3", "http3 requires tls to be enabled")
+	}
+	
 	// Validate storage
 	validStorageTypes := map[string]bool{
 		"sqlite": true,
@@ -120,23 +315,56 @@ func Validate(cfg *types.ProxyConfig) error {
 	}
 	
 	if !validStorageTypes[cfg.Storage.Type] {
-		return fmt.Errorf("invalid storage.type: %s", cfg.Storage.Type)
+		return fieldError("storage.type", "invalid storage.type: %s", cfg.Storage.Type)
 	}
 	
 	// Validate API
 	if cfg.API.Enabled {
 		if cfg.API.Addr == "" {
-			return fmt.Errorf("api.addr is required when API is enabled")
+			return fieldError("api.addr", "api.addr is required when API is enabled")
 		}
 		
 		if _, _, err := net.SplitHostPort(cfg.API.Addr); err != nil {
 			// Try adding default port
 			if _, _, err := net.SplitHostPort(cfg.API.Addr + ":80"); err != nil {
-				return fmt.Errorf("invalid api.addr: %w", err)
+				return fieldError("api.addr", "invalid api.addr: %w", err)
+			}
+		}
+		
+		if err := validateAdminAuth(cfg); err != nil {
+			return err
+		}
+		
+		switch failure := cfg.API.StorageFailure; {
+		case failure.Mode != "" && failure.Mode != "open" && failure.Mode != "closed":
+			return fieldError("api.storage_failure.mode", "invalid api.storage_failure.mode: %s (must be open or closed)", failure.Mode)
+		case failure.GracePeriod < 0:
+			return fieldError("api.storage_failure.grace_period", "invalid api.storage_failure.grace_period: %s (must not be negative)", failure.GracePeriod)
+		}
+		
+		if webhook := cfg.API.PasswordReset.WebhookURL; webhook != "" {
+			u, err := url.Parse(webhook)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fieldError("api.password_reset.webhook_url", "invalid api.password_reset.webhook_url: %s (must be an http or https URL)", webhook)
 			}
 		}
 	}
 	
+	// Validate the UI base path and directory
+	if cfg.UI.Enabled && cfg.UI.Path != "" && !strings.HasPrefix(cfg.UI.Path, "/") {
+		return fieldError("ui.path", "ui.path must start with /")
+	}
+	
+	if cfg.UI.Enabled && cfg.UI.Dir != "" {
+		info, err := os.Stat(cfg.UI.Dir)
+		if err != nil {
+			return fieldError("ui.dir", "invalid ui.dir: %w", err)
+		}
+		if !info.IsDir() {
+			return fieldError("ui.dir", "ui.dir %s is not a directory", cfg.UI.Dir)
+		}
+	}
+	
 	// Validate logging
 	validLogLevels := map[string]bool{
 		"debug": true,
@@ -147,7 +375,7 @@ func Validate(cfg *types.ProxyConfig) error {
 	}
 	
 	if !validLogLevels[strings.ToLower(cfg.Logging.Level)] {
-		return fmt.Errorf("invalid logging.level: %s", cfg.Logging.Level)
+		return fieldError("logging.level", "invalid logging.level: %s", cfg.Logging.Level)
 	}
 	
 	validLogFormats := map[string]bool{
@@ -156,8 +384,98 @@ func Validate(cfg *types.ProxyConfig) error {
 	}
 	
 	if !validLogFormats[strings.ToLower(cfg.Logging.Format)] {
-		return fmt.Errorf("invalid logging.format: %s", cfg.Logging.Format)
+		return fieldError("logging.format", "invalid logging.format: %s", cfg.Logging.Format)
 	}
 	
+	if file := cfg.Logging.AccessLogFile; file.MaxSize < 0 || file.MaxBackups < 0 || file.MaxAge < 0 || file.RotateInterval < 0 {
+		return fieldError("logging.access_log_file", "logging.access_log_file values must not be negative")
+	}
+	
+	return nil
+}
+
+// validateListenAddrs checks the additional proxy addresses and that no two
+// addresses bind the same port. A wildcard host such as ":8080" or
+// "0.0.0.0:8080" clashes with any other address on its port.
+func validateListenAddrs(cfg *types.ProxyConfig) error {
+	wildcard := func(host string) bool {
+		return host == "" || host == "0.0.0.0" || host == "::"
+	}
+	
+	seen := make(map[string][]string) // Hosts by port
+	for i, addr := range cfg.ProxyListenAddrs() {
+		host, port, err := net.SplitHostPort(addr)
+		if i == 0 && err != nil {
+			// listen_addr is already checked and may omit the port
+			continue
+		}
+		if err != nil {
+			return fieldError("listen_addrs", "invalid listen_addrs entry %q: %w", addr, err)
+		}
+		if port == "0" {
+			// Each gets its own ephemeral port
+			continue
+		}
+		
+		for _, other := range seen[port] {
+			if host == other || wildcard(host) || wildcard(other) {
+				return fieldError("listen_addrs", "listen address %s is listed twice or overlaps another on port %s", addr, port)
+			}
+		}
+		seen[port] = append(seen[port], host)
+	}
+	return nil
+}
+
+// validateAdminAuth checks that the configured admin auth type has the
+// credentials it needs
+func validateAdminAuth(cfg *types.ProxyConfig) error {
+	admin := cfg.API.AdminAuth
+	switch admin.Type {
+	case "":
+	case "api_key", "bearer":
+		if admin.Token == "" {
+			return fieldError("api.admin_auth.token", "api.admin_auth.token is required for type %s", admin.Type)
+		}
+	case "basic":
+		if admin.Username == "" || admin.Password == "" {
+			return fieldError("api.admin_auth.username", "api.admin_auth.username and password are required for type basic")
+		}
+	case "jwt":
+		if admin.JWT.KeyFile == "" {
+			return fieldError("api.admin_auth.jwt.key_file", "api.admin_auth.jwt.key_file is required for type jwt")
+		}
+	default:
+		return fieldError("api.admin_auth.type", "invalid api.admin_auth.type: %s (must be api_key, bearer, basic or jwt)", admin.Type)
+	}
 	return nil
 }
+
+// validateUpstreamScheme checks a service or route upstream_scheme. Empty
+// keeps each endpoint's own scheme.
+func validateUpstreamScheme(scheme string) error {
+	switch scheme {
+	case "", "http", "https":
+		return nil
+	}
+	return fmt.Errorf("invalid upstream_scheme: %s (must be http or https)", scheme)
+}
+
+// FieldError is a Validate error about one config field
+type FieldError struct {
+	Field string // The field's path, e.g. tls.min_version
+	Err   error
+}
+
+func (e *FieldError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// fieldError returns a FieldError for field with a formatted message
+func fieldError(field, format string, args ...any) error {
+	return &FieldError{Field: field, Err: fmt.Errorf(format, args...)}
+}
@@ -0,0 +1,49 @@
+package logging
+
+import (
+	"discobox/internal/types"
+
+	"go.uber.org/zap"
+)
+
+// WrapZap wraps zap.Logger to implement types.Logger
+func WrapZap(zap *zap.Logger) types.Logger {
+	return &zapLoggerWrapper{zap: zap}
+}
+
+type zapLoggerWrapper struct {
+	zap *zap.Logger
+}
+
+func (z *zapLoggerWrapper) Debug(msg string, fields ...any) {
+	z.zap.Debug(msg, z.fieldsToZap(fields)...)
+}
+
+func (z *zapLoggerWrapper) Info(msg string, fields ...any) {
+	z.zap.Info(msg, z.fieldsToZap(fields)...)
+}
+
+func (z *zapLoggerWrapper) Warn(msg string, fields ...any) {
+	z.zap.Warn(msg, z.fieldsToZap(fields)...)
+}
+
+func (z *zapLoggerWrapper) Error(msg string, fields ...any) {
+	z.zap.Error(msg, z.fieldsToZap(fields)...)
+}
+
+func (z *zapLoggerWrapper) With(fields ...any) types.Logger {
+	return &zapLoggerWrapper{zap: z.zap.With(z.fieldsToZap(fields)...)}
+}
+
+func (z *zapLoggerWrapper) fieldsToZap(fields []any) []zap.Field {
+	var zapFields []zap.Field
+	for i := 0; i < len(fields); i += 2 {
+		if i+1 < len(fields) {
+			key, ok := fields[i].(string)
+			if ok {
+				zapFields = append(zapFields, zap.Any(key, fields[i+1]))
+			}
+		}
+	}
+	return zapFields
+}
@@ -2,6 +2,8 @@ package metrics
 
 import (
 	"runtime"
+	"sort"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -34,6 +36,9 @@ type Collector struct {
 	totalRequests   atomic.Uint64
 	totalErrors     atomic.Uint64
 	activeConns     atomic.Int64
+	statusClasses   [5]atomic.Uint64 // 1xx through 5xx
+	bytesIn         atomic.Uint64
+	bytesOut        atomic.Uint64
 	
 	// Latency tracking
 	latencies       []float64
@@ -43,10 +48,28 @@ type Collector struct {
 	cpuPercent      atomic.Value // float64
 	memoryUsage     atomic.Value // float64
 	
+	// Per-route counters
+	routes          sync.Map // route ID -> *routeCounters
+	
 	// Prometheus metrics
 	requestsTotal   *prometheus.CounterVec
 	requestDuration *prometheus.HistogramVec
 	errorRate       prometheus.Gauge
+	routeRequests   *prometheus.CounterVec
+	routeDuration   *prometheus.HistogramVec
+	routeTimeouts   *prometheus.CounterVec
+	routeRetries    *prometheus.CounterVec
+	routeHedges     *prometheus.CounterVec
+	routeCanceled   *prometheus.CounterVec
+	routeTruncated  *prometheus.CounterVec
+	unavailable     *prometheus.CounterVec
+	shed            *prometheus.CounterVec
+	backends        *prometheus.CounterVec
+	selections      *prometheus.CounterVec
+	bufferPoolGets  *prometheus.CounterVec
+	responses       *prometheus.CounterVec
+	requestSize     prometheus.Histogram
+	responseSize    prometheus.Histogram
 	
 	// Start time for rate calculations
 	startTime       time.Time
@@ -88,6 +111,127 @@ func NewCollector() *Collector {
 				Help: "Current error rate",
 			},
 		),
+		
+		routeRequests: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "discobox_route_requests_total",
+				Help: "Total number of proxied requests per route",
+			},
+			[]string{"route", "code"},
+		),
+		
+		routeDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "discobox_route_request_duration_seconds",
+				Help:    "Proxied request duration per route in seconds",
+				Buckets: prometheus.DefBuckets,
+			},
+			[]string{"route"},
+		),
+		
+		routeTimeouts: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "discobox_route_timeouts_total",
+				Help: "Total number of upstream timeouts per route",
+			},
+			[]string{"route"},
+		),
+		
+		routeRetries: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "discobox_route_retries_total",
+				Help: "Total number of retried requests per route",
+			},
+			[]string{"route"},
+		),
+		
+		routeHedges: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "discobox_route_hedges_total",
+				Help: "Total number of hedged requests per route, by whether the hedge was sent or won",
+			},
+			[]string{"route", "result"},
+		),
+		
+		routeCanceled: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "discobox_route_client_cancellations_total",
+				Help: "Total number of requests per route abandoned by the client before the backend answered",
+			},
+			[]string{"route"},
+		),
+		
+		routeTruncated: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "discobox_route_responses_truncated_total",
+				Help: "Total number of responses per route cut off at the route's max_response_bytes",
+			},
+			[]string{"route"},
+		),
+		
+		unavailable: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "discobox_service_unavailable_total",
+				Help: "Total number of requests rejected because a service was unavailable, by reason",
+			},
+			[]string{"service", "reason"},
+		),
+		
+		shed: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "discobox_requests_shed_total",
+				Help: "Total number of requests rejected by load shedding, by QoS class",
+			},
+			[]string{"class"},
+		),
+		
+		backends: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "discobox_backend_responses_total",
+				Help: "Total number of backend responses by service, backend, zone endpoint tag and status class",
+			},
+			[]string{"service", "backend", "zone", "class"},
+		),
+		
+		selections: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "discobox_backend_selections_total",
+				Help: "Total number of times the load balancer picked a backend, by service and the backend's endpoint index",
+			},
+			[]string{"service", "backend"},
+		),
+		
+		bufferPoolGets: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "discobox_buffer_pool_gets_total",
+				Help: "Total number of copy buffers taken from the pool, by whether one was reused (hit) or allocated (miss)",
+			},
+			[]string{"result"},
+		),
+		
+		responses: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "discobox_responses_total",
+				Help: "Total number of responses by status class (1xx to 5xx)",
+			},
+			[]string{"class"},
+		),
+		
+		requestSize: prometheus.NewHistogram(
+			prometheus.HistogramOpts{
+				Name:    "discobox_request_size_bytes",
+				Help:    "Size of request bodies read in bytes",
+				Buckets: sizeBuckets,
+			},
+		),
+		
+		responseSize: prometheus.NewHistogram(
+			prometheus.HistogramOpts{
+				Name:    "discobox_response_size_bytes",
+				Help:    "Size of response bodies written in bytes",
+				Buckets: sizeBuckets,
+			},
+		),
 	}
 	
 	// Initialize CPU and memory values
@@ -98,6 +242,21 @@ func NewCollector() *Collector {
 	_ = prometheus.Register(c.requestsTotal)
 	_ = prometheus.Register(c.requestDuration)
 	_ = prometheus.Register(c.errorRate)
+	_ = prometheus.Register(c.routeRequests)
+	_ = prometheus.Register(c.routeDuration)
+	_ = prometheus.Register(c.routeTimeouts)
+	_ = prometheus.Register(c.routeRetries)
+	_ = prometheus.Register(c.routeHedges)
+	_ = prometheus.Register(c.routeCanceled)
+	_ = prometheus.Register(c.routeTruncated)
+	_ = prometheus.Register(c.unavailable)
+	_ = prometheus.Register(c.shed)
+	_ = prometheus.Register(c.backends)
+	_ = prometheus.Register(c.selections)
+	_ = prometheus.Register(c.bufferPoolGets)
+	_ = prometheus.Register(c.responses)
+	_ = prometheus.Register(c.requestSize)
+	_ = prometheus.Register(c.responseSize)
 	
 	// Start system metrics updater
 	c.startSystemMetricsUpdater()
@@ -129,6 +288,224 @@ func (c *Collector) RecordRequest(method string, statusCode int, duration time.D
 	c.latenciesMu.Unlock()
 }
 
+// sizeBuckets are histogram buckets for body sizes, from 100 bytes to 100MB
+var sizeBuckets = prometheus.ExponentialBuckets(100, 10, 7)
+
+// StatusClass returns the class of a status code, such as "2xx", or "" for
+// codes outside 100-599
+func StatusClass(statusCode int) string {
+	if statusCode < 100 || statusCode > 599 {
+		return ""
+	}
+	return strconv.Itoa(statusCode/100) + "xx"
+}
+
+// RecordTraffic records a response's status class and the bytes read from
+// the request body and written to the response body
+func (c *Collector) RecordTraffic(statusCode int, bytesIn, bytesOut int64) {
+	if class := StatusClass(statusCode); class != "" {
+		c.statusClasses[statusCode/100-1].Add(1)
+		c.responses.WithLabelValues(class).Inc()
+	}
+	
+	c.bytesIn.Add(uint64(bytesIn))
+	c.bytesOut.Add(uint64(bytesOut))
+	c.requestSize.Observe(float64(bytesIn))
+	c.responseSize.Observe(float64(bytesOut))
+}
+
+// routeLatencyWindow is how many recent latencies are kept per route
+const routeLatencyWindow = 1000
+
+// routeCounters tracks the metrics for a single route
+type routeCounters struct {
+	requests    atomic.Uint64
+	errors      atomic.Uint64
+	timeouts    atomic.Uint64
+	retries     atomic.Uint64
+	latencies   []float64 // ring buffer of recent latencies in ms
+	next        int
+	latenciesMu sync.Mutex
+}
+
+// route returns the counters for a route, creating them on first use
+func (c *Collector) route(routeID string) *routeCounters {
+	if rc, ok := c.routes.Load(routeID); ok {
+		return rc.(*routeCounters)
+	}
+	rc, _ := c.routes.LoadOrStore(routeID, &routeCounters{
+		latencies: make([]float64, 0, routeLatencyWindow),
+	})
+	return rc.(*routeCounters)
+}
+
+// RecordRouteRequest records a proxied request for a route
+func (c *Collector) RecordRouteRequest(routeID string, statusCode int, duration time.Duration) {
+	rc := c.route(routeID)
+	rc.requests.Add(1)
+	if statusCode >= 500 {
+		rc.errors.Add(1)
+	}
+	
+	c.routeRequests.WithLabelValues(routeID, strconv.Itoa(statusCode)).Inc()
+	c.routeDuration.WithLabelValues(routeID).Observe(duration.Seconds())
+	
+	ms := duration.Seconds() * 1000
+	rc.latenciesMu.Lock()
+	if len(rc.latencies) < routeLatencyWindow {
+		rc.latencies = append(rc.latencies, ms)
+	} else {
+		rc.latencies[rc.next] = ms
+	}
+	rc.next = (rc.next + 1) % routeLatencyWindow
+	rc.latenciesMu.Unlock()
+}
+
+// RecordRouteTimeout records an upstream timeout for a route
+func (c *Collector) RecordRouteTimeout(routeID string) {
+	c.route(routeID).timeouts.Add(1)
+	c.routeTimeouts.WithLabelValues(routeID).Inc()
+}
+
+// RecordRouteRetry records a retried request for a route
+func (c *Collector) RecordRouteRetry(routeID string) {
+	c.route(routeID).retries.Add(1)
+	c.routeRetries.WithLabelValues(routeID).Inc()
+}
+
+// Outcomes recorded for hedged requests
+const (
+	HedgeSent = "sent"
+	HedgeWon  = "won"
+)
+
+// RecordRouteHedge records a hedged request for a route
+func (c *Collector) RecordRouteHedge(routeID, result string) {
+	c.routeHedges.WithLabelValues(routeID, result).Inc()
+}
+
+// RecordRouteClientCanceled records a request abandoned by its client
+// before the backend answered
+func (c *Collector) RecordRouteClientCanceled(routeID string) {
+	c.routeCanceled.WithLabelValues(routeID).Inc()
+}
+
+// RecordRouteResponseTruncated records a response cut off at the route's
+// size limit
+func (c *Collector) RecordRouteResponseTruncated(routeID string) {
+	c.routeTruncated.WithLabelValues(routeID).Inc()
+}
+
+// Reasons a request can be rejected with 503 before reaching a backend
+const (
+	UnavailableAllUnhealthy    = "all_backends_unhealthy"
+	UnavailableSaturated       = "backends_saturated" // Healthy backends are all at max_conns
+	UnavailableServiceNotFound = "service_not_found"
+)
+
+// RecordServiceUnavailable records a request rejected because its service
+// could not serve it
+func (c *Collector) RecordServiceUnavailable(serviceID, reason string) {
+	c.unavailable.WithLabelValues(serviceID, reason).Inc()
+}
+
+// RecordShed records a request of the given QoS class rejected by load
+// shedding
+func (c *Collector) RecordShed(class string) {
+	c.shed.WithLabelValues(class).Inc()
+}
+
+// RecordBackendResponse records a response from a backend, or the error
+// status returned in its place. zone is the backend's zone tag, if any.
+func (c *Collector) RecordBackendResponse(serviceID, serverID, zone string, statusCode int) {
+	class := StatusClass(statusCode)
+	if class == "" {
+		return
+	}
+	c.backends.WithLabelValues(serviceID, serverID, zone, class).Inc()
+}
+
+// RecordBackendSelection records the load balancer picking the backend at
+// index in a service's endpoints. Labeling by index rather than URL keeps
+// the series bounded as endpoints come and go.
+func (c *Collector) RecordBackendSelection(serviceID string, index int) {
+	c.selections.WithLabelValues(serviceID, strconv.Itoa(index)).Inc()
+}
+
+// DeleteBackendSelections drops a deleted service's selection counts
+func (c *Collector) DeleteBackendSelections(serviceID string) {
+	c.selections.DeletePartialMatch(prometheus.Labels{"service": serviceID})
+}
+
+// RecordBufferPoolGet records a copy buffer taken from the pool, hit
+// reporting whether it was reused rather than allocated
+func (c *Collector) RecordBufferPoolGet(hit bool) {
+	if hit {
+		c.bufferPoolGets.WithLabelValues("hit").Inc()
+	} else {
+		c.bufferPoolGets.WithLabelValues("miss").Inc()
+	}
+}
+
+// GetRouteStats returns statistics for a single route
+func (c *Collector) GetRouteStats(routeID string) RouteStats {
+	value, ok := c.routes.Load(routeID)
+	if !ok {
+		return RouteStats{}
+	}
+	rc := value.(*routeCounters)
+	
+	stats := RouteStats{
+		Requests: rc.requests.Load(),
+		Errors:   rc.errors.Load(),
+		Timeouts: rc.timeouts.Load(),
+		Retries:  rc.retries.Load(),
+	}
+	if stats.Requests > 0 {
+		stats.ErrorRate = float64(stats.Errors) / float64(stats.Requests) * 100
+	}
+	
+	rc.latenciesMu.Lock()
+	sorted := make([]float64, len(rc.latencies))
+	copy(sorted, rc.latencies)
+	rc.latenciesMu.Unlock()
+	
+	sort.Float64s(sorted)
+	stats.P50LatencyMs = percentile(sorted, 50)
+	stats.P95LatencyMs = percentile(sorted, 95)
+	stats.P99LatencyMs = percentile(sorted, 99)
+	
+	return stats
+}
+
+// RouteStats holds the metrics for a single route
+type RouteStats struct {
+	Requests     uint64  `json:"requests"`
+	Errors       uint64  `json:"errors"`
+	ErrorRate    float64 `json:"error_rate"`
+	Timeouts     uint64  `json:"timeouts"`
+	Retries      uint64  `json:"retries"`
+	P50LatencyMs float64 `json:"p50_latency_ms"`
+	P95LatencyMs float64 `json:"p95_latency_ms"`
+	P99LatencyMs float64 `json:"p99_latency_ms"`
+}
+
+// percentile returns the p-th percentile of sorted values
+func percentile(sorted []float64, p int) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	
+	index := (len(sorted)*p + 99) / 100 - 1
+	if index < 0 {
+		index = 0
+	}
+	if index >= len(sorted) {
+		index = len(sorted) - 1
+	}
+	return sorted[index]
+}
+
 // IncrementActiveConnections increments active connection count
 func (c *Collector) IncrementActiveConnections() {
 	c.activeConns.Add(1)
@@ -157,6 +534,11 @@ func (c *Collector) GetStats() Stats {
 	// Update error rate gauge
 	c.errorRate.Set(errorRate)
 	
+	byStatus := make(map[string]uint64, len(c.statusClasses))
+	for i := range c.statusClasses {
+		byStatus[StatusClass((i+1)*100)] = c.statusClasses[i].Load()
+	}
+	
 	return Stats{
 		TotalRequests:    total,
 		TotalErrors:      errors,
@@ -170,6 +552,9 @@ func (c *Collector) GetStats() Stats {
 		CPUPercent:       c.cpuPercent.Load().(float64),
 		MemoryUsageMB:    c.memoryUsage.Load().(float64),
 		Uptime:           time.Since(c.startTime),
+		ByStatus:         byStatus,
+		BytesIn:          c.bytesIn.Load(),
+		BytesOut:         c.bytesOut.Load(),
 	}
 }
 
@@ -187,6 +572,9 @@ type Stats struct {
 	CPUPercent        float64       `json:"cpu_percent"`
 	MemoryUsageMB     float64       `json:"memory_usage_mb"`
 	Uptime            time.Duration `json:"uptime"`
+	ByStatus          map[string]uint64 `json:"by_status"` // Responses by status class, 1xx to 5xx
+	BytesIn           uint64        `json:"bytes_in"`      // Request body bytes read
+	BytesOut          uint64        `json:"bytes_out"`     // Response body bytes written
 }
 
 // calculateAvgLatency calculates average latency
@@ -263,9 +651,15 @@ func (c *Collector) GetMemoryStats() runtime.MemStats {
 func (c *Collector) Reset() {
 	c.totalRequests.Store(0)
 	c.totalErrors.Store(0)
+	for i := range c.statusClasses {
+		c.statusClasses[i].Store(0)
+	}
+	c.bytesIn.Store(0)
+	c.bytesOut.Store(0)
 	c.latenciesMu.Lock()
 	c.latencies = c.latencies[:0]
 	c.latenciesMu.Unlock()
+	c.routes.Clear()
 	c.lastResetTime = time.Now()
 }
 
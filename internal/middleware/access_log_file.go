@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"discobox/internal/logging"
+	"discobox/internal/types"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// AccessLogFile is a rotating file for access logs, set up from
+// logging.access_log_file. It rotates once it reaches max_size megabytes,
+// and also every rotate_interval when that is set.
+type AccessLogFile struct {
+	file   *lumberjack.Logger
+	logger types.Logger
+	stopCh chan struct{}
+	once   sync.Once
+}
+
+// NewAccessLogFile opens the access log file named in the config. Entries
+// are written in logging.format, JSON unless it is text.
+func NewAccessLogFile(config types.ProxyConfig) *AccessLogFile {
+	settings := config.Logging.AccessLogFile
+	alf := &AccessLogFile{
+		file: &lumberjack.Logger{
+			Filename:   settings.Path,
+			MaxSize:    settings.MaxSize,
+			MaxBackups: settings.MaxBackups,
+			MaxAge:     settings.MaxAge,
+			Compress:   settings.Compress,
+		},
+		stopCh: make(chan struct{}),
+	}
+
+	encoderConfig := zap.NewProductionEncoderConfig()
+	encoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+	encoder := zapcore.NewJSONEncoder(encoderConfig)
+	if strings.EqualFold(config.Logging.Format, "text") {
+		encoder = zapcore.NewConsoleEncoder(encoderConfig)
+	}
+	core := zapcore.NewCore(encoder, zapcore.AddSync(alf.file), zapcore.InfoLevel)
+	alf.logger = logging.WrapZap(zap.New(core))
+
+	if settings.RotateInterval > 0 {
+		go alf.rotateEvery(settings.RotateInterval)
+	}
+
+	return alf
+}
+
+// Logger returns a logger writing to the file, for AccessLogging
+func (alf *AccessLogFile) Logger() types.Logger {
+	return alf.logger
+}
+
+// Rotate closes the current file, moves it aside with a timestamp in its
+// name and starts a new one
+func (alf *AccessLogFile) Rotate() error {
+	return alf.file.Rotate()
+}
+
+// Close stops timed rotation and closes the file
+func (alf *AccessLogFile) Close() error {
+	alf.once.Do(func() {
+		close(alf.stopCh)
+	})
+	return alf.file.Close()
+}
+
+// rotateEvery rotates the file each interval until Close
+func (alf *AccessLogFile) rotateEvery(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			alf.file.Rotate()
+		case <-alf.stopCh:
+			return
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"discobox/internal/types"
+)
+
+// apiKeyCheckTTL is how long an API key's check is reused
+const apiKeyCheckTTL = 30 * time.Second
+
+// apiKeyCacheSize caps the keys checked within apiKeyCheckTTL that are
+// remembered, so a flood of made-up keys can't grow the cache
+const apiKeyCacheSize = 1024
+
+// apiKeyCheck is what storage said about an API key
+type apiKeyCheck struct {
+	valid bool // Active and unexpired, with an active user
+	admin bool // Valid, and the user is an admin
+}
+
+// apiKeyCache remembers API key checks for apiKeyCheckTTL, so middleware
+// looking at keys on every request doesn't cost storage lookups each time.
+// Keys are kept by their hash so the cache holds no secrets.
+type apiKeyCache struct {
+	storage   types.Storage
+	mu        sync.Mutex
+	entries   map[[sha256.Size]byte]apiKeyEntry
+	lastPrune atomic.Int64 // Unix nanoseconds of the last prune
+}
+
+type apiKeyEntry struct {
+	check   apiKeyCheck
+	expires time.Time
+}
+
+func newAPIKeyCache(storage types.Storage) *apiKeyCache {
+	return &apiKeyCache{storage: storage, entries: make(map[[sha256.Size]byte]apiKeyEntry)}
+}
+
+// check looks apiKey up, asking storage only when the key wasn't checked
+// within apiKeyCheckTTL
+func (c *apiKeyCache) check(ctx context.Context, apiKey string) apiKeyCheck {
+	if apiKey == "" || c.storage == nil {
+		return apiKeyCheck{}
+	}
+	now := time.Now()
+	c.prune(now)
+
+	hash := sha256.Sum256([]byte(apiKey))
+	c.mu.Lock()
+	entry, ok := c.entries[hash]
+	c.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		return entry.check
+	}
+
+	check := lookupAPIKey(ctx, apiKey, c.storage)
+	if ctx.Err() == nil {
+		c.mu.Lock()
+		if _, ok := c.entries[hash]; ok || len(c.entries) < apiKeyCacheSize {
+			c.entries[hash] = apiKeyEntry{check: check, expires: now.Add(apiKeyCheckTTL)}
+		}
+		c.mu.Unlock()
+	}
+	return check
+}
+
+// prune drops expired checks, looking at most once every apiKeyCheckTTL
+func (c *apiKeyCache) prune(now time.Time) {
+	last := c.lastPrune.Load()
+	if now.UnixNano()-last < int64(apiKeyCheckTTL) || !c.lastPrune.CompareAndSwap(last, now.UnixNano()) {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for hash, entry := range c.entries {
+		if !now.Before(entry.expires) {
+			delete(c.entries, hash)
+		}
+	}
+}
+
+// lookupAPIKey checks apiKey and its user in storage
+func lookupAPIKey(ctx context.Context, apiKey string, storage types.Storage) apiKeyCheck {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+
+	key, err := storage.GetAPIKey(ctx, apiKey)
+	if err != nil || !key.Active || (key.ExpiresAt != nil && key.ExpiresAt.Before(time.Now())) {
+		return apiKeyCheck{}
+	}
+
+	user, err := storage.GetUser(ctx, key.UserID)
+	if err != nil || !user.Active {
+		return apiKeyCheck{}
+	}
+	return apiKeyCheck{valid: true, admin: user.IsAdmin}
+}
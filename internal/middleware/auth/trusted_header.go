@@ -0,0 +1,134 @@
+package auth
+
+import (
+	"context"
+	"discobox/internal/types"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Default identity headers set by an upstream auth gateway
+const (
+	DefaultUserHeader = "X-Authenticated-User"
+	DefaultRoleHeader = "X-Authenticated-Role"
+)
+
+// Identity is the authenticated user attached to a request
+type Identity struct {
+	User string
+	Role string
+}
+
+// Context keys for authentication
+type contextKey string
+
+const identityKey contextKey = "identity"
+
+// ContextWithIdentity adds an identity to the context
+func ContextWithIdentity(ctx context.Context, identity Identity) context.Context {
+	return context.WithValue(ctx, identityKey, identity)
+}
+
+// IdentityFromContext retrieves the identity from the context
+func IdentityFromContext(ctx context.Context) (Identity, bool) {
+	identity, ok := ctx.Value(identityKey).(Identity)
+	return identity, ok
+}
+
+// TrustedHeader creates middleware that accepts identity headers set by an
+// upstream auth gateway. The headers are only honoured when the connecting
+// peer is one of the configured trusted proxies; from any other peer they are
+// stripped so clients cannot impersonate users.
+func TrustedHeader(config types.ProxyConfig) types.Middleware {
+	cfg := config.Middleware.Auth.TrustedHeader
+
+	userHeader := cfg.UserHeader
+	if userHeader == "" {
+		userHeader = DefaultUserHeader
+	}
+	roleHeader := cfg.RoleHeader
+	if roleHeader == "" {
+		roleHeader = DefaultRoleHeader
+	}
+
+	trusted := parseTrustedProxies(cfg.TrustedProxies)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !isTrustedPeer(r.RemoteAddr, trusted) {
+				r.Header.Del(userHeader)
+				r.Header.Del(roleHeader)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			user := strings.TrimSpace(r.Header.Get(userHeader))
+			if user == "" {
+				// The gateway let the request through without a user
+				r.Header.Del(roleHeader)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			identity := Identity{
+				User: user,
+				Role: strings.TrimSpace(r.Header.Get(roleHeader)),
+			}
+
+			next.ServeHTTP(w, r.WithContext(ContextWithIdentity(r.Context(), identity)))
+		})
+	}
+}
+
+// parseTrustedProxies converts CIDRs and bare IPs into networks. Invalid
+// entries are skipped; they are rejected when the config is validated.
+func parseTrustedProxies(entries []string) []*net.IPNet {
+	var networks []*net.IPNet
+	for _, entry := range entries {
+		if network, err := ParseTrustedProxy(entry); err == nil {
+			networks = append(networks, network)
+		}
+	}
+	return networks
+}
+
+// ParseTrustedProxy parses a trusted proxy entry, either a CIDR or a single IP
+func ParseTrustedProxy(entry string) (*net.IPNet, error) {
+	entry = strings.TrimSpace(entry)
+	if !strings.Contains(entry, "/") {
+		ip := net.ParseIP(entry)
+		if ip == nil {
+			return nil, &net.ParseError{Type: "IP address", Text: entry}
+		}
+		bits := 128
+		if ip4 := ip.To4(); ip4 != nil {
+			ip, bits = ip4, 32
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+
+	_, network, err := net.ParseCIDR(entry)
+	return network, err
+}
+
+// isTrustedPeer reports whether the connecting address is a trusted proxy.
+// Only the socket peer is considered; X-Forwarded-For is client controlled.
+func isTrustedPeer(remoteAddr string, trusted []*net.IPNet) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+
+	for _, network := range trusted {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,21 @@
+package middleware
+
+import (
+	"net/http"
+
+	"discobox/internal/types"
+)
+
+// ClientIP creates middleware that resolves each request's client IP once,
+// trusting depth proxies in front of discobox to have appended to
+// X-Forwarded-For, and records it in the request context. Everything
+// further in, from logging and rate limiting to load balancing, then sees
+// the same client for the request. A negative depth trusts every entry.
+func ClientIP(depth int) types.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := types.ResolveClientIP(r, depth)
+			next.ServeHTTP(w, r.WithContext(types.ContextWithClientIP(r.Context(), ip)))
+		})
+	}
+}
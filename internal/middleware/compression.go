@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"io"
+	"strconv"
 	"strings"
 	"sync"
 
@@ -14,24 +15,25 @@ import (
 	"discobox/internal/types"
 )
 
-// compressionWriter wraps response writer with compression
-type compressionWriter struct {
-	http.ResponseWriter
-	writer io.WriteCloser
-}
-
-func (cw *compressionWriter) Write(b []byte) (int, error) {
-	return cw.writer.Write(b)
-}
+// defaultMinCompressSize is used when no minimum size is configured. Bodies
+// smaller than this usually grow once compression framing is added.
+const defaultMinCompressSize = 1024
 
-func (cw *compressionWriter) Close() error {
-	return cw.writer.Close()
+// encoder is a streaming compressor
+type encoder interface {
+	io.WriteCloser
+	Flush() error
 }
 
 // Compression creates compression middleware
 func Compression(config types.ProxyConfig) types.Middleware {
 	cfg := config.Middleware.Compression
 
+	minSize := cfg.MinSize
+	if minSize <= 0 {
+		minSize = defaultMinCompressSize
+	}
+
 	// Create set of compressible types
 	compressibleTypes := make(map[string]bool)
 	for _, t := range cfg.Types {
@@ -53,92 +55,239 @@ func Compression(config types.ProxyConfig) types.Middleware {
 				return
 			}
 
+			// Byte ranges refer to the identity encoding, upgraded connections
+			// aren't HTTP bodies, and HEAD responses keep the backend's
+			// Content-Length for a body that is never sent
+			if r.Method == http.MethodHead || r.Header.Get("Range") != "" || r.Header.Get("Upgrade") != "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
 			// Determine best encoding
 			var encoding string
-			var writer io.WriteCloser
 
 			// Priority order: br, zstd, gzip
 			if strings.Contains(acceptEncoding, "br") && enabledAlgorithms["br"] {
 				encoding = "br"
-				writer = brotli.NewWriterLevel(w, cfg.Level)
 			} else if strings.Contains(acceptEncoding, "zstd") && enabledAlgorithms["zstd"] {
-				encoder, _ := zstd.NewWriter(w, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(cfg.Level)))
 				encoding = "zstd"
-				writer = encoder
 			} else if strings.Contains(acceptEncoding, "gzip") && enabledAlgorithms["gzip"] {
 				encoding = "gzip"
-				gzWriter, _ := gzip.NewWriterLevel(w, cfg.Level)
-				writer = gzWriter
 			}
 
-			if writer == nil {
+			if encoding == "" {
 				next.ServeHTTP(w, r)
 				return
 			}
 
-			// Wrap response writer
-			w.Header().Set("Content-Encoding", encoding)
-			w.Header().Del("Content-Length") // Remove content length as it will change
-
 			cw := &compressionWriter{
 				ResponseWriter: w,
-				writer:         writer,
-			}
-			defer cw.Close()
-
-			// Capture response to check content type
-			rw := &responseWriter{
-				ResponseWriter: cw,
+				encoding:       encoding,
+				level:          cfg.Level,
+				minSize:        minSize,
 				compressible:   compressibleTypes,
-				shouldCompress: false,
+				statusCode:     http.StatusOK,
 			}
+			defer cw.Close()
 
-			next.ServeHTTP(rw, r)
+			next.ServeHTTP(cw, r)
 		})
 	}
 }
 
-// responseWriter captures response to check if compression should be applied
-type responseWriter struct {
+// compressionWriter decides whether to compress a response once its headers
+// and enough of its body are known. Bodies of unknown length are buffered
+// until they reach minSize; anything smaller is sent uncompressed.
+type compressionWriter struct {
 	http.ResponseWriter
-	compressible   map[string]bool
-	shouldCompress bool
-	wroteHeader    bool
-}
-
-func (rw *responseWriter) WriteHeader(code int) {
-	if !rw.wroteHeader {
-		contentType := rw.Header().Get("Content-Type")
-		if contentType != "" {
-			// Extract main type
-			if idx := strings.Index(contentType, ";"); idx != -1 {
-				contentType = contentType[:idx]
+	encoding     string
+	level        int
+	minSize      int
+	compressible map[string]bool
+
+	statusCode  int
+	wroteHeader bool
+	decided     bool
+	buf         []byte
+	writer      encoder
+}
+
+// WriteHeader records the status code. It is only sent once the writer has
+// decided whether to compress.
+func (cw *compressionWriter) WriteHeader(code int) {
+	if cw.wroteHeader {
+		return
+	}
+
+	// Informational responses pass straight through
+	if types.IsInterimStatus(code) {
+		cw.ResponseWriter.WriteHeader(code)
+		return
+	}
+
+	cw.wroteHeader = true
+	cw.statusCode = code
+
+	if !cw.eligible() {
+		cw.passthrough()
+		return
+	}
+
+	// A declared length lets us decide without buffering
+	if length := cw.Header().Get("Content-Length"); length != "" {
+		if n, err := strconv.Atoi(length); err == nil {
+			if n >= cw.minSize {
+				cw.startCompression()
+			} else {
+				cw.passthrough()
 			}
-			contentType = strings.TrimSpace(contentType)
+		}
+	}
+}
+
+func (cw *compressionWriter) Write(b []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+
+	if cw.decided {
+		if cw.writer != nil {
+			return cw.writer.Write(b)
+		}
+		return cw.ResponseWriter.Write(b)
+	}
 
-			// Check if type is compressible
-			rw.shouldCompress = rw.compressible[contentType]
+	cw.buf = append(cw.buf, b...)
+	if len(cw.buf) >= cw.minSize {
+		cw.startCompression()
+		if err := cw.flushBuffer(); err != nil {
+			return 0, err
 		}
-		rw.wroteHeader = true
 	}
-	rw.ResponseWriter.WriteHeader(code)
+
+	return len(b), nil
 }
 
-func (rw *responseWriter) Write(b []byte) (int, error) {
-	if !rw.wroteHeader {
-		rw.WriteHeader(http.StatusOK)
+// Flush sends buffered data to the client. A response flushed before it
+// reaches the size threshold is streamed uncompressed.
+func (cw *compressionWriter) Flush() {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
 	}
 
-	if rw.shouldCompress {
-		return rw.ResponseWriter.Write(b)
+	if !cw.decided {
+		cw.passthrough()
+		cw.flushBuffer()
 	}
 
-	// Write directly without compression
-	if cw, ok := rw.ResponseWriter.(*compressionWriter); ok {
-		return cw.ResponseWriter.Write(b)
+	if cw.writer != nil {
+		cw.writer.Flush()
+	}
+
+	http.NewResponseController(cw.ResponseWriter).Flush()
+}
+
+// Unwrap returns the underlying response writer for http.ResponseController
+func (cw *compressionWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// Close finishes the response, sending small bodies uncompressed
+func (cw *compressionWriter) Close() error {
+	if !cw.wroteHeader {
+		// Nothing was written; let the server send its default response
+		return nil
+	}
+
+	if !cw.decided {
+		cw.passthrough()
+		if err := cw.flushBuffer(); err != nil {
+			return err
+		}
+	}
+
+	if cw.writer != nil {
+		return cw.writer.Close()
+	}
+
+	return nil
+}
+
+// eligible reports whether the response may be compressed based on its
+// status and headers
+func (cw *compressionWriter) eligible() bool {
+	switch cw.statusCode {
+	case http.StatusSwitchingProtocols, http.StatusNoContent, http.StatusPartialContent, http.StatusNotModified:
+		return false
+	}
+
+	header := cw.Header()
+
+	// Never double-compress
+	if encoding := header.Get("Content-Encoding"); encoding != "" && encoding != "identity" {
+		return false
+	}
+
+	if header.Get("Content-Range") != "" {
+		return false
+	}
+
+	contentType := header.Get("Content-Type")
+	if idx := strings.Index(contentType, ";"); idx != -1 {
+		contentType = contentType[:idx]
+	}
+
+	return cw.compressible[strings.TrimSpace(contentType)]
+}
+
+// passthrough sends the response headers unchanged
+func (cw *compressionWriter) passthrough() {
+	cw.decided = true
+	cw.ResponseWriter.WriteHeader(cw.statusCode)
+}
+
+// startCompression sends the response headers for a compressed body and
+// creates the encoder
+func (cw *compressionWriter) startCompression() {
+	cw.decided = true
+
+	header := cw.Header()
+	header.Set("Content-Encoding", cw.encoding)
+	header.Del("Content-Length") // Remove content length as it will change
+	header.Add("Vary", "Accept-Encoding")
+
+	switch cw.encoding {
+	case "br":
+		cw.writer = brotli.NewWriterLevel(cw.ResponseWriter, cw.level)
+	case "zstd":
+		encoder, _ := zstd.NewWriter(cw.ResponseWriter, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(cw.level)))
+		cw.writer = encoder
+	case "gzip":
+		gzWriter, err := gzip.NewWriterLevel(cw.ResponseWriter, cw.level)
+		if err != nil {
+			gzWriter = gzip.NewWriter(cw.ResponseWriter)
+		}
+		cw.writer = gzWriter
+	}
+
+	cw.ResponseWriter.WriteHeader(cw.statusCode)
+}
+
+// flushBuffer writes any buffered body through the chosen path
+func (cw *compressionWriter) flushBuffer() error {
+	if len(cw.buf) == 0 {
+		return nil
+	}
+
+	var err error
+	if cw.writer != nil {
+		_, err = cw.writer.Write(cw.buf)
+	} else {
+		_, err = cw.ResponseWriter.Write(cw.buf)
 	}
+	cw.buf = nil
 
-	return rw.ResponseWriter.Write(b)
+	return err
 }
 
 // CompressionPool manages compression writers with pooling
@@ -0,0 +1,190 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"discobox/internal/types"
+)
+
+// Route metadata keys limiting how many requests a route serves at once.
+// max_concurrent is the number of requests proxied concurrently; requests
+// beyond it wait up to queue_timeout (a duration string such as "2s") for a
+// slot and are rejected with 503 if none frees up. Without queue_timeout
+// they wait for as long as the client does.
+const (
+	RouteMetadataMaxConcurrent = "max_concurrent"
+	RouteMetadataQueueTimeout  = "queue_timeout"
+)
+
+// semaphore bounds concurrent holders; waiters give up when their context ends
+type semaphore struct {
+	slots chan struct{}
+}
+
+func newSemaphore(size int) *semaphore {
+	return &semaphore{slots: make(chan struct{}, size)}
+}
+
+// Acquire takes a slot, waiting until one is free or ctx is done
+func (s *semaphore) Acquire(ctx context.Context) error {
+	select {
+	case s.slots <- struct{}{}:
+		return nil
+	default:
+	}
+
+	select {
+	case s.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Release frees a slot taken by Acquire
+func (s *semaphore) Release() {
+	<-s.slots
+}
+
+// Size returns the number of slots
+func (s *semaphore) Size() int {
+	return cap(s.slots)
+}
+
+// concurrencyPruneInterval is how often semaphores of routes that were
+// deleted, or lost their limit, are looked for
+const concurrencyPruneInterval = time.Minute
+
+// routeLimiter holds one semaphore per limited route
+type routeLimiter struct {
+	mu         sync.Mutex
+	semaphores map[string]*semaphore
+	router     types.Router
+	lastPrune  atomic.Int64 // Unix nanoseconds of the last prune
+}
+
+// semaphore returns the route's semaphore, replacing it when max_concurrent
+// has changed. Requests holding a slot in a replaced semaphore release it
+// there, so the new limit applies to new requests only.
+func (rl *routeLimiter) semaphore(routeID string, size int) *semaphore {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	sem, ok := rl.semaphores[routeID]
+	if !ok || sem.Size() != size {
+		sem = newSemaphore(size)
+		rl.semaphores[routeID] = sem
+	}
+	return sem
+}
+
+// prune drops the semaphores of routes the router no longer has or no
+// longer limits, looking at most once every concurrencyPruneInterval.
+// Requests holding a slot in a dropped semaphore still release it there.
+func (rl *routeLimiter) prune(now time.Time) {
+	last := rl.lastPrune.Load()
+	if now.UnixNano()-last < int64(concurrencyPruneInterval) || !rl.lastPrune.CompareAndSwap(last, now.UnixNano()) {
+		return
+	}
+
+	rl.mu.Lock()
+	empty := len(rl.semaphores) == 0
+	rl.mu.Unlock()
+	if empty {
+		return
+	}
+
+	routes, err := rl.router.GetRoutes()
+	if err != nil {
+		return
+	}
+	limited := make(map[string]bool, len(routes))
+	for _, route := range routes {
+		if limit, ok := metadataInt(route.Metadata[RouteMetadataMaxConcurrent]); ok && limit > 0 {
+			limited[route.ID] = true
+		}
+	}
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	for routeID := range rl.semaphores {
+		if !limited[routeID] {
+			delete(rl.semaphores, routeID)
+		}
+	}
+}
+
+// RouteConcurrency creates middleware that caps concurrent requests per
+// route using the max_concurrent and queue_timeout route metadata. Routes
+// without max_concurrent, and requests matching no route, are not limited.
+func RouteConcurrency(router types.Router) types.Middleware {
+	rl := &routeLimiter{semaphores: make(map[string]*semaphore), router: router}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rl.prune(time.Now())
+
+			route, err := matchRoute(r, router)
+			if err != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			limit, ok := metadataInt(route.Metadata[RouteMetadataMaxConcurrent])
+			if !ok || limit <= 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+			sem := rl.semaphore(route.ID, limit)
+
+			ctx := r.Context()
+			if timeout, ok := metadataDuration(route.Metadata[RouteMetadataQueueTimeout]); ok {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, timeout)
+				defer cancel()
+			}
+
+			if err := sem.Acquire(ctx); err != nil {
+				http.Error(w, "Route concurrency limit reached", http.StatusServiceUnavailable)
+				return
+			}
+			defer sem.Release()
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// metadataInt reads a number from route metadata; JSON decodes numbers as
+// float64, YAML as int, and numbers written as strings are parsed
+func metadataInt(value any) (int, bool) {
+	switch v := value.(type) {
+	case int:
+		return v, true
+	case float64:
+		return int(v), true
+	case string:
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		return n, err == nil
+	}
+	return 0, false
+}
+
+// metadataDuration reads a duration string from route metadata
+func metadataDuration(value any) (time.Duration, bool) {
+	str, ok := value.(string)
+	if !ok {
+		return 0, false
+	}
+	d, err := time.ParseDuration(str)
+	if err != nil || d < 0 {
+		return 0, false
+	}
+	return d, true
+}
@@ -1,73 +1,304 @@
 package middleware
 
 import (
+	"encoding/json"
+	"fmt"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 	
 	"net/http"
 
 	"discobox/internal/types"
 )
 
-// CORS creates CORS middleware
-func CORS(config types.ProxyConfig) types.Middleware {
+// RouteMetadataCORS is the route metadata key holding per-route CORS
+// overrides. Its value is an object with any of enabled, allowed_origins,
+// allowed_methods, allowed_headers, allow_credentials and max_age, or that
+// object as a JSON string; fields left out fall back to the global policy.
+const RouteMetadataCORS = "cors"
+
+// corsPruneInterval is how often policies of routes the router has replaced
+// are dropped
+const corsPruneInterval = time.Minute
+
+// corsSettings is a CORS policy before it is compiled for lookups
+type corsSettings struct {
+	Enabled          bool
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	AllowCredentials bool
+	MaxAge           int
+}
+
+// corsPolicy is a compiled CORS policy
+type corsPolicy struct {
+	enabled          bool
+	allowAllOrigins  bool
+	allowedOrigins   map[string]bool
+	allowedMethods   string
+	allowedHeaders   string
+	allowCredentials bool
+	maxAge           int
+}
+
+func globalCORSSettings(config types.ProxyConfig) corsSettings {
 	cfg := config.Middleware.CORS
+	return corsSettings{
+		Enabled:          cfg.Enabled,
+		AllowedOrigins:   cfg.AllowedOrigins,
+		AllowedMethods:   cfg.AllowedMethods,
+		AllowedHeaders:   cfg.AllowedHeaders,
+		AllowCredentials: cfg.AllowCredentials,
+		MaxAge:           cfg.MaxAge,
+	}
+}
+
+func (s corsSettings) compile() *corsPolicy {
+	policy := &corsPolicy{
+		enabled:          s.Enabled,
+		allowedOrigins:   make(map[string]bool),
+		allowedMethods:   strings.Join(s.AllowedMethods, ", "),
+		allowedHeaders:   strings.Join(s.AllowedHeaders, ", "),
+		allowCredentials: s.AllowCredentials,
+		maxAge:           s.MaxAge,
+	}
 	
 	// Prepare allowed origins map for faster lookup
-	allowedOrigins := make(map[string]bool)
-	allowAllOrigins := false
-	for _, origin := range cfg.AllowedOrigins {
+	for _, origin := range s.AllowedOrigins {
 		if origin == "*" {
-			allowAllOrigins = true
+			policy.allowAllOrigins = true
 			break
 		}
-		allowedOrigins[origin] = true
+		policy.allowedOrigins[origin] = true
+	}
+	
+	return policy
+}
+
+// apply sets CORS headers for an allowed origin and reports whether the
+// request was a preflight that has been answered
+func (p *corsPolicy) apply(w http.ResponseWriter, r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if !p.enabled || origin == "" {
+		return false
+	}
+	
+	// Check if origin is allowed
+	if !p.allowAllOrigins && !p.allowedOrigins[origin] {
+		return false
+	}
+	
+	w.Header().Set("Access-Control-Allow-Origin", origin)
+	
+	if p.allowCredentials {
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+	
+	// Handle preflight requests
+	if r.Method == "OPTIONS" {
+		w.Header().Set("Access-Control-Allow-Methods", p.allowedMethods)
+		w.Header().Set("Access-Control-Allow-Headers", p.allowedHeaders)
+		
+		if p.maxAge > 0 {
+			w.Header().Set("Access-Control-Max-Age", strconv.Itoa(p.maxAge))
+		}
+		
+		w.WriteHeader(http.StatusNoContent)
+		return true
 	}
 	
-	// Prepare allowed methods
-	allowedMethods := strings.Join(cfg.AllowedMethods, ", ")
+	// Add Vary header to indicate response varies by origin
+	w.Header().Add("Vary", "Origin")
 	
-	// Prepare allowed headers
-	allowedHeaders := strings.Join(cfg.AllowedHeaders, ", ")
+	return false
+}
+
+// CORS creates CORS middleware
+func CORS(config types.ProxyConfig) types.Middleware {
+	policy := globalCORSSettings(config).compile()
 	
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			origin := r.Header.Get("Origin")
-			
-			// Check if origin is allowed
-			if origin != "" {
-				allowed := allowAllOrigins || allowedOrigins[origin]
-				
-				if allowed {
-					w.Header().Set("Access-Control-Allow-Origin", origin)
-					
-					if cfg.AllowCredentials {
-						w.Header().Set("Access-Control-Allow-Credentials", "true")
-					}
-					
-					// Handle preflight requests
-					if r.Method == "OPTIONS" {
-						w.Header().Set("Access-Control-Allow-Methods", allowedMethods)
-						w.Header().Set("Access-Control-Allow-Headers", allowedHeaders)
-						
-						if cfg.MaxAge > 0 {
-							w.Header().Set("Access-Control-Max-Age", strconv.Itoa(cfg.MaxAge))
-						}
-						
-						w.WriteHeader(http.StatusNoContent)
-						return
+			if policy.apply(w, r) {
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RouteCORS creates CORS middleware that resolves the policy per route.
+// Routes carrying a "cors" metadata object override the global policy field
+// by field; other routes, and requests matching no route, use the global
+// policy. Routes are only matched for requests with an Origin header.
+func RouteCORS(config types.ProxyConfig, router types.Router) types.Middleware {
+	policies := &routeCORSPolicies{
+		global:   globalCORSSettings(config),
+		router:   router,
+		policies: make(map[*types.Route]*corsPolicy),
+	}
+	globalPolicy := policies.global.compile()
+	
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			policy := globalPolicy
+			if r.Header.Get("Origin") != "" {
+				if route, err := matchRoute(r, router); err == nil {
+					if routePolicy := policies.forRoute(route); routePolicy != nil {
+						policy = routePolicy
 					}
-					
-					// Add Vary header to indicate response varies by origin
-					w.Header().Add("Vary", "Origin")
 				}
 			}
 			
+			if policy.apply(w, r) {
+				return
+			}
 			next.ServeHTTP(w, r)
 		})
 	}
 }
 
+// routeCORSPolicies compiles each route's CORS policy once per route load.
+// Policies are kept by the route the router loaded, so an updated route,
+// which the router loads afresh, gets its policy compiled again.
+type routeCORSPolicies struct {
+	global    corsSettings
+	router    types.Router
+	mu        sync.Mutex
+	policies  map[*types.Route]*corsPolicy // nil for routes without valid overrides
+	lastPrune atomic.Int64                 // Unix nanoseconds of the last prune
+}
+
+// forRoute returns the compiled policy for a route with cors metadata, or
+// nil if it has none or it can't be read
+func (c *routeCORSPolicies) forRoute(route *types.Route) *corsPolicy {
+	if _, set := route.Metadata[RouteMetadataCORS]; !set {
+		return nil
+	}
+	c.prune(time.Now())
+	
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	
+	policy, ok := c.policies[route]
+	if !ok {
+		if overrides, err := CORSOverrides(route); err == nil && overrides != nil {
+			policy = c.global.withOverrides(overrides).compile()
+		}
+		c.policies[route] = policy
+	}
+	return policy
+}
+
+// prune drops the policies of routes the router no longer has, looking at
+// most once every corsPruneInterval
+func (c *routeCORSPolicies) prune(now time.Time) {
+	last := c.lastPrune.Load()
+	if now.UnixNano()-last < int64(corsPruneInterval) || !c.lastPrune.CompareAndSwap(last, now.UnixNano()) {
+		return
+	}
+	
+	routes, err := c.router.GetRoutes()
+	if err != nil {
+		return
+	}
+	current := make(map[*types.Route]bool, len(routes))
+	for _, route := range routes {
+		current[route] = true
+	}
+	
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for route := range c.policies {
+		if !current[route] {
+			delete(c.policies, route)
+		}
+	}
+}
+
+// CORSOverrides reads a route's cors metadata, which is an object from
+// YAML or JSON route definitions, or a JSON string from the API. It returns
+// nil without an error when the route has none.
+func CORSOverrides(route *types.Route) (map[string]any, error) {
+	switch value := route.Metadata[RouteMetadataCORS].(type) {
+	case nil:
+		return nil, nil
+	case map[string]any:
+		return value, nil
+	case string:
+		var overrides map[string]any
+		if err := json.Unmarshal([]byte(value), &overrides); err != nil || overrides == nil {
+			return nil, fmt.Errorf("cors must be a JSON object")
+		}
+		return overrides, nil
+	default:
+		return nil, fmt.Errorf("cors must be an object")
+	}
+}
+
+// withOverrides returns a copy of the settings with the fields present in a
+// route's cors metadata replaced. A route override enables CORS unless it
+// sets enabled to false.
+func (s corsSettings) withOverrides(overrides map[string]any) corsSettings {
+	s.Enabled = true
+	
+	if enabled, ok := overrides["enabled"].(bool); ok {
+		s.Enabled = enabled
+	}
+	if origins, ok := metadataStrings(overrides["allowed_origins"]); ok {
+		s.AllowedOrigins = origins
+	}
+	if methods, ok := metadataStrings(overrides["allowed_methods"]); ok {
+		s.AllowedMethods = methods
+	}
+	if headers, ok := metadataStrings(overrides["allowed_headers"]); ok {
+		s.AllowedHeaders = headers
+	}
+	if credentials, ok := overrides["allow_credentials"].(bool); ok {
+		s.AllowCredentials = credentials
+	}
+	
+	// JSON decodes numbers as float64, YAML as int
+	switch maxAge := overrides["max_age"].(type) {
+	case int:
+		s.MaxAge = maxAge
+	case float64:
+		s.MaxAge = int(maxAge)
+	}
+	
+	return s
+}
+
+// metadataStrings reads a list from route metadata, which arrives as []any
+// from JSON and YAML or as a comma-separated string
+func metadataStrings(value any) ([]string, bool) {
+	switch v := value.(type) {
+	case []string:
+		return v, true
+	case []any:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			if str, ok := item.(string); ok {
+				values = append(values, str)
+			}
+		}
+		return values, true
+	case string:
+		values := make([]string, 0)
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				values = append(values, item)
+			}
+		}
+		return values, true
+	}
+	return nil, false
+}
+
 // CORSOptions provides more control over CORS configuration
 type CORSOptions struct {
 	AllowedOrigins   []string
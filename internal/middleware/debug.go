@@ -0,0 +1,112 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"discobox/internal/types"
+)
+
+// DebugRequestHeader asks for debug headers when set to 1
+const DebugRequestHeader = "X-Discobox-Debug"
+
+// DebugKeyHeader carries an admin user's API key when debug headers are
+// admin only. It is removed before the request is proxied.
+const DebugKeyHeader = "X-Discobox-Debug-Key"
+
+// Debug response headers
+const (
+	DebugRouteHeader       = "X-Discobox-Route"
+	DebugBackendHeader     = "X-Discobox-Backend"
+	DebugMiddlewaresHeader = "X-Discobox-Middlewares"
+	DebugTimeHeader        = "X-Discobox-Proxy-Time"
+)
+
+// debugResponseHeaders are the headers only this middleware may set
+var debugResponseHeaders = []string{DebugRouteHeader, DebugBackendHeader, DebugMiddlewaresHeader, DebugTimeHeader}
+
+// DebugHeaders creates middleware that tells clients sending
+// X-Discobox-Debug: 1 how their request was handled: the matched route, the
+// backend that answered, the global middleware that ran and the time taken
+// until the response started. With admin_only set, only requests carrying an
+// active admin user's API key in X-Discobox-Debug-Key get them, so internals
+// don't leak to the public. Each key's check is reused for a while, so
+// debug requests don't each cost storage lookups. The key never reaches
+// backends, and debug headers a backend sends are dropped. It should be the
+// outermost middleware so the time covers the rest.
+func DebugHeaders(config types.ProxyConfig, storage types.Storage) types.Middleware {
+	adminOnly := config.Debug.AdminOnly
+	keys := newAPIKeyCache(storage)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			apiKey := r.Header.Get(DebugKeyHeader)
+			if apiKey != "" {
+				r = r.Clone(r.Context())
+				r.Header.Del(DebugKeyHeader)
+			}
+
+			if r.Header.Get(DebugRequestHeader) != "1" || (adminOnly && !keys.check(r.Context(), apiKey).admin) {
+				next.ServeHTTP(&debugWriter{ResponseWriter: w}, r)
+				return
+			}
+
+			trace := &types.DebugTrace{}
+			dw := &debugWriter{ResponseWriter: w, trace: trace, start: time.Now()}
+			next.ServeHTTP(dw, r.WithContext(types.ContextWithDebugTrace(r.Context(), trace)))
+		})
+	}
+}
+
+// debugWriter drops debug headers the backend sent and, for debug
+// requests, adds the trace to the response headers as they are sent
+type debugWriter struct {
+	http.ResponseWriter
+	trace       *types.DebugTrace // nil unless the client asked for debug headers
+	start       time.Time
+	wroteHeader bool
+}
+
+func (dw *debugWriter) WriteHeader(code int) {
+	// Informational responses other than an upgrade precede the real one
+	if !dw.wroteHeader && !types.IsInterimStatus(code) {
+		dw.setHeaders()
+	}
+	dw.ResponseWriter.WriteHeader(code)
+}
+
+func (dw *debugWriter) Write(b []byte) (int, error) {
+	if !dw.wroteHeader {
+		dw.setHeaders()
+	}
+	return dw.ResponseWriter.Write(b)
+}
+
+// setHeaders replaces any debug headers with the trace
+func (dw *debugWriter) setHeaders() {
+	dw.wroteHeader = true
+
+	h := dw.ResponseWriter.Header()
+	for _, name := range debugResponseHeaders {
+		h.Del(name)
+	}
+	if dw.trace == nil {
+		return
+	}
+	if route := dw.trace.Route(); route != "" {
+		h.Set(DebugRouteHeader, route)
+	}
+	if backend := dw.trace.Backend(); backend != "" {
+		h.Set(DebugBackendHeader, backend)
+	}
+	if middlewares := dw.trace.Middlewares(); len(middlewares) > 0 {
+		h.Set(DebugMiddlewaresHeader, strings.Join(middlewares, ", "))
+	}
+	h.Set(DebugTimeHeader, time.Since(dw.start).String())
+}
+
+// Unwrap returns the underlying response writer for http.ResponseController
+func (dw *debugWriter) Unwrap() http.ResponseWriter {
+	return dw.ResponseWriter
+}
@@ -0,0 +1,101 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"discobox/internal/types"
+)
+
+// Names of global middleware that routes can turn off with
+// disabled_middlewares
+const (
+	NameSecurityHeaders = "security_headers"
+	NameHeaderLimits    = "header_limits"
+	NameCORS            = "cors"
+	NameAccessLog       = "access_log"
+	NameMetrics         = "metrics"
+	NameTimeout         = "timeout"
+	NameRateLimit       = "ratelimit"
+	NameLoadShedding    = "load_shedding"
+	NameConcurrency     = "concurrency"
+	NameCompression     = "compression"
+	NameIdempotency     = "idempotency"
+	NameCustomHeaders   = "custom_headers"
+	NameRetry           = "retry"
+)
+
+// DisableableNames lists the global middleware a route can disable
+var DisableableNames = []string{
+	NameSecurityHeaders,
+	NameHeaderLimits,
+	NameCORS,
+	NameAccessLog,
+	NameMetrics,
+	NameTimeout,
+	NameRateLimit,
+	NameLoadShedding,
+	NameConcurrency,
+	NameCompression,
+	NameIdempotency,
+	NameCustomHeaders,
+	NameRetry,
+}
+
+const routeKey contextKey = "route"
+
+// matchedRoute is the outcome of matching a request's route; route is nil if
+// the request matched none
+type matchedRoute struct {
+	route *types.Route
+}
+
+// RouteDisabled creates middleware that matches the request's route once and
+// carries it in the request context, both for Disableable and for middleware
+// further down the chain that acts on route settings. It must come before any
+// middleware wrapped with Disableable.
+func RouteDisabled(router types.Router) types.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var matched matchedRoute
+			if route, err := router.Match(r); err == nil {
+				matched.route = route
+			}
+
+			ctx := context.WithValue(r.Context(), routeKey, matched)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// matchRoute returns the request's route, as matched by RouteDisabled, or
+// matches it with router if the chain has no RouteDisabled
+func matchRoute(r *http.Request, router types.Router) (*types.Route, error) {
+	if matched, ok := r.Context().Value(routeKey).(matchedRoute); ok {
+		if matched.route == nil {
+			return nil, types.ErrRouteNotFound
+		}
+		return matched.route, nil
+	}
+	if router == nil {
+		return nil, types.ErrRouteNotFound
+	}
+	return router.Match(r)
+}
+
+// Disableable wraps global middleware so routes listing name in
+// disabled_middlewares bypass it. Middleware that runs is recorded in the
+// request's debug trace, if it has one.
+func Disableable(name string, middleware types.Middleware) types.Middleware {
+	return func(next http.Handler) http.Handler {
+		wrapped := middleware(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if matched, ok := r.Context().Value(routeKey).(matchedRoute); ok && matched.route != nil && matched.route.DisablesMiddleware(name) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			types.DebugTraceFromContext(r.Context()).AddMiddleware(name)
+			wrapped.ServeHTTP(w, r)
+		})
+	}
+}
@@ -87,7 +87,8 @@ type headerRemoverWriter struct {
 }
 
 func (hrw *headerRemoverWriter) WriteHeader(code int) {
-	if !hrw.wroteHeader {
+	// Headers are removed from the final response, not interim ones
+	if !hrw.wroteHeader && !types.IsInterimStatus(code) {
 		// Remove specified headers
 		for _, h := range hrw.headers {
 			hrw.Header().Del(h)
@@ -174,3 +175,34 @@ func Headers(config types.ProxyConfig) types.Middleware {
 		})
 	}
 }
+
+// HeaderLimits rejects requests carrying too many headers, or any single
+// header that is too long, with 431. Limits of zero are not enforced.
+func HeaderLimits(config types.ProxyConfig) types.Middleware {
+	maxCount := config.Middleware.HeaderLimits.MaxCount
+	maxLength := config.Middleware.HeaderLimits.MaxLength
+	
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			count := 0
+			for name, values := range r.Header {
+				count += len(values)
+				if maxLength > 0 {
+					for _, value := range values {
+						if len(name)+len(value) > maxLength {
+							http.Error(w, "Request header too large", http.StatusRequestHeaderFieldsTooLarge)
+							return
+						}
+					}
+				}
+			}
+			
+			if maxCount > 0 && count > maxCount {
+				http.Error(w, "Too many request headers", http.StatusRequestHeaderFieldsTooLarge)
+				return
+			}
+			
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,252 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"discobox/internal/types"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// maxIdempotentBodySize bounds the request and response bodies held for
+// replay. Larger exchanges pass through without idempotency protection.
+const maxIdempotentBodySize = 1 << 20
+
+// idempotencyCleanupInterval is how often expired responses are dropped.
+// Cleanup runs inline with requests rather than in a goroutine, so a chain
+// replaced on reload leaves nothing running behind it.
+const idempotencyCleanupInterval = time.Minute
+
+// idempotentEntry is the recorded outcome of the first request for a key
+type idempotentEntry struct {
+	bodyHash  string
+	done      chan struct{} // closed once the response is recorded
+	status    int
+	header    http.Header
+	body      []byte
+	expiresAt time.Time
+}
+
+// idempotencyStore remembers responses by idempotency key
+type idempotencyStore struct {
+	entries     map[string]*idempotentEntry
+	mu          sync.Mutex
+	ttl         time.Duration
+	lastCleanup time.Time
+}
+
+// Idempotency creates middleware that honours the Idempotency-Key header on
+// POST and PATCH requests. The first response for a key is cached for the
+// configured TTL and replayed to retries carrying the same key and body. A
+// key reused with a different body is rejected with 409 Conflict.
+func Idempotency(config types.ProxyConfig) types.Middleware {
+	store := &idempotencyStore{
+		entries: make(map[string]*idempotentEntry),
+		ttl:     config.Middleware.Idempotency.TTL,
+	}
+	if store.ttl <= 0 {
+		store.ttl = 24 * time.Hour
+	}
+
+	return store.Middleware
+}
+
+// Middleware returns the middleware handler
+func (s *idempotencyStore) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		if key == "" || (r.Method != http.MethodPost && r.Method != http.MethodPatch) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Read the body so retries can be compared against the original
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxIdempotentBodySize+1))
+		if err != nil {
+			http.Error(w, "Failed to read request body", http.StatusBadRequest)
+			return
+		}
+		if len(body) > maxIdempotentBodySize {
+			r.Body = readCloser{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+			next.ServeHTTP(w, r)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		bodySum := sha256.Sum256(body)
+		bodyHash := hex.EncodeToString(bodySum[:])
+		scope := idempotencyScope(r, key)
+
+		entry, owner := s.acquire(scope, bodyHash)
+		if !owner {
+			if entry.bodyHash != bodyHash {
+				http.Error(w, "Idempotency key reused with a different request body", http.StatusConflict)
+				return
+			}
+
+			// Wait for the original request to finish, then replay it
+			select {
+			case <-entry.done:
+			case <-r.Context().Done():
+				return
+			}
+
+			if entry.status == 0 {
+				// The original wasn't cacheable; this request takes its place
+				s.Middleware(next).ServeHTTP(w, r)
+				return
+			}
+
+			entry.replay(w)
+			return
+		}
+
+		// Record the response even if the handler panics, so waiters
+		// aren't left blocked on an entry that never completes
+		rec := &idempotentRecorder{ResponseWriter: w, status: http.StatusOK}
+		finished := false
+		defer func() {
+			s.complete(scope, entry, rec, finished)
+		}()
+
+		next.ServeHTTP(rec, r)
+		finished = true
+	})
+}
+
+// idempotencyScope identifies a key within the route and credentials it was
+// used with, so the same key on different endpoints or by different clients
+// never collides
+func idempotencyScope(r *http.Request, key string) string {
+	h := sha256.New()
+	for _, part := range []string{r.Method, r.Host, r.URL.Path, r.Header.Get("Authorization"), key} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// acquire returns the entry for scope, creating it if none is live. owner
+// reports whether the caller created it and must record the response.
+func (s *idempotencyStore) acquire(scope, bodyHash string) (entry *idempotentEntry, owner bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(s.lastCleanup) >= idempotencyCleanupInterval {
+		s.cleanupExpired(now)
+		s.lastCleanup = now
+	}
+
+	if entry, exists := s.entries[scope]; exists && (entry.expiresAt.IsZero() || entry.expiresAt.After(now)) {
+		return entry, false
+	}
+
+	entry = &idempotentEntry{
+		bodyHash: bodyHash,
+		done:     make(chan struct{}),
+	}
+	s.entries[scope] = entry
+
+	return entry, true
+}
+
+// complete records the response for an entry. Server errors, oversized
+// responses and handlers that didn't finish are not cached so the client can
+// retry them.
+func (s *idempotencyStore) complete(scope string, entry *idempotentEntry, rec *idempotentRecorder, finished bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !finished || rec.status >= 500 || rec.overflow {
+		delete(s.entries, scope)
+	} else {
+		entry.status = rec.status
+		entry.header = rec.header
+		if entry.header == nil {
+			entry.header = rec.Header().Clone()
+		}
+		entry.body = rec.body.Bytes()
+		entry.expiresAt = time.Now().Add(s.ttl)
+	}
+
+	close(entry.done)
+}
+
+// replay writes a recorded response
+func (e *idempotentEntry) replay(w http.ResponseWriter) {
+	header := w.Header()
+	for k, v := range e.header {
+		header[k] = v
+	}
+	header.Set("Idempotent-Replayed", "true")
+
+	w.WriteHeader(e.status)
+	w.Write(e.body)
+}
+
+// cleanupExpired removes recorded responses past their TTL. s.mu must be held.
+func (s *idempotencyStore) cleanupExpired(now time.Time) {
+	for scope, entry := range s.entries {
+		// In-flight entries have no expiry yet
+		if !entry.expiresAt.IsZero() && entry.expiresAt.Before(now) {
+			delete(s.entries, scope)
+		}
+	}
+}
+
+// idempotentRecorder passes a response through while keeping a copy for replay
+type idempotentRecorder struct {
+	http.ResponseWriter
+	status      int
+	header      http.Header // As the handler set it, before outer writers add encodings
+	wroteHeader bool
+	body        bytes.Buffer
+	overflow    bool
+}
+
+func (ir *idempotentRecorder) WriteHeader(code int) {
+	// Informational responses precede the final one
+	if types.IsInterimStatus(code) {
+		ir.ResponseWriter.WriteHeader(code)
+		return
+	}
+
+	if !ir.wroteHeader {
+		ir.status = code
+		ir.header = ir.ResponseWriter.Header().Clone()
+		ir.wroteHeader = true
+	}
+	ir.ResponseWriter.WriteHeader(code)
+}
+
+func (ir *idempotentRecorder) Write(b []byte) (int, error) {
+	if !ir.wroteHeader {
+		ir.WriteHeader(http.StatusOK)
+	}
+
+	if !ir.overflow {
+		if ir.body.Len()+len(b) > maxIdempotentBodySize {
+			ir.overflow = true
+			ir.body.Reset()
+		} else {
+			ir.body.Write(b)
+		}
+	}
+
+	return ir.ResponseWriter.Write(b)
+}
+
+// Unwrap returns the underlying response writer for http.ResponseController
+func (ir *idempotentRecorder) Unwrap() http.ResponseWriter {
+	return ir.ResponseWriter
+}
+
+// readCloser pairs a reader with the closer of the body it was built from
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -20,6 +20,12 @@ type loggingResponseWriter struct {
 }
 
 func (lrw *loggingResponseWriter) WriteHeader(code int) {
+	// Informational responses such as 100 Continue precede the real status
+	if types.IsInterimStatus(code) {
+		lrw.ResponseWriter.WriteHeader(code)
+		return
+	}
+
 	lrw.statusCode = code
 	lrw.ResponseWriter.WriteHeader(code)
 }
@@ -70,6 +76,7 @@ func AccessLogging(logger types.Logger) types.Middleware {
 				"status", lrw.statusCode,
 				"duration", duration,
 				"bytes", lrw.bytes,
+				"client_ip", types.ClientIP(r),
 				"remote_addr", r.RemoteAddr,
 				"user_agent", r.UserAgent(),
 				"referer", r.Referer(),
@@ -125,6 +132,7 @@ func (sl *StructuredLogger) Middleware() types.Middleware {
 				"request_id", requestID,
 				"method", r.Method,
 				"path", r.URL.Path,
+				"client_ip", types.ClientIP(r),
 				"remote_addr", r.RemoteAddr,
 			)
 
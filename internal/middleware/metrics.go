@@ -1,6 +1,8 @@
 package middleware
 
 import (
+	"io"
+	"sync/atomic"
 	"time"
 
 	"discobox/internal/metrics"
@@ -21,6 +23,12 @@ type metricsResponseWriter struct {
 }
 
 func (mrw *metricsResponseWriter) WriteHeader(code int) {
+	// Informational responses such as 100 Continue precede the real status
+	if types.IsInterimStatus(code) {
+		mrw.ResponseWriter.WriteHeader(code)
+		return
+	}
+
 	if !mrw.wroteHeader {
 		mrw.statusCode = code
 		mrw.wroteHeader = true
@@ -37,6 +45,25 @@ func (mrw *metricsResponseWriter) Write(b []byte) (int, error) {
 	return n, err
 }
 
+// Unwrap returns the underlying response writer for http.ResponseController
+func (mrw *metricsResponseWriter) Unwrap() http.ResponseWriter {
+	return mrw.ResponseWriter
+}
+
+// countingBody counts the bytes read from a request body. A handler that
+// gave up on the request may leave a transport still reading it, so the
+// count is atomic.
+type countingBody struct {
+	io.ReadCloser
+	bytes atomic.Int64
+}
+
+func (cb *countingBody) Read(p []byte) (int, error) {
+	n, err := cb.ReadCloser.Read(p)
+	cb.bytes.Add(int64(n))
+	return n, err
+}
+
 // Metrics creates metrics collection middleware
 func Metrics() types.Middleware {
 	return func(next http.Handler) http.Handler {
@@ -53,6 +80,13 @@ func Metrics() types.Middleware {
 				statusCode:     http.StatusOK,
 			}
 
+			// Count the request body as it is read
+			body := &countingBody{}
+			if r.Body != nil && r.Body != http.NoBody {
+				body.ReadCloser = r.Body
+				r.Body = body
+			}
+
 			// Process request
 			next.ServeHTTP(mrw, r)
 
@@ -61,6 +95,7 @@ func Metrics() types.Middleware {
 
 			// Record the request with the global collector
 			metrics.GlobalCollector.RecordRequest(r.Method, mrw.statusCode, duration)
+			metrics.GlobalCollector.RecordTraffic(mrw.statusCode, body.bytes.Load(), mrw.bytes)
 		})
 	}
 }
@@ -2,8 +2,8 @@ package middleware
 
 import (
 	"context"
+	"discobox/internal/middleware/auth"
 	"discobox/internal/types"
-	"net"
 	"net/http"
 	"strings"
 	"sync"
@@ -19,42 +19,57 @@ type limiterEntry struct {
 	mu         sync.Mutex
 }
 
+// Rate limit keying strategies for rate_limit.key_by
+const (
+	RateLimitKeyIP           = "ip"      // One budget per client IP
+	RateLimitKeyRoute        = "route"   // One budget per matched route, shared by all clients
+	RateLimitKeyHeaderPrefix = "header:" // One budget per API key or authenticated user, e.g. header:X-API-Key
+)
+
+// RouteMetadataRateLimitCost is the route metadata key setting how many
+// tokens each of the route's requests takes from its rate limit budget, so
+// expensive endpoints use it up faster. Requests cost 1 token by default.
+const RouteMetadataRateLimitCost = "rate_limit_cost"
+
 // rateLimiter implements rate limiting middleware
 type rateLimiter struct {
 	limiters map[string]*limiterEntry
 	mu       sync.RWMutex
 	rps      int
 	burst    int
-	byHeader string
 	keyFunc  func(*http.Request) string
+	router   types.Router
 	ttl      time.Duration // Time-to-live for idle limiters
 	stopCh   chan struct{}
 }
 
-// RateLimit creates rate limiting middleware
-func RateLimit(config types.ProxyConfig) types.Middleware {
+// RateLimit creates rate limiting middleware. Requests are bucketed by
+// rate_limit.key_by, and take the rate_limit_cost of the route they match
+// from the bucket; requests the bucket can't cover get 429. Header keys are
+// checked against the API keys in storage.
+func RateLimit(config types.ProxyConfig, router types.Router, storage types.Storage) types.Middleware {
+	rateLimit, _ := RateLimitWithStop(config, router, storage)
+	return rateLimit
+}
+
+// RateLimitWithStop creates rate limiting middleware like RateLimit, along
+// with a func that stops its cleanup goroutine once the middleware is no
+// longer used, e.g. when a reload replaces the chain holding it
+func RateLimitWithStop(config types.ProxyConfig, router types.Router, storage types.Storage) (types.Middleware, func()) {
 	rl := &rateLimiter{
 		limiters: make(map[string]*limiterEntry),
 		rps:      config.RateLimit.RPS,
 		burst:    config.RateLimit.Burst,
-		byHeader: config.RateLimit.ByHeader,
+		keyFunc:  rateLimitKeyFunc(config.RateLimit.KeyBy, config.RateLimit.ByHeader, router, newAPIKeyCache(storage)),
+		router:   router,
 		ttl:      5 * time.Minute, // Default TTL for idle limiters
 		stopCh:   make(chan struct{}),
 	}
 	
-	// Set key function based on configuration
-	if rl.byHeader != "" {
-		rl.keyFunc = func(r *http.Request) string {
-			return r.Header.Get(rl.byHeader)
-		}
-	} else {
-		rl.keyFunc = getClientIP
-	}
-	
 	// Start cleanup goroutine
 	go rl.cleanup()
 	
-	return rl.Middleware
+	return rl.Middleware, rl.Stop
 }
 
 // Middleware returns the middleware handler
@@ -68,7 +83,7 @@ func (rl *rateLimiter) Middleware(next http.Handler) http.Handler {
 		}
 		
 		limiter := rl.getLimiter(key)
-		if !limiter.Allow() {
+		if !limiter.AllowN(time.Now(), rl.cost(r)) {
 			http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
 			return
 		}
@@ -77,6 +92,70 @@ func (rl *rateLimiter) Middleware(next http.Handler) http.Handler {
 	})
 }
 
+// cost returns the tokens a request takes: its route's rate_limit_cost, or 1
+func (rl *rateLimiter) cost(r *http.Request) int {
+	route, err := matchRoute(r, rl.router)
+	if err != nil {
+		return 1
+	}
+	cost, _ := RateLimitCost(route)
+	return cost
+}
+
+// RateLimitCost returns the tokens each of a route's requests takes, 1 unless
+// its rate_limit_cost says otherwise, and whether a rate_limit_cost that is
+// set is a positive whole number
+func RateLimitCost(route *types.Route) (int, bool) {
+	value, set := route.Metadata[RouteMetadataRateLimitCost]
+	if !set {
+		return 1, true
+	}
+	if cost, ok := metadataInt(value); ok && cost > 0 {
+		return cost, true
+	}
+	return 1, false
+}
+
+// rateLimitKeyFunc returns the function that picks a request's bucket.
+// Header keying trusts a header only once it is vouched for: requests an
+// auth middleware identified share their user's budget, and others share
+// their key's only if it is a valid API key, so clients can't get fresh
+// budgets by making up keys. Everything else, including requests that match
+// no route, falls back to the client IP so it still shares a budget. Keys
+// are prefixed so an IP can't collide with a header value or route ID.
+func rateLimitKeyFunc(keyBy, byHeader string, router types.Router, keys *apiKeyCache) func(*http.Request) string {
+	byIP := func(r *http.Request) string {
+		return "ip:" + types.ClientIP(r)
+	}
+	
+	if keyBy == "" && byHeader != "" {
+		keyBy = RateLimitKeyHeaderPrefix + byHeader
+	}
+	
+	if header, ok := strings.CutPrefix(keyBy, RateLimitKeyHeaderPrefix); ok {
+		return func(r *http.Request) string {
+			if identity, ok := auth.IdentityFromContext(r.Context()); ok {
+				return "user:" + identity.User
+			}
+			if value := r.Header.Get(header); value != "" && keys.check(r.Context(), value).valid {
+				return "header:" + value
+			}
+			return byIP(r)
+		}
+	}
+	
+	if keyBy == RateLimitKeyRoute && router != nil {
+		return func(r *http.Request) string {
+			if route, err := matchRoute(r, router); err == nil {
+				return "route:" + route.ID
+			}
+			return byIP(r)
+		}
+	}
+	
+	return byIP
+}
+
 // getLimiter returns a limiter for the given key
 func (rl *rateLimiter) getLimiter(key string) *rate.Limiter {
 	rl.mu.RLock()
@@ -165,35 +244,6 @@ func (rl *rateLimiter) Stop() {
 	close(rl.stopCh)
 }
 
-// getClientIP extracts client IP from request
-func getClientIP(r *http.Request) string {
-	// Check X-Forwarded-For
-	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
-		parts := strings.Split(xff, ",")
-		if len(parts) > 0 {
-			ip := strings.TrimSpace(parts[0])
-			if net.ParseIP(ip) != nil {
-				return ip
-			}
-		}
-	}
-	
-	// Check X-Real-IP
-	if xri := r.Header.Get("X-Real-IP"); xri != "" {
-		if net.ParseIP(xri) != nil {
-			return xri
-		}
-	}
-	
-	// Use RemoteAddr
-	host, _, err := net.SplitHostPort(r.RemoteAddr)
-	if err != nil {
-		return r.RemoteAddr
-	}
-	
-	return host
-}
-
 // CustomRateLimiter allows custom rate limiting implementations
 type CustomRateLimiter struct {
 	limiter types.RateLimiter
@@ -208,7 +258,7 @@ func NewCustomRateLimiter(limiter types.RateLimiter, keyFunc func(*http.Request)
 	}
 	
 	if rl.keyFunc == nil {
-		rl.keyFunc = getClientIP
+		rl.keyFunc = types.ClientIP
 	}
 	
 	return rl.Middleware
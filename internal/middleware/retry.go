@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"io"
 	"maps"
+	"strconv"
+	"strings"
 	"time"
 
 	"net/http"
@@ -74,6 +76,15 @@ type retryHandler struct {
 }
 
 func (rh *retryHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Upgrades and event streams can't be buffered and replayed. Clients
+	// sending Expect: 100-continue wait for the backend before sending the
+	// body, which buffering would defeat.
+	if r.Header.Get("Upgrade") != "" || strings.Contains(r.Header.Get("Accept"), "text/event-stream") ||
+		strings.EqualFold(r.Header.Get("Expect"), "100-continue") {
+		rh.next.ServeHTTP(w, r)
+		return
+	}
+
 	// Buffer the request body for potential retries
 	var bodyBytes []byte
 	if r.Body != nil {
@@ -89,9 +100,19 @@ func (rh *retryHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	delay := rh.config.InitialDelay
 
 	for attempt := 0; attempt < rh.config.MaxAttempts; attempt++ {
+		// Handlers may rewrite the request in place, so each attempt gets a copy
+		req := r.Clone(r.Context())
+		if attempt > 0 {
+			req = req.WithContext(types.ContextWithRetryAttempt(req.Context(), attempt))
+			req.Header.Set("X-Retry-Attempt", strconv.Itoa(attempt))
+		} else {
+			// Only retries carry the header; a client can't claim to be one
+			req.Header.Del("X-Retry-Attempt")
+		}
+
 		// Create new request body for each attempt
 		if bodyBytes != nil {
-			r.Body = io.NopCloser(bytes.NewReader(bodyBytes))
+			req.Body = io.NopCloser(bytes.NewReader(bodyBytes))
 		}
 
 		// Capture response
@@ -101,7 +122,7 @@ func (rh *retryHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 
 		// Process request
-		rh.next.ServeHTTP(recorder, r)
+		rh.next.ServeHTTP(recorder, req)
 
 		// Check if we should retry
 		shouldRetry := rh.config.RetryIf(&http.Response{
@@ -125,9 +146,6 @@ func (rh *retryHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		if delay > rh.config.MaxDelay {
 			delay = rh.config.MaxDelay
 		}
-
-		// Add retry headers
-		r.Header.Set("X-Retry-Attempt", string(rune(attempt+1)))
 	}
 }
 
@@ -150,6 +168,10 @@ func (rr *responseRecorder) Write(b []byte) (int, error) {
 }
 
 func (rr *responseRecorder) WriteHeader(code int) {
+	// Interim responses can't be replayed and the body was already read
+	if types.IsInterimStatus(code) {
+		return
+	}
 	rr.statusCode = code
 }
 
@@ -0,0 +1,175 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"discobox/internal/metrics"
+	"discobox/internal/types"
+)
+
+// QoS classes, from the traffic kept longest under load to the traffic shed
+// first
+const (
+	QoSHigh   = "high"
+	QoSNormal = "normal"
+	QoSLow    = "low"
+)
+
+// RouteMetadataQoSClass is the route metadata key setting the QoS class of
+// the route's requests. It takes precedence over the class a client names in
+// the load shedding header, and is the only way to make requests high
+// priority.
+const RouteMetadataQoSClass = "qos_class"
+
+const (
+	// shedLatencyWeight is the weight of each response in the latency average
+	shedLatencyWeight = 0.2
+	// shedLatencyWindow is how long the latency average counts without new
+	// responses, so shedding everything doesn't keep the proxy overloaded
+	shedLatencyWindow = 5 * time.Second
+)
+
+// loadShedder rejects requests by QoS class once the proxy is overloaded
+type loadShedder struct {
+	router        types.Router
+	maxConcurrent int64
+	maxLatency    time.Duration
+	header        string
+	defaultClass  string
+	errorFormat   string
+
+	inFlight atomic.Int64
+
+	mu         sync.Mutex
+	latency    time.Duration // Moving average of response times
+	lastSample time.Time
+}
+
+// LoadShedding creates middleware that sheds requests by QoS class when the
+// proxy is overloaded. Low priority requests are rejected with 503 once
+// max_concurrent requests are in flight or the average response time
+// reaches max_latency; normal priority ones once twice max_concurrent are in
+// flight. High priority requests are never shed.
+func LoadShedding(config types.ProxyConfig, router types.Router) types.Middleware {
+	settings := config.Middleware.LoadShedding
+	ls := &loadShedder{
+		router:        router,
+		maxConcurrent: int64(settings.MaxConcurrent),
+		maxLatency:    settings.MaxLatency,
+		header:        settings.Header,
+		defaultClass:  settings.DefaultClass,
+		errorFormat:   config.ErrorFormat,
+	}
+	if ls.defaultClass == "" {
+		ls.defaultClass = QoSNormal
+	}
+
+	return ls.Middleware
+}
+
+// Middleware returns the middleware handler
+func (ls *loadShedder) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inFlight := ls.inFlight.Add(1)
+		defer ls.inFlight.Add(-1)
+
+		// Count the requests already in flight, not this one. Below the
+		// lowest limit nothing is shed, so the class isn't needed.
+		if ls.overloaded(inFlight - 1) {
+			if class := ls.classOf(r); ls.shed(class, inFlight-1) {
+				metrics.GlobalCollector.RecordShed(class)
+				w.Header().Set("Retry-After", "1")
+				types.WriteError(w, r, ls.errorFormat, "Server overloaded", http.StatusServiceUnavailable, map[string]any{"retry_after": 1})
+				return
+			}
+		}
+
+		start := time.Now()
+		next.ServeHTTP(w, r)
+		ls.observe(time.Since(start))
+	})
+}
+
+// classOf returns the request's QoS class from its route's metadata, else
+// the load shedding header, else the default class. Clients can't exempt
+// themselves from shedding: a header naming high is taken as normal, so only
+// routes can be made high priority.
+func (ls *loadShedder) classOf(r *http.Request) string {
+	if route, err := matchRoute(r, ls.router); err == nil {
+		if class, ok := route.Metadata[RouteMetadataQoSClass].(string); ok && validQoSClass(class) {
+			return strings.ToLower(class)
+		}
+	}
+	if ls.header != "" {
+		if class := r.Header.Get(ls.header); validQoSClass(class) {
+			if strings.EqualFold(class, QoSHigh) {
+				return QoSNormal
+			}
+			return strings.ToLower(class)
+		}
+	}
+	return ls.defaultClass
+}
+
+// overloaded reports whether requests of some class are being shed with
+// inFlight requests in flight
+func (ls *loadShedder) overloaded(inFlight int64) bool {
+	return (ls.maxConcurrent > 0 && inFlight >= ls.maxConcurrent) || ls.slow()
+}
+
+// shed reports whether a request of class should be rejected with
+// inFlight other requests in flight
+func (ls *loadShedder) shed(class string, inFlight int64) bool {
+	switch class {
+	case QoSHigh:
+		return false
+	case QoSNormal:
+		return ls.maxConcurrent > 0 && inFlight >= 2*ls.maxConcurrent
+	default:
+		return (ls.maxConcurrent > 0 && inFlight >= ls.maxConcurrent) || ls.slow()
+	}
+}
+
+// slow reports whether recent responses have been taking max_latency or
+// longer on average
+func (ls *loadShedder) slow() bool {
+	if ls.maxLatency <= 0 {
+		return false
+	}
+
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	return time.Since(ls.lastSample) < shedLatencyWindow && ls.latency >= ls.maxLatency
+}
+
+// observe adds a response time to the moving average
+func (ls *loadShedder) observe(d time.Duration) {
+	if ls.maxLatency <= 0 {
+		return
+	}
+
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	if time.Since(ls.lastSample) >= shedLatencyWindow {
+		// Start over rather than blend with a stale average
+		ls.latency = d
+	} else {
+		ls.latency += time.Duration(shedLatencyWeight * float64(d-ls.latency))
+	}
+	ls.lastSample = time.Now()
+}
+
+// validQoSClass reports whether class names a QoS class, ignoring case
+func validQoSClass(class string) bool {
+	switch strings.ToLower(class) {
+	case QoSHigh, QoSNormal, QoSLow:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,179 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+
+	"discobox/internal/types"
+)
+
+// ErrRequestTimeout is returned by writes from a handler that outlived the
+// request deadline
+var ErrRequestTimeout = errors.New("request deadline exceeded")
+
+// Timeout creates middleware that gives each request
+// middleware.request_timeout to finish, whatever route or service timeouts
+// apply further in. The request's context is
+// cancelled at the deadline; if the handler hasn't started its response by
+// then the client gets 504, and if it has the response is aborted, since a
+// cut-off body would look complete. Protocol upgrades and event streams are
+// exempt, and routes can opt out with disabled_middlewares.
+func Timeout(config types.ProxyConfig) types.Middleware {
+	d := config.Middleware.RequestTimeout
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if d <= 0 || isStreaming(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+
+			tw := &timeoutWriter{w: w, header: make(http.Header), ctx: ctx}
+			done := make(chan struct{})
+			panicked := make(chan any, 1)
+
+			go func() {
+				defer func() {
+					if p := recover(); p != nil {
+						panicked <- p
+					}
+				}()
+				next.ServeHTTP(tw, r.WithContext(ctx))
+				close(done)
+			}()
+
+			finished := false
+			select {
+			case p := <-panicked:
+				panic(p)
+			case <-done:
+				finished = true
+			case <-ctx.Done():
+			}
+
+			tw.mu.Lock()
+			defer tw.mu.Unlock()
+
+			if ctx.Err() == nil {
+				return
+			}
+			// The handler may outlive this call; keep it off w from here on
+			tw.timedOut = true
+			if ctx.Err() != context.DeadlineExceeded {
+				// The client went away
+				return
+			}
+
+			if !tw.wroteHeader {
+				types.WriteError(w, r, config.ErrorFormat, "Request timed out", http.StatusGatewayTimeout, nil)
+				return
+			}
+			if !finished {
+				select {
+				case <-done:
+					finished = true
+				default:
+				}
+			}
+			// A response finished just as the deadline passed is complete
+			// unless some of it was dropped
+			if !finished || tw.dropped {
+				panic(http.ErrAbortHandler)
+			}
+		})
+	}
+}
+
+// isStreaming reports whether a request is expected to hold its connection
+// open: protocol upgrades such as WebSocket, and server-sent event streams
+func isStreaming(r *http.Request) bool {
+	return r.Header.Get("Upgrade") != "" || strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+}
+
+// timeoutWriter passes a handler's response through until the deadline,
+// after which its writes fail. The handler gets its own header map so the
+// timeout response can't race with it.
+type timeoutWriter struct {
+	w      http.ResponseWriter
+	header http.Header
+	ctx    context.Context
+
+	mu          sync.Mutex
+	wroteHeader bool
+	timedOut    bool
+	dropped     bool // A write came after the deadline
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.expiredLocked() || tw.wroteHeader {
+		return
+	}
+	tw.writeHeaderLocked(code)
+}
+
+// expiredLocked reports whether the deadline has passed, even if Timeout
+// hasn't yet noticed; the caller holds the lock
+func (tw *timeoutWriter) expiredLocked() bool {
+	return tw.timedOut || tw.ctx.Err() == context.DeadlineExceeded
+}
+
+// writeHeaderLocked sends the handler's headers; the caller holds the lock
+func (tw *timeoutWriter) writeHeaderLocked(code int) {
+	dst := tw.w.Header()
+	for k, v := range tw.header {
+		dst[k] = v
+	}
+	tw.w.WriteHeader(code)
+
+	// Informational responses such as 103 Early Hints precede the real one
+	if types.IsInterimStatus(code) {
+		return
+	}
+	tw.wroteHeader = true
+}
+
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.expiredLocked() {
+		tw.dropped = true
+		return 0, ErrRequestTimeout
+	}
+	if !tw.wroteHeader {
+		tw.writeHeaderLocked(http.StatusOK)
+	}
+	return tw.w.Write(b)
+}
+
+// Unwrap returns the underlying response writer for http.ResponseController
+func (tw *timeoutWriter) Unwrap() http.ResponseWriter {
+	return tw.w
+}
+
+// Flush sends buffered data to the client, so responses streamed by
+// non-exempt requests still reach it as they are written
+func (tw *timeoutWriter) Flush() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.expiredLocked() {
+		return
+	}
+	if !tw.wroteHeader {
+		tw.writeHeaderLocked(http.StatusOK)
+	}
+	http.NewResponseController(tw.w).Flush()
+}
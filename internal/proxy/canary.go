@@ -0,0 +1,48 @@
+package proxy
+
+import (
+	"math/rand/v2"
+	"net/http"
+
+	"discobox/internal/types"
+)
+
+// routeServiceID returns the service a request is sent to. Routes with a
+// canary send new clients to the canary service with the configured
+// probability and pin them there with a cookie of the route's own; returning
+// clients keep their variant. A weight of zero sends everyone to the route's own service.
+func routeServiceID(w http.ResponseWriter, r *http.Request, route *types.Route) string {
+	canary := route.Canary
+	if canary == nil || canary.Weight <= 0 || canary.ServiceID == "" {
+		return route.ServiceID
+	}
+
+	cookieName := types.CanaryCookieName(route.ID)
+	variant := ""
+	if cookie, err := r.Cookie(cookieName); err == nil {
+		switch cookie.Value {
+		case types.VariantStable, types.VariantCanary:
+			variant = cookie.Value
+		}
+	}
+
+	if variant == "" {
+		variant = types.VariantStable
+		if rand.IntN(100) < canary.Weight {
+			variant = types.VariantCanary
+		}
+		http.SetCookie(w, &http.Cookie{
+			Name:     cookieName,
+			Value:    variant,
+			Path:     "/",
+			Secure:   r.TLS != nil,
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		})
+	}
+
+	if variant == types.VariantCanary {
+		return canary.ServiceID
+	}
+	return route.ServiceID
+}
@@ -0,0 +1,63 @@
+package proxy
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"discobox/internal/types"
+)
+
+// RequestTimeoutHeader carries the time a request has left, in milliseconds.
+// Clients may send it to shorten the deadline; backends receive the time
+// remaining when the request is forwarded.
+const RequestTimeoutHeader = "X-Request-Timeout"
+
+// requestTimeout returns the timeout a client asked for, if it sent a valid one
+func requestTimeout(r *http.Request) (time.Duration, bool) {
+	value := r.Header.Get(RequestTimeoutHeader)
+	if value == "" {
+		return 0, false
+	}
+
+	ms, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || ms <= 0 || ms > math.MaxInt64/int64(time.Millisecond) {
+		return 0, false
+	}
+	return time.Duration(ms) * time.Millisecond, true
+}
+
+// withDeadline bounds the request by the service timeout and the client's
+// requested timeout, whichever is shorter. An existing context deadline
+// still applies if it is sooner. The returned cancel func is never nil.
+func withDeadline(r *http.Request, service *types.Service) (*http.Request, context.CancelFunc) {
+	timeout := service.Timeout
+	if requested, ok := requestTimeout(r); ok && (timeout <= 0 || requested < timeout) {
+		timeout = requested
+	}
+
+	if timeout <= 0 {
+		return r, func() {}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	return r.WithContext(ctx), cancel
+}
+
+// setRemainingTimeout tells the backend how long it has to respond. Requests
+// without a deadline don't pass the header on.
+func setRemainingTimeout(req *http.Request) {
+	deadline, ok := req.Context().Deadline()
+	if !ok {
+		req.Header.Del(RequestTimeoutHeader)
+		return
+	}
+
+	remaining := time.Until(deadline).Milliseconds()
+	if remaining < 1 {
+		remaining = 1
+	}
+	req.Header.Set(RequestTimeoutHeader, strconv.FormatInt(remaining, 10))
+}
@@ -0,0 +1,62 @@
+package proxy
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"discobox/internal/types"
+)
+
+// DefaultMaxDecompressedSize is the largest request body inflated when
+// Options.MaxDecompressedSize is not set
+const DefaultMaxDecompressedSize = 10 * 1024 * 1024
+
+// decompressRequest replaces a gzip or deflate encoded request body with the
+// plain bytes so backends that can't decode it receive what the client meant.
+// The body is buffered so Content-Length can be set; inflating past maxSize
+// fails with ErrBodyTooLarge to guard against decompression bombs. Other
+// encodings are forwarded untouched.
+func decompressRequest(r *http.Request, maxSize int64) error {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+
+	encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding")))
+
+	var reader io.ReadCloser
+	var err error
+	switch encoding {
+	case "gzip", "x-gzip":
+		reader, err = gzip.NewReader(r.Body)
+	case "deflate":
+		reader, err = zlib.NewReader(r.Body)
+	default:
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%w: malformed %s request body: %v", types.ErrInvalidRequest, encoding, err)
+	}
+	defer reader.Close()
+
+	body, err := io.ReadAll(io.LimitReader(reader, maxSize+1))
+	if err != nil {
+		return fmt.Errorf("%w: malformed %s request body: %v", types.ErrInvalidRequest, encoding, err)
+	}
+	if int64(len(body)) > maxSize {
+		return fmt.Errorf("%w: decompressed body exceeds %d bytes", types.ErrBodyTooLarge, maxSize)
+	}
+	r.Body.Close()
+
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.ContentLength = int64(len(body))
+	r.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	r.Header.Del("Content-Encoding")
+
+	return nil
+}
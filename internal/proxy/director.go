@@ -25,10 +25,14 @@ func NewDirector(preserveHost bool, rewriter types.URLRewriter) *Director {
 	}
 }
 
-// Direct modifies the request for the backend
-func (d *Director) Direct(req *http.Request, backend *types.Server, route *types.Route) {
-	// Set the scheme and host
+// Direct modifies the request for the backend. service may be nil when the
+// route's service isn't at hand.
+func (d *Director) Direct(req *http.Request, backend *types.Server, route *types.Route, service *types.Service) {
+	// Set the scheme and host; the route or service may force the scheme
 	req.URL.Scheme = backend.URL.Scheme
+	if route != nil {
+		req.URL.Scheme = backendScheme(backend, route, service)
+	}
 	req.URL.Host = backend.URL.Host
 
 	// Handle host header
@@ -63,17 +67,10 @@ func (d *Director) Direct(req *http.Request, backend *types.Server, route *types
 // addForwardingHeaders adds X-Forwarded-* and related headers
 func (d *Director) addForwardingHeaders(req *http.Request) {
 	// X-Real-IP
-	if clientIP, _, err := net.SplitHostPort(req.RemoteAddr); err == nil {
-		if req.Header.Get("X-Real-IP") == "" {
-			req.Header.Set("X-Real-IP", clientIP)
-		}
+	req.Header.Set("X-Real-IP", types.ClientIP(req))
 
-		// X-Forwarded-For
-		if prior, ok := req.Header["X-Forwarded-For"]; ok {
-			clientIP = strings.Join(prior, ", ") + ", " + clientIP
-		}
-		req.Header.Set("X-Forwarded-For", clientIP)
-	}
+	// X-Forwarded-For, normalized, with the peer appended
+	setForwardedFor(req, true)
 
 	// X-Forwarded-Proto
 	if req.TLS != nil {
@@ -91,6 +88,9 @@ func (d *Director) addForwardingHeaders(req *http.Request) {
 	if port := d.getPort(req); port != "" {
 		req.Header.Set("X-Forwarded-Port", port)
 	}
+
+	// The director strips no prefix, so a client's X-Forwarded-Prefix is false
+	req.Header.Del(ForwardedPrefixHeader)
 }
 
 // getPort extracts the port from the request
@@ -0,0 +1,505 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"discobox/internal/types"
+
+	"github.com/miekg/dns"
+)
+
+// HostLookup resolves a host name to its addresses. *net.Resolver
+// satisfies it.
+type HostLookup interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// TTLLookup is a HostLookup that also reports how long its answers stay
+// valid. The resolver caches such answers for no longer than their TTL.
+type TTLLookup interface {
+	HostLookup
+	LookupHostTTL(ctx context.Context, host string) ([]string, time.Duration, error)
+}
+
+// hostsFile is consulted before the configured DNS servers, as the system
+// resolver would
+const hostsFile = "/etc/hosts"
+
+// defaultFallbackDelay is how long a dial waits on the first address family
+// before racing the other, when the dialer doesn't set FallbackDelay
+const defaultFallbackDelay = 300 * time.Millisecond
+
+// NewDNSLookup returns a lookup that queries the given DNS servers directly,
+// moving on to the next when one fails, and reports the TTL of the records it
+// gets back. Servers without a port use 53. Hosts listed in /etc/hosts are
+// answered from there. Names are queried as given, without resolv.conf search
+// domains, so they must be fully qualified. Without servers, the system
+// resolver is used; it doesn't report TTLs, so its answers are cached for the
+// configured TTL.
+func NewDNSLookup(servers []string, timeout time.Duration) HostLookup {
+	if len(servers) == 0 {
+		return net.DefaultResolver
+	}
+
+	addrs := make([]string, len(servers))
+	for i, server := range servers {
+		if _, _, err := net.SplitHostPort(server); err != nil {
+			server = net.JoinHostPort(server, "53")
+		}
+		addrs[i] = server
+	}
+
+	return &dnsLookup{
+		servers: addrs,
+		udp:     &dns.Client{Net: "udp", Timeout: timeout},
+		tcp:     &dns.Client{Net: "tcp", Timeout: timeout},
+	}
+}
+
+// dnsLookup resolves hosts by querying DNS servers for A and AAAA records
+type dnsLookup struct {
+	servers []string
+	udp     *dns.Client
+	tcp     *dns.Client // For answers too large for UDP
+	next    atomic.Uint64
+}
+
+func (l *dnsLookup) LookupHost(ctx context.Context, host string) ([]string, error) {
+	addrs, _, err := l.LookupHostTTL(ctx, host)
+	return addrs, err
+}
+
+// LookupHostTTL returns host's IPv4 and IPv6 addresses and the lowest TTL of
+// the records leading to them, CNAMEs included. A zero TTL is reported as
+// one second, so a burst of dials shares a lookup.
+func (l *dnsLookup) LookupHostTTL(ctx context.Context, host string) ([]string, time.Duration, error) {
+	// Hosts file entries have no TTL; the resolver's own applies
+	if addrs := lookupHostsFile(hostsFile, host); len(addrs) > 0 {
+		return addrs, 0, nil
+	}
+
+	var addrs []string
+	var ttl uint32
+	var errs []error
+
+	for _, qtype := range []uint16{dns.TypeA, dns.TypeAAAA} {
+		answer, err := l.query(ctx, host, qtype)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, rr := range answer {
+			switch rr := rr.(type) {
+			case *dns.A:
+				addrs = append(addrs, rr.A.String())
+			case *dns.AAAA:
+				addrs = append(addrs, rr.AAAA.String())
+			case *dns.CNAME:
+				// Counts toward the TTL
+			default:
+				continue
+			}
+			if ttl == 0 || rr.Header().Ttl < ttl {
+				ttl = max(rr.Header().Ttl, 1)
+			}
+		}
+	}
+
+	if len(addrs) == 0 {
+		if len(errs) > 0 {
+			return nil, 0, errors.Join(errs...)
+		}
+		return nil, 0, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	return addrs, time.Duration(ttl) * time.Second, nil
+}
+
+// query asks the servers in turn for host's records of qtype, starting with
+// the one after the server the previous query started with
+func (l *dnsLookup) query(ctx context.Context, host string, qtype uint16) ([]dns.RR, error) {
+	msg := new(dns.Msg)
+	msg.SetQuestion(dns.Fqdn(host), qtype)
+
+	start := l.next.Add(1) - 1
+	var err error
+	for i := range l.servers {
+		server := l.servers[(start+uint64(i))%uint64(len(l.servers))]
+
+		var resp *dns.Msg
+		resp, _, err = l.udp.ExchangeContext(ctx, msg, server)
+		if err == nil && resp.Truncated {
+			resp, _, err = l.tcp.ExchangeContext(ctx, msg, server)
+		}
+
+		switch {
+		case err != nil:
+			err = &net.DNSError{Err: err.Error(), Name: host, Server: server, IsTimeout: isTimeout(err)}
+		case resp.Rcode == dns.RcodeSuccess:
+			return resp.Answer, nil
+		case resp.Rcode == dns.RcodeNameError:
+			return nil, &net.DNSError{Err: "no such host", Name: host, Server: server, IsNotFound: true}
+		default:
+			err = &net.DNSError{Err: "server returned " + dns.RcodeToString[resp.Rcode], Name: host, Server: server}
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, err
+}
+
+// lookupHostsFile returns the addresses a hosts file lists for host, if any
+func lookupHostsFile(path, host string) []string {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	host = strings.TrimSuffix(host, ".")
+	var addrs []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(line)
+		if len(fields) < 2 || net.ParseIP(fields[0]) == nil {
+			continue
+		}
+		for _, name := range fields[1:] {
+			if strings.EqualFold(strings.TrimSuffix(name, "."), host) {
+				addrs = append(addrs, fields[0])
+				break
+			}
+		}
+	}
+	return addrs
+}
+
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// dnsEntry is a cached lookup result
+type dnsEntry struct {
+	addrs   []string
+	expires time.Time
+	used    bool // Looked up since the last refresh
+}
+
+// dnsCall is a lookup in progress, shared by every Resolve that misses the
+// cache for the same host meanwhile
+type dnsCall struct {
+	done  chan struct{}
+	addrs []string
+	err   error
+}
+
+// Resolver caches backend host lookups. Answers are kept for the configured
+// TTL, or the record's own TTL when the lookup reports a shorter one. A
+// refresh loop re-resolves hosts in use before they expire so dials don't
+// wait on DNS, and keeps the previous addresses when a refresh fails.
+type Resolver struct {
+	lookup          HostLookup
+	ttl             time.Duration
+	refreshInterval time.Duration
+	logger          types.Logger
+
+	mu       sync.Mutex
+	entries  map[string]*dnsEntry
+	inflight map[string]*dnsCall
+
+	stopOnce sync.Once
+	stopCh   chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewResolver creates a resolver caching lookups for ttl. A zero ttl
+// disables caching; a zero refreshInterval disables the refresh loop.
+func NewResolver(lookup HostLookup, ttl, refreshInterval time.Duration, logger types.Logger) *Resolver {
+	if lookup == nil {
+		lookup = net.DefaultResolver
+	}
+	return &Resolver{
+		lookup:          lookup,
+		ttl:             ttl,
+		refreshInterval: refreshInterval,
+		logger:          logger,
+		entries:         make(map[string]*dnsEntry),
+		inflight:        make(map[string]*dnsCall),
+		stopCh:          make(chan struct{}),
+	}
+}
+
+// Start begins refreshing cached hosts in the background
+func (r *Resolver) Start() {
+	if r.ttl <= 0 || r.refreshInterval <= 0 {
+		return
+	}
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		ticker := time.NewTicker(r.refreshInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				r.Refresh(context.Background())
+			case <-r.stopCh:
+				return
+			}
+		}
+	}()
+}
+
+// Stop ends the refresh loop
+func (r *Resolver) Stop() {
+	r.stopOnce.Do(func() { close(r.stopCh) })
+	r.wg.Wait()
+}
+
+// Resolve returns the addresses of host, from the cache while they are
+// fresh. IP literals are returned as they are. Concurrent misses for a host
+// share one lookup.
+func (r *Resolver) Resolve(ctx context.Context, host string) ([]string, error) {
+	if net.ParseIP(host) != nil {
+		return []string{host}, nil
+	}
+	if r.ttl <= 0 {
+		return r.lookup.LookupHost(ctx, host)
+	}
+
+	r.mu.Lock()
+	if entry, ok := r.entries[host]; ok && time.Now().Before(entry.expires) {
+		entry.used = true
+		addrs := entry.addrs
+		r.mu.Unlock()
+		return addrs, nil
+	}
+	if call, ok := r.inflight[host]; ok {
+		r.mu.Unlock()
+		select {
+		case <-call.done:
+			return call.addrs, call.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	call := &dnsCall{done: make(chan struct{})}
+	r.inflight[host] = call
+	r.mu.Unlock()
+
+	addrs, ttl, err := r.lookupTTL(ctx, host)
+	if err == nil {
+		r.store(host, addrs, ttl, true)
+	}
+	call.addrs, call.err = addrs, err
+
+	r.mu.Lock()
+	delete(r.inflight, host)
+	r.mu.Unlock()
+	close(call.done)
+
+	return addrs, err
+}
+
+// Refresh re-resolves the cached hosts looked up since the previous refresh
+// and drops the rest, so hosts no longer dialed age out of the cache
+func (r *Resolver) Refresh(ctx context.Context) {
+	r.mu.Lock()
+	var hosts []string
+	for host, entry := range r.entries {
+		if !entry.used {
+			delete(r.entries, host)
+			continue
+		}
+		entry.used = false
+		hosts = append(hosts, host)
+	}
+	r.mu.Unlock()
+
+	for _, host := range hosts {
+		addrs, ttl, err := r.lookupTTL(ctx, host)
+		if err != nil {
+			if r.logger != nil {
+				r.logger.Warn("DNS refresh failed, keeping previous addresses", "host", host, "error", err)
+			}
+			r.extend(host)
+			continue
+		}
+		r.store(host, addrs, ttl, false)
+	}
+}
+
+// DialContext returns a dial function that resolves hosts through the
+// resolver and dials their addresses with Happy Eyeballs (RFC 6555), as
+// net.Dialer does for host names
+func (r *Resolver) DialContext(dialer *net.Dialer) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+
+		addrs, err := r.Resolve(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		if len(addrs) == 0 {
+			return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+		}
+
+		return dialHappyEyeballs(ctx, dialer, network, port, addrs)
+	}
+}
+
+// dialHappyEyeballs tries the addresses of the first address's family in
+// turn, and races the other family against them once they have had the
+// dialer's fallback delay to connect, or have all failed
+func dialHappyEyeballs(ctx context.Context, dialer *net.Dialer, network, port string, addrs []string) (net.Conn, error) {
+	primaries, fallbacks := splitAddrFamilies(addrs)
+	if len(fallbacks) == 0 {
+		return dialSerial(ctx, dialer, network, port, primaries)
+	}
+
+	delay := dialer.FallbackDelay
+	if delay <= 0 {
+		delay = defaultFallbackDelay
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type dialResult struct {
+		conn net.Conn
+		err  error
+	}
+	results := make(chan dialResult, 2)
+	race := func(addrs []string) {
+		conn, err := dialSerial(ctx, dialer, network, port, addrs)
+		results <- dialResult{conn, err}
+	}
+
+	go race(primaries)
+	pending, fallbackStarted := 1, false
+	startFallback := func() {
+		if !fallbackStarted {
+			fallbackStarted = true
+			pending++
+			go race(fallbacks)
+		}
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	var errs []error
+	for {
+		select {
+		case <-timer.C:
+			startFallback()
+		case res := <-results:
+			pending--
+			if res.err == nil {
+				// Close the loser's connection should it still connect
+				if pending > 0 {
+					go func() {
+						if late := <-results; late.conn != nil {
+							late.conn.Close()
+						}
+					}()
+				}
+				return res.conn, nil
+			}
+			errs = append(errs, res.err)
+			startFallback()
+			if pending == 0 {
+				return nil, errors.Join(errs...)
+			}
+		}
+	}
+}
+
+// splitAddrFamilies splits addresses into those of the first address's
+// family and the rest, keeping their order
+func splitAddrFamilies(addrs []string) (primaries, fallbacks []string) {
+	isIPv4 := func(addr string) bool {
+		ip := net.ParseIP(addr)
+		return ip != nil && ip.To4() != nil
+	}
+
+	first := isIPv4(addrs[0])
+	for _, addr := range addrs {
+		if isIPv4(addr) == first {
+			primaries = append(primaries, addr)
+		} else {
+			fallbacks = append(fallbacks, addr)
+		}
+	}
+	return primaries, fallbacks
+}
+
+// dialSerial tries each address in turn until one connects
+func dialSerial(ctx context.Context, dialer *net.Dialer, network, port string, addrs []string) (net.Conn, error) {
+	var errs []error
+	for _, ip := range addrs {
+		conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(ip, port))
+		if err == nil {
+			return conn, nil
+		}
+		errs = append(errs, err)
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, errors.Join(errs...)
+}
+
+// lookupTTL looks host up, capping the cache TTL at the record's when the
+// lookup reports one
+func (r *Resolver) lookupTTL(ctx context.Context, host string) ([]string, time.Duration, error) {
+	ttl := r.ttl
+	if lookup, ok := r.lookup.(TTLLookup); ok {
+		addrs, recordTTL, err := lookup.LookupHostTTL(ctx, host)
+		if err != nil {
+			return nil, 0, err
+		}
+		if recordTTL > 0 && recordTTL < ttl {
+			ttl = recordTTL
+		}
+		return addrs, ttl, nil
+	}
+
+	addrs, err := r.lookup.LookupHost(ctx, host)
+	return addrs, ttl, err
+}
+
+func (r *Resolver) store(host string, addrs []string, ttl time.Duration, used bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if existing, ok := r.entries[host]; ok {
+		used = used || existing.used
+	}
+	r.entries[host] = &dnsEntry{addrs: addrs, expires: time.Now().Add(ttl), used: used}
+}
+
+// extend keeps serving a host's previous addresses for another TTL
+func (r *Resolver) extend(host string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if entry, ok := r.entries[host]; ok {
+		entry.expires = time.Now().Add(r.ttl)
+	}
+}
@@ -0,0 +1,33 @@
+package proxy
+
+import (
+	"net/http"
+
+	"discobox/internal/types"
+)
+
+// sendEarlyHints writes a 103 Early Hints response carrying the route's
+// preload links so the client can start fetching them while the backend
+// works. Browsers only act on early hints over HTTP/2 and later, and
+// HTTP/1.x clients are known to mishandle unexpected 1xx responses, so
+// older protocols are skipped.
+func sendEarlyHints(w http.ResponseWriter, r *http.Request, route *types.Route) {
+	if len(route.EarlyHints) == 0 || r.ProtoMajor < 2 {
+		return
+	}
+
+	header := w.Header()
+	prior := header.Values("Link")
+	for _, link := range route.EarlyHints {
+		header.Add("Link", link)
+	}
+
+	w.WriteHeader(http.StatusEarlyHints)
+
+	// The final response carries the backend's own Link headers, not ours
+	if len(prior) == 0 {
+		header.Del("Link")
+	} else {
+		header["Link"] = prior
+	}
+}
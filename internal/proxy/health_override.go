@@ -0,0 +1,79 @@
+package proxy
+
+import (
+	"net/url"
+	"sync"
+)
+
+// healthOverrides holds backend health forced by operators, by service and
+// endpoint URL, so an override follows its endpoint when the service's
+// endpoints are reordered. Overrides live in memory and end with the process.
+type healthOverrides struct {
+	mu     sync.RWMutex
+	health map[overrideKey]bool
+}
+
+// overrideKey names a service's endpoint by its normalized URL
+type overrideKey struct {
+	serviceID string
+	endpoint  string
+}
+
+// get returns a backend's forced health and whether it has one
+func (o *healthOverrides) get(serviceID string, endpoint *url.URL) (healthy, ok bool) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	healthy, ok = o.health[overrideKey{serviceID, endpoint.String()}]
+	return healthy, ok
+}
+
+// clearService drops the overrides for a deleted service's backends
+func (o *healthOverrides) clearService(serviceID string) {
+	o.retain(serviceID, nil)
+}
+
+// retain drops the overrides for a service's endpoints that aren't among
+// endpoints, so an endpoint removed and later added back starts afresh
+func (o *healthOverrides) retain(serviceID string, endpoints []string) {
+	keep := make(map[string]bool, len(endpoints))
+	for _, endpoint := range endpoints {
+		keep[normalizeEndpoint(endpoint)] = true
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for key := range o.health {
+		if key.serviceID == serviceID && !keep[key.endpoint] {
+			delete(o.health, key)
+		}
+	}
+}
+
+// normalizeEndpoint returns an endpoint URL as backends built from it print it
+func normalizeEndpoint(endpoint string) string {
+	if u, err := url.Parse(endpoint); err == nil {
+		return u.String()
+	}
+	return endpoint
+}
+
+// OverrideHealth forces the backend for a service's endpoint healthy or
+// unhealthy, whatever health checks and outlier detection say, until
+// ClearHealthOverride is called or the endpoint leaves the service
+func (p *Proxy) OverrideHealth(serviceID, endpoint string, healthy bool) {
+	p.overrides.mu.Lock()
+	defer p.overrides.mu.Unlock()
+
+	if p.overrides.health == nil {
+		p.overrides.health = make(map[overrideKey]bool)
+	}
+	p.overrides.health[overrideKey{serviceID, normalizeEndpoint(endpoint)}] = healthy
+}
+
+// ClearHealthOverride hands a backend's health back to the health checks
+func (p *Proxy) ClearHealthOverride(serviceID, endpoint string) {
+	p.overrides.mu.Lock()
+	defer p.overrides.mu.Unlock()
+	delete(p.overrides.health, overrideKey{serviceID, normalizeEndpoint(endpoint)})
+}
@@ -0,0 +1,195 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"discobox/internal/metrics"
+	"discobox/internal/types"
+)
+
+// isHedgeable reports whether a request can safely be sent twice. Only safe
+// methods without a body qualify; upgrades can't be raced.
+func isHedgeable(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+	default:
+		return false
+	}
+	if req.Body != nil && req.Body != http.NoBody {
+		return false
+	}
+	return req.Header.Get("Upgrade") == ""
+}
+
+// hedgingTransport is a transport that sends a second copy of a slow request
+// to a different backend once delay has passed, returning whichever response
+// arrives first and canceling the other
+type hedgingTransport struct {
+	proxy   *Proxy
+	next    http.RoundTripper
+	service *types.Service
+	route   *types.Route
+	primary *types.Server
+	delay   time.Duration
+
+	// onHedgeWin is called when the hedged backend answers first
+	onHedgeWin func(server *types.Server)
+}
+
+// hedgeAttempt is one in-flight copy of the request
+type hedgeAttempt struct {
+	server *types.Server
+	cancel context.CancelFunc
+	done   func() // Releases the backend's active connection slot
+}
+
+// hedgeResult is the outcome of an attempt
+type hedgeResult struct {
+	attempt *hedgeAttempt
+	resp    *http.Response
+	err     error
+}
+
+// RoundTrip sends the request, hedging it if no response arrives within the delay
+func (ht *hedgingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !isHedgeable(req) {
+		return ht.next.RoundTrip(req)
+	}
+
+	results := make(chan hedgeResult, 2)
+	launch := func(r *http.Request, server *types.Server, done func()) *hedgeAttempt {
+		ctx, cancel := context.WithCancel(r.Context())
+		attempt := &hedgeAttempt{server: server, cancel: cancel, done: done}
+		go func() {
+			resp, err := ht.next.RoundTrip(r.WithContext(ctx))
+			results <- hedgeResult{attempt: attempt, resp: resp, err: err}
+		}()
+		return attempt
+	}
+
+	// The primary's active connection is tracked by ServeHTTP
+	attempts := []*hedgeAttempt{launch(req, ht.primary, func() {})}
+	inflight := 1
+
+	timer := time.NewTimer(ht.delay)
+	defer timer.Stop()
+	hedgeAfter := timer.C
+
+	var firstErr error
+	for {
+		select {
+		case <-hedgeAfter:
+			hedgeAfter = nil
+			server := ht.selectHedge(req)
+			if server == nil {
+				continue
+			}
+
+			hedgeReq := req.Clone(req.Context())
+			hedgeReq.URL.Scheme = backendScheme(server, ht.route, ht.service)
+			hedgeReq.URL.Host = server.URL.Host
+
+			shared := ht.proxy.sharedServer(ht.service.ID, server)
+			atomic.AddInt64(&shared.ActiveConns, 1)
+			attempts = append(attempts, launch(hedgeReq, server, func() { atomic.AddInt64(&shared.ActiveConns, -1) }))
+			inflight++
+			metrics.GlobalCollector.RecordRouteHedge(ht.route.ID, metrics.HedgeSent)
+
+		case result := <-results:
+			inflight--
+			if result.err != nil {
+				result.attempt.cancel()
+				result.attempt.done()
+				if firstErr == nil {
+					firstErr = result.err
+				}
+				// A failure with nothing else in flight is returned as is; hedging
+				// covers slow backends, retries cover failing ones
+				if inflight == 0 {
+					return nil, firstErr
+				}
+				continue
+			}
+
+			// Cancel the loser and discard whatever it returns
+			for _, attempt := range attempts {
+				if attempt != result.attempt {
+					attempt.cancel()
+				}
+			}
+			if inflight > 0 {
+				go drainHedges(results, inflight)
+			}
+
+			if result.attempt.server != ht.primary {
+				metrics.GlobalCollector.RecordRouteHedge(ht.route.ID, metrics.HedgeWon)
+				if ht.onHedgeWin != nil {
+					ht.onHedgeWin(result.attempt.server)
+				}
+			}
+
+			// Keep the winner's context alive until its body has been copied
+			result.resp.Body = &hedgeBody{
+				ReadCloser: result.resp.Body,
+				release: func() {
+					result.attempt.cancel()
+					result.attempt.done()
+				},
+			}
+			return result.resp, nil
+		}
+	}
+}
+
+// selectHedge asks the load balancer for a backend other than the primary,
+// or returns nil when there is none
+func (ht *hedgingTransport) selectHedge(req *http.Request) *types.Server {
+	servers := ht.proxy.endpointsToServers(ht.service)
+
+	others := make([]*types.Server, 0, len(servers))
+	for _, server := range servers {
+		if server.ID != ht.primary.ID {
+			others = append(others, server)
+		}
+	}
+	if len(others) == 0 {
+		return nil
+	}
+
+	server, err := ht.proxy.loadBalancer.Select(req.Context(), req, others)
+	if err != nil || server.ID == ht.primary.ID {
+		return nil
+	}
+	recordSelection(ht.service.ID, server)
+	return server
+}
+
+// drainHedges collects canceled attempts so their connections are released
+func drainHedges(results <-chan hedgeResult, n int) {
+	for i := 0; i < n; i++ {
+		result := <-results
+		if result.resp != nil {
+			result.resp.Body.Close()
+		}
+		result.attempt.done()
+	}
+}
+
+// hedgeBody releases the winning attempt once its body is closed
+type hedgeBody struct {
+	io.ReadCloser
+	release func()
+	closed  atomic.Bool
+}
+
+func (hb *hedgeBody) Close() error {
+	err := hb.ReadCloser.Close()
+	if hb.closed.CompareAndSwap(false, true) {
+		hb.release()
+	}
+	return err
+}
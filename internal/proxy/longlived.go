@@ -0,0 +1,102 @@
+package proxy
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// isLongLived reports whether a request is expected to hold its connection open
+// (protocol upgrades such as WebSocket, or server-sent event streams)
+func isLongLived(r *http.Request) bool {
+	return isUpgrade(r) || strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+}
+
+// isUpgrade reports whether a request asks to switch protocols, e.g. to WebSocket
+func isUpgrade(r *http.Request) bool {
+	if r.Header.Get("Upgrade") == "" {
+		return false
+	}
+
+	for _, v := range r.Header.Values("Connection") {
+		for _, token := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// prepareLongLived clears the server read/write deadlines for a long-lived request
+// and, when an idle timeout is configured, wraps the writer so hijacked connections
+// are closed after sitting idle
+func (p *Proxy) prepareLongLived(w http.ResponseWriter, r *http.Request) http.ResponseWriter {
+	rc := http.NewResponseController(w)
+	if err := rc.SetReadDeadline(time.Time{}); err != nil {
+		p.logger.Debug("unable to clear read deadline", "error", err, "path", r.URL.Path)
+	}
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		p.logger.Debug("unable to clear write deadline", "error", err, "path", r.URL.Path)
+	}
+
+	if p.longLivedIdleTimeout <= 0 {
+		return w
+	}
+
+	return &idleTimeoutWriter{
+		ResponseWriter: w,
+		timeout:        p.longLivedIdleTimeout,
+	}
+}
+
+// idleTimeoutWriter hands out connections that enforce an idle timeout once hijacked
+type idleTimeoutWriter struct {
+	http.ResponseWriter
+	timeout time.Duration
+}
+
+// Hijack takes over the underlying connection and wraps it with idle tracking
+func (iw *idleTimeoutWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err := http.NewResponseController(iw.ResponseWriter).Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ic := &idleConn{Conn: conn, timeout: iw.timeout}
+	ic.extend()
+	return ic, brw, nil
+}
+
+// Unwrap returns the underlying response writer for http.ResponseController
+func (iw *idleTimeoutWriter) Unwrap() http.ResponseWriter {
+	return iw.ResponseWriter
+}
+
+// idleConn pushes its deadline forward on every read or write
+type idleConn struct {
+	net.Conn
+	timeout time.Duration
+}
+
+func (c *idleConn) extend() {
+	c.Conn.SetDeadline(time.Now().Add(c.timeout))
+}
+
+func (c *idleConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		c.extend()
+	}
+	return n, err
+}
+
+func (c *idleConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	if n > 0 {
+		c.extend()
+	}
+	return n, err
+}
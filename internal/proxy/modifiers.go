@@ -0,0 +1,105 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// DefaultResponseModifier names the modifier set with Options.ModifyResponse
+const DefaultResponseModifier = "default"
+
+// ResponseModifier is a named step run on every backend response before it
+// is returned to the client
+type ResponseModifier struct {
+	Name   string
+	Modify func(*http.Response) error
+}
+
+// modifierChain runs response modifiers in registration order. The slice is
+// replaced rather than changed in place, so responses in flight keep the
+// modifiers they started with.
+type modifierChain struct {
+	mu        sync.RWMutex
+	modifiers []ResponseModifier
+}
+
+// use appends a modifier, or replaces the one registered under the same
+// name in its place
+func (mc *modifierChain) use(modifier ResponseModifier) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	modifiers := make([]ResponseModifier, len(mc.modifiers), len(mc.modifiers)+1)
+	copy(modifiers, mc.modifiers)
+	for i, existing := range modifiers {
+		if existing.Name == modifier.Name {
+			modifiers[i] = modifier
+			mc.modifiers = modifiers
+			return
+		}
+	}
+	mc.modifiers = append(modifiers, modifier)
+}
+
+// remove drops the modifier registered under name, reporting whether there was one
+func (mc *modifierChain) remove(name string) bool {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	for i, existing := range mc.modifiers {
+		if existing.Name == name {
+			modifiers := make([]ResponseModifier, 0, len(mc.modifiers)-1)
+			modifiers = append(modifiers, mc.modifiers[:i]...)
+			mc.modifiers = append(modifiers, mc.modifiers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// names returns the registered modifier names in order
+func (mc *modifierChain) names() []string {
+	mc.mu.RLock()
+	defer mc.mu.RUnlock()
+
+	names := make([]string, len(mc.modifiers))
+	for i, modifier := range mc.modifiers {
+		names[i] = modifier.Name
+	}
+	return names
+}
+
+// apply runs the modifiers in order, stopping at the first error
+func (mc *modifierChain) apply(resp *http.Response) error {
+	mc.mu.RLock()
+	modifiers := mc.modifiers
+	mc.mu.RUnlock()
+
+	for _, modifier := range modifiers {
+		if err := modifier.Modify(resp); err != nil {
+			return fmt.Errorf("response modifier %s: %w", modifier.Name, err)
+		}
+	}
+	return nil
+}
+
+// UseResponseModifier registers a response modifier under name. Modifiers run
+// in the order they were first registered; registering a name again replaces
+// that modifier in place. An error from a modifier stops the chain and the
+// client gets a 502.
+func (p *Proxy) UseResponseModifier(name string, modify func(*http.Response) error) {
+	p.modifiers.use(ResponseModifier{Name: name, Modify: modify})
+}
+
+// RemoveResponseModifier unregisters the response modifier registered under
+// name, reporting whether there was one
+func (p *Proxy) RemoveResponseModifier(name string) bool {
+	return p.modifiers.remove(name)
+}
+
+// ResponseModifiers returns the names of the registered response modifiers
+// in the order they run
+func (p *Proxy) ResponseModifiers() []string {
+	return p.modifiers.names()
+}
@@ -0,0 +1,70 @@
+package proxy
+
+import (
+	"net/http"
+	"strings"
+
+	"discobox/internal/types"
+)
+
+// pathMapping records how a request path was changed on its way to the
+// backend, so redirects from the backend can be mapped back to public paths
+type pathMapping struct {
+	stripped string // Prefix removed from the client's path
+	added    string // Prefix the backend is mounted under
+}
+
+// mapPath removes the route's strip prefix from the request path, if the path
+// starts with it, then prepends the route's add prefix. Rewrite rules have
+// already been applied.
+func mapPath(r *http.Request, route *types.Route, service *types.Service) pathMapping {
+	var mapping pathMapping
+
+	if prefix := route.StrippedPrefix(service); prefix != "" && strings.HasPrefix(r.URL.Path, prefix) {
+		r.URL.Path = strings.TrimPrefix(r.URL.Path, prefix)
+		if !strings.HasPrefix(r.URL.Path, "/") {
+			r.URL.Path = "/" + r.URL.Path
+		}
+		mapping.stripped = prefix
+	}
+
+	if route.AddPathPrefix != "" {
+		mapping.added = strings.TrimSuffix(route.AddPathPrefix, "/")
+		r.URL.Path = mapping.added + r.URL.Path
+		r.URL.RawPath = ""
+	}
+
+	return mapping
+}
+
+// ForwardedPrefixHeader tells backends which path prefix was stripped from
+// the public URL, so they can build absolute links
+const ForwardedPrefixHeader = "X-Forwarded-Prefix"
+
+// setForwardedPrefix sets X-Forwarded-Prefix to the stripped prefix, if
+// any. A value sent by the client is already gone.
+func setForwardedPrefix(req *http.Request, m pathMapping) {
+	if prefix := strings.TrimSuffix(m.stripped, "/"); prefix != "" {
+		req.Header.Set(ForwardedPrefixHeader, prefix)
+	}
+}
+
+// publicPath maps a backend path back to the path a client would use. Paths
+// outside the backend's mount point are returned unchanged.
+func (m pathMapping) publicPath(path string) string {
+	if m.added != "" {
+		rest, ok := strings.CutPrefix(path, m.added)
+		if !ok || (rest != "" && !strings.HasPrefix(rest, "/")) {
+			return path
+		}
+		path = rest
+		if path == "" {
+			path = "/"
+		}
+	}
+
+	if m.stripped == "" {
+		return path
+	}
+	return strings.TrimSuffix(m.stripped, "/") + path
+}
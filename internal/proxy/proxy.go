@@ -6,36 +6,80 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"net"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"net/http"
 	"net/http/httputil"
-	"net/url"
 	"sync/atomic"
 
+	"discobox/internal/metrics"
 	"discobox/internal/types"
 )
 
-// BufferPool adapts sync.Pool to httputil.BufferPool interface
+// DefaultBufferSize is the size of pooled copy buffers when Options.BufferSize is not set
+const DefaultBufferSize = 32 * 1024
+
+// BufferPool adapts sync.Pool to httputil.BufferPool interface. The pool
+// holds *[]byte rather than []byte so Put does not box a slice header into
+// an interface on every call (staticcheck SA6002).
 type BufferPool struct {
-	pool *sync.Pool
+	pool   sync.Pool
+	size   int
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+// NewBufferPool creates a pool of size-byte buffers
+func NewBufferPool(size int) *BufferPool {
+	if size <= 0 {
+		size = DefaultBufferSize
+	}
+	return &BufferPool{size: size}
 }
 
+// Get returns a pooled buffer, allocating one if the pool is empty
 func (bp *BufferPool) Get() []byte {
-	return bp.pool.Get().([]byte)
+	if b, ok := bp.pool.Get().(*[]byte); ok {
+		bp.hits.Add(1)
+		metrics.GlobalCollector.RecordBufferPoolGet(true)
+		return *b
+	}
+
+	bp.misses.Add(1)
+	metrics.GlobalCollector.RecordBufferPoolGet(false)
+	return make([]byte, bp.size)
 }
 
+// Put returns a buffer to the pool. Buffers of another size are dropped.
 func (bp *BufferPool) Put(b []byte) {
-	bp.pool.Put(b)
+	if cap(b) != bp.size {
+		return
+	}
+	b = b[:bp.size]
+	bp.pool.Put(&b)
+}
+
+// Size returns the size of the pooled buffers
+func (bp *BufferPool) Size() int {
+	return bp.size
+}
+
+// Stats returns how many Gets were served from the pool and how many allocated
+func (bp *BufferPool) Stats() (hits, misses uint64) {
+	return bp.hits.Load(), bp.misses.Load()
 }
 
 // Proxy is the main reverse proxy implementation
 type Proxy struct {
 	loadBalancer   types.LoadBalancer
 	healthChecker  types.HealthChecker
+	outliers       types.OutlierDetector
+	healthScorer   types.HealthScorer
 	circuitBreaker types.CircuitBreaker
 	router         types.Router
 	rewriter       types.URLRewriter
@@ -44,9 +88,77 @@ type Proxy struct {
 	storage        types.Storage
 	bufferPool     *BufferPool
 	errorHandler   func(http.ResponseWriter, *http.Request, error)
-	modifyResponse func(*http.Response) error
+
+	// modifiers run on backend responses in order
+	modifiers modifierChain
+
+	// Long-lived connection handling
+	exemptLongLived      bool
+	longLivedIdleTimeout time.Duration
+
+	// upgraded tracks hijacked connections so they can be closed on shutdown
+	upgraded upgradeTracker
+
+	// retryAfter is advertised to clients when no backend can take a
+	// request; zero leaves it out
+	retryAfter time.Duration
+
+	// maxDecompressedSize caps request bodies inflated for routes with the
+	// decompress-request middleware
+	maxDecompressedSize int64
+
+	// defaultServiceID serves requests no route matches (empty = 404). It
+	// is a string, replaced on reload while requests read it.
+	defaultServiceID atomic.Value
+
+	// forwardedPrefix sends stripped path prefixes to backends in X-Forwarded-Prefix
+	forwardedPrefix bool
+	// logSelection logs why each backend was chosen, for balancers that can say
+	logSelection bool
+	// errorFormat selects JSON or plain text error bodies
+	errorFormat string
+
+	// circuitBreakers guards each service with its own breaker; it takes
+	// precedence over circuitBreaker
+	circuitBreakers types.CircuitBreakerSet
+
+	// servers keeps each service's backends across requests
+	servers serverCache
+	// overrides holds backend health forced through the admin API
+	overrides healthOverrides
+	// inflight lets draining cut off requests to backends removed from
+	// their service
+	inflight inflightRequests
+	// drainTimeout bounds draining backends removed from their service (0 = wait)
+	drainTimeout time.Duration
+	// stopWatch stops watching storage for service changes
+	stopWatch context.CancelFunc
+
+	// serviceTransports caches transports for services with their own
+	// upstream TLS settings (*serviceTransport), keyed by service ID
+	serviceTransports sync.Map
 }
 
+// DefaultRouteID identifies requests served by the default service in
+// metrics and logs
+const DefaultRouteID = "_default"
+
+// StatusClientClosedRequest is recorded for requests the client abandoned
+// before the backend answered, following nginx's 499
+const StatusClientClosedRequest = 499
+
+// Error body formats for Options.ErrorFormat
+const (
+	// ErrorFormatAuto sends JSON to clients that prefer it in Accept, else text
+	ErrorFormatAuto = types.ErrorFormatAuto
+	ErrorFormatJSON = types.ErrorFormatJSON
+	ErrorFormatText = types.ErrorFormatText
+)
+
+
+// errBackendFailed reports a 5xx response to the circuit breaker
+var errBackendFailed = errors.New("backend returned a server error")
+
 // Options for creating a new proxy
 type Options struct {
 	LoadBalancer   types.LoadBalancer
@@ -58,7 +170,45 @@ type Options struct {
 	Logger         types.Logger
 	Storage        types.Storage
 	ErrorHandler   func(http.ResponseWriter, *http.Request, error)
+	// ModifyResponse is run on backend responses ahead of ResponseModifiers,
+	// registered as the "default" modifier
 	ModifyResponse func(*http.Response) error
+	// ResponseModifiers run on backend responses in order; the first error
+	// stops the chain
+	ResponseModifiers []ResponseModifier
+
+	// ExemptLongLived clears server read/write deadlines for upgraded and streaming requests
+	ExemptLongLived bool
+	// LongLivedIdleTimeout closes exempted upgraded connections after this much inactivity (0 = never)
+	LongLivedIdleTimeout time.Duration
+	// RetryAfter is sent in the Retry-After header when no backend of a service can take a request (0 = no header)
+	RetryAfter time.Duration
+	// BufferSize is the size of the pooled buffers used to copy bodies (default 32KB)
+	BufferSize int
+	// OutlierDetector ejects backends that keep failing from the pool (passive health)
+	OutlierDetector types.OutlierDetector
+	// HealthScorer scales backend weights by their recent error rate and
+	// latency, so struggling backends get less traffic before ejection
+	HealthScorer types.HealthScorer
+	// MaxDecompressedSize caps inflated request bodies (default 10MB)
+	MaxDecompressedSize int64
+	// DefaultServiceID serves requests that match no route instead of a 404
+	DefaultServiceID string
+	// ForwardedPrefix sets X-Forwarded-Prefix to the path prefix stripped before forwarding
+	ForwardedPrefix bool
+	// LogSelection logs at debug level why the load balancer chose each
+	// backend, if it implements types.SelectionExplainer
+	LogSelection bool
+	// ErrorFormat picks between {"error": "..."} JSON and plain text error
+	// bodies: ErrorFormatAuto (the default), ErrorFormatJSON or ErrorFormatText
+	ErrorFormat string
+	// CircuitBreakers gives each service its own breaker, built from the
+	// global settings with the service's circuit_breaker overrides. Takes
+	// precedence over CircuitBreaker.
+	CircuitBreakers types.CircuitBreakerSet
+	// DrainTimeout is how long backends removed from a service get to finish
+	// their requests, shared out least loaded first (0 = no limit)
+	DrainTimeout time.Duration
 }
 
 // New creates a new proxy instance
@@ -66,6 +216,8 @@ func New(opts Options) *Proxy {
 	p := &Proxy{
 		loadBalancer:   opts.LoadBalancer,
 		healthChecker:  opts.HealthChecker,
+		outliers:       opts.OutlierDetector,
+		healthScorer:   opts.HealthScorer,
 		circuitBreaker: opts.CircuitBreaker,
 		router:         opts.Router,
 		rewriter:       opts.Rewriter,
@@ -73,26 +225,48 @@ func New(opts Options) *Proxy {
 		logger:         opts.Logger,
 		storage:        opts.Storage,
 		errorHandler:   opts.ErrorHandler,
-		modifyResponse: opts.ModifyResponse,
-		bufferPool: &BufferPool{
-			pool: &sync.Pool{
-				New: func() any {
-					return make([]byte, 32*1024) // 32KB buffers
-				},
-			},
-		},
+
+		exemptLongLived:      opts.ExemptLongLived,
+		longLivedIdleTimeout: opts.LongLivedIdleTimeout,
+		retryAfter:           opts.RetryAfter,
+		bufferPool:           NewBufferPool(opts.BufferSize),
+		maxDecompressedSize:  opts.MaxDecompressedSize,
+		forwardedPrefix:      opts.ForwardedPrefix,
+		logSelection:         opts.LogSelection,
+		errorFormat:          opts.ErrorFormat,
+		circuitBreakers:      opts.CircuitBreakers,
+		drainTimeout:         opts.DrainTimeout,
 	}
 
+	p.defaultServiceID.Store(opts.DefaultServiceID)
+
 	if p.transport == nil {
 		p.transport = DefaultTransport()
 	}
 
+	if p.maxDecompressedSize <= 0 {
+		p.maxDecompressedSize = DefaultMaxDecompressedSize
+	}
+
+	if opts.ModifyResponse != nil {
+		p.UseResponseModifier(DefaultResponseModifier, opts.ModifyResponse)
+	}
+	for _, modifier := range opts.ResponseModifiers {
+		p.UseResponseModifier(modifier.Name, modifier.Modify)
+	}
+
 	if p.errorHandler == nil {
 		p.errorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
 			p.defaultErrorHandler(w, r, err, http.StatusBadGateway)
 		}
 	}
 
+	if p.storage != nil {
+		var ctx context.Context
+		ctx, p.stopWatch = context.WithCancel(context.Background())
+		go p.watchServices(ctx, p.storage.Watch(ctx))
+	}
+
 	return p
 }
 
@@ -106,26 +280,85 @@ func (p *Proxy) UpdateCircuitBreaker(cb types.CircuitBreaker) {
 	p.circuitBreaker = cb
 }
 
+// UpdateCircuitBreakers replaces the per-service circuit breakers at runtime
+func (p *Proxy) UpdateCircuitBreakers(set types.CircuitBreakerSet) {
+	p.circuitBreakers = set
+}
+
+// breakerFor returns the circuit breaker guarding service, if any, and
+// whether 5xx responses count as its failures
+func (p *Proxy) breakerFor(service *types.Service) (types.CircuitBreaker, bool) {
+	if set := p.circuitBreakers; set != nil {
+		return set.ForService(service), set.ServerErrors()
+	}
+	return p.circuitBreaker, false
+}
+
+// UpdateDefaultService sets the service for unmatched requests at runtime (empty = 404)
+func (p *Proxy) UpdateDefaultService(serviceID string) {
+	p.defaultServiceID.Store(serviceID)
+}
+
 // ServeHTTP handles incoming requests
 func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	startTime := time.Now()
 
-	// Find matching route
+	// Responses to HEAD keep the backend's headers but never carry a body,
+	// even when the backend or an error page writes one
+	if r.Method == http.MethodHead {
+		w = &headWriter{ResponseWriter: w}
+	}
+
+	// Find matching route, falling back to the default service
 	route, err := p.router.Match(r)
+	if defaultServiceID := p.defaultServiceID.Load().(string); errors.Is(err, types.ErrRouteNotFound) && defaultServiceID != "" {
+		route, err = &types.Route{ID: DefaultRouteID, ServiceID: defaultServiceID}, nil
+	}
 	if err != nil {
 		p.handleError(w, r, err, http.StatusNotFound)
 		return
 	}
+	trace := types.DebugTraceFromContext(r.Context())
+	trace.SetRoute(route.ID)
+
+	// Record per-route metrics once the request completes
+	sw := &statusWriter{ResponseWriter: w, statusCode: http.StatusOK}
+	w = sw
+	defer func() {
+		metrics.GlobalCollector.RecordRouteRequest(route.ID, sw.statusCode, time.Since(startTime))
+	}()
+
+	// The retry middleware marks the requests it re-sends
+	if types.RetryAttemptFromContext(r.Context()) > 0 {
+		metrics.GlobalCollector.RecordRouteRetry(route.ID)
+	}
+
+	// Inflate compressed request bodies for backends that can't
+	if route.HasMiddleware(types.MiddlewareDecompressRequest) {
+		if err := decompressRequest(r, p.maxDecompressedSize); err != nil {
+			p.handleError(w, r, err, http.StatusBadRequest)
+			return
+		}
+	}
 
-	// Get service
+	// Get service, which may be the route's canary
 	ctx := r.Context()
-	service, err := p.getService(ctx, route.ServiceID)
+	serviceID := routeServiceID(w, r, route)
+	service, err := p.getService(ctx, serviceID)
 	if err != nil {
+		if errors.Is(err, types.ErrServiceNotFound) {
+			metrics.GlobalCollector.RecordServiceUnavailable(serviceID, metrics.UnavailableServiceNotFound)
+			p.logger.Warn("route points at missing service",
+				"route_id", route.ID,
+				"service_id", serviceID,
+			)
+		}
 		p.handleError(w, r, err, http.StatusServiceUnavailable)
 		return
 	}
 
-	// Convert endpoints to servers
+	// Convert endpoints to servers, taking passively ejected backends out of
+	// the pool and shedding load from struggling ones
 	servers := p.endpointsToServers(service)
 	if len(servers) == 0 {
 		p.handleError(w, r, types.ErrNoHealthyBackends, http.StatusServiceUnavailable)
@@ -133,18 +366,41 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Select backend server
-	server, err := p.loadBalancer.Select(ctx, r, servers)
+	server, err := p.selectServer(ctx, r, route, servers)
 	if err != nil {
+		if errors.Is(err, types.ErrNoHealthyBackends) || errors.Is(err, types.ErrMaxConnectionsReached) {
+			// Every backend is down or full; tell clients when to come back.
+			// Balancers skipping full backends may report either error.
+			reason, msg := metrics.UnavailableAllUnhealthy, "all backends unhealthy"
+			if errors.Is(err, types.ErrMaxConnectionsReached) || anyHealthy(servers) {
+				reason, msg = metrics.UnavailableSaturated, "all backends at max connections"
+			}
+			metrics.GlobalCollector.RecordServiceUnavailable(service.ID, reason)
+			p.logger.Warn(msg,
+				"route_id", route.ID,
+				"service_id", service.ID,
+				"backends", len(servers),
+			)
+			p.setRetryAfter(w)
+		}
 		p.handleError(w, r, err, http.StatusServiceUnavailable)
 		return
 	}
+	trace.SetBackend(server.ID)
+	recordSelection(service.ID, server)
 
-	// Increment active connections
-	atomic.AddInt64(&server.ActiveConns, 1)
-	defer atomic.AddInt64(&server.ActiveConns, -1)
+	// Count the request against the shared backend, not the balancer's
+	// snapshot of it
+	shared := p.sharedServer(service.ID, server)
+	atomic.AddInt64(&shared.ActiveConns, 1)
+	defer atomic.AddInt64(&shared.ActiveConns, -1)
+
+	// Let draining cut the request off if the backend is removed
+	r, release := p.inflight.track(r, shared)
+	defer release()
 
 	// Update last used time
-	server.LastUsed = time.Now()
+	p.markUsed(shared)
 
 	// Apply URL rewriting
 	if p.rewriter != nil && len(route.RewriteRules) > 0 {
@@ -156,24 +412,61 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Strip prefix if configured
-	if service.StripPrefix && route.PathPrefix != "" {
-		r.URL.Path = strings.TrimPrefix(r.URL.Path, route.PathPrefix)
-		if !strings.HasPrefix(r.URL.Path, "/") {
-			r.URL.Path = "/" + r.URL.Path
+	// Strip and add path prefixes if configured
+	mapping := mapPath(r, route, service)
+
+	// Keep upgraded and streaming connections alive past server timeouts
+	if p.exemptLongLived && isLongLived(r) {
+		w = p.prepareLongLived(w, r)
+	}
+
+	// Track upgraded connections, which server shutdown doesn't wait for
+	if isUpgrade(r) {
+		if p.upgraded.isClosing() {
+			p.handleError(w, r, errUpgradesClosed, http.StatusServiceUnavailable)
+			return
 		}
+		w = p.trackUpgrade(w, r)
+	}
+
+	// Bound the upstream exchange by the service timeout, or the client's
+	// X-Request-Timeout if shorter. Long-lived requests are expected to stay
+	// open and are exempt.
+	if !isLongLived(r) {
+		var cancel context.CancelFunc
+		r, cancel = withDeadline(r, service)
+		defer cancel()
+	}
+
+	// Pick the transport matching the service's upstream TLS settings
+	transport, err := p.transportFor(service)
+	if err != nil {
+		p.logger.Error("invalid upstream TLS settings",
+			"service_id", service.ID,
+			"error", err,
+		)
+		p.handleError(w, r, err, http.StatusBadGateway)
+		return
 	}
 
+	// Let the client start on preloads while the backend responds
+	sendEarlyHints(w, r, route)
+
 	// Create reverse proxy for this request
-	proxy := p.createReverseProxy(server, service, route)
+	proxy := p.createReverseProxy(server, service, route, transport, mapping)
 
-	// Execute with circuit breaker if available
-	if p.circuitBreaker != nil {
-		err = p.circuitBreaker.Execute(func() error {
+	// Execute with circuit breaker if available. With circuit_breaker.server_errors
+	// on, server errors count as failures towards tripping it; they have
+	// already been sent.
+	if breaker, serverErrors := p.breakerFor(service); breaker != nil {
+		err = breaker.Execute(func() error {
 			proxy.ServeHTTP(w, r)
+			if serverErrors && sw.statusCode >= http.StatusInternalServerError {
+				return errBackendFailed
+			}
 			return nil
 		})
-		if err != nil {
+		if err != nil && !errors.Is(err, errBackendFailed) {
 			p.handleError(w, r, err, http.StatusServiceUnavailable)
 			return
 		}
@@ -192,12 +485,59 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 }
 
 // createReverseProxy creates a reverse proxy for a specific backend
-func (p *Proxy) createReverseProxy(server *types.Server, service *types.Service, route *types.Route) *httputil.ReverseProxy {
+func (p *Proxy) createReverseProxy(server *types.Server, service *types.Service, route *types.Route, transport http.RoundTripper, mapping pathMapping) *httputil.ReverseProxy {
+	// Set by the director just before the request goes upstream
+	var upstreamStart time.Time
+
 	// Create error handler that records failures
 	errorHandler := func(w http.ResponseWriter, r *http.Request, err error) {
-		if p.healthChecker != nil {
+		// Draining cut the request off; the backend may well be fine
+		if errors.Is(context.Cause(r.Context()), errBackendRemoved) {
+			p.errorHandler(w, r, errBackendRemoved)
+			return
+		}
+
+		// The backend answered, and was credited for it, but the route
+		// won't pass on a body that large
+		if errors.Is(err, types.ErrResponseTooLarge) {
+			p.errorHandler(w, r, err)
+			return
+		}
+
+		// Clients hanging up say nothing about the backend. The upstream
+		// request shares the client's context, so it has been canceled too.
+		if errors.Is(r.Context().Err(), context.Canceled) {
+			metrics.GlobalCollector.RecordRouteClientCanceled(route.ID)
+			p.logger.Debug("client canceled request",
+				"route_id", route.ID,
+				"server_id", server.ID,
+				"method", r.Method,
+				"path", r.URL.Path,
+			)
+			w.WriteHeader(StatusClientClosedRequest)
+			return
+		}
+
+		// A redirect loop is a routing problem, not a sign the backend is down
+		if p.healthChecker != nil && !errors.Is(err, types.ErrTooManyRedirects) {
 			p.healthChecker.RecordFailure(server.ID, err)
 		}
+		if p.outliers != nil && !errors.Is(err, types.ErrTooManyRedirects) && !errors.Is(err, context.Canceled) {
+			p.outliers.RecordFailure(server.ID, err)
+		}
+		if p.healthScorer != nil && !errors.Is(err, types.ErrTooManyRedirects) && !upstreamStart.IsZero() {
+			p.healthScorer.RecordResult(server.ID, true, time.Since(upstreamStart))
+		}
+		if observer, ok := p.loadBalancer.(types.ResultObserver); ok && !errors.Is(err, types.ErrTooManyRedirects) && !upstreamStart.IsZero() {
+			observer.ObserveResult(server.ID, true, time.Since(upstreamStart))
+		}
+		status := http.StatusBadGateway
+		if r.Context().Err() == context.DeadlineExceeded || isTransportTimeout(err) {
+			metrics.GlobalCollector.RecordRouteTimeout(route.ID)
+			err = fmt.Errorf("%w: %v", types.ErrTimeout, err)
+			status = http.StatusGatewayTimeout
+		}
+		metrics.GlobalCollector.RecordBackendResponse(service.ID, server.ID, server.Metadata[types.TagZone], status)
 		if p.errorHandler != nil {
 			p.errorHandler(w, r, err)
 		} else {
@@ -205,30 +545,104 @@ func (p *Proxy) createReverseProxy(server *types.Server, service *types.Service,
 		}
 	}
 
+	// The backend whose response is returned; differs from server when a
+	// hedged request wins
+	backend := server
+	hedged := false
+
 	// Create response modifier that records success
 	modifyResponse := func(resp *http.Response) error {
 		// Record success for 2xx and 3xx responses
 		if p.healthChecker != nil && resp.StatusCode < 400 {
-			p.healthChecker.RecordSuccess(server.ID)
+			p.healthChecker.RecordSuccess(backend.ID)
 		} else if p.healthChecker != nil && resp.StatusCode >= 500 {
 			// Record failure for 5xx responses
-			p.healthChecker.RecordFailure(server.ID, fmt.Errorf("backend returned %d", resp.StatusCode))
+			p.healthChecker.RecordFailure(backend.ID, fmt.Errorf("backend returned %d", resp.StatusCode))
+		}
+
+		// Only server errors count towards ejection; a 4xx still means the backend is up
+		if p.outliers != nil {
+			if resp.StatusCode >= 500 {
+				p.outliers.RecordFailure(backend.ID, fmt.Errorf("backend returned %d", resp.StatusCode))
+			} else {
+				p.outliers.RecordSuccess(backend.ID)
+			}
+		}
+
+		// Feed response times to latency-aware balancers. Fast 5xx responses
+		// would make a failing backend look attractive, so they are left out,
+		// as are hedge wins, which started after the delay.
+		if observer, ok := p.loadBalancer.(types.LatencyObserver); ok && resp.StatusCode < 500 && !hedged {
+			observer.ObserveLatency(server.ID, time.Since(upstreamStart))
+		}
+		if p.healthScorer != nil && !hedged {
+			p.healthScorer.RecordResult(server.ID, resp.StatusCode >= 500, time.Since(upstreamStart))
+		}
+		if observer, ok := p.loadBalancer.(types.ResultObserver); ok && !hedged {
+			observer.ObserveResult(server.ID, resp.StatusCode >= 500, time.Since(upstreamStart))
+		}
+		metrics.GlobalCollector.RecordBackendResponse(service.ID, backend.ID, backend.Metadata[types.TagZone], resp.StatusCode)
+		types.DebugTraceFromContext(resp.Request.Context()).SetBackend(backend.ID)
+
+		// Point backend redirects at the public host
+		if route.RedirectMode() == types.RedirectRewrite {
+			rewriteLocation(resp, service, mapping)
+		}
+
+		// Run the registered modifiers
+		if err := p.modifiers.apply(resp); err != nil {
+			return err
+		}
+
+		// Enforce the route's cap on the body, which modifiers may have replaced
+		return p.limitResponse(resp, route, backend)
+	}
+
+	// Follow redirects between the service's backends server-side
+	if route.RedirectMode() == types.RedirectFollow {
+		maxHops := route.Redirects.MaxHops
+		if maxHops <= 0 {
+			maxHops = types.DefaultMaxRedirects
+		}
+		transport = &redirectFollower{
+			proxy:   p,
+			next:    transport,
+			service: service,
+			route:   route,
+			maxHops: maxHops,
 		}
+	}
 
-		// Call the original modifier if present
-		if p.modifyResponse != nil {
-			return p.modifyResponse(resp)
+	// Race slow safe requests against a second backend
+	if delay := route.HedgeDelay(); delay > 0 {
+		transport = &hedgingTransport{
+			proxy:   p,
+			next:    transport,
+			service: service,
+			route:   route,
+			primary: server,
+			delay:   delay,
+			onHedgeWin: func(winner *types.Server) {
+				backend = winner
+				hedged = true
+			},
 		}
-		return nil
 	}
 
 	proxy := &httputil.ReverseProxy{
 		Director: func(req *http.Request) {
-			req.URL.Scheme = server.URL.Scheme
+			upstreamStart = time.Now()
+			req.URL.Scheme = backendScheme(server, route, service)
 			req.URL.Host = server.URL.Host
 
 			// Add forwarding headers
 			p.addForwardingHeaders(req)
+			if p.forwardedPrefix {
+				setForwardedPrefix(req, mapping)
+			}
+
+			// Tell the backend how long it has left
+			setRemainingTimeout(req)
 
 			// Add custom headers
 			for k, v := range server.Metadata {
@@ -237,7 +651,7 @@ func (p *Proxy) createReverseProxy(server *types.Server, service *types.Service,
 				}
 			}
 		},
-		Transport:      p.transport,
+		Transport:      transport,
 		ErrorHandler:   errorHandler,
 		ModifyResponse: modifyResponse,
 		BufferPool:     p.bufferPool,
@@ -246,22 +660,79 @@ func (p *Proxy) createReverseProxy(server *types.Server, service *types.Service,
 	return proxy
 }
 
-// addForwardingHeaders adds X-Forwarded-* headers
-func (p *Proxy) addForwardingHeaders(req *http.Request) {
-	// X-Forwarded-For
-	if clientIP, _, err := net.SplitHostPort(req.RemoteAddr); err == nil {
-		if prior := req.Header.Get("X-Forwarded-For"); prior != "" {
-			clientIP = prior + ", " + clientIP
-		}
-		req.Header.Set("X-Forwarded-For", clientIP)
+// statusWriter captures the response status for per-route metrics
+type statusWriter struct {
+	http.ResponseWriter
+	statusCode  int
+	wroteHeader bool
+}
+
+func (sw *statusWriter) WriteHeader(code int) {
+	// Informational responses other than an upgrade precede the real status
+	if !sw.wroteHeader && !types.IsInterimStatus(code) {
+		sw.statusCode = code
+		sw.wroteHeader = true
 	}
+	sw.ResponseWriter.WriteHeader(code)
+}
 
-	// X-Real-IP
-	if req.Header.Get("X-Real-IP") == "" {
-		if clientIP, _, err := net.SplitHostPort(req.RemoteAddr); err == nil {
-			req.Header.Set("X-Real-IP", clientIP)
-		}
+func (sw *statusWriter) Write(b []byte) (int, error) {
+	sw.wroteHeader = true
+	return sw.ResponseWriter.Write(b)
+}
+
+// Unwrap returns the underlying response writer for http.ResponseController
+func (sw *statusWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
+
+// headWriter discards the body of a response to a HEAD request
+type headWriter struct {
+	http.ResponseWriter
+}
+
+func (hw *headWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+// Unwrap returns the underlying response writer for http.ResponseController
+func (hw *headWriter) Unwrap() http.ResponseWriter {
+	return hw.ResponseWriter
+}
+
+// setForwardedFor rewrites X-Forwarded-For as a single normalized header
+// holding the request's forwarding chain. withPeer ends it with the
+// connecting peer; leave it off when the reverse proxy appends the peer.
+func setForwardedFor(req *http.Request, withPeer bool) {
+	chain := types.ForwardedFor(req)
+	if withPeer {
+		chain = types.ForwardingChain(req)
+	}
+
+	if len(chain) == 0 {
+		req.Header.Del("X-Forwarded-For")
+		return
 	}
+	req.Header.Set("X-Forwarded-For", strings.Join(chain, ", "))
+}
+
+// backendScheme returns the scheme requests to server use: the upstream
+// scheme forced by the route or service, otherwise the endpoint's own
+func backendScheme(server *types.Server, route *types.Route, service *types.Service) string {
+	if scheme := route.BackendScheme(service); scheme != "" {
+		return scheme
+	}
+	return server.URL.Scheme
+}
+
+// addForwardingHeaders adds X-Forwarded-* headers
+func (p *Proxy) addForwardingHeaders(req *http.Request) {
+	// X-Real-IP
+	req.Header.Set("X-Real-IP", types.ClientIP(req))
+
+	// X-Forwarded-For, normalized. The reverse proxy appends the peer itself
+	// once the director returns, so it's left off here.
+	setForwardedFor(req, false)
 
 	// X-Forwarded-Proto
 	if req.TLS != nil {
@@ -272,6 +743,9 @@ func (p *Proxy) addForwardingHeaders(req *http.Request) {
 
 	// X-Forwarded-Host
 	req.Header.Set("X-Forwarded-Host", req.Host)
+
+	// X-Forwarded-Prefix is only ever the proxy's own, set when enabled
+	req.Header.Del(ForwardedPrefixHeader)
 }
 
 // getService retrieves service from storage
@@ -292,33 +766,105 @@ func (p *Proxy) getService(ctx context.Context, serviceID string) (*types.Servic
 	return service, nil
 }
 
-// endpointsToServers converts service endpoints to server objects
-func (p *Proxy) endpointsToServers(service *types.Service) []*types.Server {
-	servers := make([]*types.Server, 0, len(service.Endpoints))
+// selectServer picks a backend with the load balancer, logging the reason for
+// the choice when selection logging is on and the balancer can explain it
+func (p *Proxy) selectServer(ctx context.Context, r *http.Request, route *types.Route, servers []*types.Server) (*types.Server, error) {
+	lb := p.loadBalancer
+	explainer, ok := lb.(types.SelectionExplainer)
+	if !p.logSelection || !ok {
+		return lb.Select(ctx, r, servers)
+	}
 
-	for i, endpoint := range service.Endpoints {
-		u, err := url.Parse(endpoint)
-		if err != nil {
-			p.logger.Error("invalid endpoint URL",
-				"endpoint", endpoint,
-				"error", err,
-			)
-			continue
+	server, reason, err := explainer.SelectWithReason(ctx, r, servers)
+	if reason == nil {
+		return server, err
+	}
+
+	selected := ""
+	if server != nil {
+		selected = server.ID
+	}
+	p.logger.Debug("backend selected",
+		"route_id", route.ID,
+		"server_id", selected,
+		"algorithm", reason.Algorithm,
+		"reason", reason.Reason,
+		"candidates", reason.Candidates,
+	)
+	return server, err
+}
+
+// markHealth marks backends forced down by an operator or ejected by the
+// outlier detector as unhealthy so the load balancer skips them. Backends
+// forced up stay in regardless. If every backend not forced down is ejected
+// none are, since a degraded backend is better than no backend.
+func (p *Proxy) markHealth(serviceID string, servers []*types.Server) {
+	healthy := make([]bool, len(servers))
+	forced := make([]bool, len(servers))
+	remaining := 0
+	for i, server := range servers {
+		healthy[i], forced[i] = p.overrides.get(serviceID, server.URL)
+		if !forced[i] {
+			healthy[i] = p.outliers == nil || !p.outliers.IsEjected(server.ID)
 		}
+		if healthy[i] {
+			remaining++
+		}
+	}
 
-		server := &types.Server{
-			ID:       fmt.Sprintf("%s-%d", service.ID, i),
-			URL:      u,
-			Weight:   service.Weight,
-			MaxConns: service.MaxConns,
-			Healthy:  true, // Should be determined by health checker
-			Metadata: service.Metadata,
+	// Servers persist across requests, so write only what changed and let
+	// readmitted backends back in
+	for i, server := range servers {
+		if !healthy[i] && !forced[i] && remaining == 0 {
+			healthy[i] = true
+		}
+		if server.Healthy != healthy[i] {
+			server.Healthy = healthy[i]
 		}
+	}
+}
 
-		servers = append(servers, server)
+// healthScoreScale multiplies backend weights before they are scaled by
+// health score, so a degraded backend's share can drop below a whole weight
+const healthScoreScale = 10
+
+// applyHealthScores scales backend weights by their health score so weighted
+// balancers send struggling backends proportionally less traffic. Weights are
+// left alone while every backend is healthy, and a degraded backend keeps a
+// weight of at least 1 so it still sees the traffic that shows it recovering.
+// Scaling starts from the service's weight, since servers persist across
+// requests.
+func (p *Proxy) applyHealthScores(servers []*types.Server, serviceWeight int) {
+	if p.healthScorer == nil {
+		return
 	}
 
-	return servers
+	scores := make([]float64, len(servers))
+	degraded := false
+	for i, server := range servers {
+		scores[i] = p.healthScorer.Score(server.ID)
+		if scores[i] < 1 {
+			degraded = true
+		}
+	}
+
+	for i, server := range servers {
+		scaled := serviceWeight
+		if degraded {
+			weight := serviceWeight
+			if weight <= 0 {
+				weight = 1
+			}
+
+			scaled = int(math.Round(float64(weight*healthScoreScale) * scores[i]))
+			if scaled < 1 {
+				scaled = 1
+			}
+		}
+		if server.Weight != scaled {
+			server.Weight = scaled
+		}
+	}
 }
 
 // handleError sends an error response
@@ -346,7 +892,7 @@ func (p *Proxy) defaultErrorHandler(w http.ResponseWriter, r *http.Request, err
 	switch {
 	case errors.Is(err, types.ErrRouteNotFound):
 		statusCode = http.StatusNotFound
-	case errors.Is(err, types.ErrNoHealthyBackends):
+	case errors.Is(err, types.ErrNoHealthyBackends), errors.Is(err, types.ErrMaxConnectionsReached):
 		statusCode = http.StatusServiceUnavailable
 	case errors.Is(err, types.ErrCircuitBreakerOpen):
 		statusCode = http.StatusServiceUnavailable
@@ -356,11 +902,60 @@ func (p *Proxy) defaultErrorHandler(w http.ResponseWriter, r *http.Request, err
 		statusCode = http.StatusGatewayTimeout
 	case errors.Is(err, types.ErrServiceNotFound):
 		statusCode = http.StatusServiceUnavailable
+	case errors.Is(err, types.ErrBodyTooLarge):
+		statusCode = http.StatusRequestEntityTooLarge
+	case errors.Is(err, types.ErrInvalidRequest):
+		statusCode = http.StatusBadRequest
+	case errors.Is(err, errUpgradesClosed):
+		statusCode = http.StatusServiceUnavailable
 	case strings.Contains(err.Error(), "is not active"):
 		statusCode = http.StatusServiceUnavailable
 	}
 
-	http.Error(w, err.Error(), statusCode)
+	// Let clients distinguish a temporary outage from other failures
+	if errors.Is(err, types.ErrNoHealthyBackends) || errors.Is(err, types.ErrMaxConnectionsReached) {
+		fields := map[string]any{"reason": err.Error()}
+		if seconds := p.setRetryAfter(w); seconds > 0 {
+			fields["retry_after"] = seconds
+		}
+		p.writeErrorFields(w, r, "Service temporarily unavailable", statusCode, fields)
+		return
+	}
+
+	p.writeError(w, r, err.Error(), statusCode)
+}
+
+// writeError sends msg as a {"error": msg} JSON body, matching the admin
+// API's error responses, or as plain text
+func (p *Proxy) writeError(w http.ResponseWriter, r *http.Request, msg string, statusCode int) {
+	p.writeErrorFields(w, r, msg, statusCode, nil)
+}
+
+// writeErrorFields is writeError with extra fields for the JSON body. Plain
+// text bodies carry only msg.
+func (p *Proxy) writeErrorFields(w http.ResponseWriter, r *http.Request, msg string, statusCode int, fields map[string]any) {
+	types.WriteError(w, r, p.errorFormat, msg, statusCode, fields)
+}
+
+// setRetryAfter sets the Retry-After header to the retry-after delay in
+// whole seconds, rounding up, and returns them. No delay means no header.
+func (p *Proxy) setRetryAfter(w http.ResponseWriter) int {
+	if p.retryAfter <= 0 {
+		return 0
+	}
+	seconds := int(math.Ceil(p.retryAfter.Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	return seconds
+}
+
+// anyHealthy reports whether any of servers is healthy
+func anyHealthy(servers []*types.Server) bool {
+	for _, server := range servers {
+		if server.Healthy {
+			return true
+		}
+	}
+	return false
 }
 
 // Option functions for builder pattern
@@ -380,6 +975,13 @@ func WithHealthChecker(hc types.HealthChecker) Option {
 	}
 }
 
+// WithOutlierDetector sets the outlier detector
+func WithOutlierDetector(od types.OutlierDetector) Option {
+	return func(o *Options) {
+		o.OutlierDetector = od
+	}
+}
+
 // WithCircuitBreaker sets the circuit breaker
 func WithCircuitBreaker(cb types.CircuitBreaker) Option {
 	return func(o *Options) {
@@ -408,6 +1010,27 @@ func WithTransport(t http.RoundTripper) Option {
 	}
 }
 
+// WithBufferSize sets the size of pooled copy buffers
+func WithBufferSize(size int) Option {
+	return func(o *Options) {
+		o.BufferSize = size
+	}
+}
+
+// WithMaxDecompressedSize caps request bodies inflated by the decompress-request middleware
+func WithMaxDecompressedSize(size int64) Option {
+	return func(o *Options) {
+		o.MaxDecompressedSize = size
+	}
+}
+
+// WithResponseModifier adds a named response modifier to the end of the chain
+func WithResponseModifier(name string, modify func(*http.Response) error) Option {
+	return func(o *Options) {
+		o.ResponseModifiers = append(o.ResponseModifiers, ResponseModifier{Name: name, Modify: modify})
+	}
+}
+
 // WithLogger sets the logger
 func WithLogger(l types.Logger) Option {
 	return func(o *Options) {
@@ -422,6 +1045,14 @@ func WithStorage(s types.Storage) Option {
 	}
 }
 
+// WithLongLivedTimeouts exempts upgraded and streaming connections from server timeouts
+func WithLongLivedTimeouts(idleTimeout time.Duration) Option {
+	return func(o *Options) {
+		o.ExemptLongLived = true
+		o.LongLivedIdleTimeout = idleTimeout
+	}
+}
+
 // NewWithOptions creates a proxy with option functions
 func NewWithOptions(opts ...Option) *Proxy {
 	options := &Options{}
@@ -431,6 +1062,11 @@ func NewWithOptions(opts ...Option) *Proxy {
 	return New(*options)
 }
 
+// BufferPool returns the pool of copy buffers
+func (p *Proxy) BufferPool() *BufferPool {
+	return p.bufferPool
+}
+
 // CopyBuffer copies from src to dst using a buffer from the pool
 func (p *Proxy) CopyBuffer(dst io.Writer, src io.Reader) (int64, error) {
 	buf := p.bufferPool.Get()
@@ -438,3 +1074,11 @@ func (p *Proxy) CopyBuffer(dst io.Writer, src io.Reader) (int64, error) {
 
 	return io.CopyBuffer(dst, src, buf)
 }
+
+// isTransportTimeout reports whether a round trip failed because the
+// transport gave up waiting, such as a backend that never sent its response
+// headers within the configured response_header_timeout
+func isTransportTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
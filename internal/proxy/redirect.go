@@ -0,0 +1,194 @@
+package proxy
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"discobox/internal/types"
+)
+
+// maxRedirectBodySize bounds the request body buffered so it can be resent
+// after a 307 or 308. Larger requests are not followed.
+const maxRedirectBodySize = 1 << 20
+
+// isRedirect reports whether a status code is a redirect with a Location
+func isRedirect(code int) bool {
+	switch code {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
+
+// isServiceBackend reports whether u points at one of the service's endpoints
+func isServiceBackend(u *url.URL, service *types.Service) bool {
+	for _, endpoint := range service.Endpoints {
+		if eu, err := url.Parse(endpoint); err == nil && strings.EqualFold(eu.Host, u.Host) {
+			return true
+		}
+	}
+	return false
+}
+
+// rewriteLocation points a redirect to one of the service's backends at the
+// public host the client used, restoring any prefix stripped on the way in.
+// Redirects to other hosts are left alone.
+func rewriteLocation(resp *http.Response, service *types.Service, mapping pathMapping) {
+	if !isRedirect(resp.StatusCode) || resp.Request == nil {
+		return
+	}
+
+	location := resp.Header.Get("Location")
+	if location == "" {
+		return
+	}
+
+	loc, err := url.Parse(location)
+	if err != nil {
+		return
+	}
+
+	target := resp.Request.URL.ResolveReference(loc)
+	if !isServiceBackend(target, service) {
+		return
+	}
+
+	if public := mapping.publicPath(target.Path); public != target.Path {
+		target.Path = public
+		target.RawPath = ""
+	}
+
+	if loc.Host == "" {
+		// Keep relative redirects relative
+		target.Scheme = ""
+		target.Host = ""
+	} else {
+		target.Scheme = resp.Request.Header.Get("X-Forwarded-Proto")
+		if target.Scheme == "" {
+			target.Scheme = "http"
+		}
+		target.Host = resp.Request.Host
+	}
+
+	resp.Header.Set("Location", target.String())
+}
+
+// redirectFollower is a transport that follows redirects to a service's own
+// backends server-side, choosing a backend afresh for every hop, so the client
+// only sees the final response
+type redirectFollower struct {
+	proxy   *Proxy
+	next    http.RoundTripper
+	service *types.Service
+	route   *types.Route
+	maxHops int
+}
+
+// RoundTrip sends the request and follows redirects that stay within the service
+func (rf *redirectFollower) RoundTrip(req *http.Request) (*http.Response, error) {
+	// The client is waiting on the backend before sending the body, so it
+	// can't be buffered for replay; send it once
+	if strings.EqualFold(req.Header.Get("Expect"), "100-continue") {
+		return rf.next.RoundTrip(req)
+	}
+
+	// Keep the body so it can be resent for 307 and 308
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		body, err = io.ReadAll(io.LimitReader(req.Body, maxRedirectBodySize+1))
+		if err != nil {
+			return nil, err
+		}
+		if len(body) > maxRedirectBodySize {
+			// Too large to replay; send it once and return whatever comes back
+			req.Body = readCloser{io.MultiReader(bytes.NewReader(body), req.Body), req.Body}
+			return rf.next.RoundTrip(req)
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	visited := map[string]bool{req.Method + " " + req.URL.RequestURI(): true}
+	current := req
+
+	for hops := 0; ; hops++ {
+		resp, err := rf.next.RoundTrip(current)
+		if err != nil {
+			return nil, err
+		}
+
+		if !isRedirect(resp.StatusCode) {
+			return resp, nil
+		}
+
+		location := resp.Header.Get("Location")
+		if location == "" {
+			return resp, nil
+		}
+
+		target, err := current.URL.Parse(location)
+		if err != nil || !isServiceBackend(target, rf.service) {
+			// Redirects elsewhere are the client's to follow
+			return resp, nil
+		}
+
+		// 301, 302 and 303 turn into a GET without a body, as browsers do;
+		// 307 and 308 must repeat the method and body unchanged
+		method, hopBody := current.Method, body
+		if resp.StatusCode != http.StatusTemporaryRedirect && resp.StatusCode != http.StatusPermanentRedirect {
+			if method != http.MethodGet && method != http.MethodHead {
+				method = http.MethodGet
+			}
+			hopBody = nil
+		}
+
+		io.Copy(io.Discard, io.LimitReader(resp.Body, maxRedirectBodySize))
+		resp.Body.Close()
+
+		if hops >= rf.maxHops {
+			return nil, fmt.Errorf("%w: stopped after %d redirects", types.ErrTooManyRedirects, hops)
+		}
+
+		key := method + " " + target.RequestURI()
+		if visited[key] {
+			return nil, fmt.Errorf("%w: redirect loop at %s", types.ErrTooManyRedirects, target.RequestURI())
+		}
+		visited[key] = true
+
+		// The redirect may name a specific backend; pick one afresh so an
+		// unhealthy or overloaded instance isn't pinned
+		server, err := rf.proxy.loadBalancer.Select(current.Context(), current, rf.proxy.endpointsToServers(rf.service))
+		if err != nil {
+			return nil, err
+		}
+		recordSelection(rf.service.ID, server)
+		target.Scheme = backendScheme(server, rf.route, rf.service)
+		target.Host = server.URL.Host
+
+		next := current.Clone(current.Context())
+		next.Method = method
+		next.URL = target
+		if hopBody != nil {
+			next.Body = io.NopCloser(bytes.NewReader(hopBody))
+			next.ContentLength = int64(len(hopBody))
+		} else {
+			next.Body = nil
+			next.ContentLength = 0
+			next.Header.Del("Content-Type")
+			next.Header.Del("Content-Length")
+		}
+
+		current = next
+	}
+}
+
+// readCloser pairs a reader with the closer of the body it was built from
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
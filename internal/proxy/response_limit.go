@@ -0,0 +1,85 @@
+package proxy
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	"discobox/internal/metrics"
+	"discobox/internal/types"
+)
+
+// limitResponse caps the body of a response at the route's limit. A body the
+// backend declares larger fails the request before anything is sent to the
+// client; one that streams past the limit is cut off there. Upgraded
+// connections aren't limited.
+func (p *Proxy) limitResponse(resp *http.Response, route *types.Route, server *types.Server) error {
+	limit := route.MaxResponseBytes
+	if limit <= 0 || resp.StatusCode == http.StatusSwitchingProtocols || resp.Body == nil || resp.Body == http.NoBody {
+		return nil
+	}
+
+	if resp.ContentLength > limit {
+		p.logger.Warn("response body exceeds route limit",
+			"route_id", route.ID,
+			"server_id", server.ID,
+			"limit", limit,
+			"content_length", resp.ContentLength,
+			"path", resp.Request.URL.Path,
+		)
+		return fmt.Errorf("%w: backend sent %d bytes, limit is %d", types.ErrResponseTooLarge, resp.ContentLength, limit)
+	}
+
+	// The failed copy aborts the handler with a panic that skips the access
+	// log, so the truncation is recorded here with the request's details
+	resp.Body = &limitedBody{
+		ReadCloser: resp.Body,
+		remaining:  limit,
+		onExceed: func() {
+			metrics.GlobalCollector.RecordRouteResponseTruncated(route.ID)
+			p.logger.Warn("response body truncated at route limit",
+				"route_id", route.ID,
+				"server_id", server.ID,
+				"limit", limit,
+				"method", resp.Request.Method,
+				"path", resp.Request.URL.Path,
+				"status", resp.StatusCode,
+				"client_ip", types.ClientIP(resp.Request),
+			)
+		},
+	}
+	return nil
+}
+
+// limitedBody reads a response body up to a limit. The proxy's pooled copy
+// buffers read through it, so a body that runs past the limit fails the
+// copy after exactly limit bytes and the client sees it cut short. onExceed
+// runs before the error is returned.
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+	onExceed  func()
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining <= 0 {
+		// A body exactly at the limit ends here; anything more exceeds it
+		var probe [1]byte
+		n, err := b.ReadCloser.Read(probe[:])
+		if n > 0 {
+			if b.onExceed != nil {
+				b.onExceed()
+				b.onExceed = nil
+			}
+			return 0, types.ErrResponseTooLarge
+		}
+		return 0, err
+	}
+
+	if int64(len(p)) > b.remaining {
+		p = p[:b.remaining]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.remaining -= int64(n)
+	return n, err
+}
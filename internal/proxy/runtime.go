@@ -0,0 +1,93 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	"discobox/internal/types"
+)
+
+// healthStatusReporter is implemented by health checkers that expose
+// per-server details
+type healthStatusReporter interface {
+	GetHealthStatus(serverID string) map[string]any
+}
+
+// RouteCount returns how many routes the router has loaded
+func (p *Proxy) RouteCount() int {
+	if p.router == nil {
+		return 0
+	}
+	routes, err := p.router.GetRoutes()
+	if err != nil {
+		return 0
+	}
+	return len(routes)
+}
+
+// RuntimeStats returns a snapshot of the proxy's view of its backends,
+// circuit breakers and routes. It only reads in-memory state plus the
+// service list, leaving the backends requests use untouched, so it is cheap
+// enough to call on demand.
+func (p *Proxy) RuntimeStats(ctx context.Context) (*types.RuntimeStats, error) {
+	stats := &types.RuntimeStats{
+		CircuitBreakers: make(map[string]string),
+		Backends:        make([]types.BackendStats, 0),
+	}
+
+	stats.Routes = p.RouteCount()
+
+	if breaker := p.circuitBreaker; breaker != nil {
+		stats.CircuitBreakers["global"] = breaker.State()
+	}
+	if set := p.circuitBreakers; set != nil {
+		for serviceID, state := range set.GetAllStates() {
+			stats.CircuitBreakers["service:"+serviceID] = state
+		}
+	}
+
+	if p.storage == nil {
+		return stats, nil
+	}
+
+	services, err := p.storage.ListServices(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list services: %w", err)
+	}
+
+	reporter, _ := p.healthChecker.(healthStatusReporter)
+
+	for _, service := range services {
+		for _, server := range p.cachedServers(service) {
+			backend := types.BackendStats{
+				ID:          server.ID,
+				ServiceID:   service.ID,
+				URL:         server.URL.String(),
+				Healthy:     true,
+				ActiveConns: atomic.LoadInt64(&server.ActiveConns),
+			}
+
+			if reporter != nil {
+				backend.Health = reporter.GetHealthStatus(server.ID)
+				if healthy, ok := backend.Health["healthy"].(bool); ok {
+					backend.Healthy = healthy
+				}
+			}
+
+			if p.outliers != nil && p.outliers.IsEjected(server.ID) {
+				backend.Ejected = true
+				backend.Healthy = false
+			}
+
+			if healthy, ok := p.overrides.get(service.ID, server.URL); ok {
+				backend.Healthy = healthy
+				backend.HealthOverride = &healthy
+			}
+
+			stats.Backends = append(stats.Backends, backend)
+		}
+	}
+
+	return stats, nil
+}
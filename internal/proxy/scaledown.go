@@ -0,0 +1,197 @@
+package proxy
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"discobox/internal/types"
+)
+
+// drainPollInterval is how often draining checks a backend's requests
+const drainPollInterval = 10 * time.Millisecond
+
+// errBackendRemoved cuts off requests to a backend removed from its service
+// that were still running at its drain deadline
+var errBackendRemoved = errors.New("backend removed from service")
+
+// DrainResult reports how a backend removed from its service was drained
+type DrainResult struct {
+	ServerID    string
+	URL         string
+	ActiveConns int64     // Requests in flight when draining started
+	Deadline    time.Time // When requests still in flight are cut off; zero waits for them
+	CutOff      int64     // Requests cut off at the deadline
+}
+
+// inflightRequests tracks the requests in progress to each backend, so
+// draining can cut off those still running at the backend's deadline
+type inflightRequests struct {
+	mu       sync.Mutex
+	backends map[*types.Server]*backendRequests
+}
+
+// backendRequests is shared by the requests in progress to one backend
+type backendRequests struct {
+	ctx    context.Context // Canceled to cut the requests off
+	cancel context.CancelFunc
+	count  int
+}
+
+// track ties r to server until release is called, so cutting the backend
+// off cancels it
+func (t *inflightRequests) track(r *http.Request, server *types.Server) (*http.Request, func()) {
+	t.mu.Lock()
+	if t.backends == nil {
+		t.backends = make(map[*types.Server]*backendRequests)
+	}
+	backend, ok := t.backends[server]
+	if !ok {
+		backend = &backendRequests{}
+		backend.ctx, backend.cancel = context.WithCancel(context.Background())
+		t.backends[server] = backend
+	}
+	backend.count++
+	t.mu.Unlock()
+
+	ctx, cancel := context.WithCancelCause(r.Context())
+	stop := context.AfterFunc(backend.ctx, func() { cancel(errBackendRemoved) })
+
+	release := func() {
+		stop()
+		cancel(nil)
+
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		backend.count--
+		if backend.count == 0 && t.backends[server] == backend {
+			delete(t.backends, server)
+			backend.cancel()
+		}
+	}
+	return r.WithContext(ctx), release
+}
+
+// cutOff cancels the requests in progress to server
+func (t *inflightRequests) cutOff(server *types.Server) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if backend, ok := t.backends[server]; ok {
+		delete(t.backends, server)
+		backend.cancel()
+	}
+}
+
+// DrainAll takes every backend of a service out of rotation and drains them,
+// least loaded first, returning them in the order drained. Each backend gets
+// a staggered share of timeout to finish its requests: with n backends, the
+// i-th is cut off at i/n of the timeout, so the busiest gets all of it. A
+// timeout of 0 waits for requests however long they take. Backends are
+// forgotten before draining; if the service is still in storage, its next
+// request builds new ones.
+func (p *Proxy) DrainAll(serviceID string, timeout time.Duration) []DrainResult {
+	p.servers.mu.Lock()
+	var servers []*types.Server
+	if cached, ok := p.servers.services[serviceID]; ok {
+		servers = cached.servers
+		delete(p.servers.services, serviceID)
+	}
+	p.servers.mu.Unlock()
+
+	return p.drainServers(serviceID, servers, timeout)
+}
+
+// removedServers returns the cached backends of a service whose endpoints
+// updated no longer lists. The caller holds p.servers.mu.
+func (p *Proxy) removedServers(updated *types.Service) []*types.Server {
+	cached, ok := p.servers.services[updated.ID]
+	if !ok {
+		return nil
+	}
+
+	var removed []*types.Server
+	for _, server := range cached.servers {
+		if !slices.Contains(updated.Endpoints, server.URL.String()) {
+			removed = append(removed, server)
+		}
+	}
+	return removed
+}
+
+// drainServers waits for backends taken out of rotation to finish their
+// requests, ordered and cut off as described for DrainAll
+func (p *Proxy) drainServers(serviceID string, servers []*types.Server, timeout time.Duration) []DrainResult {
+	if len(servers) == 0 {
+		return nil
+	}
+
+	// Least loaded first, by the load when draining starts
+	type drain struct {
+		server *types.Server
+		result DrainResult
+	}
+	drains := make([]drain, len(servers))
+	for i, server := range servers {
+		drains[i] = drain{server: server, result: DrainResult{
+			ServerID:    server.ID,
+			URL:         server.URL.String(),
+			ActiveConns: atomic.LoadInt64(&server.ActiveConns),
+		}}
+	}
+	slices.SortStableFunc(drains, func(a, b drain) int {
+		return cmp.Compare(a.result.ActiveConns, b.result.ActiveConns)
+	})
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := range drains {
+		d := &drains[i]
+		if timeout > 0 {
+			d.result.Deadline = start.Add(timeout * time.Duration(i+1) / time.Duration(len(drains)))
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.result.CutOff = p.drainServer(d.server, d.result.Deadline)
+		}()
+	}
+	wg.Wait()
+
+	results := make([]DrainResult, len(drains))
+	for i, d := range drains {
+		results[i] = d.result
+		p.logger.Info("backend drained",
+			"service_id", serviceID,
+			"server_id", d.result.ServerID,
+			"active_conns", d.result.ActiveConns,
+			"cut_off", d.result.CutOff,
+		)
+	}
+	return results
+}
+
+// drainServer waits for server's requests to finish, cutting off any still
+// running at deadline, and returns how many were cut off
+func (p *Proxy) drainServer(server *types.Server, deadline time.Time) int64 {
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+
+	for {
+		remaining := atomic.LoadInt64(&server.ActiveConns)
+		if remaining == 0 {
+			return 0
+		}
+		if !deadline.IsZero() && !time.Now().Before(deadline) {
+			p.inflight.cutOff(server)
+			return remaining
+		}
+		<-ticker.C
+	}
+}
@@ -0,0 +1,268 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"discobox/internal/metrics"
+	"discobox/internal/types"
+)
+
+// serverCache keeps the backends built for each service across requests, so
+// their connection counts, last use and health persist and connection-aware
+// balancers see real concurrency
+type serverCache struct {
+	mu       sync.Mutex
+	services map[string]*serviceServers // By service ID
+}
+
+// serviceServers are the backends built for one service
+type serviceServers struct {
+	spec    serverSpec
+	version int64 // Storage version of the service the spec was taken from
+	servers []*types.Server
+	stale   bool // The service changed in storage since these were built
+}
+
+// serverSpec holds the service settings its backends are built from; the
+// backends are rebuilt when any of them change
+type serverSpec struct {
+	endpoints    []string
+	weight       int
+	maxConns     int
+	metadata     map[string]string
+	endpointTags map[string]map[string]string
+	healthCheck  *types.HealthCheckConfig
+}
+
+func specOf(service *types.Service) serverSpec {
+	return serverSpec{
+		endpoints:    service.Endpoints,
+		weight:       service.Weight,
+		maxConns:     service.MaxConns,
+		metadata:     service.Metadata,
+		endpointTags: service.EndpointTags,
+		healthCheck:  service.HealthCheck,
+	}
+}
+
+// endpointsToServers returns snapshots of the backends for a service's
+// endpoints, with passively ejected and manually downed ones marked unhealthy
+// and weights scaled by health score. The cached backends are updated under
+// p.servers.mu while other requests are balancing, so callers get copies;
+// counting a request against a backend goes through sharedServer.
+func (p *Proxy) endpointsToServers(service *types.Service) []*types.Server {
+	p.servers.mu.Lock()
+	defer p.servers.mu.Unlock()
+
+	if p.servers.services == nil {
+		p.servers.services = make(map[string]*serviceServers)
+	}
+
+	cached, exists := p.servers.services[service.ID]
+	if !exists || cached.stale || !cached.builtFrom(service) {
+		cached = p.buildServers(service, specOf(service), cached)
+		p.servers.services[service.ID] = cached
+	}
+
+	p.markHealth(service.ID, cached.servers)
+	p.applyHealthScores(cached.servers, cached.spec.weight)
+
+	servers := make([]*types.Server, len(cached.servers))
+	for i, server := range cached.servers {
+		servers[i] = snapshotServer(server)
+	}
+	return servers
+}
+
+// snapshotServer copies a cached backend. The caller holds p.servers.mu;
+// ActiveConns changes outside it, so it is loaded atomically.
+func snapshotServer(server *types.Server) *types.Server {
+	return &types.Server{
+		URL:         server.URL,
+		ID:          server.ID,
+		Index:       server.Index,
+		Weight:      server.Weight,
+		MaxConns:    server.MaxConns,
+		ActiveConns: atomic.LoadInt64(&server.ActiveConns),
+		Healthy:     server.Healthy,
+		Metadata:    server.Metadata,
+		HealthCheck: server.HealthCheck,
+		LastUsed:    server.LastUsed,
+	}
+}
+
+// cachedServers returns snapshots of the backends built for a service,
+// leaving the cache as it is. A service no request has used yet gets
+// backends built for the snapshot alone.
+func (p *Proxy) cachedServers(service *types.Service) []*types.Server {
+	p.servers.mu.Lock()
+	defer p.servers.mu.Unlock()
+
+	cached, exists := p.servers.services[service.ID]
+	if !exists {
+		return p.buildServers(service, specOf(service), nil).servers
+	}
+
+	servers := make([]*types.Server, len(cached.servers))
+	for i, server := range cached.servers {
+		servers[i] = snapshotServer(server)
+	}
+	return servers
+}
+
+// sharedServer returns the cached backend a snapshot was taken from, whose
+// connection count and draining state persist across requests. If the
+// backend has since been replaced the snapshot itself is returned, so the
+// request is still counted while it runs.
+func (p *Proxy) sharedServer(serviceID string, snapshot *types.Server) *types.Server {
+	p.servers.mu.Lock()
+	defer p.servers.mu.Unlock()
+
+	if cached, ok := p.servers.services[serviceID]; ok {
+		for _, server := range cached.servers {
+			if server.ID == snapshot.ID && server.URL.String() == snapshot.URL.String() {
+				return server
+			}
+		}
+	}
+	return snapshot
+}
+
+// builtFrom reports whether the backends were built from service as it is
+// now. Storage bumps a service's version on every update, so an unchanged
+// version means an unchanged service; services without one are compared.
+func (cached *serviceServers) builtFrom(service *types.Service) bool {
+	if service.Version != 0 && cached.version == service.Version {
+		return true
+	}
+	return reflect.DeepEqual(cached.spec, specOf(service))
+}
+
+// buildServers creates the backends for a service. A backend that keeps its
+// endpoint and position from previous is reused, so requests in flight to it
+// stay counted.
+func (p *Proxy) buildServers(service *types.Service, spec serverSpec, previous *serviceServers) *serviceServers {
+	reusable := make(map[string]*types.Server)
+	if previous != nil {
+		for _, server := range previous.servers {
+			reusable[server.ID] = server
+		}
+	}
+
+	built := &serviceServers{spec: spec, version: service.Version, servers: make([]*types.Server, 0, len(service.Endpoints))}
+
+	for i, endpoint := range service.Endpoints {
+		u, err := url.Parse(endpoint)
+		if err != nil {
+			p.logger.Error("invalid endpoint URL",
+				"endpoint", endpoint,
+				"error", err,
+			)
+			continue
+		}
+
+		id := serverID(service.ID, i)
+		server, ok := reusable[id]
+		if !ok || server.URL.String() != u.String() {
+			server = &types.Server{
+				ID:      id,
+				Index:   i,
+				URL:     u,
+				Healthy: true, // Should be determined by health checker
+			}
+		}
+		server.Weight = service.Weight
+		server.MaxConns = service.MaxConns
+		server.Metadata = service.EndpointMetadata(endpoint)
+		server.HealthCheck = service.HealthCheck
+
+		built.servers = append(built.servers, server)
+	}
+
+	return built
+}
+
+// serverID names the backend for a service's endpoint at index
+func serverID(serviceID string, index int) string {
+	return fmt.Sprintf("%s-%d", serviceID, index)
+}
+
+// recordSelection counts the load balancer picking server for a service, by
+// the server's endpoint index
+func recordSelection(serviceID string, server *types.Server) {
+	metrics.GlobalCollector.RecordBackendSelection(serviceID, server.Index)
+}
+
+// markUsed records that a backend was just picked
+func (p *Proxy) markUsed(server *types.Server) {
+	p.servers.mu.Lock()
+	server.LastUsed = time.Now()
+	p.servers.mu.Unlock()
+}
+
+// invalidateService has the next request rebuild a changed service's
+// backends and forgets those of a deleted one, along with its transport and
+// selection counts. Backends whose endpoints are gone are drained in the
+// background.
+func (p *Proxy) invalidateService(event types.StorageEvent) {
+	p.servers.mu.Lock()
+	defer p.servers.mu.Unlock()
+
+	cached, exists := p.servers.services[event.ID]
+	if event.Type == "deleted" {
+		delete(p.servers.services, event.ID)
+		p.overrides.clearService(event.ID)
+		if set := p.circuitBreakers; set != nil {
+			set.RemoveBreaker(event.ID)
+		}
+		p.dropServiceTransport(event.ID)
+		metrics.GlobalCollector.DeleteBackendSelections(event.ID)
+		if exists {
+			go p.drainServers(event.ID, cached.servers, p.drainTimeout)
+		}
+		return
+	}
+	if !exists {
+		return
+	}
+	cached.stale = true
+
+	if updated, ok := event.Object.(*types.Service); ok {
+		p.overrides.retain(event.ID, updated.Endpoints)
+		if removed := p.removedServers(updated); len(removed) > 0 {
+			go p.drainServers(event.ID, removed, p.drainTimeout)
+		}
+	}
+}
+
+// watchServices invalidates cached backends as their services change in
+// storage, until ctx is cancelled
+func (p *Proxy) watchServices(ctx context.Context, events <-chan types.StorageEvent) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if event.Kind != "service" {
+				continue
+			}
+			p.invalidateService(event)
+		}
+	}
+}
+
+// Stop stops watching storage for service changes
+func (p *Proxy) Stop() {
+	if p.stopWatch != nil {
+		p.stopWatch()
+	}
+}
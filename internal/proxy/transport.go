@@ -1,10 +1,12 @@
 package proxy
 
 import (
+	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"fmt"
 	"os"
+	"reflect"
 	
 	"discobox/internal/types"
 	"net"
@@ -33,14 +35,17 @@ func DefaultTransport() http.RoundTripper {
 	}
 }
 
-// NewTransport creates a new transport with the given configuration
-func NewTransport(config types.ProxyConfig) http.RoundTripper {
+// NewTransport creates a new transport with the given configuration. Backend
+// hosts are resolved through resolver when it isn't nil.
+func NewTransport(config types.ProxyConfig, resolver *Resolver) http.RoundTripper {
+	responseHeaderTimeout := config.Transport.ResponseHeaderTimeout
+	if responseHeaderTimeout == 0 {
+		responseHeaderTimeout = 30 * time.Second
+	}
+
 	transport := &http.Transport{
 		Proxy: http.ProxyFromEnvironment,
-		DialContext: (&net.Dialer{
-			Timeout:   config.Transport.DialTimeout,
-			KeepAlive: config.Transport.KeepAlive,
-		}).DialContext,
+		DialContext:           dialContext(config, resolver),
 		ForceAttemptHTTP2:     config.HTTP2.Enabled,
 		MaxIdleConns:          config.Transport.MaxIdleConns,
 		MaxIdleConnsPerHost:   config.Transport.MaxIdleConnsPerHost,
@@ -48,7 +53,7 @@ func NewTransport(config types.ProxyConfig) http.RoundTripper {
 		IdleConnTimeout:       config.Transport.IdleConnTimeout,
 		TLSHandshakeTimeout:   10 * time.Second,
 		ExpectContinueTimeout: 1 * time.Second,
-		ResponseHeaderTimeout: 30 * time.Second,
+		ResponseHeaderTimeout: responseHeaderTimeout,
 		DisableCompression:    config.Transport.DisableCompression,
 	}
 
@@ -70,6 +75,19 @@ func NewTransport(config types.ProxyConfig) http.RoundTripper {
 	return transport
 }
 
+// dialContext returns the transport's dial function, resolving hosts
+// through resolver when one is given
+func dialContext(config types.ProxyConfig, resolver *Resolver) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	dialer := &net.Dialer{
+		Timeout:   config.Transport.DialTimeout,
+		KeepAlive: config.Transport.KeepAlive,
+	}
+	if resolver == nil {
+		return dialer.DialContext
+	}
+	return resolver.DialContext(dialer)
+}
+
 // NewBackendTransport creates a transport for connecting to backend services
 func NewBackendTransport(service *types.Service, config types.ProxyConfig) (http.RoundTripper, error) {
 	transport := &http.Transport{
@@ -91,50 +109,115 @@ func NewBackendTransport(service *types.Service, config types.ProxyConfig) (http
 
 	// Configure backend TLS
 	if service.TLS != nil {
-		tlsConfig := &tls.Config{
-			InsecureSkipVerify: service.TLS.InsecureSkipVerify,
-			ServerName:         service.TLS.ServerName,
+		tlsConfig, err := backendTLSConfig(service.TLS)
+		if err != nil {
+			return nil, err
 		}
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	return transport, nil
+}
 
-		// Add root CAs if provided
-		if len(service.TLS.RootCAs) > 0 {
-			rootCAs := x509.NewCertPool()
-			for _, ca := range service.TLS.RootCAs {
-				// Try to load as file first
-				if pemData, err := os.ReadFile(ca); err == nil {
-					if !rootCAs.AppendCertsFromPEM(pemData) {
-						return nil, fmt.Errorf("failed to parse root CA from file %s", ca)
-					}
-				} else {
-					// Treat as PEM data directly
-					if !rootCAs.AppendCertsFromPEM([]byte(ca)) {
-						return nil, fmt.Errorf("failed to parse root CA PEM data")
-					}
+// backendTLSConfig builds the client TLS configuration for a service's
+// backends. CAs and client certificates may be file paths or inline PEM.
+func backendTLSConfig(cfg *types.TLSConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+		ServerName:         cfg.ServerName,
+	}
+
+	// Add root CAs if provided
+	if len(cfg.RootCAs) > 0 {
+		rootCAs := x509.NewCertPool()
+		for _, ca := range cfg.RootCAs {
+			// Try to load as file first
+			if pemData, err := os.ReadFile(ca); err == nil {
+				if !rootCAs.AppendCertsFromPEM(pemData) {
+					return nil, fmt.Errorf("failed to parse root CA from file %s", ca)
+				}
+			} else {
+				// Treat as PEM data directly
+				if !rootCAs.AppendCertsFromPEM([]byte(ca)) {
+					return nil, fmt.Errorf("failed to parse root CA PEM data")
 				}
 			}
-			tlsConfig.RootCAs = rootCAs
 		}
+		tlsConfig.RootCAs = rootCAs
+	}
 
-		// Add client certificate if provided
-		if service.TLS.ClientCert != "" && service.TLS.ClientKey != "" {
-			// Try to load as files first
-			cert, err := tls.LoadX509KeyPair(service.TLS.ClientCert, service.TLS.ClientKey)
+	// Add client certificate if provided
+	if cfg.ClientCert != "" && cfg.ClientKey != "" {
+		// Try to load as files first
+		cert, err := tls.LoadX509KeyPair(cfg.ClientCert, cfg.ClientKey)
+		if err != nil {
+			// Try as PEM data directly
+			cert, err = tls.X509KeyPair([]byte(cfg.ClientCert), []byte(cfg.ClientKey))
 			if err != nil {
-				// Try as PEM data directly
-				cert, err = tls.X509KeyPair([]byte(service.TLS.ClientCert), []byte(service.TLS.ClientKey))
-				if err != nil {
-					return nil, fmt.Errorf("failed to load client certificate: %w", err)
-				}
+				return nil, fmt.Errorf("failed to load client certificate: %w", err)
 			}
-			tlsConfig.Certificates = []tls.Certificate{cert}
 		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
 
-		transport.TLSClientConfig = tlsConfig
+	return tlsConfig, nil
+}
+
+// serviceTransport is a cached transport built for a service's TLS settings
+type serviceTransport struct {
+	settings  types.TLSConfig
+	transport *http.Transport
+}
+
+// transportFor returns the transport for a service's backends. Services
+// without enabled TLS settings share the proxy's transport; the rest get a copy of it
+// with their own TLS configuration, built once and rebuilt when the settings
+// change.
+func (p *Proxy) transportFor(service *types.Service) (http.RoundTripper, error) {
+	if !service.HasTLS() {
+		p.dropServiceTransport(service.ID)
+		return p.transport, nil
+	}
+
+	if cached, ok := p.serviceTransports.Load(service.ID); ok {
+		st := cached.(*serviceTransport)
+		if reflect.DeepEqual(st.settings, *service.TLS) {
+			return st.transport, nil
+		}
+	}
+
+	tlsConfig, err := backendTLSConfig(service.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep the pool, timeout and dial settings of the shared transport. Any
+	// other transport can't be copied without losing them, such as the
+	// resolver's dialer, so TLS settings are refused rather than applied to
+	// a stand-in.
+	base, ok := p.transport.(*http.Transport)
+	if !ok {
+		return nil, fmt.Errorf("service %s has TLS settings, which a %T transport can't carry", service.ID, p.transport)
+	}
+	transport := base.Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	st := &serviceTransport{settings: *service.TLS, transport: transport}
+	if previous, loaded := p.serviceTransports.Swap(service.ID, st); loaded {
+		previous.(*serviceTransport).transport.CloseIdleConnections()
 	}
 
 	return transport, nil
 }
 
+// dropServiceTransport forgets a service's own transport, closing its idle
+// connections. Requests still using it finish normally.
+func (p *Proxy) dropServiceTransport(serviceID string) {
+	if cached, ok := p.serviceTransports.LoadAndDelete(serviceID); ok {
+		cached.(*serviceTransport).transport.CloseIdleConnections()
+	}
+}
+
 // getTLSVersion converts string TLS version to tls constant
 func getTLSVersion(version string) uint16 {
 	switch version {
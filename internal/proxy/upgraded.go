@@ -0,0 +1,196 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// upgradedPollInterval is how often CloseUpgraded checks for remaining connections
+const upgradedPollInterval = 10 * time.Millisecond
+
+// websocketGoingAway is the close status sent to WebSocket clients on shutdown
+const websocketGoingAway = 1001
+
+// errUpgradesClosed refuses upgrades once CloseUpgraded has been called
+var errUpgradesClosed = errors.New("server is shutting down")
+
+// upgradeTracker keeps the hijacked connections of upgraded requests, which
+// http.Server.Shutdown neither waits for nor closes
+type upgradeTracker struct {
+	mu      sync.Mutex
+	conns   map[*trackedConn]struct{}
+	closing bool
+}
+
+// isClosing reports whether CloseUpgraded has been called
+func (t *upgradeTracker) isClosing() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.closing
+}
+
+// trackUpgrade wraps w so the connection it hands out when hijacked is tracked
+func (p *Proxy) trackUpgrade(w http.ResponseWriter, r *http.Request) http.ResponseWriter {
+	return &trackingWriter{
+		ResponseWriter: w,
+		tracker:        &p.upgraded,
+		websocket:      strings.EqualFold(r.Header.Get("Upgrade"), "websocket"),
+	}
+}
+
+// trackingWriter registers hijacked connections with an upgradeTracker
+type trackingWriter struct {
+	http.ResponseWriter
+	tracker   *upgradeTracker
+	websocket bool
+}
+
+// Hijack takes over the underlying connection and tracks it until it is closed
+func (tw *trackingWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err := http.NewResponseController(tw.ResponseWriter).Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tc := &trackedConn{Conn: conn, tracker: tw.tracker, websocket: tw.websocket}
+
+	t := tw.tracker
+	t.mu.Lock()
+	closing := t.closing
+	if !closing {
+		if t.conns == nil {
+			t.conns = make(map[*trackedConn]struct{})
+		}
+		t.conns[tc] = struct{}{}
+	}
+	t.mu.Unlock()
+
+	// Upgrades that raced with shutdown are cut off
+	if closing {
+		conn.Close()
+		return nil, nil, net.ErrClosed
+	}
+	return tc, brw, nil
+}
+
+// Unwrap returns the underlying response writer for http.ResponseController
+func (tw *trackingWriter) Unwrap() http.ResponseWriter {
+	return tw.ResponseWriter
+}
+
+// trackedConn is a hijacked client connection. Once shutdown starts, backend
+// data is discarded so nothing follows the close frame, while the client's
+// close reply still reaches the backend.
+type trackedConn struct {
+	net.Conn
+	tracker   *upgradeTracker
+	websocket bool
+
+	mu        sync.Mutex
+	goingAway bool
+	closeOnce sync.Once
+}
+
+func (c *trackedConn) Write(b []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.goingAway {
+		return len(b), nil
+	}
+	return c.Conn.Write(b)
+}
+
+func (c *trackedConn) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		c.tracker.mu.Lock()
+		delete(c.tracker.conns, c)
+		c.tracker.mu.Unlock()
+
+		err = c.Conn.Close()
+	})
+	return err
+}
+
+// goAway tells WebSocket clients the server is going away. A write in
+// flight finishes first so the close frame doesn't land inside it; deadline
+// bounds how long that and the close frame itself may take.
+func (c *trackedConn) goAway(deadline time.Time) {
+	// Unblocks a write stuck on a client that stopped reading
+	c.Conn.SetWriteDeadline(deadline)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.goingAway {
+		return
+	}
+	c.goingAway = true
+
+	if c.websocket {
+		c.Conn.Write(websocketCloseFrame(websocketGoingAway))
+	}
+}
+
+// websocketCloseFrame builds an unmasked close frame, as sent by servers
+func websocketCloseFrame(code uint16) []byte {
+	frame := []byte{0x88, 2, 0, 0} // FIN + close opcode, 2-byte payload
+	binary.BigEndian.PutUint16(frame[2:], code)
+	return frame
+}
+
+// CloseUpgraded closes upgraded connections such as WebSockets, which
+// http.Server.Shutdown leaves running. WebSocket clients are sent a close
+// frame and every connection gets until ctx's deadline to finish; any still
+// open when ctx is done are cut. New upgrades are refused from here on. It
+// returns ctx's error if connections had to be cut.
+func (p *Proxy) CloseUpgraded(ctx context.Context) error {
+	t := &p.upgraded
+
+	t.mu.Lock()
+	t.closing = true
+	conns := make([]*trackedConn, 0, len(t.conns))
+	for conn := range t.conns {
+		conns = append(conns, conn)
+	}
+	t.mu.Unlock()
+
+	// Without a deadline, connections are waited for until ctx is canceled
+	deadline, _ := ctx.Deadline()
+	for _, conn := range conns {
+		go conn.goAway(deadline)
+	}
+
+	ticker := time.NewTicker(upgradedPollInterval)
+	defer ticker.Stop()
+
+	for {
+		if p.UpgradedConns() == 0 {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			for _, conn := range conns {
+				conn.Close()
+			}
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// UpgradedConns returns the number of open upgraded connections
+func (p *Proxy) UpgradedConns() int {
+	p.upgraded.mu.Lock()
+	defer p.upgraded.mu.Unlock()
+	return len(p.upgraded.conns)
+}
@@ -10,7 +10,6 @@ import (
 
 	"crypto/tls"
 	"net/http"
-	"net/http/httputil"
 
 	"discobox/internal/types"
 )
@@ -131,17 +130,14 @@ func (wp *WebSocketProxy) forwardRequest(backendConn net.Conn, r *http.Request)
 	outReq.URL.Host = ""
 	outReq.RequestURI = ""
 
-	// Remove hop-by-hop headers
+	// Remove hop-by-hop headers, then ask the backend for the same upgrade.
+	// Subprotocol and other handshake headers pass through untouched.
+	upgrade := r.Header.Get("Upgrade")
 	removeHopHeaders(outReq.Header)
+	outReq.Header.Set("Connection", "Upgrade")
+	outReq.Header.Set("Upgrade", upgrade)
 
-	// Dump the request
-	dump, err := httputil.DumpRequestOut(outReq, true)
-	if err != nil {
-		return err
-	}
-
-	_, err = backendConn.Write(dump)
-	return err
+	return outReq.Write(backendConn)
 }
 
 // forwardResponse forwards the WebSocket upgrade response to the client
@@ -199,8 +195,19 @@ func (wp *WebSocketProxy) writeError(w *bufio.ReadWriter, code int) {
 	w.Flush()
 }
 
-// removeHopHeaders removes hop-by-hop headers
+// removeHopHeaders removes hop-by-hop headers. WebSocket handshake headers
+// are end-to-end and kept even when Connection lists them.
 func removeHopHeaders(h http.Header) {
+	// Read before Connection itself is removed
+	var listed []string
+	for _, v := range h.Values("Connection") {
+		for _, header := range strings.Split(v, ",") {
+			if header = strings.TrimSpace(header); header != "" {
+				listed = append(listed, header)
+			}
+		}
+	}
+
 	hopHeaders := []string{
 		"Connection",
 		"Proxy-Connection",
@@ -218,8 +225,10 @@ func removeHopHeaders(h http.Header) {
 	}
 
 	// Remove connection-specific headers
-	for _, header := range h["Connection"] {
-		h.Del(header)
+	for _, header := range listed {
+		if !strings.HasPrefix(http.CanonicalHeaderKey(header), "Sec-Websocket-") {
+			h.Del(header)
+		}
 	}
 }
 
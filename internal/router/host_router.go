@@ -1,80 +1,115 @@
 package router
 
 import (
+	"net"
+	"net/http"
 	"strings"
 	"sync"
 
 	"discobox/internal/types"
 )
 
-// hostRouter is an optimized router for host-based routing
+// hostRouter indexes routes by host, then by path prefix, so a lookup only
+// considers routes that could match the request's host and path. Exact
+// hosts are checked before wildcards, most specific first, and wildcards
+// before routes without a host; within each group routes keep their priority
+// order.
 type hostRouter struct {
 	mu         sync.RWMutex
-	exactHosts map[string][]*types.Route      // Exact host matches
-	wildcards  map[string][]*types.Route      // Wildcard domains (*.example.com)
-	allRoutes  []*types.Route                 // Routes without host constraints
+	exactHosts map[string]*pathTrie // Exact host matches
+	wildcards  map[string]*pathTrie // Wildcard domains, keyed by suffix (.example.com)
+	allRoutes  *pathTrie            // Routes without host constraints
+	size       int                  // Routes added so far
 }
 
 // newHostRouter creates a new host-based router
 func newHostRouter() *hostRouter {
 	return &hostRouter{
-		exactHosts: make(map[string][]*types.Route),
-		wildcards:  make(map[string][]*types.Route),
-		allRoutes:  make([]*types.Route, 0),
+		exactHosts: make(map[string]*pathTrie),
+		wildcards:  make(map[string]*pathTrie),
+		allRoutes:  &pathTrie{},
 	}
 }
 
-// addRoute adds a route to the host router
+// addRoute adds a route to the host router. Routes must be added in
+// priority order.
 func (h *hostRouter) addRoute(route *types.Route) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
-	
-	if route.Host == "" {
-		// Route matches all hosts
-		h.allRoutes = append(h.allRoutes, route)
-		return
-	}
-	
-	if strings.HasPrefix(route.Host, "*.") {
-		// Wildcard host
-		domain := route.Host[1:] // Remove * prefix
-		h.wildcards[domain] = append(h.wildcards[domain], route)
-	} else {
-		// Exact host
-		h.exactHosts[route.Host] = append(h.exactHosts[route.Host], route)
+
+	trie := h.allRoutes
+	if route.Host != "" {
+		host := normalizeHost(route.Host)
+		index := h.exactHosts
+		if strings.HasPrefix(host, "*.") {
+			// Wildcard host
+			host = host[1:] // Remove * prefix
+			index = h.wildcards
+		}
+		if trie = index[host]; trie == nil {
+			trie = &pathTrie{}
+			index[host] = trie
+		}
 	}
+
+	trie.insert(route.PathPrefix, trieEntry{pos: h.size, route: route})
+	h.size++
 }
 
-// findRoutes returns all routes that could match the given host
-func (h *hostRouter) findRoutes(host string) []*types.Route {
+// match returns the first route that could serve host and path and that
+// accepts reports true for
+func (h *hostRouter) match(host, path string, accepts func(*types.Route) bool) *types.Route {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
-	
-	// Remove port if present
-	if idx := strings.LastIndex(host, ":"); idx != -1 {
-		host = host[:idx]
-	}
-	
-	var routes []*types.Route
-	
+
+	host = normalizeHost(host)
+
 	// Check exact match first
-	if exactRoutes, ok := h.exactHosts[host]; ok {
-		routes = append(routes, exactRoutes...)
+	if trie := h.exactHosts[host]; trie != nil {
+		if route := trie.match(path, accepts); route != nil {
+			return route
+		}
 	}
-	
-	// Check wildcard matches
-	for domain, wildcardRoutes := range h.wildcards {
-		if strings.HasSuffix(host, domain) {
-			routes = append(routes, wildcardRoutes...)
+
+	// Check wildcard matches, longest domain first
+	if len(h.wildcards) > 0 {
+		for i := strings.IndexByte(host, '.'); i >= 0; {
+			if trie := h.wildcards[host[i:]]; trie != nil {
+				if route := trie.match(path, accepts); route != nil {
+					return route
+				}
+			}
+			next := strings.IndexByte(host[i+1:], '.')
+			if next < 0 {
+				break
+			}
+			i += next + 1
 		}
 	}
-	
-	// Add routes without host constraints
-	routes = append(routes, h.allRoutes...)
-	
-	// Note: Routes are already sorted by priority when they were added
-	// The router maintains the priority order when loading from storage
-	return routes
+
+	// Fall back to routes without host constraints
+	return h.allRoutes.match(path, accepts)
 }
 
+// requestHost returns the host a request was sent to, the same way for
+// HTTP/1.1 and HTTP/2. Go fills req.Host from the Host header or the
+// :authority pseudo-header; the request URL covers requests built without
+// either.
+func requestHost(req *http.Request) string {
+	host := req.Host
+	if host == "" && req.URL != nil {
+		host = req.URL.Host
+	}
+	return normalizeHost(host)
+}
 
+// normalizeHost lowercases a host and drops its port, IPv6 brackets and any
+// trailing dot, so differently written forms of a host compare equal
+func normalizeHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	} else if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
+		host = host[1 : len(host)-1]
+	}
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
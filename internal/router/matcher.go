@@ -24,7 +24,7 @@ func (m *Matcher) MatchRoute(req *http.Request, route *types.Route) (bool, map[s
 	params := make(map[string]string)
 	
 	// Match host
-	if route.Host != "" && !m.matchHost(req.Host, route.Host) {
+	if route.Host != "" && !m.matchHost(requestHost(req), route.Host) {
 		return false, nil
 	}
 	
@@ -39,29 +39,40 @@ func (m *Matcher) MatchRoute(req *http.Request, route *types.Route) (bool, map[s
 		params[k] = v
 	}
 	
+	// Match path suffix
+	if !route.MatchesPathSuffix(req.URL.Path) {
+		return false, nil
+	}
+	
 	// Match headers
 	if !m.matchHeaders(req, route.Headers) {
 		return false, nil
 	}
 	
+	// Match content type
+	if !route.MatchesContentType(req.Header.Get("Content-Type")) {
+		return false, nil
+	}
+	
+	// Match SNI and client certificate
+	if route.RequiresTLS() && !m.matchTLS(req, route) {
+		return false, nil
+	}
+	
 	return true, params
 }
 
-// matchHost checks if the request host matches the route host pattern
+// matchHost checks if the normalized request host matches the route host
+// pattern
 func (m *Matcher) matchHost(reqHost, routeHost string) bool {
-	// Remove port from request host
-	if idx := strings.LastIndex(reqHost, ":"); idx != -1 {
-		reqHost = reqHost[:idx]
-	}
-	
 	// Exact match
-	if reqHost == routeHost {
+	if reqHost == normalizeHost(routeHost) {
 		return true
 	}
 	
 	// Wildcard subdomain match (*.example.com)
 	if strings.HasPrefix(routeHost, "*.") {
-		suffix := routeHost[1:] // Remove *
+		suffix := normalizeHost(routeHost[1:]) // Remove *
 		return strings.HasSuffix(reqHost, suffix)
 	}
 	
@@ -147,6 +158,24 @@ func (m *Matcher) matchHeaders(req *http.Request, routeHeaders map[string]string
 	return true
 }
 
+// matchTLS checks the route's SNI and client certificate criteria
+func (m *Matcher) matchTLS(req *http.Request, route *types.Route) bool {
+	cr := &compiledRoute{route: route}
+	
+	if pattern, ok := strings.CutPrefix(route.SNI, types.TLSMatchRegexPrefix); ok {
+		if cr.sniRegexp = m.getOrCompileRegex("sni:"+pattern, pattern); cr.sniRegexp == nil {
+			return false
+		}
+	}
+	if pattern, ok := strings.CutPrefix(route.ClientCertSubject, types.TLSMatchRegexPrefix); ok {
+		if cr.subjectRegexp = m.getOrCompileRegex("subject:"+pattern, pattern); cr.subjectRegexp == nil {
+			return false
+		}
+	}
+	
+	return matchTLS(req, route, cr)
+}
+
 // getOrCompileRegex returns a compiled regex, caching it for reuse
 func (m *Matcher) getOrCompileRegex(key, pattern string) *regexp.Regexp {
 	if regex, exists := m.compiledRegex[key]; exists {
@@ -208,9 +237,15 @@ func RouteScore(route *types.Route) int {
 	} else if route.PathPrefix != "" {
 		score += 20 + len(route.PathPrefix) // Longer prefixes are more specific
 	}
+	if len(route.PathSuffixes) > 0 {
+		score += 10
+	}
 	
 	// Header requirements add specificity
 	score += len(route.Headers) * 10
+	if route.ContentType != "" {
+		score += 10
+	}
 	
 	return score
 }
@@ -0,0 +1,122 @@
+package router
+
+import (
+	"strings"
+
+	"discobox/internal/types"
+)
+
+// trieEntry is a route indexed in a pathTrie, with its position in the
+// router's priority order
+type trieEntry struct {
+	pos   int
+	route *types.Route
+}
+
+// pathTrie is a radix tree of route path prefixes. A lookup walks the
+// request path once and yields only the routes whose prefix it starts with,
+// so matching cost follows the path length rather than the route count.
+// Routes without a prefix, such as regex and header-only routes, sit at the
+// root and are checked for every path.
+type pathTrie struct {
+	root trieNode
+}
+
+// trieNode is a node of a pathTrie. label is the part of the prefix on the
+// edge into the node, and indices holds the first byte of each child's label.
+type trieNode struct {
+	label    string
+	indices  string
+	children []*trieNode
+	routes   []trieEntry // In priority order
+}
+
+// insert adds a route under prefix. Routes must be inserted in priority
+// order.
+func (t *pathTrie) insert(prefix string, entry trieEntry) {
+	n := &t.root
+	for prefix != "" {
+		i := strings.IndexByte(n.indices, prefix[0])
+		if i < 0 {
+			child := &trieNode{label: prefix}
+			n.indices += prefix[:1]
+			n.children = append(n.children, child)
+			n = child
+			break
+		}
+
+		child := n.children[i]
+		common := commonPrefixLen(prefix, child.label)
+		if common < len(child.label) {
+			// Split the edge where the prefixes diverge
+			split := &trieNode{
+				label:    child.label[:common],
+				indices:  child.label[common : common+1],
+				children: []*trieNode{child},
+			}
+			child.label = child.label[common:]
+			n.children[i] = split
+			child = split
+		}
+		prefix = prefix[common:]
+		n = child
+	}
+	n.routes = append(n.routes, entry)
+}
+
+// match returns the first route, in priority order, that has a prefix of
+// path and that accepts reports true for
+func (t *pathTrie) match(path string, accepts func(*types.Route) bool) *types.Route {
+	// Each node along the path contributes a list already in priority order
+	var buf [16][]trieEntry
+	lists := buf[:0]
+
+	n := &t.root
+	for {
+		if len(n.routes) > 0 {
+			lists = append(lists, n.routes)
+		}
+		if path == "" {
+			break
+		}
+		i := strings.IndexByte(n.indices, path[0])
+		if i < 0 {
+			break
+		}
+		child := n.children[i]
+		if !strings.HasPrefix(path, child.label) {
+			break
+		}
+		path = path[len(child.label):]
+		n = child
+	}
+
+	// Merge the lists by position
+	for {
+		best := -1
+		for i, list := range lists {
+			if len(list) > 0 && (best < 0 || list[0].pos < lists[best][0].pos) {
+				best = i
+			}
+		}
+		if best < 0 {
+			return nil
+		}
+		entry := lists[best][0]
+		lists[best] = lists[best][1:]
+		if accepts(entry.route) {
+			return entry.route
+		}
+	}
+}
+
+// commonPrefixLen returns the length of the longest common prefix of a and b
+func commonPrefixLen(a, b string) int {
+	n := min(len(a), len(b))
+	for i := 0; i < n; i++ {
+		if a[i] != b[i] {
+			return i
+		}
+	}
+	return n
+}
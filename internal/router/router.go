@@ -29,8 +29,10 @@ type router struct {
 
 // compiledRoute holds pre-compiled regex patterns
 type compiledRoute struct {
-	route      *types.Route
-	pathRegexp *regexp.Regexp
+	route         *types.Route
+	pathRegexp    *regexp.Regexp
+	sniRegexp     *regexp.Regexp
+	subjectRegexp *regexp.Regexp
 }
 
 // NewRouter creates a new router instance
@@ -73,50 +75,63 @@ func (r *router) Match(req *http.Request) (*types.Route, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 	
-	// Use host router to get candidate routes
-	candidates := r.hostRouter.findRoutes(req.Host)
-	
-	// If no candidates based on host, no match possible
-	if len(candidates) == 0 {
+	// The host router only offers routes whose host and path prefix fit the
+	// request, in priority order
+	route := r.hostRouter.match(requestHost(req), req.URL.Path, func(route *types.Route) bool {
+		return r.matchRoute(req, route)
+	})
+	if route == nil {
 		return nil, types.ErrRouteNotFound
 	}
 	
-	// Routes are already sorted by priority in the candidates list
-	for _, route := range candidates {
-		compiledRoute := r.compiled[route.ID]
-		
-		// Skip routes with invalid regex (not in compiled map)
-		if route.PathRegex != "" && compiledRoute == nil {
-			continue
-		}
-		
-		// Match path prefix
-		if route.PathPrefix != "" && !strings.HasPrefix(req.URL.Path, route.PathPrefix) {
-			continue
-		}
-		
-		// Match path regex
-		if route.PathRegex != "" && compiledRoute != nil && compiledRoute.pathRegexp != nil {
-			if !compiledRoute.pathRegexp.MatchString(req.URL.Path) {
-				continue
-			}
-		}
-		
-		// Match headers
-		if !r.matchHeaders(req, route.Headers) {
-			continue
-		}
-		
-		// Found a match
-		r.logger.Debug("route matched",
-			"route_id", route.ID,
-			"host", req.Host,
-			"path", req.URL.Path,
-		)
-		return route, nil
+	r.logger.Debug("route matched",
+		"route_id", route.ID,
+		"host", req.Host,
+		"path", req.URL.Path,
+	)
+	return route, nil
+}
+
+// matchRoute checks a candidate route's remaining criteria against a request
+func (r *router) matchRoute(req *http.Request, route *types.Route) bool {
+	compiledRoute := r.compiled[route.ID]
+	
+	// Skip routes with invalid regex (not in compiled map)
+	if compiledRoute == nil {
+		return false
+	}
+	
+	// Match path prefix
+	if route.PathPrefix != "" && !strings.HasPrefix(req.URL.Path, route.PathPrefix) {
+		return false
+	}
+	
+	// Match path regex
+	if compiledRoute.pathRegexp != nil && !compiledRoute.pathRegexp.MatchString(req.URL.Path) {
+		return false
+	}
+	
+	// Match path suffix
+	if !route.MatchesPathSuffix(req.URL.Path) {
+		return false
+	}
+	
+	// Match headers
+	if !r.matchHeaders(req, route.Headers) {
+		return false
+	}
+	
+	// Match content type
+	if !route.MatchesContentType(req.Header.Get("Content-Type")) {
+		return false
 	}
 	
-	return nil, types.ErrRouteNotFound
+	// Match SNI and client certificate
+	if route.RequiresTLS() && !matchTLS(req, route, compiledRoute) {
+		return false
+	}
+	
+	return true
 }
 
 // AddRoute adds a new route
@@ -205,6 +220,24 @@ func (r *router) loadRoutes(ctx context.Context) error {
 			cr.pathRegexp = regex
 		}
 		
+		var err error
+		if cr.sniRegexp, err = compileTLSPattern(route.SNI); err != nil {
+			r.logger.Error("failed to compile route SNI regex",
+				"route_id", route.ID,
+				"sni", route.SNI,
+				"error", err,
+			)
+			continue
+		}
+		if cr.subjectRegexp, err = compileTLSPattern(route.ClientCertSubject); err != nil {
+			r.logger.Error("failed to compile route client certificate regex",
+				"route_id", route.ID,
+				"client_cert_subject", route.ClientCertSubject,
+				"error", err,
+			)
+			continue
+		}
+		
 		compiled[route.ID] = cr
 	}
 	
@@ -280,6 +313,50 @@ func (r *router) matchHeaders(req *http.Request, routeHeaders map[string]string)
 	return true
 }
 
+// compileTLSPattern compiles an SNI or subject criterion written as a regex,
+// returning nil for exact values
+func compileTLSPattern(pattern string) (*regexp.Regexp, error) {
+	if !strings.HasPrefix(pattern, types.TLSMatchRegexPrefix) {
+		return nil, nil
+	}
+	return regexp.Compile(strings.TrimPrefix(pattern, types.TLSMatchRegexPrefix))
+}
+
+// matchTLS checks the route's SNI and client certificate criteria against
+// the connection. Plain HTTP requests never match.
+func matchTLS(req *http.Request, route *types.Route, cr *compiledRoute) bool {
+	if req.TLS == nil {
+		return false
+	}
+	
+	if route.SNI != "" {
+		sni := req.TLS.ServerName
+		if cr.sniRegexp != nil {
+			if !cr.sniRegexp.MatchString(sni) {
+				return false
+			}
+		} else if !strings.EqualFold(sni, route.SNI) {
+			return false
+		}
+	}
+	
+	if route.ClientCertSubject != "" {
+		if len(req.TLS.PeerCertificates) == 0 {
+			return false
+		}
+		subject := req.TLS.PeerCertificates[0].Subject
+		if cr.subjectRegexp != nil {
+			if !cr.subjectRegexp.MatchString(subject.String()) {
+				return false
+			}
+		} else if route.ClientCertSubject != subject.String() && route.ClientCertSubject != subject.CommonName {
+			return false
+		}
+	}
+	
+	return true
+}
+
 // Close stops the router and waits for goroutines to finish
 func (r *router) Close() error {
 	close(r.stopCh)
@@ -5,10 +5,14 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"fmt"
+	"path/filepath"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/caddyserver/certmagic"
+	"github.com/fsnotify/fsnotify"
 
 	"discobox/internal/types"
 )
@@ -19,16 +23,16 @@ type CertManager struct {
 	logger      types.Logger
 	cache       sync.Map // domain -> *tls.Certificate
 	certMagic   *certmagic.Config
-	staticCerts map[string]*tls.Certificate
+	staticCerts atomic.Pointer[map[string]*tls.Certificate] // swapped wholesale on reload
 }
 
 // NewCertManager creates a new certificate manager
 func NewCertManager(config *types.ProxyConfig, logger types.Logger) (*CertManager, error) {
 	cm := &CertManager{
-		config:      config,
-		logger:      logger,
-		staticCerts: make(map[string]*tls.Certificate),
+		config: config,
+		logger: logger,
 	}
+	cm.staticCerts.Store(&map[string]*tls.Certificate{})
 
 	// Initialize CertMagic if ACME is enabled
 	if config.TLS.AutoCert {
@@ -63,42 +67,186 @@ func NewCertManager(config *types.ProxyConfig, logger types.Logger) (*CertManage
 
 	// Load static certificates if not using ACME
 	if !config.TLS.AutoCert && config.TLS.CertFile != "" {
-		cert, err := tls.LoadX509KeyPair(config.TLS.CertFile, config.TLS.KeyFile)
+		if err := cm.ReloadCertificates(); err != nil {
+			return nil, err
+		}
+	}
+
+	return cm, nil
+}
+
+// loadCertificate loads a certificate/key pair and checks that it is
+// currently valid
+func loadCertificate(certFile, keyFile string) (*tls.Certificate, *x509.Certificate, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load certificate %s: %w", certFile, err)
+	}
+
+	// Parse the certificate to extract domains
+	x509Cert, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse certificate %s: %w", certFile, err)
+	}
+
+	now := time.Now()
+	if now.Before(x509Cert.NotBefore) || now.After(x509Cert.NotAfter) {
+		return nil, nil, fmt.Errorf("certificate %s is not valid at %s (valid %s to %s)", certFile,
+			now.Format(time.RFC3339), x509Cert.NotBefore.Format(time.RFC3339), x509Cert.NotAfter.Format(time.RFC3339))
+	}
+
+	return &cert, x509Cert, nil
+}
+
+// certificateNames returns the names a certificate covers, lowercased to
+// match the SNI lookup
+func certificateNames(x509Cert *x509.Certificate) []string {
+	names := make([]string, 0, len(x509Cert.DNSNames)+1)
+	if x509Cert.Subject.CommonName != "" {
+		names = append(names, strings.ToLower(x509Cert.Subject.CommonName))
+	}
+	for _, name := range x509Cert.DNSNames {
+		names = append(names, strings.ToLower(name))
+	}
+	return names
+}
+
+// loadStaticCertificates loads and validates the default certificate and any
+// additional SNI certificates, mapping each by the names it serves
+func (cm *CertManager) loadStaticCertificates() (map[string]*tls.Certificate, error) {
+	cert, x509Cert, err := loadCertificate(cm.config.TLS.CertFile, cm.config.TLS.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	certs := make(map[string]*tls.Certificate)
+	for _, name := range certificateNames(x509Cert) {
+		certs[name] = cert
+	}
+
+	// Serve it by default to clients that don't send SNI or ask for an
+	// unknown host
+	certs[""] = cert
+
+	// Additional certificates take precedence for the hosts they cover
+	for _, extra := range cm.config.TLS.Certificates {
+		cert, x509Cert, err := loadCertificate(extra.CertFile, extra.KeyFile)
 		if err != nil {
-			return nil, fmt.Errorf("failed to load certificate: %w", err)
+			return nil, err
 		}
 
-		// Extract domains from certificate
-		if len(cert.Certificate) > 0 {
-			// Parse the certificate to extract domains
-			x509Cert, err := x509.ParseCertificate(cert.Certificate[0])
-			if err != nil {
-				return nil, fmt.Errorf("failed to parse certificate: %w", err)
-			}
+		hosts := extra.Hosts
+		if len(hosts) == 0 {
+			hosts = certificateNames(x509Cert)
+		}
+		for _, host := range hosts {
+			certs[strings.ToLower(host)] = cert
+		}
+	}
 
-			// Add certificate for the common name
-			if x509Cert.Subject.CommonName != "" {
-				cm.staticCerts[x509Cert.Subject.CommonName] = &cert
-			}
+	return certs, nil
+}
 
-			// Add certificate for all DNS SANs
-			for _, dnsName := range x509Cert.DNSNames {
-				cm.staticCerts[dnsName] = &cert
-			}
+// ReloadCertificates reloads the static certificate from disk. The new
+// certificate only replaces the current one if it loads and validates.
+func (cm *CertManager) ReloadCertificates() error {
+	if cm.config.TLS.AutoCert || cm.config.TLS.CertFile == "" {
+		return nil
+	}
 
-			// If no specific domains found, use as default certificate
-			if x509Cert.Subject.CommonName == "" && len(x509Cert.DNSNames) == 0 {
-				cm.staticCerts[""] = &cert
-			}
+	certs, err := cm.loadStaticCertificates()
+	if err != nil {
+		return err
+	}
+
+	cm.staticCerts.Store(&certs)
+
+	// Drop wildcard lookups cached against the old certificate
+	cm.cache.Range(func(key, value any) bool {
+		cm.cache.Delete(key)
+		return true
+	})
+
+	cm.logger.Info("TLS certificate loaded", "cert_file", cm.config.TLS.CertFile)
+	return nil
+}
+
+// WatchCertificates reloads the static certificate whenever the certificate
+// or key file changes, until ctx is cancelled. The containing directories are
+// watched so atomic renames and symlink swaps (as done by cert-manager) are
+// picked up.
+func (cm *CertManager) WatchCertificates(ctx context.Context) error {
+	if cm.config.TLS.AutoCert || cm.config.TLS.CertFile == "" {
+		return nil
+	}
+
+	fsWatcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create file watcher: %w", err)
+	}
+
+	files := []string{cm.config.TLS.CertFile, cm.config.TLS.KeyFile}
+	for _, extra := range cm.config.TLS.Certificates {
+		files = append(files, extra.CertFile, extra.KeyFile)
+	}
+
+	for _, file := range files {
+		if err := fsWatcher.Add(filepath.Dir(file)); err != nil {
+			fsWatcher.Close()
+			return fmt.Errorf("failed to watch %s: %w", file, err)
 		}
 	}
 
-	return cm, nil
+	go func() {
+		defer fsWatcher.Close()
+
+		// Debounce timer so a cert and key written together reload once
+		var debounceTimer *time.Timer
+		debounceDuration := 500 * time.Millisecond
+
+		for {
+			select {
+			case <-ctx.Done():
+				if debounceTimer != nil {
+					debounceTimer.Stop()
+				}
+				return
+
+			case event, ok := <-fsWatcher.Events:
+				if !ok {
+					return
+				}
+				if event.Op == fsnotify.Chmod {
+					continue
+				}
+
+				cm.logger.Debug("Certificate directory changed", "file", event.Name, "op", event.Op)
+
+				if debounceTimer != nil {
+					debounceTimer.Stop()
+				}
+				debounceTimer = time.AfterFunc(debounceDuration, func() {
+					if err := cm.ReloadCertificates(); err != nil {
+						cm.logger.Error("Failed to reload TLS certificate, keeping current one", "error", err)
+					}
+				})
+
+			case err, ok := <-fsWatcher.Errors:
+				if !ok {
+					return
+				}
+				cm.logger.Error("Certificate watcher error", "error", err)
+			}
+		}
+	}()
+
+	cm.logger.Info("Watching TLS certificate files", "cert_file", cm.config.TLS.CertFile, "key_file", cm.config.TLS.KeyFile)
+	return nil
 }
 
 // GetCertificate returns a certificate for the given ClientHelloInfo
 func (cm *CertManager) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
-	domain := hello.ServerName
+	domain := strings.ToLower(hello.ServerName)
 	cm.logger.Debug("Certificate requested", "domain", domain)
 
 	// Check cache first
@@ -125,8 +273,10 @@ func (cm *CertManager) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certific
 	}
 
 	// Check static certificates
+	staticCerts := *cm.staticCerts.Load()
+
 	// First try exact match
-	if cert, ok := cm.staticCerts[domain]; ok {
+	if cert, ok := staticCerts[domain]; ok {
 		return cert, nil
 	}
 
@@ -134,7 +284,7 @@ func (cm *CertManager) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certific
 	labels := splitDomain(domain)
 	for i := range labels {
 		wildcardDomain := "*." + joinDomain(labels[i:])
-		if cert, ok := cm.staticCerts[wildcardDomain]; ok {
+		if cert, ok := staticCerts[wildcardDomain]; ok {
 			// Cache for faster lookup
 			cm.cache.Store(domain, cert)
 			return cert, nil
@@ -142,7 +292,7 @@ func (cm *CertManager) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certific
 	}
 
 	// Return default certificate if available
-	if cert, ok := cm.staticCerts[""]; ok {
+	if cert, ok := staticCerts[""]; ok {
 		return cert, nil
 	}
 
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// drainPollInterval is how often Drain checks the in-flight count
+const drainPollInterval = 10 * time.Millisecond
+
+// Drainer takes an instance out of rotation ahead of a rolling restart.
+// Requests served through Handler are counted; once Drain is called,
+// keep-alives are turned off on the registered servers so clients reconnect
+// elsewhere, and Drain waits for the counted requests to finish.
+type Drainer struct {
+	inFlight atomic.Int64
+	draining atomic.Bool
+	servers  []*http.Server
+}
+
+// NewDrainer creates a drainer that stops keep-alives on servers when draining
+func NewDrainer(servers ...*http.Server) *Drainer {
+	return &Drainer{servers: servers}
+}
+
+// Handler counts the requests served by next as in flight
+func (d *Drainer) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d.inFlight.Add(1)
+		defer d.inFlight.Add(-1)
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Drain marks the instance as draining and waits until at most threshold
+// requests are in flight or ctx is done. It returns the number of requests
+// still in flight. Draining can't be undone; the instance is expected to be
+// stopped afterwards.
+func (d *Drainer) Drain(ctx context.Context, threshold int) int {
+	if d.draining.CompareAndSwap(false, true) {
+		for _, srv := range d.servers {
+			// Responses now carry Connection: close and idle connections are closed
+			srv.SetKeepAlivesEnabled(false)
+		}
+	}
+
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+
+	for {
+		remaining := d.InFlight()
+		if remaining <= threshold {
+			return remaining
+		}
+
+		select {
+		case <-ctx.Done():
+			return d.InFlight()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Draining reports whether Drain has been called
+func (d *Drainer) Draining() bool {
+	return d.draining.Load()
+}
+
+// InFlight returns the number of requests currently being served
+func (d *Drainer) InFlight() int {
+	return int(d.inFlight.Load())
+}
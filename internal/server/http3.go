@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"sync"
 	
 	"crypto/tls"
 	"github.com/quic-go/quic-go"
@@ -20,11 +21,15 @@ type HTTP3Server struct {
 	server   *http3.Server
 	handler  http.Handler
 	quicConf *quic.Config
+	conn     net.PacketConn
+	mu       sync.Mutex
 }
 
-// NewHTTP3Server creates a new HTTP/3 server
-func NewHTTP3Server(config *types.ProxyConfig, handler http.Handler, logger types.Logger) (*HTTP3Server, error) {
-	if !config.TLS.Enabled {
+// NewHTTP3Server creates a new HTTP/3 server. Certificates are taken from
+// tlsManager so HTTP/3 serves the same (and hot-reloaded) certificates as the
+// TCP listener.
+func NewHTTP3Server(config *types.ProxyConfig, handler http.Handler, tlsManager *TLSManager, logger types.Logger) (*HTTP3Server, error) {
+	if !config.TLS.Enabled || tlsManager == nil {
 		return nil, fmt.Errorf("HTTP/3 requires TLS to be enabled")
 	}
 	
@@ -48,22 +53,19 @@ func NewHTTP3Server(config *types.ProxyConfig, handler http.Handler, logger type
 			tls.TLS_AES_256_GCM_SHA384,
 			tls.TLS_CHACHA20_POLY1305_SHA256,
 		},
+		GetCertificate: tlsManager.GetCertificate,
 	}
 	
-	// Load certificates
-	if !config.TLS.AutoCert {
-		cert, err := tls.LoadX509KeyPair(config.TLS.CertFile, config.TLS.KeyFile)
-		if err != nil {
-			return nil, fmt.Errorf("failed to load certificate: %w", err)
-		}
-		tlsConfig.Certificates = []tls.Certificate{cert}
+	if err := configureClientAuth(tlsConfig, config); err != nil {
+		return nil, err
 	}
 	
 	// Create HTTP/3 server
 	h3Server := &http3.Server{
-		Handler:    handler,
-		TLSConfig:  tlsConfig,
-		QUICConfig: quicConf,
+		Handler:        handler,
+		TLSConfig:      tlsConfig,
+		QUICConfig:     quicConf,
+		MaxHeaderBytes: config.MaxHeaderBytes,
 	}
 	
 	return &HTTP3Server{
@@ -87,6 +89,11 @@ func (h3s *HTTP3Server) Start(ctx context.Context) error {
 		host = "0.0.0.0"
 	}
 	
+	// Use a dedicated UDP port if configured
+	if h3s.config.HTTP3.Port != "" {
+		port = h3s.config.HTTP3.Port
+	}
+	
 	// Create UDP listener for QUIC
 	addr, err := net.ResolveUDPAddr("udp", net.JoinHostPort(host, port))
 	if err != nil {
@@ -98,51 +105,100 @@ func (h3s *HTTP3Server) Start(ctx context.Context) error {
 		return fmt.Errorf("failed to listen on UDP: %w", err)
 	}
 	
-	h3s.logger.Info("Starting HTTP/3 server", "addr", addr.String())
+	h3s.mu.Lock()
+	h3s.conn = conn
+	h3s.mu.Unlock()
 	
 	// Start serving
 	go func() {
-		if err := h3s.server.Serve(conn); err != nil {
+		if err := h3s.server.Serve(conn); err != nil && err != http.ErrServerClosed {
 			h3s.logger.Error("HTTP/3 server error", "error", err)
 		}
 	}()
 	
-	// Set Alt-Svc header to advertise HTTP/3
-	// This should be added to responses in the HTTP/1.1 and HTTP/2 servers
-	h3s.logger.Info("HTTP/3 server started", 
-		"addr", addr.String(),
-		"alt-svc", fmt.Sprintf(`h3="%s"`, addr.String()),
+	h3s.logger.Info("HTTP/3 server started",
+		"addr", conn.LocalAddr().String(),
+		"alt-svc", AltSvcValue(h3s.config),
 	)
 	
 	return nil
 }
 
-// Stop stops the HTTP/3 server
+// Addr returns the UDP address the server is listening on
+func (h3s *HTTP3Server) Addr() net.Addr {
+	h3s.mu.Lock()
+	defer h3s.mu.Unlock()
+	
+	if h3s.conn == nil {
+		return nil
+	}
+	return h3s.conn.LocalAddr()
+}
+
+// Stop gracefully stops the HTTP/3 server, sending GOAWAY to clients and
+// waiting for in-flight requests until ctx expires
 func (h3s *HTTP3Server) Stop(ctx context.Context) error {
 	h3s.logger.Info("Stopping HTTP/3 server")
 	
-	// Close the server
-	if err := h3s.server.Close(); err != nil {
-		return fmt.Errorf("failed to close HTTP/3 server: %w", err)
+	err := h3s.server.Shutdown(ctx)
+	
+	// The server doesn't own the UDP socket passed to Serve
+	h3s.mu.Lock()
+	if h3s.conn != nil {
+		h3s.conn.Close()
+		h3s.conn = nil
+	}
+	h3s.mu.Unlock()
+	
+	if err != nil {
+		return fmt.Errorf("failed to shutdown HTTP/3 server: %w", err)
 	}
 	
 	h3s.logger.Info("HTTP/3 server stopped")
 	return nil
 }
 
-// SetAltSvcHeader sets the Alt-Svc header to advertise HTTP/3
-func SetAltSvcHeader(w http.ResponseWriter, config *types.ProxyConfig) {
-	// Only set if HTTP/3 is enabled and TLS is configured
-	if config.TLS.Enabled {
+// AltSvcValue returns the Alt-Svc header value advertising HTTP/3
+func AltSvcValue(config *types.ProxyConfig) string {
+	if config.HTTP3.AltSvc != "" {
+		return config.HTTP3.AltSvc
+	}
+	
+	port := config.HTTP3.Port
+	if port == "" {
 		// Extract port from listen address
-		_, port, err := net.SplitHostPort(config.ListenAddr)
+		_, listenPort, err := net.SplitHostPort(config.ListenAddr)
 		if err != nil {
-			return
+			return ""
 		}
-		
-		// Set Alt-Svc header
-		// ma=86400 means the alternative service is fresh for 24 hours
-		w.Header().Set("Alt-Svc", fmt.Sprintf(`h3=":%s"; ma=86400`, port))
+		port = listenPort
+	}
+	
+	// ma=86400 means the alternative service is fresh for 24 hours
+	return fmt.Sprintf(`h3=":%s"; ma=86400`, port)
+}
+
+// SetAltSvcHeader sets the Alt-Svc header to advertise HTTP/3
+func SetAltSvcHeader(w http.ResponseWriter, config *types.ProxyConfig) {
+	// Only set if HTTP/3 is enabled and TLS is configured
+	if !config.HTTP3.Enabled || !config.TLS.Enabled {
+		return
+	}
+	
+	if value := AltSvcValue(config); value != "" {
+		w.Header().Set("Alt-Svc", value)
+	}
+}
+
+// AltSvc returns middleware that advertises HTTP/3 on TCP responses
+func AltSvc(config *types.ProxyConfig) types.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !IsHTTP3Request(r) {
+				SetAltSvcHeader(w, config)
+			}
+			next.ServeHTTP(w, r)
+		})
 	}
 }
 
@@ -0,0 +1,110 @@
+package server
+
+import (
+	"net"
+	"sync"
+)
+
+// Connection limit modes
+const (
+	// LimitWait holds a connection over the limit, leaving the rest in the
+	// kernel backlog, until a slot frees up
+	LimitWait = "wait"
+	// LimitRefuse accepts connections over the limit and closes them at once
+	LimitRefuse = "refuse"
+)
+
+// limitListener caps how many accepted connections are open at once
+type limitListener struct {
+	net.Listener
+	sem       chan struct{}
+	refuse    bool
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// LimitListener returns a listener that keeps at most max accepted
+// connections open, returning slots as connections close. mode picks what
+// happens to connections over the limit: LimitWait (the default) holds them
+// until a slot is free, LimitRefuse closes them straight away so
+// clients fail fast. max <= 0 returns l unchanged.
+func LimitListener(l net.Listener, max int, mode string) net.Listener {
+	return LimitListeners([]net.Listener{l}, max, mode)[0]
+}
+
+// LimitListeners is LimitListener for several listeners sharing one limit,
+// so max caps the connections open across all of them
+func LimitListeners(ls []net.Listener, max int, mode string) []net.Listener {
+	if max <= 0 {
+		return ls
+	}
+
+	sem := make(chan struct{}, max)
+	limited := make([]net.Listener, len(ls))
+	for i, l := range ls {
+		limited[i] = &limitListener{
+			Listener: l,
+			sem:      sem,
+			refuse:   mode == LimitRefuse,
+			done:     make(chan struct{}),
+		}
+	}
+	return limited
+}
+
+// Accept waits for the next connection and a free slot for it. Slots are
+// only taken once a connection has arrived, so listeners sharing a limit
+// don't hold slots while idle. In refuse mode connections with no free slot
+// are closed and the next one is awaited.
+func (l *limitListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+
+		if l.refuse {
+			select {
+			case l.sem <- struct{}{}:
+				return &limitConn{Conn: conn, release: l.release}, nil
+			default:
+				conn.Close()
+				continue
+			}
+		}
+
+		select {
+		case l.sem <- struct{}{}:
+			return &limitConn{Conn: conn, release: l.release}, nil
+		case <-l.done:
+			conn.Close()
+			return nil, net.ErrClosed
+		}
+	}
+}
+
+// release frees a slot
+func (l *limitListener) release() {
+	<-l.sem
+}
+
+// Close closes the listener and wakes a waiting Accept
+func (l *limitListener) Close() error {
+	err := l.Listener.Close()
+	l.closeOnce.Do(func() { close(l.done) })
+	return err
+}
+
+// limitConn returns its slot when closed
+type limitConn struct {
+	net.Conn
+	releaseOnce sync.Once
+	release     func()
+}
+
+// Close closes the connection and frees its slot
+func (c *limitConn) Close() error {
+	err := c.Conn.Close()
+	c.releaseOnce.Do(c.release)
+	return err
+}
@@ -0,0 +1,24 @@
+package server
+
+import (
+	"fmt"
+	"net"
+)
+
+// Listen opens a TCP listener on each address, sharing one connection limit
+// as LimitListeners does. If any address can't be bound, the listeners
+// already opened are closed.
+func Listen(addrs []string, max int, mode string) ([]net.Listener, error) {
+	listeners := make([]net.Listener, 0, len(addrs))
+	for _, addr := range addrs {
+		listener, err := net.Listen("tcp", addr)
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return nil, fmt.Errorf("failed to listen on %s: %w", addr, err)
+		}
+		listeners = append(listeners, listener)
+	}
+	return LimitListeners(listeners, max, mode), nil
+}
@@ -47,12 +47,13 @@ func (s *Server) Start() error {
 	
 	// Create base HTTP server
 	s.httpServer = &http.Server{
-		Addr:         s.config.ListenAddr,
-		Handler:      s.handler,
-		ReadTimeout:  s.config.ReadTimeout,
-		WriteTimeout: s.config.WriteTimeout,
-		IdleTimeout:  s.config.IdleTimeout,
-		ErrorLog:     nil, // Use our logger instead
+		Addr:           s.config.ListenAddr,
+		Handler:        s.handler,
+		ReadTimeout:    s.config.ReadTimeout,
+		WriteTimeout:   s.config.WriteTimeout,
+		IdleTimeout:    s.config.IdleTimeout,
+		MaxHeaderBytes: s.config.MaxHeaderBytes,
+		ErrorLog:       nil, // Use our logger instead
 	}
 	
 	// Configure TLS if enabled
@@ -76,6 +77,7 @@ func (s *Server) Start() error {
 	if err != nil {
 		return fmt.Errorf("failed to listen on %s: %w", s.config.ListenAddr, err)
 	}
+	listener = LimitListener(listener, s.config.MaxConnections, s.config.MaxConnectionsMode)
 	s.listeners = append(s.listeners, listener)
 	
 	// Start server
@@ -175,6 +177,10 @@ func (s *Server) createTLSConfig() (*tls.Config, error) {
 		tlsConfig.Certificates = []tls.Certificate{cert}
 	}
 	
+	if err := configureClientAuth(tlsConfig, s.config); err != nil {
+		return nil, err
+	}
+	
 	return tlsConfig, nil
 }
 
@@ -0,0 +1,71 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+
+	"discobox/internal/types"
+)
+
+// Shutdown stops an instance in dependency order, so requests still in
+// flight never find their dependencies gone:
+//
+//  1. Intake: each step stops accepting new work and waits for what it is
+//     serving to finish, e.g. http.Server.Shutdown. Steps run concurrently,
+//     so every listener closes at once rather than after the servers before
+//     it have drained, and the phase ends when the slowest step returns.
+//  2. Workers: background loops that read storage, such as health checks
+//     and storage watchers, are stopped.
+//  3. Storage is closed.
+//
+// A phase that fails or runs out of time is logged and the next one still
+// runs; Run returns every error it saw.
+type Shutdown struct {
+	Intake  []ShutdownStep
+	Workers []func()
+	Storage io.Closer
+	Logger  types.Logger
+}
+
+// ShutdownStep is one part of the intake phase
+type ShutdownStep struct {
+	Name string
+	Stop func(ctx context.Context) error
+}
+
+// Run performs the shutdown. ctx bounds how long intake may wait for
+// in-flight requests.
+func (s *Shutdown) Run(ctx context.Context) error {
+	errs := make([]error, len(s.Intake))
+
+	var wg sync.WaitGroup
+	for i, step := range s.Intake {
+		s.Logger.Info("Stopping intake", "step", step.Name)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := step.Stop(ctx); err != nil {
+				s.Logger.Error("Shutdown step failed", "step", step.Name, "error", err)
+				errs[i] = err
+			}
+		}()
+	}
+	wg.Wait()
+
+	s.Logger.Info("Stopping background workers", "count", len(s.Workers))
+	for _, stop := range s.Workers {
+		stop()
+	}
+
+	if s.Storage != nil {
+		s.Logger.Info("Closing storage")
+		if err := s.Storage.Close(); err != nil {
+			s.Logger.Error("Storage close failed", "error", err)
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
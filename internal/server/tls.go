@@ -1,9 +1,12 @@
 package server
 
 import (
+	"context"
 	"fmt"
+	"os"
 	
 	"crypto/tls"
+	"crypto/x509"
 	
 	"discobox/internal/types"
 )
@@ -34,6 +37,21 @@ func (tm *TLSManager) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certifica
 	return tm.certManager.GetCertificate(hello)
 }
 
+// ReloadCertificates reloads the static certificate from disk
+func (tm *TLSManager) ReloadCertificates() error {
+	return tm.certManager.ReloadCertificates()
+}
+
+// WatchCertificates reloads the static certificate when its files change
+func (tm *TLSManager) WatchCertificates(ctx context.Context) error {
+	return tm.certManager.WatchCertificates(ctx)
+}
+
+// Close cleans up the TLS manager
+func (tm *TLSManager) Close() error {
+	return tm.certManager.Close()
+}
+
 // CreateTLSConfig creates a TLS configuration
 func (tm *TLSManager) CreateTLSConfig() (*tls.Config, error) {
 	tlsConfig := &tls.Config{
@@ -53,7 +71,8 @@ func (tm *TLSManager) CreateTLSConfig() (*tls.Config, error) {
 			tls.CurveP384,
 		},
 		
-		// Certificate selection
+		// Certificate selection, resolved per handshake so reloads apply
+		// to new connections without a restart
 		GetCertificate: tm.GetCertificate,
 		
 		// Enable session tickets for performance
@@ -63,21 +82,38 @@ func (tm *TLSManager) CreateTLSConfig() (*tls.Config, error) {
 		ClientSessionCache: tls.NewLRUClientSessionCache(1000),
 	}
 	
-	// Load static certificates if not using ACME
-	if !tm.config.TLS.AutoCert && tm.config.TLS.CertFile != "" {
-		cert, err := tls.LoadX509KeyPair(tm.config.TLS.CertFile, tm.config.TLS.KeyFile)
-		if err != nil {
-			return nil, fmt.Errorf("failed to load certificate: %w", err)
-		}
-		tlsConfig.Certificates = []tls.Certificate{cert}
-	}
-	
 	// Configure NextProtos for ALPN
 	tlsConfig.NextProtos = []string{"h2", "http/1.1"}
 	
+	if err := configureClientAuth(tlsConfig, tm.config); err != nil {
+		return nil, err
+	}
+	
 	return tlsConfig, nil
 }
 
+// configureClientAuth asks clients for a certificate signed by the configured
+// CA bundle. Presenting one is optional; routes decide whether they need it.
+func configureClientAuth(tlsConfig *tls.Config, config *types.ProxyConfig) error {
+	if config.TLS.ClientCAFile == "" {
+		return nil
+	}
+	
+	pem, err := os.ReadFile(config.TLS.ClientCAFile)
+	if err != nil {
+		return fmt.Errorf("failed to read client CA file: %w", err)
+	}
+	
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return fmt.Errorf("no certificates found in client CA file %s", config.TLS.ClientCAFile)
+	}
+	
+	tlsConfig.ClientCAs = pool
+	tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	return nil
+}
+
 // getSecureCipherSuites returns a list of secure cipher suites
 func getSecureCipherSuites() []uint16 {
 	return []uint16{
@@ -116,6 +152,12 @@ func ValidateTLSConfig(config *types.ProxyConfig) error {
 		if config.TLS.CertFile == "" || config.TLS.KeyFile == "" {
 			return fmt.Errorf("cert_file and key_file are required when auto_cert is disabled")
 		}
+		
+		for i, cert := range config.TLS.Certificates {
+			if cert.CertFile == "" || cert.KeyFile == "" {
+				return fmt.Errorf("certificates[%d]: cert_file and key_file are required", i)
+			}
+		}
 	} else {
 		if len(config.TLS.Domains) == 0 {
 			return fmt.Errorf("at least one domain is required when auto_cert is enabled")
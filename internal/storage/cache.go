@@ -0,0 +1,347 @@
+package storage
+
+import (
+	"context"
+	"sync"
+
+	"discobox/internal/types"
+)
+
+// cachedStorage serves service and route reads from memory. Entries are
+// dropped when a change event arrives from the wrapped storage's Watch
+// stream, and straight away for writes made through the cache itself.
+// Everything else passes through to the wrapped storage. Reads always return
+// deep copies, so callers can't change what the cache or storage holds.
+type cachedStorage struct {
+	types.Storage
+
+	mu       sync.RWMutex
+	services map[string]*types.Service
+	routes   map[string]*types.Route
+	// Full listings, nil until loaded
+	serviceList []*types.Service
+	routeList   []*types.Route
+	// generation is bumped on every invalidation, so a read that raced with
+	// a change doesn't put the old value back in the cache
+	generation uint64
+	// stopped is set once change events stop arriving; reads then always
+	// go to the wrapped storage
+	stopped bool
+
+	watcherMu sync.Mutex
+	watchers  []chan types.StorageEvent
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// WithCache wraps s with an in-memory cache of services and routes, kept
+// fresh through s.Watch. Watchers of the cache are notified after the cache
+// has dropped the changed entries, so they never read stale data.
+func WithCache(s types.Storage) types.Storage {
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &cachedStorage{
+		Storage:  s,
+		services: make(map[string]*types.Service),
+		routes:   make(map[string]*types.Route),
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+
+	go c.watch(s.Watch(ctx))
+	return c
+}
+
+// watch invalidates entries as events arrive and passes the events on
+func (c *cachedStorage) watch(events <-chan types.StorageEvent) {
+	for event := range events {
+		switch event.Kind {
+		case "service":
+			c.invalidateService(event.ID)
+		case "route":
+			c.invalidateRoute(event.ID)
+		default:
+			c.invalidateAll()
+		}
+		c.notifyWatchers(event)
+	}
+
+	// The wrapped storage stopped delivering events; nothing cached can be
+	// trusted from here on
+	c.invalidateAll()
+	c.mu.Lock()
+	c.stopped = true
+	c.mu.Unlock()
+
+	c.watcherMu.Lock()
+	defer c.watcherMu.Unlock()
+	for _, watcher := range c.watchers {
+		close(watcher)
+	}
+	c.watchers = nil
+	close(c.done)
+}
+
+func (c *cachedStorage) invalidateService(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.services, id)
+	c.serviceList = nil
+	c.generation++
+}
+
+func (c *cachedStorage) invalidateRoute(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.routes, id)
+	c.routeList = nil
+	c.generation++
+}
+
+func (c *cachedStorage) invalidateAll() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.services = make(map[string]*types.Service)
+	c.routes = make(map[string]*types.Route)
+	c.serviceList = nil
+	c.routeList = nil
+	c.generation++
+}
+
+// currentGeneration returns the generation to check before caching a read
+func (c *cachedStorage) currentGeneration() uint64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.generation
+}
+
+// Services
+
+func (c *cachedStorage) GetService(ctx context.Context, id string) (*types.Service, error) {
+	c.mu.RLock()
+	service, ok := c.services[id]
+	c.mu.RUnlock()
+	if ok {
+		return service.Clone(), nil
+	}
+
+	generation := c.currentGeneration()
+	service, err := c.Storage.GetService(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	if c.generation == generation && !c.stopped {
+		c.services[id] = service.Clone()
+	}
+	c.mu.Unlock()
+
+	return service.Clone(), nil
+}
+
+func (c *cachedStorage) ListServices(ctx context.Context) ([]*types.Service, error) {
+	c.mu.RLock()
+	list := c.serviceList
+	c.mu.RUnlock()
+	if list != nil {
+		return copyServices(list), nil
+	}
+
+	generation := c.currentGeneration()
+	services, err := c.Storage.ListServices(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	if c.generation == generation && !c.stopped {
+		c.serviceList = copyServices(services)
+	}
+	c.mu.Unlock()
+
+	return copyServices(services), nil
+}
+
+func (c *cachedStorage) CreateService(ctx context.Context, service *types.Service) error {
+	err := c.Storage.CreateService(ctx, service)
+	if service != nil {
+		c.invalidateService(service.ID)
+	}
+	return err
+}
+
+func (c *cachedStorage) UpdateService(ctx context.Context, service *types.Service) error {
+	err := c.Storage.UpdateService(ctx, service)
+	if service != nil {
+		c.invalidateService(service.ID)
+	}
+	return err
+}
+
+func (c *cachedStorage) DeleteService(ctx context.Context, id string) error {
+	// Routes pointing at the service may go with it
+	defer c.invalidateAll()
+	return c.Storage.DeleteService(ctx, id)
+}
+
+// Tx runs fn in a transaction on the wrapped storage. Anything fn wrote may
+// be cached, so the whole cache is dropped once it finishes.
+func (c *cachedStorage) Tx(ctx context.Context, fn func(types.Storage) error) error {
+	defer c.invalidateAll()
+	return c.Storage.Tx(ctx, fn)
+}
+
+// Routes
+
+func (c *cachedStorage) GetRoute(ctx context.Context, id string) (*types.Route, error) {
+	c.mu.RLock()
+	route, ok := c.routes[id]
+	c.mu.RUnlock()
+	if ok {
+		return route.Clone(), nil
+	}
+
+	generation := c.currentGeneration()
+	route, err := c.Storage.GetRoute(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	if c.generation == generation && !c.stopped {
+		c.routes[id] = route.Clone()
+	}
+	c.mu.Unlock()
+
+	return route.Clone(), nil
+}
+
+func (c *cachedStorage) ListRoutes(ctx context.Context) ([]*types.Route, error) {
+	c.mu.RLock()
+	list := c.routeList
+	c.mu.RUnlock()
+	if list != nil {
+		return copyRoutes(list), nil
+	}
+
+	generation := c.currentGeneration()
+	routes, err := c.Storage.ListRoutes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	if c.generation == generation && !c.stopped {
+		c.routeList = copyRoutes(routes)
+	}
+	c.mu.Unlock()
+
+	return copyRoutes(routes), nil
+}
+
+func (c *cachedStorage) CreateRoute(ctx context.Context, route *types.Route) error {
+	err := c.Storage.CreateRoute(ctx, route)
+	if route != nil {
+		c.invalidateRoute(route.ID)
+	}
+	return err
+}
+
+func (c *cachedStorage) UpdateRoute(ctx context.Context, route *types.Route) error {
+	err := c.Storage.UpdateRoute(ctx, route)
+	if route != nil {
+		c.invalidateRoute(route.ID)
+	}
+	return err
+}
+
+func (c *cachedStorage) DeleteRoute(ctx context.Context, id string) error {
+	defer c.invalidateRoute(id)
+	return c.Storage.DeleteRoute(ctx, id)
+}
+
+func (c *cachedStorage) DeleteRouteGroup(ctx context.Context, group string) (int, error) {
+	defer c.invalidateAll()
+	return c.Storage.DeleteRouteGroup(ctx, group)
+}
+
+// Watch returns events from the wrapped storage, delivered once the cache
+// has dropped the entries they change
+func (c *cachedStorage) Watch(ctx context.Context) <-chan types.StorageEvent {
+	c.watcherMu.Lock()
+	defer c.watcherMu.Unlock()
+
+	ch := make(chan types.StorageEvent, 100)
+	select {
+	case <-c.done:
+		// No more events will arrive
+		close(ch)
+		return ch
+	default:
+	}
+	c.watchers = append(c.watchers, ch)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-c.done:
+			return
+		}
+
+		c.watcherMu.Lock()
+		defer c.watcherMu.Unlock()
+		for i, watcher := range c.watchers {
+			if watcher == ch {
+				c.watchers = append(c.watchers[:i], c.watchers[i+1:]...)
+				close(ch)
+				break
+			}
+		}
+	}()
+
+	return ch
+}
+
+// notifyWatchers sends an event to all registered watchers
+func (c *cachedStorage) notifyWatchers(event types.StorageEvent) {
+	c.watcherMu.Lock()
+	defer c.watcherMu.Unlock()
+
+	for _, watcher := range c.watchers {
+		select {
+		case watcher <- event:
+		default:
+			// Channel is full, drop the event
+		}
+	}
+}
+
+// Close stops watching and closes the wrapped storage
+func (c *cachedStorage) Close() error {
+	c.cancel()
+	return c.Storage.Close()
+}
+
+// copyServices deep-copies services, so neither the cache nor its callers
+// see changes the other makes
+func copyServices(services []*types.Service) []*types.Service {
+	copies := make([]*types.Service, len(services))
+	for i, service := range services {
+		copies[i] = service.Clone()
+	}
+	return copies
+}
+
+// copyRoutes deep-copies routes, so neither the cache nor its callers see
+// changes the other makes
+func copyRoutes(routes []*types.Route) []*types.Route {
+	copies := make([]*types.Route, len(routes))
+	for i, route := range routes {
+		copies[i] = route.Clone()
+	}
+	return copies
+}
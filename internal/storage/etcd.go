@@ -4,6 +4,7 @@ import (
 	"context"
 	"discobox/internal/types"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 	"sync"
@@ -15,10 +16,20 @@ import (
 // etcdStorage implements Storage interface using etcd
 type etcdStorage struct {
 	client    *clientv3.Client
+	kv        clientv3.KV // The client, or a transaction's journal of its writes
 	prefix    string
 	watchers  []chan types.StorageEvent
 	watcherMu sync.RWMutex
 	stopWatch chan struct{}
+	txMu      sync.Mutex
+	tx        *etcdTx // Set on the view handed to a Tx callback
+}
+
+// etcdTx tracks a transaction started by Tx
+type etcdTx struct {
+	root    *etcdStorage
+	journal *txJournal
+	events  []types.StorageEvent
 }
 
 // NewEtcd creates a new etcd storage instance
@@ -46,6 +57,7 @@ func NewEtcd(endpoints []string, prefix string) (types.Storage, error) {
 
 	s := &etcdStorage{
 		client:    client,
+		kv:        client,
 		prefix:    prefix,
 		watchers:  make([]chan types.StorageEvent, 0),
 		stopWatch: make(chan struct{}),
@@ -61,7 +73,7 @@ func NewEtcd(endpoints []string, prefix string) (types.Storage, error) {
 
 func (s *etcdStorage) GetService(ctx context.Context, id string) (*types.Service, error) {
 	key := s.serviceKey(id)
-	resp, err := s.client.Get(ctx, key)
+	resp, err := s.kv.Get(ctx, key)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get service: %w", err)
 	}
@@ -80,7 +92,7 @@ func (s *etcdStorage) GetService(ctx context.Context, id string) (*types.Service
 
 func (s *etcdStorage) ListServices(ctx context.Context) ([]*types.Service, error) {
 	prefix := s.prefix + "/services/"
-	resp, err := s.client.Get(ctx, prefix, clientv3.WithPrefix())
+	resp, err := s.kv.Get(ctx, prefix, clientv3.WithPrefix())
 	if err != nil {
 		return nil, fmt.Errorf("failed to list services: %w", err)
 	}
@@ -101,7 +113,7 @@ func (s *etcdStorage) CreateService(ctx context.Context, service *types.Service)
 	key := s.serviceKey(service.ID)
 
 	// Check if already exists
-	resp, err := s.client.Get(ctx, key)
+	resp, err := s.kv.Get(ctx, key)
 	if err != nil {
 		return fmt.Errorf("failed to check service existence: %w", err)
 	}
@@ -113,6 +125,7 @@ func (s *etcdStorage) CreateService(ctx context.Context, service *types.Service)
 	now := time.Now()
 	service.CreatedAt = now
 	service.UpdatedAt = now
+	service.Version = 1
 
 	// Marshal service
 	data, err := json.Marshal(service)
@@ -121,7 +134,7 @@ func (s *etcdStorage) CreateService(ctx context.Context, service *types.Service)
 	}
 
 	// Create service
-	if _, err := s.client.Put(ctx, key, string(data)); err != nil {
+	if _, err := s.kv.Put(ctx, key, string(data)); err != nil {
 		return fmt.Errorf("failed to create service: %w", err)
 	}
 
@@ -140,7 +153,7 @@ func (s *etcdStorage) UpdateService(ctx context.Context, service *types.Service)
 	key := s.serviceKey(service.ID)
 
 	// Check if exists
-	resp, err := s.client.Get(ctx, key)
+	resp, err := s.kv.Get(ctx, key)
 	if err != nil {
 		return fmt.Errorf("failed to check service existence: %w", err)
 	}
@@ -148,12 +161,19 @@ func (s *etcdStorage) UpdateService(ctx context.Context, service *types.Service)
 		return types.ErrServiceNotFound
 	}
 
-	// Preserve created timestamp
 	var existing types.Service
-	if err := json.Unmarshal(resp.Kvs[0].Value, &existing); err == nil {
-		service.CreatedAt = existing.CreatedAt
+	if err := json.Unmarshal(resp.Kvs[0].Value, &existing); err != nil {
+		return fmt.Errorf("failed to unmarshal service: %w", err)
 	}
 
+	if service.Version != 0 && service.Version != existing.Version {
+		return types.ErrVersionConflict
+	}
+
+	// Preserve created timestamp
+	service.CreatedAt = existing.CreatedAt
+	service.Version = existing.Version + 1
+
 	// Update timestamp
 	service.UpdatedAt = time.Now()
 
@@ -163,9 +183,9 @@ func (s *etcdStorage) UpdateService(ctx context.Context, service *types.Service)
 		return fmt.Errorf("failed to marshal service: %w", err)
 	}
 
-	// Update service
-	if _, err := s.client.Put(ctx, key, string(data)); err != nil {
-		return fmt.Errorf("failed to update service: %w", err)
+	// Update service only if nobody else wrote it since we read it
+	if err := s.putIfUnchanged(ctx, key, string(data), resp.Kvs[0].ModRevision); err != nil {
+		return err
 	}
 
 	// Notify watchers
@@ -180,10 +200,18 @@ func (s *etcdStorage) UpdateService(ctx context.Context, service *types.Service)
 }
 
 func (s *etcdStorage) DeleteService(ctx context.Context, id string) error {
+	// Routes go with their service, so the deletes share a transaction
+	return s.Tx(ctx, func(tx types.Storage) error {
+		return tx.(*etcdStorage).deleteService(ctx, id)
+	})
+}
+
+// deleteService removes a service and the routes that reference it
+func (s *etcdStorage) deleteService(ctx context.Context, id string) error {
 	key := s.serviceKey(id)
 
 	// Check if service exists
-	resp, err := s.client.Get(ctx, key)
+	resp, err := s.kv.Get(ctx, key)
 	if err != nil {
 		return fmt.Errorf("failed to check service existence: %w", err)
 	}
@@ -192,15 +220,20 @@ func (s *etcdStorage) DeleteService(ctx context.Context, id string) error {
 	}
 
 	// Delete all routes for this service
-	routes, _ := s.ListRoutes(ctx)
+	routes, err := s.ListRoutes(ctx)
+	if err != nil {
+		return err
+	}
 	for _, route := range routes {
 		if route.ServiceID == id {
-			s.DeleteRoute(ctx, route.ID)
+			if err := s.DeleteRoute(ctx, route.ID); err != nil && !errors.Is(err, types.ErrRouteNotFound) {
+				return err
+			}
 		}
 	}
 
 	// Delete service
-	resp2, err := s.client.Delete(ctx, key)
+	resp2, err := s.kv.Delete(ctx, key)
 	if err != nil {
 		return fmt.Errorf("failed to delete service: %w", err)
 	}
@@ -223,7 +256,7 @@ func (s *etcdStorage) DeleteService(ctx context.Context, id string) error {
 
 func (s *etcdStorage) GetRoute(ctx context.Context, id string) (*types.Route, error) {
 	key := s.routeKey(id)
-	resp, err := s.client.Get(ctx, key)
+	resp, err := s.kv.Get(ctx, key)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get route: %w", err)
 	}
@@ -242,7 +275,7 @@ func (s *etcdStorage) GetRoute(ctx context.Context, id string) (*types.Route, er
 
 func (s *etcdStorage) ListRoutes(ctx context.Context) ([]*types.Route, error) {
 	prefix := s.prefix + "/routes/"
-	resp, err := s.client.Get(ctx, prefix, clientv3.WithPrefix())
+	resp, err := s.kv.Get(ctx, prefix, clientv3.WithPrefix())
 	if err != nil {
 		return nil, fmt.Errorf("failed to list routes: %w", err)
 	}
@@ -272,7 +305,7 @@ func (s *etcdStorage) CreateRoute(ctx context.Context, route *types.Route) error
 	key := s.routeKey(route.ID)
 
 	// Check if already exists
-	resp, err := s.client.Get(ctx, key)
+	resp, err := s.kv.Get(ctx, key)
 	if err != nil {
 		return fmt.Errorf("failed to check route existence: %w", err)
 	}
@@ -285,6 +318,8 @@ func (s *etcdStorage) CreateRoute(ctx context.Context, route *types.Route) error
 		return fmt.Errorf("service %s not found", route.ServiceID)
 	}
 
+	route.Version = 1
+
 	// Marshal route
 	data, err := json.Marshal(route)
 	if err != nil {
@@ -292,7 +327,7 @@ func (s *etcdStorage) CreateRoute(ctx context.Context, route *types.Route) error
 	}
 
 	// Create route
-	if _, err := s.client.Put(ctx, key, string(data)); err != nil {
+	if _, err := s.kv.Put(ctx, key, string(data)); err != nil {
 		return fmt.Errorf("failed to create route: %w", err)
 	}
 
@@ -311,7 +346,7 @@ func (s *etcdStorage) UpdateRoute(ctx context.Context, route *types.Route) error
 	key := s.routeKey(route.ID)
 
 	// Check if exists
-	resp, err := s.client.Get(ctx, key)
+	resp, err := s.kv.Get(ctx, key)
 	if err != nil {
 		return fmt.Errorf("failed to check route existence: %w", err)
 	}
@@ -319,7 +354,15 @@ func (s *etcdStorage) UpdateRoute(ctx context.Context, route *types.Route) error
 		return types.ErrRouteNotFound
 	}
 
-	// Route doesn't have timestamps, just update directly
+	var existing types.Route
+	if err := json.Unmarshal(resp.Kvs[0].Value, &existing); err != nil {
+		return fmt.Errorf("failed to unmarshal route: %w", err)
+	}
+
+	if route.Version != 0 && route.Version != existing.Version {
+		return types.ErrVersionConflict
+	}
+	route.Version = existing.Version + 1
 
 	// Marshal route
 	data, err := json.Marshal(route)
@@ -327,9 +370,9 @@ func (s *etcdStorage) UpdateRoute(ctx context.Context, route *types.Route) error
 		return fmt.Errorf("failed to marshal route: %w", err)
 	}
 
-	// Update route
-	if _, err := s.client.Put(ctx, key, string(data)); err != nil {
-		return fmt.Errorf("failed to update route: %w", err)
+	// Update route only if nobody else wrote it since we read it
+	if err := s.putIfUnchanged(ctx, key, string(data), resp.Kvs[0].ModRevision); err != nil {
+		return err
 	}
 
 	// Notify watchers
@@ -347,7 +390,7 @@ func (s *etcdStorage) DeleteRoute(ctx context.Context, id string) error {
 	key := s.routeKey(id)
 
 	// Delete route
-	resp, err := s.client.Delete(ctx, key)
+	resp, err := s.kv.Delete(ctx, key)
 	if err != nil {
 		return fmt.Errorf("failed to delete route: %w", err)
 	}
@@ -366,9 +409,82 @@ func (s *etcdStorage) DeleteRoute(ctx context.Context, id string) error {
 	return nil
 }
 
+func (s *etcdStorage) ListRoutesByGroup(ctx context.Context, group string) ([]*types.Route, error) {
+	routes, err := s.ListRoutes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	grouped := make([]*types.Route, 0)
+	for _, route := range routes {
+		if route.Group == group {
+			grouped = append(grouped, route)
+		}
+	}
+
+	return grouped, nil
+}
+
+func (s *etcdStorage) DeleteRouteGroup(ctx context.Context, group string) (int, error) {
+	if group == "" {
+		return 0, types.ErrInvalidRequest
+	}
+
+	prefix := s.prefix + "/routes/"
+	resp, err := s.kv.Get(ctx, prefix, clientv3.WithPrefix())
+	if err != nil {
+		return 0, fmt.Errorf("failed to list routes: %w", err)
+	}
+
+	var ids []string
+	var cmps []clientv3.Cmp
+	var ops []clientv3.Op
+	for _, kv := range resp.Kvs {
+		var route types.Route
+		if err := json.Unmarshal(kv.Value, &route); err != nil {
+			continue // Skip invalid entries
+		}
+		if route.Group != group {
+			continue
+		}
+
+		// Only delete routes that haven't changed since we read them
+		ids = append(ids, route.ID)
+		cmps = append(cmps, clientv3.Compare(clientv3.ModRevision(string(kv.Key)), "=", kv.ModRevision))
+		ops = append(ops, clientv3.OpDelete(string(kv.Key)))
+	}
+
+	if len(ops) == 0 {
+		return 0, nil
+	}
+
+	txnResp, err := s.kv.Txn(ctx).If(cmps...).Then(ops...).Commit()
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete route group: %w", err)
+	}
+	if !txnResp.Succeeded {
+		return 0, types.ErrVersionConflict
+	}
+
+	// Notify watchers
+	for _, id := range ids {
+		s.notifyWatchers(types.StorageEvent{
+			Type: "deleted",
+			Kind: "route",
+			ID:   id,
+		})
+	}
+
+	return len(ids), nil
+}
+
 // Watch for changes
 
 func (s *etcdStorage) Watch(ctx context.Context) <-chan types.StorageEvent {
+	if s.tx != nil {
+		return s.tx.root.Watch(ctx)
+	}
+
 	ch := make(chan types.StorageEvent, 100)
 
 	s.watcherMu.Lock()
@@ -484,6 +600,12 @@ func (s *etcdStorage) handleWatchEvent(event *clientv3.Event) {
 
 // notifyWatchers sends event to all watchers
 func (s *etcdStorage) notifyWatchers(event types.StorageEvent) {
+	// Events raised in a transaction wait until it commits
+	if s.tx != nil {
+		s.tx.events = append(s.tx.events, event)
+		return
+	}
+
 	s.watcherMu.RLock()
 	defer s.watcherMu.RUnlock()
 
@@ -497,8 +619,218 @@ func (s *etcdStorage) notifyWatchers(event types.StorageEvent) {
 	}
 }
 
+// Tx emulates a transaction. Transactions are serialized with a lock and
+// fn's writes go straight to etcd, journaled with the values they replace.
+// If fn fails, each key fn wrote is put back the way it was, unless
+// something else has written it since; writes made outside the transaction
+// are left alone. The etcd watch reports both the writes and their reversal.
+func (s *etcdStorage) Tx(ctx context.Context, fn func(types.Storage) error) error {
+	if s.tx != nil {
+		return fn(s)
+	}
+
+	s.txMu.Lock()
+	defer s.txMu.Unlock()
+
+	journal := &txJournal{KV: s.kv, writes: make(map[string]*txWrite)}
+	view := &etcdStorage{
+		client: s.client,
+		kv:     journal,
+		prefix: s.prefix,
+		tx:     &etcdTx{root: s, journal: journal},
+	}
+	if err := fn(view); err != nil {
+		// Roll back even if the caller's context has been cancelled
+		if rollbackErr := journal.rollback(context.WithoutCancel(ctx)); rollbackErr != nil {
+			return fmt.Errorf("%w (rollback failed: %v)", err, rollbackErr)
+		}
+		return err
+	}
+
+	for _, event := range view.tx.events {
+		s.notifyWatchers(event)
+	}
+	return nil
+}
+
+// txJournal is the KV a transaction writes through. Before a key is first
+// written it records the key's value, and after each write the revision the
+// write left it at, so a rollback can tell whether the key is still as the
+// transaction left it.
+type txJournal struct {
+	clientv3.KV
+	mu     sync.Mutex
+	writes map[string]*txWrite
+}
+
+// txWrite is a key written in a transaction
+type txWrite struct {
+	prev     []byte // Value before the transaction's first write
+	existed  bool
+	revision int64 // Mod revision after the last write; 0 once deleted
+}
+
+func (j *txJournal) Put(ctx context.Context, key, val string, opts ...clientv3.OpOption) (*clientv3.PutResponse, error) {
+	op := clientv3.OpPut(key, val, opts...)
+	keys, err := j.before(ctx, op)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := j.KV.Put(ctx, key, val, opts...)
+	if err == nil {
+		j.after([]clientv3.Op{op}, keys, resp.Header.Revision)
+	}
+	return resp, err
+}
+
+func (j *txJournal) Delete(ctx context.Context, key string, opts ...clientv3.OpOption) (*clientv3.DeleteResponse, error) {
+	op := clientv3.OpDelete(key, opts...)
+	keys, err := j.before(ctx, op)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := j.KV.Delete(ctx, key, opts...)
+	if err == nil {
+		j.after([]clientv3.Op{op}, keys, resp.Header.Revision)
+	}
+	return resp, err
+}
+
+func (j *txJournal) Txn(ctx context.Context) clientv3.Txn {
+	return &journaledTxn{Txn: j.KV.Txn(ctx), journal: j, ctx: ctx}
+}
+
+// before records the current values of the keys ops write that the
+// journal hasn't seen yet, and returns the keys each op writes
+func (j *txJournal) before(ctx context.Context, ops ...clientv3.Op) ([][]string, error) {
+	keys := make([][]string, len(ops))
+	for i, op := range ops {
+		if !op.IsPut() && !op.IsDelete() {
+			continue
+		}
+
+		key := string(op.KeyBytes())
+		var getOpts []clientv3.OpOption
+		if end := op.RangeBytes(); len(end) > 0 {
+			getOpts = append(getOpts, clientv3.WithRange(string(end)))
+		}
+		resp, err := j.KV.Get(ctx, key, getOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to journal transaction write: %w", err)
+		}
+
+		j.mu.Lock()
+		for _, kv := range resp.Kvs {
+			keys[i] = append(keys[i], string(kv.Key))
+			if _, seen := j.writes[string(kv.Key)]; !seen {
+				j.writes[string(kv.Key)] = &txWrite{prev: kv.Value, existed: true, revision: kv.ModRevision}
+			}
+		}
+		if op.IsPut() && len(resp.Kvs) == 0 {
+			keys[i] = append(keys[i], key)
+			if _, seen := j.writes[key]; !seen {
+				j.writes[key] = &txWrite{}
+			}
+		}
+		j.mu.Unlock()
+	}
+	return keys, nil
+}
+
+// after records the revision ops left their keys at
+func (j *txJournal) after(ops []clientv3.Op, keys [][]string, revision int64) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	for i, op := range ops {
+		for _, key := range keys[i] {
+			if op.IsPut() {
+				j.writes[key].revision = revision
+			} else if op.IsDelete() {
+				j.writes[key].revision = 0
+			}
+		}
+	}
+}
+
+// rollback puts back every journaled key still as the transaction left it,
+// in a single etcd transaction. A key's mod revision is 0 while it doesn't
+// exist, so deleted keys are compared the same way.
+func (j *txJournal) rollback(ctx context.Context) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	var ops []clientv3.Op
+	for key, w := range j.writes {
+		restore := clientv3.OpDelete(key)
+		if w.existed {
+			restore = clientv3.OpPut(key, string(w.prev))
+		}
+		unchanged := clientv3.Compare(clientv3.ModRevision(key), "=", w.revision)
+		ops = append(ops, clientv3.OpTxn([]clientv3.Cmp{unchanged}, []clientv3.Op{restore}, nil))
+	}
+
+	if len(ops) == 0 {
+		return nil
+	}
+	_, err := j.KV.Txn(ctx).Then(ops...).Commit()
+	return err
+}
+
+// journaledTxn journals the writes of an etcd transaction through its
+// journal
+type journaledTxn struct {
+	clientv3.Txn
+	journal *txJournal
+	ctx     context.Context
+	thenOps []clientv3.Op
+	elseOps []clientv3.Op
+}
+
+func (t *journaledTxn) If(cs ...clientv3.Cmp) clientv3.Txn {
+	t.Txn = t.Txn.If(cs...)
+	return t
+}
+
+func (t *journaledTxn) Then(ops ...clientv3.Op) clientv3.Txn {
+	t.Txn = t.Txn.Then(ops...)
+	t.thenOps = append(t.thenOps, ops...)
+	return t
+}
+
+func (t *journaledTxn) Else(ops ...clientv3.Op) clientv3.Txn {
+	t.Txn = t.Txn.Else(ops...)
+	t.elseOps = append(t.elseOps, ops...)
+	return t
+}
+
+func (t *journaledTxn) Commit() (*clientv3.TxnResponse, error) {
+	ops := append(append([]clientv3.Op{}, t.thenOps...), t.elseOps...)
+	keys, err := t.journal.before(t.ctx, ops...)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := t.Txn.Commit()
+	if err != nil {
+		return resp, err
+	}
+	if resp.Succeeded {
+		t.journal.after(t.thenOps, keys[:len(t.thenOps)], resp.Header.Revision)
+	} else {
+		t.journal.after(t.elseOps, keys[len(t.thenOps):], resp.Header.Revision)
+	}
+	return resp, nil
+}
+
 // Close closes the etcd connection
 func (s *etcdStorage) Close() error {
+	if s.tx != nil {
+		return errors.New("cannot close storage inside a transaction")
+	}
+
 	close(s.stopWatch)
 	return s.client.Close()
 }
@@ -507,13 +839,13 @@ func (s *etcdStorage) Close() error {
 
 func (s *etcdStorage) GetUser(ctx context.Context, id string) (*types.User, error) {
 	key := s.userKey(id)
-	resp, err := s.client.Get(ctx, key)
+	resp, err := s.kv.Get(ctx, key)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
 
 	if len(resp.Kvs) == 0 {
-		return nil, fmt.Errorf("user not found")
+		return nil, types.ErrUserNotFound
 	}
 
 	var user types.User
@@ -540,9 +872,29 @@ func (s *etcdStorage) GetUserByUsername(ctx context.Context, username string) (*
 	return nil, fmt.Errorf("user not found")
 }
 
+func (s *etcdStorage) GetUserByEmail(ctx context.Context, email string) (*types.User, error) {
+	if email == "" {
+		return nil, fmt.Errorf("user not found")
+	}
+
+	// List all users and find by email
+	users, err := s.ListUsers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, user := range users {
+		if strings.EqualFold(user.Email, email) {
+			return user, nil
+		}
+	}
+
+	return nil, fmt.Errorf("user not found")
+}
+
 func (s *etcdStorage) ListUsers(ctx context.Context) ([]*types.User, error) {
 	prefix := s.prefix + "/users/"
-	resp, err := s.client.Get(ctx, prefix, clientv3.WithPrefix())
+	resp, err := s.kv.Get(ctx, prefix, clientv3.WithPrefix())
 	if err != nil {
 		return nil, fmt.Errorf("failed to list users: %w", err)
 	}
@@ -563,18 +915,18 @@ func (s *etcdStorage) CreateUser(ctx context.Context, user *types.User) error {
 	key := s.userKey(user.ID)
 
 	// Check if already exists
-	resp, err := s.client.Get(ctx, key)
+	resp, err := s.kv.Get(ctx, key)
 	if err != nil {
 		return fmt.Errorf("failed to check user existence: %w", err)
 	}
 	if len(resp.Kvs) > 0 {
-		return fmt.Errorf("user already exists")
+		return fmt.Errorf("user already exists: %w", types.ErrAlreadyExists)
 	}
 
 	// Check for duplicate username
 	existing, _ := s.GetUserByUsername(ctx, user.Username)
 	if existing != nil {
-		return fmt.Errorf("username already exists")
+		return fmt.Errorf("username already taken: %w", types.ErrAlreadyExists)
 	}
 
 	// Set timestamps
@@ -589,7 +941,7 @@ func (s *etcdStorage) CreateUser(ctx context.Context, user *types.User) error {
 	}
 
 	// Create user
-	if _, err := s.client.Put(ctx, key, string(data)); err != nil {
+	if _, err := s.kv.Put(ctx, key, string(data)); err != nil {
 		return fmt.Errorf("failed to create user: %w", err)
 	}
 
@@ -600,7 +952,7 @@ func (s *etcdStorage) UpdateUser(ctx context.Context, user *types.User) error {
 	key := s.userKey(user.ID)
 
 	// Check if exists
-	resp, err := s.client.Get(ctx, key)
+	resp, err := s.kv.Get(ctx, key)
 	if err != nil {
 		return fmt.Errorf("failed to check user existence: %w", err)
 	}
@@ -624,7 +976,7 @@ func (s *etcdStorage) UpdateUser(ctx context.Context, user *types.User) error {
 	}
 
 	// Update user
-	if _, err := s.client.Put(ctx, key, string(data)); err != nil {
+	if _, err := s.kv.Put(ctx, key, string(data)); err != nil {
 		return fmt.Errorf("failed to update user: %w", err)
 	}
 
@@ -635,7 +987,7 @@ func (s *etcdStorage) DeleteUser(ctx context.Context, id string) error {
 	key := s.userKey(id)
 
 	// Delete user
-	resp, err := s.client.Delete(ctx, key)
+	resp, err := s.kv.Delete(ctx, key)
 	if err != nil {
 		return fmt.Errorf("failed to delete user: %w", err)
 	}
@@ -657,13 +1009,13 @@ func (s *etcdStorage) DeleteUser(ctx context.Context, id string) error {
 
 func (s *etcdStorage) GetAPIKey(ctx context.Context, key string) (*types.APIKey, error) {
 	keyPath := s.apiKeyKey(key)
-	resp, err := s.client.Get(ctx, keyPath)
+	resp, err := s.kv.Get(ctx, keyPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get API key: %w", err)
 	}
 
 	if len(resp.Kvs) == 0 {
-		return nil, fmt.Errorf("API key not found")
+		return nil, types.ErrAPIKeyNotFound
 	}
 
 	var apiKey types.APIKey
@@ -675,14 +1027,34 @@ func (s *etcdStorage) GetAPIKey(ctx context.Context, key string) (*types.APIKey,
 	now := time.Now()
 	apiKey.LastUsedAt = &now
 	data, _ := json.Marshal(apiKey)
-	s.client.Put(ctx, keyPath, string(data))
+	s.kv.Put(ctx, keyPath, string(data))
 
 	return &apiKey, nil
 }
 
+func (s *etcdStorage) ListAPIKeys(ctx context.Context) ([]*types.APIKey, error) {
+	prefix := s.prefix + "/api_keys/"
+	resp, err := s.kv.Get(ctx, prefix, clientv3.WithPrefix())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list API keys: %w", err)
+	}
+
+	apiKeys := make([]*types.APIKey, 0, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		var apiKey types.APIKey
+		if err := json.Unmarshal(kv.Value, &apiKey); err != nil {
+			continue // Skip invalid entries
+		}
+		apiKeys = append(apiKeys, &apiKey)
+	}
+
+	sortAPIKeys(apiKeys)
+	return apiKeys, nil
+}
+
 func (s *etcdStorage) ListAPIKeysByUser(ctx context.Context, userID string) ([]*types.APIKey, error) {
 	prefix := s.prefix + "/api_keys/"
-	resp, err := s.client.Get(ctx, prefix, clientv3.WithPrefix())
+	resp, err := s.kv.Get(ctx, prefix, clientv3.WithPrefix())
 	if err != nil {
 		return nil, fmt.Errorf("failed to list API keys: %w", err)
 	}
@@ -705,7 +1077,7 @@ func (s *etcdStorage) CreateAPIKey(ctx context.Context, apiKey *types.APIKey) er
 	key := s.apiKeyKey(apiKey.Key)
 
 	// Check if already exists
-	resp, err := s.client.Get(ctx, key)
+	resp, err := s.kv.Get(ctx, key)
 	if err != nil {
 		return fmt.Errorf("failed to check API key existence: %w", err)
 	}
@@ -729,7 +1101,7 @@ func (s *etcdStorage) CreateAPIKey(ctx context.Context, apiKey *types.APIKey) er
 	}
 
 	// Create API key
-	if _, err := s.client.Put(ctx, key, string(data)); err != nil {
+	if _, err := s.kv.Put(ctx, key, string(data)); err != nil {
 		return fmt.Errorf("failed to create API key: %w", err)
 	}
 
@@ -740,7 +1112,7 @@ func (s *etcdStorage) RevokeAPIKey(ctx context.Context, key string) error {
 	keyPath := s.apiKeyKey(key)
 
 	// Get API key
-	resp, err := s.client.Get(ctx, keyPath)
+	resp, err := s.kv.Get(ctx, keyPath)
 	if err != nil {
 		return fmt.Errorf("failed to get API key: %w", err)
 	}
@@ -757,15 +1129,152 @@ func (s *etcdStorage) RevokeAPIKey(ctx context.Context, key string) error {
 	// Mark as inactive
 	apiKey.Active = false
 	data, _ := json.Marshal(apiKey)
-	if _, err := s.client.Put(ctx, keyPath, string(data)); err != nil {
+	if _, err := s.kv.Put(ctx, keyPath, string(data)); err != nil {
 		return fmt.Errorf("failed to revoke API key: %w", err)
 	}
 
 	return nil
 }
 
+func (s *etcdStorage) RevokeAllAPIKeysByUser(ctx context.Context, userID string) (int, error) {
+	prefix := s.prefix + "/api_keys/"
+	resp, err := s.kv.Get(ctx, prefix, clientv3.WithPrefix())
+	if err != nil {
+		return 0, fmt.Errorf("failed to list API keys: %w", err)
+	}
+
+	var cmps []clientv3.Cmp
+	var ops []clientv3.Op
+	for _, kv := range resp.Kvs {
+		var apiKey types.APIKey
+		if err := json.Unmarshal(kv.Value, &apiKey); err != nil {
+			continue // Skip invalid entries
+		}
+		if apiKey.UserID != userID || !apiKey.Active {
+			continue
+		}
+
+		apiKey.Active = false
+		data, err := json.Marshal(apiKey)
+		if err != nil {
+			return 0, fmt.Errorf("failed to marshal API key: %w", err)
+		}
+
+		// Only revoke keys that haven't changed since we read them
+		cmps = append(cmps, clientv3.Compare(clientv3.ModRevision(string(kv.Key)), "=", kv.ModRevision))
+		ops = append(ops, clientv3.OpPut(string(kv.Key), string(data)))
+	}
+
+	if len(ops) == 0 {
+		return 0, nil
+	}
+
+	txnResp, err := s.kv.Txn(ctx).If(cmps...).Then(ops...).Commit()
+	if err != nil {
+		return 0, fmt.Errorf("failed to revoke API keys: %w", err)
+	}
+	if !txnResp.Succeeded {
+		return 0, types.ErrVersionConflict
+	}
+
+	return len(ops), nil
+}
+
+// Password reset tokens implementation
+
+func (s *etcdStorage) CreatePasswordResetToken(ctx context.Context, token *types.PasswordResetToken) error {
+	if token == nil {
+		return types.ErrInvalidRequest
+	}
+
+	// Only the newest token for a user stays valid
+	prefix := s.prefix + "/reset_tokens/"
+	resp, err := s.kv.Get(ctx, prefix, clientv3.WithPrefix())
+	if err != nil {
+		return fmt.Errorf("failed to list reset tokens: %w", err)
+	}
+
+	ops := make([]clientv3.Op, 0, len(resp.Kvs)+1)
+	for _, kv := range resp.Kvs {
+		var existing types.PasswordResetToken
+		if err := json.Unmarshal(kv.Value, &existing); err != nil {
+			continue // Skip invalid entries
+		}
+		if existing.UserID == token.UserID {
+			ops = append(ops, clientv3.OpDelete(string(kv.Key)))
+		}
+	}
+
+	token.CreatedAt = time.Now()
+
+	data, err := json.Marshal(token)
+	if err != nil {
+		return fmt.Errorf("failed to marshal reset token: %w", err)
+	}
+	ops = append(ops, clientv3.OpPut(s.resetTokenKey(token.TokenHash), string(data)))
+
+	if _, err := s.kv.Txn(ctx).Then(ops...).Commit(); err != nil {
+		return fmt.Errorf("failed to create reset token: %w", err)
+	}
+
+	return nil
+}
+
+func (s *etcdStorage) ConsumePasswordResetToken(ctx context.Context, tokenHash string) (*types.PasswordResetToken, error) {
+	key := s.resetTokenKey(tokenHash)
+
+	resp, err := s.kv.Get(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reset token: %w", err)
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, types.ErrInvalidToken
+	}
+
+	var token types.PasswordResetToken
+	if err := json.Unmarshal(resp.Kvs[0].Value, &token); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal reset token: %w", err)
+	}
+
+	now := time.Now()
+	if token.UsedAt != nil || now.After(token.ExpiresAt) {
+		return nil, types.ErrInvalidToken
+	}
+
+	token.UsedAt = &now
+	data, err := json.Marshal(token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal reset token: %w", err)
+	}
+
+	// A concurrent consumer that got there first wins
+	if err := s.putIfUnchanged(ctx, key, string(data), resp.Kvs[0].ModRevision); err != nil {
+		if errors.Is(err, types.ErrVersionConflict) {
+			return nil, types.ErrInvalidToken
+		}
+		return nil, err
+	}
+
+	return &token, nil
+}
+
 // Helper methods
 
+// putIfUnchanged writes value only if key still has the given mod revision
+func (s *etcdStorage) putIfUnchanged(ctx context.Context, key, value string, modRevision int64) error {
+	resp, err := s.kv.Txn(ctx).
+		If(clientv3.Compare(clientv3.ModRevision(key), "=", modRevision)).
+		Then(clientv3.OpPut(key, value)).
+		Commit()
+	if err != nil {
+		return fmt.Errorf("failed to update %s: %w", key, err)
+	}
+	if !resp.Succeeded {
+		return types.ErrVersionConflict
+	}
+	return nil
+}
+
 func (s *etcdStorage) serviceKey(id string) string {
 	return fmt.Sprintf("%s/services/%s", s.prefix, id)
 }
@@ -781,3 +1290,7 @@ func (s *etcdStorage) userKey(id string) string {
 func (s *etcdStorage) apiKeyKey(key string) string {
 	return fmt.Sprintf("%s/api_keys/%s", s.prefix, key)
 }
+
+func (s *etcdStorage) resetTokenKey(tokenHash string) string {
+	return fmt.Sprintf("%s/reset_tokens/%s", s.prefix, tokenHash)
+}
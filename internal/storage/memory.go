@@ -3,6 +3,10 @@ package storage
 import (
 	"context"
 	"errors"
+	"fmt"
+	"maps"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -16,9 +20,18 @@ type memoryStorage struct {
 	routes    map[string]*types.Route
 	users     map[string]*types.User
 	usernames map[string]string // username -> userID mapping
+	emails    map[string]string // lowercased email -> userID mapping
 	apiKeys   map[string]*types.APIKey
+	resets    map[string]*types.PasswordResetToken // token hash -> token
 	watchers  []chan types.StorageEvent
 	watcherMu sync.RWMutex
+	tx        *memoryTx // Set on the copy handed to a Tx callback
+}
+
+// memoryTx tracks a transaction started by Tx
+type memoryTx struct {
+	root   *memoryStorage
+	events []types.StorageEvent
 }
 
 // NewMemory creates a new in-memory storage instance
@@ -28,7 +41,9 @@ func NewMemory() types.Storage {
 		routes:    make(map[string]*types.Route),
 		users:     make(map[string]*types.User),
 		usernames: make(map[string]string),
+		emails:    make(map[string]string),
 		apiKeys:   make(map[string]*types.APIKey),
+		resets:    make(map[string]*types.PasswordResetToken),
 		watchers:  make([]chan types.StorageEvent, 0),
 	}
 }
@@ -79,6 +94,7 @@ func (m *memoryStorage) CreateService(ctx context.Context, service *types.Servic
 	now := time.Now()
 	service.CreatedAt = now
 	service.UpdatedAt = now
+	service.Version = 1
 	
 	// Create a copy to store
 	serviceCopy := *service
@@ -108,6 +124,11 @@ func (m *memoryStorage) UpdateService(ctx context.Context, service *types.Servic
 		return types.ErrServiceNotFound
 	}
 	
+	if service.Version != 0 && service.Version != existing.Version {
+		return types.ErrVersionConflict
+	}
+	service.Version = existing.Version + 1
+	
 	// Update timestamp
 	service.UpdatedAt = time.Now()
 	// Preserve creation timestamp
@@ -197,6 +218,7 @@ func (m *memoryStorage) ListRoutes(ctx context.Context) ([]*types.Route, error)
 		routes = append(routes, &routeCopy)
 	}
 	
+	sortRoutes(routes)
 	return routes, nil
 }
 
@@ -217,6 +239,8 @@ func (m *memoryStorage) CreateRoute(ctx context.Context, route *types.Route) err
 		return errors.New("service not found for route")
 	}
 	
+	route.Version = 1
+	
 	// Create a copy to store
 	routeCopy := *route
 	m.routes[route.ID] = &routeCopy
@@ -240,7 +264,8 @@ func (m *memoryStorage) UpdateRoute(ctx context.Context, route *types.Route) err
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	
-	if _, exists := m.routes[route.ID]; !exists {
+	existing, exists := m.routes[route.ID]
+	if !exists {
 		return types.ErrRouteNotFound
 	}
 	
@@ -249,6 +274,11 @@ func (m *memoryStorage) UpdateRoute(ctx context.Context, route *types.Route) err
 		return errors.New("service not found for route")
 	}
 	
+	if route.Version != 0 && route.Version != existing.Version {
+		return types.ErrVersionConflict
+	}
+	route.Version = existing.Version + 1
+	
 	// Create a copy to store
 	routeCopy := *route
 	m.routes[route.ID] = &routeCopy
@@ -286,9 +316,68 @@ func (m *memoryStorage) DeleteRoute(ctx context.Context, id string) error {
 	return nil
 }
 
+func (m *memoryStorage) ListRoutesByGroup(ctx context.Context, group string) ([]*types.Route, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	
+	routes := make([]*types.Route, 0)
+	for _, route := range m.routes {
+		if route.Group == group {
+			routeCopy := *route
+			routes = append(routes, &routeCopy)
+		}
+	}
+	
+	sortRoutes(routes)
+	return routes, nil
+}
+
+// sortRoutes orders routes by descending priority, then ID, matching the SQL backends
+func sortRoutes(routes []*types.Route) {
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Priority != routes[j].Priority {
+			return routes[i].Priority > routes[j].Priority
+		}
+		return routes[i].ID < routes[j].ID
+	})
+}
+
+func (m *memoryStorage) DeleteRouteGroup(ctx context.Context, group string) (int, error) {
+	if group == "" {
+		return 0, types.ErrInvalidRequest
+	}
+	
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	
+	deleted := 0
+	for id, route := range m.routes {
+		if route.Group != group {
+			continue
+		}
+		
+		delete(m.routes, id)
+		deleted++
+		
+		// Notify watchers
+		m.notifyWatchers(types.StorageEvent{
+			Type:   "deleted",
+			Kind:   "route",
+			ID:     id,
+			Object: route,
+		})
+	}
+	
+	return deleted, nil
+}
+
 // Watch implementation
 
 func (m *memoryStorage) Watch(ctx context.Context) <-chan types.StorageEvent {
+	if m.tx != nil {
+		return m.tx.root.Watch(ctx)
+	}
+	
 	m.watcherMu.Lock()
 	defer m.watcherMu.Unlock()
 	
@@ -316,6 +405,12 @@ func (m *memoryStorage) Watch(ctx context.Context) <-chan types.StorageEvent {
 
 // notifyWatchers sends an event to all registered watchers
 func (m *memoryStorage) notifyWatchers(event types.StorageEvent) {
+	// Events raised in a transaction wait until it commits
+	if m.tx != nil {
+		m.tx.events = append(m.tx.events, event)
+		return
+	}
+	
 	m.watcherMu.RLock()
 	defer m.watcherMu.RUnlock()
 	
@@ -338,7 +433,7 @@ func (m *memoryStorage) GetUser(ctx context.Context, id string) (*types.User, er
 	
 	user, exists := m.users[id]
 	if !exists {
-		return nil, errors.New("user not found")
+		return nil, types.ErrUserNotFound
 	}
 	
 	// Return a copy
@@ -365,6 +460,25 @@ func (m *memoryStorage) GetUserByUsername(ctx context.Context, username string)
 	return &userCopy, nil
 }
 
+func (m *memoryStorage) GetUserByEmail(ctx context.Context, email string) (*types.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	
+	userID, exists := m.emails[strings.ToLower(email)]
+	if !exists || email == "" {
+		return nil, errors.New("user not found")
+	}
+	
+	user, exists := m.users[userID]
+	if !exists {
+		return nil, errors.New("user not found")
+	}
+	
+	// Return a copy
+	userCopy := *user
+	return &userCopy, nil
+}
+
 func (m *memoryStorage) ListUsers(ctx context.Context) ([]*types.User, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
@@ -388,11 +502,11 @@ func (m *memoryStorage) CreateUser(ctx context.Context, user *types.User) error
 	defer m.mu.Unlock()
 	
 	if _, exists := m.users[user.ID]; exists {
-		return errors.New("user already exists")
+		return fmt.Errorf("user already exists: %w", types.ErrAlreadyExists)
 	}
 	
 	if _, exists := m.usernames[user.Username]; exists {
-		return errors.New("username already taken")
+		return fmt.Errorf("username already taken: %w", types.ErrAlreadyExists)
 	}
 	
 	// Set timestamps
@@ -404,6 +518,7 @@ func (m *memoryStorage) CreateUser(ctx context.Context, user *types.User) error
 	userCopy := *user
 	m.users[user.ID] = &userCopy
 	m.usernames[user.Username] = user.ID
+	m.indexEmail("", user)
 	
 	return nil
 }
@@ -425,12 +540,14 @@ func (m *memoryStorage) UpdateUser(ctx context.Context, user *types.User) error
 	if existing.Username != user.Username {
 		// Check if new username is taken
 		if _, exists := m.usernames[user.Username]; exists {
-			return errors.New("username already taken")
+			return fmt.Errorf("username already taken: %w", types.ErrAlreadyExists)
 		}
 		delete(m.usernames, existing.Username)
 		m.usernames[user.Username] = user.ID
 	}
 	
+	m.indexEmail(existing.Email, user)
+	
 	// Update timestamp
 	user.UpdatedAt = time.Now()
 	// Preserve creation timestamp
@@ -454,6 +571,9 @@ func (m *memoryStorage) DeleteUser(ctx context.Context, id string) error {
 	
 	delete(m.users, id)
 	delete(m.usernames, user.Username)
+	if m.emails[strings.ToLower(user.Email)] == id {
+		delete(m.emails, strings.ToLower(user.Email))
+	}
 	
 	// Delete all API keys for this user
 	for key, apiKey := range m.apiKeys {
@@ -462,6 +582,13 @@ func (m *memoryStorage) DeleteUser(ctx context.Context, id string) error {
 		}
 	}
 	
+	// Delete any outstanding reset tokens
+	for hash, token := range m.resets {
+		if token.UserID == id {
+			delete(m.resets, hash)
+		}
+	}
+	
 	return nil
 }
 
@@ -473,7 +600,7 @@ func (m *memoryStorage) GetAPIKey(ctx context.Context, key string) (*types.APIKe
 	
 	apiKey, exists := m.apiKeys[key]
 	if !exists {
-		return nil, errors.New("API key not found")
+		return nil, types.ErrAPIKeyNotFound
 	}
 	
 	// Update last used time
@@ -485,6 +612,30 @@ func (m *memoryStorage) GetAPIKey(ctx context.Context, key string) (*types.APIKe
 	return &apiKeyCopy, nil
 }
 
+func (m *memoryStorage) ListAPIKeys(ctx context.Context) ([]*types.APIKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	
+	apiKeys := make([]*types.APIKey, 0, len(m.apiKeys))
+	for _, apiKey := range m.apiKeys {
+		apiKeyCopy := *apiKey
+		apiKeys = append(apiKeys, &apiKeyCopy)
+	}
+	
+	sortAPIKeys(apiKeys)
+	return apiKeys, nil
+}
+
+// sortAPIKeys orders keys newest first, then by key, matching the SQL backends
+func sortAPIKeys(apiKeys []*types.APIKey) {
+	sort.Slice(apiKeys, func(i, j int) bool {
+		if !apiKeys[i].CreatedAt.Equal(apiKeys[j].CreatedAt) {
+			return apiKeys[i].CreatedAt.After(apiKeys[j].CreatedAt)
+		}
+		return apiKeys[i].Key < apiKeys[j].Key
+	})
+}
+
 func (m *memoryStorage) ListAPIKeysByUser(ctx context.Context, userID string) ([]*types.APIKey, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
@@ -542,8 +693,128 @@ func (m *memoryStorage) RevokeAPIKey(ctx context.Context, key string) error {
 	return nil
 }
 
+func (m *memoryStorage) RevokeAllAPIKeysByUser(ctx context.Context, userID string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	
+	revoked := 0
+	for _, apiKey := range m.apiKeys {
+		if apiKey.UserID == userID && apiKey.Active {
+			apiKey.Active = false
+			revoked++
+		}
+	}
+	
+	return revoked, nil
+}
+
+// Password reset tokens implementation
+
+func (m *memoryStorage) CreatePasswordResetToken(ctx context.Context, token *types.PasswordResetToken) error {
+	if token == nil {
+		return types.ErrInvalidRequest
+	}
+	
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	
+	// Only the newest token for a user stays valid
+	for hash, existing := range m.resets {
+		if existing.UserID == token.UserID {
+			delete(m.resets, hash)
+		}
+	}
+	
+	token.CreatedAt = time.Now()
+	
+	tokenCopy := *token
+	m.resets[token.TokenHash] = &tokenCopy
+	
+	return nil
+}
+
+func (m *memoryStorage) ConsumePasswordResetToken(ctx context.Context, tokenHash string) (*types.PasswordResetToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	
+	token, exists := m.resets[tokenHash]
+	if !exists || token.UsedAt != nil || time.Now().After(token.ExpiresAt) {
+		return nil, types.ErrInvalidToken
+	}
+	
+	now := time.Now()
+	token.UsedAt = &now
+	
+	tokenCopy := *token
+	return &tokenCopy, nil
+}
+
+// Tx emulates a transaction: fn works on a copy of the data while the write
+// lock is held, so nothing else reads or writes until it returns. The copy
+// replaces the data if fn succeeds and is discarded if it fails.
+func (m *memoryStorage) Tx(ctx context.Context, fn func(types.Storage) error) error {
+	if m.tx != nil {
+		return fn(m)
+	}
+	
+	view, err := m.runTx(fn)
+	if err != nil {
+		return err
+	}
+	
+	for _, event := range view.tx.events {
+		m.notifyWatchers(event)
+	}
+	return nil
+}
+
+// runTx runs fn on a copy of the data under the write lock, keeping the copy
+// if fn succeeds
+func (m *memoryStorage) runTx(fn func(types.Storage) error) (*memoryStorage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	
+	view := &memoryStorage{
+		services:  cloneEntries(m.services),
+		routes:    cloneEntries(m.routes),
+		users:     cloneEntries(m.users),
+		usernames: maps.Clone(m.usernames),
+		emails:    maps.Clone(m.emails),
+		apiKeys:   cloneEntries(m.apiKeys),
+		resets:    cloneEntries(m.resets),
+		tx:        &memoryTx{root: m},
+	}
+	if err := fn(view); err != nil {
+		return nil, err
+	}
+	
+	m.services = view.services
+	m.routes = view.routes
+	m.users = view.users
+	m.usernames = view.usernames
+	m.emails = view.emails
+	m.apiKeys = view.apiKeys
+	m.resets = view.resets
+	return view, nil
+}
+
+// cloneEntries copies a map along with the values it points to, since some
+// methods change stored entries in place
+func cloneEntries[T any](entries map[string]*T) map[string]*T {
+	clone := make(map[string]*T, len(entries))
+	for key, entry := range entries {
+		entryCopy := *entry
+		clone[key] = &entryCopy
+	}
+	return clone
+}
+
 // Close closes the storage
 func (m *memoryStorage) Close() error {
+	if m.tx != nil {
+		return errors.New("cannot close storage inside a transaction")
+	}
+	
 	m.watcherMu.Lock()
 	defer m.watcherMu.Unlock()
 	
@@ -555,3 +826,14 @@ func (m *memoryStorage) Close() error {
 	
 	return nil
 }
+
+// indexEmail moves a user's email mapping from oldEmail to its current email.
+// Caller must hold the write lock.
+func (m *memoryStorage) indexEmail(oldEmail string, user *types.User) {
+	if oldKey := strings.ToLower(oldEmail); oldKey != "" && m.emails[oldKey] == user.ID {
+		delete(m.emails, oldKey)
+	}
+	if user.Email != "" {
+		m.emails[strings.ToLower(user.Email)] = user.ID
+	}
+}
@@ -5,16 +5,64 @@ import (
 	"database/sql"
 	"discobox/internal/types"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	fp "path/filepath"
+	"strings"
 	"sync"
 	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// queryer is satisfied by both *sql.DB and *sql.Tx, so the same queries run
+// inside or outside a transaction
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// Lock contention handling. Each connection waits up to sqliteBusyTimeout
+// for a lock before SQLite reports the database busy; writes that still fail
+// that way are retried with exponential backoff.
+const (
+	sqliteBusyTimeout  = 5 * time.Second
+	sqliteBusyRetries  = 5
+	sqliteRetryBackoff = 20 * time.Millisecond
 )
 
+// retryingDB retries statements that fail because another connection holds
+// the database lock. Reads pass straight through.
+type retryingDB struct {
+	*sql.DB
+}
+
+func (db retryingDB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	var result sql.Result
+	err := retryBusy(ctx, func() error {
+		var err error
+		result, err = db.DB.ExecContext(ctx, query, args...)
+		return err
+	})
+	return result, err
+}
+
+// sqliteTx tracks a transaction started by Tx
+type sqliteTx struct {
+	root   *sqliteStorage
+	events []types.StorageEvent
+}
+
 // sqliteStorage implements Storage interface using SQLite
 type sqliteStorage struct {
-	db        *sql.DB
+	db *sql.DB
+	// q runs queries: the database itself, or the transaction a Tx view is
+	// scoped to
+	q queryer
+	// tx is set on the view of the storage handed to a Tx callback
+	tx        *sqliteTx
 	logger    types.Logger
 	watchers  []chan types.StorageEvent
 	watcherMu sync.RWMutex
@@ -32,6 +80,15 @@ func NewSQLite(dsn string, logger types.Logger) (types.Storage, error) {
 		return nil, fmt.Errorf("failed to create config directory: %w", err)
 	}
 
+	// Transactions take the write lock when they begin, where the busy
+	// timeout applies, rather than failing when they first write. Options
+	// already in the DSN take precedence.
+	sep := "?"
+	if strings.Contains(dsn, "?") {
+		sep = "&"
+	}
+	dsn += fmt.Sprintf("%s_busy_timeout=%d&_txlock=immediate", sep, sqliteBusyTimeout.Milliseconds())
+
 	db, err := sql.Open("sqlite3", dsn)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
@@ -45,6 +102,7 @@ func NewSQLite(dsn string, logger types.Logger) (types.Storage, error) {
 
 	s := &sqliteStorage{
 		db:        db,
+		q:         retryingDB{db},
 		logger:    logger,
 		watchers:  make([]chan types.StorageEvent, 0),
 		stopWatch: make(chan struct{}),
@@ -71,8 +129,13 @@ func (s *sqliteStorage) createTables() error {
 			timeout INTEGER DEFAULT 30000,
 			metadata TEXT,
 			tls_config TEXT,
+			health_check TEXT NOT NULL DEFAULT '',
+			endpoint_tags TEXT NOT NULL DEFAULT '',
+			circuit_breaker TEXT NOT NULL DEFAULT '',
+			upstream_scheme TEXT NOT NULL DEFAULT '',
 			strip_prefix BOOLEAN DEFAULT FALSE,
 			active BOOLEAN DEFAULT TRUE,
+			version INTEGER NOT NULL DEFAULT 1,
 			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 		)`,
@@ -87,6 +150,21 @@ func (s *sqliteStorage) createTables() error {
 			middlewares TEXT,
 			rewrite_rules TEXT,
 			metadata TEXT,
+			version INTEGER NOT NULL DEFAULT 1,
+			group_name TEXT NOT NULL DEFAULT '',
+			redirects TEXT NOT NULL DEFAULT '',
+			hedging TEXT NOT NULL DEFAULT '',
+			sni TEXT NOT NULL DEFAULT '',
+			client_cert_subject TEXT NOT NULL DEFAULT '',
+			strip_path_prefix TEXT NOT NULL DEFAULT '',
+			add_path_prefix TEXT NOT NULL DEFAULT '',
+			early_hints TEXT NOT NULL DEFAULT '',
+			canary TEXT NOT NULL DEFAULT '',
+			disabled_middlewares TEXT NOT NULL DEFAULT '',
+			content_type TEXT NOT NULL DEFAULT '',
+			path_suffixes TEXT NOT NULL DEFAULT '',
+			upstream_scheme TEXT NOT NULL DEFAULT '',
+			max_response_bytes INTEGER NOT NULL DEFAULT 0,
 			FOREIGN KEY (service_id) REFERENCES services(id)
 		)`,
 		`CREATE TABLE IF NOT EXISTS users (
@@ -114,11 +192,21 @@ func (s *sqliteStorage) createTables() error {
 			metadata TEXT,
 			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
 		)`,
+		`CREATE TABLE IF NOT EXISTS password_reset_tokens (
+			token_hash TEXT PRIMARY KEY,
+			user_id TEXT NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			expires_at TIMESTAMP NOT NULL,
+			used_at TIMESTAMP,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		)`,
 		`CREATE INDEX IF NOT EXISTS idx_routes_priority ON routes(priority DESC)`,
 		`CREATE INDEX IF NOT EXISTS idx_routes_host ON routes(host)`,
 		`CREATE INDEX IF NOT EXISTS idx_services_active ON services(active)`,
 		`CREATE INDEX IF NOT EXISTS idx_users_username ON users(username)`,
+		`CREATE INDEX IF NOT EXISTS idx_users_email ON users(email COLLATE NOCASE)`,
 		`CREATE INDEX IF NOT EXISTS idx_api_keys_user_id ON api_keys(user_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_password_reset_tokens_user_id ON password_reset_tokens(user_id)`,
 	}
 
 	for _, query := range queries {
@@ -127,6 +215,84 @@ func (s *sqliteStorage) createTables() error {
 		}
 	}
 
+	// Columns added after the initial schema, for databases created by older versions
+	columns := []struct{ table, column, definition string }{
+		{"services", "version", "INTEGER NOT NULL DEFAULT 1"},
+		{"services", "health_check", "TEXT NOT NULL DEFAULT ''"},
+		{"services", "circuit_breaker", "TEXT NOT NULL DEFAULT ''"},
+		{"services", "endpoint_tags", "TEXT NOT NULL DEFAULT ''"},
+		{"services", "upstream_scheme", "TEXT NOT NULL DEFAULT ''"},
+		{"routes", "version", "INTEGER NOT NULL DEFAULT 1"},
+		{"routes", "group_name", "TEXT NOT NULL DEFAULT ''"},
+		{"routes", "redirects", "TEXT NOT NULL DEFAULT ''"},
+		{"routes", "hedging", "TEXT NOT NULL DEFAULT ''"},
+		{"routes", "sni", "TEXT NOT NULL DEFAULT ''"},
+		{"routes", "client_cert_subject", "TEXT NOT NULL DEFAULT ''"},
+		{"routes", "strip_path_prefix", "TEXT NOT NULL DEFAULT ''"},
+		{"routes", "add_path_prefix", "TEXT NOT NULL DEFAULT ''"},
+		{"routes", "early_hints", "TEXT NOT NULL DEFAULT ''"},
+		{"routes", "canary", "TEXT NOT NULL DEFAULT ''"},
+		{"routes", "disabled_middlewares", "TEXT NOT NULL DEFAULT ''"},
+		{"routes", "content_type", "TEXT NOT NULL DEFAULT ''"},
+		{"routes", "path_suffixes", "TEXT NOT NULL DEFAULT ''"},
+		{"routes", "upstream_scheme", "TEXT NOT NULL DEFAULT ''"},
+		{"routes", "max_response_bytes", "INTEGER NOT NULL DEFAULT 0"},
+	}
+
+	for _, c := range columns {
+		if err := s.ensureColumn(c.table, c.column, c.definition); err != nil {
+			return err
+		}
+	}
+
+	if _, err := s.db.Exec(`CREATE INDEX IF NOT EXISTS idx_routes_group ON routes(group_name)`); err != nil {
+		return fmt.Errorf("failed to execute query: %w", err)
+	}
+
+	return nil
+}
+
+// isUniqueViolation reports whether err is a primary key or unique constraint failure
+func isUniqueViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+	return sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey ||
+		sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique
+}
+
+// ensureColumn adds a column to an existing table if it is missing
+func (s *sqliteStorage) ensureColumn(table, column, definition string) error {
+	rows, err := s.db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return fmt.Errorf("failed to inspect table %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid        int
+			name, kind string
+			notNull    bool
+			dflt       sql.NullString
+			pk         int
+		)
+		if err := rows.Scan(&cid, &name, &kind, &notNull, &dflt, &pk); err != nil {
+			return fmt.Errorf("failed to inspect table %s: %w", table, err)
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to inspect table %s: %w", table, err)
+	}
+
+	if _, err := s.db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition)); err != nil {
+		return fmt.Errorf("failed to add column %s.%s: %w", table, column, err)
+	}
+
 	return nil
 }
 
@@ -134,17 +300,17 @@ func (s *sqliteStorage) createTables() error {
 
 func (s *sqliteStorage) GetService(ctx context.Context, id string) (*types.Service, error) {
 	var service types.Service
-	var endpoints, metadata, tlsConfig string
+	var endpoints, metadata, tlsConfig, healthCheck, endpointTags, circuitBreaker string
 	var timeout int64
 
 	query := `SELECT id, name, endpoints, health_path, weight, max_conns, timeout, 
-	          metadata, tls_config, strip_prefix, active, created_at, updated_at 
+	          metadata, tls_config, health_check, endpoint_tags, circuit_breaker, upstream_scheme, strip_prefix, active, version, created_at, updated_at 
 	          FROM services WHERE id = ?`
 
-	err := s.db.QueryRowContext(ctx, query, id).Scan(
+	err := s.q.QueryRowContext(ctx, query, id).Scan(
 		&service.ID, &service.Name, &endpoints, &service.HealthPath,
-		&service.Weight, &service.MaxConns, &timeout, &metadata, &tlsConfig,
-		&service.StripPrefix, &service.Active, &service.CreatedAt, &service.UpdatedAt,
+		&service.Weight, &service.MaxConns, &timeout, &metadata, &tlsConfig, &healthCheck, &endpointTags, &circuitBreaker, &service.UpstreamScheme,
+		&service.StripPrefix, &service.Active, &service.Version, &service.CreatedAt, &service.UpdatedAt,
 	)
 
 	if err == sql.ErrNoRows {
@@ -172,6 +338,26 @@ func (s *sqliteStorage) GetService(ctx context.Context, id string) (*types.Servi
 		}
 	}
 
+	if healthCheck != "" {
+		service.HealthCheck = &types.HealthCheckConfig{}
+		if err := json.Unmarshal([]byte(healthCheck), service.HealthCheck); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal health check config: %w", err)
+		}
+	}
+
+	if endpointTags != "" {
+		if err := json.Unmarshal([]byte(endpointTags), &service.EndpointTags); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal endpoint tags: %w", err)
+		}
+	}
+
+	if circuitBreaker != "" {
+		service.CircuitBreaker = &types.CircuitBreakerConfig{}
+		if err := json.Unmarshal([]byte(circuitBreaker), service.CircuitBreaker); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal circuit breaker config: %w", err)
+		}
+	}
+
 	service.Timeout = time.Duration(timeout) * time.Millisecond
 
 	return &service, nil
@@ -179,10 +365,10 @@ func (s *sqliteStorage) GetService(ctx context.Context, id string) (*types.Servi
 
 func (s *sqliteStorage) ListServices(ctx context.Context) ([]*types.Service, error) {
 	query := `SELECT id, name, endpoints, health_path, weight, max_conns, timeout, 
-	          metadata, tls_config, strip_prefix, active, created_at, updated_at 
+	          metadata, tls_config, health_check, endpoint_tags, circuit_breaker, upstream_scheme, strip_prefix, active, version, created_at, updated_at 
 	          FROM services ORDER BY name`
 
-	rows, err := s.db.QueryContext(ctx, query)
+	rows, err := s.q.QueryContext(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list services: %w", err)
 	}
@@ -191,13 +377,13 @@ func (s *sqliteStorage) ListServices(ctx context.Context) ([]*types.Service, err
 	var services []*types.Service
 	for rows.Next() {
 		var service types.Service
-		var endpoints, metadata, tlsConfig string
+		var endpoints, metadata, tlsConfig, healthCheck, endpointTags, circuitBreaker string
 		var timeout int64
 
 		err := rows.Scan(
 			&service.ID, &service.Name, &endpoints, &service.HealthPath,
-			&service.Weight, &service.MaxConns, &timeout, &metadata, &tlsConfig,
-			&service.StripPrefix, &service.Active, &service.CreatedAt, &service.UpdatedAt,
+			&service.Weight, &service.MaxConns, &timeout, &metadata, &tlsConfig, &healthCheck, &endpointTags, &circuitBreaker, &service.UpstreamScheme,
+			&service.StripPrefix, &service.Active, &service.Version, &service.CreatedAt, &service.UpdatedAt,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan service: %w", err)
@@ -221,6 +407,26 @@ func (s *sqliteStorage) ListServices(ctx context.Context) ([]*types.Service, err
 			}
 		}
 
+		if healthCheck != "" {
+			service.HealthCheck = &types.HealthCheckConfig{}
+			if err := json.Unmarshal([]byte(healthCheck), service.HealthCheck); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal health check config: %w", err)
+			}
+		}
+
+		if endpointTags != "" {
+			if err := json.Unmarshal([]byte(endpointTags), &service.EndpointTags); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal endpoint tags: %w", err)
+			}
+		}
+
+		if circuitBreaker != "" {
+			service.CircuitBreaker = &types.CircuitBreakerConfig{}
+			if err := json.Unmarshal([]byte(circuitBreaker), service.CircuitBreaker); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal circuit breaker config: %w", err)
+			}
+		}
+
 		service.Timeout = time.Duration(timeout) * time.Millisecond
 		services = append(services, &service)
 	}
@@ -252,20 +458,49 @@ func (s *sqliteStorage) CreateService(ctx context.Context, service *types.Servic
 		}
 	}
 
+	var healthCheck []byte
+	if service.HealthCheck != nil {
+		healthCheck, err = json.Marshal(service.HealthCheck)
+		if err != nil {
+			return fmt.Errorf("failed to marshal health check config: %w", err)
+		}
+	}
+
+	var endpointTags []byte
+	if len(service.EndpointTags) > 0 {
+		endpointTags, err = json.Marshal(service.EndpointTags)
+		if err != nil {
+			return fmt.Errorf("failed to marshal endpoint tags: %w", err)
+		}
+	}
+
+	var circuitBreaker []byte
+	if service.CircuitBreaker != nil {
+		circuitBreaker, err = json.Marshal(service.CircuitBreaker)
+		if err != nil {
+			return fmt.Errorf("failed to marshal circuit breaker config: %w", err)
+		}
+	}
+
 	query := `INSERT INTO services (id, name, endpoints, health_path, weight, max_conns, 
-	          timeout, metadata, tls_config, strip_prefix, active) 
-	          VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	          timeout, metadata, tls_config, health_check, endpoint_tags, circuit_breaker, upstream_scheme, strip_prefix, active) 
+	          VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 
-	_, err = s.db.ExecContext(ctx, query,
+	_, err = s.q.ExecContext(ctx, query,
 		service.ID, service.Name, string(endpoints), service.HealthPath,
 		service.Weight, service.MaxConns, service.Timeout.Milliseconds(),
-		string(metadata), string(tlsConfig), service.StripPrefix, service.Active,
+		string(metadata), string(tlsConfig), string(healthCheck), string(endpointTags), string(circuitBreaker), service.UpstreamScheme, service.StripPrefix, service.Active,
 	)
 
 	if err != nil {
+		if isUniqueViolation(err) {
+			return types.ErrAlreadyExists
+		}
 		return fmt.Errorf("failed to create service: %w", err)
 	}
 
+	service.Version = 1
+
 	// Notify watchers
 	s.notifyWatchers(types.StorageEvent{
 		Type:   "created",
@@ -283,11 +518,17 @@ func (s *sqliteStorage) UpdateService(ctx context.Context, service *types.Servic
 	}
 
 	// Check if service exists
-	var exists bool
-	err := s.db.QueryRowContext(ctx, "SELECT 1 FROM services WHERE id = ?", service.ID).Scan(&exists)
-	if err == sql.ErrNoRows {
+	var current int64
+	err := s.q.QueryRowContext(ctx, "SELECT version FROM services WHERE id = ?", service.ID).Scan(&current)
+	if errors.Is(err, sql.ErrNoRows) {
 		return types.ErrServiceNotFound
 	}
+	if err != nil {
+		return fmt.Errorf("failed to check service existence: %w", err)
+	}
+	if service.Version != 0 && service.Version != current {
+		return types.ErrVersionConflict
+	}
 
 	// Marshal JSON fields
 	endpoints, err := json.Marshal(service.Endpoints)
@@ -308,21 +549,56 @@ func (s *sqliteStorage) UpdateService(ctx context.Context, service *types.Servic
 		}
 	}
 
+	var healthCheck []byte
+	if service.HealthCheck != nil {
+		healthCheck, err = json.Marshal(service.HealthCheck)
+		if err != nil {
+			return fmt.Errorf("failed to marshal health check config: %w", err)
+		}
+	}
+
+	var endpointTags []byte
+	if len(service.EndpointTags) > 0 {
+		endpointTags, err = json.Marshal(service.EndpointTags)
+		if err != nil {
+			return fmt.Errorf("failed to marshal endpoint tags: %w", err)
+		}
+	}
+
+	var circuitBreaker []byte
+	if service.CircuitBreaker != nil {
+		circuitBreaker, err = json.Marshal(service.CircuitBreaker)
+		if err != nil {
+			return fmt.Errorf("failed to marshal circuit breaker config: %w", err)
+		}
+	}
+
 	query := `UPDATE services SET name = ?, endpoints = ?, health_path = ?, weight = ?, 
-	          max_conns = ?, timeout = ?, metadata = ?, tls_config = ?, 
-	          strip_prefix = ?, active = ?, updated_at = CURRENT_TIMESTAMP 
-	          WHERE id = ?`
+	          max_conns = ?, timeout = ?, metadata = ?, tls_config = ?, health_check = ?, 
+	          endpoint_tags = ?, circuit_breaker = ?, upstream_scheme = ?, strip_prefix = ?, active = ?, version = version + 1, 
+	          updated_at = CURRENT_TIMESTAMP 
+	          WHERE id = ? AND (? = 0 OR version = ?)`
 
-	_, err = s.db.ExecContext(ctx, query,
+	result, err := s.q.ExecContext(ctx, query,
 		service.Name, string(endpoints), service.HealthPath, service.Weight,
 		service.MaxConns, service.Timeout.Milliseconds(), string(metadata),
-		string(tlsConfig), service.StripPrefix, service.Active, service.ID,
+		string(tlsConfig), string(healthCheck), string(endpointTags), string(circuitBreaker), service.UpstreamScheme, service.StripPrefix, service.Active, service.ID,
+		service.Version, service.Version,
 	)
 
 	if err != nil {
 		return fmt.Errorf("failed to update service: %w", err)
 	}
 
+	// Zero rows here means a concurrent writer got in between the check and the update
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return types.ErrVersionConflict
+	}
+
+	if err := s.q.QueryRowContext(ctx, "SELECT version FROM services WHERE id = ?", service.ID).Scan(&service.Version); err != nil {
+		return fmt.Errorf("failed to read service version: %w", err)
+	}
+
 	// Notify watchers
 	s.notifyWatchers(types.StorageEvent{
 		Type:   "updated",
@@ -335,6 +611,14 @@ func (s *sqliteStorage) UpdateService(ctx context.Context, service *types.Servic
 }
 
 func (s *sqliteStorage) DeleteService(ctx context.Context, id string) error {
+	// Routes go with their service, so the deletes share a transaction
+	return s.Tx(ctx, func(tx types.Storage) error {
+		return tx.(*sqliteStorage).deleteService(ctx, id)
+	})
+}
+
+// deleteService removes a service and the routes that reference it
+func (s *sqliteStorage) deleteService(ctx context.Context, id string) error {
 	// Get service before deletion for event
 	service, err := s.GetService(ctx, id)
 	if err != nil {
@@ -342,28 +626,32 @@ func (s *sqliteStorage) DeleteService(ctx context.Context, id string) error {
 	}
 
 	// Get all routes that reference this service for deletion events
-	routesToDelete, err := s.db.QueryContext(ctx, "SELECT id FROM routes WHERE service_id = ?", id)
+	routesToDelete, err := s.q.QueryContext(ctx, "SELECT id FROM routes WHERE service_id = ?", id)
 	if err != nil {
 		return fmt.Errorf("failed to query routes: %w", err)
 	}
-	defer routesToDelete.Close()
 
 	var routeIDs []string
 	for routesToDelete.Next() {
 		var routeID string
 		if err := routesToDelete.Scan(&routeID); err != nil {
+			routesToDelete.Close()
 			return fmt.Errorf("failed to scan route ID: %w", err)
 		}
 		routeIDs = append(routeIDs, routeID)
 	}
+	routesToDelete.Close()
+	if err := routesToDelete.Err(); err != nil {
+		return fmt.Errorf("failed to query routes: %w", err)
+	}
 
 	// Delete routes that reference this service
-	if _, err := s.db.ExecContext(ctx, "DELETE FROM routes WHERE service_id = ?", id); err != nil {
+	if _, err := s.q.ExecContext(ctx, "DELETE FROM routes WHERE service_id = ?", id); err != nil {
 		return fmt.Errorf("failed to delete routes: %w", err)
 	}
 
 	// Delete the service
-	_, err = s.db.ExecContext(ctx, "DELETE FROM services WHERE id = ?", id)
+	_, err = s.q.ExecContext(ctx, "DELETE FROM services WHERE id = ?", id)
 	if err != nil {
 		return fmt.Errorf("failed to delete service: %w", err)
 	}
@@ -393,16 +681,18 @@ func (s *sqliteStorage) DeleteService(ctx context.Context, id string) error {
 
 func (s *sqliteStorage) GetRoute(ctx context.Context, id string) (*types.Route, error) {
 	var route types.Route
-	var headers, middlewares, rewriteRules, metadata string
+	var headers, middlewares, rewriteRules, metadata, redirects, hedging, earlyHints, canary, disabledMiddlewares, pathSuffixes string
 
 	query := `SELECT id, priority, host, path_prefix, path_regex, headers, 
-	          service_id, middlewares, rewrite_rules, metadata 
+	          service_id, middlewares, rewrite_rules, metadata, version, group_name, redirects, hedging, sni, client_cert_subject, 
+	          strip_path_prefix, add_path_prefix, early_hints, canary, disabled_middlewares, content_type, path_suffixes, upstream_scheme, max_response_bytes 
 	          FROM routes WHERE id = ?`
 
-	err := s.db.QueryRowContext(ctx, query, id).Scan(
+	err := s.q.QueryRowContext(ctx, query, id).Scan(
 		&route.ID, &route.Priority, &route.Host, &route.PathPrefix,
 		&route.PathRegex, &headers, &route.ServiceID, &middlewares,
-		&rewriteRules, &metadata,
+		&rewriteRules, &metadata, &route.Version, &route.Group, &redirects, &hedging,
+		&route.SNI, &route.ClientCertSubject, &route.StripPathPrefix, &route.AddPathPrefix, &earlyHints, &canary, &disabledMiddlewares, &route.ContentType, &pathSuffixes, &route.UpstreamScheme, &route.MaxResponseBytes,
 	)
 
 	if err == sql.ErrNoRows {
@@ -437,15 +727,61 @@ func (s *sqliteStorage) GetRoute(ctx context.Context, id string) (*types.Route,
 		}
 	}
 
+	if redirects != "" {
+		if err := json.Unmarshal([]byte(redirects), &route.Redirects); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal redirects: %w", err)
+		}
+	}
+
+	if hedging != "" {
+		if err := json.Unmarshal([]byte(hedging), &route.Hedging); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal hedging: %w", err)
+		}
+	}
+
+	if earlyHints != "" {
+		if err := json.Unmarshal([]byte(earlyHints), &route.EarlyHints); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal early hints: %w", err)
+		}
+	}
+
+	if canary != "" {
+		if err := json.Unmarshal([]byte(canary), &route.Canary); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal canary: %w", err)
+		}
+	}
+
+	if disabledMiddlewares != "" {
+		if err := json.Unmarshal([]byte(disabledMiddlewares), &route.DisabledMiddlewares); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal disabled middlewares: %w", err)
+		}
+	}
+
+	if pathSuffixes != "" {
+		if err := json.Unmarshal([]byte(pathSuffixes), &route.PathSuffixes); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal path suffixes: %w", err)
+		}
+	}
+
 	return &route, nil
 }
 
 func (s *sqliteStorage) ListRoutes(ctx context.Context) ([]*types.Route, error) {
+	return s.queryRoutes(ctx, "")
+}
+
+func (s *sqliteStorage) ListRoutesByGroup(ctx context.Context, group string) ([]*types.Route, error) {
+	return s.queryRoutes(ctx, "WHERE group_name = ?", group)
+}
+
+// queryRoutes lists routes matching an optional WHERE clause
+func (s *sqliteStorage) queryRoutes(ctx context.Context, where string, args ...any) ([]*types.Route, error) {
 	query := `SELECT id, priority, host, path_prefix, path_regex, headers, 
-	          service_id, middlewares, rewrite_rules, metadata 
-	          FROM routes ORDER BY priority DESC, id`
+	          service_id, middlewares, rewrite_rules, metadata, version, group_name, redirects, hedging, sni, client_cert_subject, 
+	          strip_path_prefix, add_path_prefix, early_hints, canary, disabled_middlewares, content_type, path_suffixes, upstream_scheme, max_response_bytes 
+	          FROM routes ` + where + ` ORDER BY priority DESC, id`
 
-	rows, err := s.db.QueryContext(ctx, query)
+	rows, err := s.q.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list routes: %w", err)
 	}
@@ -454,12 +790,13 @@ func (s *sqliteStorage) ListRoutes(ctx context.Context) ([]*types.Route, error)
 	var routes []*types.Route
 	for rows.Next() {
 		var route types.Route
-		var headers, middlewares, rewriteRules, metadata string
+		var headers, middlewares, rewriteRules, metadata, redirects, hedging, earlyHints, canary, disabledMiddlewares, pathSuffixes string
 
 		err := rows.Scan(
 			&route.ID, &route.Priority, &route.Host, &route.PathPrefix,
 			&route.PathRegex, &headers, &route.ServiceID, &middlewares,
-			&rewriteRules, &metadata,
+			&rewriteRules, &metadata, &route.Version, &route.Group, &redirects, &hedging,
+			&route.SNI, &route.ClientCertSubject, &route.StripPathPrefix, &route.AddPathPrefix, &earlyHints, &canary, &disabledMiddlewares, &route.ContentType, &pathSuffixes, &route.UpstreamScheme, &route.MaxResponseBytes,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan route: %w", err)
@@ -490,12 +827,74 @@ func (s *sqliteStorage) ListRoutes(ctx context.Context) ([]*types.Route, error)
 			}
 		}
 
+		if redirects != "" {
+			if err := json.Unmarshal([]byte(redirects), &route.Redirects); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal redirects: %w", err)
+			}
+		}
+
+		if hedging != "" {
+			if err := json.Unmarshal([]byte(hedging), &route.Hedging); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal hedging: %w", err)
+			}
+		}
+
+		if earlyHints != "" {
+			if err := json.Unmarshal([]byte(earlyHints), &route.EarlyHints); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal early hints: %w", err)
+			}
+		}
+
+		if canary != "" {
+			if err := json.Unmarshal([]byte(canary), &route.Canary); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal canary: %w", err)
+			}
+		}
+
+		if disabledMiddlewares != "" {
+			if err := json.Unmarshal([]byte(disabledMiddlewares), &route.DisabledMiddlewares); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal disabled middlewares: %w", err)
+			}
+		}
+
+		if pathSuffixes != "" {
+			if err := json.Unmarshal([]byte(pathSuffixes), &route.PathSuffixes); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal path suffixes: %w", err)
+			}
+		}
+
 		routes = append(routes, &route)
 	}
 
 	return routes, nil
 }
 
+// marshalRedirects encodes a route's redirect policy, storing an empty string when unset
+func marshalRedirects(policy *types.RedirectPolicy) string {
+	if policy == nil {
+		return ""
+	}
+	data, _ := json.Marshal(policy)
+	return string(data)
+}
+
+// marshalHedging encodes a route's hedging policy, storing an empty string when unset
+func marshalHedging(policy *types.HedgePolicy) string {
+	if policy == nil {
+		return ""
+	}
+	data, _ := json.Marshal(policy)
+	return string(data)
+}
+
+func marshalCanary(policy *types.CanaryPolicy) string {
+	if policy == nil {
+		return ""
+	}
+	data, _ := json.Marshal(policy)
+	return string(data)
+}
+
 func (s *sqliteStorage) CreateRoute(ctx context.Context, route *types.Route) error {
 	if route == nil {
 		return types.ErrInvalidRequest
@@ -503,7 +902,7 @@ func (s *sqliteStorage) CreateRoute(ctx context.Context, route *types.Route) err
 
 	// Verify service exists
 	var exists bool
-	err := s.db.QueryRowContext(ctx, "SELECT 1 FROM services WHERE id = ?", route.ServiceID).Scan(&exists)
+	err := s.q.QueryRowContext(ctx, "SELECT 1 FROM services WHERE id = ?", route.ServiceID).Scan(&exists)
 	if err == sql.ErrNoRows {
 		return fmt.Errorf("service not found for route")
 	}
@@ -513,21 +912,35 @@ func (s *sqliteStorage) CreateRoute(ctx context.Context, route *types.Route) err
 	middlewares, _ := json.Marshal(route.Middlewares)
 	rewriteRules, _ := json.Marshal(route.RewriteRules)
 	metadata, _ := json.Marshal(route.Metadata)
+	redirects := marshalRedirects(route.Redirects)
+	hedging := marshalHedging(route.Hedging)
+	earlyHints, _ := json.Marshal(route.EarlyHints)
+	canary := marshalCanary(route.Canary)
+	disabledMiddlewares, _ := json.Marshal(route.DisabledMiddlewares)
+	pathSuffixes, _ := json.Marshal(route.PathSuffixes)
 
 	query := `INSERT INTO routes (id, priority, host, path_prefix, path_regex, 
-	          headers, service_id, middlewares, rewrite_rules, metadata) 
-	          VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	          headers, service_id, middlewares, rewrite_rules, metadata, group_name, redirects, hedging, sni, client_cert_subject, 
+	          strip_path_prefix, add_path_prefix, early_hints, canary, disabled_middlewares, content_type, path_suffixes, upstream_scheme, max_response_bytes) 
+	          VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 
-	_, err = s.db.ExecContext(ctx, query,
+	_, err = s.q.ExecContext(ctx, query,
 		route.ID, route.Priority, route.Host, route.PathPrefix,
 		route.PathRegex, string(headers), route.ServiceID,
 		string(middlewares), string(rewriteRules), string(metadata),
+		route.Group, redirects, hedging, route.SNI, route.ClientCertSubject,
+		route.StripPathPrefix, route.AddPathPrefix, string(earlyHints), canary, string(disabledMiddlewares), route.ContentType, string(pathSuffixes), route.UpstreamScheme, route.MaxResponseBytes,
 	)
 
 	if err != nil {
+		if isUniqueViolation(err) {
+			return types.ErrAlreadyExists
+		}
 		return fmt.Errorf("failed to create route: %w", err)
 	}
 
+	route.Version = 1
+
 	// Notify watchers
 	s.notifyWatchers(types.StorageEvent{
 		Type:   "created",
@@ -545,38 +958,67 @@ func (s *sqliteStorage) UpdateRoute(ctx context.Context, route *types.Route) err
 	}
 
 	// Check if route exists
-	var exists bool
-	err := s.db.QueryRowContext(ctx, "SELECT 1 FROM routes WHERE id = ?", route.ID).Scan(&exists)
-	if err == sql.ErrNoRows {
+	var current int64
+	err := s.q.QueryRowContext(ctx, "SELECT version FROM routes WHERE id = ?", route.ID).Scan(&current)
+	if errors.Is(err, sql.ErrNoRows) {
 		return types.ErrRouteNotFound
 	}
+	if err != nil {
+		return fmt.Errorf("failed to check route existence: %w", err)
+	}
+	if route.Version != 0 && route.Version != current {
+		return types.ErrVersionConflict
+	}
 
 	// Verify service exists
-	err = s.db.QueryRowContext(ctx, "SELECT 1 FROM services WHERE id = ?", route.ServiceID).Scan(&exists)
-	if err == sql.ErrNoRows {
+	var exists int
+	err = s.q.QueryRowContext(ctx, "SELECT 1 FROM services WHERE id = ?", route.ServiceID).Scan(&exists)
+	if errors.Is(err, sql.ErrNoRows) {
 		return fmt.Errorf("service not found for route")
 	}
+	if err != nil {
+		return fmt.Errorf("failed to check service existence: %w", err)
+	}
 
 	// Marshal JSON fields
 	headers, _ := json.Marshal(route.Headers)
 	middlewares, _ := json.Marshal(route.Middlewares)
 	rewriteRules, _ := json.Marshal(route.RewriteRules)
 	metadata, _ := json.Marshal(route.Metadata)
+	redirects := marshalRedirects(route.Redirects)
+	hedging := marshalHedging(route.Hedging)
+	earlyHints, _ := json.Marshal(route.EarlyHints)
+	canary := marshalCanary(route.Canary)
+	disabledMiddlewares, _ := json.Marshal(route.DisabledMiddlewares)
+	pathSuffixes, _ := json.Marshal(route.PathSuffixes)
 
 	query := `UPDATE routes SET priority = ?, host = ?, path_prefix = ?, 
 	          path_regex = ?, headers = ?, service_id = ?, middlewares = ?, 
-	          rewrite_rules = ?, metadata = ? WHERE id = ?`
+	          rewrite_rules = ?, metadata = ?, group_name = ?, redirects = ?, hedging = ?, 
+	          sni = ?, client_cert_subject = ?, strip_path_prefix = ?, 
+	          add_path_prefix = ?, early_hints = ?, canary = ?, disabled_middlewares = ?, content_type = ?, path_suffixes = ?, upstream_scheme = ?, max_response_bytes = ?, version = version + 1 
+	          WHERE id = ? AND (? = 0 OR version = ?)`
 
-	_, err = s.db.ExecContext(ctx, query,
+	result, err := s.q.ExecContext(ctx, query,
 		route.Priority, route.Host, route.PathPrefix, route.PathRegex,
 		string(headers), route.ServiceID, string(middlewares),
-		string(rewriteRules), string(metadata), route.ID,
+		string(rewriteRules), string(metadata), route.Group, redirects, hedging,
+		route.SNI, route.ClientCertSubject, route.StripPathPrefix, route.AddPathPrefix, string(earlyHints), canary, string(disabledMiddlewares), route.ContentType, string(pathSuffixes), route.UpstreamScheme, route.MaxResponseBytes, route.ID,
+		route.Version, route.Version,
 	)
 
 	if err != nil {
 		return fmt.Errorf("failed to update route: %w", err)
 	}
 
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return types.ErrVersionConflict
+	}
+
+	if err := s.q.QueryRowContext(ctx, "SELECT version FROM routes WHERE id = ?", route.ID).Scan(&route.Version); err != nil {
+		return fmt.Errorf("failed to read route version: %w", err)
+	}
+
 	// Notify watchers
 	s.notifyWatchers(types.StorageEvent{
 		Type:   "updated",
@@ -595,7 +1037,7 @@ func (s *sqliteStorage) DeleteRoute(ctx context.Context, id string) error {
 		return err
 	}
 
-	_, err = s.db.ExecContext(ctx, "DELETE FROM routes WHERE id = ?", id)
+	_, err = s.q.ExecContext(ctx, "DELETE FROM routes WHERE id = ?", id)
 	if err != nil {
 		return fmt.Errorf("failed to delete route: %w", err)
 	}
@@ -611,9 +1053,64 @@ func (s *sqliteStorage) DeleteRoute(ctx context.Context, id string) error {
 	return nil
 }
 
+func (s *sqliteStorage) DeleteRouteGroup(ctx context.Context, group string) (int, error) {
+	if group == "" {
+		return 0, types.ErrInvalidRequest
+	}
+
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Collect the IDs in the same transaction so events match what was deleted
+	rows, err := tx.QueryContext(ctx, "SELECT id FROM routes WHERE group_name = ?", group)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list route group: %w", err)
+	}
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan route: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to list route group: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM routes WHERE group_name = ?", group); err != nil {
+		return 0, fmt.Errorf("failed to delete route group: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	// Notify watchers
+	for _, id := range ids {
+		s.notifyWatchers(types.StorageEvent{
+			Type: "deleted",
+			Kind: "route",
+			ID:   id,
+		})
+	}
+
+	return len(ids), nil
+}
+
 // Watch implementation
 
 func (s *sqliteStorage) Watch(ctx context.Context) <-chan types.StorageEvent {
+	if s.tx != nil {
+		return s.tx.root.Watch(ctx)
+	}
+
 	s.watcherMu.Lock()
 	defer s.watcherMu.Unlock()
 
@@ -641,6 +1138,12 @@ func (s *sqliteStorage) Watch(ctx context.Context) <-chan types.StorageEvent {
 
 // notifyWatchers sends an event to all registered watchers
 func (s *sqliteStorage) notifyWatchers(event types.StorageEvent) {
+	// Events raised in a transaction wait until it commits
+	if s.tx != nil {
+		s.tx.events = append(s.tx.events, event)
+		return
+	}
+
 	s.watcherMu.RLock()
 	defer s.watcherMu.RUnlock()
 
@@ -666,14 +1169,14 @@ func (s *sqliteStorage) GetUser(ctx context.Context, id string) (*types.User, er
 	          active, created_at, updated_at, last_login_at, metadata
 	          FROM users WHERE id = ?`
 
-	err := s.db.QueryRowContext(ctx, query, id).Scan(
+	err := s.q.QueryRowContext(ctx, query, id).Scan(
 		&user.ID, &user.Username, &user.PasswordHash, &user.Email,
 		&user.IsAdmin, &user.MustChangePassword, &user.Active,
 		&user.CreatedAt, &user.UpdatedAt, &lastLoginAt, &metadata,
 	)
 
 	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("user not found")
+		return nil, types.ErrUserNotFound
 	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user: %w", err)
@@ -701,7 +1204,46 @@ func (s *sqliteStorage) GetUserByUsername(ctx context.Context, username string)
 	          active, created_at, updated_at, last_login_at, metadata
 	          FROM users WHERE username = ?`
 
-	err := s.db.QueryRowContext(ctx, query, username).Scan(
+	err := s.q.QueryRowContext(ctx, query, username).Scan(
+		&user.ID, &user.Username, &user.PasswordHash, &user.Email,
+		&user.IsAdmin, &user.MustChangePassword, &user.Active,
+		&user.CreatedAt, &user.UpdatedAt, &lastLoginAt, &metadata,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("user not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if lastLoginAt.Valid {
+		user.LastLoginAt = &lastLoginAt.Time
+	}
+
+	if metadata.Valid && metadata.String != "" {
+		if err := json.Unmarshal([]byte(metadata.String), &user.Metadata); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal metadata: %w", err)
+		}
+	}
+
+	return &user, nil
+}
+
+func (s *sqliteStorage) GetUserByEmail(ctx context.Context, email string) (*types.User, error) {
+	if email == "" {
+		return nil, fmt.Errorf("user not found")
+	}
+
+	var user types.User
+	var metadata sql.NullString
+	var lastLoginAt sql.NullTime
+
+	query := `SELECT id, username, password_hash, email, is_admin, must_change_password,
+	          active, created_at, updated_at, last_login_at, metadata
+	          FROM users WHERE email = ? COLLATE NOCASE LIMIT 1`
+
+	err := s.q.QueryRowContext(ctx, query, email).Scan(
 		&user.ID, &user.Username, &user.PasswordHash, &user.Email,
 		&user.IsAdmin, &user.MustChangePassword, &user.Active,
 		&user.CreatedAt, &user.UpdatedAt, &lastLoginAt, &metadata,
@@ -732,7 +1274,7 @@ func (s *sqliteStorage) ListUsers(ctx context.Context) ([]*types.User, error) {
 	          active, created_at, updated_at, last_login_at, metadata
 	          FROM users ORDER BY username`
 
-	rows, err := s.db.QueryContext(ctx, query)
+	rows, err := s.q.QueryContext(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list users: %w", err)
 	}
@@ -783,12 +1325,15 @@ func (s *sqliteStorage) CreateUser(ctx context.Context, user *types.User) error
 	          must_change_password, active, metadata)
 	          VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
 
-	_, err = s.db.ExecContext(ctx, query,
+	_, err = s.q.ExecContext(ctx, query,
 		user.ID, user.Username, user.PasswordHash, user.Email,
 		user.IsAdmin, user.MustChangePassword, user.Active, string(metadata),
 	)
 
 	if err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("user already exists: %w", types.ErrAlreadyExists)
+		}
 		return fmt.Errorf("failed to create user: %w", err)
 	}
 
@@ -810,13 +1355,16 @@ func (s *sqliteStorage) UpdateUser(ctx context.Context, user *types.User) error
 	          updated_at = CURRENT_TIMESTAMP, metadata = ?
 	          WHERE id = ?`
 
-	result, err := s.db.ExecContext(ctx, query,
+	result, err := s.q.ExecContext(ctx, query,
 		user.Username, user.PasswordHash, user.Email,
 		user.IsAdmin, user.MustChangePassword, user.Active,
 		string(metadata), user.ID,
 	)
 
 	if err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("username already taken: %w", types.ErrAlreadyExists)
+		}
 		return fmt.Errorf("failed to update user: %w", err)
 	}
 
@@ -835,7 +1383,7 @@ func (s *sqliteStorage) UpdateUser(ctx context.Context, user *types.User) error
 func (s *sqliteStorage) DeleteUser(ctx context.Context, id string) error {
 	// Check if user exists
 	var count int
-	err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM users WHERE id = ?", id).Scan(&count)
+	err := s.q.QueryRowContext(ctx, "SELECT COUNT(*) FROM users WHERE id = ?", id).Scan(&count)
 	if err != nil {
 		return fmt.Errorf("failed to check user existence: %w", err)
 	}
@@ -844,13 +1392,13 @@ func (s *sqliteStorage) DeleteUser(ctx context.Context, id string) error {
 	}
 
 	// Delete API keys first (cascade deletion)
-	_, err = s.db.ExecContext(ctx, "DELETE FROM api_keys WHERE user_id = ?", id)
+	_, err = s.q.ExecContext(ctx, "DELETE FROM api_keys WHERE user_id = ?", id)
 	if err != nil {
 		return fmt.Errorf("failed to delete API keys: %w", err)
 	}
 
 	// Delete the user
-	_, err = s.db.ExecContext(ctx, "DELETE FROM users WHERE id = ?", id)
+	_, err = s.q.ExecContext(ctx, "DELETE FROM users WHERE id = ?", id)
 	if err != nil {
 		return fmt.Errorf("failed to delete user: %w", err)
 	}
@@ -868,13 +1416,13 @@ func (s *sqliteStorage) GetAPIKey(ctx context.Context, key string) (*types.APIKe
 	          last_used_at, expires_at, metadata
 	          FROM api_keys WHERE key = ?`
 
-	err := s.db.QueryRowContext(ctx, query, key).Scan(
+	err := s.q.QueryRowContext(ctx, query, key).Scan(
 		&apiKey.Key, &apiKey.UserID, &apiKey.Name, &apiKey.Description,
 		&apiKey.Active, &apiKey.CreatedAt, &lastUsedAt, &expiresAt, &metadata,
 	)
 
 	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("API key not found")
+		return nil, types.ErrAPIKeyNotFound
 	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to get API key: %w", err)
@@ -895,23 +1443,36 @@ func (s *sqliteStorage) GetAPIKey(ctx context.Context, key string) (*types.APIKe
 	}
 
 	// Update last used timestamp
-	_, _ = s.db.ExecContext(ctx, "UPDATE api_keys SET last_used_at = CURRENT_TIMESTAMP WHERE key = ?", key)
+	_, _ = s.q.ExecContext(ctx, "UPDATE api_keys SET last_used_at = CURRENT_TIMESTAMP WHERE key = ?", key)
 
 	return &apiKey, nil
 }
 
+func (s *sqliteStorage) ListAPIKeys(ctx context.Context) ([]*types.APIKey, error) {
+	query := `SELECT key, user_id, name, description, active, created_at,
+	          last_used_at, expires_at, metadata
+	          FROM api_keys ORDER BY created_at DESC, key`
+
+	return s.queryAPIKeys(ctx, query)
+}
+
 func (s *sqliteStorage) ListAPIKeysByUser(ctx context.Context, userID string) ([]*types.APIKey, error) {
 	query := `SELECT key, user_id, name, description, active, created_at,
 	          last_used_at, expires_at, metadata
 	          FROM api_keys WHERE user_id = ? ORDER BY created_at DESC`
 
-	rows, err := s.db.QueryContext(ctx, query, userID)
+	return s.queryAPIKeys(ctx, query, userID)
+}
+
+// queryAPIKeys runs a query selecting API key columns and scans the results
+func (s *sqliteStorage) queryAPIKeys(ctx context.Context, query string, args ...any) ([]*types.APIKey, error) {
+	rows, err := s.q.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list API keys: %w", err)
 	}
 	defer rows.Close()
 
-	var apiKeys []*types.APIKey
+	apiKeys := make([]*types.APIKey, 0)
 	for rows.Next() {
 		var apiKey types.APIKey
 		var metadata sql.NullString
@@ -952,7 +1513,7 @@ func (s *sqliteStorage) CreateAPIKey(ctx context.Context, apiKey *types.APIKey)
 
 	// Check if user exists
 	var count int
-	err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM users WHERE id = ?", apiKey.UserID).Scan(&count)
+	err := s.q.QueryRowContext(ctx, "SELECT COUNT(*) FROM users WHERE id = ?", apiKey.UserID).Scan(&count)
 	if err != nil {
 		return fmt.Errorf("failed to check user existence: %w", err)
 	}
@@ -973,7 +1534,7 @@ func (s *sqliteStorage) CreateAPIKey(ctx context.Context, apiKey *types.APIKey)
 		expiresAt = sql.NullTime{Time: *apiKey.ExpiresAt, Valid: true}
 	}
 
-	_, err = s.db.ExecContext(ctx, query,
+	_, err = s.q.ExecContext(ctx, query,
 		apiKey.Key, apiKey.UserID, apiKey.Name, apiKey.Description,
 		apiKey.Active, expiresAt, string(metadata),
 	)
@@ -986,7 +1547,7 @@ func (s *sqliteStorage) CreateAPIKey(ctx context.Context, apiKey *types.APIKey)
 }
 
 func (s *sqliteStorage) RevokeAPIKey(ctx context.Context, key string) error {
-	result, err := s.db.ExecContext(ctx, "UPDATE api_keys SET active = FALSE WHERE key = ?", key)
+	result, err := s.q.ExecContext(ctx, "UPDATE api_keys SET active = FALSE WHERE key = ?", key)
 	if err != nil {
 		return fmt.Errorf("failed to revoke API key: %w", err)
 	}
@@ -1003,8 +1564,203 @@ func (s *sqliteStorage) RevokeAPIKey(ctx context.Context, key string) error {
 	return nil
 }
 
+func (s *sqliteStorage) RevokeAllAPIKeysByUser(ctx context.Context, userID string) (int, error) {
+	result, err := s.q.ExecContext(ctx, "UPDATE api_keys SET active = FALSE WHERE user_id = ? AND active = TRUE", userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to revoke API keys: %w", err)
+	}
+	
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	
+	return int(rowsAffected), nil
+}
+
+// Password reset tokens implementation
+
+func (s *sqliteStorage) CreatePasswordResetToken(ctx context.Context, token *types.PasswordResetToken) error {
+	if token == nil {
+		return types.ErrInvalidRequest
+	}
+
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Only the newest token for a user stays valid
+	if _, err := tx.ExecContext(ctx, "DELETE FROM password_reset_tokens WHERE user_id = ?", token.UserID); err != nil {
+		return fmt.Errorf("failed to clear reset tokens: %w", err)
+	}
+
+	token.CreatedAt = time.Now()
+
+	query := `INSERT INTO password_reset_tokens (token_hash, user_id, created_at, expires_at)
+	          VALUES (?, ?, ?, ?)`
+
+	if _, err := tx.ExecContext(ctx, query, token.TokenHash, token.UserID, token.CreatedAt, token.ExpiresAt); err != nil {
+		return fmt.Errorf("failed to create reset token: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+func (s *sqliteStorage) ConsumePasswordResetToken(ctx context.Context, tokenHash string) (*types.PasswordResetToken, error) {
+	var token types.PasswordResetToken
+	var usedAt sql.NullTime
+
+	query := `SELECT token_hash, user_id, created_at, expires_at, used_at
+	          FROM password_reset_tokens WHERE token_hash = ?`
+
+	err := s.q.QueryRowContext(ctx, query, tokenHash).Scan(
+		&token.TokenHash, &token.UserID, &token.CreatedAt, &token.ExpiresAt, &usedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, types.ErrInvalidToken
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reset token: %w", err)
+	}
+
+	now := time.Now()
+	if usedAt.Valid || now.After(token.ExpiresAt) {
+		return nil, types.ErrInvalidToken
+	}
+
+	// The used_at guard makes concurrent consumers race for a single winner
+	result, err := s.q.ExecContext(ctx,
+		"UPDATE password_reset_tokens SET used_at = ? WHERE token_hash = ? AND used_at IS NULL",
+		now, tokenHash,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to consume reset token: %w", err)
+	}
+
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return nil, types.ErrInvalidToken
+	}
+
+	token.UsedAt = &now
+	return &token, nil
+}
+
+// Tx runs fn in a database transaction. Writes made through the storage
+// passed to fn commit together when fn returns nil and are rolled back when
+// it returns an error; watchers hear about them only after the commit.
+// Calling Tx on that storage joins the enclosing transaction.
+func (s *sqliteStorage) Tx(ctx context.Context, fn func(types.Storage) error) error {
+	if s.tx != nil {
+		return fn(s)
+	}
+
+	tx, err := s.beginTx(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	view := &sqliteStorage{
+		db:     s.db,
+		q:      tx,
+		tx:     &sqliteTx{root: s},
+		logger: s.logger,
+	}
+	if err := fn(view); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	for _, event := range view.tx.events {
+		s.notifyWatchers(event)
+	}
+	return nil
+}
+
+// txHandle is a transaction used by a single storage method
+type txHandle interface {
+	queryer
+	Commit() error
+	Rollback() error
+}
+
+// joinedTx runs a method's transaction inside the one a Tx view is scoped
+// to; Tx commits or rolls back the whole thing
+type joinedTx struct {
+	queryer
+}
+
+func (joinedTx) Commit() error   { return nil }
+func (joinedTx) Rollback() error { return nil }
+
+// begin starts a transaction for a single method, joining the enclosing one
+// inside a Tx
+func (s *sqliteStorage) begin(ctx context.Context) (txHandle, error) {
+	if s.tx != nil {
+		return joinedTx{s.q}, nil
+	}
+
+	tx, err := s.beginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// beginTx starts a transaction, retrying while another connection holds the
+// write lock
+func (s *sqliteStorage) beginTx(ctx context.Context) (*sql.Tx, error) {
+	var tx *sql.Tx
+	err := retryBusy(ctx, func() error {
+		var err error
+		tx, err = s.db.BeginTx(ctx, nil)
+		return err
+	})
+	return tx, err
+}
+
+// retryBusy runs fn, retrying with exponential backoff while it fails because
+// the database is busy or locked. It gives up after sqliteBusyRetries retries
+// or when ctx is done, returning the last error.
+func retryBusy(ctx context.Context, fn func() error) error {
+	backoff := sqliteRetryBackoff
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || !isBusy(err) || attempt == sqliteBusyRetries {
+			return err
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		}
+		backoff *= 2
+	}
+}
+
+// isBusy reports whether err means another connection holds the lock
+func isBusy(err error) bool {
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+	return sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked
+}
+
 // Close closes the database connection
 func (s *sqliteStorage) Close() error {
+	if s.tx != nil {
+		return errors.New("cannot close storage inside a transaction")
+	}
+
 	close(s.stopWatch)
 	s.wg.Wait()
 	return s.db.Close()
@@ -0,0 +1,111 @@
+package types
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// TrustAllProxies trusts every X-Forwarded-For entry, making the leftmost
+// one the client
+const TrustAllProxies = -1
+
+type clientIPKey struct{}
+
+// ContextWithClientIP records the request's resolved client IP
+func ContextWithClientIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, clientIPKey{}, ip)
+}
+
+// ClientIP returns the IP of the client that made the request: the one
+// recorded in its context, or else the one the forwarding chain gives when
+// every proxy in it is trusted. Load balancing, rate limiting and logging
+// all identify clients through it, so they agree on who a client is.
+func ClientIP(req *http.Request) string {
+	if ip, ok := req.Context().Value(clientIPKey{}).(string); ok {
+		return ip
+	}
+	return ResolveClientIP(req, TrustAllProxies)
+}
+
+// ResolveClientIP picks the client IP out of the request's forwarding chain,
+// the X-Forwarded-For entries followed by the connecting peer. depth is how
+// many proxies in front of us are trusted to have appended to the chain; the
+// client is the entry that many hops left of the peer. Entries further left
+// were supplied by the client and are ignored. A negative depth trusts the
+// whole chain. Without X-Forwarded-For entries, a trusted X-Real-IP is used.
+func ResolveClientIP(req *http.Request, depth int) string {
+	chain := ForwardingChain(req)
+
+	if depth != 0 && len(chain) <= 1 {
+		if ip, ok := normalizeIP(req.Header.Get("X-Real-IP")); ok {
+			return ip
+		}
+	}
+
+	if len(chain) == 0 {
+		// The peer isn't an IP, e.g. a unix socket
+		if host, _, err := net.SplitHostPort(req.RemoteAddr); err == nil {
+			return host
+		}
+		return req.RemoteAddr
+	}
+
+	i := len(chain) - 1 - depth
+	if depth < 0 || i < 0 {
+		i = 0
+	}
+	return chain[i]
+}
+
+// ForwardingChain returns the request's X-Forwarded-For entries followed by
+// the connecting peer, one entry per hop. It is the chain client IPs are
+// resolved from and the one forwarded to backends.
+func ForwardingChain(req *http.Request) []string {
+	chain := ForwardedFor(req)
+	if peer := PeerIP(req); peer != "" {
+		chain = append(chain, peer)
+	}
+	return chain
+}
+
+// ForwardedFor returns the request's X-Forwarded-For entries, in order,
+// across all of its X-Forwarded-For headers. Entries are normalized to bare
+// IPs, dropping ports, brackets and zones, and entries that aren't IPs are
+// dropped. Repeated entries are kept, since each is a hop.
+func ForwardedFor(req *http.Request) []string {
+	var chain []string
+	for _, value := range req.Header.Values("X-Forwarded-For") {
+		for _, entry := range strings.Split(value, ",") {
+			if ip, ok := normalizeIP(entry); ok {
+				chain = append(chain, ip)
+			}
+		}
+	}
+	return chain
+}
+
+// PeerIP returns the IP of the connecting peer, or "" if its address isn't
+// an IP
+func PeerIP(req *http.Request) string {
+	ip, _ := normalizeIP(req.RemoteAddr)
+	return ip
+}
+
+// normalizeIP parses an address written as an IP, optionally with a port,
+// brackets or zone, and returns the bare IP
+func normalizeIP(s string) (string, bool) {
+	s = strings.TrimSpace(s)
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		s = host
+	}
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "["), "]")
+
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return "", false
+	}
+	return addr.WithZone("").Unmap().String(), true
+}
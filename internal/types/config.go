@@ -9,10 +9,29 @@ import (
 type ProxyConfig struct {
 	// Server configuration
 	ListenAddr      string        `yaml:"listen_addr" mapstructure:"listen_addr"`
+	ListenAddrs     []string      `yaml:"listen_addrs,omitempty" mapstructure:"listen_addrs"` // Further addresses serving the proxy alongside ListenAddr
 	ReadTimeout     time.Duration `yaml:"read_timeout" mapstructure:"read_timeout"`
 	WriteTimeout    time.Duration `yaml:"write_timeout" mapstructure:"write_timeout"`
 	IdleTimeout     time.Duration `yaml:"idle_timeout" mapstructure:"idle_timeout"`
 	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" mapstructure:"shutdown_timeout"`
+	DrainTimeout    time.Duration `yaml:"drain_timeout" mapstructure:"drain_timeout"`       // Time backends removed from a service get to finish requests, least loaded first; 0 = no limit
+	MaxHeaderBytes  int           `yaml:"max_header_bytes" mapstructure:"max_header_bytes"` // Request line and headers; larger requests get 431
+	
+	// Connection limit on the proxy listener
+	MaxConnections     int    `yaml:"max_connections" mapstructure:"max_connections"`           // Open client connections at once; 0 = unlimited
+	MaxConnectionsMode string `yaml:"max_connections_mode" mapstructure:"max_connections_mode"` // "wait" leaves extra connections queued, "refuse" closes them
+	
+	// Proxies in front of discobox trusted to append to X-Forwarded-For; the
+	// client IP is the entry that many hops left of the connecting peer.
+	// -1 trusts every entry.
+	TrustedProxyDepth int `yaml:"trusted_proxy_depth" mapstructure:"trusted_proxy_depth"`
+	
+	// Long-lived connections (WebSocket upgrades, event streams)
+	LongLived struct {
+		ExemptTimeouts bool          `yaml:"exempt_timeouts" mapstructure:"exempt_timeouts"`
+		IdleTimeout    time.Duration `yaml:"idle_timeout" mapstructure:"idle_timeout"`     // 0 = no idle limit
+		ShutdownGrace  time.Duration `yaml:"shutdown_grace" mapstructure:"shutdown_grace"` // Time upgraded connections get to close on shutdown
+	} `yaml:"long_lived" mapstructure:"long_lived"`
 	
 	// TLS configuration
 	TLS struct {
@@ -24,6 +43,13 @@ type ProxyConfig struct {
 		Email      string   `yaml:"email,omitempty" mapstructure:"email,omitempty"`
 		MinVersion string   `yaml:"min_version" mapstructure:"min_version"`
 		CacheDir   string   `yaml:"cache_dir,omitempty" mapstructure:"cache_dir,omitempty"`
+		
+		// CA bundle for verifying client certificates. When set, clients are
+		// asked for a certificate, which routes can match on; it stays optional.
+		ClientCAFile string `yaml:"client_ca_file,omitempty" mapstructure:"client_ca_file,omitempty"`
+		
+		// Additional certificates selected by SNI; cert_file is the fallback
+		Certificates []TLSCertificate `yaml:"certificates,omitempty" mapstructure:"certificates,omitempty"`
 	} `yaml:"tls" mapstructure:"tls"`
 	
 	// HTTP/2 and HTTP/3
@@ -39,24 +65,60 @@ type ProxyConfig struct {
 	
 	// Transport configuration
 	Transport struct {
-		MaxIdleConns        int           `yaml:"max_idle_conns" mapstructure:"max_idle_conns"`
-		MaxIdleConnsPerHost int           `yaml:"max_idle_conns_per_host" mapstructure:"max_idle_conns_per_host"`
-		MaxConnsPerHost     int           `yaml:"max_conns_per_host" mapstructure:"max_conns_per_host"`
-		IdleConnTimeout     time.Duration `yaml:"idle_conn_timeout" mapstructure:"idle_conn_timeout"`
-		DialTimeout         time.Duration `yaml:"dial_timeout" mapstructure:"dial_timeout"`
-		KeepAlive           time.Duration `yaml:"keep_alive" mapstructure:"keep_alive"`
-		DisableCompression  bool          `yaml:"disable_compression" mapstructure:"disable_compression"`
-		BufferSize          int           `yaml:"buffer_size" mapstructure:"buffer_size"`
+		MaxIdleConns          int           `yaml:"max_idle_conns" mapstructure:"max_idle_conns"`
+		MaxIdleConnsPerHost   int           `yaml:"max_idle_conns_per_host" mapstructure:"max_idle_conns_per_host"`
+		MaxConnsPerHost       int           `yaml:"max_conns_per_host" mapstructure:"max_conns_per_host"`
+		IdleConnTimeout       time.Duration `yaml:"idle_conn_timeout" mapstructure:"idle_conn_timeout"`
+		DialTimeout           time.Duration `yaml:"dial_timeout" mapstructure:"dial_timeout"`
+		KeepAlive             time.Duration `yaml:"keep_alive" mapstructure:"keep_alive"`
+		ResponseHeaderTimeout time.Duration `yaml:"response_header_timeout" mapstructure:"response_header_timeout"` // Time to wait for backend response headers; the body may stream for longer
+		DisableCompression    bool          `yaml:"disable_compression" mapstructure:"disable_compression"`
+		BufferSize            int           `yaml:"buffer_size" mapstructure:"buffer_size"`
+
+		// Backend DNS resolution
+		DNS struct {
+			CacheTTL        time.Duration `yaml:"cache_ttl" mapstructure:"cache_ttl"`               // How long lookups are cached; 0 disables caching
+			RefreshInterval time.Duration `yaml:"refresh_interval" mapstructure:"refresh_interval"` // How often cached hosts in use are re-resolved; 0 disables refreshing
+			Servers         []string      `yaml:"servers,omitempty" mapstructure:"servers"`         // DNS servers to query directly instead of the system resolver, as host[:port]; their record TTLs cap cache_ttl
+		} `yaml:"dns" mapstructure:"dns"`
 	} `yaml:"transport" mapstructure:"transport"`
 	
+	// Routing
+	DefaultServiceID string `yaml:"default_service_id" mapstructure:"default_service_id"` // Serves requests no route matches; empty returns 404
+	ForwardedPrefix  bool   `yaml:"forwarded_prefix" mapstructure:"forwarded_prefix"`     // Send stripped path prefixes to backends in X-Forwarded-Prefix
+	ErrorFormat      string `yaml:"error_format" mapstructure:"error_format"`             // Proxy error bodies: auto (by Accept), json, text
+	
 	// Load balancing
 	LoadBalancing struct {
-		Algorithm string `yaml:"algorithm" mapstructure:"algorithm"` // round_robin, weighted, least_conn, ip_hash
+		Algorithm string `yaml:"algorithm" mapstructure:"algorithm"` // round_robin, weighted, least_conn, ip_hash, least_time
 		Sticky    struct {
 			Enabled    bool          `yaml:"enabled" mapstructure:"enabled"`
 			CookieName string        `yaml:"cookie_name" mapstructure:"cookie_name"`
 			TTL        time.Duration `yaml:"ttl" mapstructure:"ttl"`
+			Weighted   bool          `yaml:"weighted" mapstructure:"weighted"` // Assign new sessions by weight, draining zero-weight servers
 		} `yaml:"sticky" mapstructure:"sticky"`
+		// Send reconnecting WebSocket clients back to the backend they upgraded on
+		WebSocketAffinity struct {
+			Enabled    bool          `yaml:"enabled" mapstructure:"enabled"`
+			CookieName string        `yaml:"cookie_name" mapstructure:"cookie_name"` // Identifies clients; client IP when empty or absent
+			TTL        time.Duration `yaml:"ttl" mapstructure:"ttl"`                 // How long a pin survives without a reconnect
+		} `yaml:"websocket_affinity" mapstructure:"websocket_affinity"`
+		// Prefer backends whose zone endpoint tag matches the request's zone
+		ZonePreference struct {
+			Enabled     bool   `yaml:"enabled" mapstructure:"enabled"`
+			Header      string `yaml:"header" mapstructure:"header"`             // Request header carrying the caller's zone
+			DefaultZone string `yaml:"default_zone" mapstructure:"default_zone"` // Zone for requests without the header, usually the proxy's own
+		} `yaml:"zone_preference" mapstructure:"zone_preference"`
+		LogDecisions bool `yaml:"log_decisions" mapstructure:"log_decisions"` // Debug-log why each backend was chosen
+		
+		// Tune backend weights from their observed latency and error rate
+		AdaptiveWeights struct {
+			Enabled   bool          `yaml:"enabled" mapstructure:"enabled"`
+			Interval  time.Duration `yaml:"interval" mapstructure:"interval"`     // How often weights are recomputed
+			Samples   int           `yaml:"samples" mapstructure:"samples"`       // Responses averaged over
+			MinWeight int           `yaml:"min_weight" mapstructure:"min_weight"` // Weight of the slowest backends
+			MaxWeight int           `yaml:"max_weight" mapstructure:"max_weight"` // Weight of the fastest backend in a service
+		} `yaml:"adaptive_weights" mapstructure:"adaptive_weights"`
 	} `yaml:"load_balancing" mapstructure:"load_balancing"`
 	
 	// Health checking
@@ -65,6 +127,24 @@ type ProxyConfig struct {
 		Timeout       time.Duration `yaml:"timeout" mapstructure:"timeout"`
 		FailThreshold int           `yaml:"fail_threshold" mapstructure:"fail_threshold"`
 		PassThreshold int           `yaml:"pass_threshold" mapstructure:"pass_threshold"`
+		RetryAfter    time.Duration `yaml:"retry_after" mapstructure:"retry_after"` // Retry-After sent when all backends are unhealthy
+		MaxProbes     int           `yaml:"max_probes" mapstructure:"max_probes"`   // Active checks run at once; others wait (0 = unlimited)
+		
+		// Passive outlier ejection based on live traffic
+		Outlier struct {
+			Enabled           bool          `yaml:"enabled" mapstructure:"enabled"`
+			ConsecutiveErrors int           `yaml:"consecutive_errors" mapstructure:"consecutive_errors"` // 5xx or connect errors before ejection
+			Window            time.Duration `yaml:"window" mapstructure:"window"`                         // Errors further apart than this start a new streak
+			BaseEjectionTime  time.Duration `yaml:"base_ejection_time" mapstructure:"base_ejection_time"` // Multiplied by the number of ejections
+			MaxEjectionTime   time.Duration `yaml:"max_ejection_time" mapstructure:"max_ejection_time"`
+		} `yaml:"outlier" mapstructure:"outlier"`
+		
+		// Weighted health: scale backend weights by recent errors and latency
+		Score struct {
+			Enabled          bool          `yaml:"enabled" mapstructure:"enabled"`
+			Samples          int           `yaml:"samples" mapstructure:"samples"`                     // Responses averaged over
+			LatencyThreshold time.Duration `yaml:"latency_threshold" mapstructure:"latency_threshold"` // Slower averages lower the score (0 = ignore latency)
+		} `yaml:"score" mapstructure:"score"`
 	} `yaml:"health_check" mapstructure:"health_check"`
 	
 	// Circuit breaker
@@ -73,25 +153,64 @@ type ProxyConfig struct {
 		FailureThreshold int           `yaml:"failure_threshold" mapstructure:"failure_threshold"`
 		SuccessThreshold int           `yaml:"success_threshold" mapstructure:"success_threshold"`
 		Timeout          time.Duration `yaml:"timeout" mapstructure:"timeout"`
+		ServerErrors     bool          `yaml:"server_errors" mapstructure:"server_errors"` // Count 5xx responses as failures
 	} `yaml:"circuit_breaker" mapstructure:"circuit_breaker"`
 	
+	// Retries of failed upstream requests
+	Retry struct {
+		Enabled      bool          `yaml:"enabled" mapstructure:"enabled"`
+		MaxAttempts  int           `yaml:"max_attempts" mapstructure:"max_attempts"`
+		InitialDelay time.Duration `yaml:"initial_delay" mapstructure:"initial_delay"`
+		MaxDelay     time.Duration `yaml:"max_delay" mapstructure:"max_delay"`
+	} `yaml:"retry" mapstructure:"retry"`
+	
 	// Rate limiting
 	RateLimit struct {
 		Enabled  bool   `yaml:"enabled" mapstructure:"enabled"`
 		RPS      int    `yaml:"rps" mapstructure:"rps"`
 		Burst    int    `yaml:"burst" mapstructure:"burst"`
-		ByHeader string `yaml:"by_header,omitempty" mapstructure:"by_header,omitempty"`
+		ByHeader string `yaml:"by_header,omitempty" mapstructure:"by_header,omitempty"` // Shorthand for key_by: header:<name>
+		KeyBy    string `yaml:"key_by,omitempty" mapstructure:"key_by,omitempty"`       // ip (default), header:<name> or route
 	} `yaml:"rate_limit" mapstructure:"rate_limit"`
 	
 	// Middleware configuration
 	Middleware struct {
+		// Deadline for every request, independent of route and service
+		// timeouts (0 = none)
+		RequestTimeout time.Duration `yaml:"request_timeout" mapstructure:"request_timeout"`
+		
 		Compression struct {
 			Enabled    bool     `yaml:"enabled" mapstructure:"enabled"`
 			Level      int      `yaml:"level" mapstructure:"level"`
 			Types      []string `yaml:"types" mapstructure:"types"`
 			Algorithms []string `yaml:"algorithms" mapstructure:"algorithms"` // gzip, br, zstd
+			MinSize    int      `yaml:"min_size" mapstructure:"min_size"`     // Smallest body in bytes worth compressing
 		} `yaml:"compression" mapstructure:"compression"`
 		
+		Decompression struct {
+			MaxSize int64 `yaml:"max_size" mapstructure:"max_size"` // Largest inflated request body in bytes
+		} `yaml:"decompression" mapstructure:"decompression"`
+		
+		HeaderLimits struct {
+			MaxCount  int `yaml:"max_count" mapstructure:"max_count"`   // Header fields per request (0 = unlimited)
+			MaxLength int `yaml:"max_length" mapstructure:"max_length"` // Bytes in a single header's name and value (0 = unlimited)
+		} `yaml:"header_limits" mapstructure:"header_limits"`
+		
+		Idempotency struct {
+			Enabled bool          `yaml:"enabled" mapstructure:"enabled"`
+			TTL     time.Duration `yaml:"ttl" mapstructure:"ttl"` // How long responses are kept for replay
+		} `yaml:"idempotency" mapstructure:"idempotency"`
+		
+		// Shed requests by QoS class when the proxy is overloaded, low
+		// priority first
+		LoadShedding struct {
+			Enabled       bool          `yaml:"enabled" mapstructure:"enabled"`
+			MaxConcurrent int           `yaml:"max_concurrent" mapstructure:"max_concurrent"` // In-flight requests at which low priority is shed (0 = no limit)
+			MaxLatency    time.Duration `yaml:"max_latency" mapstructure:"max_latency"`       // Average response time at which low priority is shed (0 = no limit)
+			Header        string        `yaml:"header" mapstructure:"header"`                 // Request header naming the QoS class; high is taken as normal
+			DefaultClass  string        `yaml:"default_class" mapstructure:"default_class"`   // Class of requests naming none: high, normal or low
+		} `yaml:"load_shedding" mapstructure:"load_shedding"`
+		
 		CORS struct {
 			Enabled          bool     `yaml:"enabled" mapstructure:"enabled"`
 			AllowedOrigins   []string `yaml:"allowed_origins" mapstructure:"allowed_origins"`
@@ -127,6 +246,13 @@ type ProxyConfig struct {
 				ClientSecret string `yaml:"client_secret,omitempty" mapstructure:"client_secret,omitempty"`
 				RedirectURL  string `yaml:"redirect_url,omitempty" mapstructure:"redirect_url,omitempty"`
 			} `yaml:"oauth2" mapstructure:"oauth2"`
+			
+			TrustedHeader struct {
+				Enabled        bool     `yaml:"enabled" mapstructure:"enabled"`
+				TrustedProxies []string `yaml:"trusted_proxies,omitempty" mapstructure:"trusted_proxies,omitempty"` // CIDRs or IPs of the auth gateway
+				UserHeader     string   `yaml:"user_header" mapstructure:"user_header"`
+				RoleHeader     string   `yaml:"role_header" mapstructure:"role_header"`
+			} `yaml:"trusted_header" mapstructure:"trusted_header"`
 		} `yaml:"auth" mapstructure:"auth"`
 	} `yaml:"middleware" mapstructure:"middleware"`
 	
@@ -135,6 +261,16 @@ type ProxyConfig struct {
 		Level      string `yaml:"level" mapstructure:"level"`
 		Format     string `yaml:"format" mapstructure:"format"` // json, text
 		AccessLogs bool   `yaml:"access_logs" mapstructure:"access_logs"`
+		
+		// Write access logs to a rotating file instead of the main log
+		AccessLogFile struct {
+			Path           string        `yaml:"path,omitempty" mapstructure:"path,omitempty"`         // Empty logs access through the main logger
+			MaxSize        int           `yaml:"max_size" mapstructure:"max_size"`                     // Megabytes before the file is rotated
+			MaxBackups     int           `yaml:"max_backups" mapstructure:"max_backups"`               // Rotated files kept (0 = all)
+			MaxAge         int           `yaml:"max_age" mapstructure:"max_age"`                       // Days rotated files are kept (0 = forever)
+			Compress       bool          `yaml:"compress" mapstructure:"compress"`                     // Gzip rotated files
+			RotateInterval time.Duration `yaml:"rotate_interval" mapstructure:"rotate_interval"` // Also rotate this often (0 = by size only)
+		} `yaml:"access_log_file" mapstructure:"access_log_file"`
 	} `yaml:"logging" mapstructure:"logging"`
 	
 	Metrics struct {
@@ -142,6 +278,13 @@ type ProxyConfig struct {
 		Path    string `yaml:"path" mapstructure:"path"`
 	} `yaml:"metrics" mapstructure:"metrics"`
 	
+	// Response headers describing how requests sent with X-Discobox-Debug: 1
+	// were handled
+	Debug struct {
+		Enabled   bool `yaml:"enabled" mapstructure:"enabled"`
+		AdminOnly bool `yaml:"admin_only" mapstructure:"admin_only"` // Only for requests carrying an admin user's API key in X-Discobox-Debug-Key
+	} `yaml:"debug" mapstructure:"debug"`
+	
 	// Storage backend
 	Storage struct {
 		Type   string `yaml:"type" mapstructure:"type"` // sqlite, memory, etcd
@@ -149,22 +292,70 @@ type ProxyConfig struct {
 		Prefix string `yaml:"prefix,omitempty" mapstructure:"prefix,omitempty"`
 	} `yaml:"storage" mapstructure:"storage"`
 	
+	// Directory of *.yml files declaring services and routes. Storage is
+	// reconciled to match them at startup and whenever a file changes.
+	ConfigDir string `yaml:"config_dir,omitempty" mapstructure:"config_dir,omitempty"`
+	
 	// Admin API
 	API struct {
-		Enabled bool   `yaml:"enabled" mapstructure:"enabled"`
-		Addr    string `yaml:"addr" mapstructure:"addr"`
-		Auth    bool   `yaml:"auth" mapstructure:"auth"`
-		APIKey  string `yaml:"api_key,omitempty" mapstructure:"api_key,omitempty"`
+		Enabled        bool   `yaml:"enabled" mapstructure:"enabled"`
+		Addr           string `yaml:"addr" mapstructure:"addr"`
+		Auth           bool   `yaml:"auth" mapstructure:"auth"`
+		APIKey         string `yaml:"api_key,omitempty" mapstructure:"api_key,omitempty"`
+		ProbeEndpoints bool   `yaml:"probe_endpoints" mapstructure:"probe_endpoints"` // Reject services whose endpoints refuse connections
+		
+		// Credentials for /api/v1/admin/*, checked instead of the general API
+		// auth there whether or not api.auth is on. General API keys don't
+		// open admin routes while this is configured.
+		AdminAuth struct {
+			Type     string `yaml:"type,omitempty" mapstructure:"type,omitempty"`         // api_key, bearer, basic or jwt; empty uses the general API auth
+			Token    string `yaml:"token,omitempty" mapstructure:"token,omitempty"`       // Sent in X-API-Key (api_key) or as a bearer token (bearer)
+			Username string `yaml:"username,omitempty" mapstructure:"username,omitempty"` // basic
+			Password string `yaml:"password,omitempty" mapstructure:"password,omitempty"` // basic
+			
+			JWT struct {
+				Issuer   string `yaml:"issuer,omitempty" mapstructure:"issuer,omitempty"`
+				Audience string `yaml:"audience,omitempty" mapstructure:"audience,omitempty"`
+				KeyFile  string `yaml:"key_file,omitempty" mapstructure:"key_file,omitempty"`
+			} `yaml:"jwt" mapstructure:"jwt"`
+		} `yaml:"admin_auth" mapstructure:"admin_auth"`
+		
+		// What API auth does when storage can't be reached to check a key
+		StorageFailure struct {
+			Mode        string        `yaml:"mode" mapstructure:"mode"`                 // closed rejects requests; open lets them through without a user
+			GracePeriod time.Duration `yaml:"grace_period" mapstructure:"grace_period"` // How long into an outage open mode lasts; 0 for all of it
+		} `yaml:"storage_failure" mapstructure:"storage_failure"`
+		
+		// Where password reset tokens are delivered; forgot password is
+		// disabled without a webhook
+		PasswordReset struct {
+			WebhookURL string        `yaml:"webhook_url,omitempty" mapstructure:"webhook_url,omitempty"` // Receives each token as a JSON POST
+			Timeout    time.Duration `yaml:"timeout" mapstructure:"timeout"`
+		} `yaml:"password_reset" mapstructure:"password_reset"`
 	} `yaml:"api" mapstructure:"api"`
 	
 	// Web UI
 	UI struct {
 		Enabled bool   `yaml:"enabled" mapstructure:"enabled"`
 		Path    string `yaml:"path" mapstructure:"path"`
+		Dir     string `yaml:"dir,omitempty" mapstructure:"dir,omitempty"` // Serve the UI from this directory instead of the built-in files
 	} `yaml:"ui" mapstructure:"ui"`
 }
 
+// ProxyListenAddrs returns every address the proxy listens on: ListenAddr
+// followed by ListenAddrs
+func (c *ProxyConfig) ProxyListenAddrs() []string {
+	return append([]string{c.ListenAddr}, c.ListenAddrs...)
+}
+
 // ParseURL is a helper function to parse URLs
 func ParseURL(urlStr string) (*url.URL, error) {
 	return url.Parse(urlStr)
-}
\ No newline at end of file
+}
+
+// TLSCertificate is a certificate/key pair served for specific hostnames
+type TLSCertificate struct {
+	CertFile string   `yaml:"cert_file" mapstructure:"cert_file"`
+	KeyFile  string   `yaml:"key_file" mapstructure:"key_file"`
+	Hosts    []string `yaml:"hosts,omitempty" mapstructure:"hosts,omitempty"` // Defaults to the certificate's names
+}
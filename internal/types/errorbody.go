@@ -0,0 +1,79 @@
+package types
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Formats for error bodies the proxy sends, set by error_format
+const (
+	// ErrorFormatAuto sends JSON to clients that prefer it in Accept, else text
+	ErrorFormatAuto = "auto"
+	ErrorFormatJSON = "json"
+	ErrorFormatText = "text"
+)
+
+// WriteError sends msg as a {"error": msg} JSON body, matching the admin
+// API's error responses, or as plain text, as format and the request's
+// Accept header call for. fields are added to JSON bodies; plain text
+// bodies carry only msg.
+func WriteError(w http.ResponseWriter, r *http.Request, format, msg string, statusCode int, fields map[string]any) {
+	if !wantsJSONError(r, format) {
+		http.Error(w, msg, statusCode)
+		return
+	}
+
+	body := make(map[string]any, len(fields)+1)
+	for k, v := range fields {
+		body[k] = v
+	}
+	body["error"] = msg
+
+	h := w.Header()
+	h.Del("Content-Length")
+	h.Set("Content-Type", "application/json")
+	h.Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(body)
+}
+
+// wantsJSONError reports whether error bodies for r should be JSON
+func wantsJSONError(r *http.Request, format string) bool {
+	switch format {
+	case ErrorFormatJSON:
+		return true
+	case ErrorFormatText:
+		return false
+	}
+	return prefersJSON(r.Header.Get("Accept"))
+}
+
+// prefersJSON reports whether an Accept header ranks a JSON media type at
+// least as high as any text type. Wildcards alone keep plain text.
+func prefersJSON(accept string) bool {
+	var jsonQ, textQ float64
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, _ := strings.Cut(part, ";")
+		mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if ok && strings.EqualFold(strings.TrimSpace(key), "q") {
+				if parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+
+		switch {
+		case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+			jsonQ = max(jsonQ, q)
+		case strings.HasPrefix(mediaType, "text/"):
+			textQ = max(textQ, q)
+		}
+	}
+	return jsonQ > 0 && jsonQ >= textQ
+}
@@ -13,6 +13,12 @@ var (
 	// ErrRouteNotFound indicates the requested route does not exist
 	ErrRouteNotFound = errors.New("route not found")
 	
+	// ErrUserNotFound indicates the requested user does not exist
+	ErrUserNotFound = errors.New("user not found")
+	
+	// ErrAPIKeyNotFound indicates the requested API key does not exist
+	ErrAPIKeyNotFound = errors.New("API key not found")
+	
 	// ErrNoHealthyBackends indicates all backends are unhealthy
 	ErrNoHealthyBackends = errors.New("no healthy backends available")
 	
@@ -31,9 +37,15 @@ var (
 	// ErrAlreadyExists indicates a resource already exists
 	ErrAlreadyExists = errors.New("resource already exists")
 	
+	// ErrVersionConflict indicates a resource changed since the version the caller last read
+	ErrVersionConflict = errors.New("version conflict")
+	
 	// ErrInvalidRequest indicates an invalid request
 	ErrInvalidRequest = errors.New("invalid request")
 	
+	// ErrInvalidToken indicates a token is unknown, expired or already used
+	ErrInvalidToken = errors.New("invalid or expired token")
+	
 	// ErrUnauthorized indicates authentication is required
 	ErrUnauthorized = errors.New("unauthorized")
 	
@@ -54,12 +66,21 @@ var (
 	
 	// ErrServerNotFound indicates the requested server does not exist
 	ErrServerNotFound = errors.New("server not found")
+	
+	// ErrBodyTooLarge indicates a request body exceeds the allowed size
+	ErrBodyTooLarge = errors.New("request body too large")
+	
+	// ErrTooManyRedirects indicates a backend redirect chain looped or exceeded the hop limit
+	ErrTooManyRedirects = errors.New("too many redirects")
+	
+	// ErrResponseTooLarge indicates a backend response body exceeds the route's limit
+	ErrResponseTooLarge = errors.New("response body too large")
 )
 
 // ValidationError represents a validation error with details
 type ValidationError struct {
-	Field   string
-	Message string
+	Field   string `json:"field"`
+	Message string `json:"message"`
 }
 
 func (e ValidationError) Error() string {
@@ -19,6 +19,27 @@ type LoadBalancer interface {
 	UpdateWeight(serverID string, weight int) error
 }
 
+// LatencyObserver is implemented by load balancers that pick servers by
+// response time; the proxy reports how long each backend took to respond
+type LatencyObserver interface {
+	// ObserveLatency records the time a backend took to return headers
+	ObserveLatency(serverID string, latency time.Duration)
+}
+
+// ResultObserver is implemented by load balancers that adapt to how backends
+// respond; the proxy reports the outcome and duration of each request
+type ResultObserver interface {
+	// ObserveResult records whether a backend request failed and how long it took
+	ObserveResult(serverID string, failed bool, latency time.Duration)
+}
+
+// SelectionExplainer is implemented by load balancers that can report why
+// they picked a server; the proxy uses it to log selection decisions
+type SelectionExplainer interface {
+	// SelectWithReason selects a server like Select and explains the choice
+	SelectWithReason(ctx context.Context, req *http.Request, servers []*Server) (*Server, *SelectionReason, error)
+}
+
 // HealthChecker monitors backend health
 type HealthChecker interface {
 	// Check performs a health check on the server
@@ -31,6 +52,25 @@ type HealthChecker interface {
 	RecordFailure(serverID string, err error)
 }
 
+// OutlierDetector ejects backends that fail repeatedly (passive health)
+type OutlierDetector interface {
+	// RecordSuccess records a request the backend answered
+	RecordSuccess(serverID string)
+	// RecordFailure records a 5xx response or connection error
+	RecordFailure(serverID string, err error)
+	// IsEjected reports whether the backend is currently out of the pool
+	IsEjected(serverID string) bool
+}
+
+// HealthScorer rates backends from their recent responses so weighted
+// balancers shed load from struggling backends before they are ejected
+type HealthScorer interface {
+	// RecordResult records a response or failure and how long it took
+	RecordResult(serverID string, failed bool, latency time.Duration)
+	// Score returns the backend's health from 0 (failing) to 1 (healthy)
+	Score(serverID string) float64
+}
+
 // CircuitBreaker protects backends from cascading failures
 type CircuitBreaker interface {
 	// Execute runs the function with circuit breaker protection
@@ -41,6 +81,19 @@ type CircuitBreaker interface {
 	Reset()
 }
 
+// CircuitBreakerSet hands out a circuit breaker per service
+type CircuitBreakerSet interface {
+	// ForService returns the breaker guarding service, built from the
+	// global settings with the service's overrides applied
+	ForService(service *Service) CircuitBreaker
+	// GetAllStates returns the state of each service's breaker
+	GetAllStates() map[string]string
+	// RemoveBreaker forgets the breaker of a deleted service
+	RemoveBreaker(serviceID string)
+	// ServerErrors reports whether 5xx responses count as failures
+	ServerErrors() bool
+}
+
 // RateLimiter controls request rates
 type RateLimiter interface {
 	// Allow checks if a request should be allowed
@@ -71,7 +124,12 @@ type Router interface {
 	GetRoutes() ([]*Route, error)
 }
 
-// Storage persists configuration
+// Storage persists configuration.
+//
+// Services and routes carry a Version that starts at 1 and is bumped by every
+// successful update. Updates with a non-zero Version fail with ErrVersionConflict
+// if the stored version differs. A zero Version forces the update; the API
+// only sends one for If-Match: *, and requires a version otherwise.
 type Storage interface {
 	// Services
 	GetService(ctx context.Context, id string) (*Service, error)
@@ -87,9 +145,15 @@ type Storage interface {
 	UpdateRoute(ctx context.Context, route *Route) error
 	DeleteRoute(ctx context.Context, id string) error
 
+	// Route groups. Deleting a group removes all of its routes atomically and
+	// returns how many were removed.
+	ListRoutesByGroup(ctx context.Context, group string) ([]*Route, error)
+	DeleteRouteGroup(ctx context.Context, group string) (int, error)
+
 	// Users
 	GetUser(ctx context.Context, id string) (*User, error)
 	GetUserByUsername(ctx context.Context, username string) (*User, error)
+	GetUserByEmail(ctx context.Context, email string) (*User, error) // Case-insensitive
 	ListUsers(ctx context.Context) ([]*User, error)
 	CreateUser(ctx context.Context, user *User) error
 	UpdateUser(ctx context.Context, user *User) error
@@ -97,9 +161,28 @@ type Storage interface {
 
 	// API Keys
 	GetAPIKey(ctx context.Context, key string) (*APIKey, error)
+	// ListAPIKeys returns every user's keys, newest first
+	ListAPIKeys(ctx context.Context) ([]*APIKey, error)
 	ListAPIKeysByUser(ctx context.Context, userID string) ([]*APIKey, error)
 	CreateAPIKey(ctx context.Context, apiKey *APIKey) error
 	RevokeAPIKey(ctx context.Context, key string) error
+	// RevokeAllAPIKeysByUser revokes every active key the user holds, session
+	// keys included, and returns how many were revoked
+	RevokeAllAPIKeysByUser(ctx context.Context, userID string) (int, error)
+
+	// Password reset tokens. Creating a token replaces any outstanding tokens for
+	// the same user; consuming marks it used and fails with ErrInvalidToken when
+	// the token is unknown, expired or already used.
+	CreatePasswordResetToken(ctx context.Context, token *PasswordResetToken) error
+	ConsumePasswordResetToken(ctx context.Context, tokenHash string) (*PasswordResetToken, error)
+
+	// Tx runs fn against a view of the storage whose writes are applied
+	// together if fn returns nil and rolled back if it returns an error.
+	// Watchers hear about the writes once they are applied. fn must use the
+	// view it is given rather than the outer storage; calling Tx on the view
+	// joins the enclosing transaction. Backends without native transactions
+	// emulate them with a lock.
+	Tx(ctx context.Context, fn func(Storage) error) error
 
 	// Watch for changes
 	Watch(ctx context.Context) <-chan StorageEvent
@@ -0,0 +1,105 @@
+package types
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// jsonPathStep is one step of a parsed JSON path: an object key, or an
+// array index when key is empty
+type jsonPathStep struct {
+	key   string
+	index int
+}
+
+// parseJSONPath parses the JSONPath subset used by health checks: an
+// optional leading $, then .key, ["key"] or [index] steps, e.g.
+// $.checks.db.status or $.nodes[0].state
+func parseJSONPath(path string) ([]jsonPathStep, error) {
+	rest, rooted := strings.CutPrefix(strings.TrimSpace(path), "$")
+	if rest == "" {
+		return nil, fmt.Errorf("json path %q selects nothing", path)
+	}
+	if !rooted && rest[0] != '.' && rest[0] != '[' {
+		// A bare key, as in status.code
+		rest = "." + rest
+	}
+
+	var steps []jsonPathStep
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end == -1 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("json path %q has an empty key", path)
+			}
+			steps = append(steps, jsonPathStep{key: rest[:end]})
+			rest = rest[end:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end == -1 {
+				return nil, fmt.Errorf("json path %q has an unclosed [", path)
+			}
+			inner := rest[1:end]
+			rest = rest[end+1:]
+
+			if key, err := strconv.Unquote(inner); err == nil && key != "" {
+				steps = append(steps, jsonPathStep{key: key})
+				continue
+			}
+			index, err := strconv.Atoi(inner)
+			if err != nil || index < 0 {
+				return nil, fmt.Errorf("json path %q has an invalid index [%s]", path, inner)
+			}
+			steps = append(steps, jsonPathStep{index: index})
+		default:
+			return nil, fmt.Errorf("json path %q is malformed at %q", path, rest)
+		}
+	}
+	return steps, nil
+}
+
+// ValidateJSONPath reports whether path can be used as a health check
+// json_path
+func ValidateJSONPath(path string) error {
+	_, err := parseJSONPath(path)
+	return err
+}
+
+// LookupJSONPath returns the value at path in a document decoded with
+// encoding/json
+func LookupJSONPath(doc any, path string) (any, error) {
+	steps, err := parseJSONPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	value := doc
+	for _, step := range steps {
+		if step.key != "" {
+			object, ok := value.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("%s: %q is not inside an object", path, step.key)
+			}
+			if value, ok = object[step.key]; !ok {
+				return nil, fmt.Errorf("%s: no %q field", path, step.key)
+			}
+			continue
+		}
+
+		array, ok := value.([]any)
+		if !ok {
+			return nil, fmt.Errorf("%s: [%d] is not inside an array", path, step.index)
+		}
+		if step.index >= len(array) {
+			return nil, fmt.Errorf("%s: index %d out of range", path, step.index)
+		}
+		value = array[step.index]
+	}
+	return value, nil
+}
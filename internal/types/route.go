@@ -1,22 +1,42 @@
 package types
 
 import (
+	"encoding/json"
+	"fmt"
+	"maps"
 	"net/http"
+	"slices"
 	"strings"
+	"time"
 )
 
 // Route represents a routing rule
 type Route struct {
-	ID           string            `json:"id" yaml:"id"`
-	Priority     int               `json:"priority" yaml:"priority"`
-	Host         string            `json:"host,omitempty" yaml:"host,omitempty"`
-	PathPrefix   string            `json:"path_prefix,omitempty" yaml:"path_prefix,omitempty"`
-	PathRegex    string            `json:"path_regex,omitempty" yaml:"path_regex,omitempty"`
-	Headers      map[string]string `json:"headers,omitempty" yaml:"headers,omitempty"`
-	ServiceID    string            `json:"service_id" yaml:"service_id"`
-	Middlewares  []string          `json:"middlewares" yaml:"middlewares"`
-	RewriteRules []RewriteRule     `json:"rewrite_rules,omitempty" yaml:"rewrite_rules,omitempty"`
-	Metadata     map[string]any    `json:"metadata,omitempty" yaml:"metadata,omitempty"`
+	ID                  string            `json:"id" yaml:"id"`
+	Group               string            `json:"group,omitempty" yaml:"group,omitempty"` // Organizational only; does not affect matching
+	Priority            int               `json:"priority" yaml:"priority"`
+	Host                string            `json:"host,omitempty" yaml:"host,omitempty"`
+	PathPrefix          string            `json:"path_prefix,omitempty" yaml:"path_prefix,omitempty"`
+	PathRegex           string            `json:"path_regex,omitempty" yaml:"path_regex,omitempty"`
+	PathSuffixes        []string          `json:"path_suffixes,omitempty" yaml:"path_suffixes,omitempty"` // Path must end with one of these, e.g. .js or .css
+	Headers             map[string]string `json:"headers,omitempty" yaml:"headers,omitempty"`
+	SNI                 string            `json:"sni,omitempty" yaml:"sni,omitempty"`                                 // TLS server name the client asked for
+	ClientCertSubject   string            `json:"client_cert_subject,omitempty" yaml:"client_cert_subject,omitempty"` // Subject DN or common name of the client certificate
+	ContentType         string            `json:"content_type,omitempty" yaml:"content_type,omitempty"`               // Prefix of the request's media type, e.g. application/grpc
+	ServiceID           string            `json:"service_id" yaml:"service_id"`
+	StripPathPrefix     string            `json:"strip_path_prefix,omitempty" yaml:"strip_path_prefix,omitempty"`   // Removed from the path before forwarding; overrides the service's strip_prefix
+	AddPathPrefix       string            `json:"add_path_prefix,omitempty" yaml:"add_path_prefix,omitempty"`       // Prepended to the path after rewriting and stripping
+	UpstreamScheme      string            `json:"upstream_scheme,omitempty" yaml:"upstream_scheme,omitempty"`       // http or https to backends whatever their endpoint scheme; overrides the service's
+	MaxResponseBytes    int64             `json:"max_response_bytes,omitempty" yaml:"max_response_bytes,omitempty"` // Cap on backend response bodies; 0 = unlimited
+	Middlewares         []string          `json:"middlewares" yaml:"middlewares"`
+	DisabledMiddlewares []string          `json:"disabled_middlewares,omitempty" yaml:"disabled_middlewares,omitempty"` // Global middleware skipped for this route
+	RewriteRules        []RewriteRule     `json:"rewrite_rules,omitempty" yaml:"rewrite_rules,omitempty"`
+	Redirects           *RedirectPolicy   `json:"redirects,omitempty" yaml:"redirects,omitempty"`
+	Hedging             *HedgePolicy      `json:"hedging,omitempty" yaml:"hedging,omitempty"`
+	EarlyHints          []string          `json:"early_hints,omitempty" yaml:"early_hints,omitempty"` // Link header values sent in a 103 response before proxying
+	Canary              *CanaryPolicy     `json:"canary,omitempty" yaml:"canary,omitempty"`
+	Metadata            map[string]any    `json:"metadata,omitempty" yaml:"metadata,omitempty"`
+	Version             int64             `json:"version" yaml:"version"` // Bumped on every update; used for optimistic concurrency
 }
 
 // RewriteRule defines URL rewriting rules
@@ -26,6 +46,93 @@ type RewriteRule struct {
 	Replacement string `json:"replacement,omitempty" yaml:"replacement,omitempty"`
 }
 
+// Redirect handling modes for backend 3xx responses
+const (
+	RedirectPassThrough = "pass"    // Return the backend's redirect unchanged
+	RedirectFollow      = "follow"  // Follow redirects to the service's backends server-side
+	RedirectRewrite     = "rewrite" // Point Location headers at the public host
+)
+
+// TLSMatchRegexPrefix marks an SNI or client certificate subject criterion as
+// a regular expression rather than an exact value, e.g. "~^CN=svc-.*"
+const TLSMatchRegexPrefix = "~"
+
+// MiddlewareDecompressRequest is the route middleware that inflates gzip and
+// deflate request bodies before they are forwarded
+const MiddlewareDecompressRequest = "decompress-request"
+
+// DefaultMaxRedirects bounds server-side redirect following when MaxHops is unset
+const DefaultMaxRedirects = 5
+
+// RedirectPolicy controls how redirects returned by backends are handled
+type RedirectPolicy struct {
+	Mode    string `json:"mode" yaml:"mode"`                             // pass, follow, rewrite
+	MaxHops int    `json:"max_hops,omitempty" yaml:"max_hops,omitempty"` // Follow mode only
+}
+
+// HedgePolicy sends a second copy of a safe request to another backend when
+// the first hasn't answered within Delay; whichever responds first is used
+type HedgePolicy struct {
+	Delay time.Duration `json:"delay" yaml:"delay"`
+}
+
+// MarshalJSON encodes the delay as a duration string such as "50ms"
+func (h HedgePolicy) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Delay string `json:"delay"`
+	}{Delay: h.Delay.String()})
+}
+
+// UnmarshalJSON accepts the delay as a duration string
+func (h *HedgePolicy) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Delay string `json:"delay"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	h.Delay = 0
+	if raw.Delay != "" {
+		delay, err := time.ParseDuration(raw.Delay)
+		if err != nil {
+			return fmt.Errorf("invalid hedging delay: %w", err)
+		}
+		h.Delay = delay
+	}
+	return nil
+}
+
+// CanaryPolicy sends a share of a route's clients to a second service. Each
+// client's variant is kept in a cookie so its whole session stays on it.
+type CanaryPolicy struct {
+	ServiceID string `json:"service_id" yaml:"service_id"`
+	Weight    int    `json:"weight" yaml:"weight"` // Percent of new clients given the canary, 0-100
+}
+
+// CanaryCookiePrefix starts the name of the cookie pinning a client to a
+// route's canary variant
+const CanaryCookiePrefix = "discobox_variant_"
+
+// CanaryCookieName returns the name of the cookie holding a client's variant
+// for a route. Each route has its own, so variants of routes sharing a host
+// don't overwrite each other. Characters not allowed in cookie names become
+// underscores.
+func CanaryCookieName(routeID string) string {
+	return CanaryCookiePrefix + strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '.', r == '_':
+			return r
+		}
+		return '_'
+	}, routeID)
+}
+
+// Canary variants stored in the CanaryCookieName cookie
+const (
+	VariantStable = "stable"
+	VariantCanary = "canary"
+)
+
 // MatchesHost returns true if the route matches the given host
 func (r *Route) MatchesHost(host string) bool {
 	if r.Host == "" {
@@ -85,6 +192,74 @@ func (r *Route) MatchesHeaders(headers http.Header) bool {
 	return true
 }
 
+// SameMatch returns true if both routes match exactly the same requests at the
+// same priority, leaving the router no deterministic way to pick one
+func (r *Route) SameMatch(other *Route) bool {
+	if r.Priority != other.Priority ||
+		!strings.EqualFold(r.Host, other.Host) ||
+		r.PathPrefix != other.PathPrefix ||
+		r.PathRegex != other.PathRegex ||
+		r.SNI != other.SNI ||
+		r.ClientCertSubject != other.ClientCertSubject ||
+		!strings.EqualFold(r.ContentType, other.ContentType) ||
+		len(r.Headers) != len(other.Headers) ||
+		len(r.PathSuffixes) != len(other.PathSuffixes) {
+		return false
+	}
+
+	// Suffixes are an unordered, case-insensitive set
+	for _, suffix := range r.PathSuffixes {
+		if !slices.ContainsFunc(other.PathSuffixes, func(s string) bool { return strings.EqualFold(s, suffix) }) {
+			return false
+		}
+	}
+
+	// Header names are case-insensitive
+	headers := make(http.Header, len(other.Headers))
+	for key, value := range other.Headers {
+		headers.Set(key, value)
+	}
+	for key, value := range r.Headers {
+		values, ok := headers[http.CanonicalHeaderKey(key)]
+		if !ok || values[0] != value {
+			return false
+		}
+	}
+
+	return true
+}
+
+// MatchesContentType reports whether a request Content-Type header starts
+// with the route's ContentType. Parameters such as charset are ignored and
+// the comparison is case-insensitive, so "application/grpc" matches
+// "application/grpc+proto". Routes without ContentType match any request.
+func (r *Route) MatchesContentType(contentType string) bool {
+	if r.ContentType == "" {
+		return true
+	}
+
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	return strings.HasPrefix(mediaType, strings.ToLower(r.ContentType))
+}
+
+// MatchesPathSuffix reports whether path ends with one of the route's
+// PathSuffixes, ignoring case so ".js" also matches "/app.JS". Routes without
+// PathSuffixes match any path.
+func (r *Route) MatchesPathSuffix(path string) bool {
+	if len(r.PathSuffixes) == 0 {
+		return true
+	}
+
+	path = strings.ToLower(path)
+	for _, suffix := range r.PathSuffixes {
+		if strings.HasSuffix(path, strings.ToLower(suffix)) {
+			return true
+		}
+	}
+	return false
+}
+
 // HasMiddleware returns true if the route has the specified middleware
 func (r *Route) HasMiddleware(name string) bool {
 	for _, mw := range r.Middlewares {
@@ -95,6 +270,111 @@ func (r *Route) HasMiddleware(name string) bool {
 	return false
 }
 
+// DisablesMiddleware returns true if the route skips the named global middleware
+func (r *Route) DisablesMiddleware(name string) bool {
+	for _, mw := range r.DisabledMiddlewares {
+		if mw == name {
+			return true
+		}
+	}
+	return false
+}
+
+// RedirectMode returns the route's redirect handling mode
+func (r *Route) RedirectMode() string {
+	if r.Redirects == nil || r.Redirects.Mode == "" {
+		return RedirectPassThrough
+	}
+	return r.Redirects.Mode
+}
+
+// Clone returns a deep copy of the route, so changing the copy's slices,
+// maps, metadata or policies leaves r as it was
+func (r *Route) Clone() *Route {
+	clone := *r
+	clone.PathSuffixes = slices.Clone(r.PathSuffixes)
+	clone.Headers = maps.Clone(r.Headers)
+	clone.Middlewares = slices.Clone(r.Middlewares)
+	clone.DisabledMiddlewares = slices.Clone(r.DisabledMiddlewares)
+	clone.RewriteRules = slices.Clone(r.RewriteRules)
+	clone.EarlyHints = slices.Clone(r.EarlyHints)
+	if r.Redirects != nil {
+		redirects := *r.Redirects
+		clone.Redirects = &redirects
+	}
+	if r.Hedging != nil {
+		hedging := *r.Hedging
+		clone.Hedging = &hedging
+	}
+	if r.Canary != nil {
+		canary := *r.Canary
+		clone.Canary = &canary
+	}
+	if r.Metadata != nil {
+		clone.Metadata = cloneJSONValue(r.Metadata).(map[string]any)
+	}
+	return &clone
+}
+
+// cloneJSONValue deep-copies the maps and slices of a decoded JSON value
+func cloneJSONValue(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		clone := make(map[string]any, len(v))
+		for key, item := range v {
+			clone[key] = cloneJSONValue(item)
+		}
+		return clone
+	case []any:
+		clone := make([]any, len(v))
+		for i, item := range v {
+			clone[i] = cloneJSONValue(item)
+		}
+		return clone
+	}
+	return value
+}
+
+// RequiresTLS reports whether the route has criteria that can only be met
+// by a TLS connection
+func (r *Route) RequiresTLS() bool {
+	return r.SNI != "" || r.ClientCertSubject != ""
+}
+
+// StrippedPrefix returns the prefix removed from request paths before they
+// are forwarded: StripPathPrefix when set, otherwise the matched PathPrefix
+// if the service strips prefixes
+func (r *Route) StrippedPrefix(service *Service) string {
+	if r.StripPathPrefix != "" {
+		return r.StripPathPrefix
+	}
+	if service != nil && service.StripPrefix {
+		return r.PathPrefix
+	}
+	return ""
+}
+
+// BackendScheme returns the scheme forced on requests to the service's
+// backends: the route's UpstreamScheme when set, otherwise the service's.
+// Empty keeps each endpoint's own scheme.
+func (r *Route) BackendScheme(service *Service) string {
+	if r.UpstreamScheme != "" {
+		return r.UpstreamScheme
+	}
+	if service != nil {
+		return service.UpstreamScheme
+	}
+	return ""
+}
+
+// HedgeDelay returns how long to wait before hedging, or zero when hedging is off
+func (r *Route) HedgeDelay() time.Duration {
+	if r.Hedging == nil || r.Hedging.Delay <= 0 {
+		return 0
+	}
+	return r.Hedging.Delay
+}
+
 // GetRewriteRule returns the rewrite rule of the specified type
 func (r *Route) GetRewriteRule(ruleType string) *RewriteRule {
 	for _, rule := range r.RewriteRules {
@@ -0,0 +1,20 @@
+package types
+
+// RuntimeStats is a point-in-time snapshot of proxy internals for diagnostics
+type RuntimeStats struct {
+	Routes          int               // Routes loaded by the router
+	CircuitBreakers map[string]string // Breaker scope -> state (closed, open, half-open)
+	Backends        []BackendStats
+}
+
+// BackendStats describes a backend as the proxy currently sees it
+type BackendStats struct {
+	ID             string
+	ServiceID      string
+	URL            string
+	ActiveConns    int64
+	Healthy        bool
+	Ejected        bool           // Taken out of the pool by outlier detection
+	Health         map[string]any // Health checker details, if tracked
+	HealthOverride *bool          // Health forced by an operator, nil when the checks decide
+}
@@ -5,14 +5,34 @@ import (
 	"time"
 )
 
+// TagZone is the endpoint tag naming the zone a backend runs in
+const TagZone = "zone"
+
 // Server represents a backend server instance
 type Server struct {
 	URL         *url.URL
 	ID          string
+	Index       int // Position of the server's endpoint in its service's endpoints
 	Weight      int
 	MaxConns    int
 	ActiveConns int64
 	Healthy     bool
-	Metadata    map[string]string
+	Metadata    map[string]string  // Service metadata plus the endpoint's tags
+	HealthCheck *HealthCheckConfig // Criteria for active health checks; nil accepts any 2xx
 	LastUsed    time.Time
-}
\ No newline at end of file
+}
+
+// SelectionReason explains a load balancer's choice of server
+type SelectionReason struct {
+	Algorithm  string               `json:"algorithm"`
+	Reason     string               `json:"reason"` // e.g. "fewest active connections"
+	Candidates []SelectionCandidate `json:"candidates"`
+}
+
+// SelectionCandidate is a server's state when a selection was made
+type SelectionCandidate struct {
+	ServerID    string `json:"server_id"`
+	ActiveConns int64  `json:"active_conns"`
+	Weight      int    `json:"weight"`
+	Eligible    bool   `json:"eligible"` // Healthy and under its connection limit
+}
@@ -1,24 +1,95 @@
 package types
 
 import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/url"
+	"slices"
 	"time"
 )
 
 // Service represents a backend service
 type Service struct {
-	ID          string            `json:"id" yaml:"id"`
-	Name        string            `json:"name" yaml:"name"`
-	Endpoints   []string          `json:"endpoints" yaml:"endpoints"`
-	HealthPath  string            `json:"health_path" yaml:"health_path"`
-	Weight      int               `json:"weight" yaml:"weight"`
-	MaxConns    int               `json:"max_conns" yaml:"max_conns"`
-	Timeout     time.Duration     `json:"timeout" yaml:"timeout"`
-	Metadata    map[string]string `json:"metadata" yaml:"metadata"`
-	TLS         *TLSConfig        `json:"tls,omitempty" yaml:"tls,omitempty"`
-	StripPrefix bool              `json:"strip_prefix" yaml:"strip_prefix"`
-	Active      bool              `json:"active" yaml:"active"`
-	CreatedAt   time.Time         `json:"created_at" yaml:"created_at"`
-	UpdatedAt   time.Time         `json:"updated_at" yaml:"updated_at"`
+	ID             string                `json:"id" yaml:"id"`
+	Name           string                `json:"name" yaml:"name"`
+	Endpoints      []string              `json:"endpoints" yaml:"endpoints"`
+	HealthPath     string                `json:"health_path" yaml:"health_path"`
+	HealthCheck    *HealthCheckConfig    `json:"health_check,omitempty" yaml:"health_check,omitempty"`
+	CircuitBreaker *CircuitBreakerConfig `json:"circuit_breaker,omitempty" yaml:"circuit_breaker,omitempty"`
+	Weight         int                   `json:"weight" yaml:"weight"`
+	MaxConns       int                   `json:"max_conns" yaml:"max_conns"`
+	Timeout        time.Duration         `json:"timeout" yaml:"timeout"`
+	Metadata       map[string]string     `json:"metadata" yaml:"metadata"`
+	// EndpointTags labels individual endpoints, keyed by endpoint URL, e.g.
+	// {"http://10.0.1.5:8080": {"zone": "us-east-1a", "version": "v2"}}
+	EndpointTags map[string]map[string]string `json:"endpoint_tags,omitempty" yaml:"endpoint_tags,omitempty"`
+	TLS          *TLSConfig                   `json:"tls,omitempty" yaml:"tls,omitempty"`
+	StripPrefix  bool                         `json:"strip_prefix" yaml:"strip_prefix"`
+	// UpstreamScheme forces http or https to the backends whatever their
+	// endpoint scheme, e.g. https to reach backends listed as http
+	UpstreamScheme string    `json:"upstream_scheme,omitempty" yaml:"upstream_scheme,omitempty"`
+	Active         bool      `json:"active" yaml:"active"`
+	Version        int64     `json:"version" yaml:"version"` // Bumped on every update; used for optimistic concurrency
+	CreatedAt      time.Time `json:"created_at" yaml:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at" yaml:"updated_at"`
+}
+
+// HealthCheckConfig decides which active health check responses count as healthy
+type HealthCheckConfig struct {
+	StatusCodes  []int  `json:"status_codes,omitempty" yaml:"status_codes,omitempty"`   // Any 2xx when empty
+	BodyContains string `json:"body_contains,omitempty" yaml:"body_contains,omitempty"` // Substring the response body must contain
+	JSONPath     string `json:"json_path,omitempty" yaml:"json_path,omitempty"`         // Field of a JSON body to check, e.g. $.status
+	JSONEquals   string `json:"json_equals,omitempty" yaml:"json_equals,omitempty"`     // Value the field must have, e.g. UP
+}
+
+// AcceptsStatus reports whether a health check response status counts as healthy
+func (c *HealthCheckConfig) AcceptsStatus(code int) bool {
+	if c == nil || len(c.StatusCodes) == 0 {
+		return code >= 200 && code < 300
+	}
+	for _, accepted := range c.StatusCodes {
+		if code == accepted {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchesJSON returns an error unless body is JSON with JSONEquals at
+// JSONPath. Strings compare by their contents, other values by their JSON
+// text, so "true" matches a boolean and "3" the number 3.
+func (c *HealthCheckConfig) MatchesJSON(body []byte) error {
+	var doc any
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return fmt.Errorf("health check body is not JSON: %w", err)
+	}
+
+	value, err := LookupJSONPath(doc, c.JSONPath)
+	if err != nil {
+		return err
+	}
+
+	actual, ok := value.(string)
+	if !ok {
+		text, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		actual = string(text)
+	}
+	if actual != c.JSONEquals {
+		return fmt.Errorf("health check %s is %q, want %q", c.JSONPath, actual, c.JSONEquals)
+	}
+	return nil
+}
+
+// CircuitBreakerConfig overrides the global circuit breaker settings for one
+// service. Zero fields keep the global value.
+type CircuitBreakerConfig struct {
+	FailureThreshold int           `json:"failure_threshold,omitempty" yaml:"failure_threshold,omitempty"`
+	SuccessThreshold int           `json:"success_threshold,omitempty" yaml:"success_threshold,omitempty"`
+	Timeout          time.Duration `json:"timeout,omitempty" yaml:"timeout,omitempty"`
 }
 
 // TLSConfig for backend connections
@@ -50,7 +121,84 @@ func (s *Service) GetEndpointCount() int {
 	return len(s.Endpoints)
 }
 
+// EndpointMetadata returns the metadata for one endpoint: the service's
+// metadata with the endpoint's tags layered on top
+func (s *Service) EndpointMetadata(endpoint string) map[string]string {
+	tags := s.EndpointTags[endpoint]
+	if len(tags) == 0 {
+		return s.Metadata
+	}
+
+	metadata := make(map[string]string, len(s.Metadata)+len(tags))
+	for k, v := range s.Metadata {
+		metadata[k] = v
+	}
+	for k, v := range tags {
+		metadata[k] = v
+	}
+	return metadata
+}
+
+// Clone returns a deep copy of the service, so changing the copy's slices,
+// maps or nested settings leaves s as it was
+func (s *Service) Clone() *Service {
+	clone := *s
+	clone.Endpoints = slices.Clone(s.Endpoints)
+	clone.Metadata = maps.Clone(s.Metadata)
+	if s.EndpointTags != nil {
+		clone.EndpointTags = make(map[string]map[string]string, len(s.EndpointTags))
+		for endpoint, tags := range s.EndpointTags {
+			clone.EndpointTags[endpoint] = maps.Clone(tags)
+		}
+	}
+	if s.HealthCheck != nil {
+		healthCheck := *s.HealthCheck
+		healthCheck.StatusCodes = slices.Clone(healthCheck.StatusCodes)
+		clone.HealthCheck = &healthCheck
+	}
+	if s.CircuitBreaker != nil {
+		circuitBreaker := *s.CircuitBreaker
+		clone.CircuitBreaker = &circuitBreaker
+	}
+	if s.TLS != nil {
+		tls := *s.TLS
+		tls.RootCAs = slices.Clone(tls.RootCAs)
+		clone.TLS = &tls
+	}
+	return &clone
+}
+
 // HasTLS returns true if the service has TLS configuration
 func (s *Service) HasTLS() bool {
 	return s.TLS != nil && s.TLS.Enabled
 }
+
+// ValidateEndpoint checks that an endpoint is an absolute http or https URL
+// with a host, which is all the proxy can forward to
+func ValidateEndpoint(endpoint string) error {
+	if endpoint == "" {
+		return fmt.Errorf("endpoint cannot be empty")
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return fmt.Errorf("endpoint is not a valid URL: %v", err)
+	}
+
+	switch u.Scheme {
+	case "http", "https":
+	case "":
+		return fmt.Errorf("endpoint must include a scheme, e.g. http://%s", endpoint)
+	default:
+		if u.Opaque != "" {
+			// host:port parses as a scheme followed by an opaque port
+			return fmt.Errorf("endpoint must include a scheme, e.g. http://%s", endpoint)
+		}
+		return fmt.Errorf("endpoint scheme must be http or https, not %q", u.Scheme)
+	}
+
+	if u.Host == "" || u.Hostname() == "" {
+		return fmt.Errorf("endpoint must include a host")
+	}
+	return nil
+}
@@ -0,0 +1,10 @@
+package types
+
+import "net/http"
+
+// IsInterimStatus reports whether code is an informational response that
+// precedes the final one, such as 100 Continue or 103 Early Hints. 101
+// Switching Protocols ends the HTTP exchange, so it counts as final.
+func IsInterimStatus(code int) bool {
+	return code >= 100 && code < 200 && code != http.StatusSwitchingProtocols
+}
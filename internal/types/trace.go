@@ -0,0 +1,103 @@
+package types
+
+import (
+	"context"
+	"sync"
+)
+
+// DebugTrace records how the proxy handled a request that asked for debug
+// headers. The debug middleware puts one in the request context; the
+// middleware chain and the proxy fill it in as the request passes through.
+type DebugTrace struct {
+	mu          sync.Mutex
+	routeID     string
+	backend     string
+	middlewares []string
+}
+
+type debugTraceKey struct{}
+
+type retryAttemptKey struct{}
+
+// ContextWithRetryAttempt records which retry of a request this is, 1 for
+// the first retry
+func ContextWithRetryAttempt(ctx context.Context, attempt int) context.Context {
+	return context.WithValue(ctx, retryAttemptKey{}, attempt)
+}
+
+// RetryAttemptFromContext returns which retry of a request this is, or 0 for
+// the first attempt. Unlike the X-Retry-Attempt header backends get, it
+// can't be set by clients.
+func RetryAttemptFromContext(ctx context.Context) int {
+	attempt, _ := ctx.Value(retryAttemptKey{}).(int)
+	return attempt
+}
+
+// ContextWithDebugTrace adds a trace to the context
+func ContextWithDebugTrace(ctx context.Context, trace *DebugTrace) context.Context {
+	return context.WithValue(ctx, debugTraceKey{}, trace)
+}
+
+// DebugTraceFromContext returns the request's trace, or nil if it has none.
+// The methods of a nil trace do nothing.
+func DebugTraceFromContext(ctx context.Context) *DebugTrace {
+	trace, _ := ctx.Value(debugTraceKey{}).(*DebugTrace)
+	return trace
+}
+
+// SetRoute records the matched route
+func (t *DebugTrace) SetRoute(routeID string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.routeID = routeID
+}
+
+// SetBackend records the backend the response came from
+func (t *DebugTrace) SetBackend(serverID string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.backend = serverID
+}
+
+// AddMiddleware records that a middleware handled the request. Middleware
+// running again for a retried request is only recorded once.
+func (t *DebugTrace) AddMiddleware(name string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, seen := range t.middlewares {
+		if seen == name {
+			return
+		}
+	}
+	t.middlewares = append(t.middlewares, name)
+}
+
+// Route returns the matched route's ID
+func (t *DebugTrace) Route() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.routeID
+}
+
+// Backend returns the ID of the backend the response came from
+func (t *DebugTrace) Backend() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.backend
+}
+
+// Middlewares returns the middleware that handled the request, outermost first
+func (t *DebugTrace) Middlewares() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]string(nil), t.middlewares...)
+}
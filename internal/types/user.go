@@ -32,6 +32,16 @@ type APIKey struct {
 	Metadata    map[string]string `json:"metadata,omitempty"`
 }
 
+// PasswordResetToken is a single-use token for resetting a forgotten password.
+// Only a hash of the token is stored.
+type PasswordResetToken struct {
+	TokenHash string     `json:"token_hash"`
+	UserID    string     `json:"user_id"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+}
+
 // UserCredentials for authentication
 type UserCredentials struct {
 	Username string `json:"username"`
@@ -44,6 +54,18 @@ type PasswordChange struct {
 	NewPassword string `json:"new_password"`
 }
 
+// ForgotPasswordRequest identifies the account to issue a reset token for
+type ForgotPasswordRequest struct {
+	Username string `json:"username,omitempty"`
+	Email    string `json:"email,omitempty"`
+}
+
+// ResetPasswordRequest sets a new password using a reset token
+type ResetPasswordRequest struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password"`
+}
+
 // CreateUserRequest for API
 type CreateUserRequest struct {
 	Username string            `json:"username"`
@@ -2,15 +2,19 @@ package api
 
 import (
 	"context"
+	"crypto/sha256"
+	"errors"
 	"net/http"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 	
 	"discobox/internal/types"
 )
 
 // storageAuthMiddleware provides database-backed authentication
-func storageAuthMiddleware(next http.Handler, storage types.Storage, logger types.Logger) http.Handler {
+func storageAuthMiddleware(next http.Handler, storage types.Storage, logger types.Logger, failure *storageFailurePolicy) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Skip auth for public endpoints
 		if isPublicEndpoint(r.URL.Path) {
@@ -35,6 +39,10 @@ func storageAuthMiddleware(next http.Handler, storage types.Storage, logger type
 		
 		// Validate API key
 		key, err := storage.GetAPIKey(ctx, apiKey)
+		if err != nil && !errors.Is(err, types.ErrAPIKeyNotFound) && r.Context().Err() == nil {
+			failure.serve(w, r, next, logger, apiKey, err)
+			return
+		}
 		if err != nil {
 			logger.Debug("Invalid API key", "key", apiKey[:8]+"...", "error", err)
 			http.Error(w, "Unauthorized", http.StatusUnauthorized)
@@ -55,6 +63,10 @@ func storageAuthMiddleware(next http.Handler, storage types.Storage, logger type
 		
 		// Get user info
 		user, err := storage.GetUser(ctx, key.UserID)
+		if err != nil && !errors.Is(err, types.ErrUserNotFound) && r.Context().Err() == nil {
+			failure.serve(w, r, next, logger, apiKey, err)
+			return
+		}
 		if err != nil {
 			logger.Error("Failed to get user for API key", "user_id", key.UserID, "error", err)
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
@@ -67,6 +79,15 @@ func storageAuthMiddleware(next http.Handler, storage types.Storage, logger type
 			return
 		}
 		
+		// Until they change their password, a flagged user can only do that
+		// and look themselves up
+		if user.MustChangePassword && !passwordChangeAllowed(r, user.ID) {
+			http.Error(w, "Password change required", http.StatusForbidden)
+			return
+		}
+		
+		failure.recovered(apiKey)
+		
 		// Add user info to request context
 		r.Header.Set("X-User-ID", user.ID)
 		r.Header.Set("X-User-Name", user.Username)
@@ -78,6 +99,95 @@ func storageAuthMiddleware(next http.Handler, storage types.Storage, logger type
 	})
 }
 
+// recentKeyTTL is how long after a successful check an API key is still let
+// through by the open storage failure policy
+const recentKeyTTL = 10 * time.Minute
+
+// storageFailurePolicy decides what happens to requests whose API key can't
+// be checked because storage is failing. Closed rejects them with 503. Open
+// lets keys that checked out in the last recentKeyTTL through for the first
+// grace period of an outage, without a user, so admin and per-user endpoints
+// still refuse them; other keys get 503.
+type storageFailurePolicy struct {
+	open  bool
+	grace time.Duration
+	since atomic.Int64 // Start of the current outage in Unix nanoseconds, 0 while storage works
+
+	mu     sync.Mutex
+	recent map[[sha256.Size]byte]time.Time // When keys last checked out, by key hash
+}
+
+// newStorageFailurePolicy creates the policy set by api.storage_failure
+func newStorageFailurePolicy(config *types.ProxyConfig) *storageFailurePolicy {
+	return &storageFailurePolicy{
+		open:   config.API.StorageFailure.Mode == "open",
+		grace:  config.API.StorageFailure.GracePeriod,
+		recent: make(map[[sha256.Size]byte]time.Time),
+	}
+}
+
+// serve handles a request whose lookup of apiKey failed with err
+func (p *storageFailurePolicy) serve(w http.ResponseWriter, r *http.Request, next http.Handler, logger types.Logger, apiKey string, err error) {
+	now := time.Now()
+	p.since.CompareAndSwap(0, now.UnixNano())
+	outage := now.Sub(time.Unix(0, p.since.Load()))
+	
+	if !p.open || (p.grace > 0 && outage >= p.grace) || !p.recentlyValid(apiKey, now) {
+		logger.Error("Storage unavailable for API auth", "path", r.URL.Path, "outage", outage, "error", err)
+		http.Error(w, "Authentication unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	
+	logger.Warn("Storage unavailable for API auth, allowing request", "path", r.URL.Path, "outage", outage, "error", err)
+	
+	// The request has no user, whatever its headers claim
+	r.Header.Del("X-User-ID")
+	r.Header.Del("X-User-Name")
+	r.Header.Del("X-User-Admin")
+	r.Header.Set("X-Auth-Degraded", "true")
+	w.Header().Set("X-Auth-Degraded", "true")
+	
+	next.ServeHTTP(w, r)
+}
+
+// recovered ends the current outage after apiKey checked out, and remembers
+// the key for the open policy
+func (p *storageFailurePolicy) recovered(apiKey string) {
+	if p.since.Load() != 0 {
+		p.since.Store(0)
+	}
+	if !p.open {
+		return
+	}
+	
+	now := time.Now()
+	sum := sha256.Sum256([]byte(apiKey))
+	
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	
+	if _, known := p.recent[sum]; !known {
+		// Forget expired keys as new ones arrive, so the set stays bounded
+		for hash, checked := range p.recent {
+			if now.Sub(checked) >= recentKeyTTL {
+				delete(p.recent, hash)
+			}
+		}
+	}
+	p.recent[sum] = now
+}
+
+// recentlyValid reports whether apiKey checked out in the last recentKeyTTL
+func (p *storageFailurePolicy) recentlyValid(apiKey string, now time.Time) bool {
+	sum := sha256.Sum256([]byte(apiKey))
+	
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	
+	checked, ok := p.recent[sum]
+	return ok && now.Sub(checked) < recentKeyTTL
+}
+
 // requireAdminMiddleware ensures the user is an admin
 func requireAdminMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -91,10 +201,21 @@ func requireAdminMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// passwordChangeAllowed reports whether r is one of the few requests a user who
+// must change their password may still make
+func passwordChangeAllowed(r *http.Request, userID string) bool {
+	path := strings.TrimSuffix(r.URL.Path, "/")
+	return path == "/api/v1/auth/whoami" || path == "/api/v1/users/"+userID+"/password"
+}
+
 // publicEndpoints is a list of endpoints that don't require authentication
 var publicEndpoints = map[string]bool{
-	"/health":          true,
-	"/api/v1/auth/login": true,
+	"/health":             true,
+	"/livez":              true,
+	"/readyz":             true,
+	"/api/v1/auth/login":  true,
+	"/api/v1/auth/forgot": true,
+	"/api/v1/auth/reset":  true,
 }
 
 // isPublicEndpoint checks if an endpoint is public
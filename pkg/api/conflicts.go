@@ -0,0 +1,115 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"discobox/internal/types"
+)
+
+// routeConflicts returns the stored routes, other than route itself, that
+// match exactly the same requests at the same priority
+func (h *Handler) routeConflicts(ctx context.Context, route *types.Route) ([]*types.Route, error) {
+	routes, err := h.storage.ListRoutes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var conflicts []*types.Route
+	for _, existing := range routes {
+		if existing.ID != route.ID && existing.SameMatch(route) {
+			conflicts = append(conflicts, existing)
+		}
+	}
+	return conflicts, nil
+}
+
+// checkRouteConflicts writes a 409 and returns false if route would conflict
+// with a stored route
+func (h *Handler) checkRouteConflicts(ctx context.Context, w http.ResponseWriter, route *types.Route) bool {
+	conflicts, err := h.routeConflicts(ctx, route)
+	if err != nil {
+		h.logger.Error("failed to check route conflicts", "error", err, "id", route.ID)
+		respondError(w, http.StatusInternalServerError, "Failed to check route conflicts")
+		return false
+	}
+	if len(conflicts) == 0 {
+		return true
+	}
+
+	ids := make([]string, len(conflicts))
+	for i, conflict := range conflicts {
+		ids[i] = conflict.ID
+	}
+	sort.Strings(ids)
+
+	respondJSON(w, http.StatusConflict, ErrorResponse{
+		Error: fmt.Sprintf("Route matches the same requests at priority %d as: %s", route.Priority, strings.Join(ids, ", ")),
+		Code:  "route_conflict",
+	})
+	return false
+}
+
+// groupRouteConflicts groups routes that match the same requests at the same
+// priority. Routes without a conflict are left out.
+func groupRouteConflicts(routes []*types.Route) []RouteConflict {
+	var groups [][]*types.Route
+	for _, route := range routes {
+		placed := false
+		for i, group := range groups {
+			if group[0].SameMatch(route) {
+				groups[i] = append(group, route)
+				placed = true
+				break
+			}
+		}
+		if !placed {
+			groups = append(groups, []*types.Route{route})
+		}
+	}
+
+	conflicts := []RouteConflict{}
+	for _, group := range groups {
+		if len(group) < 2 {
+			continue
+		}
+
+		ids := make([]string, len(group))
+		for i, route := range group {
+			ids[i] = route.ID
+		}
+		sort.Strings(ids)
+
+		conflicts = append(conflicts, RouteConflict{
+			Priority:   group[0].Priority,
+			Host:       group[0].Host,
+			PathPrefix: group[0].PathPrefix,
+			PathRegex:  group[0].PathRegex,
+			Headers:    group[0].Headers,
+			RouteIDs:   ids,
+		})
+	}
+
+	sort.Slice(conflicts, func(i, j int) bool {
+		return conflicts[i].RouteIDs[0] < conflicts[j].RouteIDs[0]
+	})
+	return conflicts
+}
+
+func (h *Handler) handleRouteConflicts(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	routes, err := h.storage.ListRoutes(ctx)
+	if err != nil {
+		h.logger.Error("failed to list routes", "error", err)
+		respondError(w, http.StatusInternalServerError, "Failed to list routes")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, groupRouteConflicts(routes))
+}
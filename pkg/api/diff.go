@@ -0,0 +1,165 @@
+package api
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"discobox/internal/types"
+)
+
+// redactedConfigPaths hold credentials; changes to them are reported without values
+var redactedConfigPaths = []string{
+	"api.api_key",
+	"api.admin_auth.token",
+	"api.admin_auth.password",
+	"middleware.auth.basic.users",
+	"middleware.auth.oauth2.client_secret",
+	"storage.dsn",
+}
+
+// diffConfig compares two configurations and the services stored before and
+// after a reload. Config paths use the YAML keys; services are keyed by ID.
+func diffConfig(oldConfig, newConfig *types.ProxyConfig, oldServices, newServices []*types.Service) ConfigDiff {
+	diff := ConfigDiff{
+		Added:   []ConfigChange{},
+		Removed: []ConfigChange{},
+		Changed: []ConfigChange{},
+	}
+
+	oldFlat := make(map[string]any)
+	newFlat := make(map[string]any)
+	flattenConfig("", reflect.ValueOf(*oldConfig), oldFlat)
+	flattenConfig("", reflect.ValueOf(*newConfig), newFlat)
+	diffFlat(oldFlat, newFlat, &diff)
+
+	oldFlat = make(map[string]any)
+	newFlat = make(map[string]any)
+	for _, service := range oldServices {
+		flattenService(service, oldFlat)
+	}
+	for _, service := range newServices {
+		flattenService(service, newFlat)
+	}
+	diffFlat(oldFlat, newFlat, &diff)
+
+	for _, changes := range [][]ConfigChange{diff.Added, diff.Removed, diff.Changed} {
+		sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	}
+
+	return diff
+}
+
+// diffFlat records the differences between two flattened trees
+func diffFlat(oldFlat, newFlat map[string]any, diff *ConfigDiff) {
+	for path, newValue := range newFlat {
+		oldValue, existed := oldFlat[path]
+		switch {
+		case !existed:
+			diff.Added = append(diff.Added, redactChange(ConfigChange{Path: path, New: newValue}))
+		case !reflect.DeepEqual(oldValue, newValue):
+			diff.Changed = append(diff.Changed, redactChange(ConfigChange{Path: path, Old: oldValue, New: newValue}))
+		}
+	}
+
+	for path, oldValue := range oldFlat {
+		if _, exists := newFlat[path]; !exists {
+			diff.Removed = append(diff.Removed, redactChange(ConfigChange{Path: path, Old: oldValue}))
+		}
+	}
+}
+
+// redactChange hides the values of credential settings
+func redactChange(change ConfigChange) ConfigChange {
+	if !isRedactedPath(change.Path) {
+		return change
+	}
+	if change.Old != nil {
+		change.Old = "<redacted>"
+	}
+	if change.New != nil {
+		change.New = "<redacted>"
+	}
+	return change
+}
+
+// isRedactedPath reports whether a path holds a credential
+func isRedactedPath(path string) bool {
+	for _, prefix := range redactedConfigPaths {
+		if path == prefix || strings.HasPrefix(path, prefix+".") {
+			return true
+		}
+	}
+	// Service client keys may be stored as inline PEM
+	return strings.HasPrefix(path, "services.") && strings.HasSuffix(path, ".tls.client_key")
+}
+
+// flattenConfig walks a config struct, storing each leaf under its dotted
+// YAML path. Maps are walked by key; slices are compared whole.
+func flattenConfig(prefix string, v reflect.Value, out map[string]any) {
+	switch v.Kind() {
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = strings.ToLower(field.Name)
+			}
+			flattenConfig(joinPath(prefix, name), v.Field(i), out)
+		}
+	case reflect.Map:
+		for _, key := range v.MapKeys() {
+			flattenConfig(joinPath(prefix, key.String()), v.MapIndex(key), out)
+		}
+	default:
+		if d, ok := v.Interface().(time.Duration); ok {
+			out[prefix] = d.String()
+			return
+		}
+		out[prefix] = v.Interface()
+	}
+}
+
+// flattenService stores a service's fields under services.<id>, using the
+// same JSON encoding the API returns
+func flattenService(service *types.Service, out map[string]any) {
+	data, err := json.Marshal(service)
+	if err != nil {
+		return
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return
+	}
+	// Bumped on every write; the fields that changed are what matter
+	delete(fields, "version")
+
+	flattenJSON(joinPath("services", service.ID), fields, out)
+}
+
+// flattenJSON stores each leaf of a decoded JSON object under its dotted path
+func flattenJSON(prefix string, value any, out map[string]any) {
+	if object, ok := value.(map[string]any); ok {
+		for key, child := range object {
+			flattenJSON(joinPath(prefix, key), child, out)
+		}
+		return
+	}
+	out[prefix] = value
+}
+
+func joinPath(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
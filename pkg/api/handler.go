@@ -2,10 +2,21 @@
 package api
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
 	"regexp"
 	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"encoding/json"
@@ -17,6 +28,7 @@ import (
 	"discobox/internal/config"
 	"discobox/internal/metrics"
 	"discobox/internal/middleware"
+	"discobox/internal/middleware/auth"
 	"discobox/internal/types"
 	"discobox/internal/version"
 )
@@ -28,6 +40,11 @@ type Handler struct {
 	config       *types.ProxyConfig
 	configLoader ConfigLoader
 	onReload     func(*types.ProxyConfig) error
+	onResetToken ResetTokenNotifier
+	runtime      RuntimeInspector
+	drainer      Drainer
+	overrider    HealthOverrider
+	authFailure  *storageFailurePolicy
 }
 
 // ConfigLoader defines the interface for loading configuration
@@ -35,12 +52,42 @@ type ConfigLoader interface {
 	LoadConfig() (*types.ProxyConfig, error)
 }
 
+// RuntimeInspector provides a snapshot of live proxy internals
+type RuntimeInspector interface {
+	RuntimeStats(ctx context.Context) (*types.RuntimeStats, error)
+}
+
+// routeCounter is implemented by runtime inspectors that can tell how many
+// routes are loaded without building a full snapshot
+type routeCounter interface {
+	RouteCount() int
+}
+
+// Drainer takes the instance out of rotation ahead of a shutdown
+type Drainer interface {
+	// Drain stops taking new work and waits until at most threshold requests
+	// are in flight or ctx is done, returning how many remain
+	Drain(ctx context.Context, threshold int) int
+	// Draining reports whether Drain has been called
+	Draining() bool
+}
+
+// HealthOverrider lets operators force a backend endpoint's health
+type HealthOverrider interface {
+	// OverrideHealth forces the service's endpoint with URL endpoint healthy
+	// or unhealthy until the override is cleared
+	OverrideHealth(serviceID, endpoint string, healthy bool)
+	// ClearHealthOverride hands the endpoint's health back to the checks
+	ClearHealthOverride(serviceID, endpoint string)
+}
+
 // New creates a new API handler instance
 func New(storage types.Storage, logger types.Logger, config *types.ProxyConfig) *Handler {
 	return &Handler{
-		storage: storage,
-		logger:  logger,
-		config:  config,
+		storage:     storage,
+		logger:      logger,
+		config:      config,
+		authFailure: newStorageFailurePolicy(config),
 	}
 }
 
@@ -54,6 +101,28 @@ func (h *Handler) SetReloadCallback(callback func(*types.ProxyConfig) error) {
 	h.onReload = callback
 }
 
+// SetResetTokenNotifier sets the function used to deliver password reset
+// tokens. Until one is set, POST /api/v1/auth/forgot is disabled.
+func (h *Handler) SetResetTokenNotifier(notifier ResetTokenNotifier) {
+	h.onResetToken = notifier
+}
+
+// SetRuntimeInspector sets the source of live diagnostics for the admin runtime endpoint
+func (h *Handler) SetRuntimeInspector(inspector RuntimeInspector) {
+	h.runtime = inspector
+}
+
+// SetDrainer sets what the admin drain endpoint drains; readiness fails once it is draining
+func (h *Handler) SetDrainer(drainer Drainer) {
+	h.drainer = drainer
+}
+
+// SetHealthOverrider sets the proxy whose endpoint health the endpoint
+// health API overrides
+func (h *Handler) SetHealthOverrider(overrider HealthOverrider) {
+	h.overrider = overrider
+}
+
 // Router returns the HTTP handler for the API
 func (h *Handler) Router() http.Handler {
 	mainRouter := mux.NewRouter()
@@ -61,7 +130,12 @@ func (h *Handler) Router() http.Handler {
 	// Public endpoints (no auth required)
 	publicRouter := mainRouter.PathPrefix("/").Subrouter()
 	publicRouter.HandleFunc("/health", h.handleHealth).Methods("GET")
+	publicRouter.HandleFunc("/livez", h.handleLivez).Methods("GET")
+	publicRouter.HandleFunc("/readyz", h.handleReadyz).Methods("GET")
+	publicRouter.HandleFunc("/api/v1/version", h.handleVersion).Methods("GET")
 	publicRouter.HandleFunc("/api/v1/auth/login", h.handleLogin).Methods("POST", "OPTIONS")
+	publicRouter.HandleFunc("/api/v1/auth/forgot", h.handleForgotPassword).Methods("POST", "OPTIONS")
+	publicRouter.HandleFunc("/api/v1/auth/reset", h.handleResetPassword).Methods("POST", "OPTIONS")
 
 	// Prometheus metrics endpoint (no auth, no JSON middleware)
 	if h.config.Metrics.Enabled {
@@ -73,6 +147,16 @@ func (h *Handler) Router() http.Handler {
 		return corsMiddleware(jsonMiddleware(loggingMiddleware(next, h.logger)))
 	})
 
+	// Admin endpoints. Registered ahead of the other API routes so they can
+	// have their own auth.
+	adminRouter := mainRouter.PathPrefix("/api/v1/admin").Subrouter()
+	adminRouter.HandleFunc("/reload", h.handleReload).Methods("POST", "OPTIONS")
+	adminRouter.HandleFunc("/config", h.handleGetConfig).Methods("GET", "OPTIONS")
+	adminRouter.HandleFunc("/config", h.handleUpdateConfig).Methods("PUT", "OPTIONS")
+	adminRouter.HandleFunc("/config/validate", h.handleValidateConfig).Methods("POST", "OPTIONS")
+	adminRouter.HandleFunc("/runtime", h.handleRuntime).Methods("GET", "OPTIONS")
+	adminRouter.HandleFunc("/drain", h.handleDrain).Methods("POST", "OPTIONS")
+
 	// Protected API endpoints
 	apiRouter := mainRouter.PathPrefix("/api/v1").Subrouter()
 
@@ -81,14 +165,20 @@ func (h *Handler) Router() http.Handler {
 	apiRouter.HandleFunc("/services", h.handleCreateService).Methods("POST", "OPTIONS")
 	apiRouter.HandleFunc("/services/{id}", h.handleGetService).Methods("GET", "OPTIONS")
 	apiRouter.HandleFunc("/services/{id}", h.handleUpdateService).Methods("PUT", "OPTIONS")
+	apiRouter.HandleFunc("/services/{id}", h.handlePatchService).Methods("PATCH", "OPTIONS")
 	apiRouter.HandleFunc("/services/{id}", h.handleDeleteService).Methods("DELETE", "OPTIONS")
+	apiRouter.HandleFunc("/services/{id}/endpoints/{index}/health", h.handleEndpointHealth).Methods("POST", "OPTIONS")
 
 	// Routes
 	apiRouter.HandleFunc("/routes", h.handleListRoutes).Methods("GET", "OPTIONS")
 	apiRouter.HandleFunc("/routes", h.handleCreateRoute).Methods("POST", "OPTIONS")
+	apiRouter.HandleFunc("/routes:conflicts", h.handleRouteConflicts).Methods("GET", "OPTIONS")
 	apiRouter.HandleFunc("/routes/{id}", h.handleGetRoute).Methods("GET", "OPTIONS")
 	apiRouter.HandleFunc("/routes/{id}", h.handleUpdateRoute).Methods("PUT", "OPTIONS")
+	apiRouter.HandleFunc("/routes/{id}", h.handlePatchRoute).Methods("PATCH", "OPTIONS")
 	apiRouter.HandleFunc("/routes/{id}", h.handleDeleteRoute).Methods("DELETE", "OPTIONS")
+	apiRouter.HandleFunc("/routes/{id}/stats", h.handleRouteStats).Methods("GET", "OPTIONS")
+	apiRouter.HandleFunc("/route-groups/{group}", h.handleDeleteRouteGroup).Methods("DELETE", "OPTIONS")
 
 	// Metrics (JSON format for UI)
 	apiRouter.HandleFunc("/stats", h.handleMetrics).Methods("GET", "OPTIONS")
@@ -100,59 +190,97 @@ func (h *Handler) Router() http.Handler {
 	apiRouter.HandleFunc("/users/{id}", h.handleUpdateUser).Methods("PUT", "OPTIONS")
 	apiRouter.HandleFunc("/users/{id}", h.handleDeleteUser).Methods("DELETE", "OPTIONS")
 	apiRouter.HandleFunc("/users/{id}/password", h.handleChangePassword).Methods("POST", "OPTIONS")
+	apiRouter.HandleFunc("/users/{id}/force-password-reset", h.handleForcePasswordReset).Methods("POST", "OPTIONS")
 	apiRouter.HandleFunc("/users/{id}/api-keys", h.handleListUserAPIKeys).Methods("GET", "OPTIONS")
 	apiRouter.HandleFunc("/users/{id}/api-keys", h.handleCreateAPIKey).Methods("POST", "OPTIONS")
+	apiRouter.HandleFunc("/users/{id}/api-keys", h.handleRevokeUserAPIKeys).Methods("DELETE", "OPTIONS")
 
 	// API Keys
+	apiRouter.HandleFunc("/api-keys", h.handleListAPIKeys).Methods("GET", "OPTIONS")
 	apiRouter.HandleFunc("/api-keys/{key}", h.handleRevokeAPIKey).Methods("DELETE", "OPTIONS")
 
 	// Auth (whoami is protected, login is public)
 	apiRouter.HandleFunc("/auth/whoami", h.handleWhoAmI).Methods("GET", "OPTIONS")
 
-	// Admin endpoints - create a subrouter for admin-only endpoints
-	adminRouter := apiRouter.PathPrefix("/admin").Subrouter()
-	adminRouter.HandleFunc("/reload", h.handleReload).Methods("POST", "OPTIONS")
-	adminRouter.HandleFunc("/config", h.handleGetConfig).Methods("GET", "OPTIONS")
-	adminRouter.HandleFunc("/config", h.handleUpdateConfig).Methods("PUT", "OPTIONS")
-
 	// Apply common middleware to API routes first
-	apiRouter.Use(func(next http.Handler) http.Handler {
-		return loggingMiddleware(next, h.logger)
-	})
-	apiRouter.Use(func(next http.Handler) http.Handler {
-		return corsMiddleware(next)
-	})
-	apiRouter.Use(func(next http.Handler) http.Handler {
-		return jsonMiddleware(next)
-	})
+	for _, router := range []*mux.Router{adminRouter, apiRouter} {
+		router.Use(func(next http.Handler) http.Handler {
+			return loggingMiddleware(next, h.logger)
+		})
+		router.Use(func(next http.Handler) http.Handler {
+			return corsMiddleware(next)
+		})
+		router.Use(func(next http.Handler) http.Handler {
+			return jsonMiddleware(next)
+		})
+	}
 
 	// Apply auth middleware to API routes last
 	if h.config.API.Auth {
-		// Use storage-based authentication
-		apiRouter.Use(func(next http.Handler) http.Handler {
-			return storageAuthMiddleware(next, h.storage, h.logger)
-		})
-
-		// If static API key is configured, also allow that
-		if h.config.API.APIKey != "" {
-			authConfig := &AuthConfig{
-				Enabled:    true,
-				Type:       "api-key",
-				Token:      h.config.API.APIKey,
-				HeaderName: "X-API-Key",
-			}
-			apiRouter.Use(func(next http.Handler) http.Handler {
-				return authMiddleware(next, authConfig)
-			})
-		}
+		h.useAPIAuth(apiRouter)
+	}
 
-		// Apply admin middleware to admin routes after auth
+	// Admin routes take the admin credentials when configured, and otherwise
+	// an admin user's general API credentials
+	if adminAuth := h.adminAuthMiddleware(); adminAuth != nil {
+		adminRouter.Use(adminAuth)
+	} else if h.config.API.Auth {
+		h.useAPIAuth(adminRouter)
 		adminRouter.Use(requireAdminMiddleware)
 	}
 
 	return mainRouter
 }
 
+// useAPIAuth applies the general API authentication to router
+func (h *Handler) useAPIAuth(router *mux.Router) {
+	// Use storage-based authentication
+	router.Use(func(next http.Handler) http.Handler {
+		return storageAuthMiddleware(next, h.storage, h.logger, h.authFailure)
+	})
+
+	// If static API key is configured, also allow that
+	if h.config.API.APIKey != "" {
+		authConfig := &AuthConfig{
+			Enabled:    true,
+			Type:       "api-key",
+			Token:      h.config.API.APIKey,
+			HeaderName: "X-API-Key",
+		}
+		router.Use(func(next http.Handler) http.Handler {
+			return authMiddleware(next, authConfig)
+		})
+	}
+}
+
+// adminAuthMiddleware returns the middleware enforcing api.admin_auth, or nil
+// when admin routes share the general API auth
+func (h *Handler) adminAuthMiddleware() mux.MiddlewareFunc {
+	admin := h.config.API.AdminAuth
+
+	var authConfig *AuthConfig
+	switch admin.Type {
+	case "api_key":
+		authConfig = &AuthConfig{Enabled: true, Type: "api-key", Token: admin.Token, HeaderName: "X-API-Key"}
+	case "bearer":
+		authConfig = &AuthConfig{Enabled: true, Type: "bearer", Token: admin.Token}
+	case "basic":
+		authConfig = &AuthConfig{Enabled: true, Type: "basic", Username: admin.Username, Password: admin.Password}
+	case "jwt":
+		var jwtConfig types.ProxyConfig
+		jwtConfig.Middleware.Auth.JWT.Issuer = admin.JWT.Issuer
+		jwtConfig.Middleware.Auth.JWT.Audience = admin.JWT.Audience
+		jwtConfig.Middleware.Auth.JWT.KeyFile = admin.JWT.KeyFile
+		return mux.MiddlewareFunc(auth.JWT(jwtConfig))
+	default:
+		return nil
+	}
+
+	return func(next http.Handler) http.Handler {
+		return authMiddleware(next, authConfig)
+	}
+}
+
 // Health endpoint handlers
 
 // handleHealth handles GET /health
@@ -183,6 +311,72 @@ func (h *Handler) handleHealth(w http.ResponseWriter, r *http.Request) {
 	respondJSON(w, http.StatusOK, health)
 }
 
+// handleLivez reports that the process is up and serving requests. It
+// checks nothing else, so orchestrators only restart a wedged process.
+func (h *Handler) handleLivez(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, http.StatusOK, map[string]any{
+		"status":    "alive",
+		"timestamp": time.Now().UTC().Format(time.RFC3339),
+	})
+}
+
+// handleVersion handles GET /api/v1/version. It reports build information
+// only, so tooling can poll it without credentials or touching storage.
+func (h *Handler) handleVersion(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, http.StatusOK, version.GetInfo())
+}
+
+// handleReadyz reports whether the proxy can serve traffic: storage must be
+// reachable, at least one route loaded and the instance not draining. Returns
+// 503 otherwise so orchestrators hold traffic back without restarting the
+// process.
+func (h *Handler) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	ready := true
+	checks := make(map[string]any)
+
+	if h.drainer != nil && h.drainer.Draining() {
+		ready = false
+		checks["drain"] = map[string]any{"status": "draining"}
+	}
+
+	routes, err := h.storage.ListRoutes(ctx)
+	if err != nil {
+		// The probe is public, so the cause is only logged
+		h.logger.Warn("readiness check: storage unreachable", "error", err)
+		ready = false
+		checks["storage"] = map[string]any{"status": "unreachable"}
+	} else {
+		checks["storage"] = map[string]any{"status": "ok"}
+
+		// Prefer the routes the proxy has actually loaded over what is stored
+		routeCount := len(routes)
+		if counter, ok := h.runtime.(routeCounter); ok {
+			routeCount = counter.RouteCount()
+		}
+
+		if routeCount == 0 {
+			ready = false
+			checks["routes"] = map[string]any{"status": "none loaded", "count": 0}
+		} else {
+			checks["routes"] = map[string]any{"status": "ok", "count": routeCount}
+		}
+	}
+
+	status, code := "ready", http.StatusOK
+	if !ready {
+		status, code = "not_ready", http.StatusServiceUnavailable
+	}
+
+	respondJSON(w, code, map[string]any{
+		"status":    status,
+		"timestamp": time.Now().UTC().Format(time.RFC3339),
+		"checks":    checks,
+	})
+}
+
 // Service endpoint handlers
 
 // handleListServices handles GET /api/v1/services
@@ -209,8 +403,8 @@ func (h *Handler) handleCreateService(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Validate request
-	if err := validateServiceRequest(&req); err != nil {
-		respondError(w, http.StatusBadRequest, err.Error())
+	if err := h.validateService(r.Context(), &req); err != nil {
+		respondValidationError(w, err)
 		return
 	}
 
@@ -230,11 +424,16 @@ func (h *Handler) handleCreateService(w http.ResponseWriter, r *http.Request) {
 	defer cancel()
 
 	if err := h.storage.CreateService(ctx, service); err != nil {
+		if errors.Is(err, types.ErrAlreadyExists) {
+			respondError(w, http.StatusConflict, "Service already exists")
+			return
+		}
 		h.logger.Error("failed to create service", "error", err)
 		respondError(w, http.StatusInternalServerError, "Failed to create service")
 		return
 	}
 
+	setETag(w, service.Version)
 	response := serviceToResponse(service)
 	respondJSON(w, http.StatusCreated, response)
 }
@@ -254,6 +453,7 @@ func (h *Handler) handleGetService(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	setETag(w, service.Version)
 	response := serviceToResponse(service)
 	respondJSON(w, http.StatusOK, response)
 }
@@ -270,8 +470,8 @@ func (h *Handler) handleUpdateService(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Validate request
-	if err := validateServiceRequest(&req); err != nil {
-		respondError(w, http.StatusBadRequest, err.Error())
+	if err := h.validateService(r.Context(), &req); err != nil {
+		respondValidationError(w, err)
 		return
 	}
 
@@ -295,12 +495,22 @@ func (h *Handler) handleUpdateService(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if service.Version, err = expectedVersion(r, req.Version); err != nil {
+		respondVersionError(w, err)
+		return
+	}
+
 	if err := h.storage.UpdateService(ctx, service); err != nil {
+		if errors.Is(err, types.ErrVersionConflict) {
+			respondError(w, http.StatusConflict, "Service was modified by another request")
+			return
+		}
 		h.logger.Error("failed to update service", "error", err, "id", id)
 		respondError(w, http.StatusInternalServerError, "Failed to update service")
 		return
 	}
 
+	setETag(w, service.Version)
 	response := serviceToResponse(service)
 	respondJSON(w, http.StatusOK, response)
 }
@@ -328,7 +538,7 @@ func (h *Handler) handleDeleteService(w http.ResponseWriter, r *http.Request) {
 	}
 
 	for _, route := range routes {
-		if route.ServiceID == id {
+		if route.ServiceID == id || (route.Canary != nil && route.Canary.ServiceID == id) {
 			respondError(w, http.StatusConflict, "Service is referenced by routes")
 			return
 		}
@@ -343,14 +553,82 @@ func (h *Handler) handleDeleteService(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// handleEndpointHealth handles POST /api/v1/services/{id}/endpoints/{index}/health,
+// forcing one endpoint healthy or unhealthy until a null healthy clears it.
+// Admin only when auth is on.
+func (h *Handler) handleEndpointHealth(w http.ResponseWriter, r *http.Request) {
+	if h.config.API.Auth && r.Header.Get("X-User-Admin") != "true" {
+		respondError(w, http.StatusForbidden, "Admin access required")
+		return
+	}
+	if h.overrider == nil {
+		respondError(w, http.StatusServiceUnavailable, "Health overrides not available")
+		return
+	}
+
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	var req EndpointHealthRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	service, err := h.storage.GetService(ctx, id)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "Service not found")
+		return
+	}
+
+	index, err := strconv.Atoi(vars["index"])
+	if err != nil || index < 0 || index >= len(service.Endpoints) {
+		respondError(w, http.StatusNotFound, "Endpoint not found")
+		return
+	}
+
+	action := "endpoint_health_cleared"
+	if req.Healthy == nil {
+		h.overrider.ClearHealthOverride(id, service.Endpoints[index])
+	} else {
+		h.overrider.OverrideHealth(id, service.Endpoints[index], *req.Healthy)
+		action = "endpoint_health_forced"
+	}
+
+	h.logger.Info("Endpoint health override changed",
+		"action", action,
+		"service_id", id,
+		"endpoint", service.Endpoints[index],
+		"healthy", req.Healthy,
+		"by", r.Header.Get("X-User-ID"),
+	)
+
+	respondJSON(w, http.StatusOK, EndpointHealthResponse{
+		ID:        fmt.Sprintf("%s-%d", id, index),
+		ServiceID: id,
+		Endpoint:  service.Endpoints[index],
+		Healthy:   req.Healthy,
+	})
+}
+
 // Route endpoint handlers
 
-// handleListRoutes handles GET /api/v1/routes
+// handleListRoutes handles GET /api/v1/routes. An optional group query
+// parameter limits the list to one group; an empty value lists ungrouped routes.
 func (h *Handler) handleListRoutes(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
 
-	routes, err := h.storage.ListRoutes(ctx)
+	var routes []*types.Route
+	var err error
+	if query := r.URL.Query(); query.Has("group") {
+		routes, err = h.storage.ListRoutesByGroup(ctx, query.Get("group"))
+	} else {
+		routes, err = h.storage.ListRoutes(ctx)
+	}
 	if err != nil {
 		h.logger.Error("failed to list routes", "error", err)
 		respondError(w, http.StatusInternalServerError, "Failed to list routes")
@@ -368,16 +646,36 @@ func (h *Handler) handleCreateRoute(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	hedging, err := req.Hedging.toPolicy()
+	if err != nil {
+		respondValidationError(w, err)
+		return
+	}
+
 	// Convert request to route
 	route := types.Route{
-		ID:          req.ID,
-		Priority:    req.Priority,
-		Host:        req.Host,
-		PathPrefix:  req.PathPrefix,
-		PathRegex:   req.PathRegex,
-		Headers:     req.Headers,
-		ServiceID:   req.ServiceID,
-		Middlewares: req.Middlewares,
+		ID:                  req.ID,
+		Group:               req.Group,
+		Priority:            req.Priority,
+		Host:                req.Host,
+		PathPrefix:          req.PathPrefix,
+		PathRegex:           req.PathRegex,
+		PathSuffixes:        req.PathSuffixes,
+		Headers:             req.Headers,
+		SNI:                 req.SNI,
+		ClientCertSubject:   req.ClientCertSubject,
+		ContentType:         req.ContentType,
+		ServiceID:           req.ServiceID,
+		StripPathPrefix:     req.StripPathPrefix,
+		AddPathPrefix:       req.AddPathPrefix,
+		UpstreamScheme:      req.UpstreamScheme,
+		MaxResponseBytes:    req.MaxResponseBytes,
+		Middlewares:         req.Middlewares,
+		DisabledMiddlewares: req.DisabledMiddlewares,
+		Redirects:           req.Redirects.toPolicy(),
+		Hedging:             hedging,
+		EarlyHints:          req.EarlyHints,
+		Canary:              req.Canary.toPolicy(),
 	}
 
 	// Convert metadata
@@ -401,8 +699,8 @@ func (h *Handler) handleCreateRoute(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Validate route
-	if err := validateRoute(&route); err != nil {
-		respondError(w, http.StatusBadRequest, err.Error())
+	if err := validateRoute(&route, h.config); err != nil {
+		respondValidationError(w, err)
 		return
 	}
 
@@ -419,18 +717,33 @@ func (h *Handler) handleCreateRoute(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
 
-	// Verify service exists
+	// Verify services exist
 	if _, err := h.storage.GetService(ctx, route.ServiceID); err != nil {
 		respondError(w, http.StatusBadRequest, "Service not found")
 		return
 	}
+	if route.Canary != nil {
+		if _, err := h.storage.GetService(ctx, route.Canary.ServiceID); err != nil {
+			respondError(w, http.StatusBadRequest, "Canary service not found")
+			return
+		}
+	}
+
+	if !h.checkRouteConflicts(ctx, w, &route) {
+		return
+	}
 
 	if err := h.storage.CreateRoute(ctx, &route); err != nil {
+		if errors.Is(err, types.ErrAlreadyExists) {
+			respondError(w, http.StatusConflict, "Route already exists")
+			return
+		}
 		h.logger.Error("failed to create route", "error", err)
 		respondError(w, http.StatusInternalServerError, "Failed to create route")
 		return
 	}
 
+	setETag(w, route.Version)
 	response := routeToResponse(&route)
 	respondJSON(w, http.StatusCreated, response)
 }
@@ -450,10 +763,56 @@ func (h *Handler) handleGetRoute(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	setETag(w, route.Version)
 	response := routeToResponse(route)
 	respondJSON(w, http.StatusOK, response)
 }
 
+// handleRouteStats handles GET /api/v1/routes/{id}/stats
+func (h *Handler) handleRouteStats(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	route, err := h.storage.GetRoute(ctx, id)
+	if err != nil {
+		h.logger.Error("failed to get route", "error", err, "id", id)
+		respondError(w, http.StatusNotFound, "Route not found")
+		return
+	}
+
+	stats := metrics.GlobalCollector.GetRouteStats(route.ID)
+	response := RouteStats{
+		RouteID:      route.ID,
+		ServiceID:    route.ServiceID,
+		Timeout:      "0s",
+		Retry:        RetryPolicy{Enabled: h.config.Retry.Enabled},
+		Requests:     int64(stats.Requests),
+		Errors:       int64(stats.Errors),
+		ErrorRate:    stats.ErrorRate,
+		Timeouts:     int64(stats.Timeouts),
+		Retries:      int64(stats.Retries),
+		P50LatencyMs: stats.P50LatencyMs,
+		P95LatencyMs: stats.P95LatencyMs,
+		P99LatencyMs: stats.P99LatencyMs,
+	}
+
+	// The timeout is configured on the route's service
+	if service, err := h.storage.GetService(ctx, route.ServiceID); err == nil {
+		response.Timeout = service.Timeout.String()
+	}
+
+	if h.config.Retry.Enabled {
+		response.Retry.MaxAttempts = h.config.Retry.MaxAttempts
+		response.Retry.InitialDelay = h.config.Retry.InitialDelay.String()
+		response.Retry.MaxDelay = h.config.Retry.MaxDelay.String()
+	}
+
+	respondJSON(w, http.StatusOK, response)
+}
+
 // handleUpdateRoute handles PUT /api/v1/routes/{id}
 func (h *Handler) handleUpdateRoute(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -465,16 +824,36 @@ func (h *Handler) handleUpdateRoute(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	hedging, err := req.Hedging.toPolicy()
+	if err != nil {
+		respondValidationError(w, err)
+		return
+	}
+
 	// Convert request to route
 	route := types.Route{
-		ID:          id, // Use ID from URL
-		Priority:    req.Priority,
-		Host:        req.Host,
-		PathPrefix:  req.PathPrefix,
-		PathRegex:   req.PathRegex,
-		Headers:     req.Headers,
-		ServiceID:   req.ServiceID,
-		Middlewares: req.Middlewares,
+		ID:                  id, // Use ID from URL
+		Group:               req.Group,
+		Priority:            req.Priority,
+		Host:                req.Host,
+		PathPrefix:          req.PathPrefix,
+		PathRegex:           req.PathRegex,
+		PathSuffixes:        req.PathSuffixes,
+		Headers:             req.Headers,
+		SNI:                 req.SNI,
+		ClientCertSubject:   req.ClientCertSubject,
+		ContentType:         req.ContentType,
+		ServiceID:           req.ServiceID,
+		StripPathPrefix:     req.StripPathPrefix,
+		AddPathPrefix:       req.AddPathPrefix,
+		UpstreamScheme:      req.UpstreamScheme,
+		MaxResponseBytes:    req.MaxResponseBytes,
+		Middlewares:         req.Middlewares,
+		DisabledMiddlewares: req.DisabledMiddlewares,
+		Redirects:           req.Redirects.toPolicy(),
+		Hedging:             hedging,
+		EarlyHints:          req.EarlyHints,
+		Canary:              req.Canary.toPolicy(),
 	}
 
 	// Convert metadata
@@ -498,8 +877,8 @@ func (h *Handler) handleUpdateRoute(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Validate route
-	if err := validateRoute(&route); err != nil {
-		respondError(w, http.StatusBadRequest, err.Error())
+	if err := validateRoute(&route, h.config); err != nil {
+		respondValidationError(w, err)
 		return
 	}
 
@@ -512,18 +891,38 @@ func (h *Handler) handleUpdateRoute(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Verify service exists
+	// Verify services exist
 	if _, err := h.storage.GetService(ctx, route.ServiceID); err != nil {
 		respondError(w, http.StatusBadRequest, "Service not found")
 		return
 	}
+	if route.Canary != nil {
+		if _, err := h.storage.GetService(ctx, route.Canary.ServiceID); err != nil {
+			respondError(w, http.StatusBadRequest, "Canary service not found")
+			return
+		}
+	}
+
+	if route.Version, err = expectedVersion(r, req.Version); err != nil {
+		respondVersionError(w, err)
+		return
+	}
+
+	if !h.checkRouteConflicts(ctx, w, &route) {
+		return
+	}
 
 	if err := h.storage.UpdateRoute(ctx, &route); err != nil {
+		if errors.Is(err, types.ErrVersionConflict) {
+			respondError(w, http.StatusConflict, "Route was modified by another request")
+			return
+		}
 		h.logger.Error("failed to update route", "error", err, "id", id)
 		respondError(w, http.StatusInternalServerError, "Failed to update route")
 		return
 	}
 
+	setETag(w, route.Version)
 	response := routeToResponse(&route)
 	respondJSON(w, http.StatusOK, response)
 }
@@ -551,6 +950,36 @@ func (h *Handler) handleDeleteRoute(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// handleDeleteRouteGroup handles DELETE /api/v1/route-groups/{group}
+func (h *Handler) handleDeleteRouteGroup(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	group := vars["group"]
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	deleted, err := h.storage.DeleteRouteGroup(ctx, group)
+	if err != nil {
+		if errors.Is(err, types.ErrVersionConflict) {
+			respondError(w, http.StatusConflict, "Route group was modified by another request")
+			return
+		}
+		h.logger.Error("failed to delete route group", "error", err, "group", group)
+		respondError(w, http.StatusInternalServerError, "Failed to delete route group")
+		return
+	}
+
+	if deleted == 0 {
+		respondError(w, http.StatusNotFound, "Route group not found")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, RouteGroupDeleteResponse{
+		Group:   group,
+		Deleted: deleted,
+	})
+}
+
 // Metrics endpoint handlers
 
 // handleMetrics handles GET /api/v1/stats
@@ -570,6 +999,9 @@ func (h *Handler) handleMetrics(w http.ResponseWriter, r *http.Request) {
 			P95LatencyMs: stats.P95LatencyMs,
 			P99LatencyMs: stats.P99LatencyMs,
 			ErrorRate:    stats.ErrorRate,
+			ByStatus:     stats.ByStatus,
+			BytesIn:      stats.BytesIn,
+			BytesOut:     stats.BytesOut,
 		},
 		System: SystemMetrics{
 			Goroutines:  runtime.NumGoroutine(),
@@ -616,6 +1048,104 @@ func (h *Handler) handleMetrics(w http.ResponseWriter, r *http.Request) {
 
 // Admin endpoint handlers
 
+// handleRuntime handles GET /api/v1/admin/runtime
+func (h *Handler) handleRuntime(w http.ResponseWriter, r *http.Request) {
+	if h.runtime == nil {
+		respondError(w, http.StatusServiceUnavailable, "Runtime diagnostics not available")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	stats, err := h.runtime.RuntimeStats(ctx)
+	if err != nil {
+		h.logger.Error("failed to collect runtime stats", "error", err)
+		respondError(w, http.StatusInternalServerError, "Failed to collect runtime stats")
+		return
+	}
+
+	info := RuntimeInfo{
+		Timestamp:       time.Now().UTC(),
+		Goroutines:      runtime.NumGoroutine(),
+		Routes:          stats.Routes,
+		CircuitBreakers: stats.CircuitBreakers,
+		Backends:        make([]BackendRuntime, len(stats.Backends)),
+	}
+
+	for i, backend := range stats.Backends {
+		info.Backends[i] = BackendRuntime{
+			ID:             backend.ID,
+			ServiceID:      backend.ServiceID,
+			URL:            backend.URL,
+			ActiveConns:    backend.ActiveConns,
+			Healthy:        backend.Healthy,
+			Ejected:        backend.Ejected,
+			Health:         backend.Health,
+			HealthOverride: backend.HealthOverride,
+		}
+	}
+
+	respondJSON(w, http.StatusOK, info)
+}
+
+// handleDrain handles POST /api/v1/admin/drain. Readiness starts failing and
+// keep-alives stop straight away; the response is sent once in-flight
+// requests drop to the threshold or the timeout elapses.
+func (h *Handler) handleDrain(w http.ResponseWriter, r *http.Request) {
+	if h.drainer == nil {
+		respondError(w, http.StatusServiceUnavailable, "Draining not available")
+		return
+	}
+
+	var req DrainRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+	}
+
+	var errs ValidationErrors
+	timeout := 30 * time.Second
+	if req.Timeout != "" {
+		parsed, err := time.ParseDuration(req.Timeout)
+		if err != nil || parsed <= 0 {
+			errs.Add("timeout", "timeout must be a positive duration")
+		}
+		timeout = parsed
+	}
+	if req.Threshold < 0 {
+		errs.Add("threshold", "threshold must be non-negative")
+	}
+	if err := errs.Err(); err != nil {
+		respondValidationError(w, err)
+		return
+	}
+
+	h.logger.Info("Drain requested", "timeout", timeout, "threshold", req.Threshold)
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+
+	start := time.Now()
+	remaining := h.drainer.Drain(ctx, req.Threshold)
+
+	response := DrainResponse{
+		Status:   "drained",
+		InFlight: remaining,
+		Waited:   time.Since(start).Round(time.Millisecond).String(),
+	}
+	if remaining > req.Threshold {
+		response.Status = "timeout"
+		h.logger.Warn("Drain timed out", "in_flight", remaining)
+	} else {
+		h.logger.Info("Drain completed", "in_flight", remaining)
+	}
+
+	respondJSON(w, http.StatusOK, response)
+}
+
 // handleReload handles POST /api/v1/admin/reload
 func (h *Handler) handleReload(w http.ResponseWriter, r *http.Request) {
 	h.logger.Info("Configuration reload requested")
@@ -641,6 +1171,19 @@ func (h *Handler) handleReload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	// Snapshot what is running now so the response can say what changed.
+	// The running config is copied because the reload callback overwrites it.
+	oldConfig := *h.config
+	oldServices, err := h.storage.ListServices(ctx)
+	if err != nil {
+		h.logger.Error("Failed to list services", "error", err)
+		respondError(w, http.StatusInternalServerError, "Failed to list services")
+		return
+	}
+
 	// Apply the new configuration if callback is set
 	if h.onReload != nil {
 		if err := h.onReload(newConfig); err != nil {
@@ -653,18 +1196,26 @@ func (h *Handler) handleReload(w http.ResponseWriter, r *http.Request) {
 	// Update the handler's config reference
 	h.config = newConfig
 
-	h.logger.Info("Configuration reloaded successfully")
+	newServices, err := h.storage.ListServices(ctx)
+	if err != nil {
+		h.logger.Error("Failed to list services", "error", err)
+		respondError(w, http.StatusInternalServerError, "Failed to list services")
+		return
+	}
+
+	diff := diffConfig(&oldConfig, newConfig, oldServices, newServices)
+
+	h.logger.Info("Configuration reloaded successfully",
+		"added", len(diff.Added),
+		"removed", len(diff.Removed),
+		"changed", len(diff.Changed),
+	)
 
 	response := map[string]any{
 		"status":    "success",
 		"message":   "Configuration reloaded successfully",
 		"timestamp": time.Now().UTC().Format(time.RFC3339),
-		"summary": map[string]any{
-			"listen_addr":     newConfig.ListenAddr,
-			"tls_enabled":     newConfig.TLS.Enabled,
-			"rate_limit":      newConfig.RateLimit.Enabled,
-			"circuit_breaker": newConfig.CircuitBreaker.Enabled,
-		},
+		"diff":      diff,
 	}
 
 	respondJSON(w, http.StatusOK, response)
@@ -697,6 +1248,12 @@ func (h *Handler) handleGetConfig(w http.ResponseWriter, r *http.Request) {
 	if config.Middleware.Auth.OAuth2.ClientSecret != "" {
 		config.Middleware.Auth.OAuth2.ClientSecret = "<redacted>"
 	}
+	if config.API.AdminAuth.Token != "" {
+		config.API.AdminAuth.Token = "<redacted>"
+	}
+	if config.API.AdminAuth.Password != "" {
+		config.API.AdminAuth.Password = "<redacted>"
+	}
 
 	respondJSON(w, http.StatusOK, config)
 }
@@ -788,28 +1345,185 @@ func (h *Handler) handleUpdateConfig(w http.ResponseWriter, r *http.Request) {
 	respondJSON(w, http.StatusOK, response)
 }
 
+// maxConfigSize bounds configuration documents posted for validation
+const maxConfigSize = 1 << 20
+
+// handleValidateConfig handles POST /api/v1/admin/config/validate. It checks
+// the posted configuration document, or the configuration file when the body
+// is empty, without applying it.
+func (h *Handler) handleValidateConfig(w http.ResponseWriter, r *http.Request) {
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxConfigSize+1))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Failed to read request body")
+		return
+	}
+	if len(data) > maxConfigSize {
+		respondError(w, http.StatusRequestEntityTooLarge, "Configuration document too large")
+		return
+	}
+
+	format := "yaml"
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		format = "json"
+	}
+
+	// No document means the file a reload would read
+	if len(bytes.TrimSpace(data)) == 0 {
+		loader, ok := h.configLoader.(*config.Loader)
+		if !ok || loader.ConfigFile() == "" {
+			respondError(w, http.StatusServiceUnavailable, "Configuration file not available")
+			return
+		}
+		data, err = os.ReadFile(loader.ConfigFile())
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to read configuration file: %v", err))
+			return
+		}
+		format = strings.TrimPrefix(filepath.Ext(loader.ConfigFile()), ".")
+		if format == "yml" || format == "" {
+			format = "yaml"
+		}
+	}
+
+	result := ConfigValidation{Valid: true}
+
+	candidate, err := config.Parse(data, format)
+	if err != nil {
+		result.Valid = false
+		result.Errors = []types.ValidationError{{Message: err.Error()}}
+		respondJSON(w, http.StatusOK, result)
+		return
+	}
+
+	result.Errors = config.Check(candidate)
+
+	// Reloads also run the API's own checks. They overlap config.Check, so
+	// they only add something when it found nothing.
+	if err := validateConfig(candidate); err != nil && len(result.Errors) == 0 {
+		result.Errors = append(result.Errors, types.ValidationError{Message: err.Error()})
+	}
+
+	result.Valid = len(result.Errors) == 0
+	respondJSON(w, http.StatusOK, result)
+}
+
 // Helper functions
 
-// validateRoute validates a route configuration
-func validateRoute(route *types.Route) error {
+// validateRoute validates a route configuration against the running config
+func validateRoute(route *types.Route, config *types.ProxyConfig) error {
+	var errs ValidationErrors
+
 	if route.ServiceID == "" {
-		return fmt.Errorf("service ID is required")
+		errs.Add("service_id", "service ID is required")
 	}
 
 	// Must have at least one matching criterion
 	if route.Host == "" && route.PathPrefix == "" && route.PathRegex == "" &&
-		len(route.Headers) == 0 {
-		return fmt.Errorf("at least one matching criterion is required")
+		len(route.PathSuffixes) == 0 && len(route.Headers) == 0 {
+		errs.Add("match", "at least one matching criterion is required")
 	}
 
 	// Validate regex if provided
 	if route.PathRegex != "" {
 		if _, err := regexp.Compile(route.PathRegex); err != nil {
-			return fmt.Errorf("invalid path regex: %v", err)
+			errs.Add("path_regex", fmt.Sprintf("invalid path regex: %v", err))
 		}
 	}
 
-	return nil
+	// Validate TLS criteria written as regexes
+	if pattern, ok := strings.CutPrefix(route.SNI, types.TLSMatchRegexPrefix); ok {
+		if _, err := regexp.Compile(pattern); err != nil {
+			errs.Add("sni", fmt.Sprintf("invalid SNI regex: %v", err))
+		}
+	}
+	if pattern, ok := strings.CutPrefix(route.ClientCertSubject, types.TLSMatchRegexPrefix); ok {
+		if _, err := regexp.Compile(pattern); err != nil {
+			errs.Add("client_cert_subject", fmt.Sprintf("invalid client certificate subject regex: %v", err))
+		}
+	}
+
+	if route.StripPathPrefix != "" && !strings.HasPrefix(route.StripPathPrefix, "/") {
+		errs.Add("strip_path_prefix", "strip path prefix must start with /")
+	}
+	if route.AddPathPrefix != "" && !strings.HasPrefix(route.AddPathPrefix, "/") {
+		errs.Add("add_path_prefix", "add path prefix must start with /")
+	}
+	switch route.UpstreamScheme {
+	case "", "http", "https":
+	default:
+		errs.Add("upstream_scheme", "upstream scheme must be http or https")
+	}
+	if route.MaxResponseBytes < 0 {
+		errs.Add("max_response_bytes", "max response bytes must be non-negative")
+	}
+
+	// Validate redirect handling
+	if route.Redirects != nil {
+		switch route.Redirects.Mode {
+		case "", types.RedirectPassThrough, types.RedirectFollow, types.RedirectRewrite:
+		default:
+			errs.Add("redirects.mode", fmt.Sprintf("invalid redirect mode %q: must be pass, follow or rewrite", route.Redirects.Mode))
+		}
+
+		if route.Redirects.MaxHops < 0 {
+			errs.Add("redirects.max_hops", "max hops must not be negative")
+		}
+	}
+
+	// Validate hedging
+	if route.Hedging != nil && route.Hedging.Delay <= 0 {
+		errs.Add("hedging.delay", "hedging delay must be positive")
+	}
+
+	// Early hints are sent as Link headers and must look like one
+	for i, link := range route.EarlyHints {
+		if !strings.HasPrefix(strings.TrimSpace(link), "<") || !strings.Contains(link, ">") {
+			errs.Add(fmt.Sprintf("early_hints[%d]", i), "early hint must be a Link header value such as </app.css>; rel=preload; as=style")
+		}
+	}
+
+	for i, suffix := range route.PathSuffixes {
+		if suffix == "" {
+			errs.Add(fmt.Sprintf("path_suffixes[%d]", i), "path suffix must not be empty")
+		}
+	}
+
+	// Content types are matched by prefix, so parameters would never match
+	if strings.ContainsAny(route.ContentType, "; ") {
+		errs.Add("content_type", "content type must be a media type without parameters, e.g. application/grpc")
+	}
+
+	// Only global middleware can be disabled
+	for i, name := range route.DisabledMiddlewares {
+		if !slices.Contains(middleware.DisableableNames, name) {
+			errs.Add(fmt.Sprintf("disabled_middlewares[%d]", i), fmt.Sprintf("unknown middleware %q; must be one of %s", name, strings.Join(middleware.DisableableNames, ", ")))
+		}
+	}
+
+	// A cost above the burst could never be covered
+	if cost, ok := middleware.RateLimitCost(route); !ok {
+		errs.Add("metadata.rate_limit_cost", "rate limit cost must be a positive integer")
+	} else if config.RateLimit.Enabled && cost > config.RateLimit.Burst {
+		errs.Add("metadata.rate_limit_cost", fmt.Sprintf("rate limit cost must not exceed rate_limit.burst (%d)", config.RateLimit.Burst))
+	}
+
+	if _, err := middleware.CORSOverrides(route); err != nil {
+		errs.Add("metadata.cors", err.Error())
+	}
+
+	// Validate canary
+	if route.Canary != nil {
+		if route.Canary.ServiceID == "" {
+			errs.Add("canary.service_id", "canary service ID is required")
+		} else if route.Canary.ServiceID == route.ServiceID {
+			errs.Add("canary.service_id", "canary service must differ from the route's service")
+		}
+		if route.Canary.Weight < 0 || route.Canary.Weight > 100 {
+			errs.Add("canary.weight", "canary weight must be between 0 and 100")
+		}
+	}
+
+	return errs.Err()
 }
 
 // respondJSON writes a JSON response
@@ -825,9 +1539,9 @@ func respondJSON(w http.ResponseWriter, status int, data any) {
 
 // ErrorResponse represents an error response
 type ErrorResponse struct {
-	Error   string `json:"error"`
-	Code    string `json:"code,omitempty"`
-	Details string `json:"details,omitempty"`
+	Error   string                  `json:"error"`
+	Code    string                  `json:"code,omitempty"`
+	Details []types.ValidationError `json:"details,omitempty"`
 }
 
 // respondError writes an error response
@@ -837,6 +1551,49 @@ func respondError(w http.ResponseWriter, status int, message string) {
 	})
 }
 
+// respondValidationError writes a 422 with per-field details for validation
+// failures, falling back to a plain 400 for any other error
+func respondValidationError(w http.ResponseWriter, err error) {
+	var errs ValidationErrors
+	if !errors.As(err, &errs) {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusUnprocessableEntity, ErrorResponse{
+		Error:   errs.Error(),
+		Code:    "validation_failed",
+		Details: errs,
+	})
+}
+
+// ValidationErrors collects field-level validation failures
+type ValidationErrors []types.ValidationError
+
+// Add records a validation failure for a field
+func (v *ValidationErrors) Add(field, message string) {
+	*v = append(*v, types.ValidationError{Field: field, Message: message})
+}
+
+// Err returns nil when nothing was recorded, so validators can return it directly
+func (v ValidationErrors) Err() error {
+	if len(v) == 0 {
+		return nil
+	}
+	return v
+}
+
+func (v ValidationErrors) Error() string {
+	if len(v) == 1 {
+		return v[0].Message
+	}
+	messages := make([]string, len(v))
+	for i, e := range v {
+		messages[i] = e.Message
+	}
+	return fmt.Sprintf("%d validation errors: %s", len(v), strings.Join(messages, "; "))
+}
+
 // formatDuration formats a duration in a human-readable way
 func formatDuration(d time.Duration) string {
 	days := int(d.Hours() / 24)
@@ -874,6 +1631,7 @@ func validateConfig(config *types.ProxyConfig) error {
 		"weighted":    true,
 		"least_conn":  true,
 		"ip_hash":     true,
+		"least_time":  true,
 	}
 	if !validAlgorithms[config.LoadBalancing.Algorithm] {
 		return fmt.Errorf("invalid load balancing algorithm: %s", config.LoadBalancing.Algorithm)
@@ -912,21 +1670,74 @@ func validateConfig(config *types.ProxyConfig) error {
 // serviceToResponse converts a types.Service to a ServiceResponse
 func serviceToResponse(s *types.Service) ServiceResponse {
 	return ServiceResponse{
-		ID:          s.ID,
-		Name:        s.Name,
-		Endpoints:   s.Endpoints,
-		HealthPath:  s.HealthPath,
-		Weight:      s.Weight,
-		MaxConns:    s.MaxConns,
-		Timeout:     s.Timeout.String(),
-		Metadata:    s.Metadata,
-		StripPrefix: s.StripPrefix,
-		Active:      s.Active,
-		CreatedAt:   s.CreatedAt,
-		UpdatedAt:   s.UpdatedAt,
+		ID:             s.ID,
+		Name:           s.Name,
+		Endpoints:      s.Endpoints,
+		HealthPath:     s.HealthPath,
+		HealthCheck:    serviceHealthCheckToResponse(s.HealthCheck),
+		CircuitBreaker: serviceCircuitBreakerToResponse(s.CircuitBreaker),
+		Weight:         s.Weight,
+		MaxConns:       s.MaxConns,
+		Timeout:        s.Timeout.String(),
+		Metadata:       s.Metadata,
+		EndpointTags:   s.EndpointTags,
+		TLS:            serviceTLSToResponse(s.TLS),
+		StripPrefix:    s.StripPrefix,
+		UpstreamScheme: s.UpstreamScheme,
+		Active:         s.Active,
+		Version:        s.Version,
+		CreatedAt:      s.CreatedAt,
+		UpdatedAt:      s.UpdatedAt,
 	}
 }
 
+// serviceHealthCheckToResponse converts a service's health check criteria for API responses
+func serviceHealthCheckToResponse(c *types.HealthCheckConfig) *ServiceHealthCheck {
+	if c == nil {
+		return nil
+	}
+	return &ServiceHealthCheck{
+		StatusCodes:  c.StatusCodes,
+		BodyContains: c.BodyContains,
+		JSONPath:     c.JSONPath,
+		JSONEquals:   c.JSONEquals,
+	}
+}
+
+// serviceCircuitBreakerToResponse converts a service's circuit breaker overrides for API responses
+func serviceCircuitBreakerToResponse(c *types.CircuitBreakerConfig) *ServiceCircuitBreaker {
+	if c == nil {
+		return nil
+	}
+	resp := &ServiceCircuitBreaker{
+		FailureThreshold: c.FailureThreshold,
+		SuccessThreshold: c.SuccessThreshold,
+	}
+	if c.Timeout > 0 {
+		resp.Timeout = c.Timeout.String()
+	}
+	return resp
+}
+
+// serviceTLSToResponse converts a service's backend TLS settings for API
+// responses, hiding the client key
+func serviceTLSToResponse(t *types.TLSConfig) *ServiceTLS {
+	if t == nil {
+		return nil
+	}
+	response := &ServiceTLS{
+		Enabled:            t.Enabled,
+		InsecureSkipVerify: t.InsecureSkipVerify,
+		ServerName:         t.ServerName,
+		RootCAs:            t.RootCAs,
+		ClientCert:         t.ClientCert,
+	}
+	if t.ClientKey != "" {
+		response.ClientKey = "<redacted>"
+	}
+	return response
+}
+
 // servicesToResponse converts a slice of types.Service to ServiceResponse
 func servicesToResponse(services []*types.Service) []ServiceResponse {
 	responses := make([]ServiceResponse, len(services))
@@ -938,39 +1749,150 @@ func servicesToResponse(services []*types.Service) []ServiceResponse {
 
 // validateServiceRequest validates a service request
 func validateServiceRequest(req *ServiceRequest) error {
+	var errs ValidationErrors
+
 	if req.Name == "" {
-		return fmt.Errorf("service name is required")
+		errs.Add("name", "service name is required")
 	}
 
 	if len(req.Endpoints) == 0 {
-		return fmt.Errorf("at least one endpoint is required")
+		errs.Add("endpoints", "at least one endpoint is required")
 	}
 
 	// Validate endpoints format
-	for _, endpoint := range req.Endpoints {
-		if endpoint == "" {
-			return fmt.Errorf("endpoint cannot be empty")
+	for i, endpoint := range req.Endpoints {
+		if err := types.ValidateEndpoint(endpoint); err != nil {
+			errs.Add(fmt.Sprintf("endpoints[%d]", i), err.Error())
+		}
+	}
+
+	// Tags must belong to one of the service's endpoints
+	for endpoint, tags := range req.EndpointTags {
+		field := fmt.Sprintf("endpoint_tags[%s]", endpoint)
+		if !slices.Contains(req.Endpoints, endpoint) {
+			errs.Add(field, "tags must be for one of the service's endpoints")
+		}
+		if _, ok := tags[""]; ok {
+			errs.Add(field, "tag names cannot be empty")
 		}
 	}
 
 	// Validate timeout format if provided
 	if req.Timeout != "" {
 		if _, err := time.ParseDuration(req.Timeout); err != nil {
-			return fmt.Errorf("invalid timeout format: %v", err)
+			errs.Add("timeout", fmt.Sprintf("invalid timeout format: %v", err))
 		}
 	}
 
 	if req.Weight < 0 {
-		return fmt.Errorf("weight must be non-negative")
+		errs.Add("weight", "weight must be non-negative")
 	}
 
 	if req.MaxConns < 0 {
-		return fmt.Errorf("max connections must be non-negative")
+		errs.Add("max_conns", "max connections must be non-negative")
+	}
+
+	switch req.UpstreamScheme {
+	case "", "http", "https":
+	default:
+		errs.Add("upstream_scheme", "upstream scheme must be http or https")
 	}
 
+	if req.TLS != nil && (req.TLS.ClientCert == "") != (req.TLS.ClientKey == "") {
+		errs.Add("tls", "client_cert and client_key must be set together")
+	}
+
+	if req.HealthCheck != nil {
+		for i, code := range req.HealthCheck.StatusCodes {
+			if code < 100 || code > 599 {
+				errs.Add(fmt.Sprintf("health_check.status_codes[%d]", i), "status code must be between 100 and 599")
+			}
+		}
+		if req.HealthCheck.JSONPath != "" {
+			if err := types.ValidateJSONPath(req.HealthCheck.JSONPath); err != nil {
+				errs.Add("health_check.json_path", err.Error())
+			}
+		} else if req.HealthCheck.JSONEquals != "" {
+			errs.Add("health_check.json_equals", "json_equals requires json_path")
+		}
+	}
+
+	if req.CircuitBreaker != nil {
+		if req.CircuitBreaker.FailureThreshold < 0 {
+			errs.Add("circuit_breaker.failure_threshold", "failure threshold must be non-negative")
+		}
+		if req.CircuitBreaker.SuccessThreshold < 0 {
+			errs.Add("circuit_breaker.success_threshold", "success threshold must be non-negative")
+		}
+		if req.CircuitBreaker.Timeout != "" {
+			if d, err := time.ParseDuration(req.CircuitBreaker.Timeout); err != nil || d < 0 {
+				errs.Add("circuit_breaker.timeout", "timeout must be a non-negative duration, e.g. 30s")
+			}
+		}
+	}
+
+	return errs.Err()
+}
+
+// validateService validates a service request and, when api.probe_endpoints
+// is set, checks that its endpoints accept connections
+func (h *Handler) validateService(ctx context.Context, req *ServiceRequest) error {
+	if err := validateServiceRequest(req); err != nil {
+		return err
+	}
+	if h.config.API.ProbeEndpoints {
+		return probeEndpoints(ctx, req.Endpoints)
+	}
 	return nil
 }
 
+// endpointProbeTimeout bounds each endpoint reachability probe
+const endpointProbeTimeout = 2 * time.Second
+
+// probeEndpoints dials every endpoint at once and reports those that refuse
+// or time out. Endpoints must already have passed types.ValidateEndpoint.
+func probeEndpoints(ctx context.Context, endpoints []string) error {
+	dialer := &net.Dialer{Timeout: endpointProbeTimeout}
+	failures := make([]error, len(endpoints))
+
+	var wg sync.WaitGroup
+	for i, endpoint := range endpoints {
+		u, err := url.Parse(endpoint)
+		if err != nil {
+			failures[i] = err
+			continue
+		}
+
+		port := u.Port()
+		if port == "" {
+			port = "80"
+			if u.Scheme == "https" {
+				port = "443"
+			}
+		}
+
+		wg.Add(1)
+		go func(i int, addr string) {
+			defer wg.Done()
+			conn, err := dialer.DialContext(ctx, "tcp", addr)
+			if err != nil {
+				failures[i] = err
+				return
+			}
+			conn.Close()
+		}(i, net.JoinHostPort(u.Hostname(), port))
+	}
+	wg.Wait()
+
+	var errs ValidationErrors
+	for i, err := range failures {
+		if err != nil {
+			errs.Add(fmt.Sprintf("endpoints[%d]", i), fmt.Sprintf("endpoint is not reachable: %v", err))
+		}
+	}
+	return errs.Err()
+}
+
 // parseServiceRequest converts a ServiceRequest to types.Service
 func parseServiceRequest(req *ServiceRequest, existingService *types.Service) (*types.Service, error) {
 	// Parse timeout
@@ -986,16 +1908,58 @@ func parseServiceRequest(req *ServiceRequest, existingService *types.Service) (*
 	}
 
 	service := &types.Service{
-		ID:          req.ID,
-		Name:        req.Name,
-		Endpoints:   req.Endpoints,
-		HealthPath:  req.HealthPath,
-		Weight:      req.Weight,
-		MaxConns:    req.MaxConns,
-		Timeout:     timeout,
-		Metadata:    req.Metadata,
-		StripPrefix: req.StripPrefix,
-		Active:      req.Active,
+		ID:             req.ID,
+		Name:           req.Name,
+		Endpoints:      req.Endpoints,
+		HealthPath:     req.HealthPath,
+		Weight:         req.Weight,
+		MaxConns:       req.MaxConns,
+		Timeout:        timeout,
+		Metadata:       req.Metadata,
+		EndpointTags:   req.EndpointTags,
+		StripPrefix:    req.StripPrefix,
+		UpstreamScheme: req.UpstreamScheme,
+		Active:         req.Active,
+		Version:        req.Version,
+	}
+
+	if req.HealthCheck != nil {
+		service.HealthCheck = &types.HealthCheckConfig{
+			StatusCodes:  req.HealthCheck.StatusCodes,
+			BodyContains: req.HealthCheck.BodyContains,
+			JSONPath:     req.HealthCheck.JSONPath,
+			JSONEquals:   req.HealthCheck.JSONEquals,
+		}
+	}
+
+	if req.CircuitBreaker != nil {
+		service.CircuitBreaker = &types.CircuitBreakerConfig{
+			FailureThreshold: req.CircuitBreaker.FailureThreshold,
+			SuccessThreshold: req.CircuitBreaker.SuccessThreshold,
+		}
+		if req.CircuitBreaker.Timeout != "" {
+			breakerTimeout, err := time.ParseDuration(req.CircuitBreaker.Timeout)
+			if err != nil {
+				return nil, fmt.Errorf("invalid circuit breaker timeout: %v", err)
+			}
+			service.CircuitBreaker.Timeout = breakerTimeout
+		}
+	}
+
+	if req.TLS != nil {
+		service.TLS = &types.TLSConfig{
+			Enabled:            req.TLS.Enabled,
+			InsecureSkipVerify: req.TLS.InsecureSkipVerify,
+			ServerName:         req.TLS.ServerName,
+			RootCAs:            req.TLS.RootCAs,
+			ClientCert:         req.TLS.ClientCert,
+			ClientKey:          req.TLS.ClientKey,
+		}
+
+		// Responses redact the key, so a service sent back unchanged keeps it
+		if req.TLS.ClientKey == "<redacted>" && existingService != nil && existingService.TLS != nil {
+			service.TLS.ClientKey = existingService.TLS.ClientKey
+		}
 	}
 
 	// Preserve timestamps from existing service if updating
@@ -1022,15 +1986,30 @@ func parseServiceRequest(req *ServiceRequest, existingService *types.Service) (*
 // routeToResponse converts a types.Route to a RouteResponse
 func routeToResponse(r *types.Route) RouteResponse {
 	response := RouteResponse{
-		ID:          r.ID,
-		Priority:    r.Priority,
-		Host:        r.Host,
-		PathPrefix:  r.PathPrefix,
-		PathRegex:   r.PathRegex,
-		Headers:     r.Headers,
-		ServiceID:   r.ServiceID,
-		Middlewares: r.Middlewares,
-		Metadata:    r.Metadata,
+		ID:                  r.ID,
+		Group:               r.Group,
+		Priority:            r.Priority,
+		Host:                r.Host,
+		PathPrefix:          r.PathPrefix,
+		PathRegex:           r.PathRegex,
+		PathSuffixes:        r.PathSuffixes,
+		Headers:             r.Headers,
+		SNI:                 r.SNI,
+		ClientCertSubject:   r.ClientCertSubject,
+		ContentType:         r.ContentType,
+		ServiceID:           r.ServiceID,
+		StripPathPrefix:     r.StripPathPrefix,
+		AddPathPrefix:       r.AddPathPrefix,
+		UpstreamScheme:      r.UpstreamScheme,
+		MaxResponseBytes:    r.MaxResponseBytes,
+		Middlewares:         r.Middlewares,
+		DisabledMiddlewares: r.DisabledMiddlewares,
+		Redirects:           redirectPolicyToResponse(r.Redirects),
+		Hedging:             hedgePolicyToResponse(r.Hedging),
+		EarlyHints:          r.EarlyHints,
+		Canary:              canaryPolicyToResponse(r.Canary),
+		Metadata:            r.Metadata,
+		Version:             r.Version,
 	}
 
 	// Convert rewrite rules
@@ -1056,6 +2035,61 @@ func routeToResponse(r *types.Route) RouteResponse {
 	return response
 }
 
+// toPolicy converts a request redirect policy to a types.RedirectPolicy
+func (p *RedirectPolicy) toPolicy() *types.RedirectPolicy {
+	if p == nil {
+		return nil
+	}
+	return &types.RedirectPolicy{Mode: p.Mode, MaxHops: p.MaxHops}
+}
+
+// redirectPolicyToResponse converts a types.RedirectPolicy for API responses
+func redirectPolicyToResponse(p *types.RedirectPolicy) *RedirectPolicy {
+	if p == nil {
+		return nil
+	}
+	return &RedirectPolicy{Mode: p.Mode, MaxHops: p.MaxHops}
+}
+
+// toPolicy converts a request hedging policy to a types.HedgePolicy
+func (p *HedgePolicy) toPolicy() (*types.HedgePolicy, error) {
+	if p == nil {
+		return nil, nil
+	}
+
+	delay, err := time.ParseDuration(p.Delay)
+	if err != nil {
+		var errs ValidationErrors
+		errs.Add("hedging.delay", fmt.Sprintf("invalid delay format: %v", err))
+		return nil, errs.Err()
+	}
+	return &types.HedgePolicy{Delay: delay}, nil
+}
+
+// hedgePolicyToResponse converts a types.HedgePolicy for API responses
+func hedgePolicyToResponse(p *types.HedgePolicy) *HedgePolicy {
+	if p == nil {
+		return nil
+	}
+	return &HedgePolicy{Delay: p.Delay.String()}
+}
+
+// toPolicy converts a request canary policy to a types.CanaryPolicy
+func (p *CanaryPolicy) toPolicy() *types.CanaryPolicy {
+	if p == nil {
+		return nil
+	}
+	return &types.CanaryPolicy{ServiceID: p.ServiceID, Weight: p.Weight}
+}
+
+// canaryPolicyToResponse converts a types.CanaryPolicy for API responses
+func canaryPolicyToResponse(p *types.CanaryPolicy) *CanaryPolicy {
+	if p == nil {
+		return nil
+	}
+	return &CanaryPolicy{ServiceID: p.ServiceID, Weight: p.Weight}
+}
+
 // routesToResponse converts a slice of types.Route to RouteResponse
 func routesToResponse(routes []*types.Route) []RouteResponse {
 	responses := make([]RouteResponse, len(routes))
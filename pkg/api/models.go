@@ -2,6 +2,8 @@ package api
 
 import (
 	"time"
+
+	"discobox/internal/types"
 )
 
 // MetricsData represents the metrics response
@@ -22,6 +24,9 @@ type RequestMetrics struct {
 	P95LatencyMs float64 `json:"p95_latency_ms"`
 	P99LatencyMs float64 `json:"p99_latency_ms"`
 	ErrorRate    float64 `json:"error_rate"`
+	ByStatus     map[string]uint64 `json:"by_status"` // Responses by status class, 1xx to 5xx
+	BytesIn      uint64  `json:"bytes_in"`
+	BytesOut     uint64  `json:"bytes_out"`
 }
 
 // SystemMetrics represents system statistics
@@ -32,6 +37,61 @@ type SystemMetrics struct {
 	Connections  int     `json:"connections"`
 }
 
+// RuntimeInfo is a live snapshot of proxy internals for on-call debugging
+type RuntimeInfo struct {
+	Timestamp       time.Time         `json:"timestamp"`
+	Goroutines      int               `json:"goroutines"`
+	Routes          int               `json:"routes"`
+	CircuitBreakers map[string]string `json:"circuit_breakers"` // Breaker scope -> state
+	Backends        []BackendRuntime  `json:"backends"`
+}
+
+// DrainRequest controls how long POST /api/v1/admin/drain waits
+type DrainRequest struct {
+	Timeout   string `json:"timeout,omitempty"`   // Duration as string; defaults to 30s
+	Threshold int    `json:"threshold,omitempty"` // Return once at most this many requests are in flight
+}
+
+// DrainResponse reports how draining went
+type DrainResponse struct {
+	Status   string `json:"status"` // "drained", or "timeout" if requests were still in flight
+	InFlight int    `json:"in_flight"`
+	Waited   string `json:"waited"` // Duration as string
+}
+
+// ConfigValidation reports whether a configuration passed
+// POST /api/v1/admin/config/validate, and what is wrong with it if not
+type ConfigValidation struct {
+	Valid  bool                    `json:"valid"`
+	Errors []types.ValidationError `json:"errors,omitempty"`
+}
+
+// BackendRuntime represents the live state of a single backend
+type BackendRuntime struct {
+	ID             string         `json:"id"`
+	ServiceID      string         `json:"service_id"`
+	URL            string         `json:"url"`
+	ActiveConns    int64          `json:"active_conns"`
+	Healthy        bool           `json:"healthy"`
+	Ejected        bool           `json:"ejected"`
+	Health         map[string]any `json:"health,omitempty"`
+	HealthOverride *bool          `json:"health_override,omitempty"` // Forced through the endpoint health API
+}
+
+// EndpointHealthRequest forces a service endpoint healthy or unhealthy.
+// A null healthy clears the override.
+type EndpointHealthRequest struct {
+	Healthy *bool `json:"healthy"`
+}
+
+// EndpointHealthResponse describes an endpoint's health override
+type EndpointHealthResponse struct {
+	ID        string `json:"id"`
+	ServiceID string `json:"service_id"`
+	Endpoint  string `json:"endpoint"`
+	Healthy   *bool  `json:"healthy"` // null when the health checks decide
+}
+
 // ServiceMetrics represents per-service statistics
 type ServiceMetrics struct {
 	Requests     int64   `json:"requests"`
@@ -40,70 +100,238 @@ type ServiceMetrics struct {
 	HealthStatus string  `json:"health_status"`
 }
 
+// RouteStats represents per-route traffic statistics and the policies applied
+type RouteStats struct {
+	RouteID      string      `json:"route_id"`
+	ServiceID    string      `json:"service_id"`
+	Timeout      string      `json:"timeout"` // Service timeout; "0s" means unbounded
+	Retry        RetryPolicy `json:"retry"`
+	Requests     int64       `json:"requests"`
+	Errors       int64       `json:"errors"`
+	ErrorRate    float64     `json:"error_rate"`
+	Timeouts     int64       `json:"timeouts"`
+	Retries      int64       `json:"retries"`
+	P50LatencyMs float64     `json:"p50_latency_ms"`
+	P95LatencyMs float64     `json:"p95_latency_ms"`
+	P99LatencyMs float64     `json:"p99_latency_ms"`
+}
+
+// RetryPolicy represents the configured retry behavior
+type RetryPolicy struct {
+	Enabled      bool   `json:"enabled"`
+	MaxAttempts  int    `json:"max_attempts,omitempty"`
+	InitialDelay string `json:"initial_delay,omitempty"`
+	MaxDelay     string `json:"max_delay,omitempty"`
+}
+
 // ServiceRequest represents a service creation/update request
 type ServiceRequest struct {
-	ID          string            `json:"id"`
-	Name        string            `json:"name"`
-	Endpoints   []string          `json:"endpoints"`
-	HealthPath  string            `json:"health_path"`
-	Weight      int               `json:"weight"`
-	MaxConns    int               `json:"max_conns"`
-	Timeout     string            `json:"timeout"` // Duration as string
-	Metadata    map[string]string `json:"metadata"`
-	StripPrefix bool              `json:"strip_prefix"`
-	Active      bool              `json:"active"`
+	ID             string                       `json:"id"`
+	Name           string                       `json:"name"`
+	Endpoints      []string                     `json:"endpoints"`
+	HealthPath     string                       `json:"health_path"`
+	HealthCheck    *ServiceHealthCheck          `json:"health_check,omitempty"`
+	CircuitBreaker *ServiceCircuitBreaker       `json:"circuit_breaker,omitempty"` // Overrides the global circuit breaker settings
+	Weight         int                          `json:"weight"`
+	MaxConns       int                          `json:"max_conns"`
+	Timeout        string                       `json:"timeout"` // Duration as string
+	Metadata       map[string]string            `json:"metadata"`
+	EndpointTags   map[string]map[string]string `json:"endpoint_tags,omitempty"` // Tags per endpoint URL, e.g. zone
+	TLS            *ServiceTLS                  `json:"tls,omitempty"`
+	StripPrefix    bool                         `json:"strip_prefix"`
+	UpstreamScheme string                       `json:"upstream_scheme,omitempty"` // http or https to backends whatever their endpoint scheme
+	Active         bool                         `json:"active"`
+	Version        int64                        `json:"version,omitempty"` // Expected version; If-Match takes precedence
 }
 
 // ServiceResponse represents a service in API responses
 type ServiceResponse struct {
-	ID          string            `json:"id"`
-	Name        string            `json:"name"`
-	Endpoints   []string          `json:"endpoints"`
-	HealthPath  string            `json:"health_path"`
-	Weight      int               `json:"weight"`
-	MaxConns    int               `json:"max_conns"`
-	Timeout     string            `json:"timeout"` // Duration as string
-	Metadata    map[string]string `json:"metadata"`
-	StripPrefix bool              `json:"strip_prefix"`
-	Active      bool              `json:"active"`
-	CreatedAt   time.Time         `json:"created_at"`
-	UpdatedAt   time.Time         `json:"updated_at"`
+	ID             string                       `json:"id"`
+	Name           string                       `json:"name"`
+	Endpoints      []string                     `json:"endpoints"`
+	HealthPath     string                       `json:"health_path"`
+	HealthCheck    *ServiceHealthCheck          `json:"health_check,omitempty"`
+	CircuitBreaker *ServiceCircuitBreaker       `json:"circuit_breaker,omitempty"` // Overrides the global circuit breaker settings
+	Weight         int                          `json:"weight"`
+	MaxConns       int                          `json:"max_conns"`
+	Timeout        string                       `json:"timeout"` // Duration as string
+	Metadata       map[string]string            `json:"metadata"`
+	EndpointTags   map[string]map[string]string `json:"endpoint_tags,omitempty"`
+	TLS            *ServiceTLS                  `json:"tls,omitempty"`
+	StripPrefix    bool                         `json:"strip_prefix"`
+	UpstreamScheme string                       `json:"upstream_scheme,omitempty"` // http or https to backends whatever their endpoint scheme
+	Active         bool                         `json:"active"`
+	Version        int64                        `json:"version"`
+	CreatedAt      time.Time                    `json:"created_at"`
+	UpdatedAt      time.Time                    `json:"updated_at"`
+}
+
+// ServiceTLS configures TLS for connections to a service's backends. CAs
+// and the client certificate and key may be file paths or inline PEM.
+type ServiceTLS struct {
+	Enabled            bool     `json:"enabled"`
+	InsecureSkipVerify bool     `json:"insecure_skip_verify"`
+	ServerName         string   `json:"server_name,omitempty"`
+	RootCAs            []string `json:"root_cas,omitempty"`
+	ClientCert         string   `json:"client_cert,omitempty"`
+	ClientKey          string   `json:"client_key,omitempty"` // Redacted in responses
+}
+
+// ServiceHealthCheck decides which active health check responses count as
+// healthy. Any 2xx is accepted when StatusCodes is empty.
+type ServiceHealthCheck struct {
+	StatusCodes  []int  `json:"status_codes,omitempty"`
+	BodyContains string `json:"body_contains,omitempty"`
+	JSONPath     string `json:"json_path,omitempty"`
+	JSONEquals   string `json:"json_equals,omitempty"`
+}
+
+// ServiceCircuitBreaker overrides the global circuit breaker settings for a
+// service. Zero or empty fields keep the global value.
+type ServiceCircuitBreaker struct {
+	FailureThreshold int    `json:"failure_threshold,omitempty"`
+	SuccessThreshold int    `json:"success_threshold,omitempty"`
+	Timeout          string `json:"timeout,omitempty"` // Duration as string
 }
 
 // RouteRequest represents a route creation/update request
 type RouteRequest struct {
-	ID           string            `json:"id"`
-	Priority     int               `json:"priority"`
-	Host         string            `json:"host,omitempty"`
-	PathPrefix   string            `json:"path_prefix,omitempty"`
-	PathRegex    string            `json:"path_regex,omitempty"`
-	Headers      map[string]string `json:"headers,omitempty"`
-	ServiceID    string            `json:"service_id"`
-	Middlewares  []string          `json:"middlewares"`
-	RewriteRules []struct {
+	ID                string            `json:"id"`
+	Group             string            `json:"group,omitempty"`
+	Priority          int               `json:"priority"`
+	Host              string            `json:"host,omitempty"`
+	PathPrefix        string            `json:"path_prefix,omitempty"`
+	PathRegex         string            `json:"path_regex,omitempty"`
+	PathSuffixes      []string          `json:"path_suffixes,omitempty"` // Path must end with one of these, e.g. .js
+	Headers           map[string]string `json:"headers,omitempty"`
+	SNI               string            `json:"sni,omitempty"`                 // Exact, or a regex prefixed with ~
+	ClientCertSubject string            `json:"client_cert_subject,omitempty"` // Exact DN or CN, or a regex prefixed with ~
+	ContentType       string            `json:"content_type,omitempty"`        // Prefix of the request's media type, e.g. application/grpc
+	ServiceID         string            `json:"service_id"`
+	StripPathPrefix   string            `json:"strip_path_prefix,omitempty"` // Overrides the service's strip_prefix
+	AddPathPrefix     string            `json:"add_path_prefix,omitempty"`   // Prepended after rewriting and stripping
+	UpstreamScheme    string            `json:"upstream_scheme,omitempty"`   // http or https to backends; overrides the service's
+	MaxResponseBytes  int64             `json:"max_response_bytes,omitempty"` // Cap on backend response bodies; 0 = unlimited
+	Middlewares       []string          `json:"middlewares"`
+	DisabledMiddlewares []string        `json:"disabled_middlewares,omitempty"` // Global middleware skipped for this route
+	RewriteRules      []struct {
 		Type        string `json:"type"`
 		Pattern     string `json:"pattern"`
 		Replacement string `json:"replacement,omitempty"`
 	} `json:"rewrite_rules,omitempty"`
-	Metadata map[string]string `json:"metadata,omitempty"`
+	Redirects  *RedirectPolicy   `json:"redirects,omitempty"`
+	Hedging    *HedgePolicy      `json:"hedging,omitempty"`
+	EarlyHints []string          `json:"early_hints,omitempty"` // Link header values, e.g. </app.css>; rel=preload; as=style
+	Canary     *CanaryPolicy     `json:"canary,omitempty"`
+	Metadata   map[string]string `json:"metadata,omitempty"`
+	Version    int64             `json:"version,omitempty"` // Expected version; If-Match takes precedence
 }
 
 // RouteResponse represents a route in API responses
 type RouteResponse struct {
-	ID           string            `json:"id"`
-	Priority     int               `json:"priority"`
-	Host         string            `json:"host,omitempty"`
-	PathPrefix   string            `json:"path_prefix,omitempty"`
-	PathRegex    string            `json:"path_regex,omitempty"`
-	Headers      map[string]string `json:"headers,omitempty"`
-	ServiceID    string            `json:"service_id"`
-	Middlewares  []string          `json:"middlewares"`
-	RewriteRules []struct {
+	ID                string            `json:"id"`
+	Group             string            `json:"group,omitempty"`
+	Priority          int               `json:"priority"`
+	Host              string            `json:"host,omitempty"`
+	PathPrefix        string            `json:"path_prefix,omitempty"`
+	PathRegex         string            `json:"path_regex,omitempty"`
+	PathSuffixes      []string          `json:"path_suffixes,omitempty"` // Path must end with one of these, e.g. .js
+	Headers           map[string]string `json:"headers,omitempty"`
+	SNI               string            `json:"sni,omitempty"`                 // Exact, or a regex prefixed with ~
+	ClientCertSubject string            `json:"client_cert_subject,omitempty"` // Exact DN or CN, or a regex prefixed with ~
+	ContentType       string            `json:"content_type,omitempty"`        // Prefix of the request's media type, e.g. application/grpc
+	ServiceID         string            `json:"service_id"`
+	StripPathPrefix   string            `json:"strip_path_prefix,omitempty"` // Overrides the service's strip_prefix
+	AddPathPrefix     string            `json:"add_path_prefix,omitempty"`   // Prepended after rewriting and stripping
+	UpstreamScheme    string            `json:"upstream_scheme,omitempty"`   // http or https to backends; overrides the service's
+	MaxResponseBytes  int64             `json:"max_response_bytes,omitempty"` // Cap on backend response bodies; 0 = unlimited
+	Middlewares       []string          `json:"middlewares"`
+	DisabledMiddlewares []string        `json:"disabled_middlewares,omitempty"` // Global middleware skipped for this route
+	RewriteRules      []struct {
 		Type        string `json:"type"`
 		Pattern     string `json:"pattern"`
 		Replacement string `json:"replacement,omitempty"`
 	} `json:"rewrite_rules,omitempty"`
-	Metadata map[string]any `json:"metadata,omitempty"`
+	Redirects  *RedirectPolicy `json:"redirects,omitempty"`
+	Hedging    *HedgePolicy    `json:"hedging,omitempty"`
+	EarlyHints []string        `json:"early_hints,omitempty"`
+	Canary     *CanaryPolicy   `json:"canary,omitempty"`
+	Metadata   map[string]any  `json:"metadata,omitempty"`
+	Version    int64           `json:"version"`
+}
+
+// RedirectPolicy controls how redirects returned by a route's backends are handled
+type RedirectPolicy struct {
+	Mode    string `json:"mode"`               // pass, follow, rewrite
+	MaxHops int    `json:"max_hops,omitempty"` // Follow mode only
+}
+
+// HedgePolicy sends a second copy of a slow GET, HEAD or OPTIONS request to
+// another backend and uses whichever response arrives first
+type HedgePolicy struct {
+	Delay string `json:"delay"` // e.g. "50ms"
+}
+
+// CanaryPolicy sends a percentage of new clients to another service and
+// keeps each client on the variant it was given
+type CanaryPolicy struct {
+	ServiceID string `json:"service_id"`
+	Weight    int    `json:"weight"` // 0-100
+}
+
+// RouteGroupDeleteResponse reports the outcome of deleting a route group
+type RouteGroupDeleteResponse struct {
+	Group   string `json:"group"`
+	Deleted int    `json:"deleted"`
+}
+
+// RouteConflict lists routes that match the same requests at the same
+// priority; which of them serves a request is undefined
+type RouteConflict struct {
+	Priority   int               `json:"priority"`
+	Host       string            `json:"host,omitempty"`
+	PathPrefix string            `json:"path_prefix,omitempty"`
+	PathRegex  string            `json:"path_regex,omitempty"`
+	Headers    map[string]string `json:"headers,omitempty"`
+	RouteIDs   []string          `json:"route_ids"`
+}
+
+// APIKeyResponse represents an API key in admin listings. Key is masked so
+// listings can't be used to authenticate.
+type APIKeyResponse struct {
+	ID          string            `json:"id"`  // Fingerprint of the key, accepted when revoking it
+	Key         string            `json:"key"` // e.g. "abcd...wxyz"
+	UserID      string            `json:"user_id"`
+	Name        string            `json:"name"`
+	Description string            `json:"description,omitempty"`
+	Active      bool              `json:"active"`
+	CreatedAt   time.Time         `json:"created_at"`
+	LastUsedAt  *time.Time        `json:"last_used_at,omitempty"`
+	ExpiresAt   *time.Time        `json:"expires_at,omitempty"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+}
+
+// APIKeyListResponse is a page of API keys
+type APIKeyListResponse struct {
+	APIKeys []APIKeyResponse `json:"api_keys"`
+	Total   int              `json:"total"`
+	Limit   int              `json:"limit"`
+	Offset  int              `json:"offset"`
+}
+
+// ConfigDiff lists what a configuration reload changed
+type ConfigDiff struct {
+	Added   []ConfigChange `json:"added"`
+	Removed []ConfigChange `json:"removed"`
+	Changed []ConfigChange `json:"changed"`
+}
+
+// ConfigChange is a single setting that differs between two configurations.
+// Paths are dotted YAML keys, or services.<id>.<field> for stored services.
+type ConfigChange struct {
+	Path string `json:"path"`
+	Old  any    `json:"old,omitempty"`
+	New  any    `json:"new,omitempty"`
 }
 
 // ConfigUpdate represents a configuration update request
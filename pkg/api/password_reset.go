@@ -0,0 +1,191 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"discobox/internal/config"
+	"discobox/internal/types"
+)
+
+// resetTokenTTL is how long a password reset token stays valid
+const resetTokenTTL = 30 * time.Minute
+
+// ResetTokenNotifier delivers a freshly issued password reset token to a user
+type ResetTokenNotifier func(ctx context.Context, user *types.User, token string) error
+
+// resetTokenNotice is the body WebhookResetNotifier posts for each token
+type resetTokenNotice struct {
+	UserID    string    `json:"user_id"`
+	Username  string    `json:"username"`
+	Email     string    `json:"email"`
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// WebhookResetNotifier returns a ResetTokenNotifier that POSTs each token to
+// url as JSON, for a mailer or chat hook to pass on to the user. Anything
+// but a 2xx response is a failed delivery.
+func WebhookResetNotifier(url string, timeout time.Duration) ResetTokenNotifier {
+	client := &http.Client{Timeout: timeout}
+	return func(ctx context.Context, user *types.User, token string) error {
+		body, err := json.Marshal(resetTokenNotice{
+			UserID:    user.ID,
+			Username:  user.Username,
+			Email:     user.Email,
+			Token:     token,
+			ExpiresAt: time.Now().Add(resetTokenTTL),
+		})
+		if err != nil {
+			return err
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return fmt.Errorf("reset webhook returned %s", resp.Status)
+		}
+		return nil
+	}
+}
+
+// handleForgotPassword handles POST /api/v1/auth/forgot. The endpoint is
+// only enabled once a ResetTokenNotifier is set, since tokens are never
+// logged or returned.
+func (h *Handler) handleForgotPassword(w http.ResponseWriter, r *http.Request) {
+	if h.onResetToken == nil {
+		respondError(w, http.StatusNotFound, "Password reset is not enabled")
+		return
+	}
+
+	var req types.ForgotPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.Username == "" && req.Email == "" {
+		respondError(w, http.StatusBadRequest, "Username or email is required")
+		return
+	}
+
+	// Always respond the same way so callers can't probe for accounts
+	accepted := map[string]string{
+		"message": "If the account exists, a reset token has been issued",
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	var user *types.User
+	var err error
+	if req.Username != "" {
+		user, err = h.storage.GetUserByUsername(ctx, req.Username)
+	} else {
+		user, err = h.storage.GetUserByEmail(ctx, req.Email)
+	}
+	if err != nil || !user.Active {
+		respondJSON(w, http.StatusAccepted, accepted)
+		return
+	}
+
+	token := config.GenerateResetToken()
+	resetToken := &types.PasswordResetToken{
+		TokenHash: config.HashResetToken(token),
+		UserID:    user.ID,
+		ExpiresAt: time.Now().Add(resetTokenTTL),
+	}
+
+	// Failures get the same response too; an error only an existing account
+	// can hit would give it away
+	if err := h.storage.CreatePasswordResetToken(ctx, resetToken); err != nil {
+		h.logger.Error("Failed to create reset token", "error", err)
+		respondJSON(w, http.StatusAccepted, accepted)
+		return
+	}
+
+	if err := h.onResetToken(ctx, user, token); err != nil {
+		h.logger.Error("Failed to deliver reset token", "user", user.Username, "error", err)
+		respondJSON(w, http.StatusAccepted, accepted)
+		return
+	}
+	h.logger.Info("Password reset token issued", "user", user.Username, "expires_at", resetToken.ExpiresAt)
+
+	respondJSON(w, http.StatusAccepted, accepted)
+}
+
+// handleResetPassword handles POST /api/v1/auth/reset
+func (h *Handler) handleResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req types.ResetPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.Token == "" || req.NewPassword == "" {
+		respondError(w, http.StatusBadRequest, "Token and new password are required")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	resetToken, err := h.storage.ConsumePasswordResetToken(ctx, config.HashResetToken(req.Token))
+	if err != nil {
+		if errors.Is(err, types.ErrInvalidToken) {
+			respondError(w, http.StatusUnauthorized, "Invalid or expired reset token")
+			return
+		}
+		h.logger.Error("Failed to consume reset token", "error", err)
+		respondError(w, http.StatusInternalServerError, "Failed to reset password")
+		return
+	}
+
+	user, err := h.storage.GetUser(ctx, resetToken.UserID)
+	if err != nil {
+		// The user was deleted after the token was issued
+		respondError(w, http.StatusUnauthorized, "Invalid or expired reset token")
+		return
+	}
+
+	if !user.Active {
+		respondError(w, http.StatusUnauthorized, "Account is disabled")
+		return
+	}
+
+	hashedPassword, err := config.HashPassword(req.NewPassword)
+	if err != nil {
+		h.logger.Error("Failed to hash password", "error", err)
+		respondError(w, http.StatusInternalServerError, "Failed to reset password")
+		return
+	}
+
+	user.PasswordHash = hashedPassword
+	user.MustChangePassword = false
+	user.UpdatedAt = time.Now()
+
+	if err := h.storage.UpdateUser(ctx, user); err != nil {
+		h.logger.Error("Failed to update user", "error", err)
+		respondError(w, http.StatusInternalServerError, "Failed to reset password")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{
+		"message": "Password reset successfully",
+	})
+}
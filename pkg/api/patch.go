@@ -0,0 +1,278 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"discobox/internal/types"
+)
+
+// Partial update endpoints using JSON merge-patch (RFC 7396) semantics:
+// omitted fields are left untouched, explicit nulls clear a field, and
+// nested objects such as metadata are merged key by key. The version of the
+// entity that was patched is checked again when storing it, so a concurrent
+// write between read and update surfaces as a 409.
+
+// handlePatchService handles PATCH /api/v1/services/{id}
+func (h *Handler) handlePatchService(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	var patch map[string]any
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	existingService, err := h.storage.GetService(ctx, id)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "Service not found")
+		return
+	}
+
+	var req ServiceRequest
+	if err := applyMergePatch(serviceToRequest(existingService), patch, &req); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// The ID always comes from the URL
+	req.ID = id
+
+	if err := h.validateService(r.Context(), &req); err != nil {
+		respondValidationError(w, err)
+		return
+	}
+
+	service, err := parseServiceRequest(&req, existingService)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Fields not exposed through the request model are carried over
+	service.TLS = existingService.TLS
+
+	if service.Version, err = expectedVersion(r, req.Version); err != nil {
+		respondVersionError(w, err)
+		return
+	}
+
+	if err := h.storage.UpdateService(ctx, service); err != nil {
+		if errors.Is(err, types.ErrVersionConflict) {
+			respondError(w, http.StatusConflict, "Service was modified by another request")
+			return
+		}
+		h.logger.Error("failed to patch service", "error", err, "id", id)
+		respondError(w, http.StatusInternalServerError, "Failed to update service")
+		return
+	}
+
+	setETag(w, service.Version)
+	respondJSON(w, http.StatusOK, serviceToResponse(service))
+}
+
+// handlePatchRoute handles PATCH /api/v1/routes/{id}
+func (h *Handler) handlePatchRoute(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	var patch map[string]any
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	existingRoute, err := h.storage.GetRoute(ctx, id)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "Route not found")
+		return
+	}
+
+	var route types.Route
+	if err := applyMergePatch(existingRoute, patch, &route); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// The ID always comes from the URL
+	route.ID = id
+
+	if err := validateRoute(&route, h.config); err != nil {
+		respondValidationError(w, err)
+		return
+	}
+
+	// Verify services exist
+	if _, err := h.storage.GetService(ctx, route.ServiceID); err != nil {
+		respondError(w, http.StatusBadRequest, "Service not found")
+		return
+	}
+	if route.Canary != nil {
+		if _, err := h.storage.GetService(ctx, route.Canary.ServiceID); err != nil {
+			respondError(w, http.StatusBadRequest, "Canary service not found")
+			return
+		}
+	}
+
+	if route.Version, err = expectedVersion(r, route.Version); err != nil {
+		respondVersionError(w, err)
+		return
+	}
+
+	if !h.checkRouteConflicts(ctx, w, &route) {
+		return
+	}
+
+	if err := h.storage.UpdateRoute(ctx, &route); err != nil {
+		if errors.Is(err, types.ErrVersionConflict) {
+			respondError(w, http.StatusConflict, "Route was modified by another request")
+			return
+		}
+		h.logger.Error("failed to patch route", "error", err, "id", id)
+		respondError(w, http.StatusInternalServerError, "Failed to update route")
+		return
+	}
+
+	setETag(w, route.Version)
+	respondJSON(w, http.StatusOK, routeToResponse(&route))
+}
+
+// serviceToRequest converts a types.Service to the ServiceRequest shape clients patch against
+func serviceToRequest(s *types.Service) ServiceRequest {
+	req := ServiceRequest{
+		ID:             s.ID,
+		Name:           s.Name,
+		Endpoints:      s.Endpoints,
+		HealthPath:     s.HealthPath,
+		HealthCheck:    serviceHealthCheckToResponse(s.HealthCheck),
+		CircuitBreaker: serviceCircuitBreakerToResponse(s.CircuitBreaker),
+		Weight:         s.Weight,
+		MaxConns:       s.MaxConns,
+		Timeout:        s.Timeout.String(),
+		Metadata:       s.Metadata,
+		EndpointTags:   s.EndpointTags,
+		StripPrefix:    s.StripPrefix,
+		UpstreamScheme: s.UpstreamScheme,
+		Active:         s.Active,
+		Version:        s.Version,
+	}
+	if s.TLS != nil {
+		req.TLS = &ServiceTLS{
+			Enabled:            s.TLS.Enabled,
+			InsecureSkipVerify: s.TLS.InsecureSkipVerify,
+			ServerName:         s.TLS.ServerName,
+			RootCAs:            s.TLS.RootCAs,
+			ClientCert:         s.TLS.ClientCert,
+			ClientKey:          s.TLS.ClientKey,
+		}
+	}
+	return req
+}
+
+// applyMergePatch merges patch into the JSON form of original and decodes the result into out
+func applyMergePatch(original any, patch map[string]any, out any) error {
+	data, err := json.Marshal(original)
+	if err != nil {
+		return fmt.Errorf("failed to encode existing resource: %w", err)
+	}
+
+	var doc map[string]any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("failed to decode existing resource: %w", err)
+	}
+
+	merged, err := json.Marshal(mergePatch(doc, patch))
+	if err != nil {
+		return fmt.Errorf("failed to encode patched resource: %w", err)
+	}
+
+	if err := json.Unmarshal(merged, out); err != nil {
+		return fmt.Errorf("invalid patch: %v", err)
+	}
+
+	return nil
+}
+
+// mergePatch applies an RFC 7396 merge patch to target
+func mergePatch(target, patch map[string]any) map[string]any {
+	if target == nil {
+		target = make(map[string]any)
+	}
+
+	for key, value := range patch {
+		if value == nil {
+			delete(target, key)
+			continue
+		}
+
+		if patchObj, ok := value.(map[string]any); ok {
+			targetObj, _ := target[key].(map[string]any)
+			target[key] = mergePatch(targetObj, patchObj)
+			continue
+		}
+
+		target[key] = value
+	}
+
+	return target
+}
+
+// errVersionRequired is returned for updates that name no version, so a
+// client that never read the entity can't overwrite a concurrent edit
+var errVersionRequired = errors.New(`update requires a version: send If-Match or "version", or If-Match: * to overwrite`)
+
+// expectedVersion returns the version a client expects to be updating: the
+// If-Match header when present, otherwise the version sent in the body.
+// If-Match: * forces the update and returns zero, which storage treats as
+// unconditional. Sending neither is an error.
+func expectedVersion(r *http.Request, bodyVersion int64) (int64, error) {
+	ifMatch := strings.TrimSpace(r.Header.Get("If-Match"))
+	if ifMatch == "*" {
+		return 0, nil
+	}
+	if ifMatch == "" {
+		if bodyVersion <= 0 {
+			return 0, errVersionRequired
+		}
+		return bodyVersion, nil
+	}
+
+	tag := strings.Trim(strings.TrimPrefix(ifMatch, "W/"), `"`)
+	version, err := strconv.ParseInt(tag, 10, 64)
+	if err != nil || version <= 0 {
+		return 0, fmt.Errorf("invalid If-Match header: %s", ifMatch)
+	}
+
+	return version, nil
+}
+
+// respondVersionError reports an update's missing or malformed version
+func respondVersionError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errVersionRequired) {
+		respondError(w, http.StatusPreconditionRequired, err.Error())
+		return
+	}
+	respondError(w, http.StatusBadRequest, err.Error())
+}
+
+// setETag exposes an entity version as its ETag
+func setETag(w http.ResponseWriter, version int64) {
+	if version > 0 {
+		w.Header().Set("ETag", fmt.Sprintf(`"%d"`, version))
+	}
+}
@@ -2,9 +2,14 @@ package api
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/mail"
+	"strconv"
 	"strings"
 	"time"
 	
@@ -41,8 +46,8 @@ func (h *Handler) handleCreateUser(w http.ResponseWriter, r *http.Request) {
 	}
 	
 	// Validate request
-	if req.Username == "" || req.Password == "" {
-		respondError(w, http.StatusBadRequest, "Username and password are required")
+	if err := validateCreateUserRequest(&req); err != nil {
+		respondValidationError(w, err)
 		return
 	}
 	
@@ -72,7 +77,7 @@ func (h *Handler) handleCreateUser(w http.ResponseWriter, r *http.Request) {
 	defer cancel()
 	
 	if err := h.storage.CreateUser(ctx, user); err != nil {
-		if strings.Contains(err.Error(), "already exists") || strings.Contains(err.Error(), "already taken") {
+		if errors.Is(err, types.ErrAlreadyExists) {
 			respondError(w, http.StatusConflict, "Username already exists")
 			return
 		}
@@ -126,6 +131,12 @@ func (h *Handler) handleUpdateUser(w http.ResponseWriter, r *http.Request) {
 	// Ensure ID matches
 	user.ID = userID
 	
+	// Validate user
+	if err := validateUser(&user); err != nil {
+		respondValidationError(w, err)
+		return
+	}
+	
 	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
 	
@@ -145,7 +156,7 @@ func (h *Handler) handleUpdateUser(w http.ResponseWriter, r *http.Request) {
 	user.PasswordHash = existing.PasswordHash
 	
 	if err := h.storage.UpdateUser(ctx, &user); err != nil {
-		if strings.Contains(err.Error(), "already taken") {
+		if errors.Is(err, types.ErrAlreadyExists) {
 			respondError(w, http.StatusConflict, "Username already taken")
 			return
 		}
@@ -244,6 +255,68 @@ func (h *Handler) handleChangePassword(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// handleForcePasswordReset handles POST /api/v1/users/{id}/force-password-reset.
+// For a suspected compromise: the user must change their password and every
+// API key and session they hold is revoked, so they have to log in again.
+func (h *Handler) handleForcePasswordReset(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userID := vars["id"]
+	
+	if h.config.API.Auth && r.Header.Get("X-User-Admin") != "true" {
+		respondError(w, http.StatusForbidden, "Forbidden - admin access required")
+		return
+	}
+	
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	
+	user, err := h.storage.GetUser(ctx, userID)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			respondError(w, http.StatusNotFound, "User not found")
+			return
+		}
+		h.logger.Error("Failed to get user", "error", err)
+		respondError(w, http.StatusInternalServerError, "Failed to force password reset")
+		return
+	}
+	
+	// Flag the user and revoke their keys together, so a failure can't leave
+	// them flagged with live sessions or signed out without the flag
+	var revoked int
+	err = h.storage.Tx(ctx, func(tx types.Storage) error {
+		user.MustChangePassword = true
+		user.UpdatedAt = time.Now()
+		if err := tx.UpdateUser(ctx, user); err != nil {
+			return err
+		}
+		
+		var err error
+		revoked, err = tx.RevokeAllAPIKeysByUser(ctx, userID)
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, types.ErrVersionConflict) {
+			respondError(w, http.StatusConflict, "User or API keys changed during the reset, retry the request")
+			return
+		}
+		h.logger.Error("Failed to force password reset", "error", err)
+		respondError(w, http.StatusInternalServerError, "Failed to force password reset")
+		return
+	}
+	
+	h.logger.Info("Password reset forced",
+		"user_id", userID,
+		"by", r.Header.Get("X-User-ID"),
+		"revoked", revoked,
+	)
+	
+	respondJSON(w, http.StatusOK, map[string]any{
+		"must_change_password": true,
+		"revoked":              revoked,
+	})
+}
+
 // handleListUserAPIKeys handles GET /api/v1/users/{id}/api-keys
 func (h *Handler) handleListUserAPIKeys(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -334,6 +407,91 @@ func (h *Handler) handleCreateAPIKey(w http.ResponseWriter, r *http.Request) {
 	respondJSON(w, http.StatusCreated, apiKey)
 }
 
+// Pagination defaults for GET /api/v1/api-keys
+const (
+	defaultAPIKeyLimit = 100
+	maxAPIKeyLimit     = 1000
+)
+
+// handleListAPIKeys handles GET /api/v1/api-keys. It lists every user's keys,
+// newest first, for admins auditing access; key values are masked.
+func (h *Handler) handleListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	if h.config.API.Auth && r.Header.Get("X-User-Admin") != "true" {
+		respondError(w, http.StatusForbidden, "Forbidden - admin access required")
+		return
+	}
+	
+	limit, offset := defaultAPIKeyLimit, 0
+	query := r.URL.Query()
+	if query.Has("limit") {
+		n, err := strconv.Atoi(query.Get("limit"))
+		if err != nil || n < 1 || n > maxAPIKeyLimit {
+			respondError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxAPIKeyLimit))
+			return
+		}
+		limit = n
+	}
+	if query.Has("offset") {
+		n, err := strconv.Atoi(query.Get("offset"))
+		if err != nil || n < 0 {
+			respondError(w, http.StatusBadRequest, "offset must be a non-negative integer")
+			return
+		}
+		offset = n
+	}
+	
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	
+	apiKeys, err := h.storage.ListAPIKeys(ctx)
+	if err != nil {
+		h.logger.Error("Failed to list API keys", "error", err)
+		respondError(w, http.StatusInternalServerError, "Failed to list API keys")
+		return
+	}
+	
+	start := min(offset, len(apiKeys))
+	page := apiKeys[start : start+min(limit, len(apiKeys)-start)]
+	resp := APIKeyListResponse{
+		APIKeys: make([]APIKeyResponse, 0, len(page)),
+		Total:   len(apiKeys),
+		Limit:   limit,
+		Offset:  offset,
+	}
+	for _, apiKey := range page {
+		resp.APIKeys = append(resp.APIKeys, APIKeyResponse{
+			ID:          apiKeyID(apiKey.Key),
+			Key:         maskAPIKey(apiKey.Key),
+			UserID:      apiKey.UserID,
+			Name:        apiKey.Name,
+			Description: apiKey.Description,
+			Active:      apiKey.Active,
+			CreatedAt:   apiKey.CreatedAt,
+			LastUsedAt:  apiKey.LastUsedAt,
+			ExpiresAt:   apiKey.ExpiresAt,
+			Metadata:    apiKey.Metadata,
+		})
+	}
+	
+	respondJSON(w, http.StatusOK, resp)
+}
+
+// apiKeyID returns the fingerprint that identifies a key in listings without
+// revealing it
+func apiKeyID(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:8])
+}
+
+// maskAPIKey keeps the first and last four characters of a key so admins can
+// tell keys apart; short keys are hidden entirely
+func maskAPIKey(key string) string {
+	if len(key) < 16 {
+		return "****"
+	}
+	return key[:4] + "..." + key[len(key)-4:]
+}
+
 // handleRevokeAPIKey handles DELETE /api/v1/api-keys/{key}
 func (h *Handler) handleRevokeAPIKey(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -342,7 +500,14 @@ func (h *Handler) handleRevokeAPIKey(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
 	
-	if err := h.storage.RevokeAPIKey(ctx, key); err != nil {
+	// The key may be named by the fingerprint the listing shows
+	err := h.storage.RevokeAPIKey(ctx, key)
+	if err != nil && strings.Contains(err.Error(), "not found") {
+		if match, ok := h.apiKeyByID(ctx, key); ok {
+			err = h.storage.RevokeAPIKey(ctx, match)
+		}
+	}
+	if err != nil {
 		if strings.Contains(err.Error(), "not found") {
 			respondError(w, http.StatusNotFound, "API key not found")
 			return
@@ -355,8 +520,70 @@ func (h *Handler) handleRevokeAPIKey(w http.ResponseWriter, r *http.Request) {
 	respondJSON(w, http.StatusNoContent, nil)
 }
 
+// apiKeyByID returns the key whose fingerprint is id
+func (h *Handler) apiKeyByID(ctx context.Context, id string) (string, bool) {
+	apiKeys, err := h.storage.ListAPIKeys(ctx)
+	if err != nil {
+		return "", false
+	}
+	for _, apiKey := range apiKeys {
+		if apiKeyID(apiKey.Key) == id {
+			return apiKey.Key, true
+		}
+	}
+	return "", false
+}
+
+// handleRevokeUserAPIKeys handles DELETE /api/v1/users/{id}/api-keys
+func (h *Handler) handleRevokeUserAPIKeys(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userID := vars["id"]
+	
+	// Only admins may revoke another user's keys
+	if h.config.API.Auth && r.Header.Get("X-User-Admin") != "true" && r.Header.Get("X-User-ID") != userID {
+		respondError(w, http.StatusForbidden, "Forbidden - admin access required to revoke another user's keys")
+		return
+	}
+	
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	
+	// Verify user exists
+	if _, err := h.storage.GetUser(ctx, userID); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			respondError(w, http.StatusNotFound, "User not found")
+			return
+		}
+		h.logger.Error("Failed to get user", "error", err)
+		respondError(w, http.StatusInternalServerError, "Failed to revoke API keys")
+		return
+	}
+	
+	// Session keys are API keys too, so this also ends active sessions
+	revoked, err := h.storage.RevokeAllAPIKeysByUser(ctx, userID)
+	if err != nil {
+		if errors.Is(err, types.ErrVersionConflict) {
+			respondError(w, http.StatusConflict, "API keys changed during revocation, retry the request")
+			return
+		}
+		h.logger.Error("Failed to revoke API keys", "error", err)
+		respondError(w, http.StatusInternalServerError, "Failed to revoke API keys")
+		return
+	}
+	
+	h.logger.Info("revoked all API keys for user", "user_id", userID, "revoked", revoked)
+	
+	respondJSON(w, http.StatusOK, map[string]int{
+		"revoked": revoked,
+	})
+}
+
 // Authentication endpoints
 
+// passwordChangeSessionTTL is how long the session of a user who must change
+// their password lasts
+const passwordChangeSessionTTL = 15 * time.Minute
+
 // handleLogin handles POST /api/v1/auth/login
 func (h *Handler) handleLogin(w http.ResponseWriter, r *http.Request) {
 	var creds types.UserCredentials
@@ -393,6 +620,13 @@ func (h *Handler) handleLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	
+	// A user who must change their password only gets a short session, and
+	// storageAuthMiddleware limits it to the password change endpoint
+	sessionType, sessionTTL := "session", 24*time.Hour
+	if user.MustChangePassword {
+		sessionType, sessionTTL = "password_change", passwordChangeSessionTTL
+	}
+	
 	// Update last login time
 	now := time.Now()
 	user.LastLoginAt = &now
@@ -406,14 +640,14 @@ func (h *Handler) handleLogin(w http.ResponseWriter, r *http.Request) {
 		Description: "Auto-generated session key",
 		Active:      true,
 		CreatedAt:   time.Now(),
-		ExpiresAt:   &time.Time{}, // Set expiration for 24 hours
+		ExpiresAt:   &time.Time{}, // Set expiration below
 		Metadata: map[string]string{
-			"type": "session",
+			"type": sessionType,
 		},
 	}
 	
-	// Set expiration to 24 hours
-	expires := time.Now().Add(24 * time.Hour)
+	// Set expiration
+	expires := time.Now().Add(sessionTTL)
 	sessionKey.ExpiresAt = &expires
 	
 	if err := h.storage.CreateAPIKey(ctx, sessionKey); err != nil {
@@ -476,4 +710,42 @@ func (h *Handler) handleWhoAmI(w http.ResponseWriter, r *http.Request) {
 	user.PasswordHash = ""
 	
 	respondJSON(w, http.StatusOK, user)
-}
\ No newline at end of file
+}
+
+// validateCreateUserRequest validates a new user request
+func validateCreateUserRequest(req *types.CreateUserRequest) error {
+	var errs ValidationErrors
+	
+	if req.Username == "" {
+		errs.Add("username", "username is required")
+	}
+	
+	if req.Password == "" {
+		errs.Add("password", "password is required")
+	}
+	
+	if req.Email != "" {
+		if _, err := mail.ParseAddress(req.Email); err != nil {
+			errs.Add("email", "email address is invalid")
+		}
+	}
+	
+	return errs.Err()
+}
+
+// validateUser validates a user update
+func validateUser(user *types.User) error {
+	var errs ValidationErrors
+	
+	if user.Username == "" {
+		errs.Add("username", "username is required")
+	}
+	
+	if user.Email != "" {
+		if _, err := mail.ParseAddress(user.Email); err != nil {
+			errs.Add("email", "email address is invalid")
+		}
+	}
+	
+	return errs.Err()
+}
@@ -0,0 +1,155 @@
+// Package proxytest runs the proxy in memory for tests.
+package proxytest
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+
+	"discobox/internal/balancer"
+	"discobox/internal/proxy"
+	"discobox/internal/router"
+	"discobox/internal/types"
+)
+
+// Harness runs requests through the full route match, backend selection
+// and proxy path without any listening sockets. Service endpoints are served
+// by in-memory handlers registered with Backend, so routing configuration
+// can be exercised from unit tests.
+//
+// Routes and services should be in storage before the harness is created;
+// later changes reach the router asynchronously through storage watches.
+type Harness struct {
+	proxy     *proxy.Proxy
+	router    types.Router
+	transport *harnessTransport
+}
+
+// NewHarness creates a harness over storage. Unset options get the same
+// defaults the server uses: a storage-backed router, round robin balancing
+// and the standard URL rewriter. opts.Transport, if set, handles endpoints
+// with no in-memory backend; otherwise requests to them fail with 502.
+func NewHarness(storage types.Storage, opts proxy.Options) *Harness {
+	if opts.Logger == nil {
+		opts.Logger = nopLogger{}
+	}
+	if opts.Router == nil {
+		opts.Router = router.NewRouter(storage, opts.Logger)
+	}
+	if opts.LoadBalancer == nil {
+		opts.LoadBalancer = balancer.NewRoundRobin()
+	}
+	if opts.Rewriter == nil {
+		opts.Rewriter = proxy.NewURLRewriter()
+	}
+
+	transport := &harnessTransport{
+		backends: make(map[string]http.Handler),
+		fallback: opts.Transport,
+	}
+	opts.Transport = transport
+	opts.Storage = storage
+
+	return &Harness{
+		proxy:     proxy.New(opts),
+		router:    opts.Router,
+		transport: transport,
+	}
+}
+
+// Backend serves requests for a service endpoint with handler. The endpoint
+// is matched by host, so "http://users-1" handles every request the proxy
+// sends to that endpoint.
+func (h *Harness) Backend(endpoint string, handler http.Handler) {
+	h.transport.mu.Lock()
+	defer h.transport.mu.Unlock()
+	h.transport.backends[endpointHost(endpoint)] = handler
+}
+
+// Do sends req through the proxy and returns the recorded response
+func (h *Harness) Do(req *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.proxy.ServeHTTP(rec, req)
+	return rec
+}
+
+// Proxy returns the proxy under test
+func (h *Harness) Proxy() *proxy.Proxy {
+	return h.proxy
+}
+
+// Router returns the router used to match requests
+func (h *Harness) Router() types.Router {
+	return h.router
+}
+
+// Close stops the proxy's and the router's storage watches
+func (h *Harness) Close() error {
+	h.proxy.Stop()
+	if closer, ok := h.router.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// harnessTransport dispatches upstream requests to in-memory handlers by host
+type harnessTransport struct {
+	mu       sync.RWMutex
+	backends map[string]http.Handler
+	fallback http.RoundTripper
+}
+
+func (ht *harnessTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ht.mu.RLock()
+	handler, ok := ht.backends[strings.ToLower(req.URL.Host)]
+	ht.mu.RUnlock()
+
+	if !ok {
+		if ht.fallback != nil {
+			return ht.fallback.RoundTrip(req)
+		}
+		return nil, fmt.Errorf("no test backend for %s", req.URL.Host)
+	}
+
+	// Present the request as a server would receive it
+	inbound := req.Clone(req.Context())
+	inbound.RequestURI = req.URL.RequestURI()
+	inbound.RemoteAddr = "127.0.0.1:0"
+	if inbound.Body == nil {
+		inbound.Body = http.NoBody
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, inbound)
+
+	// A network transport fails requests canceled before the response
+	if err := req.Context().Err(); err != nil {
+		return nil, err
+	}
+
+	resp := rec.Result()
+	resp.Request = req
+	return resp, nil
+}
+
+// endpointHost returns the lowercased host of an endpoint URL, or the
+// endpoint itself when it has no scheme
+func endpointHost(endpoint string) string {
+	if u, err := url.Parse(endpoint); err == nil && u.Host != "" {
+		return strings.ToLower(u.Host)
+	}
+	return strings.ToLower(endpoint)
+}
+
+// nopLogger discards log output
+type nopLogger struct{}
+
+func (nopLogger) Debug(msg string, fields ...any) {}
+func (nopLogger) Info(msg string, fields ...any)  {}
+func (nopLogger) Warn(msg string, fields ...any)  {}
+func (nopLogger) Error(msg string, fields ...any) {}
+func (nopLogger) With(fields ...any) types.Logger { return nopLogger{} }
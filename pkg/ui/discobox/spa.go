@@ -0,0 +1,152 @@
+package discobox_ui
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// spaHandler serves the SPA UI, returning index.html for non-existent paths
+type spaHandler struct {
+	fs       http.FileSystem
+	basePath string // Prefix the UI is hosted under, without a trailing slash; empty at the root
+}
+
+// NewHandler serves the UI files in fs, answering paths that don't exist
+// with index.html so client-side routes load the app. With a base path,
+// requests arrive with it stripped and HTML pages are rewritten so their
+// links and assets point under it.
+func NewHandler(fs http.FileSystem, basePath string) http.Handler {
+	return &spaHandler{fs: fs, basePath: NormalizeBasePath(basePath)}
+}
+
+// NormalizeBasePath turns a configured base path such as "admin/" into the
+// "/admin" form handlers use. The root becomes the empty string.
+func NormalizeBasePath(basePath string) string {
+	basePath = strings.Trim(basePath, "/")
+	if basePath == "" {
+		return ""
+	}
+	return "/" + basePath
+}
+
+// apiPaths are served by the API handler when it shares a server with the UI
+var apiPaths = []string{"/api/", "/health", "/livez", "/readyz", "/prometheus/metrics"}
+
+// Mount serves the API and UI handlers together under basePath. Both see
+// request paths with the base path removed, and requests outside it get 404.
+func Mount(apiHandler, uiHandler http.Handler, basePath string) http.Handler {
+	mux := http.NewServeMux()
+	for _, p := range apiPaths {
+		mux.Handle(p, apiHandler)
+	}
+	mux.Handle("/", uiHandler)
+
+	basePath = NormalizeBasePath(basePath)
+	if basePath == "" {
+		return mux
+	}
+
+	root := http.NewServeMux()
+	root.Handle(basePath+"/", http.StripPrefix(basePath, mux))
+	root.Handle(basePath, http.RedirectHandler(basePath+"/", http.StatusMovedPermanently))
+	return root
+}
+
+func (h *spaHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Try to open the requested file
+	path := r.URL.Path
+	if path == "/" {
+		path = "/index.html"
+	}
+
+	file, err := h.fs.Open(path)
+	if err != nil {
+		// If file doesn't exist, serve index.html for client-side routing
+		file, err = h.fs.Open("/index.html")
+		if err != nil {
+			http.Error(w, "index.html not found", http.StatusInternalServerError)
+			return
+		}
+		path = "/index.html"
+		// Set path back to "/" so http.ServeContent doesn't get confused
+		r.URL.Path = "/"
+	}
+	defer file.Close()
+
+	// Get file info
+	stat, err := file.Stat()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Serve the file
+	if stat.IsDir() {
+		// If it's a directory, try index.html
+		indexFile, err := h.fs.Open(path + "/index.html")
+		if err != nil {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+		defer indexFile.Close()
+
+		indexStat, err := indexFile.Stat()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		h.serveFile(w, r, path+"/index.html", indexStat.ModTime(), indexFile)
+	} else {
+		h.serveFile(w, r, path, stat.ModTime(), file)
+	}
+}
+
+// serveFile sends a UI file, rewriting HTML for the base path
+func (h *spaHandler) serveFile(w http.ResponseWriter, r *http.Request, name string, modTime time.Time, file http.File) {
+	if h.basePath == "" || path.Ext(name) != ".html" {
+		if seeker, ok := file.(io.ReadSeeker); ok {
+			http.ServeContent(w, r, name, modTime, seeker)
+		} else {
+			http.Error(w, "File not seekable", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	content, err := io.ReadAll(file)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.ServeContent(w, r, name, modTime, bytes.NewReader(h.rewriteHTML(content)))
+}
+
+// rootReference matches root-relative href and src attributes, and the
+// entry imports of SvelteKit's startup script, leaving protocol-relative
+// //host URLs alone
+var rootReference = regexp.MustCompile(`((?:href|src)=["']|import\(["'])/([^/]|["'])`)
+
+// kitBase matches the base path in SvelteKit's startup script, which the app
+// reads at runtime for $app/paths and its client-side router
+var kitBase = regexp.MustCompile(`(__sveltekit_\w+\s*=\s*\{\s*base:\s*)"[^"]*"`)
+
+// rewriteHTML points root-relative links and assets under the base path,
+// hands the base path to the app so its routes and links resolve under it,
+// and adds a <base> tag so relative references resolve from it on deep
+// routes too
+func (h *spaHandler) rewriteHTML(content []byte) []byte {
+	content = rootReference.ReplaceAll(content, []byte("${1}"+h.basePath+"/${2}"))
+	content = kitBase.ReplaceAll(content, []byte(`${1}"`+h.basePath+`"`))
+
+	baseTag := []byte(`<base href="` + h.basePath + `/">`)
+	if head := bytes.Index(bytes.ToLower(content), []byte("<head>")); head >= 0 {
+		at := head + len("<head>")
+		return append(content[:at:at], append(baseTag, content[at:]...)...)
+	}
+	return append(baseTag, content...)
+}
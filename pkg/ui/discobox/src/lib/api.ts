@@ -1,9 +1,16 @@
 import { browser } from '$app/environment';
+import { base } from '$app/paths';
 
 import type { Service, Route, Metrics, Health } from '$lib/types';
 
+// apiUrl returns the URL of an API path. When the UI is hosted under a base
+// path the server sets it as the app's base, and the API lives under the
+// same prefix.
+export function apiUrl(path: string): string {
+	return `${base}/api/v1${path}`;
+}
+
 class ApiClient {
-	private baseUrl = '/api/v1';
 	
 	private getHeaders(): HeadersInit {
 		const headers: HeadersInit = {
@@ -19,7 +26,7 @@ class ApiClient {
 	}
 	
 	async request<T>(path: string, options: RequestInit = {}): Promise<T> {
-		const res = await fetch(`${this.baseUrl}${path}`, {
+		const res = await fetch(apiUrl(path), {
 			...options,
 			headers: {
 				...this.getHeaders(),
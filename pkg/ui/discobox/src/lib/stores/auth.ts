@@ -1,5 +1,6 @@
 import { writable, derived } from 'svelte/store';
 import type { User } from '$lib/types';
+import { apiUrl } from '$lib/api';
 
 interface AuthState {
 	user: User | null;
@@ -19,7 +20,7 @@ function createAuthStore() {
 		login: async (username: string, password: string) => {
 			update(s => ({ ...s, loading: true }));
 			try {
-				const res = await fetch('/api/v1/auth/login', {
+				const res = await fetch(apiUrl('/auth/login'), {
 					method: 'POST',
 					headers: { 'Content-Type': 'application/json' },
 					body: JSON.stringify({ username, password })
@@ -52,7 +53,7 @@ function createAuthStore() {
 			
 			update(s => ({ ...s, loading: true }));
 			try {
-				const res = await fetch('/api/v1/auth/whoami', {
+				const res = await fetch(apiUrl('/auth/whoami'), {
 					headers: { 'X-API-Key': apiKey }
 				});
 				
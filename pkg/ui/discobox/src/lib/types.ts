@@ -18,6 +18,7 @@ export interface Service {
 	tls?: any;
 	strip_prefix?: boolean;
 	active: boolean;
+	version: number;
 	created_at: string;
 	updated_at: string;
 }
@@ -33,6 +34,7 @@ export interface Route {
 	middlewares?: string[];
 	rewrite_rules?: any[];
 	metadata?: Record<string, any>;
+	version: number;
 }
 
 export interface Metrics {
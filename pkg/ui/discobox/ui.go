@@ -2,8 +2,11 @@ package discobox_ui
 
 import (
 	"embed"
+	"fmt"
 	"io/fs"
 	"net/http"
+	"os"
+	"path/filepath"
 )
 
 //go:generate npm i
@@ -19,3 +22,20 @@ func GetFileSystem() http.FileSystem {
 	}
 	return http.FS(distFS)
 }
+
+// FileSystem returns the UI files in dir, or the embedded build when dir is
+// empty. The directory must hold an index.html.
+func FileSystem(dir string) (http.FileSystem, error) {
+	if dir == "" {
+		return GetFileSystem(), nil
+	}
+
+	info, err := os.Stat(filepath.Join(dir, "index.html"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid UI directory %s: %w", dir, err)
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("invalid UI directory %s: index.html is a directory", dir)
+	}
+	return http.Dir(dir), nil
+}
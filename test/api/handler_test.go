@@ -0,0 +1,1856 @@
+package api_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"discobox/internal/balancer"
+	"discobox/internal/circuit"
+	"discobox/internal/config"
+	"discobox/internal/proxy"
+	"discobox/internal/router"
+	"discobox/internal/server"
+	"discobox/internal/storage"
+	"discobox/internal/types"
+	"discobox/internal/version"
+	"discobox/pkg/api"
+	"discobox/pkg/proxytest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testLogger is a simple logger implementation for tests
+type testLogger struct{}
+
+func (l *testLogger) Debug(msg string, fields ...any) {}
+func (l *testLogger) Info(msg string, fields ...any)  {}
+func (l *testLogger) Warn(msg string, fields ...any)  {}
+func (l *testLogger) Error(msg string, fields ...any) {}
+func (l *testLogger) With(fields ...any) types.Logger { return l }
+
+// newTestAPI creates an unauthenticated API router backed by memory storage
+func newTestAPI(t *testing.T) (http.Handler, types.Storage) {
+	store := storage.NewMemory()
+	t.Cleanup(func() { store.Close() })
+
+	cfg := &types.ProxyConfig{}
+	return api.New(store, &testLogger{}, cfg).Router(), store
+}
+
+// doJSON sends a JSON request to the handler and returns the recorded response
+func doJSON(t *testing.T, handler http.Handler, method, path string, body any) *httptest.ResponseRecorder {
+	var buf bytes.Buffer
+	if body != nil {
+		require.NoError(t, json.NewEncoder(&buf).Encode(body))
+	}
+
+	req := httptest.NewRequest(method, path, &buf)
+	req.Header.Set("Content-Type", "application/json")
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+// decodeError decodes an ErrorResponse body
+func decodeError(t *testing.T, rec *httptest.ResponseRecorder) api.ErrorResponse {
+	var resp api.ErrorResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	return resp
+}
+
+// fieldsOf returns the field names reported in validation details
+func fieldsOf(details []types.ValidationError) []string {
+	fields := make([]string, len(details))
+	for i, d := range details {
+		fields[i] = d.Field
+	}
+	return fields
+}
+
+func TestValidationErrors(t *testing.T) {
+	handler, _ := newTestAPI(t)
+
+	t.Run("service", func(t *testing.T) {
+		rec := doJSON(t, handler, "POST", "/api/v1/services", map[string]any{
+			"endpoints":       []string{"http://localhost:8080", ""},
+			"timeout":         "soon",
+			"weight":          -1,
+			"upstream_scheme": "HTTPS",
+		})
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+		resp := decodeError(t, rec)
+		assert.NotEmpty(t, resp.Error)
+		assert.Equal(t, "validation_failed", resp.Code)
+		assert.ElementsMatch(t, []string{"name", "endpoints[1]", "timeout", "weight", "upstream_scheme"}, fieldsOf(resp.Details))
+	})
+
+	t.Run("route", func(t *testing.T) {
+		rec := doJSON(t, handler, "POST", "/api/v1/routes", map[string]any{
+			"path_regex":           "([",
+			"disabled_middlewares": []string{"compression", "gzip"},
+			"upstream_scheme":      "wss",
+			"max_response_bytes":   -1,
+		})
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+		resp := decodeError(t, rec)
+		assert.ElementsMatch(t, []string{"service_id", "path_regex", "disabled_middlewares[1]", "upstream_scheme", "max_response_bytes"}, fieldsOf(resp.Details))
+	})
+
+	t.Run("route rate limit cost", func(t *testing.T) {
+		store := storage.NewMemory()
+		t.Cleanup(func() { store.Close() })
+		cfg := &types.ProxyConfig{}
+		cfg.RateLimit.Enabled = true
+		cfg.RateLimit.Burst = 10
+		limited := api.New(store, &testLogger{}, cfg).Router()
+
+		for _, cost := range []string{"11", "lots", "0"} {
+			rec := doJSON(t, limited, "POST", "/api/v1/routes", map[string]any{
+				"service_id":  "svc",
+				"path_prefix": "/search",
+				"metadata":    map[string]string{"rate_limit_cost": cost},
+			})
+			assert.Equal(t, http.StatusUnprocessableEntity, rec.Code, "cost %s", cost)
+			assert.Equal(t, []string{"metadata.rate_limit_cost"}, fieldsOf(decodeError(t, rec).Details), "cost %s", cost)
+		}
+
+		rec := doJSON(t, limited, "POST", "/api/v1/routes", map[string]any{
+			"service_id":  "svc",
+			"path_prefix": "/search",
+			"metadata":    map[string]string{"rate_limit_cost": "10"},
+		})
+		assert.NotEqual(t, http.StatusUnprocessableEntity, rec.Code)
+	})
+
+	t.Run("route cors", func(t *testing.T) {
+		rec := doJSON(t, handler, "POST", "/api/v1/routes", map[string]any{
+			"service_id":  "svc",
+			"path_prefix": "/app",
+			"metadata":    map[string]string{"cors": "allowed_origins=*"},
+		})
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+		assert.Equal(t, []string{"metadata.cors"}, fieldsOf(decodeError(t, rec).Details))
+
+		rec = doJSON(t, handler, "POST", "/api/v1/routes", map[string]any{
+			"service_id":  "svc",
+			"path_prefix": "/app",
+			"metadata":    map[string]string{"cors": `{"allowed_origins": ["https://app.example.com"]}`},
+		})
+		assert.NotEqual(t, http.StatusUnprocessableEntity, rec.Code)
+	})
+
+	t.Run("user", func(t *testing.T) {
+		rec := doJSON(t, handler, "POST", "/api/v1/users", map[string]any{
+			"email": "not-an-email",
+		})
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+		resp := decodeError(t, rec)
+		assert.ElementsMatch(t, []string{"username", "password", "email"}, fieldsOf(resp.Details))
+	})
+
+	t.Run("malformed body is still a bad request", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/api/v1/services", bytes.NewBufferString("{"))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Empty(t, decodeError(t, rec).Details)
+	})
+}
+
+func TestServiceEndpointValidation(t *testing.T) {
+	handler, _ := newTestAPI(t)
+
+	tests := []struct {
+		name     string
+		endpoint string
+		wantErr  string
+	}{
+		{name: "http", endpoint: "http://localhost:8080"},
+		{name: "https with path", endpoint: "https://api.internal:8443/v1"},
+		{name: "ipv6", endpoint: "http://[::1]:9000"},
+		{name: "host and port without scheme", endpoint: "localhost:8080", wantErr: "must include a scheme"},
+		{name: "bare host", endpoint: "backend.internal", wantErr: "must include a scheme"},
+		{name: "unsupported scheme", endpoint: "ftp://files.internal", wantErr: "must be http or https"},
+		{name: "missing host", endpoint: "http://", wantErr: "must include a host"},
+		{name: "unparseable", endpoint: "http://[::1", wantErr: "not a valid URL"},
+		{name: "invalid port", endpoint: "http://localhost:port", wantErr: "not a valid URL"},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := doJSON(t, handler, "POST", "/api/v1/services", map[string]any{
+				"id":        fmt.Sprintf("svc-%d", i),
+				"name":      "svc",
+				"endpoints": []string{tt.endpoint},
+			})
+
+			if tt.wantErr == "" {
+				assert.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+				return
+			}
+
+			require.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+			resp := decodeError(t, rec)
+			require.Len(t, resp.Details, 1)
+			assert.Equal(t, "endpoints[0]", resp.Details[0].Field)
+			assert.Contains(t, resp.Details[0].Message, tt.wantErr)
+		})
+	}
+}
+
+func TestServiceEndpointTags(t *testing.T) {
+	handler, _ := newTestAPI(t)
+
+	tags := map[string]map[string]string{
+		"http://a:8080": {"zone": "us-east-1a", "version": "v2"},
+	}
+	rec := doJSON(t, handler, "POST", "/api/v1/services", map[string]any{
+		"id":            "tagged",
+		"name":          "tagged",
+		"endpoints":     []string{"http://a:8080", "http://b:8080"},
+		"endpoint_tags": tags,
+	})
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	rec = doJSON(t, handler, "GET", "/api/v1/services/tagged", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var service api.ServiceResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &service))
+	assert.Equal(t, tags, service.EndpointTags)
+
+	rec = doJSON(t, handler, "POST", "/api/v1/services", map[string]any{
+		"id":        "stray",
+		"name":      "stray",
+		"endpoints": []string{"http://a:8080"},
+		"endpoint_tags": map[string]map[string]string{
+			"http://c:8080": {"zone": "us-east-1b"},
+		},
+	})
+	require.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	resp := decodeError(t, rec)
+	require.Len(t, resp.Details, 1)
+	assert.Equal(t, "endpoint_tags[http://c:8080]", resp.Details[0].Field)
+}
+
+func TestServiceEndpointProbe(t *testing.T) {
+	store := storage.NewMemory()
+	t.Cleanup(func() { store.Close() })
+
+	cfg := &types.ProxyConfig{}
+	cfg.API.ProbeEndpoints = true
+	handler := api.New(store, &testLogger{}, cfg).Router()
+
+	backend := httptest.NewServer(http.NotFoundHandler())
+	defer backend.Close()
+
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	rec := doJSON(t, handler, "POST", "/api/v1/services", map[string]any{
+		"id": "up", "name": "up", "endpoints": []string{backend.URL},
+	})
+	assert.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	rec = doJSON(t, handler, "POST", "/api/v1/services", map[string]any{
+		"id": "down", "name": "down", "endpoints": []string{backend.URL, closed.URL},
+	})
+	require.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	resp := decodeError(t, rec)
+	assert.Equal(t, []string{"endpoints[1]"}, fieldsOf(resp.Details))
+	assert.Contains(t, resp.Details[0].Message, "not reachable")
+}
+
+func TestPatchService(t *testing.T) {
+	handler, store := newTestAPI(t)
+	ctx := context.Background()
+
+	require.NoError(t, store.CreateService(ctx, &types.Service{
+		ID:         "svc",
+		Name:       "Original",
+		Endpoints:  []string{"http://localhost:8080"},
+		HealthPath: "/healthz",
+		Weight:     3,
+		Timeout:    10 * time.Second,
+		Metadata:   map[string]string{"team": "core", "env": "prod"},
+		TLS:        &types.TLSConfig{Enabled: true, ServerName: "svc.internal"},
+		Active:     true,
+	}))
+
+	original, err := store.GetService(ctx, "svc")
+	require.NoError(t, err)
+
+	rec := doJSON(t, handler, "PATCH", "/api/v1/services/svc", map[string]any{
+		"active":   false,
+		"metadata": map[string]any{"env": nil},
+	})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	service, err := store.GetService(ctx, "svc")
+	require.NoError(t, err)
+
+	assert.False(t, service.Active)
+	assert.Equal(t, map[string]string{"team": "core"}, service.Metadata)
+
+	// Everything else is untouched
+	assert.Equal(t, "Original", service.Name)
+	assert.Equal(t, []string{"http://localhost:8080"}, service.Endpoints)
+	assert.Equal(t, "/healthz", service.HealthPath)
+	assert.Equal(t, 3, service.Weight)
+	assert.Equal(t, 10*time.Second, service.Timeout)
+	require.NotNil(t, service.TLS)
+	assert.Equal(t, "svc.internal", service.TLS.ServerName)
+	assert.True(t, service.CreatedAt.Equal(original.CreatedAt))
+	assert.True(t, service.UpdatedAt.After(original.UpdatedAt))
+
+	t.Run("invalid result is rejected", func(t *testing.T) {
+		rec := doJSON(t, handler, "PATCH", "/api/v1/services/svc", map[string]any{"name": nil})
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	})
+
+	t.Run("missing service", func(t *testing.T) {
+		rec := doJSON(t, handler, "PATCH", "/api/v1/services/missing", map[string]any{"active": true})
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
+
+func TestPatchRoute(t *testing.T) {
+	handler, store := newTestAPI(t)
+	ctx := context.Background()
+
+	require.NoError(t, store.CreateService(ctx, &types.Service{ID: "svc", Name: "svc", Endpoints: []string{"http://localhost"}}))
+	require.NoError(t, store.CreateRoute(ctx, &types.Route{
+		ID:          "route",
+		Priority:    100,
+		Host:        "example.com",
+		PathPrefix:  "/api",
+		ServiceID:   "svc",
+		Middlewares: []string{"compression"},
+		RewriteRules: []types.RewriteRule{
+			{Type: "strip_prefix", Pattern: "/api"},
+		},
+		Metadata: map[string]any{"description": "api"},
+	}))
+
+	rec := doJSON(t, handler, "PATCH", "/api/v1/routes/route", map[string]any{"priority": 5})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	route, err := store.GetRoute(ctx, "route")
+	require.NoError(t, err)
+
+	assert.Equal(t, 5, route.Priority)
+	assert.Equal(t, "example.com", route.Host)
+	assert.Equal(t, "/api", route.PathPrefix)
+	assert.Equal(t, "svc", route.ServiceID)
+	assert.Equal(t, []string{"compression"}, route.Middlewares)
+	assert.Equal(t, []types.RewriteRule{{Type: "strip_prefix", Pattern: "/api"}}, route.RewriteRules)
+	assert.Equal(t, "api", route.Metadata["description"])
+
+	t.Run("null clears a field", func(t *testing.T) {
+		rec := doJSON(t, handler, "PATCH", "/api/v1/routes/route", map[string]any{"host": nil})
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		route, err := store.GetRoute(ctx, "route")
+		require.NoError(t, err)
+		assert.Empty(t, route.Host)
+		assert.Equal(t, "/api", route.PathPrefix)
+	})
+}
+
+func TestOptimisticConcurrency(t *testing.T) {
+	handler, store := newTestAPI(t)
+	ctx := context.Background()
+
+	require.NoError(t, store.CreateService(ctx, &types.Service{ID: "svc", Name: "svc", Endpoints: []string{"http://localhost:8080"}}))
+
+	rec := doJSON(t, handler, "GET", "/api/v1/services/svc", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `"1"`, rec.Header().Get("ETag"))
+
+	update := func(ifMatch string) *httptest.ResponseRecorder {
+		var buf bytes.Buffer
+		require.NoError(t, json.NewEncoder(&buf).Encode(map[string]any{
+			"name":      "svc",
+			"endpoints": []string{"http://localhost:9090"},
+		}))
+
+		req := httptest.NewRequest("PUT", "/api/v1/services/svc", &buf)
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec = update(`"1"`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, `"2"`, rec.Header().Get("ETag"))
+
+	t.Run("stale If-Match is rejected", func(t *testing.T) {
+		rec := update(`"1"`)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+
+		service, err := store.GetService(ctx, "svc")
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), service.Version)
+	})
+
+	t.Run("stale body version is rejected", func(t *testing.T) {
+		rec := doJSON(t, handler, "PATCH", "/api/v1/services/svc", map[string]any{"version": 1, "active": true})
+		assert.Equal(t, http.StatusConflict, rec.Code)
+	})
+
+	t.Run("invalid If-Match", func(t *testing.T) {
+		rec := update("abc")
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("missing version is rejected", func(t *testing.T) {
+		rec := update("")
+		assert.Equal(t, http.StatusPreconditionRequired, rec.Code)
+
+		service, err := store.GetService(ctx, "svc")
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), service.Version)
+	})
+
+	t.Run("wildcard is unconditional", func(t *testing.T) {
+		rec := update("*")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, `"3"`, rec.Header().Get("ETag"))
+	})
+
+	t.Run("route", func(t *testing.T) {
+		require.NoError(t, store.CreateRoute(ctx, &types.Route{ID: "route", ServiceID: "svc", PathPrefix: "/"}))
+
+		rec := doJSON(t, handler, "PUT", "/api/v1/routes/route", map[string]any{
+			"service_id":  "svc",
+			"path_prefix": "/v2",
+			"version":     1,
+		})
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Equal(t, `"2"`, rec.Header().Get("ETag"))
+
+		rec = doJSON(t, handler, "PUT", "/api/v1/routes/route", map[string]any{
+			"service_id":  "svc",
+			"path_prefix": "/v3",
+			"version":     1,
+		})
+		assert.Equal(t, http.StatusConflict, rec.Code)
+	})
+}
+
+func TestCreateDuplicate(t *testing.T) {
+	handler, _ := newTestAPI(t)
+
+	service := map[string]any{"id": "svc", "name": "svc", "endpoints": []string{"http://localhost:8080"}}
+	require.Equal(t, http.StatusCreated, doJSON(t, handler, "POST", "/api/v1/services", service).Code)
+	assert.Equal(t, http.StatusConflict, doJSON(t, handler, "POST", "/api/v1/services", service).Code)
+
+	route := map[string]any{"id": "route", "service_id": "svc", "path_prefix": "/"}
+	require.Equal(t, http.StatusCreated, doJSON(t, handler, "POST", "/api/v1/routes", route).Code)
+	assert.Equal(t, http.StatusConflict, doJSON(t, handler, "POST", "/api/v1/routes", route).Code)
+
+	user := map[string]any{"username": "alice", "password": "password123", "email": "alice@example.com"}
+	require.Equal(t, http.StatusCreated, doJSON(t, handler, "POST", "/api/v1/users", user).Code)
+	assert.Equal(t, http.StatusConflict, doJSON(t, handler, "POST", "/api/v1/users", user).Code)
+}
+
+func TestPasswordReset(t *testing.T) {
+	store := storage.NewMemory()
+	t.Cleanup(func() { store.Close() })
+
+	// Capture issued tokens instead of delivering them
+	tokens := make(map[string]string)
+	h := api.New(store, &testLogger{}, &types.ProxyConfig{})
+	h.SetResetTokenNotifier(func(ctx context.Context, user *types.User, token string) error {
+		tokens[user.Username] = token
+		return nil
+	})
+	handler := h.Router()
+
+	user := map[string]any{"username": "alice", "password": "password123", "email": "alice@example.com"}
+	require.Equal(t, http.StatusCreated, doJSON(t, handler, "POST", "/api/v1/users", user).Code)
+
+	login := func(password string) int {
+		return doJSON(t, handler, "POST", "/api/v1/auth/login", map[string]any{
+			"username": "alice",
+			"password": password,
+		}).Code
+	}
+
+	t.Run("unknown account", func(t *testing.T) {
+		rec := doJSON(t, handler, "POST", "/api/v1/auth/forgot", map[string]any{"username": "nobody"})
+		assert.Equal(t, http.StatusAccepted, rec.Code)
+		assert.Empty(t, tokens)
+	})
+
+	t.Run("valid reset", func(t *testing.T) {
+		rec := doJSON(t, handler, "POST", "/api/v1/auth/forgot", map[string]any{"email": "Alice@Example.com"})
+		require.Equal(t, http.StatusAccepted, rec.Code)
+		require.NotEmpty(t, tokens["alice"])
+
+		rec = doJSON(t, handler, "POST", "/api/v1/auth/reset", map[string]any{
+			"token":        tokens["alice"],
+			"new_password": "newpassword456",
+		})
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, http.StatusOK, login("newpassword456"))
+		assert.Equal(t, http.StatusUnauthorized, login("password123"))
+	})
+
+	t.Run("reuse rejected", func(t *testing.T) {
+		rec := doJSON(t, handler, "POST", "/api/v1/auth/reset", map[string]any{
+			"token":        tokens["alice"],
+			"new_password": "anotherpassword",
+		})
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.Equal(t, http.StatusOK, login("newpassword456"))
+	})
+
+	t.Run("expired token", func(t *testing.T) {
+		existing, err := store.GetUserByUsername(context.Background(), "alice")
+		require.NoError(t, err)
+
+		const token = "expired-token"
+		require.NoError(t, store.CreatePasswordResetToken(context.Background(), &types.PasswordResetToken{
+			TokenHash: config.HashResetToken(token),
+			UserID:    existing.ID,
+			ExpiresAt: time.Now().Add(-time.Minute),
+		}))
+
+		rec := doJSON(t, handler, "POST", "/api/v1/auth/reset", map[string]any{
+			"token":        token,
+			"new_password": "anotherpassword",
+		})
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+}
+
+func TestPasswordResetRequiresNotifier(t *testing.T) {
+	store := storage.NewMemory()
+	t.Cleanup(func() { store.Close() })
+
+	h := api.New(store, &testLogger{}, &types.ProxyConfig{})
+	handler := h.Router()
+
+	user := map[string]any{"username": "alice", "password": "password123", "email": "alice@example.com"}
+	require.Equal(t, http.StatusCreated, doJSON(t, handler, "POST", "/api/v1/users", user).Code)
+
+	// Without a way to deliver tokens the endpoint is off
+	rec := doJSON(t, handler, "POST", "/api/v1/auth/forgot", map[string]any{"username": "alice"})
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	// A failed delivery looks the same as an unknown account
+	h.SetResetTokenNotifier(func(ctx context.Context, user *types.User, token string) error {
+		return errors.New("mail server unavailable")
+	})
+	rec = doJSON(t, handler, "POST", "/api/v1/auth/forgot", map[string]any{"username": "alice"})
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+}
+
+func TestWebhookResetNotifier(t *testing.T) {
+	notices := make(chan map[string]any, 1)
+	status := http.StatusNoContent
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "POST", r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		var notice map[string]any
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&notice))
+		notices <- notice
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(webhook.Close)
+
+	notify := api.WebhookResetNotifier(webhook.URL, time.Second)
+	user := &types.User{ID: "u1", Username: "alice", Email: "alice@example.com"}
+
+	require.NoError(t, notify(context.Background(), user, "secret-token"))
+	notice := <-notices
+	assert.Equal(t, "u1", notice["user_id"])
+	assert.Equal(t, "alice", notice["username"])
+	assert.Equal(t, "alice@example.com", notice["email"])
+	assert.Equal(t, "secret-token", notice["token"])
+	assert.NotEmpty(t, notice["expires_at"])
+
+	// Anything but 2xx fails the delivery
+	status = http.StatusBadGateway
+	assert.Error(t, notify(context.Background(), user, "secret-token"))
+	<-notices
+}
+
+func TestRouteStats(t *testing.T) {
+	handler, store := newTestAPI(t)
+	ctx := context.Background()
+
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+	}))
+	t.Cleanup(backend.Close)
+
+	require.NoError(t, store.CreateService(ctx, &types.Service{
+		ID:        "slow-svc",
+		Name:      "slow",
+		Endpoints: []string{backend.URL},
+		Timeout:   50 * time.Millisecond,
+		Active:    true,
+	}))
+	require.NoError(t, store.CreateRoute(ctx, &types.Route{
+		ID:         "slow-route",
+		ServiceID:  "slow-svc",
+		PathPrefix: "/slow",
+	}))
+
+	rt := router.NewRouter(store, &testLogger{})
+
+	reverseProxy := proxy.New(proxy.Options{
+		LoadBalancer: balancer.NewRoundRobin(),
+		Router:       rt,
+		Logger:       &testLogger{},
+		Storage:      store,
+	})
+
+	rec := httptest.NewRecorder()
+	reverseProxy.ServeHTTP(rec, httptest.NewRequest("GET", "/slow/resource", nil))
+	require.Equal(t, http.StatusGatewayTimeout, rec.Code)
+
+	rec = doJSON(t, handler, "GET", "/api/v1/routes/slow-route/stats", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var stats api.RouteStats
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &stats))
+	assert.Equal(t, "slow-svc", stats.ServiceID)
+	assert.Equal(t, "50ms", stats.Timeout)
+	assert.Equal(t, int64(1), stats.Requests)
+	assert.Equal(t, int64(1), stats.Errors)
+	assert.Equal(t, int64(1), stats.Timeouts)
+	assert.False(t, stats.Retry.Enabled)
+
+	rec = doJSON(t, handler, "GET", "/api/v1/routes/missing/stats", nil)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestRouteGroups(t *testing.T) {
+	handler, store := newTestAPI(t)
+	ctx := context.Background()
+
+	require.NoError(t, store.CreateService(ctx, &types.Service{
+		ID:        "svc",
+		Name:      "svc",
+		Endpoints: []string{"http://localhost:9000"},
+		Active:    true,
+	}))
+
+	for _, route := range []api.RouteRequest{
+		{ID: "a", Group: "team-a", PathPrefix: "/a", ServiceID: "svc"},
+		{ID: "a2", Group: "team-a", PathPrefix: "/a2", ServiceID: "svc"},
+		{ID: "b", Group: "team-b", PathPrefix: "/b", ServiceID: "svc"},
+		{ID: "c", PathPrefix: "/c", ServiceID: "svc"},
+	} {
+		rec := doJSON(t, handler, "POST", "/api/v1/routes", route)
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	}
+
+	listIDs := func(path string) []string {
+		rec := doJSON(t, handler, "GET", path, nil)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var routes []api.RouteResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &routes))
+		ids := make([]string, 0, len(routes))
+		for _, route := range routes {
+			ids = append(ids, route.ID)
+		}
+		return ids
+	}
+
+	assert.ElementsMatch(t, []string{"a", "a2"}, listIDs("/api/v1/routes?group=team-a"))
+	assert.ElementsMatch(t, []string{"c"}, listIDs("/api/v1/routes?group="))
+	assert.ElementsMatch(t, []string{"a", "a2", "b", "c"}, listIDs("/api/v1/routes"))
+
+	rec := doJSON(t, handler, "DELETE", "/api/v1/route-groups/team-a", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var result api.RouteGroupDeleteResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+	assert.Equal(t, "team-a", result.Group)
+	assert.Equal(t, 2, result.Deleted)
+
+	assert.ElementsMatch(t, []string{"b", "c"}, listIDs("/api/v1/routes"))
+
+	rec = doJSON(t, handler, "DELETE", "/api/v1/route-groups/team-a", nil)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestRouteConflicts(t *testing.T) {
+	handler, store := newTestAPI(t)
+	ctx := context.Background()
+
+	require.NoError(t, store.CreateService(ctx, &types.Service{
+		ID:        "svc",
+		Name:      "svc",
+		Endpoints: []string{"http://localhost:9000"},
+		Active:    true,
+	}))
+
+	rec := doJSON(t, handler, "POST", "/api/v1/routes", api.RouteRequest{
+		ID: "a", Host: "example.com", PathPrefix: "/api", Headers: map[string]string{"X-Tenant": "acme"}, ServiceID: "svc",
+	})
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	t.Run("identical criteria are rejected", func(t *testing.T) {
+		// Host and header names differ only in case; priority defaults to the same value
+		rec := doJSON(t, handler, "POST", "/api/v1/routes", api.RouteRequest{
+			ID: "b", Host: "EXAMPLE.com", PathPrefix: "/api", Headers: map[string]string{"x-tenant": "acme"}, ServiceID: "svc",
+		})
+		require.Equal(t, http.StatusConflict, rec.Code, rec.Body.String())
+
+		resp := decodeError(t, rec)
+		assert.Equal(t, "route_conflict", resp.Code)
+		assert.Contains(t, resp.Error, "a")
+
+		_, err := store.GetRoute(ctx, "b")
+		assert.ErrorIs(t, err, types.ErrRouteNotFound)
+	})
+
+	t.Run("different priority or criteria are allowed", func(t *testing.T) {
+		for _, route := range []api.RouteRequest{
+			{ID: "c", Priority: 10, Host: "example.com", PathPrefix: "/api", Headers: map[string]string{"X-Tenant": "acme"}, ServiceID: "svc"},
+			{ID: "d", Host: "example.com", PathPrefix: "/api", Headers: map[string]string{"X-Tenant": "other"}, ServiceID: "svc"},
+			{ID: "e", Host: "example.com", PathPrefix: "/api", ServiceID: "svc"},
+		} {
+			rec := doJSON(t, handler, "POST", "/api/v1/routes", route)
+			assert.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		}
+	})
+
+	t.Run("updates into a conflict are rejected", func(t *testing.T) {
+		rec := doJSON(t, handler, "PUT", "/api/v1/routes/c", api.RouteRequest{
+			Priority: 1000, Host: "example.com", PathPrefix: "/api", Headers: map[string]string{"X-Tenant": "acme"}, ServiceID: "svc", Version: 1,
+		})
+		assert.Equal(t, http.StatusConflict, rec.Code, rec.Body.String())
+
+		rec = doJSON(t, handler, "PATCH", "/api/v1/routes/c", map[string]any{"priority": 1000})
+		assert.Equal(t, http.StatusConflict, rec.Code, rec.Body.String())
+
+		// A route never conflicts with its own stored version
+		rec = doJSON(t, handler, "PATCH", "/api/v1/routes/a", map[string]any{"group": "team-a"})
+		assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	})
+
+	t.Run("report lists existing conflicts", func(t *testing.T) {
+		// Routes loaded from config bypass the API checks
+		for _, id := range []string{"x", "y"} {
+			require.NoError(t, store.CreateRoute(ctx, &types.Route{
+				ID: id, Priority: 1000, PathPrefix: "/legacy", ServiceID: "svc",
+			}))
+		}
+
+		rec := doJSON(t, handler, "GET", "/api/v1/routes:conflicts", nil)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		var conflicts []api.RouteConflict
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &conflicts))
+		require.Len(t, conflicts, 1)
+		assert.Equal(t, []string{"x", "y"}, conflicts[0].RouteIDs)
+		assert.Equal(t, 1000, conflicts[0].Priority)
+		assert.Equal(t, "/legacy", conflicts[0].PathPrefix)
+	})
+}
+
+func TestAdminRuntime(t *testing.T) {
+	store := storage.NewMemory()
+	t.Cleanup(func() { store.Close() })
+	ctx := context.Background()
+
+	received := make(chan struct{})
+	release := make(chan struct{})
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(received)
+		<-release
+	}))
+	t.Cleanup(backend.Close)
+
+	require.NoError(t, store.CreateService(ctx, &types.Service{
+		ID:        "svc",
+		Name:      "svc",
+		Endpoints: []string{backend.URL},
+		Active:    true,
+	}))
+	require.NoError(t, store.CreateRoute(ctx, &types.Route{
+		ID:         "route",
+		ServiceID:  "svc",
+		PathPrefix: "/",
+	}))
+
+	reverseProxy := proxy.New(proxy.Options{
+		LoadBalancer:   balancer.NewRoundRobin(),
+		HealthChecker:  circuit.NewHealthChecker(time.Minute, time.Second, 3, 2, 0, &testLogger{}),
+		CircuitBreaker: circuit.NewCircuitBreaker(5, 2, time.Minute),
+		Router:         router.NewRouter(store, &testLogger{}),
+		Logger:         &testLogger{},
+		Storage:        store,
+	})
+
+	apiHandler := api.New(store, &testLogger{}, &types.ProxyConfig{})
+	handler := apiHandler.Router()
+
+	t.Run("unavailable without an inspector", func(t *testing.T) {
+		rec := doJSON(t, handler, "GET", "/api/v1/admin/runtime", nil)
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	})
+
+	apiHandler.SetRuntimeInspector(reverseProxy)
+
+	getRuntime := func() api.RuntimeInfo {
+		rec := doJSON(t, handler, "GET", "/api/v1/admin/runtime", nil)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var info api.RuntimeInfo
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &info))
+		require.Len(t, info.Backends, 1)
+		return info
+	}
+
+	// Hold a request open at the backend
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		reverseProxy.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/slow", nil))
+	}()
+	<-received
+
+	info := getRuntime()
+	assert.Positive(t, info.Goroutines)
+	assert.Equal(t, 1, info.Routes)
+	assert.Equal(t, map[string]string{"global": "closed"}, info.CircuitBreakers)
+	assert.Equal(t, "svc-0", info.Backends[0].ID)
+	assert.Equal(t, "svc", info.Backends[0].ServiceID)
+	assert.Equal(t, int64(1), info.Backends[0].ActiveConns)
+
+	close(release)
+	<-done
+
+	info = getRuntime()
+	assert.Equal(t, int64(0), info.Backends[0].ActiveConns)
+	assert.True(t, info.Backends[0].Healthy)
+	assert.False(t, info.Backends[0].Ejected)
+	assert.Equal(t, true, info.Backends[0].Health["tracked"])
+}
+
+// unreachableStorage fails every route listing, like a lost database connection
+type unreachableStorage struct {
+	types.Storage
+}
+
+func (s *unreachableStorage) ListRoutes(ctx context.Context) ([]*types.Route, error) {
+	return nil, types.ErrStorageError
+}
+
+func TestHealthProbes(t *testing.T) {
+	handler, store := newTestAPI(t)
+
+	readiness := func(t *testing.T, handler http.Handler) (int, map[string]any) {
+		rec := doJSON(t, handler, "GET", "/readyz", nil)
+
+		var body map[string]any
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		return rec.Code, body
+	}
+
+	t.Run("live regardless of readiness", func(t *testing.T) {
+		rec := doJSON(t, handler, "GET", "/livez", nil)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"alive"`)
+	})
+
+	t.Run("not ready without routes", func(t *testing.T) {
+		code, body := readiness(t, handler)
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.Equal(t, "not_ready", body["status"])
+
+		checks := body["checks"].(map[string]any)
+		assert.Equal(t, "ok", checks["storage"].(map[string]any)["status"])
+		assert.Equal(t, "none loaded", checks["routes"].(map[string]any)["status"])
+	})
+
+	t.Run("ready once a route is loaded", func(t *testing.T) {
+		require.NoError(t, store.CreateService(context.Background(), &types.Service{
+			ID:        "svc",
+			Name:      "svc",
+			Endpoints: []string{"http://127.0.0.1:9000"},
+			Active:    true,
+		}))
+		require.NoError(t, store.CreateRoute(context.Background(), &types.Route{
+			ID:         "route",
+			ServiceID:  "svc",
+			PathPrefix: "/",
+		}))
+
+		code, body := readiness(t, handler)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "ready", body["status"])
+	})
+
+	t.Run("not ready when storage is unreachable", func(t *testing.T) {
+		broken := api.New(&unreachableStorage{Storage: store}, &testLogger{}, &types.ProxyConfig{}).Router()
+
+		code, body := readiness(t, broken)
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+
+		checks := body["checks"].(map[string]any)
+		assert.Equal(t, "unreachable", checks["storage"].(map[string]any)["status"])
+		assert.NotContains(t, checks["storage"], "error", "storage errors aren't exposed publicly")
+
+		rec := doJSON(t, broken, "GET", "/livez", nil)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+}
+
+func TestVersionEndpoint(t *testing.T) {
+	store := storage.NewMemory()
+	t.Cleanup(func() { store.Close() })
+
+	cfg := &types.ProxyConfig{}
+	cfg.API.Auth = true
+	cfg.API.APIKey = "secret"
+	handler := api.New(store, &testLogger{}, cfg).Router()
+
+	rec := doJSON(t, handler, "GET", "/api/v1/version", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+	var body map[string]any
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	for _, field := range []string{"version", "git_commit", "build_time", "go_version", "platform", "start_time", "uptime"} {
+		assert.Contains(t, body, field)
+	}
+
+	var info version.Info
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &info))
+	expected := version.GetInfo()
+	assert.Equal(t, expected.Version, info.Version)
+	assert.Equal(t, expected.GitCommit, info.GitCommit)
+	assert.Equal(t, expected.BuildTime, info.BuildTime)
+	assert.Equal(t, expected.GoVersion, info.GoVersion)
+	assert.Equal(t, expected.Platform, info.Platform)
+	assert.True(t, expected.StartTime.Equal(info.StartTime))
+	assert.NotEmpty(t, info.Uptime)
+
+	// Protected endpoints still need credentials
+	rec = doJSON(t, handler, "GET", "/api/v1/services", nil)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestAdminDrain(t *testing.T) {
+	handler, store := newTestAPI(t)
+	ctx := context.Background()
+
+	t.Run("unavailable without a drainer", func(t *testing.T) {
+		rec := doJSON(t, handler, "POST", "/api/v1/admin/drain", nil)
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	})
+
+	require.NoError(t, store.CreateService(ctx, &types.Service{
+		ID:        "svc",
+		Name:      "svc",
+		Endpoints: []string{"http://127.0.0.1:9000"},
+		Active:    true,
+	}))
+	require.NoError(t, store.CreateRoute(ctx, &types.Route{
+		ID:         "route",
+		ServiceID:  "svc",
+		PathPrefix: "/",
+	}))
+
+	// A proxy listener whose requests are held open until released
+	received := make(chan struct{}, 2)
+	release := make(chan struct{})
+	proxyServer := httptest.NewUnstartedServer(nil)
+	drainer := server.NewDrainer(proxyServer.Config)
+	proxyServer.Config.Handler = drainer.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- struct{}{}
+		<-release
+		w.Write([]byte("finished"))
+	}))
+	proxyServer.Start()
+	t.Cleanup(proxyServer.Close)
+
+	apiHandler := api.New(store, &testLogger{}, &types.ProxyConfig{})
+	apiHandler.SetDrainer(drainer)
+	handler = apiHandler.Router()
+
+	t.Run("rejects invalid settings", func(t *testing.T) {
+		rec := doJSON(t, handler, "POST", "/api/v1/admin/drain", map[string]any{"timeout": "soon", "threshold": -1})
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+		assert.ElementsMatch(t, []string{"timeout", "threshold"}, fieldsOf(decodeError(t, rec).Details))
+		assert.False(t, drainer.Draining())
+	})
+
+	rec := doJSON(t, handler, "GET", "/readyz", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	// Hold a request in flight
+	type result struct {
+		resp *http.Response
+		body string
+		err  error
+	}
+	inFlight := make(chan result, 1)
+	go func() {
+		resp, err := http.Get(proxyServer.URL)
+		if err != nil {
+			inFlight <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		inFlight <- result{resp: resp, body: string(body), err: err}
+	}()
+	<-received
+
+	drained := make(chan *httptest.ResponseRecorder, 1)
+	go func() {
+		drained <- doJSON(t, handler, "POST", "/api/v1/admin/drain", map[string]any{"timeout": "5s"})
+	}()
+
+	// Readiness fails while the request is still in flight
+	assert.Eventually(t, func() bool {
+		return doJSON(t, handler, "GET", "/readyz", nil).Code == http.StatusServiceUnavailable
+	}, 2*time.Second, 10*time.Millisecond)
+
+	var body map[string]any
+	require.NoError(t, json.Unmarshal(doJSON(t, handler, "GET", "/readyz", nil).Body.Bytes(), &body))
+	assert.Equal(t, "draining", body["checks"].(map[string]any)["drain"].(map[string]any)["status"])
+
+	select {
+	case <-drained:
+		t.Fatal("drain returned with a request still in flight")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+
+	// The in-flight request completes, and the client is told to reconnect
+	res := <-inFlight
+	require.NoError(t, res.err)
+	assert.Equal(t, http.StatusOK, res.resp.StatusCode)
+	assert.Equal(t, "finished", res.body)
+	assert.True(t, res.resp.Close, "response should carry Connection: close")
+
+	rec = <-drained
+	require.Equal(t, http.StatusOK, rec.Code)
+	var response api.DrainResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, "drained", response.Status)
+	assert.Equal(t, 0, response.InFlight)
+
+	t.Run("reports requests still in flight at the timeout", func(t *testing.T) {
+		hold := make(chan struct{})
+		stuck := httptest.NewUnstartedServer(nil)
+		slow := server.NewDrainer(stuck.Config)
+		stuck.Config.Handler = slow.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			received <- struct{}{}
+			<-hold
+		}))
+		stuck.Start()
+		defer stuck.Close()
+		defer close(hold)
+
+		go http.Get(stuck.URL)
+		<-received
+
+		slowAPI := api.New(store, &testLogger{}, &types.ProxyConfig{})
+		slowAPI.SetDrainer(slow)
+
+		rec := doJSON(t, slowAPI.Router(), "POST", "/api/v1/admin/drain", map[string]any{"timeout": "50ms"})
+		require.Equal(t, http.StatusOK, rec.Code)
+		var response api.DrainResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		assert.Equal(t, "timeout", response.Status)
+		assert.Equal(t, 1, response.InFlight)
+	})
+}
+
+func TestRevokeUserAPIKeys(t *testing.T) {
+	store := storage.NewMemory()
+	t.Cleanup(func() { store.Close() })
+	ctx := context.Background()
+
+	cfg := &types.ProxyConfig{}
+	cfg.API.Auth = true
+	handler := api.New(store, &testLogger{}, cfg).Router()
+
+	for _, user := range []*types.User{
+		{ID: "admin", Username: "admin", Email: "admin@example.com", IsAdmin: true, Active: true},
+		{ID: "alice", Username: "alice", Email: "alice@example.com", Active: true},
+		{ID: "bob", Username: "bob", Email: "bob@example.com", Active: true},
+	} {
+		require.NoError(t, store.CreateUser(ctx, user))
+		require.NoError(t, store.CreateAPIKey(ctx, &types.APIKey{
+			Key:      user.ID + "-session",
+			UserID:   user.ID,
+			Name:     "session",
+			Active:   true,
+			Metadata: map[string]string{"type": "session"},
+		}))
+	}
+
+	as := func(key, method, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("X-API-Key", key)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Alice holds several keys besides her session
+	for i := 0; i < 3; i++ {
+		require.NoError(t, store.CreateAPIKey(ctx, &types.APIKey{
+			Key:    fmt.Sprintf("alice-key-%d", i),
+			UserID: "alice",
+			Name:   fmt.Sprintf("key %d", i),
+			Active: true,
+		}))
+	}
+
+	t.Run("other users are forbidden", func(t *testing.T) {
+		rec := as("bob-session", "DELETE", "/api/v1/users/alice/api-keys")
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+
+		keys, err := store.ListAPIKeysByUser(ctx, "alice")
+		require.NoError(t, err)
+		for _, key := range keys {
+			assert.True(t, key.Active)
+		}
+	})
+
+	t.Run("admin revokes every key including sessions", func(t *testing.T) {
+		rec := as("admin-session", "DELETE", "/api/v1/users/alice/api-keys")
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var resp map[string]int
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		assert.Equal(t, 4, resp["revoked"])
+
+		keys, err := store.ListAPIKeysByUser(ctx, "alice")
+		require.NoError(t, err)
+		assert.Len(t, keys, 4)
+		for _, key := range keys {
+			assert.False(t, key.Active, key.Key)
+		}
+
+		// The revoked session no longer authenticates
+		rec = as("alice-session", "GET", "/api/v1/auth/whoami")
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+
+	t.Run("users may revoke their own keys", func(t *testing.T) {
+		rec := as("bob-session", "DELETE", "/api/v1/users/bob/api-keys")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"revoked": 1}`, rec.Body.String())
+
+		rec = as("admin-session", "DELETE", "/api/v1/users/nobody/api-keys")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
+
+// resetLogger records the fields of forced password reset log entries
+type resetLogger struct {
+	testLogger
+	mu      sync.Mutex
+	entries [][]any
+}
+
+func (l *resetLogger) Info(msg string, fields ...any) {
+	if msg != "Password reset forced" {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, fields)
+}
+
+func TestForcePasswordReset(t *testing.T) {
+	store := storage.NewMemory()
+	t.Cleanup(func() { store.Close() })
+	ctx := context.Background()
+
+	cfg := &types.ProxyConfig{}
+	cfg.API.Auth = true
+	logger := &resetLogger{}
+	handler := api.New(store, logger, cfg).Router()
+
+	for _, user := range []*types.User{
+		{ID: "admin", Username: "admin", Email: "admin@example.com", IsAdmin: true, Active: true},
+		{ID: "alice", Username: "alice", Email: "alice@example.com", Active: true},
+	} {
+		require.NoError(t, store.CreateUser(ctx, user))
+		require.NoError(t, store.CreateAPIKey(ctx, &types.APIKey{
+			Key:      user.ID + "-session",
+			UserID:   user.ID,
+			Name:     "session",
+			Active:   true,
+			Metadata: map[string]string{"type": "session"},
+		}))
+	}
+	require.NoError(t, store.CreateAPIKey(ctx, &types.APIKey{
+		Key:    "alice-ci",
+		UserID: "alice",
+		Name:   "ci",
+		Active: true,
+	}))
+
+	as := func(key, method, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("X-API-Key", key)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("users can't force a reset", func(t *testing.T) {
+		rec := as("alice-session", "POST", "/api/v1/users/admin/force-password-reset")
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+
+		admin, err := store.GetUser(ctx, "admin")
+		require.NoError(t, err)
+		assert.False(t, admin.MustChangePassword)
+	})
+
+	t.Run("admin forces a reset", func(t *testing.T) {
+		rec := as("admin-session", "POST", "/api/v1/users/alice/force-password-reset")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"must_change_password": true, "revoked": 2}`, rec.Body.String())
+
+		alice, err := store.GetUser(ctx, "alice")
+		require.NoError(t, err)
+		assert.True(t, alice.MustChangePassword)
+
+		// Her session and keys no longer authenticate
+		assert.Equal(t, http.StatusUnauthorized, as("alice-session", "GET", "/api/v1/auth/whoami").Code)
+		assert.Equal(t, http.StatusUnauthorized, as("alice-ci", "GET", "/api/v1/auth/whoami").Code)
+
+		// Other users keep theirs
+		assert.Equal(t, http.StatusOK, as("admin-session", "GET", "/api/v1/auth/whoami").Code)
+
+		logger.mu.Lock()
+		defer logger.mu.Unlock()
+		require.Len(t, logger.entries, 1)
+		assert.Equal(t, []any{"user_id", "alice", "by", "admin", "revoked", 2}, logger.entries[0])
+	})
+
+	t.Run("logins are held until the password changes", func(t *testing.T) {
+		alice, err := store.GetUser(ctx, "alice")
+		require.NoError(t, err)
+		alice.PasswordHash, err = config.HashPassword("old-password")
+		require.NoError(t, err)
+		require.NoError(t, store.UpdateUser(ctx, alice))
+
+		body := strings.NewReader(`{"username": "alice", "password": "old-password"}`)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("POST", "/api/v1/auth/login", body))
+		require.Equal(t, http.StatusOK, rec.Code)
+		var login types.AuthResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &login))
+		assert.True(t, login.User.MustChangePassword)
+
+		session, err := store.GetAPIKey(ctx, login.APIKey)
+		require.NoError(t, err)
+		assert.Equal(t, "password_change", session.Metadata["type"])
+		assert.WithinDuration(t, time.Now().Add(15*time.Minute), *session.ExpiresAt, time.Minute)
+
+		rec = as(login.APIKey, "GET", "/api/v1/services")
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.Contains(t, rec.Body.String(), "Password change required")
+		assert.Equal(t, http.StatusOK, as(login.APIKey, "GET", "/api/v1/auth/whoami").Code)
+
+		req := httptest.NewRequest("POST", "/api/v1/users/alice/password",
+			strings.NewReader(`{"old_password": "old-password", "new_password": "new-password"}`))
+		req.Header.Set("X-API-Key", login.APIKey)
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		assert.Equal(t, http.StatusOK, as(login.APIKey, "GET", "/api/v1/services").Code)
+	})
+
+	t.Run("unknown user", func(t *testing.T) {
+		rec := as("admin-session", "POST", "/api/v1/users/nobody/force-password-reset")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
+
+func TestListAPIKeys(t *testing.T) {
+	store := storage.NewMemory()
+	t.Cleanup(func() { store.Close() })
+	ctx := context.Background()
+
+	cfg := &types.ProxyConfig{}
+	cfg.API.Auth = true
+	handler := api.New(store, &testLogger{}, cfg).Router()
+
+	// Every user holds a short session key and two generated-length keys
+	raw := make(map[string]string) // masked -> raw
+	for _, user := range []*types.User{
+		{ID: "admin", Username: "admin", Email: "admin@example.com", IsAdmin: true, Active: true},
+		{ID: "alice", Username: "alice", Email: "alice@example.com", Active: true},
+		{ID: "bob", Username: "bob", Email: "bob@example.com", Active: true},
+	} {
+		require.NoError(t, store.CreateUser(ctx, user))
+		require.NoError(t, store.CreateAPIKey(ctx, &types.APIKey{
+			Key: user.ID + "-session", UserID: user.ID, Name: "session", Active: true,
+		}))
+		for i := 0; i < 2; i++ {
+			key := fmt.Sprintf("%s-%d-0123456789abcdefghijklmnopqrstuvwxyz", user.ID, i)
+			require.NoError(t, store.CreateAPIKey(ctx, &types.APIKey{
+				Key: key, UserID: user.ID, Name: fmt.Sprintf("key %d", i), Active: true,
+			}))
+			raw[key[:4]+"..."+key[len(key)-4:]] = key
+		}
+	}
+
+	list := func(key, query string) (*httptest.ResponseRecorder, api.APIKeyListResponse) {
+		req := httptest.NewRequest("GET", "/api/v1/api-keys"+query, nil)
+		req.Header.Set("X-API-Key", key)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		var resp api.APIKeyListResponse
+		if rec.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		}
+		return rec, resp
+	}
+
+	t.Run("admins see every user's keys masked", func(t *testing.T) {
+		rec, resp := list("admin-session", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, 9, resp.Total)
+		assert.Equal(t, 100, resp.Limit)
+		assert.Len(t, resp.APIKeys, 9)
+
+		perUser := make(map[string]int)
+		for _, key := range resp.APIKeys {
+			perUser[key.UserID]++
+			if key.Name == "session" {
+				assert.Equal(t, "****", key.Key)
+			} else {
+				assert.Contains(t, raw, key.Key)
+			}
+		}
+		assert.Equal(t, map[string]int{"admin": 3, "alice": 3, "bob": 3}, perUser)
+
+		// Raw key values never appear in the response
+		for _, key := range raw {
+			assert.NotContains(t, rec.Body.String(), key)
+		}
+		assert.NotContains(t, rec.Body.String(), "alice-session")
+	})
+
+	t.Run("pages cover every key once", func(t *testing.T) {
+		seen := make(map[string]bool)
+		for offset := 0; offset < 9; offset += 4 {
+			rec, resp := list("admin-session", fmt.Sprintf("?limit=4&offset=%d", offset))
+			require.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, 9, resp.Total)
+			assert.Equal(t, offset, resp.Offset)
+			assert.Len(t, resp.APIKeys, min(4, 9-offset))
+			for _, key := range resp.APIKeys {
+				id := key.UserID + "/" + key.Name
+				assert.False(t, seen[id], id)
+				seen[id] = true
+			}
+		}
+		assert.Len(t, seen, 9)
+
+		for _, offset := range []string{"20", "9223372036854775807"} {
+			rec, resp := list("admin-session", "?offset="+offset)
+			require.Equal(t, http.StatusOK, rec.Code, offset)
+			assert.Empty(t, resp.APIKeys)
+			assert.Equal(t, 9, resp.Total)
+		}
+	})
+
+	t.Run("invalid pagination is rejected", func(t *testing.T) {
+		for _, query := range []string{"?limit=0", "?limit=abc", "?limit=5000", "?offset=-1"} {
+			rec, _ := list("admin-session", query)
+			assert.Equal(t, http.StatusBadRequest, rec.Code, query)
+		}
+	})
+
+	t.Run("non-admins are forbidden", func(t *testing.T) {
+		rec, _ := list("alice-session", "")
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+
+	t.Run("keys can be revoked by their listed id", func(t *testing.T) {
+		_, resp := list("admin-session", "")
+		var id string
+		for _, key := range resp.APIKeys {
+			if key.UserID == "bob" && key.Name == "session" {
+				id = key.ID
+			}
+		}
+		require.NotEmpty(t, id)
+
+		req := httptest.NewRequest("DELETE", "/api/v1/api-keys/"+id, nil)
+		req.Header.Set("X-API-Key", "admin-session")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+
+		key, err := store.GetAPIKey(ctx, "bob-session")
+		require.NoError(t, err)
+		assert.False(t, key.Active)
+	})
+}
+
+// staticLoader returns a fixed configuration on every load
+type staticLoader struct {
+	cfg *types.ProxyConfig
+}
+
+func (l *staticLoader) LoadConfig() (*types.ProxyConfig, error) {
+	copied := *l.cfg
+	return &copied, nil
+}
+
+func TestReloadConfigDiff(t *testing.T) {
+	store := storage.NewMemory()
+	t.Cleanup(func() { store.Close() })
+	ctx := context.Background()
+
+	require.NoError(t, store.CreateService(ctx, &types.Service{
+		ID:        "svc",
+		Name:      "svc",
+		Endpoints: []string{"http://10.0.0.1:8080"},
+		Active:    true,
+	}))
+
+	running := &types.ProxyConfig{
+		ListenAddr:   ":8080",
+		ReadTimeout:  30 * time.Second,
+		WriteTimeout: 30 * time.Second,
+	}
+	running.LoadBalancing.Algorithm = "round_robin"
+	running.RateLimit.Enabled = true
+	running.RateLimit.RPS = 1000
+	running.RateLimit.Burst = 5000
+	running.API.APIKey = "old-key"
+
+	next := *running
+	next.RateLimit.RPS = 2000
+	next.WriteTimeout = time.Minute
+	next.API.APIKey = "new-key"
+	next.Middleware.Headers.Custom = map[string]string{"X-Env": "production"}
+
+	apiHandler := api.New(store, &testLogger{}, running)
+	apiHandler.SetConfigLoader(&staticLoader{cfg: &next})
+	apiHandler.SetReloadCallback(func(newConfig *types.ProxyConfig) error {
+		// Like the server, overwrite the running config in place
+		*running = *newConfig
+
+		service, err := store.GetService(ctx, "svc")
+		if err != nil {
+			return err
+		}
+		service.Endpoints = []string{"http://10.0.0.2:8080"}
+		return store.UpdateService(ctx, service)
+	})
+
+	rec := doJSON(t, apiHandler.Router(), "POST", "/api/v1/admin/reload", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var response struct {
+		Diff api.ConfigDiff `json:"diff"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+
+	changed := make(map[string]api.ConfigChange)
+	for _, change := range response.Diff.Changed {
+		changed[change.Path] = change
+	}
+
+	assert.Equal(t, api.ConfigChange{Path: "rate_limit.rps", Old: float64(1000), New: float64(2000)}, changed["rate_limit.rps"])
+	assert.Equal(t, api.ConfigChange{Path: "write_timeout", Old: "30s", New: "1m0s"}, changed["write_timeout"])
+	assert.Equal(t, api.ConfigChange{Path: "api.api_key", Old: "<redacted>", New: "<redacted>"}, changed["api.api_key"])
+	assert.Equal(t, []any{"http://10.0.0.2:8080"}, changed["services.svc.endpoints"].New)
+	assert.NotContains(t, changed, "read_timeout")
+	assert.NotContains(t, changed, "services.svc.version")
+
+	assert.Equal(t, []api.ConfigChange{{Path: "middleware.headers.custom.X-Env", New: "production"}}, response.Diff.Added)
+	assert.Empty(t, response.Diff.Removed)
+}
+
+func TestAdminAuth(t *testing.T) {
+	store := storage.NewMemory()
+	t.Cleanup(func() { store.Close() })
+	ctx := context.Background()
+
+	require.NoError(t, store.CreateUser(ctx, &types.User{ID: "admin", Username: "admin", Email: "admin@example.com", IsAdmin: true, Active: true}))
+	require.NoError(t, store.CreateAPIKey(ctx, &types.APIKey{Key: "admin-session", UserID: "admin", Name: "session", Active: true}))
+
+	do := func(handler http.Handler, path string, prepare func(*http.Request)) int {
+		req := httptest.NewRequest("GET", path, nil)
+		prepare(req)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	withKey := func(key string) func(*http.Request) {
+		return func(r *http.Request) { r.Header.Set("X-API-Key", key) }
+	}
+
+	t.Run("general keys open admin routes without admin auth", func(t *testing.T) {
+		cfg := &types.ProxyConfig{}
+		cfg.API.Auth = true
+		handler := api.New(store, &testLogger{}, cfg).Router()
+
+		assert.Equal(t, http.StatusOK, do(handler, "/api/v1/admin/config", withKey("admin-session")))
+	})
+
+	t.Run("admin key replaces general keys on admin routes", func(t *testing.T) {
+		cfg := &types.ProxyConfig{}
+		cfg.API.Auth = true
+		cfg.API.AdminAuth.Type = "api_key"
+		cfg.API.AdminAuth.Token = "admin-secret"
+		handler := api.New(store, &testLogger{}, cfg).Router()
+
+		assert.Equal(t, http.StatusUnauthorized, do(handler, "/api/v1/admin/config", withKey("admin-session")))
+		assert.Equal(t, http.StatusOK, do(handler, "/api/v1/admin/config", withKey("admin-secret")))
+
+		// The general API still takes general keys only
+		assert.Equal(t, http.StatusOK, do(handler, "/api/v1/services", withKey("admin-session")))
+		assert.Equal(t, http.StatusUnauthorized, do(handler, "/api/v1/services", withKey("admin-secret")))
+	})
+
+	t.Run("admin auth applies with general auth off", func(t *testing.T) {
+		cfg := &types.ProxyConfig{}
+		cfg.API.AdminAuth.Type = "basic"
+		cfg.API.AdminAuth.Username = "ops"
+		cfg.API.AdminAuth.Password = "hunter2"
+		handler := api.New(store, &testLogger{}, cfg).Router()
+
+		assert.Equal(t, http.StatusUnauthorized, do(handler, "/api/v1/admin/config", func(*http.Request) {}))
+		assert.Equal(t, http.StatusUnauthorized, do(handler, "/api/v1/admin/config", func(r *http.Request) { r.SetBasicAuth("ops", "wrong") }))
+		assert.Equal(t, http.StatusOK, do(handler, "/api/v1/admin/config", func(r *http.Request) { r.SetBasicAuth("ops", "hunter2") }))
+		assert.Equal(t, http.StatusOK, do(handler, "/api/v1/services", func(*http.Request) {}))
+	})
+}
+
+func TestValidateConfig(t *testing.T) {
+	store := storage.NewMemory()
+	t.Cleanup(func() { store.Close() })
+
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "discobox.yml")
+	require.NoError(t, os.WriteFile(configFile, []byte("listen_addr: \":9090\"\n"), 0644))
+
+	running := &types.ProxyConfig{}
+	apiHandler := api.New(store, &testLogger{}, running)
+	apiHandler.SetConfigLoader(config.NewLoader(configFile, &testLogger{}))
+
+	applied := false
+	apiHandler.SetReloadCallback(func(*types.ProxyConfig) error {
+		applied = true
+		return nil
+	})
+
+	validate := func(t *testing.T, contentType, document string) api.ConfigValidation {
+		t.Helper()
+		req := httptest.NewRequest("POST", "/api/v1/admin/config/validate", strings.NewReader(document))
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		rec := httptest.NewRecorder()
+		apiHandler.Router().ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		var result api.ConfigValidation
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+		return result
+	}
+
+	t.Run("valid document", func(t *testing.T) {
+		result := validate(t, "application/yaml", `
+listen_addr: ":8080"
+load_balancing:
+  algorithm: least_conn
+storage:
+  type: sqlite
+  dsn: "file:data/discobox.db?cache=shared"
+`)
+		assert.True(t, result.Valid)
+		assert.Empty(t, result.Errors)
+	})
+
+	t.Run("valid json document", func(t *testing.T) {
+		result := validate(t, "application/json", `{"listen_addr": ":8080", "storage": {"type": "memory"}}`)
+		assert.True(t, result.Valid)
+	})
+
+	t.Run("current file when the body is empty", func(t *testing.T) {
+		result := validate(t, "", "")
+		assert.True(t, result.Valid)
+	})
+
+	tests := []struct {
+		name     string
+		document string
+		fields   []string
+	}{
+		{
+			name:     "unknown balancer algorithm",
+			document: "load_balancing:\n  algorithm: fastest\n",
+			fields:   []string{"load_balancing.algorithm"},
+		},
+		{
+			name: "missing tls files",
+			document: fmt.Sprintf(`
+tls:
+  enabled: true
+  cert_file: %s
+  key_file: %s
+`, filepath.Join(dir, "missing.crt"), filepath.Join(dir, "missing.key")),
+			fields: []string{"tls.cert_file"},
+		},
+		{
+			name:     "unparseable storage dsn",
+			document: "storage:\n  type: sqlite\n  dsn: \"data.db?cache=%zz\"\n",
+			fields:   []string{"storage.dsn"},
+		},
+		{
+			name:     "unsupported storage type",
+			document: "storage:\n  type: redis\n",
+			fields:   []string{"storage.type"},
+		},
+		{
+			name: "several problems at once",
+			document: `
+read_timeout: -1s
+storage:
+  type: sqlite
+  dsn: "?mode=ro"
+`,
+			fields: []string{"storage.dsn", "read_timeout"},
+		},
+		{
+			name:     "malformed document",
+			document: "listen_addr: [",
+			fields:   []string{""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := validate(t, "application/yaml", tt.document)
+			assert.False(t, result.Valid)
+			assert.ElementsMatch(t, tt.fields, fieldsOf(result.Errors))
+			for _, problem := range result.Errors {
+				assert.NotEmpty(t, problem.Message)
+			}
+		})
+	}
+
+	assert.False(t, applied, "validation must not apply the configuration")
+	assert.Empty(t, running.ListenAddr)
+}
+
+// flakyStorage fails API key lookups while down is set
+type flakyStorage struct {
+	types.Storage
+	down atomic.Bool
+}
+
+func (s *flakyStorage) GetAPIKey(ctx context.Context, key string) (*types.APIKey, error) {
+	if s.down.Load() {
+		return nil, types.ErrStorageError
+	}
+	return s.Storage.GetAPIKey(ctx, key)
+}
+
+func TestAuthStorageFailure(t *testing.T) {
+	newAPI := func(t *testing.T, mode string, grace time.Duration) (http.Handler, *flakyStorage) {
+		memory := storage.NewMemory()
+		t.Cleanup(func() { memory.Close() })
+		ctx := context.Background()
+
+		require.NoError(t, memory.CreateUser(ctx, &types.User{ID: "alice", Username: "alice", Email: "alice@example.com", Active: true}))
+		require.NoError(t, memory.CreateAPIKey(ctx, &types.APIKey{Key: "alice-key-1234", UserID: "alice", Name: "ci", Active: true}))
+
+		cfg := &types.ProxyConfig{}
+		cfg.API.Auth = true
+		cfg.API.StorageFailure.Mode = mode
+		cfg.API.StorageFailure.GracePeriod = grace
+
+		store := &flakyStorage{Storage: memory}
+		return api.New(store, &testLogger{}, cfg).Router(), store
+	}
+
+	as := func(handler http.Handler, key, method, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("X-API-Key", key)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("closed rejects requests while storage is down", func(t *testing.T) {
+		handler, store := newAPI(t, "closed", 0)
+		assert.Equal(t, http.StatusOK, as(handler, "alice-key-1234", "GET", "/api/v1/services").Code)
+
+		store.down.Store(true)
+		rec := as(handler, "alice-key-1234", "GET", "/api/v1/services")
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+		assert.Empty(t, rec.Header().Get("X-Auth-Degraded"))
+
+		// Unknown keys are still unauthorized once storage answers
+		store.down.Store(false)
+		assert.Equal(t, http.StatusUnauthorized, as(handler, "unknown-key-1234", "GET", "/api/v1/services").Code)
+	})
+
+	t.Run("open allows recently valid keys without a user", func(t *testing.T) {
+		handler, store := newAPI(t, "open", time.Minute)
+		assert.Equal(t, http.StatusOK, as(handler, "alice-key-1234", "GET", "/api/v1/services").Code)
+		store.down.Store(true)
+
+		rec := as(handler, "alice-key-1234", "GET", "/api/v1/services")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "true", rec.Header().Get("X-Auth-Degraded"))
+
+		// Keys not seen valid before the outage are refused
+		rec = as(handler, "made-up-key-1234", "GET", "/api/v1/services")
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+		assert.Empty(t, rec.Header().Get("X-Auth-Degraded"))
+
+		// Admin endpoints still need a known admin, even one claimed in headers
+		req := httptest.NewRequest("POST", "/api/v1/users/alice/force-password-reset", nil)
+		req.Header.Set("X-API-Key", "alice-key-1234")
+		req.Header.Set("X-User-Admin", "true")
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+
+		// Requests without a key are rejected as before
+		assert.Equal(t, http.StatusUnauthorized, doJSON(t, handler, "GET", "/api/v1/services", nil).Code)
+	})
+
+	t.Run("open closes after the grace period", func(t *testing.T) {
+		handler, store := newAPI(t, "open", 50*time.Millisecond)
+		assert.Equal(t, http.StatusOK, as(handler, "alice-key-1234", "GET", "/api/v1/services").Code)
+		store.down.Store(true)
+
+		assert.Equal(t, http.StatusOK, as(handler, "alice-key-1234", "GET", "/api/v1/services").Code)
+		time.Sleep(60 * time.Millisecond)
+		assert.Equal(t, http.StatusServiceUnavailable, as(handler, "alice-key-1234", "GET", "/api/v1/services").Code)
+
+		// A successful lookup ends the outage, so the next one gets a new grace period
+		store.down.Store(false)
+		rec := as(handler, "alice-key-1234", "GET", "/api/v1/services")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Empty(t, rec.Header().Get("X-Auth-Degraded"))
+
+		store.down.Store(true)
+		assert.Equal(t, http.StatusOK, as(handler, "alice-key-1234", "GET", "/api/v1/services").Code)
+	})
+}
+
+func TestEndpointHealthOverride(t *testing.T) {
+	handler, store := newTestAPI(t)
+	ctx := context.Background()
+
+	t.Run("unavailable without a proxy", func(t *testing.T) {
+		rec := doJSON(t, handler, "POST", "/api/v1/services/svc/endpoints/0/health", map[string]any{"healthy": false})
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	})
+
+	require.NoError(t, store.CreateService(ctx, &types.Service{
+		ID:        "svc",
+		Name:      "svc",
+		Endpoints: []string{"http://one", "http://two"},
+		Active:    true,
+	}))
+	require.NoError(t, store.CreateRoute(ctx, &types.Route{ID: "route", ServiceID: "svc", PathPrefix: "/"}))
+
+	h := proxytest.NewHarness(store, proxy.Options{LoadBalancer: balancer.NewRoundRobin()})
+	t.Cleanup(func() { h.Close() })
+
+	hits := make(map[string]int)
+	h.Backend("http://one", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { hits["one"]++ }))
+	h.Backend("http://two", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { hits["two"]++ }))
+
+	apiHandler := api.New(store, &testLogger{}, &types.ProxyConfig{})
+	apiHandler.SetHealthOverrider(h.Proxy())
+	handler = apiHandler.Router()
+
+	send := func(n int) {
+		for i := 0; i < n; i++ {
+			require.Equal(t, http.StatusOK, h.Do(httptest.NewRequest("GET", "http://example.com/", nil)).Code)
+		}
+	}
+
+	t.Run("unknown endpoint", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, doJSON(t, handler, "POST", "/api/v1/services/svc/endpoints/2/health", map[string]any{"healthy": false}).Code)
+		assert.Equal(t, http.StatusNotFound, doJSON(t, handler, "POST", "/api/v1/services/svc/endpoints/x/health", map[string]any{"healthy": false}).Code)
+		assert.Equal(t, http.StatusNotFound, doJSON(t, handler, "POST", "/api/v1/services/nope/endpoints/0/health", map[string]any{"healthy": false}).Code)
+	})
+
+	t.Run("downed endpoint stops receiving traffic", func(t *testing.T) {
+		rec := doJSON(t, handler, "POST", "/api/v1/services/svc/endpoints/1/health", map[string]any{"healthy": false})
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"id": "svc-1", "service_id": "svc", "endpoint": "http://two", "healthy": false}`, rec.Body.String())
+
+		send(10)
+		assert.Equal(t, 10, hits["one"])
+		assert.Zero(t, hits["two"])
+	})
+
+	t.Run("cleared endpoint resumes", func(t *testing.T) {
+		rec := doJSON(t, handler, "POST", "/api/v1/services/svc/endpoints/1/health", map[string]any{"healthy": nil})
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"id": "svc-1", "service_id": "svc", "endpoint": "http://two", "healthy": null}`, rec.Body.String())
+
+		send(10)
+		assert.Equal(t, 5, hits["two"])
+	})
+
+	t.Run("admin only with auth", func(t *testing.T) {
+		cfg := &types.ProxyConfig{}
+		cfg.API.Auth = true
+		require.NoError(t, store.CreateUser(ctx, &types.User{ID: "bob", Username: "bob", Email: "bob@example.com", Active: true}))
+		require.NoError(t, store.CreateAPIKey(ctx, &types.APIKey{Key: "bob-key-1234", UserID: "bob", Name: "ci", Active: true}))
+
+		authed := api.New(store, &testLogger{}, cfg)
+		authed.SetHealthOverrider(h.Proxy())
+
+		req := httptest.NewRequest("POST", "/api/v1/services/svc/endpoints/0/health", strings.NewReader(`{"healthy": false}`))
+		req.Header.Set("X-API-Key", "bob-key-1234")
+		rec := httptest.NewRecorder()
+		authed.Router().ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+}
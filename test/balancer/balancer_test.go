@@ -236,15 +236,9 @@ func TestWeightedRoundRobinBalancer(t *testing.T) {
 			require.NoError(t, err)
 		}
 		
-		// Update weight of first server
+		// Update weight of first server; no re-add is needed
 		err := lb.UpdateWeight("server-1", 3)
 		require.NoError(t, err)
-		servers[0].Weight = 3
-		
-		// Need to pass updated servers list for rebuild
-		for _, srv := range servers {
-			lb.Add(srv)
-		}
 		
 		// Track selections
 		selections := make(map[string]int)
@@ -256,10 +250,9 @@ func TestWeightedRoundRobinBalancer(t *testing.T) {
 			selections[selected.ID]++
 		}
 		
-		// Verify new distribution - weight updates don't work without rebuild
-		// The implementation would need to rebuild the weighted list
-		assert.Greater(t, selections["server-1"], 0)
-		assert.Greater(t, selections["server-2"], 0)
+		// Verify new distribution: server-1 weight 3, server-2 weight 2
+		assert.Equal(t, 240, selections["server-1"])
+		assert.Equal(t, 160, selections["server-2"])
 	})
 	
 	t.Run("Zero weight servers", func(t *testing.T) {
@@ -286,6 +279,93 @@ func TestWeightedRoundRobinBalancer(t *testing.T) {
 		// Both servers should be selected since zero weight = 1
 		assert.Equal(t, 2, len(selections))
 	})
+	
+	t.Run("Copies of the same pool", func(t *testing.T) {
+		lb := balancer.NewWeightedRoundRobin()
+		req := httptest.NewRequest("GET", "http://example.com/test", nil)
+		
+		// Callers may pass fresh copies on every call; the server returned
+		// must be the caller's copy, with its current connection count
+		selections := make(map[string]int)
+		for i := 0; i < 300; i++ {
+			servers := createServers(2, 1) // server-1 weight 1, server-2 weight 2
+			servers[1].MaxConns = 1
+			servers[1].ActiveConns = int64(i % 2)
+			
+			selected, err := lb.Select(ctx, req, servers)
+			require.NoError(t, err)
+			assert.True(t, selected == servers[0] || selected == servers[1])
+			if selected.ID == "server-2" {
+				assert.Equal(t, int64(0), selected.ActiveConns)
+			}
+			selections[selected.ID]++
+		}
+		assert.Equal(t, 2, len(selections))
+	})
+}
+
+func TestWeightedRoundRobinLiveWeightUpdate(t *testing.T) {
+	ctx := context.Background()
+	req := httptest.NewRequest("GET", "http://example.com/test", nil)
+	
+	// The proxy builds fresh servers for every request, carrying the
+	// service's configured weights rather than any later update
+	freshServers := func() []*types.Server {
+		return createServers(2, 1) // server-1 weight 1, server-2 weight 2
+	}
+	
+	count := func(lb types.LoadBalancer, n int) map[string]int {
+		selections := make(map[string]int)
+		for i := 0; i < n; i++ {
+			selected, err := lb.Select(ctx, req, freshServers())
+			require.NoError(t, err)
+			selections[selected.ID]++
+		}
+		return selections
+	}
+	
+	balancers := map[string]func() types.LoadBalancer{
+		"weighted":        balancer.NewWeightedRoundRobin,
+		"smooth weighted": balancer.NewSmoothWeightedRoundRobin,
+	}
+	
+	for name, newBalancer := range balancers {
+		t.Run(name, func(t *testing.T) {
+			lb := newBalancer()
+			
+			before := count(lb, 300)
+			assert.Equal(t, 100, before["server-1"])
+			assert.Equal(t, 200, before["server-2"])
+			
+			// Shift traffic towards server-1 mid-stream, without re-adding
+			require.NoError(t, lb.UpdateWeight("server-1", 4))
+			
+			after := count(lb, 300)
+			assert.Equal(t, 200, after["server-1"])
+			assert.Equal(t, 100, after["server-2"])
+		})
+	}
+	
+	t.Run("concurrent updates and selects", func(t *testing.T) {
+		lb := balancer.NewWeightedRoundRobin()
+		count(lb, 1)
+		
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					if j%10 == 0 {
+						lb.UpdateWeight("server-1", i+1)
+					}
+					_, err := lb.Select(ctx, req, freshServers())
+					assert.NoError(t, err)
+				}
+			}(i)
+		}
+		wg.Wait()
+	})
 }
 
 func TestLeastConnectionsBalancer(t *testing.T) {
@@ -433,6 +513,120 @@ func TestLeastConnectionsBalancer(t *testing.T) {
 	})
 }
 
+func TestLeastTimeBalancer(t *testing.T) {
+	ctx := context.Background()
+	req := httptest.NewRequest("GET", "http://example.com/test", nil)
+	
+	observe := func(lb types.LoadBalancer, serverID string, latency time.Duration) {
+		lb.(types.LatencyObserver).ObserveLatency(serverID, latency)
+	}
+	
+	t.Run("Latency times outstanding requests", func(t *testing.T) {
+		lb := balancer.NewLeastTime()
+		servers := createServers(2, 1)
+		
+		observe(lb, "server-1", 10*time.Millisecond)
+		observe(lb, "server-2", 100*time.Millisecond)
+		
+		// 10ms x 6 beats 100ms x 1
+		servers[0].ActiveConns = 5
+		selected, err := lb.Select(ctx, req, servers)
+		assert.NoError(t, err)
+		assert.Equal(t, "server-1", selected.ID)
+		
+		// 10ms x 11 loses to 100ms x 1
+		servers[0].ActiveConns = 10
+		selected, err = lb.Select(ctx, req, servers)
+		assert.NoError(t, err)
+		assert.Equal(t, "server-2", selected.ID)
+	})
+	
+	t.Run("Skips unhealthy and saturated servers", func(t *testing.T) {
+		lb := balancer.NewLeastTime()
+		servers := createServers(3, 1)
+		
+		observe(lb, "server-1", time.Millisecond)
+		observe(lb, "server-2", 2*time.Millisecond)
+		observe(lb, "server-3", 50*time.Millisecond)
+		
+		servers[0].Healthy = false
+		servers[1].MaxConns = 2
+		servers[1].ActiveConns = 2
+		
+		selected, err := lb.Select(ctx, req, servers)
+		assert.NoError(t, err)
+		assert.Equal(t, "server-3", selected.ID)
+		
+		servers[2].Healthy = false
+		_, err = lb.Select(ctx, req, servers)
+		assert.ErrorIs(t, err, types.ErrNoHealthyBackends)
+	})
+	
+	t.Run("Unmeasured servers get the average latency", func(t *testing.T) {
+		lb := balancer.NewLeastTime()
+		servers := createServers(3, 1)
+		
+		observe(lb, "server-1", 10*time.Millisecond)
+		observe(lb, "server-2", 30*time.Millisecond)
+		
+		// server-3 is assumed to take 20ms, so one outstanding request on it
+		// scores 40ms against 10ms x 2 on server-1
+		servers[0].ActiveConns = 1
+		servers[2].ActiveConns = 1
+		selected, err := lb.Select(ctx, req, servers)
+		assert.NoError(t, err)
+		assert.Equal(t, "server-1", selected.ID)
+		
+		servers[0].ActiveConns = 3
+		servers[2].ActiveConns = 0
+		selected, err = lb.Select(ctx, req, servers)
+		assert.NoError(t, err)
+		assert.Equal(t, "server-3", selected.ID)
+	})
+	
+	t.Run("Slow server receives less traffic", func(t *testing.T) {
+		lb := balancer.NewLeastTime()
+		servers := createServers(3, 1)
+		latency := map[string]time.Duration{
+			"server-1": 2 * time.Millisecond,
+			"server-2": 2 * time.Millisecond,
+			"server-3": 20 * time.Millisecond,
+		}
+		
+		var mu sync.Mutex
+		hits := make(map[string]int)
+		
+		// Workers hold a request open on the chosen server for its latency,
+		// the way the proxy does
+		var wg sync.WaitGroup
+		for w := 0; w < 8; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := 0; i < 40; i++ {
+					selected, err := lb.Select(ctx, req, servers)
+					if !assert.NoError(t, err) {
+						return
+					}
+					
+					atomic.AddInt64(&selected.ActiveConns, 1)
+					time.Sleep(latency[selected.ID])
+					atomic.AddInt64(&selected.ActiveConns, -1)
+					observe(lb, selected.ID, latency[selected.ID])
+					
+					mu.Lock()
+					hits[selected.ID]++
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+		
+		assert.Less(t, hits["server-3"]*3, hits["server-1"])
+		assert.Less(t, hits["server-3"]*3, hits["server-2"])
+	})
+}
+
 func TestIPHashBalancer(t *testing.T) {
 	ctx := context.Background()
 	
@@ -495,7 +689,7 @@ func TestIPHashBalancer(t *testing.T) {
 		// Request with X-Forwarded-For
 		req := httptest.NewRequest("GET", "http://example.com/test", nil)
 		req.RemoteAddr = "proxy.example.com:8080"
-		req.Header.Set("X-Forwarded-For", "client.example.com, proxy1.example.com")
+		req.Header.Set("X-Forwarded-For", "203.0.113.7, 10.0.0.1")
 		
 		// Should use first IP in X-Forwarded-For
 		selected1, err := lb.Select(ctx, req, servers)
@@ -504,7 +698,7 @@ func TestIPHashBalancer(t *testing.T) {
 		// Same client through different proxy
 		req2 := httptest.NewRequest("GET", "http://example.com/test", nil)
 		req2.RemoteAddr = "proxy2.example.com:8080"
-		req2.Header.Set("X-Forwarded-For", "client.example.com, proxy2.example.com")
+		req2.Header.Set("X-Forwarded-For", "203.0.113.7, 10.0.0.2")
 		
 		selected2, err := lb.Select(ctx, req2, servers)
 		assert.NoError(t, err)
@@ -591,6 +785,34 @@ func TestIPHashBalancer(t *testing.T) {
 			}
 		}
 	})
+	
+	t.Run("Pools sharing the ring", func(t *testing.T) {
+		lb := balancer.NewIPHash()
+		pools := [][]*types.Server{createServers(20, 1), createServers(3, 1)}
+		for i, srv := range pools[1] {
+			srv.ID = fmt.Sprintf("small-%d", i+1)
+		}
+		
+		// Each pool keeps its clients on its own servers, the same ones it
+		// would get with a ring of its own
+		alone := balancer.NewIPHash()
+		for i := 0; i < 50; i++ {
+			req := httptest.NewRequest("GET", "http://example.com/test", nil)
+			req.RemoteAddr = fmt.Sprintf("10.0.%d.%d:12345", i/10, i)
+			
+			for _, pool := range pools {
+				selected, err := lb.Select(ctx, req, pool)
+				require.NoError(t, err)
+				assert.Contains(t, pool, selected)
+			}
+			
+			shared, err := lb.Select(ctx, req, pools[1])
+			require.NoError(t, err)
+			own, err := alone.Select(ctx, req, pools[1])
+			require.NoError(t, err)
+			assert.Equal(t, own.ID, shared.ID)
+		}
+	})
 }
 
 func TestStickySessionBalancer(t *testing.T) {
@@ -757,6 +979,338 @@ func TestStickySessionBalancer(t *testing.T) {
 	})
 }
 
+func TestWeightedStickySession(t *testing.T) {
+	ctx := context.Background()
+	
+	// newSessions counts which server each of n cookie-less requests lands on
+	newSessions := func(lb types.LoadBalancer, servers []*types.Server, n int) map[string]int {
+		usage := make(map[string]int)
+		for i := 0; i < n; i++ {
+			req := httptest.NewRequest("GET", "http://example.com/test", nil)
+			selected, err := lb.Select(ctx, req, servers)
+			require.NoError(t, err)
+			usage[selected.ID]++
+		}
+		return usage
+	}
+	
+	// withCookie selects a server for a request carrying the session cookie
+	withCookie := func(lb types.LoadBalancer, servers []*types.Server, value string) *types.Server {
+		req := httptest.NewRequest("GET", "http://example.com/test", nil)
+		req.AddCookie(&http.Cookie{Name: "SERVERID", Value: value})
+		selected, err := lb.Select(ctx, req, servers)
+		require.NoError(t, err)
+		return selected
+	}
+	
+	t.Run("New sessions follow weights", func(t *testing.T) {
+		lb := balancer.NewWeightedStickySession(balancer.NewWeightedRoundRobin(), "SERVERID", time.Hour)
+		servers := createServers(2, 1) // weights 1 and 2
+		
+		usage := newSessions(lb, servers, 300)
+		assert.Equal(t, 100, usage["server-1"])
+		assert.Equal(t, 200, usage["server-2"])
+		
+		// Existing sessions are unaffected by the distribution
+		assert.Equal(t, "server-1", withCookie(lb, servers, "server-1").ID)
+	})
+	
+	t.Run("Weight change rebalances new sessions only", func(t *testing.T) {
+		lb := balancer.NewWeightedStickySession(balancer.NewWeightedRoundRobin(), "SERVERID", time.Hour)
+		servers := createServers(2, 1)
+		newSessions(lb, servers, 3)
+		
+		require.NoError(t, lb.UpdateWeight("server-1", 4))
+		
+		usage := newSessions(lb, servers, 600)
+		assert.Equal(t, 400, usage["server-1"])
+		assert.Equal(t, 200, usage["server-2"])
+		assert.Equal(t, "server-2", withCookie(lb, servers, "server-2").ID)
+		
+		// The override applies inside the balancer; the caller's servers keep
+		// their own weights
+		assert.Equal(t, 1, servers[0].Weight)
+	})
+	
+	t.Run("Zero weight drains", func(t *testing.T) {
+		lb := balancer.NewWeightedStickySession(balancer.NewWeightedRoundRobin(), "SERVERID", time.Hour)
+		servers := createServers(3, 1)
+		newSessions(lb, servers, 6)
+		
+		require.NoError(t, lb.UpdateWeight("server-3", 0))
+		
+		// No new sessions land on the drained server
+		usage := newSessions(lb, servers, 100)
+		assert.Zero(t, usage["server-3"])
+		assert.Equal(t, 100, usage["server-1"]+usage["server-2"])
+		
+		// Its existing sessions stay pinned until they expire
+		for i := 0; i < 10; i++ {
+			assert.Equal(t, "server-3", withCookie(lb, servers, "server-3").ID)
+		}
+		
+		// Restoring the weight brings it back for new sessions
+		require.NoError(t, lb.UpdateWeight("server-3", 1))
+		usage = newSessions(lb, servers, 100)
+		assert.Greater(t, usage["server-3"], 0)
+	})
+	
+	t.Run("All servers drained", func(t *testing.T) {
+		lb := balancer.NewWeightedStickySession(balancer.NewWeightedRoundRobin(), "SERVERID", time.Hour)
+		servers := createServers(2, 1)
+		
+		require.NoError(t, lb.UpdateWeight("server-1", 0))
+		require.NoError(t, lb.UpdateWeight("server-2", 0))
+		
+		req := httptest.NewRequest("GET", "http://example.com/test", nil)
+		_, err := lb.Select(ctx, req, servers)
+		assert.ErrorIs(t, err, types.ErrNoHealthyBackends)
+	})
+	
+	t.Run("Invalid weight", func(t *testing.T) {
+		lb := balancer.NewWeightedStickySession(balancer.NewWeightedRoundRobin(), "SERVERID", time.Hour)
+		assert.ErrorIs(t, lb.UpdateWeight("server-1", -1), types.ErrInvalidWeight)
+	})
+}
+
+func TestUpgradeAffinity(t *testing.T) {
+	ctx := context.Background()
+	
+	upgrade := func(remoteAddr string) *http.Request {
+		req := httptest.NewRequest("GET", "http://example.com/ws", nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("Connection", "Upgrade")
+		req.Header.Set("Upgrade", "websocket")
+		return req
+	}
+	
+	t.Run("Reconnects land on the same backend", func(t *testing.T) {
+		lb := balancer.NewUpgradeAffinity(balancer.NewRoundRobin(), "", time.Hour)
+		defer lb.(interface{ Stop() }).Stop()
+		servers := createServers(3, 1)
+		
+		clients := []string{"10.0.0.1:5000", "10.0.0.2:5000", "10.0.0.3:5000"}
+		pinned := make(map[string]string)
+		for _, client := range clients {
+			server, err := lb.Select(ctx, upgrade(client), servers)
+			require.NoError(t, err)
+			pinned[client] = server.ID
+		}
+		
+		// Plain requests in between keep round robin moving
+		for i := 0; i < 5; i++ {
+			_, err := lb.Select(ctx, httptest.NewRequest("GET", "http://example.com/", nil), servers)
+			require.NoError(t, err)
+		}
+		
+		// Each reconnect comes from a new source port
+		for i := 0; i < 10; i++ {
+			for _, client := range clients {
+				addr := client[:len(client)-4] + fmt.Sprint(6000+i)
+				server, err := lb.Select(ctx, upgrade(addr), servers)
+				require.NoError(t, err)
+				assert.Equal(t, pinned[client], server.ID, "client %s reconnect %d", client, i)
+			}
+		}
+	})
+	
+	t.Run("Unhealthy pin fails over and re-pins", func(t *testing.T) {
+		lb := balancer.NewUpgradeAffinity(balancer.NewRoundRobin(), "", time.Hour)
+		defer lb.(interface{ Stop() }).Stop()
+		servers := createServers(3, 1)
+		
+		first, err := lb.Select(ctx, upgrade("10.0.0.1:5000"), servers)
+		require.NoError(t, err)
+		
+		first.Healthy = false
+		second, err := lb.Select(ctx, upgrade("10.0.0.1:5001"), servers)
+		require.NoError(t, err)
+		assert.NotEqual(t, first.ID, second.ID)
+		
+		// The old backend recovering does not move the client back
+		first.Healthy = true
+		again, err := lb.Select(ctx, upgrade("10.0.0.1:5002"), servers)
+		require.NoError(t, err)
+		assert.Equal(t, second.ID, again.ID)
+	})
+	
+	t.Run("Cookie identifies clients behind one address", func(t *testing.T) {
+		lb := balancer.NewUpgradeAffinity(balancer.NewRoundRobin(), "ws_client", time.Hour)
+		defer lb.(interface{ Stop() }).Stop()
+		servers := createServers(3, 1)
+		
+		withCookie := func(value string) *http.Request {
+			req := upgrade("192.168.1.1:4000")
+			req.AddCookie(&http.Cookie{Name: "ws_client", Value: value})
+			return req
+		}
+		
+		alice, err := lb.Select(ctx, withCookie("alice"), servers)
+		require.NoError(t, err)
+		bob, err := lb.Select(ctx, withCookie("bob"), servers)
+		require.NoError(t, err)
+		assert.NotEqual(t, alice.ID, bob.ID)
+		
+		for i := 0; i < 5; i++ {
+			server, err := lb.Select(ctx, withCookie("alice"), servers)
+			require.NoError(t, err)
+			assert.Equal(t, alice.ID, server.ID)
+			
+			server, err = lb.Select(ctx, withCookie("bob"), servers)
+			require.NoError(t, err)
+			assert.Equal(t, bob.ID, server.ID)
+		}
+	})
+	
+	t.Run("Plain requests are not pinned", func(t *testing.T) {
+		lb := balancer.NewUpgradeAffinity(balancer.NewRoundRobin(), "", time.Hour)
+		defer lb.(interface{ Stop() }).Stop()
+		servers := createServers(3, 1)
+		
+		seen := make(map[string]bool)
+		for i := 0; i < 3; i++ {
+			req := httptest.NewRequest("GET", "http://example.com/ws", nil)
+			req.RemoteAddr = "10.0.0.1:5000"
+			server, err := lb.Select(ctx, req, servers)
+			require.NoError(t, err)
+			seen[server.ID] = true
+		}
+		assert.Len(t, seen, 3)
+	})
+}
+
+func TestZonePreference(t *testing.T) {
+	ctx := context.Background()
+	
+	zonedServers := func() []*types.Server {
+		servers := createServers(4, 1)
+		zones := []string{"us-east-1a", "us-east-1a", "us-east-1b", ""}
+		for i, server := range servers {
+			if zones[i] != "" {
+				server.Metadata = map[string]string{types.TagZone: zones[i]}
+			}
+		}
+		return servers
+	}
+	
+	inZone := func(zone string) *http.Request {
+		req := httptest.NewRequest("GET", "http://example.com/", nil)
+		if zone != "" {
+			req.Header.Set("X-Client-Zone", zone)
+		}
+		return req
+	}
+	
+	pick := func(t *testing.T, lb types.LoadBalancer, req func() *http.Request, servers []*types.Server, n int) map[string]int {
+		usage := make(map[string]int)
+		for i := 0; i < n; i++ {
+			server, err := lb.Select(ctx, req(), servers)
+			require.NoError(t, err)
+			usage[server.ID]++
+		}
+		return usage
+	}
+	
+	t.Run("Prefers backends in the request's zone", func(t *testing.T) {
+		lb := balancer.NewZonePreference(balancer.NewRoundRobin(), "X-Client-Zone", "")
+		servers := zonedServers()
+		
+		usage := pick(t, lb, func() *http.Request { return inZone("us-east-1a") }, servers, 10)
+		assert.Equal(t, map[string]int{"server-1": 5, "server-2": 5}, usage)
+		
+		usage = pick(t, lb, func() *http.Request { return inZone("us-east-1b") }, servers, 4)
+		assert.Equal(t, map[string]int{"server-3": 4}, usage)
+	})
+	
+	t.Run("Falls back when the zone has no healthy backends", func(t *testing.T) {
+		lb := balancer.NewZonePreference(balancer.NewRoundRobin(), "X-Client-Zone", "")
+		servers := zonedServers()
+		servers[2].Healthy = false
+		
+		usage := pick(t, lb, func() *http.Request { return inZone("us-east-1b") }, servers, 9)
+		assert.Zero(t, usage["server-3"])
+		assert.Len(t, usage, 3)
+		
+		usage = pick(t, lb, func() *http.Request { return inZone("eu-west-1a") }, servers, 9)
+		assert.Len(t, usage, 3)
+	})
+	
+	t.Run("Default zone applies without the header", func(t *testing.T) {
+		lb := balancer.NewZonePreference(balancer.NewRoundRobin(), "X-Client-Zone", "us-east-1b")
+		servers := zonedServers()
+		
+		usage := pick(t, lb, func() *http.Request { return inZone("") }, servers, 3)
+		assert.Equal(t, map[string]int{"server-3": 3}, usage)
+		
+		// The header still wins
+		usage = pick(t, lb, func() *http.Request { return inZone("us-east-1a") }, servers, 4)
+		assert.Equal(t, map[string]int{"server-1": 2, "server-2": 2}, usage)
+	})
+	
+	t.Run("No zone uses every backend", func(t *testing.T) {
+		lb := balancer.NewZonePreference(balancer.NewRoundRobin(), "X-Client-Zone", "")
+		servers := zonedServers()
+		
+		usage := pick(t, lb, func() *http.Request { return inZone("") }, servers, 8)
+		assert.Len(t, usage, 4)
+	})
+}
+
+func TestWrappersExplainSelection(t *testing.T) {
+	ctx := context.Background()
+	
+	wrappers := map[string]func(base types.LoadBalancer) types.LoadBalancer{
+		"zone preference": func(base types.LoadBalancer) types.LoadBalancer {
+			return balancer.NewZonePreference(base, "X-Client-Zone", "")
+		},
+		"sticky session": func(base types.LoadBalancer) types.LoadBalancer {
+			return balancer.NewStickySession(base, "", time.Minute)
+		},
+		"weighted sticky session": func(base types.LoadBalancer) types.LoadBalancer {
+			return balancer.NewWeightedStickySession(base, "", time.Minute)
+		},
+		"IP sticky session": func(base types.LoadBalancer) types.LoadBalancer {
+			return balancer.NewIPStickySession(base, time.Minute)
+		},
+		"upgrade affinity": func(base types.LoadBalancer) types.LoadBalancer {
+			return balancer.NewUpgradeAffinity(base, "", time.Minute)
+		},
+		"adaptive weights": func(base types.LoadBalancer) types.LoadBalancer {
+			return balancer.NewAdaptiveWeights(base, time.Hour, 10, 1, 10)
+		},
+	}
+	
+	for name, wrap := range wrappers {
+		t.Run(name, func(t *testing.T) {
+			lb := wrap(balancer.NewLeastConnections())
+			if stopper, ok := lb.(interface{ Stop() }); ok {
+				defer stopper.Stop()
+			}
+			
+			explainer, ok := lb.(types.SelectionExplainer)
+			require.True(t, ok, "wrapper hides the base balancer's explanations")
+			
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("Upgrade", "websocket")
+			server, reason, err := explainer.SelectWithReason(ctx, req, createServers(2, 1))
+			require.NoError(t, err)
+			require.NotNil(t, server)
+			require.NotNil(t, reason)
+			assert.Equal(t, "least_conn", reason.Algorithm)
+			assert.Len(t, reason.Candidates, 2)
+		})
+	}
+	
+	t.Run("base without explanations", func(t *testing.T) {
+		lb := balancer.NewZonePreference(balancer.NewRoundRobin(), "X-Client-Zone", "")
+		
+		server, reason, err := lb.(types.SelectionExplainer).SelectWithReason(ctx, httptest.NewRequest("GET", "/", nil), createServers(2, 1))
+		require.NoError(t, err)
+		assert.NotNil(t, server)
+		assert.Nil(t, reason)
+	})
+}
+
 func TestLoadBalancerEdgeCases(t *testing.T) {
 	ctx := context.Background()
 	
@@ -973,4 +1527,76 @@ func TestLoadBalancerPerformance(t *testing.T) {
 		assert.Less(t, perRequest, time.Microsecond*100,
 			"%s performance degraded", name)
 	}
-}
\ No newline at end of file
+}
+func TestWeightedIPHash(t *testing.T) {
+	ctx := context.Background()
+
+	// Weights 1, 2 and 3
+	servers := createServers(3, 1)
+	lb := balancer.NewIPHash()
+
+	ips := make([]string, 6000)
+	for i := range ips {
+		ips[i] = fmt.Sprintf("10.%d.%d.%d", i/65536, (i/256)%256, i%256)
+	}
+
+	assign := func() map[string]string {
+		assignments := make(map[string]string, len(ips))
+		for _, ip := range ips {
+			req := httptest.NewRequest("GET", "http://example.com/test", nil)
+			req.RemoteAddr = ip + ":12345"
+			selected, err := lb.Select(ctx, req, servers)
+			require.NoError(t, err)
+			assignments[ip] = selected.ID
+		}
+		return assignments
+	}
+
+	shares := func(assignments map[string]string) map[string]int {
+		counts := make(map[string]int)
+		for _, id := range assignments {
+			counts[id]++
+		}
+		return counts
+	}
+
+	first := assign()
+
+	t.Run("key ranges track weights", func(t *testing.T) {
+		counts := shares(first)
+		assert.Less(t, counts["server-1"], counts["server-2"])
+		assert.Less(t, counts["server-2"], counts["server-3"])
+		assert.InEpsilon(t, 1000, counts["server-1"], 0.3)
+		assert.InEpsilon(t, 2000, counts["server-2"], 0.3)
+		assert.InEpsilon(t, 3000, counts["server-3"], 0.3)
+	})
+
+	t.Run("same ip stays on its server", func(t *testing.T) {
+		assert.Equal(t, first, assign())
+	})
+
+	t.Run("weight change only moves keys to the heavier server", func(t *testing.T) {
+		require.NoError(t, lb.UpdateWeight("server-1", 6))
+		after := assign()
+
+		moved := 0
+		for ip, id := range after {
+			if id != first[ip] {
+				moved++
+				assert.Equal(t, "server-1", id, "%s moved from %s to %s", ip, first[ip], id)
+			}
+		}
+		assert.Greater(t, moved, 0)
+
+		counts := shares(after)
+		assert.Greater(t, counts["server-1"], counts["server-3"])
+
+		// Back to the original weight, keys return to where they were
+		require.NoError(t, lb.UpdateWeight("server-1", 1))
+		assert.Equal(t, first, assign())
+	})
+
+	t.Run("unknown server", func(t *testing.T) {
+		assert.ErrorIs(t, lb.UpdateWeight("server-9", 2), types.ErrServerNotFound)
+	})
+}
@@ -0,0 +1,219 @@
+package circuit_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"discobox/internal/circuit"
+	"discobox/internal/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testLogger is a simple logger implementation for tests
+type testLogger struct{}
+
+func (l *testLogger) Debug(msg string, fields ...any) {}
+func (l *testLogger) Info(msg string, fields ...any)  {}
+func (l *testLogger) Warn(msg string, fields ...any)  {}
+func (l *testLogger) Error(msg string, fields ...any) {}
+func (l *testLogger) With(fields ...any) types.Logger { return l }
+
+func TestHealthCheckCriteria(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		body        string
+		criteria    *types.HealthCheckConfig
+		wantHealthy bool
+	}{
+		{
+			name:        "2xx accepted by default",
+			status:      http.StatusOK,
+			wantHealthy: true,
+		},
+		{
+			name:        "non-2xx rejected by default",
+			status:      http.StatusServiceUnavailable,
+			wantHealthy: false,
+		},
+		{
+			name:        "204 configured as healthy",
+			status:      http.StatusNoContent,
+			criteria:    &types.HealthCheckConfig{StatusCodes: []int{http.StatusNoContent}},
+			wantHealthy: true,
+		},
+		{
+			name:        "2xx not in the configured codes",
+			status:      http.StatusOK,
+			criteria:    &types.HealthCheckConfig{StatusCodes: []int{http.StatusNoContent}},
+			wantHealthy: false,
+		},
+		{
+			name:        "non-2xx configured as healthy",
+			status:      http.StatusTooManyRequests,
+			criteria:    &types.HealthCheckConfig{StatusCodes: []int{http.StatusOK, http.StatusTooManyRequests}},
+			wantHealthy: true,
+		},
+		{
+			name:        "200 with the expected body",
+			status:      http.StatusOK,
+			body:        `{"status":"ok","db":"up"}`,
+			criteria:    &types.HealthCheckConfig{BodyContains: `"db":"up"`},
+			wantHealthy: true,
+		},
+		{
+			name:        "200 with the wrong body",
+			status:      http.StatusOK,
+			body:        `{"status":"ok","db":"down"}`,
+			criteria:    &types.HealthCheckConfig{BodyContains: `"db":"up"`},
+			wantHealthy: false,
+		},
+		{
+			name:        "expected body with a rejected status",
+			status:      http.StatusInternalServerError,
+			body:        `{"status":"ok","db":"up"}`,
+			criteria:    &types.HealthCheckConfig{BodyContains: `"db":"up"`},
+			wantHealthy: false,
+		},
+		{
+			name:        "JSON field with the expected value",
+			status:      http.StatusOK,
+			body:        `{"status":"UP"}`,
+			criteria:    &types.HealthCheckConfig{JSONPath: "$.status", JSONEquals: "UP"},
+			wantHealthy: true,
+		},
+		{
+			name:        "JSON field with another value despite 200",
+			status:      http.StatusOK,
+			body:        `{"status":"DOWN"}`,
+			criteria:    &types.HealthCheckConfig{JSONPath: "$.status", JSONEquals: "UP"},
+			wantHealthy: false,
+		},
+		{
+			name:        "nested JSON field",
+			status:      http.StatusOK,
+			body:        `{"checks":[{"name":"db","ok":true}]}`,
+			criteria:    &types.HealthCheckConfig{JSONPath: "$.checks[0].ok", JSONEquals: "true"},
+			wantHealthy: true,
+		},
+		{
+			name:        "JSON field missing",
+			status:      http.StatusOK,
+			body:        `{"state":"UP"}`,
+			criteria:    &types.HealthCheckConfig{JSONPath: "$.status", JSONEquals: "UP"},
+			wantHealthy: false,
+		},
+		{
+			name:        "body not JSON",
+			status:      http.StatusOK,
+			body:        `UP`,
+			criteria:    &types.HealthCheckConfig{JSONPath: "$.status", JSONEquals: "UP"},
+			wantHealthy: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "/health", r.URL.Path)
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer backend.Close()
+
+			u, err := url.Parse(backend.URL)
+			require.NoError(t, err)
+			server := &types.Server{ID: "backend-1", URL: u, Healthy: true, HealthCheck: tt.criteria}
+
+			checker := circuit.NewHealthChecker(time.Second, time.Second, 1, 1, 0, &testLogger{})
+			err = checker.Check(context.Background(), server)
+
+			if tt.wantHealthy {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+
+			reporter := checker.(interface{ IsHealthy(serverID string) bool })
+			assert.Equal(t, tt.wantHealthy, reporter.IsHealthy(server.ID))
+		})
+	}
+}
+
+func TestLookupJSONPath(t *testing.T) {
+	var doc any
+	require.NoError(t, json.Unmarshal([]byte(`{"status":"UP","checks":{"db":{"latency":3}},"nodes":[{"state":"ready"}],"dotted.key":1}`), &doc))
+
+	tests := []struct {
+		path string
+		want any
+	}{
+		{"$.status", "UP"},
+		{"status", "UP"},
+		{"$.checks.db.latency", float64(3)},
+		{`$["checks"]["db"]`, map[string]any{"latency": float64(3)}},
+		{"$.nodes[0].state", "ready"},
+		{`$["dotted.key"]`, float64(1)},
+	}
+	for _, tt := range tests {
+		value, err := types.LookupJSONPath(doc, tt.path)
+		require.NoError(t, err, tt.path)
+		assert.Equal(t, tt.want, value, tt.path)
+	}
+
+	for _, path := range []string{"$.missing", "$.nodes[1]", "$.status.inner", "$.checks[0]"} {
+		_, err := types.LookupJSONPath(doc, path)
+		assert.Error(t, err, path)
+	}
+
+	for _, path := range []string{"", "$", "$..status", "$.nodes[", "$.nodes[-1]", "$status"} {
+		assert.Error(t, types.ValidateJSONPath(path), path)
+	}
+}
+
+func TestHealthCheckProbeLimit(t *testing.T) {
+	const maxProbes, endpoints = 4, 40
+
+	var inflight, peak, probed atomic.Int32
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inflight.Add(1)
+		defer inflight.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		probed.Add(1)
+	}))
+	defer backend.Close()
+
+	u, err := url.Parse(backend.URL)
+	require.NoError(t, err)
+
+	checker := circuit.NewHealthChecker(time.Minute, time.Second, 1, 1, maxProbes, &testLogger{})
+	defer checker.(interface{ Stop() }).Stop()
+
+	// Every endpoint's watcher probes at once on start
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for i := range endpoints {
+		server := &types.Server{ID: fmt.Sprintf("backend-%d", i), URL: u, Healthy: true}
+		checker.Watch(ctx, server, time.Minute)
+	}
+
+	// The rest queue rather than being dropped
+	require.Eventually(t, func() bool { return probed.Load() == endpoints }, 5*time.Second, 10*time.Millisecond)
+	assert.LessOrEqual(t, peak.Load(), int32(maxProbes))
+	assert.Equal(t, int32(maxProbes), peak.Load(), "probes should run up to the limit")
+}
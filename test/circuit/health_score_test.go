@@ -0,0 +1,42 @@
+package circuit_test
+
+import (
+	"testing"
+	"time"
+
+	"discobox/internal/circuit"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthScore(t *testing.T) {
+	t.Run("unknown backends are healthy", func(t *testing.T) {
+		scorer := circuit.NewHealthScorer(10, 0)
+		assert.Equal(t, 1.0, scorer.Score("backend"))
+	})
+
+	t.Run("errors lower the score and successes restore it", func(t *testing.T) {
+		scorer := circuit.NewHealthScorer(10, 0)
+		for i := 0; i < 10; i++ {
+			scorer.RecordResult("backend", i%2 == 0, time.Millisecond)
+		}
+
+		degraded := scorer.Score("backend")
+		assert.Greater(t, degraded, 0.3)
+		assert.Less(t, degraded, 0.7)
+
+		for i := 0; i < 50; i++ {
+			scorer.RecordResult("backend", false, time.Millisecond)
+		}
+		assert.Greater(t, scorer.Score("backend"), 0.99)
+	})
+
+	t.Run("latency over the threshold lowers the score", func(t *testing.T) {
+		scorer := circuit.NewHealthScorer(10, 100*time.Millisecond)
+		scorer.RecordResult("fast", false, 50*time.Millisecond)
+		scorer.RecordResult("slow", false, 400*time.Millisecond)
+
+		assert.Equal(t, 1.0, scorer.Score("fast"))
+		assert.InDelta(t, 0.25, scorer.Score("slow"), 0.001)
+	})
+}
@@ -0,0 +1,235 @@
+package config_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"discobox/internal/config"
+	"discobox/internal/storage"
+	"discobox/internal/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testLogger is a simple logger implementation for tests
+type testLogger struct{}
+
+func (l *testLogger) Debug(msg string, fields ...any) {}
+func (l *testLogger) Info(msg string, fields ...any)  {}
+func (l *testLogger) Warn(msg string, fields ...any)  {}
+func (l *testLogger) Error(msg string, fields ...any) {}
+func (l *testLogger) With(fields ...any) types.Logger { return l }
+
+const usersFile = `
+services:
+  - id: users
+    name: Users
+    endpoints: ["http://10.0.0.1:8080"]
+    timeout: 5s
+    active: true
+routes:
+  - id: users
+    path_prefix: /users
+    service_id: users
+`
+
+const ordersFile = `
+services:
+  - id: orders
+    name: Orders
+    endpoints: ["http://10.0.0.2:8080"]
+    active: true
+routes:
+  - id: orders
+    path_prefix: /orders
+    service_id: orders
+`
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+}
+
+func serviceIDs(t *testing.T, store types.Storage) []string {
+	t.Helper()
+	services, err := store.ListServices(context.Background())
+	require.NoError(t, err)
+	var ids []string
+	for _, service := range services {
+		ids = append(ids, service.ID)
+	}
+	return ids
+}
+
+func routeIDs(t *testing.T, store types.Storage) []string {
+	t.Helper()
+	routes, err := store.ListRoutes(context.Background())
+	require.NoError(t, err)
+	var ids []string
+	for _, route := range routes {
+		ids = append(ids, route.ID)
+	}
+	return ids
+}
+
+func TestDirLoaderReconcile(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store := storage.NewMemory()
+	t.Cleanup(func() { store.Close() })
+
+	// Created through the API; the directory must never remove it
+	require.NoError(t, store.CreateService(ctx, &types.Service{ID: "manual", Endpoints: []string{"http://10.0.0.9"}, Active: true}))
+
+	writeFile(t, filepath.Join(dir, "users.yml"), usersFile)
+	writeFile(t, filepath.Join(dir, "README.md"), "not config")
+
+	loader := config.NewDirLoader(dir, store, &testLogger{})
+	require.NoError(t, loader.Reconcile(ctx))
+
+	assert.ElementsMatch(t, []string{"manual", "users"}, serviceIDs(t, store))
+	assert.ElementsMatch(t, []string{"users"}, routeIDs(t, store))
+
+	users, err := store.GetService(ctx, "users")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"http://10.0.0.1:8080"}, users.Endpoints)
+	assert.Equal(t, 5*time.Second, users.Timeout)
+	assert.Equal(t, "config_dir", users.Metadata[config.MetadataManagedBy])
+
+	t.Run("unchanged files leave storage alone", func(t *testing.T) {
+		require.NoError(t, loader.Reconcile(ctx))
+		again, err := store.GetService(ctx, "users")
+		require.NoError(t, err)
+		assert.Equal(t, users.Version, again.Version)
+	})
+
+	t.Run("added file is created", func(t *testing.T) {
+		writeFile(t, filepath.Join(dir, "orders.yml"), ordersFile)
+		require.NoError(t, loader.Reconcile(ctx))
+
+		assert.ElementsMatch(t, []string{"manual", "users", "orders"}, serviceIDs(t, store))
+		assert.ElementsMatch(t, []string{"users", "orders"}, routeIDs(t, store))
+	})
+
+	t.Run("edited file is updated", func(t *testing.T) {
+		edited := `
+services:
+  - id: users
+    name: Users
+    endpoints: ["http://10.0.0.3:8080"]
+    active: true
+routes:
+  - id: users
+    path_prefix: /v2/users
+    service_id: users
+`
+		writeFile(t, filepath.Join(dir, "users.yml"), edited)
+		require.NoError(t, loader.Reconcile(ctx))
+
+		service, err := store.GetService(ctx, "users")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"http://10.0.0.3:8080"}, service.Endpoints)
+		route, err := store.GetRoute(ctx, "users")
+		require.NoError(t, err)
+		assert.Equal(t, "/v2/users", route.PathPrefix)
+	})
+
+	t.Run("invalid upstream schemes are ignored", func(t *testing.T) {
+		schemes := `
+services:
+  - id: users
+    name: Users
+    endpoints: ["http://10.0.0.3:8080"]
+    upstream_scheme: HTTPS
+    active: true
+routes:
+  - id: users
+    path_prefix: /v2/users
+    service_id: users
+    upstream_scheme: https
+`
+		writeFile(t, filepath.Join(dir, "users.yml"), schemes)
+		require.NoError(t, loader.Reconcile(ctx))
+
+		service, err := store.GetService(ctx, "users")
+		require.NoError(t, err)
+		assert.Empty(t, service.UpstreamScheme)
+		route, err := store.GetRoute(ctx, "users")
+		require.NoError(t, err)
+		assert.Equal(t, "https", route.UpstreamScheme)
+	})
+
+	t.Run("removed file is deleted", func(t *testing.T) {
+		require.NoError(t, os.Remove(filepath.Join(dir, "users.yml")))
+		require.NoError(t, loader.Reconcile(ctx))
+
+		assert.ElementsMatch(t, []string{"manual", "orders"}, serviceIDs(t, store))
+		assert.ElementsMatch(t, []string{"orders"}, routeIDs(t, store))
+	})
+
+	t.Run("unreadable file changes nothing", func(t *testing.T) {
+		writeFile(t, filepath.Join(dir, "broken.yml"), "services: [")
+		assert.Error(t, loader.Reconcile(ctx))
+		assert.ElementsMatch(t, []string{"manual", "orders"}, serviceIDs(t, store))
+		require.NoError(t, os.Remove(filepath.Join(dir, "broken.yml")))
+	})
+
+	t.Run("duplicate ids are rejected", func(t *testing.T) {
+		writeFile(t, filepath.Join(dir, "orders-copy.yml"), ordersFile)
+		assert.ErrorContains(t, loader.Reconcile(ctx), "declared in both")
+		require.NoError(t, os.Remove(filepath.Join(dir, "orders-copy.yml")))
+	})
+}
+
+func TestDirLoaderWatch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	dir := t.TempDir()
+	store := storage.NewMemory()
+	t.Cleanup(func() { store.Close() })
+
+	loader := config.NewDirLoader(dir, store, &testLogger{})
+	require.NoError(t, loader.Reconcile(ctx))
+	require.NoError(t, loader.Watch(ctx))
+
+	writeFile(t, filepath.Join(dir, "users.yml"), usersFile)
+	require.Eventually(t, func() bool {
+		_, err := store.GetRoute(ctx, "users")
+		return err == nil
+	}, 5*time.Second, 50*time.Millisecond)
+
+	require.NoError(t, os.Remove(filepath.Join(dir, "users.yml")))
+	require.Eventually(t, func() bool {
+		_, err := store.GetService(ctx, "users")
+		return err == types.ErrServiceNotFound
+	}, 5*time.Second, 50*time.Millisecond)
+}
+
+func TestDirLoaderReconcileSQLite(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store, err := storage.NewSQLite(filepath.Join(t.TempDir(), "discobox.db"), &testLogger{})
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+
+	// Numbers in route metadata come back from storage as float64
+	writeFile(t, filepath.Join(dir, "users.yml"), usersFile+`    metadata:
+      max_concurrent: 5
+      qos_class: high
+`)
+	loader := config.NewDirLoader(dir, store, &testLogger{})
+	require.NoError(t, loader.Reconcile(ctx))
+
+	route, err := store.GetRoute(ctx, "users")
+	require.NoError(t, err)
+
+	require.NoError(t, loader.Reconcile(ctx))
+	again, err := store.GetRoute(ctx, "users")
+	require.NoError(t, err)
+	assert.Equal(t, route.Version, again.Version)
+}
@@ -0,0 +1,65 @@
+package config_test
+
+import (
+	"testing"
+
+	"discobox/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateListenAddrs(t *testing.T) {
+	tests := []struct {
+		name  string
+		yaml  string
+		valid bool
+	}{
+		{"single address", `listen_addr: ":8080"`, true},
+		{"internal and external", "listen_addr: \"203.0.113.10:8080\"\nlisten_addrs: [\"10.0.0.5:8080\", \"127.0.0.1:9090\"]", true},
+		{"ephemeral ports", "listen_addr: \"127.0.0.1:0\"\nlisten_addrs: [\"127.0.0.1:0\"]", true},
+		{"duplicate address", "listen_addr: \"127.0.0.1:8080\"\nlisten_addrs: [\"127.0.0.1:8080\"]", false},
+		{"duplicate in list", "listen_addr: \":8080\"\nlisten_addrs: [\"10.0.0.5:9090\", \"10.0.0.5:9090\"]", false},
+		{"wildcard overlaps", "listen_addr: \":8080\"\nlisten_addrs: [\"10.0.0.5:8080\"]", false},
+		{"missing port", "listen_addr: \":8080\"\nlisten_addrs: [\"10.0.0.5\"]", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := config.Parse([]byte(tt.yaml), "yaml")
+			require.NoError(t, err)
+
+			err = config.Validate(cfg)
+			if tt.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateFieldErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		yaml  string
+		field string
+	}{
+		{"invalid enum", `error_format: "xml"`, "error_format"},
+		{"nested field", "health_check:\n  timeout: 30s\n  interval: 10s", "health_check.timeout"},
+		{"list entry", "tls:\n  enabled: true\n  cert_file: a.pem\n  key_file: a.key\n  certificates:\n    - cert_file: b.pem", "tls.certificates[0]"},
+		{"message without the field path", "http3:\n  enabled: true", "http3"},
+		{"overlapping listen addresses", "listen_addr: \":8080\"\nlisten_addrs: [\"10.0.0.5:8080\"]", "listen_addrs"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := config.Parse([]byte(tt.yaml), "yaml")
+			require.NoError(t, err)
+
+			var fieldErr *config.FieldError
+			require.ErrorAs(t, config.Validate(cfg), &fieldErr)
+			assert.Equal(t, tt.field, fieldErr.Field)
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package middleware_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"discobox/internal/middleware"
+	"discobox/internal/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccessLogFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "access.log")
+
+	cfg := types.ProxyConfig{}
+	cfg.Logging.Format = "json"
+	cfg.Logging.AccessLogFile.Path = path
+	cfg.Logging.AccessLogFile.MaxSize = 1 // Megabytes
+
+	file := middleware.NewAccessLogFile(cfg)
+	defer file.Close()
+
+	handler := middleware.AccessLogging(file.Logger())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	serve := func(query string) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", "http://example.com/items?"+query, nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+	}
+
+	backups := func() []string {
+		matches, err := filepath.Glob(filepath.Join(dir, "access-*.log"))
+		require.NoError(t, err)
+		return matches
+	}
+
+	t.Run("entries are written to the file", func(t *testing.T) {
+		serve("q=first")
+
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+
+		var entry map[string]any
+		require.NoError(t, json.Unmarshal(data, &entry))
+		assert.Equal(t, "request", entry["msg"])
+		assert.Equal(t, "GET", entry["method"])
+		assert.Equal(t, "/items?q=first", entry["path"])
+		assert.Equal(t, float64(http.StatusOK), entry["status"])
+	})
+
+	t.Run("file rotates at the size limit", func(t *testing.T) {
+		padding := strings.Repeat("x", 1024)
+
+		// Just under a megabyte of entries stays in one file
+		written := int64(0)
+		for written < 900*1024 {
+			serve("pad=" + padding)
+			info, err := os.Stat(path)
+			require.NoError(t, err)
+			written = info.Size()
+		}
+		assert.Empty(t, backups())
+
+		// Passing the limit moves the full file aside
+		for len(backups()) == 0 && written < 2*1024*1024 {
+			serve("pad=" + padding)
+			written += 1024
+		}
+		require.Len(t, backups(), 1)
+
+		backup, err := os.Stat(backups()[0])
+		require.NoError(t, err)
+		assert.LessOrEqual(t, backup.Size(), int64(1024*1024))
+		assert.Greater(t, backup.Size(), int64(900*1024))
+
+		// The new file holds only what came after, one entry per line
+		current, err := os.Open(path)
+		require.NoError(t, err)
+		defer current.Close()
+		lines := 0
+		scanner := bufio.NewScanner(current)
+		scanner.Buffer(make([]byte, 4096), 64*1024)
+		for scanner.Scan() {
+			require.True(t, json.Valid(scanner.Bytes()))
+			lines++
+		}
+		require.NoError(t, scanner.Err())
+		assert.Equal(t, 1, lines)
+	})
+
+	t.Run("rotate starts a new file", func(t *testing.T) {
+		require.NoError(t, file.Rotate())
+		serve("q=after")
+
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Contains(t, string(data), "q=after")
+		assert.Equal(t, 1, strings.Count(string(data), "\n"))
+	})
+}
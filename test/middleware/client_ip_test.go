@@ -0,0 +1,166 @@
+package middleware_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	"discobox/internal/balancer"
+	"discobox/internal/middleware"
+	"discobox/internal/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// accessLogRecorder keeps the client_ip field of each access log entry
+type accessLogRecorder struct {
+	mu        sync.Mutex
+	clientIPs []string
+}
+
+func (l *accessLogRecorder) Debug(msg string, fields ...any) {}
+func (l *accessLogRecorder) Warn(msg string, fields ...any)  {}
+func (l *accessLogRecorder) Error(msg string, fields ...any) {}
+func (l *accessLogRecorder) With(fields ...any) types.Logger { return l }
+
+func (l *accessLogRecorder) Info(msg string, fields ...any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i := 0; i+1 < len(fields); i += 2 {
+		if fields[i] == "client_ip" {
+			l.clientIPs = append(l.clientIPs, fmt.Sprint(fields[i+1]))
+		}
+	}
+}
+
+func (l *accessLogRecorder) last() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.clientIPs) == 0 {
+		return ""
+	}
+	return l.clientIPs[len(l.clientIPs)-1]
+}
+
+func TestResolveClientIP(t *testing.T) {
+	tests := []struct {
+		name       string
+		remoteAddr string
+		xff        []string
+		realIP     string
+		depth      int
+		want       string
+	}{
+		{"no forwarding headers", "198.51.100.9:4000", nil, "", 1, "198.51.100.9"},
+		{"depth 0 ignores the headers", "198.51.100.9:4000", []string{"203.0.113.7"}, "203.0.113.5", 0, "198.51.100.9"},
+		{"one trusted proxy", "198.51.100.9:4000", []string{"1.1.1.1, 203.0.113.7"}, "", 1, "203.0.113.7"},
+		{"two trusted proxies", "198.51.100.9:4000", []string{"1.1.1.1, 203.0.113.7, 10.0.0.2"}, "", 2, "203.0.113.7"},
+		{"headers are joined in order", "198.51.100.9:4000", []string{"1.1.1.1, 203.0.113.7", "10.0.0.2"}, "", 2, "203.0.113.7"},
+		{"depth beyond the chain picks the leftmost", "198.51.100.9:4000", []string{"203.0.113.7"}, "", 5, "203.0.113.7"},
+		{"trust all picks the leftmost", "198.51.100.9:4000", []string{"1.1.1.1, 203.0.113.7, 10.0.0.2"}, "", types.TrustAllProxies, "1.1.1.1"},
+		{"invalid entries are dropped", "198.51.100.9:4000", []string{"unknown, 203.0.113.7, not-an-ip"}, "", 1, "203.0.113.7"},
+		{"ports and brackets are stripped", "198.51.100.9:4000", []string{"[2001:db8::1]:443, 203.0.113.7:8080"}, "", 2, "2001:db8::1"},
+		{"IPv4-mapped addresses are folded", "198.51.100.9:4000", []string{"::ffff:203.0.113.7"}, "", 1, "203.0.113.7"},
+		{"repeats count as hops", "198.51.100.9:4000", []string{"203.0.113.7, 10.0.0.2, 10.0.0.2"}, "", 2, "10.0.0.2"},
+		{"peer repeated in the chain", "198.51.100.9:4000", []string{"203.0.113.7, 198.51.100.9"}, "", 1, "198.51.100.9"},
+		{"X-Real-IP without X-Forwarded-For", "198.51.100.9:4000", nil, "203.0.113.5", 1, "203.0.113.5"},
+		{"X-Forwarded-For wins over X-Real-IP", "198.51.100.9:4000", []string{"203.0.113.7"}, "203.0.113.5", 1, "203.0.113.7"},
+		{"non-IP peer", "proxy.internal:8080", nil, "", 1, "proxy.internal"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "http://example.com/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for _, v := range tt.xff {
+				req.Header.Add("X-Forwarded-For", v)
+			}
+			if tt.realIP != "" {
+				req.Header.Set("X-Real-IP", tt.realIP)
+			}
+			assert.Equal(t, tt.want, types.ResolveClientIP(req, tt.depth))
+		})
+	}
+}
+
+func TestClientIPConsistency(t *testing.T) {
+	servers := make([]*types.Server, 5)
+	for i := range servers {
+		u, _ := url.Parse(fmt.Sprintf("http://server%d:8080", i+1))
+		servers[i] = &types.Server{ID: fmt.Sprintf("server-%d", i+1), URL: u, Weight: 1, Healthy: true}
+	}
+	lb := balancer.NewIPHash()
+	for _, server := range servers {
+		require.NoError(t, lb.Add(server))
+	}
+
+	// selectFor picks the server the balancer gives a request coming straight from ip
+	selectFor := func(ip string) string {
+		req := httptest.NewRequest("GET", "http://example.com/", nil)
+		req.RemoteAddr = ip + ":5000"
+		server, err := lb.Select(context.Background(), req, servers)
+		require.NoError(t, err)
+		return server.ID
+	}
+
+	var seenIP, selected string
+	backend := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seenIP = types.ClientIP(r)
+		server, err := lb.Select(r.Context(), r, servers)
+		require.NoError(t, err)
+		selected = server.ID
+	})
+
+	cfg := types.ProxyConfig{}
+	cfg.RateLimit.Enabled = true
+	cfg.RateLimit.RPS = 1
+	cfg.RateLimit.Burst = 2
+	cfg.RateLimit.KeyBy = middleware.RateLimitKeyIP
+
+	logs := &accessLogRecorder{}
+	chain := middleware.NewChain(
+		middleware.ClientIP(2),
+		middleware.AccessLogging(logs),
+		middleware.RateLimit(cfg, nil, nil),
+	)
+	handler := chain.Then(backend)
+
+	send := func(remoteAddr, xff string) int {
+		req := httptest.NewRequest("GET", "http://example.com/", nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("X-Forwarded-For", xff)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// The same client through different edge proxies, with different spoofed
+	// entries in front, behind a CDN and a load balancer we trust
+	chains := []struct {
+		remoteAddr string
+		xff        string
+	}{
+		{"10.0.0.2:41000", "203.0.113.7, 172.16.0.1"},
+		{"10.0.0.3:41000", "6.6.6.6, 203.0.113.7:51234, 172.16.0.9"},
+	}
+
+	for _, c := range chains {
+		require.Equal(t, http.StatusOK, send(c.remoteAddr, c.xff))
+		assert.Equal(t, "203.0.113.7", seenIP, "handler")
+		assert.Equal(t, "203.0.113.7", logs.last(), "access log")
+		assert.Equal(t, selectFor("203.0.113.7"), selected, "balancer")
+	}
+
+	// Both requests came out of the one client's rate limit budget
+	assert.Equal(t, http.StatusTooManyRequests, send("10.0.0.4:41000", "7.7.7.7, 203.0.113.7, 172.16.0.2"))
+	assert.Equal(t, "203.0.113.7", logs.last())
+
+	// Another client behind the same proxies has its own budget
+	assert.Equal(t, http.StatusOK, send("10.0.0.2:41000", "203.0.113.8, 172.16.0.1"))
+	assert.Equal(t, "203.0.113.8", logs.last())
+}
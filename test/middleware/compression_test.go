@@ -0,0 +1,171 @@
+package middleware_test
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"discobox/internal/middleware"
+	"discobox/internal/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func compressionConfig() types.ProxyConfig {
+	cfg := types.ProxyConfig{}
+	cfg.Middleware.Compression.Enabled = true
+	cfg.Middleware.Compression.Level = 5
+	cfg.Middleware.Compression.Types = []string{"text/plain", "application/json"}
+	cfg.Middleware.Compression.Algorithms = []string{"gzip"}
+	cfg.Middleware.Compression.MinSize = 1024
+	return cfg
+}
+
+// serve runs a request with the given headers through the compression
+// middleware wrapping handler
+func serve(t *testing.T, handler http.HandlerFunc, headers map[string]string) *http.Response {
+	t.Helper()
+
+	h := middleware.Compression(compressionConfig())(handler)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec.Result()
+}
+
+func readBody(t *testing.T, resp *http.Response) string {
+	t.Helper()
+
+	var reader io.Reader = resp.Body
+	if resp.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(resp.Body)
+		require.NoError(t, err)
+		reader = gz
+	}
+
+	body, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	return string(body)
+}
+
+func TestCompressionSmallBody(t *testing.T) {
+	resp := serve(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("tiny"))
+	}, nil)
+
+	assert.Empty(t, resp.Header.Get("Content-Encoding"))
+	assert.Equal(t, "tiny", readBody(t, resp))
+}
+
+func TestCompressionLargeBody(t *testing.T) {
+	large := strings.Repeat("compress me ", 1000)
+
+	t.Run("unknown length", func(t *testing.T) {
+		resp := serve(t, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			// Written in chunks smaller than the threshold
+			for i := 0; i < len(large); i += 100 {
+				w.Write([]byte(large[i:min(i+100, len(large))]))
+			}
+		}, nil)
+
+		assert.Equal(t, "gzip", resp.Header.Get("Content-Encoding"))
+		assert.Equal(t, "Accept-Encoding", resp.Header.Get("Vary"))
+		assert.Equal(t, large, readBody(t, resp))
+	})
+
+	t.Run("declared length", func(t *testing.T) {
+		resp := serve(t, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Content-Length", strconv.Itoa(len(large)))
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(large))
+		}, nil)
+
+		assert.Equal(t, "gzip", resp.Header.Get("Content-Encoding"))
+		assert.Empty(t, resp.Header.Get("Content-Length"))
+		assert.Equal(t, large, readBody(t, resp))
+	})
+
+	t.Run("not a compressible type", func(t *testing.T) {
+		resp := serve(t, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte(large))
+		}, nil)
+
+		assert.Empty(t, resp.Header.Get("Content-Encoding"))
+		assert.Equal(t, large, readBody(t, resp))
+	})
+}
+
+func TestCompressionSkipsRanges(t *testing.T) {
+	large := strings.Repeat("0123456789", 500)
+
+	t.Run("range request", func(t *testing.T) {
+		resp := serve(t, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/plain")
+			w.Header().Set("Content-Range", "bytes 0-1999/5000")
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write([]byte(large[:2000]))
+		}, map[string]string{"Range": "bytes=0-1999"})
+
+		assert.Equal(t, http.StatusPartialContent, resp.StatusCode)
+		assert.Empty(t, resp.Header.Get("Content-Encoding"))
+		assert.Equal(t, large[:2000], readBody(t, resp))
+	})
+
+	t.Run("partial content response", func(t *testing.T) {
+		resp := serve(t, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/plain")
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write([]byte(large[:2000]))
+		}, nil)
+
+		assert.Equal(t, http.StatusPartialContent, resp.StatusCode)
+		assert.Empty(t, resp.Header.Get("Content-Encoding"))
+		assert.Equal(t, large[:2000], readBody(t, resp))
+	})
+}
+
+func TestCompressionSkipsEncodedBodies(t *testing.T) {
+	large := strings.Repeat("already encoded ", 200)
+
+	resp := serve(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("Content-Encoding", "br")
+		w.Write([]byte(large))
+	}, nil)
+
+	assert.Equal(t, "br", resp.Header.Get("Content-Encoding"))
+	assert.Equal(t, large, readBody(t, resp))
+}
+
+func TestCompressionSkipsHead(t *testing.T) {
+	h := middleware.Compression(compressionConfig())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("Content-Length", "5000")
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest("HEAD", "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Header().Get("Content-Encoding"))
+	assert.Equal(t, "5000", rec.Header().Get("Content-Length"))
+	assert.Empty(t, rec.Body.String())
+}
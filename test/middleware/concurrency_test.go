@@ -0,0 +1,138 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"discobox/internal/middleware"
+	"discobox/internal/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingHandler holds requests to /slow until release is closed and
+// reports each one on entered
+type blockingHandler struct {
+	entered chan struct{}
+	release chan struct{}
+}
+
+func newBlockingHandler() *blockingHandler {
+	return &blockingHandler{
+		entered: make(chan struct{}, 10),
+		release: make(chan struct{}),
+	}
+}
+
+func (h *blockingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.entered <- struct{}{}
+	<-h.release
+	w.WriteHeader(http.StatusOK)
+}
+
+// serveAsync sends a request to handler and delivers the status code
+func serveAsync(handler http.Handler, path string) <-chan int {
+	code := make(chan int, 1)
+	go func() {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", "http://example.com"+path, nil))
+		code <- rec.Code
+	}()
+	return code
+}
+
+func newConcurrencyRoutes(queueTimeout string) *prefixRouter {
+	return &prefixRouter{routes: []*types.Route{
+		{
+			ID:         "fragile",
+			PathPrefix: "/fragile",
+			// Metadata decoded from JSON
+			Metadata: map[string]any{
+				"max_concurrent": float64(1),
+				"queue_timeout":  queueTimeout,
+			},
+		},
+		{ID: "other", PathPrefix: "/"},
+	}}
+}
+
+func TestRouteConcurrency(t *testing.T) {
+	t.Run("requests under the limit acquire a slot", func(t *testing.T) {
+		backend := newBlockingHandler()
+		close(backend.release)
+		handler := middleware.RouteConcurrency(newConcurrencyRoutes("1s"))(backend)
+
+		for i := 0; i < 3; i++ {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest("GET", "http://example.com/fragile", nil))
+			assert.Equal(t, http.StatusOK, rec.Code)
+		}
+	})
+
+	t.Run("queued requests proceed when a slot frees up", func(t *testing.T) {
+		backend := newBlockingHandler()
+		handler := middleware.RouteConcurrency(newConcurrencyRoutes("5s"))(backend)
+
+		first := serveAsync(handler, "/fragile")
+		<-backend.entered
+
+		second := serveAsync(handler, "/fragile")
+		select {
+		case <-backend.entered:
+			t.Fatal("second request was not queued")
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		// Other routes are not limited
+		unlimited := serveAsync(handler, "/other")
+		<-backend.entered
+
+		close(backend.release)
+		assert.Equal(t, http.StatusOK, <-first)
+		assert.Equal(t, http.StatusOK, <-second)
+		assert.Equal(t, http.StatusOK, <-unlimited)
+	})
+
+	t.Run("requests are rejected after the queue timeout", func(t *testing.T) {
+		backend := newBlockingHandler()
+		handler := middleware.RouteConcurrency(newConcurrencyRoutes("50ms"))(backend)
+
+		first := serveAsync(handler, "/fragile")
+		<-backend.entered
+
+		start := time.Now()
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", "http://example.com/fragile", nil))
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+		assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+
+		close(backend.release)
+		require.Equal(t, http.StatusOK, <-first)
+
+		// The slot is free again
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", "http://example.com/fragile", nil))
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("limits written as strings apply", func(t *testing.T) {
+		routes := newConcurrencyRoutes("50ms")
+		routes.routes[0].Metadata["max_concurrent"] = "1"
+
+		backend := newBlockingHandler()
+		handler := middleware.RouteConcurrency(routes)(backend)
+
+		first := serveAsync(handler, "/fragile")
+		<-backend.entered
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", "http://example.com/fragile", nil))
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+
+		close(backend.release)
+		assert.Equal(t, http.StatusOK, <-first)
+	})
+}
@@ -0,0 +1,166 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"discobox/internal/middleware"
+	"discobox/internal/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// prefixRouter matches routes by path prefix in order
+type prefixRouter struct {
+	routes []*types.Route
+}
+
+func (pr *prefixRouter) Match(req *http.Request) (*types.Route, error) {
+	for _, route := range pr.routes {
+		if strings.HasPrefix(req.URL.Path, route.PathPrefix) {
+			return route, nil
+		}
+	}
+	return nil, types.ErrRouteNotFound
+}
+
+func (pr *prefixRouter) AddRoute(route *types.Route) error    { return nil }
+func (pr *prefixRouter) RemoveRoute(routeID string) error     { return nil }
+func (pr *prefixRouter) UpdateRoute(route *types.Route) error { return nil }
+func (pr *prefixRouter) GetRoutes() ([]*types.Route, error)   { return pr.routes, nil }
+
+func TestRouteCORS(t *testing.T) {
+	cfg := types.ProxyConfig{}
+	cfg.Middleware.CORS.Enabled = true
+	cfg.Middleware.CORS.AllowedOrigins = []string{"https://global.example.com"}
+	cfg.Middleware.CORS.AllowedMethods = []string{"GET"}
+	cfg.Middleware.CORS.AllowedHeaders = []string{"Content-Type"}
+
+	routes := &prefixRouter{routes: []*types.Route{
+		{
+			ID:         "public",
+			PathPrefix: "/public",
+			// Metadata decoded from JSON
+			Metadata: map[string]any{
+				"cors": map[string]any{
+					"allowed_origins":   []any{"https://app.example.com", "https://partner.example.com"},
+					"allowed_methods":   []any{"GET", "POST"},
+					"allow_credentials": true,
+					"max_age":           float64(600),
+				},
+			},
+		},
+		{
+			ID:         "internal",
+			PathPrefix: "/internal",
+			Metadata: map[string]any{
+				"cors": map[string]any{"enabled": false},
+			},
+		},
+		{
+			ID:         "api",
+			PathPrefix: "/api",
+			// Metadata set through the API, where values are strings
+			Metadata: map[string]any{
+				"cors": `{"allowed_origins": ["https://client.example.com"], "max_age": 60}`,
+			},
+		},
+		{ID: "default", PathPrefix: "/"},
+	}}
+
+	var reached int
+	handler := middleware.RouteCORS(cfg, routes)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached++
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	send := func(method, path, origin string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "http://proxy.example.com"+path, nil)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("route allowlist replaces the global one", func(t *testing.T) {
+		rec := send("GET", "/public/items", "https://app.example.com")
+		assert.Equal(t, "https://app.example.com", rec.Header().Get("Access-Control-Allow-Origin"))
+		assert.Equal(t, "true", rec.Header().Get("Access-Control-Allow-Credentials"))
+
+		rec = send("GET", "/public/items", "https://global.example.com")
+		assert.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"))
+	})
+
+	t.Run("preflight uses the route policy", func(t *testing.T) {
+		before := reached
+		rec := send("OPTIONS", "/public/items", "https://partner.example.com")
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		assert.Equal(t, "GET, POST", rec.Header().Get("Access-Control-Allow-Methods"))
+		// Not overridden, so taken from the global policy
+		assert.Equal(t, "Content-Type", rec.Header().Get("Access-Control-Allow-Headers"))
+		assert.Equal(t, "600", rec.Header().Get("Access-Control-Max-Age"))
+		assert.Equal(t, before, reached, "preflight should not reach the backend")
+	})
+
+	t.Run("routes without overrides use the global policy", func(t *testing.T) {
+		rec := send("GET", "/other", "https://global.example.com")
+		assert.Equal(t, "https://global.example.com", rec.Header().Get("Access-Control-Allow-Origin"))
+		assert.Empty(t, rec.Header().Get("Access-Control-Allow-Credentials"))
+
+		rec = send("GET", "/other", "https://app.example.com")
+		assert.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"))
+	})
+
+	t.Run("disabled route gets no CORS headers", func(t *testing.T) {
+		rec := send("GET", "/internal/status", "https://global.example.com")
+		assert.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"))
+
+		// Preflight is passed on rather than answered
+		before := reached
+		rec = send("OPTIONS", "/internal/status", "https://global.example.com")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, before+1, reached)
+	})
+
+	t.Run("JSON string overrides", func(t *testing.T) {
+		rec := send("OPTIONS", "/api/items", "https://client.example.com")
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		assert.Equal(t, "https://client.example.com", rec.Header().Get("Access-Control-Allow-Origin"))
+		assert.Equal(t, "60", rec.Header().Get("Access-Control-Max-Age"))
+
+		rec = send("GET", "/api/items", "https://global.example.com")
+		assert.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"))
+	})
+
+	t.Run("reloaded route gets its new policy", func(t *testing.T) {
+		reloaded := *routes.routes[2]
+		reloaded.Metadata = map[string]any{"cors": `{"allowed_origins": ["https://new-client.example.com"]}`}
+		old := routes.routes[2]
+		routes.routes[2] = &reloaded
+		t.Cleanup(func() { routes.routes[2] = old })
+
+		rec := send("GET", "/api/items", "https://new-client.example.com")
+		assert.Equal(t, "https://new-client.example.com", rec.Header().Get("Access-Control-Allow-Origin"))
+	})
+
+	t.Run("route override applies when the global policy is off", func(t *testing.T) {
+		off := types.ProxyConfig{}
+		h := middleware.RouteCORS(off, routes)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+		req := httptest.NewRequest("GET", "/public/items", nil)
+		req.Header.Set("Origin", "https://app.example.com")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		assert.Equal(t, "https://app.example.com", rec.Header().Get("Access-Control-Allow-Origin"))
+
+		req = httptest.NewRequest("GET", "/other", nil)
+		req.Header.Set("Origin", "https://app.example.com")
+		rec = httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		assert.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"))
+	})
+}
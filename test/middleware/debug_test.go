@@ -0,0 +1,198 @@
+package middleware_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"discobox/internal/middleware"
+	"discobox/internal/proxy"
+	"discobox/internal/storage"
+	"discobox/internal/types"
+	"discobox/pkg/proxytest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newDebugHandler proxies to a single backend through debug headers and two
+// global middlewares, one of which the /plain route disables
+func newDebugHandler(t *testing.T, adminOnly bool) (http.Handler, types.Storage) {
+	ctx := context.Background()
+	store := storage.NewMemory()
+	t.Cleanup(func() { store.Close() })
+
+	require.NoError(t, store.CreateService(ctx, &types.Service{ID: "api", Endpoints: []string{"http://api"}, Active: true}))
+	routes := []*types.Route{
+		{ID: "plain", PathPrefix: "/plain", ServiceID: "api", Priority: 10, DisabledMiddlewares: []string{middleware.NameCustomHeaders}},
+		{ID: "app", PathPrefix: "/", ServiceID: "api"},
+	}
+	for _, route := range routes {
+		require.NoError(t, store.CreateRoute(ctx, route))
+	}
+
+	h := proxytest.NewHarness(store, proxy.Options{})
+	t.Cleanup(func() { h.Close() })
+	h.Backend("http://api", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(middleware.DebugKeyHeader) != "" {
+			http.Error(w, "debug key leaked to the backend", http.StatusBadRequest)
+			return
+		}
+		if r.URL.Query().Has("spoof") {
+			w.Header().Set(middleware.DebugRouteHeader, "spoofed")
+			w.Header().Set(middleware.DebugBackendHeader, "spoofed")
+		}
+		w.Write([]byte("ok"))
+	}))
+
+	var cfg types.ProxyConfig
+	cfg.Debug.Enabled = true
+	cfg.Debug.AdminOnly = adminOnly
+
+	chain := middleware.NewChain(
+		middleware.DebugHeaders(cfg, store),
+		middleware.RouteDisabled(h.Router()),
+		middleware.Disableable(middleware.NameSecurityHeaders, middleware.SecurityHeaders()),
+		middleware.Disableable(middleware.NameCustomHeaders, middleware.CustomHeaders(map[string]string{"X-Custom": "yes"})),
+	)
+	return chain.Then(h.Proxy()), store
+}
+
+func debugGet(handler http.Handler, path string, headers map[string]string) *http.Response {
+	req := httptest.NewRequest("GET", "http://example.com"+path, nil)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec.Result()
+}
+
+func TestDebugHeaders(t *testing.T) {
+	handler, _ := newDebugHandler(t, false)
+
+	t.Run("requested", func(t *testing.T) {
+		resp := debugGet(handler, "/app", map[string]string{middleware.DebugRequestHeader: "1"})
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		assert.Equal(t, "app", resp.Header.Get(middleware.DebugRouteHeader))
+		assert.Equal(t, "api-0", resp.Header.Get(middleware.DebugBackendHeader))
+		assert.Equal(t, "security_headers, custom_headers", resp.Header.Get(middleware.DebugMiddlewaresHeader))
+
+		elapsed, err := time.ParseDuration(resp.Header.Get(middleware.DebugTimeHeader))
+		require.NoError(t, err)
+		assert.Greater(t, elapsed, time.Duration(0))
+	})
+
+	t.Run("disabled middleware is left out", func(t *testing.T) {
+		resp := debugGet(handler, "/plain", map[string]string{middleware.DebugRequestHeader: "1"})
+		assert.Equal(t, "plain", resp.Header.Get(middleware.DebugRouteHeader))
+		assert.Equal(t, "security_headers", resp.Header.Get(middleware.DebugMiddlewaresHeader))
+	})
+
+	t.Run("not requested", func(t *testing.T) {
+		for _, value := range []string{"", "0", "true"} {
+			resp := debugGet(handler, "/app", map[string]string{middleware.DebugRequestHeader: value})
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Empty(t, resp.Header.Get(middleware.DebugRouteHeader))
+			assert.Empty(t, resp.Header.Get(middleware.DebugBackendHeader))
+			assert.Empty(t, resp.Header.Get(middleware.DebugMiddlewaresHeader))
+			assert.Empty(t, resp.Header.Get(middleware.DebugTimeHeader))
+		}
+	})
+
+	t.Run("backend debug headers are dropped", func(t *testing.T) {
+		resp := debugGet(handler, "/app?spoof", nil)
+		assert.Empty(t, resp.Header.Get(middleware.DebugRouteHeader))
+		assert.Empty(t, resp.Header.Get(middleware.DebugBackendHeader))
+
+		resp = debugGet(handler, "/app?spoof", map[string]string{middleware.DebugRequestHeader: "1"})
+		assert.Equal(t, "app", resp.Header.Get(middleware.DebugRouteHeader))
+		assert.Equal(t, "api-0", resp.Header.Get(middleware.DebugBackendHeader))
+	})
+
+	t.Run("errors carry what is known", func(t *testing.T) {
+		ctx := context.Background()
+		store := storage.NewMemory()
+		t.Cleanup(func() { store.Close() })
+		require.NoError(t, store.CreateService(ctx, &types.Service{ID: "off", Endpoints: []string{"http://off"}}))
+		require.NoError(t, store.CreateRoute(ctx, &types.Route{ID: "off", PathPrefix: "/", ServiceID: "off"}))
+
+		h := proxytest.NewHarness(store, proxy.Options{})
+		t.Cleanup(func() { h.Close() })
+
+		var cfg types.ProxyConfig
+		cfg.Debug.Enabled = true
+		resp := debugGet(middleware.DebugHeaders(cfg, store)(h.Proxy()), "/", map[string]string{middleware.DebugRequestHeader: "1"})
+		assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+		assert.Equal(t, "off", resp.Header.Get(middleware.DebugRouteHeader))
+		assert.Empty(t, resp.Header.Get(middleware.DebugBackendHeader))
+		assert.NotEmpty(t, resp.Header.Get(middleware.DebugTimeHeader))
+	})
+}
+
+func TestDebugHeadersAdminOnly(t *testing.T) {
+	handler, store := newDebugHandler(t, true)
+	ctx := context.Background()
+
+	for _, user := range []*types.User{
+		{ID: "admin", Username: "admin", Email: "admin@example.com", IsAdmin: true, Active: true},
+		{ID: "alice", Username: "alice", Email: "alice@example.com", Active: true},
+	} {
+		require.NoError(t, store.CreateUser(ctx, user))
+		require.NoError(t, store.CreateAPIKey(ctx, &types.APIKey{Key: user.ID + "-key", UserID: user.ID, Name: "debug", Active: true}))
+	}
+
+	cases := []struct {
+		name   string
+		key    string
+		header string // Defaults to X-Discobox-Debug-Key
+		want   bool
+	}{
+		{name: "admin key", key: "admin-key", want: true},
+		{name: "user key", key: "alice-key"},
+		{name: "unknown key", key: "nobody-key"},
+		{name: "no key"},
+		{name: "admin key in X-API-Key", key: "admin-key", header: "X-API-Key"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			headers := map[string]string{middleware.DebugRequestHeader: "1"}
+			if tc.key != "" {
+				header := tc.header
+				if header == "" {
+					header = middleware.DebugKeyHeader
+				}
+				headers[header] = tc.key
+			}
+			resp := debugGet(handler, "/app", headers)
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+
+			if tc.want {
+				assert.Equal(t, "app", resp.Header.Get(middleware.DebugRouteHeader))
+			} else {
+				assert.Empty(t, resp.Header.Get(middleware.DebugRouteHeader))
+				assert.Empty(t, resp.Header.Get(middleware.DebugTimeHeader))
+			}
+		})
+	}
+}
+
+func TestDebugHeadersAdminKeyReused(t *testing.T) {
+	handler, store := newDebugHandler(t, true)
+	ctx := context.Background()
+
+	require.NoError(t, store.CreateUser(ctx, &types.User{ID: "admin", Username: "admin", Email: "admin@example.com", IsAdmin: true, Active: true}))
+	require.NoError(t, store.CreateAPIKey(ctx, &types.APIKey{Key: "admin-key", UserID: "admin", Name: "debug", Active: true}))
+
+	headers := map[string]string{middleware.DebugRequestHeader: "1", middleware.DebugKeyHeader: "admin-key"}
+	resp := debugGet(handler, "/app", headers)
+	require.Equal(t, "app", resp.Header.Get(middleware.DebugRouteHeader))
+
+	// The check is reused rather than repeated against storage
+	require.NoError(t, store.RevokeAPIKey(ctx, "admin-key"))
+	resp = debugGet(handler, "/app", headers)
+	assert.Equal(t, "app", resp.Header.Get(middleware.DebugRouteHeader))
+}
@@ -0,0 +1,104 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"discobox/internal/middleware"
+	"discobox/internal/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouteDisabledMiddlewares(t *testing.T) {
+	routes := &prefixRouter{routes: []*types.Route{
+		{ID: "metrics", PathPrefix: "/metrics", DisabledMiddlewares: []string{middleware.NameCompression}},
+		{ID: "other", PathPrefix: "/other", DisabledMiddlewares: []string{middleware.NameRateLimit}},
+		{ID: "app", PathPrefix: "/"},
+	}}
+
+	body := strings.Repeat("compressible ", 200)
+	backend := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(body))
+	})
+
+	chain := middleware.NewChain(
+		middleware.RouteDisabled(routes),
+		middleware.Disableable(middleware.NameCompression, middleware.Compression(compressionConfig())),
+	)
+	handler := chain.Then(backend)
+
+	get := func(path string) *http.Response {
+		req := httptest.NewRequest("GET", "http://example.com"+path, nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Result()
+	}
+
+	t.Run("route that disables compression is served uncompressed", func(t *testing.T) {
+		resp := get("/metrics")
+		assert.Empty(t, resp.Header.Get("Content-Encoding"))
+		assert.Equal(t, body, readBody(t, resp))
+	})
+
+	t.Run("other routes keep compression", func(t *testing.T) {
+		resp := get("/app")
+		assert.Equal(t, "gzip", resp.Header.Get("Content-Encoding"))
+		assert.Equal(t, body, readBody(t, resp))
+	})
+
+	t.Run("disabling other middleware leaves compression on", func(t *testing.T) {
+		resp := get("/other")
+		assert.Equal(t, "gzip", resp.Header.Get("Content-Encoding"))
+	})
+}
+
+// countingRouter counts the matches made through it
+type countingRouter struct {
+	prefixRouter
+	matches atomic.Int32
+}
+
+func (cr *countingRouter) Match(req *http.Request) (*types.Route, error) {
+	cr.matches.Add(1)
+	return cr.prefixRouter.Match(req)
+}
+
+func TestRouteMatchedOnce(t *testing.T) {
+	routes := &countingRouter{prefixRouter: prefixRouter{routes: []*types.Route{
+		{ID: "app", PathPrefix: "/", Metadata: map[string]any{
+			middleware.RouteMetadataMaxConcurrent: 2,
+			middleware.RouteMetadataRateLimitCost: 1,
+		}},
+	}}}
+
+	cfg := types.ProxyConfig{}
+	cfg.RateLimit.RPS = 100
+	cfg.RateLimit.Burst = 10
+	cfg.RateLimit.KeyBy = middleware.RateLimitKeyRoute
+	cfg.Middleware.CORS.Enabled = true
+	cfg.Middleware.CORS.AllowedOrigins = []string{"https://example.com"}
+
+	chain := middleware.NewChain(
+		middleware.RouteDisabled(routes),
+		middleware.Disableable(middleware.NameCORS, middleware.RouteCORS(cfg, routes)),
+		middleware.Disableable(middleware.NameRateLimit, middleware.RateLimit(cfg, routes, nil)),
+		middleware.Disableable(middleware.NameConcurrency, middleware.RouteConcurrency(routes)),
+	)
+	handler := chain.Then(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest("GET", "http://example.com/app", nil)
+	req.Header.Set("Origin", "https://example.com")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, int32(1), routes.matches.Load())
+}
@@ -0,0 +1,91 @@
+package middleware_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"discobox/internal/middleware"
+	"discobox/internal/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeaderLimits(t *testing.T) {
+	cfg := types.ProxyConfig{}
+	cfg.Middleware.HeaderLimits.MaxCount = 10
+	cfg.Middleware.HeaderLimits.MaxLength = 64
+
+	var reached int
+	handler := middleware.HeaderLimits(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached++
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	send := func(header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "http://example.com/", nil)
+		req.Header = header
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("requests within the limits pass", func(t *testing.T) {
+		header := http.Header{}
+		for i := 0; i < 10; i++ {
+			header.Set(fmt.Sprintf("X-Header-%d", i), "value")
+		}
+
+		before := reached
+		rec := send(header)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, before+1, reached)
+	})
+
+	t.Run("too many headers", func(t *testing.T) {
+		header := http.Header{}
+		for i := 0; i < 11; i++ {
+			header.Set(fmt.Sprintf("X-Header-%d", i), "value")
+		}
+
+		before := reached
+		rec := send(header)
+		assert.Equal(t, http.StatusRequestHeaderFieldsTooLarge, rec.Code)
+		assert.Equal(t, before, reached)
+	})
+
+	t.Run("repeated values count separately", func(t *testing.T) {
+		header := http.Header{}
+		for i := 0; i < 11; i++ {
+			header.Add("X-Repeated", "value")
+		}
+
+		rec := send(header)
+		assert.Equal(t, http.StatusRequestHeaderFieldsTooLarge, rec.Code)
+	})
+
+	t.Run("oversized header", func(t *testing.T) {
+		header := http.Header{}
+		header.Set("X-Big", strings.Repeat("a", 60))
+
+		before := reached
+		rec := send(header)
+		assert.Equal(t, http.StatusRequestHeaderFieldsTooLarge, rec.Code)
+		assert.Equal(t, before, reached)
+	})
+
+	t.Run("zero disables a limit", func(t *testing.T) {
+		off := types.ProxyConfig{}
+		h := middleware.HeaderLimits(off)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+		req := httptest.NewRequest("GET", "http://example.com/", nil)
+		for i := 0; i < 200; i++ {
+			req.Header.Set(fmt.Sprintf("X-Header-%d", i), strings.Repeat("a", 1024))
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+}
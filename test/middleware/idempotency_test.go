@@ -0,0 +1,168 @@
+package middleware_test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"discobox/internal/middleware"
+	"discobox/internal/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func idempotencyConfig() types.ProxyConfig {
+	cfg := types.ProxyConfig{}
+	cfg.Middleware.Idempotency.Enabled = true
+	cfg.Middleware.Idempotency.TTL = time.Hour
+	return cfg
+}
+
+func post(h http.Handler, path, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", path, strings.NewReader(body))
+	if key != "" {
+		req.Header.Set("Idempotency-Key", key)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestIdempotencyReplay(t *testing.T) {
+	var hits atomic.Int32
+	backend := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := hits.Add(1)
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Order", string(body))
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, "order %d", n)
+	})
+	h := middleware.Idempotency(idempotencyConfig())(backend)
+
+	first := post(h, "/orders", "key-1", `{"item":1}`)
+	assert.Equal(t, http.StatusCreated, first.Code)
+	assert.Equal(t, "order 1", first.Body.String())
+
+	replay := post(h, "/orders", "key-1", `{"item":1}`)
+	assert.Equal(t, http.StatusCreated, replay.Code)
+	assert.Equal(t, "order 1", replay.Body.String())
+	assert.Equal(t, `{"item":1}`, replay.Header().Get("X-Order"))
+	assert.Equal(t, "true", replay.Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, int32(1), hits.Load(), "backend should be hit once")
+
+	t.Run("different body conflicts", func(t *testing.T) {
+		rec := post(h, "/orders", "key-1", `{"item":2}`)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		assert.Equal(t, int32(1), hits.Load())
+	})
+
+	t.Run("keys are scoped to the route", func(t *testing.T) {
+		rec := post(h, "/invoices", "key-1", `{"item":1}`)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.Empty(t, rec.Header().Get("Idempotent-Replayed"))
+		assert.Equal(t, int32(2), hits.Load())
+	})
+
+	t.Run("requests without a key are not cached", func(t *testing.T) {
+		before := hits.Load()
+		post(h, "/orders", "", `{"item":1}`)
+		post(h, "/orders", "", `{"item":1}`)
+		assert.Equal(t, before+2, hits.Load())
+	})
+}
+
+func TestIdempotencyServerErrorsNotCached(t *testing.T) {
+	var hits atomic.Int32
+	backend := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hits.Add(1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	h := middleware.Idempotency(idempotencyConfig())(backend)
+
+	assert.Equal(t, http.StatusBadGateway, post(h, "/orders", "key-1", "body").Code)
+	assert.Equal(t, http.StatusOK, post(h, "/orders", "key-1", "body").Code)
+	assert.Equal(t, http.StatusOK, post(h, "/orders", "key-1", "body").Code)
+	assert.Equal(t, int32(2), hits.Load())
+}
+
+func TestIdempotencyConcurrent(t *testing.T) {
+	var hits atomic.Int32
+	started := make(chan struct{})
+	release := make(chan struct{})
+	backend := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		close(started)
+		<-release
+		w.Write([]byte("created"))
+	})
+	h := middleware.Idempotency(idempotencyConfig())(backend)
+
+	var wg sync.WaitGroup
+	var original *httptest.ResponseRecorder
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		original = post(h, "/orders", "key-1", "body")
+	}()
+	<-started
+
+	// A concurrent request with a different body is rejected immediately
+	assert.Equal(t, http.StatusConflict, post(h, "/orders", "key-1", "other").Code)
+
+	// One with the same body waits for the original and gets its response
+	var retry *httptest.ResponseRecorder
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		retry = post(h, "/orders", "key-1", "body")
+	}()
+
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, "created", original.Body.String())
+	assert.Equal(t, "created", retry.Body.String())
+	assert.Equal(t, int32(1), hits.Load())
+}
+
+func TestIdempotencyReplayInsideCompression(t *testing.T) {
+	payload := strings.Repeat("created ", 512)
+	backend := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, payload)
+	})
+	// The order the server chains them in
+	h := middleware.Compression(compressionConfig())(middleware.Idempotency(idempotencyConfig())(backend))
+
+	send := func(encoding string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/orders", strings.NewReader(`{"item":1}`))
+		req.Header.Set("Idempotency-Key", "key-1")
+		if encoding != "" {
+			req.Header.Set("Accept-Encoding", encoding)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	first := send("gzip")
+	assert.Equal(t, http.StatusCreated, first.Code)
+	assert.Equal(t, "gzip", first.Header().Get("Content-Encoding"))
+
+	// A retry from a client without gzip gets the response it can read
+	replay := send("")
+	assert.Equal(t, http.StatusCreated, replay.Code)
+	assert.Equal(t, "true", replay.Header().Get("Idempotent-Replayed"))
+	assert.Empty(t, replay.Header().Get("Content-Encoding"))
+	assert.Equal(t, payload, replay.Body.String())
+}
@@ -0,0 +1,62 @@
+package middleware_test
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"discobox/internal/middleware"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// promhttp negotiates the encoding itself; this guards against losing that
+func TestMetricsHandlerEncoding(t *testing.T) {
+	handler := middleware.MetricsHandler()
+
+	scrape := func(acceptEncoding string) (*httptest.ResponseRecorder, string) {
+		req := httptest.NewRequest("GET", "/metrics", nil)
+		if acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		require.Equal(t, 200, rec.Code)
+
+		body := io.Reader(rec.Body)
+		if rec.Header().Get("Content-Encoding") == "gzip" {
+			gz, err := gzip.NewReader(rec.Body)
+			require.NoError(t, err)
+			defer gz.Close()
+			body = gz
+		}
+		data, err := io.ReadAll(body)
+		require.NoError(t, err)
+		return rec, string(data)
+	}
+
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		wantEncoding   string
+	}{
+		{name: "no Accept-Encoding", acceptEncoding: "", wantEncoding: ""},
+		{name: "gzip accepted", acceptEncoding: "gzip", wantEncoding: "gzip"},
+		{name: "gzip among others", acceptEncoding: "br;q=1.0, gzip;q=0.8", wantEncoding: "gzip"},
+		{name: "only unsupported encodings", acceptEncoding: "br", wantEncoding: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec, body := scrape(tt.acceptEncoding)
+
+			assert.Equal(t, tt.wantEncoding, rec.Header().Get("Content-Encoding"))
+
+			// The exposition format is the same either way
+			assert.Contains(t, rec.Header().Get("Content-Type"), "text/plain")
+			assert.Contains(t, body, "# TYPE go_goroutines gauge")
+		})
+	}
+}
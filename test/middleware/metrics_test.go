@@ -0,0 +1,95 @@
+package middleware_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"discobox/internal/metrics"
+	"discobox/internal/middleware"
+	"discobox/internal/proxy"
+	"discobox/internal/storage"
+	"discobox/internal/types"
+	"discobox/pkg/proxytest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetricsTrafficByStatusClass(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemory()
+	t.Cleanup(func() { store.Close() })
+
+	require.NoError(t, store.CreateService(ctx, &types.Service{ID: "api", Endpoints: []string{"http://api"}, Active: true}))
+	require.NoError(t, store.CreateRoute(ctx, &types.Route{ID: "api", PathPrefix: "/", ServiceID: "api"}))
+
+	h := proxytest.NewHarness(store, proxy.Options{})
+	t.Cleanup(func() { h.Close() })
+
+	// The backend drains the body and answers with the status and number of
+	// bytes asked for in the query
+	h.Backend("http://api", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		status, _ := strconv.Atoi(r.URL.Query().Get("status"))
+		size, _ := strconv.Atoi(r.URL.Query().Get("size"))
+		w.WriteHeader(status)
+		w.Write([]byte(strings.Repeat("x", size)))
+	}))
+
+	handler := middleware.Metrics()(h.Proxy())
+
+	before := metrics.GlobalCollector.GetStats()
+
+	requests := []struct {
+		status int
+		in     int
+		out    int
+	}{
+		{status: 200, in: 0, out: 10},
+		{status: 201, in: 2048, out: 5000},
+		{status: 302, in: 0, out: 0},
+		{status: 404, in: 100, out: 20},
+		{status: 503, in: 300, out: 70},
+		{status: 500, in: 0, out: 1},
+	}
+
+	var wantIn, wantOut uint64
+	for _, req := range requests {
+		var body io.Reader
+		if req.in > 0 {
+			body = strings.NewReader(strings.Repeat("y", req.in))
+		}
+		url := "http://example.com/?status=" + strconv.Itoa(req.status) + "&size=" + strconv.Itoa(req.out)
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("POST", url, body))
+		require.Equal(t, req.status, rec.Code)
+		require.Equal(t, req.out, rec.Body.Len())
+
+		wantIn += uint64(req.in)
+		wantOut += uint64(req.out)
+	}
+
+	after := metrics.GlobalCollector.GetStats()
+
+	assert.Equal(t, wantIn, after.BytesIn-before.BytesIn)
+	assert.Equal(t, wantOut, after.BytesOut-before.BytesOut)
+
+	classes := map[string]uint64{"1xx": 0, "2xx": 2, "3xx": 1, "4xx": 1, "5xx": 2}
+	for class, want := range classes {
+		assert.Equal(t, want, after.ByStatus[class]-before.ByStatus[class], class)
+	}
+}
+
+func TestStatusClass(t *testing.T) {
+	assert.Equal(t, "1xx", metrics.StatusClass(101))
+	assert.Equal(t, "2xx", metrics.StatusClass(204))
+	assert.Equal(t, "5xx", metrics.StatusClass(599))
+	assert.Empty(t, metrics.StatusClass(600))
+	assert.Empty(t, metrics.StatusClass(0))
+}
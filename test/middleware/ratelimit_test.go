@@ -0,0 +1,227 @@
+package middleware_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+	"time"
+
+	"discobox/internal/middleware"
+	"discobox/internal/middleware/auth"
+	"discobox/internal/storage"
+	"discobox/internal/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newRateLimited returns a handler allowing burst requests per bucket; the
+// refill rate is low enough not to matter during a test. key-a and key-b
+// are valid API keys.
+func newRateLimited(t *testing.T, keyBy string, router types.Router) http.Handler {
+	ctx := context.Background()
+	store := storage.NewMemory()
+	t.Cleanup(func() { store.Close() })
+	require.NoError(t, store.CreateUser(ctx, &types.User{ID: "alice", Username: "alice", Email: "alice@example.com", Active: true}))
+	for _, key := range []string{"key-a", "key-b"} {
+		require.NoError(t, store.CreateAPIKey(ctx, &types.APIKey{Key: key, UserID: "alice", Name: key, Active: true}))
+	}
+
+	cfg := types.ProxyConfig{}
+	cfg.RateLimit.Enabled = true
+	cfg.RateLimit.RPS = 1
+	cfg.RateLimit.Burst = 2
+	cfg.RateLimit.KeyBy = keyBy
+
+	return middleware.RateLimit(cfg, router, store)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+}
+
+// sendFrom sends a request from remoteAddr with an optional API key
+func sendFrom(handler http.Handler, path, remoteAddr, apiKey string) int {
+	req := httptest.NewRequest("GET", "http://example.com"+path, nil)
+	req.RemoteAddr = remoteAddr
+	if apiKey != "" {
+		req.Header.Set("X-API-Key", apiKey)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func TestRateLimitByAPIKey(t *testing.T) {
+	t.Run("different keys get independent budgets", func(t *testing.T) {
+		handler := newRateLimited(t, "header:X-API-Key", nil)
+
+		for i := 0; i < 2; i++ {
+			assert.Equal(t, http.StatusOK, sendFrom(handler, "/", "10.0.0.1:1000", "key-a"))
+		}
+		assert.Equal(t, http.StatusTooManyRequests, sendFrom(handler, "/", "10.0.0.1:1000", "key-a"))
+
+		// Same client, different key
+		assert.Equal(t, http.StatusOK, sendFrom(handler, "/", "10.0.0.1:1000", "key-b"))
+	})
+
+	t.Run("the same key shares one budget across clients", func(t *testing.T) {
+		handler := newRateLimited(t, "header:X-API-Key", nil)
+
+		assert.Equal(t, http.StatusOK, sendFrom(handler, "/", "10.0.0.1:1000", "key-a"))
+		assert.Equal(t, http.StatusOK, sendFrom(handler, "/", "10.0.0.2:1000", "key-a"))
+		assert.Equal(t, http.StatusTooManyRequests, sendFrom(handler, "/", "10.0.0.3:1000", "key-a"))
+	})
+
+	t.Run("requests without a key fall back to their IP", func(t *testing.T) {
+		handler := newRateLimited(t, "header:X-API-Key", nil)
+
+		for i := 0; i < 2; i++ {
+			assert.Equal(t, http.StatusOK, sendFrom(handler, "/", "10.0.0.1:1000", ""))
+		}
+		assert.Equal(t, http.StatusTooManyRequests, sendFrom(handler, "/", "10.0.0.1:1000", ""))
+
+		assert.Equal(t, http.StatusOK, sendFrom(handler, "/", "10.0.0.2:1000", ""))
+		assert.Equal(t, http.StatusOK, sendFrom(handler, "/", "10.0.0.1:1000", "key-a"))
+	})
+
+	t.Run("made-up keys fall back to their IP", func(t *testing.T) {
+		handler := newRateLimited(t, "header:X-API-Key", nil)
+
+		assert.Equal(t, http.StatusOK, sendFrom(handler, "/", "10.0.0.1:1000", "made-up-1"))
+		assert.Equal(t, http.StatusOK, sendFrom(handler, "/", "10.0.0.1:1000", "made-up-2"))
+		assert.Equal(t, http.StatusTooManyRequests, sendFrom(handler, "/", "10.0.0.1:1000", "made-up-3"))
+
+		assert.Equal(t, http.StatusOK, sendFrom(handler, "/", "10.0.0.1:1000", "key-a"))
+	})
+
+	t.Run("authenticated users share their budget", func(t *testing.T) {
+		handler := newRateLimited(t, "header:X-Authenticated-User", nil)
+		send := func(remoteAddr, user string) int {
+			req := httptest.NewRequest("GET", "http://example.com/", nil)
+			req.RemoteAddr = remoteAddr
+			req = req.WithContext(auth.ContextWithIdentity(req.Context(), auth.Identity{User: user}))
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			return rec.Code
+		}
+
+		assert.Equal(t, http.StatusOK, send("10.0.0.1:1000", "bob"))
+		assert.Equal(t, http.StatusOK, send("10.0.0.2:1000", "bob"))
+		assert.Equal(t, http.StatusTooManyRequests, send("10.0.0.3:1000", "bob"))
+		assert.Equal(t, http.StatusOK, send("10.0.0.3:1000", "carol"))
+	})
+}
+
+func TestRateLimitByIP(t *testing.T) {
+	handler := newRateLimited(t, "", nil)
+
+	// Keys are ignored; every request from an IP shares its budget
+	assert.Equal(t, http.StatusOK, sendFrom(handler, "/", "10.0.0.1:1000", "key-a"))
+	assert.Equal(t, http.StatusOK, sendFrom(handler, "/", "10.0.0.1:2000", "key-b"))
+	assert.Equal(t, http.StatusTooManyRequests, sendFrom(handler, "/", "10.0.0.1:3000", "key-c"))
+
+	assert.Equal(t, http.StatusOK, sendFrom(handler, "/", "10.0.0.2:1000", ""))
+}
+
+func TestRateLimitByRoute(t *testing.T) {
+	routes := &prefixRouter{routes: []*types.Route{
+		{ID: "a", PathPrefix: "/a"},
+		{ID: "b", PathPrefix: "/b"},
+	}}
+	handler := newRateLimited(t, middleware.RateLimitKeyRoute, routes)
+
+	// All clients share the route's budget
+	assert.Equal(t, http.StatusOK, sendFrom(handler, "/a/1", "10.0.0.1:1000", ""))
+	assert.Equal(t, http.StatusOK, sendFrom(handler, "/a/2", "10.0.0.2:1000", ""))
+	assert.Equal(t, http.StatusTooManyRequests, sendFrom(handler, "/a/3", "10.0.0.3:1000", ""))
+
+	assert.Equal(t, http.StatusOK, sendFrom(handler, "/b", "10.0.0.1:1000", ""))
+
+	// Unrouted requests are limited per IP
+	assert.Equal(t, http.StatusOK, sendFrom(handler, "/other", "10.0.0.1:1000", ""))
+}
+
+func TestRateLimitCost(t *testing.T) {
+	routes := &prefixRouter{routes: []*types.Route{
+		// Metadata decoded from JSON
+		{ID: "search", PathPrefix: "/search", Metadata: map[string]any{"rate_limit_cost": float64(5)}},
+		{ID: "huge", PathPrefix: "/huge", Metadata: map[string]any{"rate_limit_cost": 11}},
+		// Metadata set through the API, which takes strings
+		{ID: "report", PathPrefix: "/report", Metadata: map[string]any{"rate_limit_cost": "5"}},
+		{ID: "items", PathPrefix: "/items"},
+	}}
+
+	cfg := types.ProxyConfig{}
+	cfg.RateLimit.Enabled = true
+	cfg.RateLimit.RPS = 1
+	cfg.RateLimit.Burst = 10
+	handler := middleware.RateLimit(cfg, routes, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	countAllowed := func(path, remoteAddr string) int {
+		allowed := 0
+		for i := 0; i < 20; i++ {
+			if sendFrom(handler, path, remoteAddr, "") == http.StatusOK {
+				allowed++
+			}
+		}
+		return allowed
+	}
+
+	t.Run("expensive routes exhaust the bucket sooner", func(t *testing.T) {
+		assert.Equal(t, 2, countAllowed("/search", "10.0.0.1:1000"))
+		assert.Equal(t, 10, countAllowed("/items", "10.0.0.2:1000"))
+		assert.Equal(t, 2, countAllowed("/report", "10.0.0.5:1000"))
+	})
+
+	t.Run("requests share one budget", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, sendFrom(handler, "/items", "10.0.0.3:1000", ""))
+		assert.Equal(t, http.StatusOK, sendFrom(handler, "/search", "10.0.0.3:1000", ""))
+
+		// 4 tokens left: not enough for a search, plenty for cheap requests
+		assert.Equal(t, http.StatusTooManyRequests, sendFrom(handler, "/search", "10.0.0.3:1000", ""))
+		assert.Equal(t, http.StatusOK, sendFrom(handler, "/items", "10.0.0.3:1000", ""))
+	})
+
+	t.Run("costs above the burst are always rejected", func(t *testing.T) {
+		assert.Equal(t, http.StatusTooManyRequests, sendFrom(handler, "/huge", "10.0.0.4:1000", ""))
+		assert.Equal(t, http.StatusOK, sendFrom(handler, "/items", "10.0.0.4:1000", ""))
+	})
+}
+
+func TestRateLimitWithStop(t *testing.T) {
+	cfg := types.ProxyConfig{}
+	cfg.RateLimit.Enabled = true
+	cfg.RateLimit.RPS = 1
+	cfg.RateLimit.Burst = 1
+
+	// Each limiter runs a cleanup goroutine until it is stopped, as chains
+	// rebuilt on reload are
+	before := runtime.NumGoroutine()
+	stops := make([]func(), 10)
+	for i := range stops {
+		_, stops[i] = middleware.RateLimitWithStop(cfg, nil, nil)
+	}
+	assert.GreaterOrEqual(t, runtime.NumGoroutine(), before+len(stops))
+
+	rateLimit, stop := middleware.RateLimitWithStop(cfg, nil, nil)
+	for _, stop := range stops {
+		stop()
+	}
+	stop()
+	// Polled here rather than with Eventually, whose own goroutine would count
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.LessOrEqual(t, runtime.NumGoroutine(), before, "cleanup goroutines are still running")
+
+	// A stopped limiter still limits requests already routed to it
+	handler := rateLimit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	assert.Equal(t, http.StatusOK, sendFrom(handler, "/", "10.0.0.1:1000", ""))
+	assert.Equal(t, http.StatusTooManyRequests, sendFrom(handler, "/", "10.0.0.1:1000", ""))
+}
@@ -0,0 +1,189 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"discobox/internal/middleware"
+	"discobox/internal/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newSheddingRoutes() *prefixRouter {
+	return &prefixRouter{routes: []*types.Route{
+		{
+			ID:         "checkout",
+			PathPrefix: "/checkout",
+			Metadata:   map[string]any{"qos_class": "high"},
+		},
+		{ID: "other", PathPrefix: "/"},
+	}}
+}
+
+// serveClass sends a request naming a QoS class in X-QoS-Class to handler
+// and delivers the status code
+func serveClass(handler http.Handler, path, class string) <-chan int {
+	code := make(chan int, 1)
+	go func() {
+		req := httptest.NewRequest("GET", "http://example.com"+path, nil)
+		if class != "" {
+			req.Header.Set("X-QoS-Class", class)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		code <- rec.Code
+	}()
+	return code
+}
+
+func TestLoadShedding(t *testing.T) {
+	t.Run("low priority is shed first under concurrency overload", func(t *testing.T) {
+		cfg := types.ProxyConfig{}
+		cfg.Middleware.LoadShedding.Enabled = true
+		cfg.Middleware.LoadShedding.MaxConcurrent = 2
+		cfg.Middleware.LoadShedding.Header = "X-QoS-Class"
+
+		backend := newBlockingHandler()
+		handler := middleware.LoadShedding(cfg, newSheddingRoutes())(backend)
+
+		// Below the limit low priority requests pass
+		var pending []<-chan int
+		for i := 0; i < 2; i++ {
+			pending = append(pending, serveClass(handler, "/analytics", "low"))
+			<-backend.entered
+		}
+
+		// Overloaded: low priority is rejected straight away
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "http://example.com/analytics", nil)
+		req.Header.Set("X-QoS-Class", "low")
+		req.Header.Set("Accept", "application/json")
+		handler.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+		assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+		assert.JSONEq(t, `{"error": "Server overloaded", "retry_after": 1}`, rec.Body.String())
+
+		// Normal and high priority requests still pass
+		pending = append(pending, serveClass(handler, "/analytics", ""))
+		<-backend.entered
+		pending = append(pending, serveClass(handler, "/checkout", ""))
+		<-backend.entered
+
+		// At twice the limit normal priority is shed too, but not high
+		assert.Equal(t, http.StatusServiceUnavailable, <-serveClass(handler, "/analytics", "normal"))
+		pending = append(pending, serveClass(handler, "/checkout", ""))
+		<-backend.entered
+
+		close(backend.release)
+		for _, code := range pending {
+			assert.Equal(t, http.StatusOK, <-code)
+		}
+
+		// Load is back down, so low priority passes again
+		assert.Equal(t, http.StatusOK, <-serveClass(handler, "/analytics", "low"))
+	})
+
+	t.Run("route metadata sets the class", func(t *testing.T) {
+		cfg := types.ProxyConfig{}
+		cfg.Middleware.LoadShedding.Enabled = true
+		cfg.Middleware.LoadShedding.MaxConcurrent = 1
+		cfg.Middleware.LoadShedding.Header = "X-QoS-Class"
+		cfg.Middleware.LoadShedding.DefaultClass = "low"
+
+		backend := newBlockingHandler()
+		handler := middleware.LoadShedding(cfg, newSheddingRoutes())(backend)
+
+		first := serveClass(handler, "/checkout", "")
+		<-backend.entered
+
+		// Unclassified requests take the default class
+		assert.Equal(t, http.StatusServiceUnavailable, <-serveClass(handler, "/search", ""))
+
+		// The route's class wins over the one the client names
+		checkout := serveClass(handler, "/checkout", "low")
+		<-backend.entered
+
+		close(backend.release)
+		assert.Equal(t, http.StatusOK, <-first)
+		assert.Equal(t, http.StatusOK, <-checkout)
+	})
+
+	t.Run("clients can't claim high priority", func(t *testing.T) {
+		cfg := types.ProxyConfig{}
+		cfg.Middleware.LoadShedding.Enabled = true
+		cfg.Middleware.LoadShedding.MaxConcurrent = 1
+		cfg.Middleware.LoadShedding.Header = "X-QoS-Class"
+
+		backend := newBlockingHandler()
+		handler := middleware.LoadShedding(cfg, newSheddingRoutes())(backend)
+
+		var pending []<-chan int
+		for i := 0; i < 2; i++ {
+			pending = append(pending, serveClass(handler, "/analytics", "normal"))
+			<-backend.entered
+		}
+
+		// At twice the limit a client naming high is shed like normal
+		for _, class := range []string{"high", "HIGH"} {
+			assert.Equal(t, http.StatusServiceUnavailable, <-serveClass(handler, "/analytics", class))
+		}
+
+		close(backend.release)
+		for _, code := range pending {
+			assert.Equal(t, http.StatusOK, <-code)
+		}
+	})
+
+	t.Run("low priority is shed while responses are slow", func(t *testing.T) {
+		cfg := types.ProxyConfig{}
+		cfg.Middleware.LoadShedding.Enabled = true
+		cfg.Middleware.LoadShedding.MaxLatency = 20 * time.Millisecond
+		cfg.Middleware.LoadShedding.Header = "X-QoS-Class"
+
+		delay := 50 * time.Millisecond
+		backend := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(delay)
+			w.WriteHeader(http.StatusOK)
+		})
+		handler := middleware.LoadShedding(cfg, newSheddingRoutes())(backend)
+
+		require.Equal(t, http.StatusOK, <-serveClass(handler, "/search", "normal"))
+
+		assert.Equal(t, http.StatusServiceUnavailable, <-serveClass(handler, "/analytics", "low"))
+		assert.Equal(t, http.StatusOK, <-serveClass(handler, "/search", "normal"))
+		assert.Equal(t, http.StatusOK, <-serveClass(handler, "/checkout", ""))
+
+		// Fast responses bring the average back under the limit
+		delay = 0
+		for i := 0; i < 20; i++ {
+			require.Equal(t, http.StatusOK, <-serveClass(handler, "/search", "normal"))
+		}
+		assert.Equal(t, http.StatusOK, <-serveClass(handler, "/analytics", "low"))
+	})
+
+	t.Run("routes are matched only under load", func(t *testing.T) {
+		cfg := types.ProxyConfig{}
+		cfg.Middleware.LoadShedding.Enabled = true
+		cfg.Middleware.LoadShedding.MaxConcurrent = 1
+
+		routes := &countingRouter{prefixRouter: *newSheddingRoutes()}
+		backend := newBlockingHandler()
+		handler := middleware.LoadShedding(cfg, routes)(backend)
+
+		first := serveClass(handler, "/checkout", "")
+		<-backend.entered
+		assert.Zero(t, routes.matches.Load())
+
+		second := serveClass(handler, "/checkout", "")
+		<-backend.entered
+		assert.Equal(t, int32(1), routes.matches.Load())
+
+		close(backend.release)
+		assert.Equal(t, http.StatusOK, <-first)
+		assert.Equal(t, http.StatusOK, <-second)
+	})
+}
@@ -0,0 +1,140 @@
+package middleware_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"discobox/internal/middleware"
+	"discobox/internal/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimeout(t *testing.T) {
+	const deadline = 50 * time.Millisecond
+	cfg := types.ProxyConfig{}
+	cfg.Middleware.RequestTimeout = deadline
+
+	routes := &prefixRouter{routes: []*types.Route{
+		{ID: "exports", PathPrefix: "/exports", DisabledMiddlewares: []string{middleware.NameTimeout}},
+		{ID: "app", PathPrefix: "/"},
+	}}
+
+	// slow takes three deadlines to answer, or gives up when its context ends
+	var slowCanceled = make(chan struct{}, 10)
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(3 * deadline):
+			w.Header().Set("X-Slow", "done")
+			w.Write([]byte("finished"))
+		case <-r.Context().Done():
+			slowCanceled <- struct{}{}
+		}
+	})
+
+	handler := middleware.NewChain(
+		middleware.RouteDisabled(routes),
+		middleware.Disableable(middleware.NameTimeout, middleware.Timeout(cfg)),
+	).Then(slow)
+
+	t.Run("fast handlers respond normally", func(t *testing.T) {
+		fast := middleware.Timeout(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Fast", "yes")
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte("created"))
+		}))
+
+		rec := httptest.NewRecorder()
+		fast.ServeHTTP(rec, httptest.NewRequest("POST", "http://example.com/items", nil))
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.Equal(t, "yes", rec.Header().Get("X-Fast"))
+		assert.Equal(t, "created", rec.Body.String())
+	})
+
+	t.Run("slow handler times out", func(t *testing.T) {
+		start := time.Now()
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", "http://example.com/report", nil))
+
+		assert.Equal(t, http.StatusGatewayTimeout, rec.Code)
+		assert.Empty(t, rec.Header().Get("X-Slow"))
+		assert.Equal(t, "Request timed out\n", rec.Body.String())
+		assert.Less(t, time.Since(start), 3*deadline)
+
+		select {
+		case <-slowCanceled:
+		case <-time.After(time.Second):
+			t.Fatal("handler context was not canceled")
+		}
+	})
+
+	t.Run("event streams are exempt", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "http://example.com/events", nil)
+		req.Header.Set("Accept", "text/event-stream")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "finished", rec.Body.String())
+	})
+
+	t.Run("routes can disable the timeout", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", "http://example.com/exports/all", nil))
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "done", rec.Header().Get("X-Slow"))
+	})
+
+	t.Run("started responses are aborted", func(t *testing.T) {
+		stalled := middleware.Timeout(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("partial"))
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+
+			// Writes after the deadline go nowhere
+			_, err := w.Write([]byte("late"))
+			assert.ErrorIs(t, err, middleware.ErrRequestTimeout)
+		}))
+		server := httptest.NewServer(stalled)
+		defer server.Close()
+
+		resp, err := http.Get(server.URL)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		body, err := io.ReadAll(resp.Body)
+		assert.Error(t, err)
+		assert.Equal(t, "partial", string(body))
+	})
+
+	t.Run("timeout body follows error_format", func(t *testing.T) {
+		jsonCfg := cfg
+		jsonCfg.ErrorFormat = types.ErrorFormatJSON
+		rec := httptest.NewRecorder()
+		middleware.Timeout(jsonCfg)(slow).ServeHTTP(rec, httptest.NewRequest("GET", "http://example.com/report", nil))
+
+		assert.Equal(t, http.StatusGatewayTimeout, rec.Code)
+		assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+		assert.JSONEq(t, `{"error": "Request timed out"}`, rec.Body.String())
+	})
+
+	t.Run("response controller reaches the connection", func(t *testing.T) {
+		var deadlineErr error
+		handler := middleware.Timeout(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			deadlineErr = http.NewResponseController(w).SetWriteDeadline(time.Now().Add(time.Minute))
+		}))
+		server := httptest.NewServer(handler)
+		defer server.Close()
+
+		resp, err := http.Get(server.URL)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.NoError(t, deadlineErr)
+	})
+}
@@ -0,0 +1,105 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"discobox/internal/middleware/auth"
+	"discobox/internal/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func trustedHeaderConfig() types.ProxyConfig {
+	cfg := types.ProxyConfig{}
+	cfg.Middleware.Auth.TrustedHeader.Enabled = true
+	cfg.Middleware.Auth.TrustedHeader.TrustedProxies = []string{"10.0.0.0/8", "192.168.1.5"}
+	return cfg
+}
+
+// identityRequest sends a request from remoteAddr carrying gateway identity
+// headers and returns what the next handler saw
+func identityRequest(remoteAddr string, headers map[string]string) (identity auth.Identity, ok bool, seen http.Header) {
+	h := auth.TrustedHeader(trustedHeaderConfig())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity, ok = auth.IdentityFromContext(r.Context())
+		seen = r.Header.Clone()
+	}))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = remoteAddr
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	return identity, ok, seen
+}
+
+func TestTrustedHeaderFromTrustedProxy(t *testing.T) {
+	headers := map[string]string{
+		"X-Authenticated-User": "alice",
+		"X-Authenticated-Role": "admin",
+	}
+
+	for _, addr := range []string{"10.1.2.3:4000", "192.168.1.5:4000"} {
+		identity, ok, seen := identityRequest(addr, headers)
+		assert.True(t, ok, addr)
+		assert.Equal(t, auth.Identity{User: "alice", Role: "admin"}, identity, addr)
+		assert.Equal(t, "alice", seen.Get("X-Authenticated-User"), "headers are forwarded upstream")
+	}
+}
+
+func TestTrustedHeaderFromUntrustedPeer(t *testing.T) {
+	identity, ok, seen := identityRequest("203.0.113.7:4000", map[string]string{
+		"X-Authenticated-User": "alice",
+		"X-Authenticated-Role": "admin",
+		// Forwarding headers don't make a peer trusted
+		"X-Forwarded-For": "10.1.2.3",
+	})
+
+	assert.False(t, ok)
+	assert.Empty(t, identity)
+	assert.Empty(t, seen.Get("X-Authenticated-User"), "spoofed headers are stripped")
+	assert.Empty(t, seen.Get("X-Authenticated-Role"), "spoofed headers are stripped")
+	assert.Equal(t, "10.1.2.3", seen.Get("X-Forwarded-For"))
+}
+
+func TestTrustedHeaderWithoutUser(t *testing.T) {
+	_, ok, seen := identityRequest("10.1.2.3:4000", map[string]string{
+		"X-Authenticated-Role": "admin",
+	})
+
+	assert.False(t, ok)
+	assert.Empty(t, seen.Get("X-Authenticated-Role"), "a role without a user is dropped")
+}
+
+func TestTrustedHeaderCustomHeaders(t *testing.T) {
+	cfg := trustedHeaderConfig()
+	cfg.Middleware.Auth.TrustedHeader.UserHeader = "X-Remote-User"
+	cfg.Middleware.Auth.TrustedHeader.RoleHeader = "X-Remote-Group"
+
+	var identity auth.Identity
+	h := auth.TrustedHeader(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity, _ = auth.IdentityFromContext(r.Context())
+	}))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "10.0.0.1:4000"
+	req.Header.Set("X-Remote-User", "bob")
+	req.Header.Set("X-Remote-Group", "ops")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Equal(t, auth.Identity{User: "bob", Role: "ops"}, identity)
+}
+
+func TestParseTrustedProxy(t *testing.T) {
+	for _, entry := range []string{"10.0.0.0/8", "192.168.1.5", "::1", "fd00::/8"} {
+		_, err := auth.ParseTrustedProxy(entry)
+		assert.NoError(t, err, entry)
+	}
+
+	for _, entry := range []string{"", "not-an-ip", "10.0.0.0/33"} {
+		_, err := auth.ParseTrustedProxy(entry)
+		assert.Error(t, err, entry)
+	}
+}
@@ -0,0 +1,75 @@
+package proxy_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"discobox/internal/balancer"
+	"discobox/internal/proxy"
+	"discobox/internal/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdaptiveWeightsFavorFastBackend(t *testing.T) {
+	var fastHits, slowHits atomic.Int32
+
+	service := &types.Service{
+		ID:        "test-service",
+		Endpoints: []string{"http://fast", "http://slow"},
+		Weight:    5,
+		Active:    true,
+	}
+
+	// Recomputed by hand below so the test controls the pace
+	adaptive := balancer.NewAdaptiveWeights(balancer.NewWeightedRoundRobin(), time.Hour, 5, 1, 10)
+	defer adaptive.Stop()
+
+	h, _ := newServiceHarness(t, service, proxy.Options{LoadBalancer: adaptive, Logger: &testLogger{}})
+	h.Backend("http://fast", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fastHits.Add(1)
+	}))
+	h.Backend("http://slow", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		slowHits.Add(1)
+		time.Sleep(20 * time.Millisecond)
+	}))
+
+	send := func(n int) {
+		for i := 0; i < n; i++ {
+			rec := h.Do(httptest.NewRequest("GET", "http://example.com/", nil))
+			require.Equal(t, http.StatusOK, rec.Code)
+		}
+	}
+
+	// Equal configured weights share traffic evenly
+	send(20)
+	assert.Equal(t, fastHits.Load(), slowHits.Load())
+
+	// The fast backend's weight climbs to the maximum as its lead shows,
+	// while the slow one's falls
+	fastID, slowID := service.ID+"-0", service.ID+"-1"
+	previous := service.Weight
+	for round := 0; round < 3; round++ {
+		adaptive.Recompute()
+		weight, ok := adaptive.Weight(fastID)
+		require.True(t, ok)
+		assert.GreaterOrEqual(t, weight, previous)
+		previous = weight
+		send(20)
+	}
+
+	fastWeight, _ := adaptive.Weight(fastID)
+	slowWeight, _ := adaptive.Weight(slowID)
+	assert.Equal(t, 10, fastWeight)
+	assert.Less(t, slowWeight, service.Weight)
+
+	// The weighted balancer now sends most requests to the fast backend
+	fastHits.Store(0)
+	slowHits.Store(0)
+	send(44)
+	assert.Greater(t, fastHits.Load(), 3*slowHits.Load())
+}
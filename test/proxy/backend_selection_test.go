@@ -0,0 +1,73 @@
+package proxy_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"discobox/internal/balancer"
+	"discobox/internal/proxy"
+	"discobox/internal/types"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackendSelectionMetrics(t *testing.T) {
+	h, store := newServiceHarness(t, &types.Service{
+		ID:        "selected",
+		Endpoints: []string{"http://s1", "http://s2", "http://s3"},
+		Active:    true,
+	}, proxy.Options{LoadBalancer: balancer.NewRoundRobin()})
+	for _, endpoint := range []string{"http://s1", "http://s2", "http://s3"} {
+		h.Backend(endpoint, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	}
+
+	selections := func(backend string) float64 {
+		return counterValue(t, "discobox_backend_selections_total", map[string]string{"service": "selected", "backend": backend})
+	}
+	before := []float64{selections("0"), selections("1"), selections("2")}
+
+	for range 6 {
+		rec := h.Do(httptest.NewRequest("GET", "http://example.com/", nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+	}
+
+	// Round robin spreads the requests evenly
+	for i, backend := range []string{"0", "1", "2"} {
+		assert.Equal(t, before[i]+2, selections(backend), "backend %s", backend)
+	}
+
+	// Requests that never reach a selection aren't counted
+	for _, endpoint := range []string{"http://s1", "http://s2", "http://s3"} {
+		h.Proxy().OverrideHealth("selected", endpoint, false)
+	}
+	rec := h.Do(httptest.NewRequest("GET", "http://example.com/", nil))
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	for i, backend := range []string{"0", "1", "2"} {
+		assert.Equal(t, before[i]+2, selections(backend), "backend %s", backend)
+	}
+
+	// Deleting the service drops its series
+	require.NoError(t, store.DeleteService(context.Background(), "selected"))
+	assert.Eventually(t, func() bool {
+		families, err := prometheus.DefaultGatherer.Gather()
+		require.NoError(t, err)
+		for _, family := range families {
+			if family.GetName() != "discobox_backend_selections_total" {
+				continue
+			}
+			for _, metric := range family.GetMetric() {
+				for _, pair := range metric.GetLabel() {
+					if pair.GetName() == "service" && pair.GetValue() == "selected" {
+						return false
+					}
+				}
+			}
+		}
+		return true
+	}, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,93 @@
+package proxy_test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"discobox/internal/proxy"
+	"discobox/internal/types"
+	"discobox/pkg/proxytest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBufferPool(t *testing.T) {
+	pool := proxy.NewBufferPool(4096)
+	assert.Equal(t, 4096, pool.Size())
+
+	buf := pool.Get()
+	assert.Len(t, buf, 4096)
+
+	hits, misses := pool.Stats()
+	assert.Equal(t, uint64(0), hits)
+	assert.Equal(t, uint64(1), misses)
+
+	// Buffers of another size are not pooled
+	pool.Put(make([]byte, 1024))
+	pool.Put(buf)
+
+	assert.Len(t, pool.Get(), 4096)
+	hits, misses = pool.Stats()
+	assert.Equal(t, uint64(2), hits+misses)
+	assert.LessOrEqual(t, hits, uint64(1), "at most the returned buffer is reused")
+
+	t.Run("default size", func(t *testing.T) {
+		assert.Equal(t, proxy.DefaultBufferSize, proxy.NewBufferPool(0).Size())
+		assert.Equal(t, proxy.DefaultBufferSize, proxy.New(proxy.Options{}).BufferPool().Size())
+	})
+}
+
+func TestProxyUsesConfiguredBufferSize(t *testing.T) {
+	body := strings.Repeat("x", 1<<20)
+	h := newBufferHarness(t, body, 8*1024)
+	p := h.Proxy()
+	assert.Equal(t, 8*1024, p.BufferPool().Size())
+
+	for i := 0; i < 5; i++ {
+		rec := h.Do(httptest.NewRequest("GET", "http://example.com/", nil))
+		assert.Equal(t, len(body), rec.Body.Len())
+	}
+
+	// Sequential requests reuse the buffer returned by the previous one
+	hits, misses := p.BufferPool().Stats()
+	assert.Equal(t, uint64(5), hits+misses)
+	assert.Positive(t, hits)
+}
+
+// newBufferHarness proxies every request to a backend answering with body,
+// using size-byte copy buffers
+func newBufferHarness(t testing.TB, body string, size int) *proxytest.Harness {
+	service := &types.Service{ID: "test-service", Endpoints: []string{"http://backend"}, Active: true}
+	h, _ := newServiceHarness(t, service, proxy.Options{Logger: &testLogger{}, BufferSize: size})
+	h.Backend("http://backend", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, body)
+	}))
+	return h
+}
+
+// BenchmarkProxyBufferSize proxies a large response with different copy
+// buffer sizes; larger buffers need fewer reads and writes per response.
+//
+//	go test ./test/proxy -bench BufferSize -run ^$
+func BenchmarkProxyBufferSize(b *testing.B) {
+	body := strings.Repeat("x", 8<<20)
+
+	for _, size := range []int{4 << 10, 32 << 10, 256 << 10} {
+		b.Run(fmt.Sprintf("%dKB", size>>10), func(b *testing.B) {
+			h := newBufferHarness(b, body, size)
+			b.SetBytes(int64(len(body)))
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				rec := h.Do(httptest.NewRequest("GET", "http://example.com/", nil))
+				if rec.Body.Len() != len(body) {
+					b.Fatalf("got %d bytes", rec.Body.Len())
+				}
+			}
+		})
+	}
+}
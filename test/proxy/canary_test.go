@@ -0,0 +1,143 @@
+package proxy_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"discobox/internal/proxy"
+	"discobox/internal/types"
+	"discobox/pkg/proxytest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newCanaryHarness serves a route that sends weight percent of new clients
+// to the canary service. Backends answer with their service ID.
+func newCanaryHarness(t *testing.T, weight int) *proxytest.Harness {
+	var services []*types.Service
+	for _, id := range []string{"stable", "canary"} {
+		services = append(services, &types.Service{ID: id, Endpoints: []string{"http://" + id}, Active: true})
+	}
+	h, _ := newHarness(t, services, []*types.Route{{
+		ID:         "web",
+		PathPrefix: "/",
+		ServiceID:  "stable",
+		Canary:     &types.CanaryPolicy{ServiceID: "canary", Weight: weight},
+	}}, proxy.Options{})
+
+	for _, id := range []string{"stable", "canary"} {
+		h.Backend("http://"+id, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(id))
+		}))
+	}
+	return h
+}
+
+// canaryClient keeps the variant cookie between requests like a browser
+type canaryClient struct {
+	h       *proxytest.Harness
+	variant *http.Cookie
+}
+
+// get returns the service that answered and whether a variant was assigned
+func (c *canaryClient) get(t *testing.T) (string, bool) {
+	req := httptest.NewRequest("GET", "http://example.com/", nil)
+	if c.variant != nil {
+		req.AddCookie(c.variant)
+	}
+	rec := c.h.Do(req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	assigned := false
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name == types.CanaryCookieName("web") {
+			c.variant = cookie
+			assigned = true
+		}
+	}
+	return rec.Body.String(), assigned
+}
+
+func TestCanaryVariantPersists(t *testing.T) {
+	h := newCanaryHarness(t, 50)
+
+	// Fresh clients are split between the variants and told which they got
+	clients := make(map[string]*canaryClient)
+	for i := 0; i < 200 && len(clients) < 2; i++ {
+		client := &canaryClient{h: h}
+		served, assigned := client.get(t)
+		require.True(t, assigned)
+		assert.Equal(t, served, client.variant.Value)
+		assert.Equal(t, "/", client.variant.Path)
+		assert.True(t, client.variant.HttpOnly)
+		clients[served] = client
+	}
+	require.Len(t, clients, 2, "both variants should be assigned")
+
+	for variant, client := range clients {
+		for i := 0; i < 20; i++ {
+			served, assigned := client.get(t)
+			assert.Equal(t, variant, served)
+			assert.False(t, assigned, "returning clients keep their cookie")
+		}
+	}
+}
+
+func TestCanaryWeights(t *testing.T) {
+	t.Run("full weight sends new clients to the canary", func(t *testing.T) {
+		h := newCanaryHarness(t, 100)
+		for i := 0; i < 10; i++ {
+			served, _ := (&canaryClient{h: h}).get(t)
+			assert.Equal(t, "canary", served)
+		}
+	})
+
+	t.Run("zero weight ignores pinned clients", func(t *testing.T) {
+		h := newCanaryHarness(t, 0)
+		client := &canaryClient{h: h, variant: &http.Cookie{Name: types.CanaryCookieName("web"), Value: types.VariantCanary}}
+
+		served, assigned := client.get(t)
+		assert.Equal(t, "stable", served)
+		assert.False(t, assigned)
+	})
+
+	t.Run("unknown variants are reassigned", func(t *testing.T) {
+		h := newCanaryHarness(t, 100)
+		client := &canaryClient{h: h, variant: &http.Cookie{Name: types.CanaryCookieName("web"), Value: "beta"}}
+
+		served, assigned := client.get(t)
+		assert.Equal(t, "canary", served)
+		assert.True(t, assigned)
+		assert.Equal(t, types.VariantCanary, client.variant.Value)
+	})
+}
+
+func TestCanaryCookiePerRoute(t *testing.T) {
+	var services []*types.Service
+	for _, id := range []string{"stable", "canary"} {
+		services = append(services, &types.Service{ID: id, Endpoints: []string{"http://" + id}, Active: true})
+	}
+	h, _ := newHarness(t, services, []*types.Route{
+		{ID: "web", PathPrefix: "/", ServiceID: "stable", Canary: &types.CanaryPolicy{ServiceID: "canary", Weight: 100}},
+		{ID: "api/v2", PathPrefix: "/api", ServiceID: "stable", Priority: 10, Canary: &types.CanaryPolicy{ServiceID: "canary", Weight: 100}},
+	}, proxy.Options{})
+	for _, id := range []string{"stable", "canary"} {
+		h.Backend("http://"+id, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(id))
+		}))
+	}
+
+	// A client pinned to stable on one route is still assigned on another
+	req := httptest.NewRequest("GET", "http://example.com/api/users", nil)
+	req.AddCookie(&http.Cookie{Name: types.CanaryCookieName("web"), Value: types.VariantStable})
+	rec := h.Do(req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "canary", rec.Body.String())
+
+	cookies := rec.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.Equal(t, "discobox_variant_api_v2", cookies[0].Name)
+	assert.Equal(t, types.VariantCanary, cookies[0].Value)
+}
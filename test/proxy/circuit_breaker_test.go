@@ -0,0 +1,111 @@
+package proxy_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"discobox/internal/circuit"
+	"discobox/internal/proxy"
+	"discobox/internal/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPerServiceCircuitBreaker(t *testing.T) {
+	ctx := context.Background()
+
+	breakers := circuit.NewMultiCircuitBreaker(circuit.CircuitBreakerSettings{
+		FailureThreshold: 5,
+		SuccessThreshold: 1,
+		Timeout:          time.Minute,
+		ServerErrors:     true,
+	})
+	services := []*types.Service{
+		{
+			ID:             "login",
+			Endpoints:      []string{"http://login"},
+			CircuitBreaker: &types.CircuitBreakerConfig{FailureThreshold: 2},
+			Active:         true,
+		},
+		{ID: "batch", Endpoints: []string{"http://batch"}, Active: true},
+	}
+	routes := []*types.Route{
+		{ID: "login", PathPrefix: "/login", ServiceID: "login"},
+		{ID: "batch", PathPrefix: "/batch", ServiceID: "batch"},
+	}
+	h, store := newHarness(t, services, routes, proxy.Options{CircuitBreakers: breakers})
+
+	failing := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	h.Backend("http://login", failing)
+	h.Backend("http://batch", failing)
+
+	// failuresUntilOpen counts the backend failures a service absorbs before
+	// its breaker starts rejecting requests
+	failuresUntilOpen := func(path string) int {
+		for failures := 0; failures < 20; failures++ {
+			rec := h.Do(httptest.NewRequest("GET", "http://example.com"+path, nil))
+			if rec.Code == http.StatusServiceUnavailable {
+				return failures
+			}
+			require.Equal(t, http.StatusInternalServerError, rec.Code)
+		}
+		t.Fatalf("breaker for %s never opened", path)
+		return 0
+	}
+
+	assert.Equal(t, 2, failuresUntilOpen("/login"))
+	assert.Equal(t, 5, failuresUntilOpen("/batch"))
+
+	assert.Equal(t, map[string]string{"login": "open", "batch": "open"}, breakers.GetAllStates())
+
+	t.Run("runtime stats report each service", func(t *testing.T) {
+		stats, err := h.Proxy().RuntimeStats(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "open", stats.CircuitBreakers["service:login"])
+		assert.Equal(t, "open", stats.CircuitBreakers["service:batch"])
+	})
+
+	t.Run("changing the override replaces the breaker", func(t *testing.T) {
+		login, err := store.GetService(ctx, "login")
+		require.NoError(t, err)
+		login.CircuitBreaker.FailureThreshold = 3
+		require.NoError(t, store.UpdateService(ctx, login))
+
+		assert.Equal(t, 3, failuresUntilOpen("/login"))
+	})
+
+	t.Run("deleting a service drops its breaker", func(t *testing.T) {
+		require.NoError(t, store.DeleteService(ctx, "batch"))
+		require.Eventually(t, func() bool {
+			_, exists := breakers.GetAllStates()["batch"]
+			return !exists
+		}, time.Second, 10*time.Millisecond)
+		assert.Contains(t, breakers.GetAllStates(), "login")
+	})
+}
+
+func TestCircuitBreakerIgnoresServerErrorsByDefault(t *testing.T) {
+	breakers := circuit.NewMultiCircuitBreaker(circuit.CircuitBreakerSettings{
+		FailureThreshold: 2,
+		SuccessThreshold: 1,
+		Timeout:          time.Minute,
+	})
+	services := []*types.Service{{ID: "api", Endpoints: []string{"http://api"}, Active: true}}
+	routes := []*types.Route{{ID: "api", PathPrefix: "/", ServiceID: "api"}}
+	h, _ := newHarness(t, services, routes, proxy.Options{CircuitBreakers: breakers})
+	h.Backend("http://api", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+
+	for i := 0; i < 5; i++ {
+		rec := h.Do(httptest.NewRequest("GET", "http://example.com/", nil))
+		require.Equal(t, http.StatusInternalServerError, rec.Code)
+	}
+	assert.Equal(t, "closed", breakers.GetAllStates()["api"])
+}
@@ -0,0 +1,94 @@
+package proxy_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"discobox/internal/proxy"
+	"discobox/internal/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProxyClientCancel(t *testing.T) {
+	entered := make(chan struct{})
+	upstreamCanceled := make(chan struct{})
+	backend := createTestBackend(func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		select {
+		case <-r.Context().Done():
+			close(upstreamCanceled)
+		case <-time.After(5 * time.Second):
+			w.WriteHeader(http.StatusOK)
+		}
+	})
+	defer backend.Close()
+
+	backendURL, _ := url.Parse(backend.URL)
+	server := &types.Server{ID: "backend-1", URL: backendURL, Healthy: true}
+
+	storage := newMockStorage()
+	storage.CreateService(context.Background(), &types.Service{
+		ID:        "test-service",
+		Endpoints: []string{backend.URL},
+		Active:    true,
+	})
+	route := &types.Route{ID: "test-route", ServiceID: "test-service"}
+
+	var failures atomic.Int32
+	logger := &recordingLogger{}
+	p := proxy.New(proxy.Options{
+		Router: &mockRouter{
+			matchFunc: func(req *http.Request) (*types.Route, error) { return route, nil },
+		},
+		LoadBalancer: &mockLoadBalancer{
+			selectFunc: func(ctx context.Context, req *http.Request, servers []*types.Server) (*types.Server, error) {
+				return server, nil
+			},
+		},
+		HealthChecker: &mockHealthChecker{
+			recordFailure: func(serverID string, err error) { failures.Add(1) },
+		},
+		Storage: storage,
+		Logger:  logger,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req := httptest.NewRequest("GET", "http://example.com/slow", nil).WithContext(ctx)
+	rec := httptest.NewRecorder()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		p.ServeHTTP(rec, req)
+	}()
+
+	<-entered
+	cancel()
+
+	select {
+	case <-upstreamCanceled:
+	case <-time.After(2 * time.Second):
+		t.Fatal("upstream request was not canceled")
+	}
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("proxy did not return after the client canceled")
+	}
+
+	assert.Equal(t, proxy.StatusClientClosedRequest, rec.Code)
+	assert.Zero(t, failures.Load(), "client cancellation must not count against the backend")
+
+	canceled := logger.logged("client canceled request")
+	require.Len(t, canceled, 1)
+	assert.Equal(t, "test-route", canceled[0].fields["route_id"])
+	assert.Equal(t, "backend-1", canceled[0].fields["server_id"])
+}
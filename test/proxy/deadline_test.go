@@ -0,0 +1,117 @@
+package proxy_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"discobox/internal/proxy"
+	"discobox/internal/types"
+	"discobox/pkg/proxytest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newDeadlineHarness proxies every request to backend through a service with the given timeout
+func newDeadlineHarness(t *testing.T, backend http.Handler, timeout time.Duration) *proxytest.Harness {
+	service := &types.Service{ID: "test-service", Endpoints: []string{"http://backend"}, Timeout: timeout, Active: true}
+	h, _ := newServiceHarness(t, service, proxy.Options{Logger: &testLogger{}})
+	h.Backend("http://backend", backend)
+	return h
+}
+
+func TestProxyPropagatesRemainingTimeout(t *testing.T) {
+	received := make(chan string, 1)
+	backend := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Get(proxy.RequestTimeoutHeader)
+	})
+
+	tests := []struct {
+		name           string
+		serviceTimeout time.Duration
+		clientHeader   string
+		wantMax        int64 // 0 means the header must be absent
+	}{
+		{name: "service timeout", serviceTimeout: 2 * time.Second, wantMax: 2000},
+		{name: "shorter client timeout", serviceTimeout: 5 * time.Second, clientHeader: "1500", wantMax: 1500},
+		{name: "longer client timeout is capped", serviceTimeout: time.Second, clientHeader: "60000", wantMax: 1000},
+		{name: "client timeout without a service timeout", clientHeader: "3000", wantMax: 3000},
+		{name: "no deadline", wantMax: 0},
+		{name: "invalid client timeout is dropped", clientHeader: "soon", wantMax: 0},
+		{name: "negative client timeout is ignored", serviceTimeout: time.Second, clientHeader: "-5", wantMax: 1000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newDeadlineHarness(t, backend, tt.serviceTimeout)
+
+			req := httptest.NewRequest("GET", "http://example.com/", nil)
+			if tt.clientHeader != "" {
+				req.Header.Set(proxy.RequestTimeoutHeader, tt.clientHeader)
+			}
+			rec := h.Do(req)
+			require.Equal(t, http.StatusOK, rec.Code)
+
+			header := <-received
+			if tt.wantMax == 0 {
+				assert.Empty(t, header)
+				return
+			}
+
+			remaining, err := strconv.ParseInt(header, 10, 64)
+			require.NoError(t, err, "header %q", header)
+			assert.Positive(t, remaining)
+			assert.LessOrEqual(t, remaining, tt.wantMax)
+			// Only a little time passes before the request is forwarded
+			assert.Greater(t, remaining, tt.wantMax-500)
+		})
+	}
+}
+
+func TestProxyCancelsUpstreamAtDeadline(t *testing.T) {
+	canceled := make(chan struct{}, 1)
+	backend := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+			canceled <- struct{}{}
+		case <-time.After(5 * time.Second):
+			w.Write([]byte("too late"))
+		}
+	})
+
+	tests := []struct {
+		name           string
+		serviceTimeout time.Duration
+		clientHeader   string
+	}{
+		{name: "service timeout", serviceTimeout: 100 * time.Millisecond},
+		{name: "client timeout", serviceTimeout: 10 * time.Second, clientHeader: "100"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newDeadlineHarness(t, backend, tt.serviceTimeout)
+
+			req := httptest.NewRequest("GET", "http://example.com/", nil)
+			if tt.clientHeader != "" {
+				req.Header.Set(proxy.RequestTimeoutHeader, tt.clientHeader)
+			}
+
+			start := time.Now()
+			rec := h.Do(req)
+			elapsed := time.Since(start)
+
+			assert.Equal(t, http.StatusGatewayTimeout, rec.Code)
+			assert.Less(t, elapsed, 2*time.Second)
+
+			select {
+			case <-canceled:
+			case <-time.After(2 * time.Second):
+				t.Fatal("backend request was not canceled at the deadline")
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package proxy_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"discobox/internal/proxy"
+	"discobox/internal/types"
+	"discobox/pkg/proxytest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newDecompressHarness proxies to backend through a route with the given middlewares
+func newDecompressHarness(t *testing.T, backend http.Handler, maxSize int64, middlewares ...string) *proxytest.Harness {
+	service := &types.Service{ID: "test-service", Endpoints: []string{"http://backend"}, Active: true}
+	route := &types.Route{ID: "test-route", PathPrefix: "/", ServiceID: service.ID, Middlewares: middlewares}
+
+	h, _ := newHarness(t, []*types.Service{service}, []*types.Route{route}, proxy.Options{
+		Logger:              &testLogger{},
+		MaxDecompressedSize: maxSize,
+	})
+	h.Backend("http://backend", backend)
+	return h
+}
+
+func gzipBytes(data []byte) []byte {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write(data)
+	zw.Close()
+	return buf.Bytes()
+}
+
+func TestProxyDecompressRequest(t *testing.T) {
+	type received struct {
+		body            string
+		contentLength   int64
+		contentEncoding string
+	}
+	var got received
+	hits := 0
+
+	backend := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		body, _ := io.ReadAll(r.Body)
+		got = received{
+			body:            string(body),
+			contentLength:   r.ContentLength,
+			contentEncoding: r.Header.Get("Content-Encoding"),
+		}
+	})
+
+	send := func(h *proxytest.Harness, encoding string, body []byte) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "http://example.com/upload", bytes.NewReader(body))
+		req.Header.Set("Content-Encoding", encoding)
+		return h.Do(req)
+	}
+
+	payload := strings.Repeat("hello legacy backend ", 50)
+
+	t.Run("gzip body reaches the backend decompressed", func(t *testing.T) {
+		h := newDecompressHarness(t, backend, 0, types.MiddlewareDecompressRequest)
+
+		rec := send(h, "gzip", gzipBytes([]byte(payload)))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, payload, got.body)
+		assert.Equal(t, int64(len(payload)), got.contentLength)
+		assert.Empty(t, got.contentEncoding)
+	})
+
+	t.Run("deflate body reaches the backend decompressed", func(t *testing.T) {
+		h := newDecompressHarness(t, backend, 0, types.MiddlewareDecompressRequest)
+
+		var buf bytes.Buffer
+		zw := zlib.NewWriter(&buf)
+		zw.Write([]byte(payload))
+		zw.Close()
+
+		rec := send(h, "deflate", buf.Bytes())
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, payload, got.body)
+		assert.Empty(t, got.contentEncoding)
+	})
+
+	t.Run("oversized body is rejected", func(t *testing.T) {
+		h := newDecompressHarness(t, backend, 4096, types.MiddlewareDecompressRequest)
+		before := hits
+
+		// A megabyte of zeros compresses to well under the cap
+		bomb := gzipBytes(make([]byte, 1<<20))
+		assert.Less(t, len(bomb), 4096)
+
+		rec := send(h, "gzip", bomb)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+		assert.Equal(t, before, hits, "backend should not be called")
+	})
+
+	t.Run("malformed body is rejected", func(t *testing.T) {
+		h := newDecompressHarness(t, backend, 0, types.MiddlewareDecompressRequest)
+		before := hits
+
+		rec := send(h, "gzip", []byte("not gzip at all"))
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Equal(t, before, hits)
+	})
+
+	t.Run("routes without the middleware forward the body as sent", func(t *testing.T) {
+		h := newDecompressHarness(t, backend, 0)
+		compressed := gzipBytes([]byte(payload))
+
+		rec := send(h, "gzip", compressed)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, string(compressed), got.body)
+		assert.Equal(t, "gzip", got.contentEncoding)
+	})
+}
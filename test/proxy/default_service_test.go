@@ -0,0 +1,87 @@
+package proxy_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"discobox/internal/proxy"
+	"discobox/internal/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProxyDefaultService(t *testing.T) {
+	services := []*types.Service{
+		{ID: "api", Endpoints: []string{"http://api"}, Active: true},
+		{ID: "app", Endpoints: []string{"http://app"}, Active: true},
+	}
+	routes := []*types.Route{
+		{ID: "api-route", Host: "api.example.com", PathPrefix: "/v1", ServiceID: "api"},
+	}
+	h, _ := newHarness(t, services, routes, proxy.Options{DefaultServiceID: "app"})
+
+	h.Backend("http://api", echoBackend("api"))
+	h.Backend("http://app", echoBackend("app"))
+
+	tests := []struct {
+		name        string
+		url         string
+		wantBackend string
+	}{
+		{name: "matched route is unaffected", url: "http://api.example.com/v1/users", wantBackend: "api"},
+		{name: "unmatched path goes to the default", url: "http://api.example.com/other", wantBackend: "app"},
+		{name: "unmatched host goes to the default", url: "http://www.example.com/v1/users", wantBackend: "app"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := h.Do(httptest.NewRequest("GET", tt.url, nil))
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, tt.wantBackend, rec.Header().Get("X-Backend"))
+		})
+	}
+
+	t.Run("unmatched path is proxied unchanged", func(t *testing.T) {
+		rec := h.Do(httptest.NewRequest("GET", "http://www.example.com/some/page", nil))
+		assert.Equal(t, "/some/page", rec.Body.String())
+	})
+
+	t.Run("unmatched requests 404 once the default is cleared", func(t *testing.T) {
+		h.Proxy().UpdateDefaultService("")
+		defer h.Proxy().UpdateDefaultService("app")
+
+		rec := h.Do(httptest.NewRequest("GET", "http://www.example.com/", nil))
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+
+		rec = h.Do(httptest.NewRequest("GET", "http://api.example.com/v1/users", nil))
+		assert.Equal(t, "api", rec.Header().Get("X-Backend"))
+	})
+
+	t.Run("missing default service is unavailable", func(t *testing.T) {
+		h.Proxy().UpdateDefaultService("missing")
+		defer h.Proxy().UpdateDefaultService("app")
+
+		rec := h.Do(httptest.NewRequest("GET", "http://www.example.com/", nil))
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	})
+
+	t.Run("default can change while requests are served", func(t *testing.T) {
+		defer h.Proxy().UpdateDefaultService("app")
+
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 100 {
+				h.Proxy().UpdateDefaultService([]string{"app", "api"}[i%2])
+			}
+		}()
+		for range 100 {
+			rec := h.Do(httptest.NewRequest("GET", "http://www.example.com/", nil))
+			assert.Equal(t, http.StatusOK, rec.Code)
+		}
+		wg.Wait()
+	})
+}
@@ -0,0 +1,382 @@
+package proxy_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"discobox/internal/proxy"
+	"discobox/internal/types"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeLookup answers lookups from a table and counts them
+type fakeLookup struct {
+	mu      sync.Mutex
+	hosts   map[string][]string
+	ttl     time.Duration
+	err     error
+	lookups map[string]int
+}
+
+func newFakeLookup(hosts map[string][]string) *fakeLookup {
+	return &fakeLookup{hosts: hosts, lookups: make(map[string]int)}
+}
+
+func (f *fakeLookup) LookupHost(ctx context.Context, host string) ([]string, error) {
+	addrs, _, err := f.LookupHostTTL(ctx, host)
+	return addrs, err
+}
+
+func (f *fakeLookup) LookupHostTTL(ctx context.Context, host string) ([]string, time.Duration, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.lookups[host]++
+	if f.err != nil {
+		return nil, 0, f.err
+	}
+	addrs, ok := f.hosts[host]
+	if !ok {
+		return nil, 0, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	return addrs, f.ttl, nil
+}
+
+func (f *fakeLookup) set(host string, addrs ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.hosts[host] = addrs
+}
+
+func (f *fakeLookup) fail(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.err = err
+}
+
+func (f *fakeLookup) count(host string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.lookups[host]
+}
+
+func TestResolverCaching(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("lookups are cached for the TTL", func(t *testing.T) {
+		lookup := newFakeLookup(map[string][]string{"backend.internal": {"10.0.0.1"}})
+		resolver := proxy.NewResolver(lookup, time.Hour, 0, nil)
+
+		for range 3 {
+			addrs, err := resolver.Resolve(ctx, "backend.internal")
+			require.NoError(t, err)
+			assert.Equal(t, []string{"10.0.0.1"}, addrs)
+		}
+		assert.Equal(t, 1, lookup.count("backend.internal"))
+	})
+
+	t.Run("a shorter record TTL wins", func(t *testing.T) {
+		lookup := newFakeLookup(map[string][]string{"backend.internal": {"10.0.0.1"}})
+		lookup.ttl = 20 * time.Millisecond
+		resolver := proxy.NewResolver(lookup, time.Hour, 0, nil)
+
+		_, err := resolver.Resolve(ctx, "backend.internal")
+		require.NoError(t, err)
+
+		lookup.set("backend.internal", "10.0.0.2")
+		time.Sleep(40 * time.Millisecond)
+
+		addrs, err := resolver.Resolve(ctx, "backend.internal")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"10.0.0.2"}, addrs)
+		assert.Equal(t, 2, lookup.count("backend.internal"))
+	})
+
+	t.Run("zero TTL disables caching", func(t *testing.T) {
+		lookup := newFakeLookup(map[string][]string{"backend.internal": {"10.0.0.1"}})
+		resolver := proxy.NewResolver(lookup, 0, 0, nil)
+
+		for range 3 {
+			_, err := resolver.Resolve(ctx, "backend.internal")
+			require.NoError(t, err)
+		}
+		assert.Equal(t, 3, lookup.count("backend.internal"))
+	})
+
+	t.Run("IP literals skip the lookup", func(t *testing.T) {
+		lookup := newFakeLookup(nil)
+		resolver := proxy.NewResolver(lookup, time.Hour, 0, nil)
+
+		addrs, err := resolver.Resolve(ctx, "127.0.0.1")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"127.0.0.1"}, addrs)
+		assert.Equal(t, 0, lookup.count("127.0.0.1"))
+	})
+}
+
+func TestResolverRefresh(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("refresh picks up changed addresses", func(t *testing.T) {
+		lookup := newFakeLookup(map[string][]string{"backend.internal": {"10.0.0.1"}})
+		resolver := proxy.NewResolver(lookup, time.Hour, 0, nil)
+
+		_, err := resolver.Resolve(ctx, "backend.internal")
+		require.NoError(t, err)
+
+		lookup.set("backend.internal", "10.0.0.2")
+		resolver.Refresh(ctx)
+
+		addrs, err := resolver.Resolve(ctx, "backend.internal")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"10.0.0.2"}, addrs)
+		assert.Equal(t, 2, lookup.count("backend.internal"))
+	})
+
+	t.Run("failed refresh keeps the previous addresses", func(t *testing.T) {
+		lookup := newFakeLookup(map[string][]string{"backend.internal": {"10.0.0.1"}})
+		resolver := proxy.NewResolver(lookup, time.Hour, 0, &testLogger{})
+
+		_, err := resolver.Resolve(ctx, "backend.internal")
+		require.NoError(t, err)
+
+		lookup.fail(errors.New("server misbehaving"))
+		resolver.Refresh(ctx)
+
+		addrs, err := resolver.Resolve(ctx, "backend.internal")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"10.0.0.1"}, addrs)
+	})
+
+	t.Run("hosts not used since the last refresh are dropped", func(t *testing.T) {
+		lookup := newFakeLookup(map[string][]string{"backend.internal": {"10.0.0.1"}})
+		resolver := proxy.NewResolver(lookup, time.Hour, 0, nil)
+
+		_, err := resolver.Resolve(ctx, "backend.internal")
+		require.NoError(t, err)
+
+		resolver.Refresh(ctx) // Re-resolved, since it was used
+		resolver.Refresh(ctx) // Dropped without a lookup
+		assert.Equal(t, 2, lookup.count("backend.internal"))
+
+		_, err = resolver.Resolve(ctx, "backend.internal")
+		require.NoError(t, err)
+		assert.Equal(t, 3, lookup.count("backend.internal"))
+	})
+
+	t.Run("refresh loop runs in the background", func(t *testing.T) {
+		lookup := newFakeLookup(map[string][]string{"backend.internal": {"10.0.0.1"}})
+		resolver := proxy.NewResolver(lookup, time.Hour, 10*time.Millisecond, nil)
+		resolver.Start()
+		defer resolver.Stop()
+
+		_, err := resolver.Resolve(ctx, "backend.internal")
+		require.NoError(t, err)
+		lookup.set("backend.internal", "10.0.0.2")
+
+		assert.Eventually(t, func() bool {
+			addrs, err := resolver.Resolve(ctx, "backend.internal")
+			return err == nil && len(addrs) == 1 && addrs[0] == "10.0.0.2"
+		}, time.Second, 5*time.Millisecond)
+	})
+}
+
+// startDNSServer serves the given records over UDP on a local port and
+// returns its address. Names without records get NXDOMAIN.
+func startDNSServer(t *testing.T, records map[string][]string) string {
+	t.Helper()
+
+	mux := dns.NewServeMux()
+	mux.HandleFunc(".", func(w dns.ResponseWriter, req *dns.Msg) {
+		resp := new(dns.Msg)
+		resp.SetReply(req)
+
+		q := req.Question[0]
+		rrs, ok := records[q.Name]
+		if !ok {
+			resp.Rcode = dns.RcodeNameError
+		}
+		for _, record := range rrs {
+			rr, err := dns.NewRR(record)
+			if err == nil && (rr.Header().Rrtype == q.Qtype || rr.Header().Rrtype == dns.TypeCNAME) {
+				resp.Answer = append(resp.Answer, rr)
+			}
+		}
+		w.WriteMsg(resp)
+	})
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := &dns.Server{PacketConn: conn, Handler: mux}
+	go server.ActivateAndServe()
+	t.Cleanup(func() { server.Shutdown() })
+	return conn.LocalAddr().String()
+}
+
+func TestDNSLookup(t *testing.T) {
+	ctx := context.Background()
+	addr := startDNSServer(t, map[string][]string{
+		"backend.internal.": {
+			"backend.internal. 30 IN A 10.0.0.1",
+			"backend.internal. 60 IN A 10.0.0.2",
+			"backend.internal. 45 IN AAAA fd00::1",
+		},
+		"alias.internal.": {
+			"alias.internal. 5 IN CNAME backend.internal.",
+			"backend.internal. 30 IN A 10.0.0.1",
+		},
+	})
+
+	// The first server doesn't answer; queries move on to the next
+	dead, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer dead.Close()
+
+	lookup, ok := proxy.NewDNSLookup([]string{dead.LocalAddr().String(), addr}, 100*time.Millisecond).(proxy.TTLLookup)
+	require.True(t, ok, "lookups against configured servers should report TTLs")
+
+	t.Run("lowest record TTL", func(t *testing.T) {
+		addrs, ttl, err := lookup.LookupHostTTL(ctx, "backend.internal")
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"10.0.0.1", "10.0.0.2", "fd00::1"}, addrs)
+		assert.Equal(t, 30*time.Second, ttl)
+	})
+
+	t.Run("CNAME TTL counts", func(t *testing.T) {
+		addrs, ttl, err := lookup.LookupHostTTL(ctx, "alias.internal")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"10.0.0.1"}, addrs)
+		assert.Equal(t, 5*time.Second, ttl)
+	})
+
+	t.Run("unknown host", func(t *testing.T) {
+		_, _, err := lookup.LookupHostTTL(ctx, "missing.internal")
+		var dnsErr *net.DNSError
+		require.ErrorAs(t, err, &dnsErr)
+		assert.True(t, dnsErr.IsNotFound)
+	})
+
+	t.Run("hosts file first", func(t *testing.T) {
+		// No server answers; localhost comes from /etc/hosts
+		offline := proxy.NewDNSLookup([]string{dead.LocalAddr().String()}, 100*time.Millisecond)
+		addrs, err := offline.LookupHost(ctx, "localhost")
+		require.NoError(t, err)
+		assert.Contains(t, addrs, "127.0.0.1")
+	})
+}
+
+// blockingLookup holds every lookup until released
+type blockingLookup struct {
+	*fakeLookup
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingLookup) LookupHostTTL(ctx context.Context, host string) ([]string, time.Duration, error) {
+	b.started <- struct{}{}
+	<-b.release
+	return b.fakeLookup.LookupHostTTL(ctx, host)
+}
+
+func TestResolverSharesLookups(t *testing.T) {
+	lookup := &blockingLookup{
+		fakeLookup: newFakeLookup(map[string][]string{"backend.internal": {"10.0.0.1"}}),
+		started:    make(chan struct{}, 10),
+		release:    make(chan struct{}),
+	}
+	resolver := proxy.NewResolver(lookup, time.Hour, 0, nil)
+
+	var wg sync.WaitGroup
+	results := make(chan []string, 5)
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			addrs, err := resolver.Resolve(context.Background(), "backend.internal")
+			assert.NoError(t, err)
+			results <- addrs
+		}()
+	}
+
+	<-lookup.started
+	// Give the other callers time to join the lookup in progress
+	time.Sleep(50 * time.Millisecond)
+	close(lookup.release)
+	wg.Wait()
+	close(results)
+
+	for addrs := range results {
+		assert.Equal(t, []string{"10.0.0.1"}, addrs)
+	}
+	assert.Equal(t, 1, lookup.count("backend.internal"))
+}
+
+func TestResolverDialFallsBackToOtherFamily(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	_, port, err := net.SplitHostPort(ln.Addr().String())
+	require.NoError(t, err)
+
+	// The IPv6 address never answers; IPv4 is raced against it rather than
+	// waiting out the dial timeout
+	lookup := newFakeLookup(map[string][]string{"backend.internal": {"fd00::1", "127.0.0.1"}})
+	resolver := proxy.NewResolver(lookup, time.Hour, 0, nil)
+	dial := resolver.DialContext(&net.Dialer{Timeout: 10 * time.Second, FallbackDelay: 50 * time.Millisecond})
+
+	start := time.Now()
+	conn, err := dial(context.Background(), "tcp", net.JoinHostPort("backend.internal", port))
+	require.NoError(t, err)
+	conn.Close()
+	assert.Equal(t, "127.0.0.1:"+port, conn.RemoteAddr().String())
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestTransportDialsThroughResolver(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer backend.Close()
+
+	_, port, err := net.SplitHostPort(backend.Listener.Addr().String())
+	require.NoError(t, err)
+
+	// Nothing listens on the first address; the dial falls through to the next
+	lookup := newFakeLookup(map[string][]string{"backend.internal": {"127.0.0.2", "127.0.0.1"}})
+	resolver := proxy.NewResolver(lookup, time.Hour, 0, nil)
+
+	var cfg types.ProxyConfig
+	cfg.Transport.DialTimeout = time.Second
+	client := &http.Client{Transport: proxy.NewTransport(cfg, resolver)}
+
+	for range 2 {
+		resp, err := client.Get("http://backend.internal:" + port + "/")
+		require.NoError(t, err)
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.Equal(t, "ok", string(body))
+
+		// Make the next request dial again
+		client.CloseIdleConnections()
+	}
+	assert.Equal(t, 1, lookup.count("backend.internal"))
+}
@@ -0,0 +1,106 @@
+package proxy_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"net/textproto"
+	"sync"
+	"testing"
+
+	"discobox/internal/proxy"
+	"discobox/internal/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var preloads = []string{
+	"</static/app.css>; rel=preload; as=style",
+	"</static/app.js>; rel=preload; as=script",
+}
+
+// newEarlyHintsServer serves a proxy for a route with preload links. The
+// backend answers with its own Link header.
+func newEarlyHintsServer(t *testing.T, http2 bool) *httptest.Server {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Link", "</next>; rel=next")
+		w.Write([]byte("ok"))
+	}))
+	t.Cleanup(backend.Close)
+
+	storage := newMockStorage()
+	service := &types.Service{ID: "test-service", Endpoints: []string{backend.URL}, Active: true}
+	storage.CreateService(context.Background(), service)
+
+	route := &types.Route{ID: "test-route", ServiceID: service.ID, EarlyHints: preloads}
+	p := proxy.New(proxy.Options{
+		Router: &mockRouter{
+			matchFunc: func(req *http.Request) (*types.Route, error) {
+				return route, nil
+			},
+		},
+		LoadBalancer: &mockLoadBalancer{
+			selectFunc: func(ctx context.Context, req *http.Request, servers []*types.Server) (*types.Server, error) {
+				return servers[0], nil
+			},
+		},
+		Storage: storage,
+		Logger:  &testLogger{},
+	})
+
+	srv := httptest.NewUnstartedServer(p)
+	srv.EnableHTTP2 = http2
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// getWithHints fetches url and returns the final response and any 1xx
+// responses received before it
+func getWithHints(t *testing.T, client *http.Client, url string) (*http.Response, map[int][]textproto.MIMEHeader) {
+	var mu sync.Mutex
+	interim := make(map[int][]textproto.MIMEHeader)
+	trace := &httptrace.ClientTrace{
+		Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+			mu.Lock()
+			defer mu.Unlock()
+			interim[code] = append(interim[code], header)
+			return nil
+		},
+	}
+
+	req, err := http.NewRequestWithContext(httptrace.WithClientTrace(context.Background(), trace), "GET", url, nil)
+	require.NoError(t, err)
+
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp, interim
+}
+
+func TestProxySendsEarlyHints(t *testing.T) {
+	srv := newEarlyHintsServer(t, true)
+
+	resp, interim := getWithHints(t, srv.Client(), srv.URL+"/")
+	require.Equal(t, 2, resp.ProtoMajor)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	require.Len(t, interim[http.StatusEarlyHints], 1)
+	assert.Equal(t, preloads, interim[http.StatusEarlyHints][0].Values("Link"))
+
+	// The final response only carries the backend's links
+	assert.Equal(t, []string{"</next>; rel=next"}, resp.Header.Values("Link"))
+}
+
+func TestProxySkipsEarlyHintsOverHTTP1(t *testing.T) {
+	srv := newEarlyHintsServer(t, false)
+
+	resp, interim := getWithHints(t, srv.Client(), srv.URL+"/")
+	require.Equal(t, 1, resp.ProtoMajor)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	assert.Empty(t, interim)
+	assert.Equal(t, []string{"</next>; rel=next"}, resp.Header.Values("Link"))
+}
@@ -0,0 +1,103 @@
+package proxy_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"discobox/internal/balancer"
+	"discobox/internal/proxy"
+	"discobox/internal/types"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// counterValue reads the named Prometheus counter for one label set
+func counterValue(t *testing.T, name string, labels map[string]string) float64 {
+	families, err := prometheus.DefaultGatherer.Gather()
+	require.NoError(t, err)
+
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+	metrics:
+		for _, metric := range family.GetMetric() {
+			for _, pair := range metric.GetLabel() {
+				if labels[pair.GetName()] != pair.GetValue() {
+					continue metrics
+				}
+			}
+			return metric.GetCounter().GetValue()
+		}
+	}
+	return 0
+}
+
+func TestEndpointTags(t *testing.T) {
+	h, _ := newServiceHarness(t, &types.Service{
+		ID:        "tagged",
+		Endpoints: []string{"http://a1", "http://a2", "http://b1"},
+		Metadata:  map[string]string{"header:X-Env": "prod"},
+		EndpointTags: map[string]map[string]string{
+			"http://a1": {types.TagZone: "zone-a", "header:X-Version": "v1"},
+			"http://a2": {types.TagZone: "zone-a", "header:X-Version": "v1"},
+			"http://b1": {types.TagZone: "zone-b", "header:X-Version": "v2"},
+		},
+		Active: true,
+	}, proxy.Options{
+		LoadBalancer: balancer.NewZonePreference(balancer.NewRoundRobin(), "X-Client-Zone", ""),
+	})
+
+	for _, name := range []string{"a1", "a2", "b1"} {
+		h.Backend("http://"+name, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Backend", name)
+			w.Header().Set("X-Seen-Version", r.Header.Get("X-Version"))
+			w.Header().Set("X-Seen-Env", r.Header.Get("X-Env"))
+		}))
+	}
+
+	get := func(zone string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "http://example.com/", nil)
+		if zone != "" {
+			req.Header.Set("X-Client-Zone", zone)
+		}
+		rec := h.Do(req)
+		require.Equal(t, http.StatusOK, rec.Code)
+		return rec
+	}
+
+	t.Run("zone tag steers selection", func(t *testing.T) {
+		seen := make(map[string]int)
+		for i := 0; i < 6; i++ {
+			seen[get("zone-a").Header().Get("X-Backend")]++
+		}
+		assert.Equal(t, map[string]int{"a1": 3, "a2": 3}, seen)
+
+		for i := 0; i < 3; i++ {
+			assert.Equal(t, "b1", get("zone-b").Header().Get("X-Backend"))
+		}
+	})
+
+	t.Run("tags are layered over service metadata", func(t *testing.T) {
+		rec := get("zone-b")
+		assert.Equal(t, "v2", rec.Header().Get("X-Seen-Version"))
+		assert.Equal(t, "prod", rec.Header().Get("X-Seen-Env"))
+
+		rec = get("zone-a")
+		assert.Equal(t, "v1", rec.Header().Get("X-Seen-Version"))
+		assert.Equal(t, "prod", rec.Header().Get("X-Seen-Env"))
+	})
+
+	t.Run("responses are counted by zone", func(t *testing.T) {
+		labels := map[string]string{"service": "tagged", "backend": "tagged-2", "zone": "zone-b", "class": "2xx"}
+		before := counterValue(t, "discobox_backend_responses_total", labels)
+
+		get("zone-b")
+		get("zone-b")
+
+		assert.Equal(t, before+2, counterValue(t, "discobox_backend_responses_total", labels))
+	})
+}
@@ -0,0 +1,92 @@
+package proxy_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"discobox/internal/proxy"
+	"discobox/internal/types"
+	"discobox/pkg/proxytest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newErrorFormatHarness serves a single route under /api, so any other path
+// gets a proxy-generated 404
+func newErrorFormatHarness(t *testing.T, format string) *proxytest.Harness {
+	h, _ := newHarness(t,
+		[]*types.Service{{ID: "api", Endpoints: []string{"http://api"}, Active: true}},
+		[]*types.Route{{ID: "api", PathPrefix: "/api", ServiceID: "api"}},
+		proxy.Options{ErrorFormat: format},
+	)
+	return h
+}
+
+func TestProxyErrorFormat(t *testing.T) {
+	missing := func(h *proxytest.Harness, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "http://example.com/missing", nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		rec := h.Do(req)
+		require.Equal(t, http.StatusNotFound, rec.Code)
+		return rec
+	}
+
+	assertJSON := func(t *testing.T, rec *httptest.ResponseRecorder) {
+		assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+		var body map[string]string
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		assert.Contains(t, body["error"], types.ErrRouteNotFound.Error())
+	}
+
+	assertText := func(t *testing.T, rec *httptest.ResponseRecorder) {
+		assert.True(t, strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain"))
+		assert.Contains(t, rec.Body.String(), types.ErrRouteNotFound.Error())
+		assert.False(t, json.Valid(rec.Body.Bytes()))
+	}
+
+	t.Run("auto sends JSON to clients accepting it", func(t *testing.T) {
+		h := newErrorFormatHarness(t, proxy.ErrorFormatAuto)
+		for _, accept := range []string{
+			"application/json",
+			"application/json, text/plain;q=0.5",
+			"application/problem+json",
+			"text/html;q=0.8, application/json",
+		} {
+			t.Run(accept, func(t *testing.T) {
+				assertJSON(t, missing(h, accept))
+			})
+		}
+	})
+
+	t.Run("auto sends text to everyone else", func(t *testing.T) {
+		h := newErrorFormatHarness(t, "")
+		for _, accept := range []string{
+			"",
+			"*/*",
+			"text/plain",
+			"text/html, application/json;q=0.9",
+			"application/json;q=0",
+		} {
+			t.Run(accept, func(t *testing.T) {
+				assertText(t, missing(h, accept))
+			})
+		}
+	})
+
+	t.Run("json ignores Accept", func(t *testing.T) {
+		h := newErrorFormatHarness(t, proxy.ErrorFormatJSON)
+		assertJSON(t, missing(h, "text/plain"))
+	})
+
+	t.Run("text ignores Accept", func(t *testing.T) {
+		h := newErrorFormatHarness(t, proxy.ErrorFormatText)
+		assertText(t, missing(h, "application/json"))
+	})
+}
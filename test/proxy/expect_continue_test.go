@@ -0,0 +1,110 @@
+package proxy_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"discobox/internal/middleware"
+	"discobox/internal/proxy"
+	"discobox/internal/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// trackingReader records whether the client started sending the body
+type trackingReader struct {
+	io.Reader
+	read atomic.Bool
+}
+
+func (tr *trackingReader) Read(p []byte) (int, error) {
+	tr.read.Store(true)
+	return tr.Reader.Read(p)
+}
+
+func TestProxyExpectContinue(t *testing.T) {
+	var backendExpect atomic.Value
+	backend := createTestBackend(func(w http.ResponseWriter, r *http.Request) {
+		backendExpect.Store(r.Header.Get("Expect"))
+
+		// Refuse before reading, so no 100 Continue is sent
+		if r.URL.Path == "/reject" {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		// Reading the body sends 100 Continue
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Received", "yes")
+		w.Write(body)
+	})
+	defer backend.Close()
+
+	// The backend is a real server so the transport sees its 100 Continue
+	service := &types.Service{ID: "test-service", Endpoints: []string{backend.URL}, Active: true}
+	h, _ := newServiceHarness(t, service, proxy.Options{Transport: proxy.DefaultTransport(), Logger: &testLogger{}})
+
+	// Middlewares that wrap the response writer must let the interim
+	// response through without mistaking it for the final status
+	handler := middleware.NewChain(
+		middleware.Metrics(),
+		middleware.Retry(middleware.DefaultRetryConfig()),
+	).Then(h.Proxy())
+
+	front := httptest.NewServer(handler)
+	defer front.Close()
+
+	client := &http.Client{
+		Transport: &http.Transport{ExpectContinueTimeout: 5 * time.Second},
+	}
+
+	send := func(path string, body *trackingReader, got100 *atomic.Bool) *http.Response {
+		req, err := http.NewRequest("PUT", front.URL+path, body)
+		require.NoError(t, err)
+		req.ContentLength = 7
+		req.Header.Set("Expect", "100-continue")
+
+		trace := &httptrace.ClientTrace{
+			Got100Continue: func() { got100.Store(true) },
+		}
+		req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		return resp
+	}
+
+	t.Run("body is sent after the backend's 100 Continue", func(t *testing.T) {
+		var got100 atomic.Bool
+		body := &trackingReader{Reader: strings.NewReader("payload")}
+
+		resp := send("/upload", body, &got100)
+		defer resp.Body.Close()
+		received, _ := io.ReadAll(resp.Body)
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "yes", resp.Header.Get("X-Received"))
+		assert.Equal(t, "payload", string(received))
+		assert.True(t, got100.Load(), "client should see 100 Continue")
+		assert.Equal(t, "100-continue", backendExpect.Load())
+	})
+
+	t.Run("rejected upload is never sent", func(t *testing.T) {
+		var got100 atomic.Bool
+		body := &trackingReader{Reader: strings.NewReader("payload")}
+
+		resp := send("/reject", body, &got100)
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+		assert.False(t, got100.Load())
+		assert.False(t, body.read.Load(), "client should not send the body")
+	})
+}
@@ -0,0 +1,87 @@
+package proxy_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"discobox/internal/proxy"
+	"discobox/internal/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProxyForwardedFor(t *testing.T) {
+	h, _ := newServiceHarness(t, &types.Service{
+		ID:        "forwarded",
+		Endpoints: []string{"http://forwarded"},
+		Active:    true,
+	}, proxy.Options{})
+
+	var seen http.Header
+	h.Backend("http://forwarded", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = r.Header.Clone()
+	}))
+
+	tests := []struct {
+		name       string
+		xff        []string
+		realIP     string
+		clientIP   string // Resolved by the client IP middleware
+		wantXFF    string
+		wantRealIP string
+	}{
+		{
+			name:       "peer is appended once",
+			wantXFF:    "192.0.2.1",
+			wantRealIP: "192.0.2.1",
+		},
+		{
+			name:       "chain is normalized",
+			xff:        []string{" 203.0.113.7 , unknown, 10.0.0.2:80"},
+			wantXFF:    "203.0.113.7, 10.0.0.2, 192.0.2.1",
+			wantRealIP: "203.0.113.7",
+		},
+		{
+			name:       "headers are folded into one",
+			xff:        []string{"203.0.113.7", "10.0.0.2"},
+			wantXFF:    "203.0.113.7, 10.0.0.2, 192.0.2.1",
+			wantRealIP: "203.0.113.7",
+		},
+		{
+			name:       "repeats are kept",
+			xff:        []string{"203.0.113.7, 10.0.0.2, 10.0.0.2, 192.0.2.1"},
+			wantXFF:    "203.0.113.7, 10.0.0.2, 10.0.0.2, 192.0.2.1, 192.0.2.1",
+			wantRealIP: "203.0.113.7",
+		},
+		{
+			name:       "resolved client IP replaces a spoofed X-Real-IP",
+			xff:        []string{"6.6.6.6, 203.0.113.7"},
+			realIP:     "6.6.6.6",
+			clientIP:   "203.0.113.7",
+			wantXFF:    "6.6.6.6, 203.0.113.7, 192.0.2.1",
+			wantRealIP: "203.0.113.7",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "http://example.com/", nil)
+			for _, v := range tt.xff {
+				req.Header.Add("X-Forwarded-For", v)
+			}
+			if tt.realIP != "" {
+				req.Header.Set("X-Real-IP", tt.realIP)
+			}
+			if tt.clientIP != "" {
+				req = req.WithContext(types.ContextWithClientIP(req.Context(), tt.clientIP))
+			}
+
+			rec := h.Do(req)
+			require.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, []string{tt.wantXFF}, seen.Values("X-Forwarded-For"))
+			assert.Equal(t, tt.wantRealIP, seen.Get("X-Real-IP"))
+		})
+	}
+}
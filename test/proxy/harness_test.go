@@ -0,0 +1,141 @@
+package proxy_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"discobox/internal/proxy"
+	"discobox/internal/storage"
+	"discobox/internal/types"
+	"discobox/pkg/proxytest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// echoBackend answers with its name and the path it received
+func echoBackend(name string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Backend", name)
+		w.Write([]byte(r.URL.Path))
+	})
+}
+
+// newHarness stores services and routes in memory storage and proxies to
+// them through a test harness built with opts. Services are stored as given,
+// so those meant to serve need Active set. The harness and storage are
+// closed when the test ends.
+func newHarness(t testing.TB, services []*types.Service, routes []*types.Route, opts proxy.Options) (*proxytest.Harness, types.Storage) {
+	t.Helper()
+
+	ctx := context.Background()
+	store := storage.NewMemory()
+	t.Cleanup(func() { store.Close() })
+
+	for _, service := range services {
+		require.NoError(t, store.CreateService(ctx, service))
+	}
+	for _, route := range routes {
+		require.NoError(t, store.CreateRoute(ctx, route))
+	}
+
+	h := proxytest.NewHarness(store, opts)
+	t.Cleanup(func() { h.Close() })
+	return h, store
+}
+
+// newServiceHarness proxies every path to service, through a route with
+// the service's ID
+func newServiceHarness(t testing.TB, service *types.Service, opts proxy.Options) (*proxytest.Harness, types.Storage) {
+	t.Helper()
+	route := &types.Route{ID: service.ID, PathPrefix: "/", ServiceID: service.ID}
+	return newHarness(t, []*types.Service{service}, []*types.Route{route}, opts)
+}
+
+func TestHarnessRoutesRequests(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemory()
+	defer store.Close()
+
+	require.NoError(t, store.CreateService(ctx, &types.Service{
+		ID:          "users",
+		Endpoints:   []string{"http://users-1", "http://users-2"},
+		StripPrefix: true,
+		Active:      true,
+	}))
+	require.NoError(t, store.CreateService(ctx, &types.Service{
+		ID:        "web",
+		Endpoints: []string{"http://web"},
+		Active:    true,
+	}))
+	require.NoError(t, store.CreateRoute(ctx, &types.Route{
+		ID:         "users-api",
+		Priority:   10,
+		PathPrefix: "/api/users",
+		ServiceID:  "users",
+	}))
+	require.NoError(t, store.CreateRoute(ctx, &types.Route{
+		ID:         "catch-all",
+		PathPrefix: "/",
+		ServiceID:  "web",
+	}))
+
+	h := proxytest.NewHarness(store, proxy.Options{})
+	defer h.Close()
+
+	h.Backend("http://users-1", echoBackend("users-1"))
+	h.Backend("http://users-2", echoBackend("users-2"))
+	h.Backend("http://web", echoBackend("web"))
+
+	t.Run("more specific route wins and strips its prefix", func(t *testing.T) {
+		rec := h.Do(httptest.NewRequest("GET", "http://example.com/api/users/42", nil))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, []string{"users-1", "users-2"}, rec.Header().Get("X-Backend"))
+		assert.Equal(t, "/42", rec.Body.String())
+	})
+
+	t.Run("requests are balanced across endpoints", func(t *testing.T) {
+		seen := make(map[string]int)
+		for i := 0; i < 4; i++ {
+			rec := h.Do(httptest.NewRequest("GET", "http://example.com/api/users", nil))
+			seen[rec.Header().Get("X-Backend")]++
+		}
+		assert.Equal(t, map[string]int{"users-1": 2, "users-2": 2}, seen)
+	})
+
+	t.Run("other paths fall through", func(t *testing.T) {
+		rec := h.Do(httptest.NewRequest("GET", "http://example.com/about", nil))
+		assert.Equal(t, "web", rec.Header().Get("X-Backend"))
+		assert.Equal(t, "/about", rec.Body.String())
+	})
+}
+
+func TestHarnessMissingBackend(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemory()
+	defer store.Close()
+
+	require.NoError(t, store.CreateService(ctx, &types.Service{
+		ID:        "orders",
+		Endpoints: []string{"http://orders"},
+		Active:    true,
+	}))
+	require.NoError(t, store.CreateRoute(ctx, &types.Route{
+		ID:        "orders",
+		Host:      "orders.example.com",
+		ServiceID: "orders",
+	}))
+
+	h := proxytest.NewHarness(store, proxy.Options{})
+	defer h.Close()
+
+	// No backend registered for the endpoint
+	rec := h.Do(httptest.NewRequest("GET", "http://orders.example.com/", nil))
+	assert.Equal(t, http.StatusBadGateway, rec.Code)
+
+	// Hosts no route matches
+	rec = h.Do(httptest.NewRequest("GET", "http://unknown.example.com/", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
@@ -0,0 +1,80 @@
+package proxy_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"discobox/internal/proxy"
+	"discobox/internal/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProxyHeadRequests(t *testing.T) {
+	var methods []string
+	backend := createTestBackend(func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Length", "17")
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte(`{"status":"fine"}`))
+	})
+	defer backend.Close()
+
+	storage := newMockStorage()
+	service := &types.Service{
+		ID:        "test-service",
+		Endpoints: []string{backend.URL},
+		Active:    true,
+	}
+	storage.CreateService(context.Background(), service)
+
+	route := &types.Route{ID: "test-route", ServiceID: service.ID, PathPrefix: "/api"}
+	p := proxy.New(proxy.Options{
+		Router: &mockRouter{
+			matchFunc: func(req *http.Request) (*types.Route, error) {
+				if req.URL.Path == "/missing" {
+					return nil, types.ErrRouteNotFound
+				}
+				return route, nil
+			},
+		},
+		LoadBalancer: &mockLoadBalancer{
+			selectFunc: func(ctx context.Context, req *http.Request, servers []*types.Server) (*types.Server, error) {
+				return servers[0], nil
+			},
+		},
+		Storage: storage,
+		Logger:  &testLogger{},
+	})
+
+	t.Run("headers are kept and the body dropped", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, httptest.NewRequest("HEAD", "http://example.com/api/status", nil))
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "17", rec.Header().Get("Content-Length"))
+		assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+		assert.Equal(t, `"v1"`, rec.Header().Get("ETag"))
+		assert.Empty(t, rec.Body.String())
+		assert.Equal(t, []string{"HEAD"}, methods, "HEAD should reach the backend unchanged")
+	})
+
+	t.Run("GET still returns the body", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, httptest.NewRequest("GET", "http://example.com/api/status", nil))
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, `{"status":"fine"}`, rec.Body.String())
+	})
+
+	t.Run("error responses have no body", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, httptest.NewRequest("HEAD", "http://example.com/missing", nil))
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Empty(t, rec.Body.String())
+	})
+}
@@ -0,0 +1,133 @@
+package proxy_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"discobox/internal/balancer"
+	"discobox/internal/proxy"
+	"discobox/internal/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProxyHealthOverrideDrainsEndpoint(t *testing.T) {
+	ctx := context.Background()
+	h, _ := newServiceHarness(t, &types.Service{
+		ID:        "api",
+		Endpoints: []string{"http://one", "http://two"},
+		Active:    true,
+	}, proxy.Options{LoadBalancer: balancer.NewRoundRobin()})
+
+	hits := make(map[string]int)
+	h.Backend("http://one", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { hits["one"]++ }))
+	h.Backend("http://two", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { hits["two"]++ }))
+
+	send := func(n int) {
+		for i := 0; i < n; i++ {
+			rec := h.Do(httptest.NewRequest("GET", "http://example.com/", nil))
+			require.Equal(t, http.StatusOK, rec.Code)
+		}
+	}
+
+	h.Proxy().OverrideHealth("api", "http://two", false)
+	send(10)
+	assert.Equal(t, 10, hits["one"])
+	assert.Zero(t, hits["two"], "manually downed endpoint should receive no traffic")
+
+	stats, err := h.Proxy().RuntimeStats(ctx)
+	require.NoError(t, err)
+	for _, backend := range stats.Backends {
+		if backend.ID == "api-1" {
+			assert.False(t, backend.Healthy)
+			require.NotNil(t, backend.HealthOverride)
+			assert.False(t, *backend.HealthOverride)
+		}
+	}
+
+	h.Proxy().ClearHealthOverride("api", "http://two")
+	send(10)
+	assert.Equal(t, 5, hits["two"], "cleared endpoint should resume receiving traffic")
+}
+
+func TestProxyHealthOverrideAllDown(t *testing.T) {
+	h, _ := newServiceHarness(t, &types.Service{
+		ID:        "api",
+		Endpoints: []string{"http://one"},
+		Active:    true,
+	}, proxy.Options{LoadBalancer: balancer.NewRoundRobin()})
+	h.Backend("http://one", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	// Unlike outlier ejection, a manual drain is honored even when it
+	// leaves no backend to serve
+	h.Proxy().OverrideHealth("api", "http://one", false)
+	rec := h.Do(httptest.NewRequest("GET", "http://example.com/", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+}
+
+func TestProxyHealthOverrideFollowsEndpoint(t *testing.T) {
+	ctx := context.Background()
+	h, store := newServiceHarness(t, &types.Service{
+		ID:        "api",
+		Endpoints: []string{"http://one", "http://two"},
+		Active:    true,
+	}, proxy.Options{LoadBalancer: balancer.NewRoundRobin()})
+
+	var mu sync.Mutex
+	hits := make(map[string]int)
+	for _, name := range []string{"one", "two", "three"} {
+		h.Backend("http://"+name, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			hits[name]++
+			mu.Unlock()
+		}))
+	}
+
+	send := func(n int) {
+		for i := 0; i < n; i++ {
+			rec := h.Do(httptest.NewRequest("GET", "http://example.com/", nil))
+			require.Equal(t, http.StatusOK, rec.Code)
+		}
+	}
+
+	h.Proxy().OverrideHealth("api", "http://two", false)
+
+	// Reordering the endpoints keeps the override on the downed one
+	service, err := store.GetService(ctx, "api")
+	require.NoError(t, err)
+	service.Endpoints = []string{"http://three", "http://two", "http://one"}
+	require.NoError(t, store.UpdateService(ctx, service))
+
+	assert.Eventually(t, func() bool {
+		send(1)
+		mu.Lock()
+		defer mu.Unlock()
+		return hits["three"] > 0
+	}, 2*time.Second, 10*time.Millisecond)
+
+	mu.Lock()
+	before := hits["two"]
+	mu.Unlock()
+	send(10)
+	mu.Lock()
+	assert.Equal(t, before, hits["two"], "downed endpoint should stay out after reordering")
+	mu.Unlock()
+
+	// Removing the endpoint drops its override
+	service.Endpoints = []string{"http://three", "http://one"}
+	require.NoError(t, store.UpdateService(ctx, service))
+	service.Endpoints = []string{"http://three", "http://one", "http://two"}
+	require.NoError(t, store.UpdateService(ctx, service))
+
+	assert.Eventually(t, func() bool {
+		send(1)
+		mu.Lock()
+		defer mu.Unlock()
+		return hits["two"] > before
+	}, 2*time.Second, 10*time.Millisecond)
+}
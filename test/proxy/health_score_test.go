@@ -0,0 +1,65 @@
+package proxy_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"discobox/internal/balancer"
+	"discobox/internal/circuit"
+	"discobox/internal/proxy"
+	"discobox/internal/types"
+	"discobox/pkg/proxytest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newHealthScoreHarness balances over a healthy endpoint and one failing
+// every third request, reporting hits per endpoint
+func newHealthScoreHarness(t *testing.T, scorer types.HealthScorer) (*proxytest.Harness, map[string]int) {
+	h, _ := newServiceHarness(t, &types.Service{
+		ID:        "api",
+		Endpoints: []string{"http://healthy", "http://flaky"},
+		Active:    true,
+	}, proxy.Options{
+		LoadBalancer: balancer.NewSmoothWeightedRoundRobin(),
+		HealthScorer: scorer,
+	})
+
+	hits := make(map[string]int)
+	h.Backend("http://healthy", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits["healthy"]++
+	}))
+	h.Backend("http://flaky", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits["flaky"]++
+		if hits["flaky"]%3 == 0 {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	return h, hits
+}
+
+func TestProxyHealthScoreShedsLoad(t *testing.T) {
+	h, hits := newHealthScoreHarness(t, circuit.NewHealthScorer(20, 0))
+
+	for i := 0; i < 600; i++ {
+		h.Do(httptest.NewRequest("GET", "http://example.com/", nil))
+	}
+
+	// A third of the flaky backend's requests fail, so it is weighted at
+	// about two thirds of the healthy one: reduced, but still serving
+	share := float64(hits["flaky"]) / 600
+	assert.Greater(t, share, 0.3, "flaky backend should keep receiving traffic")
+	assert.Less(t, share, 0.45, "flaky backend should receive less traffic")
+}
+
+func TestProxyWithoutHealthScoreSplitsEvenly(t *testing.T) {
+	h, hits := newHealthScoreHarness(t, nil)
+
+	for i := 0; i < 600; i++ {
+		h.Do(httptest.NewRequest("GET", "http://example.com/", nil))
+	}
+
+	assert.Equal(t, 300, hits["flaky"])
+	assert.Equal(t, 300, hits["healthy"])
+}
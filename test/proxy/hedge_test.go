@@ -0,0 +1,112 @@
+package proxy_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"discobox/internal/proxy"
+	"discobox/internal/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProxyHedging(t *testing.T) {
+	var slowHits, fastHits atomic.Int32
+	slowCanceled := make(chan struct{}, 10)
+
+	// The first backend stalls until the client gives up on it
+	slow := createTestBackend(func(w http.ResponseWriter, r *http.Request) {
+		slowHits.Add(1)
+		select {
+		case <-r.Context().Done():
+			slowCanceled <- struct{}{}
+			return
+		case <-time.After(500 * time.Millisecond):
+		}
+		w.Write([]byte("slow"))
+	})
+	defer slow.Close()
+
+	fast := createTestBackend(func(w http.ResponseWriter, r *http.Request) {
+		fastHits.Add(1)
+		w.Write([]byte("fast"))
+	})
+	defer fast.Close()
+
+	storage := newMockStorage()
+	service := &types.Service{
+		ID:        "test-service",
+		Endpoints: []string{slow.URL, fast.URL},
+		Active:    true,
+	}
+	storage.CreateService(context.Background(), service)
+
+	route := &types.Route{
+		ID:        "test-route",
+		ServiceID: service.ID,
+		Hedging:   &types.HedgePolicy{Delay: 20 * time.Millisecond},
+	}
+
+	p := proxy.New(proxy.Options{
+		Router: &mockRouter{
+			matchFunc: func(req *http.Request) (*types.Route, error) {
+				return route, nil
+			},
+		},
+		// Always the first candidate: the slow backend, then the fast one for the hedge
+		LoadBalancer: &mockLoadBalancer{
+			selectFunc: func(ctx context.Context, req *http.Request, servers []*types.Server) (*types.Server, error) {
+				return servers[0], nil
+			},
+		},
+		Storage: storage,
+		Logger:  &testLogger{},
+	})
+
+	t.Run("hedge wins when the first backend is slow", func(t *testing.T) {
+		start := time.Now()
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, httptest.NewRequest("GET", "http://example.com/data", nil))
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "fast", rec.Body.String())
+		assert.Less(t, time.Since(start), 400*time.Millisecond)
+		assert.Equal(t, int32(1), slowHits.Load())
+		assert.Equal(t, int32(1), fastHits.Load())
+
+		select {
+		case <-slowCanceled:
+		case <-time.After(time.Second):
+			t.Fatal("slow request was not canceled")
+		}
+	})
+
+	t.Run("non-idempotent methods are never hedged", func(t *testing.T) {
+		slowBefore, fastBefore := slowHits.Load(), fastHits.Load()
+
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, httptest.NewRequest("POST", "http://example.com/data", strings.NewReader("payload")))
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "slow", rec.Body.String())
+		assert.Equal(t, slowBefore+1, slowHits.Load())
+		assert.Equal(t, fastBefore, fastHits.Load())
+	})
+
+	t.Run("responses within the delay are not hedged", func(t *testing.T) {
+		route.Hedging = &types.HedgePolicy{Delay: time.Second}
+		slowBefore, fastBefore := slowHits.Load(), fastHits.Load()
+
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, httptest.NewRequest("GET", "http://example.com/data", nil))
+
+		assert.Equal(t, "slow", rec.Body.String())
+		assert.Equal(t, slowBefore+1, slowHits.Load())
+		assert.Equal(t, fastBefore, fastHits.Load())
+	})
+}
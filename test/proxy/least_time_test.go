@@ -0,0 +1,61 @@
+package proxy_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"discobox/internal/balancer"
+	"discobox/internal/proxy"
+	"discobox/internal/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProxyFeedsLeastTimeBalancer(t *testing.T) {
+	var fastHits, slowHits atomic.Int32
+
+	fast := createTestBackend(func(w http.ResponseWriter, r *http.Request) {
+		fastHits.Add(1)
+	})
+	defer fast.Close()
+
+	slow := createTestBackend(func(w http.ResponseWriter, r *http.Request) {
+		slowHits.Add(1)
+		time.Sleep(20 * time.Millisecond)
+	})
+	defer slow.Close()
+
+	storage := newMockStorage()
+	service := &types.Service{
+		ID:        "test-service",
+		Endpoints: []string{fast.URL, slow.URL},
+		Active:    true,
+	}
+	storage.CreateService(context.Background(), service)
+
+	route := &types.Route{ID: "test-route", ServiceID: service.ID}
+
+	p := proxy.New(proxy.Options{
+		Router: &mockRouter{
+			matchFunc: func(req *http.Request) (*types.Route, error) {
+				return route, nil
+			},
+		},
+		LoadBalancer: balancer.NewLeastTime(),
+		Storage:      storage,
+		Logger:       &testLogger{},
+	})
+
+	for i := 0; i < 20; i++ {
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, httptest.NewRequest("GET", "http://example.com/", nil))
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}
+
+	// Once both have been measured the slow backend only wins on ties
+	assert.Greater(t, fastHits.Load(), 3*slowHits.Load())
+}
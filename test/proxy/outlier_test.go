@@ -0,0 +1,146 @@
+package proxy_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"discobox/internal/circuit"
+	"discobox/internal/proxy"
+	"discobox/internal/types"
+	"discobox/pkg/proxytest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newOutlierHarness proxies to the given backends, always picking the first
+// healthy one so ejection decides where traffic goes
+func newOutlierHarness(t *testing.T, outliers types.OutlierDetector, backends ...http.HandlerFunc) *proxytest.Harness {
+	service := &types.Service{ID: "test-service", Active: true}
+	for i := range backends {
+		service.Endpoints = append(service.Endpoints, fmt.Sprintf("http://backend-%d", i))
+	}
+
+	h, _ := newServiceHarness(t, service, proxy.Options{
+		LoadBalancer: &mockLoadBalancer{
+			selectFunc: func(ctx context.Context, req *http.Request, servers []*types.Server) (*types.Server, error) {
+				for _, server := range servers {
+					if server.Healthy {
+						return server, nil
+					}
+				}
+				return nil, types.ErrNoHealthyBackends
+			},
+		},
+		OutlierDetector: outliers,
+		Logger:          &testLogger{},
+	})
+	for i, backend := range backends {
+		h.Backend(service.Endpoints[i], backend)
+	}
+	return h
+}
+
+func TestProxyOutlierEjection(t *testing.T) {
+	var flakyFailing atomic.Bool
+	flakyFailing.Store(true)
+	var flakyHits, stableHits atomic.Int32
+
+	flaky := func(w http.ResponseWriter, r *http.Request) {
+		flakyHits.Add(1)
+		if flakyFailing.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+	stable := func(w http.ResponseWriter, r *http.Request) {
+		stableHits.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}
+
+	base := 100 * time.Millisecond
+	outliers := circuit.NewOutlierDetector(3, time.Second, base, time.Second, &testLogger{})
+	h := newOutlierHarness(t, outliers, flaky, stable)
+
+	send := func() int {
+		return h.Do(httptest.NewRequest("GET", "http://example.com/", nil)).Code
+	}
+
+	// Three consecutive 5xx responses eject the flaky backend
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusInternalServerError, send())
+	}
+	assert.True(t, outliers.IsEjected("test-service-0"))
+
+	assert.Equal(t, http.StatusOK, send())
+	assert.Equal(t, int32(3), flakyHits.Load())
+	assert.Equal(t, int32(1), stableHits.Load())
+
+	// After the ejection time it is probed; failing the probe ejects it for longer
+	time.Sleep(base + 20*time.Millisecond)
+	assert.Equal(t, http.StatusInternalServerError, send())
+	assert.Equal(t, int32(4), flakyHits.Load())
+
+	flakyFailing.Store(false)
+
+	time.Sleep(base + 20*time.Millisecond)
+	assert.Equal(t, http.StatusOK, send())
+	assert.Equal(t, int32(4), flakyHits.Load(), "second ejection lasts twice as long")
+
+	// Once the longer ejection is over, a passed probe re-admits it
+	time.Sleep(base)
+	assert.Equal(t, http.StatusOK, send())
+	assert.Equal(t, int32(5), flakyHits.Load())
+	assert.False(t, outliers.IsEjected("test-service-0"))
+
+	assert.Equal(t, http.StatusOK, send())
+	assert.Equal(t, int32(6), flakyHits.Load())
+}
+
+func TestProxyOutlierErrorsOutsideWindow(t *testing.T) {
+	backend := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}
+	other := func(w http.ResponseWriter, r *http.Request) {}
+
+	window := 50 * time.Millisecond
+	outliers := circuit.NewOutlierDetector(2, window, time.Minute, time.Minute, &testLogger{})
+	h := newOutlierHarness(t, outliers, backend, other)
+
+	send := func() {
+		h.Do(httptest.NewRequest("GET", "http://example.com/", nil))
+	}
+
+	// Errors further apart than the window don't add up
+	send()
+	time.Sleep(window + 20*time.Millisecond)
+	send()
+	assert.False(t, outliers.IsEjected("test-service-0"))
+
+	send()
+	assert.True(t, outliers.IsEjected("test-service-0"))
+}
+
+func TestProxyOutlierNeverEjectsEveryBackend(t *testing.T) {
+	var hits atomic.Int32
+	backend := func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	outliers := circuit.NewOutlierDetector(1, time.Second, time.Minute, time.Minute, &testLogger{})
+	h := newOutlierHarness(t, outliers, backend)
+
+	for i := 0; i < 3; i++ {
+		rec := h.Do(httptest.NewRequest("GET", "http://example.com/", nil))
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	}
+
+	assert.True(t, outliers.IsEjected("test-service-0"))
+	assert.Equal(t, int32(3), hits.Load(), "the only backend keeps serving while ejected")
+}
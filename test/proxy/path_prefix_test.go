@@ -0,0 +1,276 @@
+package proxy_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"discobox/internal/proxy"
+	"discobox/internal/storage"
+	"discobox/internal/types"
+	"discobox/pkg/proxytest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProxyStripPathPrefix(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemory()
+	defer store.Close()
+
+	require.NoError(t, store.CreateService(ctx, &types.Service{
+		ID:        "reports",
+		Endpoints: []string{"http://reports"},
+		Active:    true,
+	}))
+	require.NoError(t, store.CreateService(ctx, &types.Service{
+		ID:          "api",
+		Endpoints:   []string{"http://api"},
+		StripPrefix: true,
+		Active:      true,
+	}))
+
+	routes := []*types.Route{
+		{
+			ID:              "reports",
+			Priority:        10,
+			PathRegex:       "^/(reports|archive)/[0-9]{4}/",
+			StripPathPrefix: "/reports",
+			ServiceID:       "reports",
+			Redirects:       &types.RedirectPolicy{Mode: types.RedirectRewrite},
+		},
+		{
+			ID:              "api-v1",
+			Priority:        5,
+			PathPrefix:      "/api",
+			StripPathPrefix: "/api/v1",
+			ServiceID:       "api",
+		},
+		{
+			ID:         "legacy",
+			PathPrefix: "/legacy",
+			ServiceID:  "api",
+		},
+	}
+	for _, route := range routes {
+		require.NoError(t, store.CreateRoute(ctx, route))
+	}
+
+	h := proxytest.NewHarness(store, proxy.Options{})
+	defer h.Close()
+
+	// Backends echo the path they received; /next paths redirect
+	echo := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/2024/next" || r.URL.Path == "/archive/2024/next" {
+			http.Redirect(w, r, "http://reports"+r.URL.Path+"/page", http.StatusFound)
+			return
+		}
+		w.Write([]byte(r.URL.Path))
+	})
+	h.Backend("http://reports", echo)
+	h.Backend("http://api", echo)
+
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{"regex route strips the configured prefix", "/reports/2024/q1", "/2024/q1"},
+		{"paths without the prefix are forwarded unchanged", "/archive/2024/q1", "/archive/2024/q1"},
+		{"configured prefix replaces the matched prefix", "/api/v1/users", "/users"},
+		{"unset keeps stripping the matched prefix", "/legacy/users", "/users"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := h.Do(httptest.NewRequest("GET", "http://example.com"+tt.path, nil))
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, tt.want, rec.Body.String())
+		})
+	}
+
+	t.Run("rewritten redirects restore the stripped prefix", func(t *testing.T) {
+		rec := h.Do(httptest.NewRequest("GET", "http://example.com/reports/2024/next", nil))
+		assert.Equal(t, http.StatusFound, rec.Code)
+		assert.Equal(t, "http://example.com/reports/2024/next/page", rec.Header().Get("Location"))
+
+		// Nothing was stripped, so nothing is restored
+		rec = h.Do(httptest.NewRequest("GET", "http://example.com/archive/2024/next", nil))
+		assert.Equal(t, http.StatusFound, rec.Code)
+		assert.Equal(t, "http://example.com/archive/2024/next/page", rec.Header().Get("Location"))
+	})
+}
+
+func TestProxyAddPathPrefix(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemory()
+	defer store.Close()
+
+	require.NoError(t, store.CreateService(ctx, &types.Service{
+		ID:        "backend",
+		Endpoints: []string{"http://backend"},
+		Active:    true,
+	}))
+	require.NoError(t, store.CreateService(ctx, &types.Service{
+		ID:          "shop",
+		Endpoints:   []string{"http://backend"},
+		StripPrefix: true,
+		Active:      true,
+	}))
+
+	routes := []*types.Route{
+		{
+			ID:            "public",
+			PathPrefix:    "/public",
+			AddPathPrefix: "/api",
+			ServiceID:     "backend",
+			Redirects:     &types.RedirectPolicy{Mode: types.RedirectRewrite},
+		},
+		{
+			ID:            "shop",
+			PathPrefix:    "/shop",
+			AddPathPrefix: "/store/v2/",
+			ServiceID:     "shop",
+			Redirects:     &types.RedirectPolicy{Mode: types.RedirectRewrite},
+		},
+		{
+			ID:            "old",
+			PathPrefix:    "/old",
+			AddPathPrefix: "/api",
+			ServiceID:     "backend",
+			RewriteRules: []types.RewriteRule{
+				{Type: "regex", Pattern: "^/old/(.*)$", Replacement: "/new/$1"},
+			},
+		},
+	}
+	for _, route := range routes {
+		require.NoError(t, store.CreateRoute(ctx, route))
+	}
+
+	h := proxytest.NewHarness(store, proxy.Options{})
+	defer h.Close()
+
+	h.Backend("http://backend", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/public/moved", "/store/v2/moved":
+			http.Redirect(w, r, "http://backend"+r.URL.Path+"/here", http.StatusFound)
+		case "/api/public/login":
+			http.Redirect(w, r, "http://backend/login", http.StatusFound)
+		default:
+			w.Write([]byte(r.URL.Path))
+		}
+	}))
+
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{"prefix is added to the matched path", "/public/items", "/api/public/items"},
+		{"composes with strip prefix", "/shop/cart", "/store/v2/cart"},
+		{"applies after rewrite rules", "/old/thing", "/api/new/thing"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := h.Do(httptest.NewRequest("GET", "http://example.com"+tt.path, nil))
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, tt.want, rec.Body.String())
+		})
+	}
+
+	t.Run("rewritten redirects drop the added prefix", func(t *testing.T) {
+		rec := h.Do(httptest.NewRequest("GET", "http://example.com/public/moved", nil))
+		assert.Equal(t, "http://example.com/public/moved/here", rec.Header().Get("Location"))
+
+		rec = h.Do(httptest.NewRequest("GET", "http://example.com/shop/moved", nil))
+		assert.Equal(t, "http://example.com/shop/moved/here", rec.Header().Get("Location"))
+
+		// Outside the backend's mount point, so left alone
+		rec = h.Do(httptest.NewRequest("GET", "http://example.com/public/login", nil))
+		assert.Equal(t, "http://example.com/login", rec.Header().Get("Location"))
+	})
+}
+
+func TestProxyForwardedPrefix(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemory()
+	defer store.Close()
+
+	require.NoError(t, store.CreateService(ctx, &types.Service{
+		ID:          "api",
+		Endpoints:   []string{"http://api"},
+		StripPrefix: true,
+		Active:      true,
+	}))
+	require.NoError(t, store.CreateService(ctx, &types.Service{
+		ID:        "web",
+		Endpoints: []string{"http://web"},
+		Active:    true,
+	}))
+
+	routes := []*types.Route{
+		{ID: "api", Priority: 10, PathPrefix: "/api/", ServiceID: "api"},
+		{ID: "reports", Priority: 10, PathPrefix: "/reports", StripPathPrefix: "/reports", ServiceID: "web"},
+		{ID: "web", PathPrefix: "/", ServiceID: "web"},
+	}
+	for _, route := range routes {
+		require.NoError(t, store.CreateRoute(ctx, route))
+	}
+
+	// Backends echo the prefix they were told about
+	echo := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get(proxy.ForwardedPrefixHeader)))
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		h := proxytest.NewHarness(store, proxy.Options{ForwardedPrefix: true})
+		defer h.Close()
+		h.Backend("http://api", echo)
+		h.Backend("http://web", echo)
+
+		tests := []struct {
+			name   string
+			path   string
+			header string
+			want   string
+		}{
+			{"service strip prefix", "/api/users", "", "/api"},
+			{"route strip prefix", "/reports/2024", "", "/reports"},
+			{"nothing stripped", "/index.html", "", ""},
+			{"client value is replaced", "/api/users", "/evil", "/api"},
+			{"client value is dropped when nothing is stripped", "/index.html", "/evil", ""},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				req := httptest.NewRequest("GET", "http://example.com"+tt.path, nil)
+				if tt.header != "" {
+					req.Header.Set(proxy.ForwardedPrefixHeader, tt.header)
+				}
+				rec := h.Do(req)
+				assert.Equal(t, http.StatusOK, rec.Code)
+				assert.Equal(t, tt.want, rec.Body.String())
+			})
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		h := proxytest.NewHarness(store, proxy.Options{})
+		defer h.Close()
+		h.Backend("http://api", echo)
+
+		rec := h.Do(httptest.NewRequest("GET", "http://example.com/api/users", nil))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Empty(t, rec.Body.String())
+
+		// A client can't pass its own value through either
+		req := httptest.NewRequest("GET", "http://example.com/api/users", nil)
+		req.Header.Set(proxy.ForwardedPrefixHeader, "/evil")
+		rec = h.Do(req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Empty(t, rec.Body.String())
+	})
+}
@@ -1,10 +1,13 @@
 package proxy_test
 
 import (
+	"bufio"
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -135,11 +138,15 @@ func (m *mockStorage) DeleteService(ctx context.Context, id string) error {
 func (m *mockStorage) GetRoute(ctx context.Context, id string) (*types.Route, error) {
 	return nil, types.ErrRouteNotFound
 }
-func (m *mockStorage) ListRoutes(ctx context.Context) ([]*types.Route, error)      { return nil, nil }
-func (m *mockStorage) CreateRoute(ctx context.Context, route *types.Route) error   { return nil }
-func (m *mockStorage) UpdateRoute(ctx context.Context, route *types.Route) error   { return nil }
-func (m *mockStorage) DeleteRoute(ctx context.Context, id string) error            { return nil }
-func (m *mockStorage) GetUser(ctx context.Context, id string) (*types.User, error) { return nil, nil }
+func (m *mockStorage) ListRoutes(ctx context.Context) ([]*types.Route, error)    { return nil, nil }
+func (m *mockStorage) CreateRoute(ctx context.Context, route *types.Route) error { return nil }
+func (m *mockStorage) UpdateRoute(ctx context.Context, route *types.Route) error { return nil }
+func (m *mockStorage) DeleteRoute(ctx context.Context, id string) error          { return nil }
+func (m *mockStorage) ListRoutesByGroup(ctx context.Context, group string) ([]*types.Route, error) {
+	return nil, nil
+}
+func (m *mockStorage) DeleteRouteGroup(ctx context.Context, group string) (int, error) { return 0, nil }
+func (m *mockStorage) GetUser(ctx context.Context, id string) (*types.User, error)     { return nil, nil }
 func (m *mockStorage) GetUserByEmail(ctx context.Context, email string) (*types.User, error) {
 	return nil, nil
 }
@@ -159,8 +166,20 @@ func (m *mockStorage) ListAPIKeysByUser(ctx context.Context, userID string) ([]*
 }
 func (m *mockStorage) CreateAPIKey(ctx context.Context, apiKey *types.APIKey) error { return nil }
 func (m *mockStorage) RevokeAPIKey(ctx context.Context, key string) error           { return nil }
-func (m *mockStorage) Watch(ctx context.Context) <-chan types.StorageEvent          { return nil }
-func (m *mockStorage) Close() error                                                 { return nil }
+func (m *mockStorage) RevokeAllAPIKeysByUser(ctx context.Context, userID string) (int, error) {
+	return 0, nil
+}
+func (m *mockStorage) CreatePasswordResetToken(ctx context.Context, token *types.PasswordResetToken) error {
+	return nil
+}
+func (m *mockStorage) ConsumePasswordResetToken(ctx context.Context, tokenHash string) (*types.PasswordResetToken, error) {
+	return nil, types.ErrInvalidToken
+}
+func (m *mockStorage) Tx(ctx context.Context, fn func(types.Storage) error) error {
+	return fn(m)
+}
+func (m *mockStorage) Watch(ctx context.Context) <-chan types.StorageEvent { return nil }
+func (m *mockStorage) Close() error                                        { return nil }
 
 type testLogger struct{}
 
@@ -349,6 +368,131 @@ func TestProxyNoHealthyBackends(t *testing.T) {
 	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
 }
 
+func TestProxyAllBackendsUnhealthy(t *testing.T) {
+	storage := newMockStorage()
+	service := &types.Service{
+		ID:        "test-service",
+		Name:      "Test Service",
+		Endpoints: []string{"http://backend1:8080", "http://backend2:8080"},
+		Active:    true,
+	}
+	storage.CreateService(context.Background(), service)
+
+	route := &types.Route{
+		ID:        "test-route",
+		ServiceID: service.ID,
+	}
+
+	router := &mockRouter{
+		matchFunc: func(req *http.Request) (*types.Route, error) {
+			return route, nil
+		},
+	}
+
+	// Mark every backend down and let the balancer find nothing to pick
+	loadBalancer := &mockLoadBalancer{
+		selectFunc: func(ctx context.Context, req *http.Request, servers []*types.Server) (*types.Server, error) {
+			for _, server := range servers {
+				server.Healthy = false
+			}
+			return nil, types.ErrNoHealthyBackends
+		},
+	}
+
+	p := proxy.New(proxy.Options{
+		Router:       router,
+		LoadBalancer: loadBalancer,
+		Storage:      storage,
+		Logger:       &testLogger{},
+		RetryAfter:   1500 * time.Millisecond,
+	})
+
+	req := httptest.NewRequest("GET", "http://example.com/api/test", nil)
+	req.Header.Set("Accept", "application/json")
+	rec := httptest.NewRecorder()
+
+	p.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, "2", rec.Header().Get("Retry-After"))
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+	var body map[string]any
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, "Service temporarily unavailable", body["error"])
+	assert.Equal(t, float64(2), body["retry_after"])
+
+	t.Run("plain text for clients not asking for JSON", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, httptest.NewRequest("GET", "http://example.com/api/test", nil))
+
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+		assert.Equal(t, "2", rec.Header().Get("Retry-After"))
+		assert.Contains(t, rec.Header().Get("Content-Type"), "text/plain")
+		assert.Equal(t, "Service temporarily unavailable\n", rec.Body.String())
+	})
+
+	t.Run("custom error handler still gets Retry-After", func(t *testing.T) {
+		p := proxy.New(proxy.Options{
+			Router:       router,
+			LoadBalancer: loadBalancer,
+			Storage:      storage,
+			Logger:       &testLogger{},
+			RetryAfter:   10 * time.Second,
+			ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			},
+		})
+
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, httptest.NewRequest("GET", "http://example.com/api/test", nil))
+
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+		assert.Equal(t, "10", rec.Header().Get("Retry-After"))
+	})
+
+	t.Run("no Retry-After when disabled", func(t *testing.T) {
+		p := proxy.New(proxy.Options{
+			Router:       router,
+			LoadBalancer: loadBalancer,
+			Storage:      storage,
+			Logger:       &testLogger{},
+		})
+
+		req := httptest.NewRequest("GET", "http://example.com/api/test", nil)
+		req.Header.Set("Accept", "application/json")
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+		assert.Empty(t, rec.Header().Get("Retry-After"))
+
+		var body map[string]any
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		assert.NotContains(t, body, "retry_after")
+	})
+
+	t.Run("missing service has no Retry-After", func(t *testing.T) {
+		missing := &mockRouter{
+			matchFunc: func(req *http.Request) (*types.Route, error) {
+				return &types.Route{ID: "orphan-route", ServiceID: "missing"}, nil
+			},
+		}
+		p := proxy.New(proxy.Options{
+			Router:       missing,
+			LoadBalancer: loadBalancer,
+			Storage:      storage,
+			Logger:       &testLogger{},
+		})
+
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, httptest.NewRequest("GET", "http://example.com/api/test", nil))
+
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+		assert.Empty(t, rec.Header().Get("Retry-After"))
+	})
+}
+
 func TestProxyCircuitBreakerOpen(t *testing.T) {
 	backend := createTestBackend(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
@@ -597,7 +741,7 @@ func TestProxyTimeout(t *testing.T) {
 	p.ServeHTTP(rec, req)
 
 	// Should timeout
-	assert.Equal(t, http.StatusBadGateway, rec.Code)
+	assert.Equal(t, http.StatusGatewayTimeout, rec.Code)
 }
 
 func TestProxyConcurrency(t *testing.T) {
@@ -845,6 +989,135 @@ func TestProxyWebSocketUpgrade(t *testing.T) {
 	assert.NotEqual(t, http.StatusNotFound, rec.Code)
 }
 
+// newLongLivedBackend returns a backend that echoes bytes back over upgraded
+// connections and otherwise streams a handful of slow server-sent events
+func newLongLivedBackend(t *testing.T) *httptest.Server {
+	return createTestBackend(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Upgrade") == "" {
+			w.Header().Set("Content-Type", "text/event-stream")
+			for i := 0; i < 5; i++ {
+				fmt.Fprintf(w, "data: %d\n\n", i)
+				w.(http.Flusher).Flush()
+				time.Sleep(80 * time.Millisecond)
+			}
+			return
+		}
+
+		conn, brw, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			t.Errorf("backend hijack failed: %v", err)
+			return
+		}
+		defer conn.Close()
+
+		brw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n")
+		brw.Flush()
+		io.Copy(conn, brw)
+	})
+}
+
+// dialUpgrade opens a raw upgraded connection through the proxy at addr
+func dialUpgrade(t *testing.T, addr string) (net.Conn, *bufio.Reader) {
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+
+	fmt.Fprintf(conn, "GET /ws HTTP/1.1\r\nHost: example.com\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n")
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+
+	return conn, br
+}
+
+func TestProxyLongLivedConnections(t *testing.T) {
+	backend := newLongLivedBackend(t)
+	defer backend.Close()
+
+	backendURL, _ := url.Parse(backend.URL)
+	server := &types.Server{ID: "backend-1", URL: backendURL, Healthy: true}
+
+	storage := newMockStorage()
+	storage.CreateService(context.Background(), &types.Service{
+		ID:        "ws-service",
+		Endpoints: []string{backend.URL},
+		Active:    true,
+	})
+	route := &types.Route{ID: "ws-route", ServiceID: "ws-service"}
+
+	newFrontend := func(idleTimeout time.Duration) *httptest.Server {
+		p := proxy.NewWithOptions(
+			proxy.WithRouter(&mockRouter{
+				matchFunc: func(req *http.Request) (*types.Route, error) { return route, nil },
+			}),
+			proxy.WithLoadBalancer(&mockLoadBalancer{
+				selectFunc: func(ctx context.Context, req *http.Request, servers []*types.Server) (*types.Server, error) {
+					return server, nil
+				},
+			}),
+			proxy.WithStorage(storage),
+			proxy.WithLogger(&testLogger{}),
+			proxy.WithLongLivedTimeouts(idleTimeout),
+		)
+
+		frontend := httptest.NewUnstartedServer(p)
+		frontend.Config.ReadTimeout = 100 * time.Millisecond
+		frontend.Config.WriteTimeout = 100 * time.Millisecond
+		frontend.Config.IdleTimeout = 100 * time.Millisecond
+		frontend.Start()
+		return frontend
+	}
+
+	t.Run("websocket survives server timeouts", func(t *testing.T) {
+		frontend := newFrontend(0)
+		defer frontend.Close()
+
+		conn, br := dialUpgrade(t, frontend.Listener.Addr().String())
+		defer conn.Close()
+
+		// Stay quiet well past every server timeout, then talk
+		time.Sleep(400 * time.Millisecond)
+
+		_, err := conn.Write([]byte("ping"))
+		require.NoError(t, err)
+
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		buf := make([]byte, 4)
+		_, err = io.ReadFull(br, buf)
+		require.NoError(t, err)
+		assert.Equal(t, "ping", string(buf))
+	})
+
+	t.Run("event stream survives write timeout", func(t *testing.T) {
+		frontend := newFrontend(0)
+		defer frontend.Close()
+
+		req, _ := http.NewRequest("GET", frontend.URL+"/events", nil)
+		req.Header.Set("Accept", "text/event-stream")
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Equal(t, 5, strings.Count(string(body), "data: "))
+	})
+
+	t.Run("idle websocket closed after idle timeout", func(t *testing.T) {
+		frontend := newFrontend(200 * time.Millisecond)
+		defer frontend.Close()
+
+		conn, br := dialUpgrade(t, frontend.Listener.Addr().String())
+		defer conn.Close()
+
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		_, err := br.ReadByte()
+		assert.ErrorIs(t, err, io.EOF)
+	})
+}
+
 func TestProxyURLRewriting(t *testing.T) {
 	var capturedPath string
 	backend := createTestBackend(func(w http.ResponseWriter, r *http.Request) {
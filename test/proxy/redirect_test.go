@@ -0,0 +1,161 @@
+package proxy_test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"discobox/internal/proxy"
+	"discobox/internal/types"
+	"discobox/pkg/proxytest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newRedirectHarness serves route from a backend at http://app answering
+// with handler
+func newRedirectHarness(t testing.TB, handler http.HandlerFunc, route *types.Route, stripPrefix bool) *proxytest.Harness {
+	service := &types.Service{ID: "test-service", Endpoints: []string{"http://app"}, StripPrefix: stripPrefix, Active: true}
+	route.ServiceID = service.ID
+	if route.PathPrefix == "" {
+		route.PathPrefix = "/"
+	}
+
+	h, _ := newHarness(t, []*types.Service{service}, []*types.Route{route}, proxy.Options{Logger: &testLogger{}})
+	h.Backend("http://app", handler)
+	return h
+}
+
+func TestProxyFollowRedirects(t *testing.T) {
+	backend := func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/old":
+			http.Redirect(w, r, "http://app/new", http.StatusFound)
+		case "/submit":
+			http.Redirect(w, r, "/accepted", http.StatusTemporaryRedirect)
+		case "/form":
+			http.Redirect(w, r, "/done", http.StatusSeeOther)
+		case "/external":
+			http.Redirect(w, r, "https://elsewhere.example.org/", http.StatusFound)
+		case "/loop-a":
+			http.Redirect(w, r, "/loop-b", http.StatusFound)
+		case "/loop-b":
+			http.Redirect(w, r, "/loop-a", http.StatusFound)
+		default:
+			body, _ := io.ReadAll(r.Body)
+			fmt.Fprintf(w, "%s %s %s", r.Method, r.URL.Path, body)
+		}
+	}
+
+	route := &types.Route{
+		ID:        "test-route",
+		Redirects: &types.RedirectPolicy{Mode: types.RedirectFollow, MaxHops: 3},
+	}
+	h := newRedirectHarness(t, backend, route, false)
+
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		return h.Do(httptest.NewRequest(method, "http://example.com"+path, strings.NewReader(body)))
+	}
+
+	t.Run("client sees the final response", func(t *testing.T) {
+		rec := send("GET", "/old", "")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "GET /new ", rec.Body.String())
+	})
+
+	t.Run("307 keeps the method and body", func(t *testing.T) {
+		rec := send("POST", "/submit", "payload")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "POST /accepted payload", rec.Body.String())
+	})
+
+	t.Run("303 switches to GET", func(t *testing.T) {
+		rec := send("POST", "/form", "payload")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "GET /done ", rec.Body.String())
+	})
+
+	t.Run("redirects to other hosts pass through", func(t *testing.T) {
+		rec := send("GET", "/external", "")
+		assert.Equal(t, http.StatusFound, rec.Code)
+		assert.Equal(t, "https://elsewhere.example.org/", rec.Header().Get("Location"))
+	})
+
+	t.Run("loops are stopped", func(t *testing.T) {
+		rec := send("GET", "/loop-a", "")
+		assert.Equal(t, http.StatusBadGateway, rec.Code)
+		assert.Contains(t, rec.Body.String(), types.ErrTooManyRedirects.Error())
+	})
+}
+
+func TestProxyFollowRedirectsMaxHops(t *testing.T) {
+	backend := func(w http.ResponseWriter, r *http.Request) {
+		// /hop/N redirects to /hop/N+1 forever
+		var n int
+		fmt.Sscanf(r.URL.Path, "/hop/%d", &n)
+		http.Redirect(w, r, fmt.Sprintf("/hop/%d", n+1), http.StatusFound)
+	}
+
+	route := &types.Route{
+		ID:        "test-route",
+		Redirects: &types.RedirectPolicy{Mode: types.RedirectFollow, MaxHops: 2},
+	}
+	h := newRedirectHarness(t, backend, route, false)
+
+	rec := h.Do(httptest.NewRequest("GET", "http://example.com/hop/0", nil))
+
+	assert.Equal(t, http.StatusBadGateway, rec.Code)
+}
+
+func TestProxyRewriteRedirects(t *testing.T) {
+	backend := func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			http.Redirect(w, r, "http://app/account?next=home", http.StatusFound)
+		case "/relative":
+			w.Header().Set("Location", "/account")
+			w.WriteHeader(http.StatusMovedPermanently)
+		default:
+			http.Redirect(w, r, "https://elsewhere.example.org/", http.StatusFound)
+		}
+	}
+
+	serve := func(h *proxytest.Harness, path string) *httptest.ResponseRecorder {
+		return h.Do(httptest.NewRequest("GET", "http://example.com"+path, nil))
+	}
+
+	rewrite := &types.RedirectPolicy{Mode: types.RedirectRewrite}
+
+	t.Run("location points to the public host", func(t *testing.T) {
+		h := newRedirectHarness(t, backend, &types.Route{ID: "test-route", Redirects: rewrite}, false)
+		rec := serve(h, "/login")
+		assert.Equal(t, http.StatusFound, rec.Code)
+		assert.Equal(t, "http://example.com/account?next=home", rec.Header().Get("Location"))
+	})
+
+	t.Run("stripped prefix is restored", func(t *testing.T) {
+		route := &types.Route{ID: "test-route", PathPrefix: "/app", Redirects: rewrite}
+		h := newRedirectHarness(t, backend, route, true)
+
+		rec := serve(h, "/app/login")
+		assert.Equal(t, "http://example.com/app/account?next=home", rec.Header().Get("Location"))
+
+		rec = serve(h, "/app/relative")
+		assert.Equal(t, "/app/account", rec.Header().Get("Location"))
+	})
+
+	t.Run("other hosts are untouched", func(t *testing.T) {
+		h := newRedirectHarness(t, backend, &types.Route{ID: "test-route", Redirects: rewrite}, false)
+		rec := serve(h, "/external")
+		assert.Equal(t, "https://elsewhere.example.org/", rec.Header().Get("Location"))
+	})
+
+	t.Run("pass-through by default", func(t *testing.T) {
+		h := newRedirectHarness(t, backend, &types.Route{ID: "test-route"}, false)
+		rec := serve(h, "/login")
+		assert.Equal(t, "http://app/account?next=home", rec.Header().Get("Location"))
+	})
+}
@@ -0,0 +1,83 @@
+package proxy_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"discobox/internal/proxy"
+	"discobox/internal/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProxyResponseSizeLimit(t *testing.T) {
+	logger := &recordingLogger{}
+	h, _ := newHarness(t,
+		[]*types.Service{{ID: "files", Endpoints: []string{"http://files"}, Active: true}},
+		[]*types.Route{
+			{ID: "capped", PathPrefix: "/capped", ServiceID: "files", MaxResponseBytes: 100},
+			{ID: "open", PathPrefix: "/open", ServiceID: "files"},
+		},
+		proxy.Options{Logger: logger},
+	)
+
+	// Backends send ?size bytes, declaring the length unless streaming
+	h.Backend("http://files", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		size, _ := strconv.Atoi(r.URL.Query().Get("size"))
+		if r.URL.Query().Get("stream") == "" {
+			w.Header().Set("Content-Length", strconv.Itoa(size))
+		}
+		for range size / 10 {
+			w.Write([]byte("0123456789"))
+			w.(http.Flusher).Flush()
+		}
+	}))
+
+	get := func(path string) *httptest.ResponseRecorder {
+		return h.Do(httptest.NewRequest("GET", "http://example.com"+path, nil))
+	}
+
+	t.Run("under the cap", func(t *testing.T) {
+		rec := get("/capped?size=50")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, 50, rec.Body.Len())
+
+		rec = get("/capped?size=100&stream=1")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, 100, rec.Body.Len())
+	})
+
+	t.Run("declared over the cap fails before sending", func(t *testing.T) {
+		rec := get("/capped?size=200")
+		assert.Equal(t, http.StatusBadGateway, rec.Code)
+		assert.NotContains(t, rec.Body.String(), "0123456789")
+		assert.NotEmpty(t, logger.logged("response body exceeds route limit"))
+	})
+
+	t.Run("streamed over the cap is truncated", func(t *testing.T) {
+		truncated := map[string]string{"route": "capped"}
+		before := counterValue(t, "discobox_route_responses_truncated_total", truncated)
+
+		rec := get("/capped?size=200&stream=1")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, strings.Repeat("0123456789", 10), rec.Body.String())
+
+		entries := logger.logged("response body truncated at route limit")
+		require.Len(t, entries, 1)
+		assert.Equal(t, "capped", entries[0].fields["route_id"])
+		assert.Equal(t, int64(100), entries[0].fields["limit"])
+		assert.Equal(t, "GET", entries[0].fields["method"])
+		assert.Equal(t, http.StatusOK, entries[0].fields["status"])
+		assert.Equal(t, before+1, counterValue(t, "discobox_route_responses_truncated_total", truncated))
+	})
+
+	t.Run("routes without a cap are unlimited", func(t *testing.T) {
+		rec := get("/open?size=200&stream=1")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, 200, rec.Body.Len())
+	})
+}
@@ -0,0 +1,94 @@
+package proxy_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"discobox/internal/proxy"
+	"discobox/internal/types"
+	"discobox/pkg/proxytest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newModifierHarness proxies every request to a backend answering "hello"
+func newModifierHarness(t *testing.T, opts proxy.Options) *proxytest.Harness {
+	h, _ := newServiceHarness(t, &types.Service{ID: "api", Endpoints: []string{"http://api"}, Active: true}, opts)
+	h.Backend("http://api", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Backend", "original")
+		w.Write([]byte("hello"))
+	}))
+	return h
+}
+
+// appendStep records a modifier's name in the X-Steps response header
+func appendStep(name string) func(*http.Response) error {
+	return func(resp *http.Response) error {
+		resp.Header.Add("X-Steps", name)
+		return nil
+	}
+}
+
+func TestResponseModifierChain(t *testing.T) {
+	t.Run("modifiers apply in order", func(t *testing.T) {
+		h := newModifierHarness(t, proxy.Options{
+			ModifyResponse: appendStep("default"),
+			ResponseModifiers: []proxy.ResponseModifier{
+				{Name: "headers", Modify: func(resp *http.Response) error {
+					resp.Header.Set("X-Backend", "rewritten")
+					return appendStep("headers")(resp)
+				}},
+				{Name: "audit", Modify: appendStep("audit")},
+			},
+		})
+
+		rec := h.Do(httptest.NewRequest("GET", "http://example.com/", nil))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "hello", rec.Body.String())
+		assert.Equal(t, "rewritten", rec.Header().Get("X-Backend"))
+		assert.Equal(t, []string{"default", "headers", "audit"}, rec.Header().Values("X-Steps"))
+	})
+
+	t.Run("an error halts the chain", func(t *testing.T) {
+		lastRan := false
+		h := newModifierHarness(t, proxy.Options{
+			ResponseModifiers: []proxy.ResponseModifier{
+				{Name: "first", Modify: appendStep("first")},
+				{Name: "transform", Modify: func(resp *http.Response) error {
+					return errors.New("body too large to transform")
+				}},
+				{Name: "last", Modify: func(resp *http.Response) error {
+					lastRan = true
+					return nil
+				}},
+			},
+		})
+
+		rec := h.Do(httptest.NewRequest("GET", "http://example.com/", nil))
+		assert.Equal(t, http.StatusBadGateway, rec.Code)
+		assert.Contains(t, rec.Body.String(), "response modifier transform: body too large to transform")
+		assert.NotContains(t, rec.Body.String(), "hello")
+		assert.False(t, lastRan)
+	})
+
+	t.Run("modifiers can be replaced and removed by name", func(t *testing.T) {
+		h := newModifierHarness(t, proxy.Options{})
+		p := h.Proxy()
+
+		p.UseResponseModifier("a", appendStep("a"))
+		p.UseResponseModifier("b", appendStep("b"))
+		p.UseResponseModifier("a", appendStep("a2"))
+		assert.Equal(t, []string{"a", "b"}, p.ResponseModifiers())
+
+		rec := h.Do(httptest.NewRequest("GET", "http://example.com/", nil))
+		assert.Equal(t, []string{"a2", "b"}, rec.Header().Values("X-Steps"))
+
+		assert.True(t, p.RemoveResponseModifier("a"))
+		assert.False(t, p.RemoveResponseModifier("a"))
+
+		rec = h.Do(httptest.NewRequest("GET", "http://example.com/", nil))
+		assert.Equal(t, []string{"b"}, rec.Header().Values("X-Steps"))
+	})
+}
@@ -0,0 +1,65 @@
+package proxy_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"discobox/internal/proxy"
+	"discobox/internal/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResponseHeaderTimeout(t *testing.T) {
+	const timeout = 100 * time.Millisecond
+
+	release := make(chan struct{})
+	slowHeaders := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(5 * time.Second):
+		case <-release:
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(slowHeaders.Close)
+	t.Cleanup(func() { close(release) })
+
+	slowBody := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("first,"))
+		w.(http.Flusher).Flush()
+		time.Sleep(3 * timeout)
+		w.Write([]byte("second"))
+	}))
+	t.Cleanup(slowBody.Close)
+
+	var cfg types.ProxyConfig
+	cfg.Transport.ResponseHeaderTimeout = timeout
+
+	services := []*types.Service{
+		{ID: "slow-headers", Endpoints: []string{slowHeaders.URL}, Active: true},
+		{ID: "slow-body", Endpoints: []string{slowBody.URL}, Active: true},
+	}
+	routes := []*types.Route{
+		{ID: "slow-headers", PathPrefix: "/headers", ServiceID: "slow-headers"},
+		{ID: "slow-body", PathPrefix: "/body", ServiceID: "slow-body"},
+	}
+	h, _ := newHarness(t, services, routes, proxy.Options{Transport: proxy.NewTransport(cfg, nil)})
+
+	t.Run("delayed headers time out with 504", func(t *testing.T) {
+		start := time.Now()
+		rec := h.Do(httptest.NewRequest("GET", "http://example.com/headers", nil))
+
+		assert.Equal(t, http.StatusGatewayTimeout, rec.Code)
+		assert.Less(t, time.Since(start), 2*time.Second)
+	})
+
+	t.Run("body may stream past the timeout", func(t *testing.T) {
+		rec := h.Do(httptest.NewRequest("GET", "http://example.com/body", nil))
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "first,second", rec.Body.String())
+	})
+}
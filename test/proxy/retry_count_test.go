@@ -0,0 +1,46 @@
+package proxy_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"discobox/internal/metrics"
+	"discobox/internal/middleware"
+	"discobox/internal/proxy"
+	"discobox/internal/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProxyCountsOnlyRealRetries(t *testing.T) {
+	h, _ := newServiceHarness(t, &types.Service{ID: "retry-count", Endpoints: []string{"http://flaky"}, Active: true}, proxy.Options{})
+
+	var calls atomic.Int32
+	var attempts []string
+	h.Backend("http://flaky", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts = append(attempts, r.Header.Get("X-Retry-Attempt"))
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	retryConfig := middleware.DefaultRetryConfig()
+	retryConfig.InitialDelay = time.Millisecond
+	handler := middleware.Retry(retryConfig)(h.Proxy())
+
+	// A client claiming to be a retry isn't counted as one
+	req := httptest.NewRequest("GET", "http://example.com/", nil)
+	req.Header.Set("X-Retry-Attempt", "5")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	assert.Equal(t, []string{"", "1"}, attempts)
+	assert.Equal(t, uint64(1), metrics.GlobalCollector.GetRouteStats("retry-count").Retries)
+}
@@ -0,0 +1,47 @@
+package proxy_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"discobox/internal/metrics"
+	"discobox/internal/proxy"
+	"discobox/internal/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProxySaturatedBackends(t *testing.T) {
+	h, _ := newServiceHarness(t, &types.Service{ID: "saturated", Endpoints: []string{"http://busy"}, MaxConns: 1, Active: true}, proxy.Options{
+		RetryAfter: time.Second,
+	})
+
+	started, release := make(chan struct{}, 1), make(chan struct{})
+	h.Backend("http://busy", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+	}))
+
+	done := make(chan int)
+	go func() {
+		done <- h.Do(httptest.NewRequest("GET", "http://example.com/", nil)).Code
+	}()
+	<-started
+
+	// The only backend is healthy but full
+	rec := h.Do(httptest.NewRequest("GET", "http://example.com/", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+
+	close(release)
+	require.Equal(t, http.StatusOK, <-done)
+
+	labels := map[string]string{"service": "saturated"}
+	labels["reason"] = metrics.UnavailableSaturated
+	assert.Equal(t, float64(1), counterValue(t, "discobox_service_unavailable_total", labels))
+	labels["reason"] = metrics.UnavailableAllUnhealthy
+	assert.Zero(t, counterValue(t, "discobox_service_unavailable_total", labels))
+}
@@ -0,0 +1,147 @@
+package proxy_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"discobox/internal/proxy"
+	"discobox/internal/types"
+	"discobox/pkg/proxytest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newScaleDownHarness serves a service with the given endpoints, picking the
+// backend named by each request's X-Backend index. Backends hold requests
+// until release is closed or the request is canceled.
+func newScaleDownHarness(t *testing.T, drainTimeout time.Duration, endpoints ...string) (*proxytest.Harness, types.Storage, chan struct{}, chan struct{}) {
+	h, store := newServiceHarness(t, &types.Service{ID: "api", Endpoints: endpoints, Active: true}, proxy.Options{
+		DrainTimeout: drainTimeout,
+		LoadBalancer: &mockLoadBalancer{
+			selectFunc: func(ctx context.Context, req *http.Request, servers []*types.Server) (*types.Server, error) {
+				index, _ := strconv.Atoi(req.Header.Get("X-Backend"))
+				return servers[index], nil
+			},
+		},
+	})
+
+	received := make(chan struct{}, 16)
+	release := make(chan struct{})
+	for _, endpoint := range endpoints {
+		h.Backend(endpoint, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			received <- struct{}{}
+			select {
+			case <-release:
+			case <-r.Context().Done():
+			}
+		}))
+	}
+	return h, store, received, release
+}
+
+// hold sends a request to the backend at index and waits until it arrives.
+// The returned channel yields the response status.
+func hold(h *proxytest.Harness, received chan struct{}, index int) <-chan int {
+	status := make(chan int, 1)
+	go func() {
+		req := httptest.NewRequest("GET", "http://example.com/", nil)
+		req.Header.Set("X-Backend", strconv.Itoa(index))
+		status <- h.Do(req).Code
+	}()
+	<-received
+	return status
+}
+
+func TestDrainAllOrdersByActiveConns(t *testing.T) {
+	h, _, received, _ := newScaleDownHarness(t, 0, "http://a", "http://b", "http://c")
+
+	// Build the backends, then load them unevenly: a=2, b=0, c=1
+	var held []<-chan int
+	for _, index := range []int{0, 0, 2} {
+		held = append(held, hold(h, received, index))
+	}
+
+	start := time.Now()
+	results := h.Proxy().DrainAll("api", 300*time.Millisecond)
+	require.Len(t, results, 3)
+
+	assert.Equal(t, []string{"api-1", "api-2", "api-0"}, []string{results[0].ServerID, results[1].ServerID, results[2].ServerID})
+	assert.Equal(t, []int64{0, 1, 2}, []int64{results[0].ActiveConns, results[1].ActiveConns, results[2].ActiveConns})
+
+	// Deadlines are staggered, least loaded first, busiest last at the timeout
+	assert.True(t, results[0].Deadline.Before(results[1].Deadline))
+	assert.True(t, results[1].Deadline.Before(results[2].Deadline))
+	assert.WithinDuration(t, start.Add(300*time.Millisecond), results[2].Deadline, 50*time.Millisecond)
+
+	// The idle backend drained at once; requests still held were cut off
+	assert.Equal(t, []int64{0, 1, 2}, []int64{results[0].CutOff, results[1].CutOff, results[2].CutOff})
+	for _, status := range held {
+		select {
+		case code := <-status:
+			assert.NotEqual(t, http.StatusOK, code)
+		case <-time.After(time.Second):
+			t.Fatal("held request was not cut off")
+		}
+	}
+}
+
+func TestDrainAllWaitsForRequests(t *testing.T) {
+	h, _, received, release := newScaleDownHarness(t, 0, "http://a", "http://b")
+
+	held := hold(h, received, 1)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+	}()
+
+	results := h.Proxy().DrainAll("api", 0)
+	require.Len(t, results, 2)
+	assert.Equal(t, "api-0", results[0].ServerID)
+	assert.Equal(t, "api-1", results[1].ServerID)
+	assert.Zero(t, results[1].CutOff)
+	assert.True(t, results[1].Deadline.IsZero())
+	assert.Equal(t, http.StatusOK, <-held)
+}
+
+func TestServiceUpdateDrainsRemovedBackends(t *testing.T) {
+	h, store, received, release := newScaleDownHarness(t, 50*time.Millisecond, "http://a", "http://b", "http://c")
+	defer close(release)
+
+	kept := hold(h, received, 0)
+	removedB := hold(h, received, 1)
+	removedC := hold(h, received, 2)
+
+	service, err := store.GetService(context.Background(), "api")
+	require.NoError(t, err)
+	service.Endpoints = []string{"http://a"}
+	require.NoError(t, store.UpdateService(context.Background(), service))
+
+	// Requests to removed backends are cut off once their deadlines pass
+	var wg sync.WaitGroup
+	for _, status := range []<-chan int{removedB, removedC} {
+		wg.Add(1)
+		go func(status <-chan int) {
+			defer wg.Done()
+			select {
+			case code := <-status:
+				assert.NotEqual(t, http.StatusOK, code)
+			case <-time.After(2 * time.Second):
+				t.Error("request to removed backend was not cut off")
+			}
+		}(status)
+	}
+	wg.Wait()
+
+	// The backend still in the service keeps its request
+	select {
+	case <-kept:
+		t.Fatal("request to remaining backend was cut off")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,150 @@
+package proxy_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"discobox/internal/balancer"
+	"discobox/internal/proxy"
+	"discobox/internal/types"
+	"discobox/pkg/proxytest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// logEntry is a message logged at debug level and its fields
+type logEntry struct {
+	msg    string
+	fields map[string]any
+}
+
+// recordingLogger keeps debug and warning messages
+type recordingLogger struct {
+	mu      sync.Mutex
+	entries []logEntry
+}
+
+func (l *recordingLogger) Debug(msg string, fields ...any) { l.record(msg, fields) }
+func (l *recordingLogger) Info(msg string, fields ...any)  {}
+func (l *recordingLogger) Warn(msg string, fields ...any)  { l.record(msg, fields) }
+func (l *recordingLogger) Error(msg string, fields ...any) {}
+func (l *recordingLogger) With(fields ...any) types.Logger { return l }
+
+func (l *recordingLogger) record(msg string, fields []any) {
+	entry := logEntry{msg: msg, fields: make(map[string]any)}
+	for i := 0; i+1 < len(fields); i += 2 {
+		if key, ok := fields[i].(string); ok {
+			entry.fields[key] = fields[i+1]
+		}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, entry)
+}
+
+// logged returns the entries with the given message
+func (l *recordingLogger) logged(msg string) []logEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var matched []logEntry
+	for _, entry := range l.entries {
+		if entry.msg == msg {
+			matched = append(matched, entry)
+		}
+	}
+	return matched
+}
+
+// selections returns the logged backend selections
+func (l *recordingLogger) selections() []logEntry {
+	return l.logged("backend selected")
+}
+
+// newSelectionHarness serves one route over two endpoints whose backends
+// hold requests until release is closed, reporting the endpoint on entered
+func newSelectionHarness(t *testing.T, logSelection bool) (*proxytest.Harness, *recordingLogger, chan string, chan struct{}) {
+	logger := &recordingLogger{}
+	h, _ := newServiceHarness(t, &types.Service{
+		ID:        "api",
+		Endpoints: []string{"http://api-a", "http://api-b"},
+		Weight:    3,
+		Active:    true,
+	}, proxy.Options{
+		LoadBalancer: balancer.NewLeastConnections(),
+		Logger:       logger,
+		LogSelection: logSelection,
+	})
+
+	entered := make(chan string, 2)
+	release := make(chan struct{})
+	for _, endpoint := range []string{"http://api-a", "http://api-b"} {
+		h.Backend(endpoint, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			entered <- endpoint
+			<-release
+		}))
+	}
+	return h, logger, entered, release
+}
+
+func TestProxyLogsLeastConnectionsSelection(t *testing.T) {
+	h, logger, entered, release := newSelectionHarness(t, true)
+
+	var wg sync.WaitGroup
+	send := func() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			h.Do(httptest.NewRequest("GET", "http://example.com/", nil))
+		}()
+	}
+
+	// The first request occupies one backend, so the second goes to the other
+	send()
+	<-entered
+	send()
+	<-entered
+	close(release)
+	wg.Wait()
+
+	selections := logger.selections()
+	require.Len(t, selections, 2)
+
+	first, second := selections[0], selections[1]
+	assert.Equal(t, "api", second.fields["route_id"])
+	assert.Equal(t, "least_conn", second.fields["algorithm"])
+	assert.Equal(t, "fewest active connections (0)", second.fields["reason"])
+	assert.NotEqual(t, first.fields["server_id"], second.fields["server_id"])
+
+	busy := first.fields["server_id"].(string)
+	candidates, ok := second.fields["candidates"].([]types.SelectionCandidate)
+	require.True(t, ok)
+	require.Len(t, candidates, 2)
+	for _, candidate := range candidates {
+		assert.True(t, candidate.Eligible)
+		assert.Equal(t, 3, candidate.Weight)
+		if candidate.ServerID == busy {
+			assert.Equal(t, int64(1), candidate.ActiveConns)
+		} else {
+			assert.Equal(t, int64(0), candidate.ActiveConns)
+		}
+	}
+
+	// Both backends were idle for the first request
+	assert.Contains(t, first.fields["reason"], "tied at 0 active connections")
+}
+
+func TestProxySelectionLoggingDisabled(t *testing.T) {
+	h, logger, entered, release := newSelectionHarness(t, false)
+	close(release)
+
+	rec := h.Do(httptest.NewRequest("GET", "http://example.com/", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	<-entered
+
+	assert.Empty(t, logger.selections())
+}
@@ -0,0 +1,247 @@
+package proxy_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"discobox/internal/balancer"
+	"discobox/internal/proxy"
+	"discobox/internal/storage"
+	"discobox/internal/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestActiveConnsAccumulateAcrossRequests(t *testing.T) {
+	release := make(chan struct{})
+	var inFlight atomic.Int32
+
+	backend := createTestBackend(func(w http.ResponseWriter, r *http.Request) {
+		inFlight.Add(1)
+		<-release
+	})
+	defer backend.Close()
+
+	store := newMockStorage()
+	service := &types.Service{
+		ID:        "test-service",
+		Endpoints: []string{backend.URL},
+		Active:    true,
+	}
+	store.CreateService(context.Background(), service)
+
+	route := &types.Route{ID: "test-route", ServiceID: service.ID}
+
+	// Record what the balancer sees on each selection
+	var mu sync.Mutex
+	var selected []*types.Server
+	var activeSeen []int64
+
+	p := proxy.New(proxy.Options{
+		Router: &mockRouter{
+			matchFunc: func(req *http.Request) (*types.Route, error) {
+				return route, nil
+			},
+		},
+		LoadBalancer: &mockLoadBalancer{
+			selectFunc: func(ctx context.Context, req *http.Request, servers []*types.Server) (*types.Server, error) {
+				mu.Lock()
+				defer mu.Unlock()
+				selected = append(selected, servers[0])
+				activeSeen = append(activeSeen, atomic.LoadInt64(&servers[0].ActiveConns))
+				return servers[0], nil
+			},
+		},
+		Storage: store,
+		Logger:  &testLogger{},
+	})
+	defer p.Stop()
+
+	const concurrent = 5
+	var wg sync.WaitGroup
+	for i := 0; i < concurrent; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rec := httptest.NewRecorder()
+			p.ServeHTTP(rec, httptest.NewRequest("GET", "http://example.com/", nil))
+		}()
+
+		// Start requests one at a time so each selection sees the ones before it
+		want := int32(i + 1)
+		require.Eventually(t, func() bool { return inFlight.Load() == want }, 2*time.Second, 5*time.Millisecond)
+	}
+
+	mu.Lock()
+	assert.Equal(t, []int64{0, 1, 2, 3, 4}, activeSeen)
+	for _, server := range selected {
+		assert.Equal(t, selected[0].ID, server.ID)
+	}
+	mu.Unlock()
+
+	stats, err := p.RuntimeStats(context.Background())
+	require.NoError(t, err)
+	require.Len(t, stats.Backends, 1)
+	assert.Equal(t, int64(concurrent), stats.Backends[0].ActiveConns)
+
+	close(release)
+	wg.Wait()
+
+	stats, err = p.RuntimeStats(context.Background())
+	require.NoError(t, err)
+	require.Len(t, stats.Backends, 1)
+	assert.Equal(t, int64(0), stats.Backends[0].ActiveConns)
+}
+
+func TestServersRebuiltWhenServiceChanges(t *testing.T) {
+	ctx := context.Background()
+
+	first := createTestBackend(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("first"))
+	})
+	defer first.Close()
+	second := createTestBackend(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("second"))
+	})
+	defer second.Close()
+
+	store := storage.NewMemory()
+	defer store.Close()
+	require.NoError(t, store.CreateService(ctx, &types.Service{
+		ID:        "test-service",
+		Endpoints: []string{first.URL},
+		Weight:    1,
+		Active:    true,
+	}))
+
+	route := &types.Route{ID: "test-route", ServiceID: "test-service"}
+
+	var mu sync.Mutex
+	var selected []*types.Server
+
+	p := proxy.New(proxy.Options{
+		Router: &mockRouter{
+			matchFunc: func(req *http.Request) (*types.Route, error) {
+				return route, nil
+			},
+		},
+		LoadBalancer: &mockLoadBalancer{
+			selectFunc: func(ctx context.Context, req *http.Request, servers []*types.Server) (*types.Server, error) {
+				mu.Lock()
+				defer mu.Unlock()
+				selected = append(selected, servers[0])
+				return servers[0], nil
+			},
+		},
+		Storage: store,
+		Logger:  &testLogger{},
+	})
+	defer p.Stop()
+
+	get := func() string {
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, httptest.NewRequest("GET", "http://example.com/", nil))
+		return rec.Body.String()
+	}
+	last := func() *types.Server {
+		mu.Lock()
+		defer mu.Unlock()
+		return selected[len(selected)-1]
+	}
+
+	// The balancer gets snapshots; a backend that was kept remembers being
+	// used by the request before
+	assert.Equal(t, "first", get())
+	assert.True(t, last().LastUsed.IsZero())
+
+	// A new weight keeps the backend, and the count of requests to it
+	service, err := store.GetService(ctx, "test-service")
+	require.NoError(t, err)
+	service.Weight = 3
+	require.NoError(t, store.UpdateService(ctx, service))
+
+	assert.Equal(t, "first", get())
+	assert.False(t, last().LastUsed.IsZero())
+	assert.Equal(t, 3, last().Weight)
+
+	// A new endpoint gets a new backend
+	service, err = store.GetService(ctx, "test-service")
+	require.NoError(t, err)
+	service.Endpoints = []string{second.URL}
+	require.NoError(t, store.UpdateService(ctx, service))
+
+	assert.Equal(t, "second", get())
+	assert.True(t, last().LastUsed.IsZero())
+}
+
+func TestHealthChangesDuringRequests(t *testing.T) {
+	ctx := context.Background()
+	endpoints := []string{"http://one", "http://two", "http://three"}
+	h, store := newServiceHarness(t, &types.Service{
+		ID:        "api",
+		Endpoints: endpoints,
+		Weight:    1,
+		Active:    true,
+	}, proxy.Options{LoadBalancer: balancer.NewLeastConnections()})
+
+	for _, endpoint := range endpoints {
+		h.Backend(endpoint, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(time.Millisecond)
+		}))
+	}
+
+	// Flip backends up and down, and rebuild them with new weights, while
+	// requests are being balanced across them. The first stays up so every
+	// request has somewhere to go. Run under -race.
+	stop := make(chan struct{})
+	var flipper sync.WaitGroup
+	flipper.Add(1)
+	go func() {
+		defer flipper.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			h.Proxy().OverrideHealth("api", endpoints[1+i%2], i%3 == 0)
+			if i%10 == 0 {
+				service, err := store.GetService(ctx, "api")
+				if err == nil {
+					service.Weight = 1 + i%4
+					store.UpdateService(ctx, service)
+				}
+			}
+			time.Sleep(100 * time.Microsecond)
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 25 {
+				rec := h.Do(httptest.NewRequest("GET", "http://example.com/", nil))
+				assert.Equal(t, http.StatusOK, rec.Code)
+			}
+		}()
+	}
+	wg.Wait()
+	close(stop)
+	flipper.Wait()
+
+	// Every request was counted against, and released from, the shared backends
+	stats, err := h.Proxy().RuntimeStats(ctx)
+	require.NoError(t, err)
+	require.Len(t, stats.Backends, len(endpoints))
+	for _, backend := range stats.Backends {
+		assert.Zero(t, backend.ActiveConns, backend.ID)
+	}
+}
@@ -0,0 +1,167 @@
+package proxy_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"discobox/internal/proxy"
+	"discobox/internal/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// goingAwayFrame is a server close frame with status 1001
+var goingAwayFrame = []byte{0x88, 0x02, 0x03, 0xe9}
+
+// newClosingBackend upgrades every request and echoes data until the client
+// sends a close frame, then hangs up like a WebSocket server would
+func newClosingBackend(t *testing.T) *httptest.Server {
+	return createTestBackend(func(w http.ResponseWriter, r *http.Request) {
+		conn, brw, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			t.Errorf("backend hijack failed: %v", err)
+			return
+		}
+		defer conn.Close()
+
+		brw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n")
+		brw.Flush()
+
+		buf := make([]byte, 64)
+		for {
+			n, err := brw.Read(buf)
+			if err != nil || buf[0] == 0x88 {
+				return
+			}
+			conn.Write(buf[:n])
+		}
+	})
+}
+
+// newUpgradeFrontend serves a proxy in front of backend
+func newUpgradeFrontend(backend *httptest.Server) (*proxy.Proxy, *httptest.Server) {
+	backendURL, _ := url.Parse(backend.URL)
+	server := &types.Server{ID: "backend-1", URL: backendURL, Healthy: true}
+
+	storage := newMockStorage()
+	storage.CreateService(context.Background(), &types.Service{
+		ID:        "ws-service",
+		Endpoints: []string{backend.URL},
+		Active:    true,
+	})
+	route := &types.Route{ID: "ws-route", ServiceID: "ws-service"}
+
+	p := proxy.NewWithOptions(
+		proxy.WithRouter(&mockRouter{
+			matchFunc: func(req *http.Request) (*types.Route, error) { return route, nil },
+		}),
+		proxy.WithLoadBalancer(&mockLoadBalancer{
+			selectFunc: func(ctx context.Context, req *http.Request, servers []*types.Server) (*types.Server, error) {
+				return server, nil
+			},
+		}),
+		proxy.WithStorage(storage),
+		proxy.WithLogger(&testLogger{}),
+		proxy.WithLongLivedTimeouts(0),
+	)
+
+	return p, httptest.NewServer(p)
+}
+
+func TestProxyClosesWebSocketsOnShutdown(t *testing.T) {
+	backend := newClosingBackend(t)
+	defer backend.Close()
+
+	t.Run("clients that answer the close frame hang up cleanly", func(t *testing.T) {
+		p, frontend := newUpgradeFrontend(backend)
+		defer frontend.Close()
+
+		conn, br := dialUpgrade(t, frontend.Listener.Addr().String())
+		defer conn.Close()
+
+		_, err := conn.Write([]byte("ping"))
+		require.NoError(t, err)
+		buf := make([]byte, 4)
+		_, err = io.ReadFull(br, buf)
+		require.NoError(t, err)
+		require.Equal(t, 1, p.UpgradedConns())
+
+		// Shutdown returns without waiting for the hijacked connection
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		require.NoError(t, frontend.Config.Shutdown(ctx))
+
+		done := make(chan error, 1)
+		go func() { done <- p.CloseUpgraded(ctx) }()
+
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		frame := make([]byte, len(goingAwayFrame))
+		_, err = io.ReadFull(br, frame)
+		require.NoError(t, err)
+		assert.Equal(t, goingAwayFrame, frame)
+
+		// Masked close reply with status 1001, as a browser would send
+		_, err = conn.Write([]byte{0x88, 0x82, 0, 0, 0, 0, 0x03, 0xe9})
+		require.NoError(t, err)
+
+		_, err = br.ReadByte()
+		assert.ErrorIs(t, err, io.EOF)
+
+		select {
+		case err := <-done:
+			assert.NoError(t, err)
+		case <-time.After(2 * time.Second):
+			t.Fatal("CloseUpgraded did not return")
+		}
+		assert.Equal(t, 0, p.UpgradedConns())
+	})
+
+	t.Run("unresponsive clients are cut at the deadline", func(t *testing.T) {
+		p, frontend := newUpgradeFrontend(backend)
+		defer frontend.Close()
+
+		conn, br := dialUpgrade(t, frontend.Listener.Addr().String())
+		defer conn.Close()
+		require.Eventually(t, func() bool { return p.UpgradedConns() == 1 }, time.Second, 10*time.Millisecond)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		err := p.CloseUpgraded(ctx)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Less(t, time.Since(start), time.Second)
+		assert.Equal(t, 0, p.UpgradedConns())
+
+		// The close frame still went out before the connection was cut
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		data, err := io.ReadAll(br)
+		assert.NoError(t, err)
+		assert.Equal(t, goingAwayFrame, data)
+	})
+
+	t.Run("upgrades after shutdown are refused", func(t *testing.T) {
+		p, frontend := newUpgradeFrontend(backend)
+		defer frontend.Close()
+
+		require.NoError(t, p.CloseUpgraded(context.Background()))
+
+		req, err := http.NewRequest("GET", frontend.URL+"/ws", nil)
+		require.NoError(t, err)
+		req.Header.Set("Upgrade", "websocket")
+		req.Header.Set("Connection", "Upgrade")
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+		assert.Equal(t, 0, p.UpgradedConns())
+	})
+}
@@ -0,0 +1,52 @@
+package proxy_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"discobox/internal/proxy"
+	"discobox/internal/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProxyUpstreamScheme(t *testing.T) {
+	services := []*types.Service{
+		{ID: "plain", Endpoints: []string{"http://plain"}, Active: true},
+		{ID: "secure", Endpoints: []string{"http://secure"}, UpstreamScheme: "https", Active: true},
+	}
+	routes := []*types.Route{
+		{ID: "forced", PathPrefix: "/forced", ServiceID: "plain", UpstreamScheme: "https"},
+		{ID: "plain", PathPrefix: "/plain", ServiceID: "plain"},
+		{ID: "secure", PathPrefix: "/secure", ServiceID: "secure"},
+		{ID: "downgrade", PathPrefix: "/downgrade", ServiceID: "secure", UpstreamScheme: "http"},
+	}
+	h, _ := newHarness(t, services, routes, proxy.Options{})
+
+	// Backends echo the scheme they were reached with
+	echo := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Scheme))
+	})
+	h.Backend("http://plain", echo)
+	h.Backend("http://secure", echo)
+
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{"route forces https over an http endpoint", "/forced", "https"},
+		{"unset keeps the endpoint scheme", "/plain", "http"},
+		{"service forces https for all its routes", "/secure", "https"},
+		{"route overrides the service", "/downgrade", "http"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := h.Do(httptest.NewRequest("GET", "http://example.com"+tt.path, nil))
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, tt.want, rec.Body.String())
+		})
+	}
+}
@@ -0,0 +1,274 @@
+package proxy_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"discobox/internal/proxy"
+	"discobox/internal/storage"
+	"discobox/internal/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newClientCert creates a self-signed client certificate, returned as PEM
+func newClientCert(t *testing.T, commonName string) (certPEM, keyPEM string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certPEM = string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	keyPEM = string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+	return certPEM, keyPEM
+}
+
+func TestProxyUpstreamTLS(t *testing.T) {
+	clientCert, clientKey := newClientCert(t, "discobox-proxy")
+	otherCert, otherKey := newClientCert(t, "someone-else")
+
+	trusted := x509.NewCertPool()
+	require.True(t, trusted.AppendCertsFromPEM([]byte(clientCert)))
+
+	// A backend that only talks to clients holding the proxy's certificate
+	backend := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello " + r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	backend.TLS = &tls.Config{
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		ClientCAs:  trusted,
+	}
+	backend.StartTLS()
+	defer backend.Close()
+
+	backendCA := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: backend.Certificate().Raw}))
+
+	storage := newMockStorage()
+	service := &types.Service{
+		ID:        "test-service",
+		Endpoints: []string{backend.URL},
+		Active:    true,
+	}
+	storage.CreateService(context.Background(), service)
+
+	route := &types.Route{ID: "test-route", ServiceID: service.ID}
+	p := proxy.New(proxy.Options{
+		Router: &mockRouter{
+			matchFunc: func(req *http.Request) (*types.Route, error) {
+				return route, nil
+			},
+		},
+		LoadBalancer: &mockLoadBalancer{
+			selectFunc: func(ctx context.Context, req *http.Request, servers []*types.Server) (*types.Server, error) {
+				return servers[0], nil
+			},
+		},
+		Storage: storage,
+		Logger:  &testLogger{},
+	})
+
+	send := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, httptest.NewRequest("GET", "http://example.com/", nil))
+		return rec
+	}
+
+	// The same proxy is reused so each change must replace the cached transport
+	tests := []struct {
+		name     string
+		tls      *types.TLSConfig
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "no TLS settings",
+			tls:      nil,
+			wantCode: http.StatusBadGateway,
+		},
+		{
+			name:     "trusted CA without a client certificate",
+			tls:      &types.TLSConfig{Enabled: true, RootCAs: []string{backendCA}},
+			wantCode: http.StatusBadGateway,
+		},
+		{
+			name:     "client certificate the backend doesn't trust",
+			tls:      &types.TLSConfig{Enabled: true, RootCAs: []string{backendCA}, ClientCert: otherCert, ClientKey: otherKey},
+			wantCode: http.StatusBadGateway,
+		},
+		{
+			name:     "trusted CA and client certificate",
+			tls:      &types.TLSConfig{Enabled: true, RootCAs: []string{backendCA}, ClientCert: clientCert, ClientKey: clientKey},
+			wantCode: http.StatusOK,
+			wantBody: "hello discobox-proxy",
+		},
+		{
+			name:     "settings are ignored when disabled",
+			tls:      &types.TLSConfig{Enabled: false, RootCAs: []string{backendCA}, ClientCert: clientCert, ClientKey: clientKey},
+			wantCode: http.StatusBadGateway,
+		},
+		{
+			name:     "server name override that the certificate doesn't cover",
+			tls:      &types.TLSConfig{Enabled: true, ServerName: "api.internal", RootCAs: []string{backendCA}, ClientCert: clientCert, ClientKey: clientKey},
+			wantCode: http.StatusBadGateway,
+		},
+		{
+			name:     "server name override the certificate covers",
+			tls:      &types.TLSConfig{Enabled: true, ServerName: "example.com", RootCAs: []string{backendCA}, ClientCert: clientCert, ClientKey: clientKey},
+			wantCode: http.StatusOK,
+			wantBody: "hello discobox-proxy",
+		},
+		{
+			name:     "skipping verification",
+			tls:      &types.TLSConfig{Enabled: true, InsecureSkipVerify: true, ClientCert: clientCert, ClientKey: clientKey},
+			wantCode: http.StatusOK,
+			wantBody: "hello discobox-proxy",
+		},
+		{
+			name:     "unparseable client certificate",
+			tls:      &types.TLSConfig{Enabled: true, InsecureSkipVerify: true, ClientCert: "not a cert", ClientKey: "not a key"},
+			wantCode: http.StatusBadGateway,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service.TLS = tt.tls
+
+			rec := send()
+			assert.Equal(t, tt.wantCode, rec.Code)
+			if tt.wantBody != "" {
+				assert.Equal(t, tt.wantBody, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestProxyUpstreamTLSDroppedWithService(t *testing.T) {
+	ctx := context.Background()
+
+	closed := make(chan struct{}, 1)
+	backend := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	backend.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateClosed {
+			select {
+			case closed <- struct{}{}:
+			default:
+			}
+		}
+	}
+	backend.StartTLS()
+	defer backend.Close()
+
+	store := storage.NewMemory()
+	defer store.Close()
+	require.NoError(t, store.CreateService(ctx, &types.Service{
+		ID:        "test-service",
+		Endpoints: []string{backend.URL},
+		TLS:       &types.TLSConfig{Enabled: true, InsecureSkipVerify: true},
+		Active:    true,
+	}))
+
+	route := &types.Route{ID: "test-route", ServiceID: "test-service"}
+	p := proxy.New(proxy.Options{
+		Router: &mockRouter{
+			matchFunc: func(req *http.Request) (*types.Route, error) {
+				return route, nil
+			},
+		},
+		LoadBalancer: &mockLoadBalancer{
+			selectFunc: func(ctx context.Context, req *http.Request, servers []*types.Server) (*types.Server, error) {
+				return servers[0], nil
+			},
+		},
+		Storage: store,
+		Logger:  &testLogger{},
+	})
+	defer p.Stop()
+
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest("GET", "http://example.com/", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	// The service's own transport goes with it, and its idle connection
+	// to the backend is closed
+	require.NoError(t, store.DeleteService(ctx, "test-service"))
+	select {
+	case <-closed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("idle connection to the deleted service was kept open")
+	}
+}
+
+func TestProxyUpstreamTLSNeedsHTTPTransport(t *testing.T) {
+	storage := newMockStorage()
+	service := &types.Service{
+		ID:        "test-service",
+		Endpoints: []string{"https://backend.internal"},
+		TLS:       &types.TLSConfig{Enabled: true, InsecureSkipVerify: true},
+		Active:    true,
+	}
+	storage.CreateService(context.Background(), service)
+
+	// A custom transport can't be copied with TLS settings, so the request
+	// fails rather than going out without them
+	var called bool
+	route := &types.Route{ID: "test-route", ServiceID: service.ID}
+	p := proxy.New(proxy.Options{
+		Router: &mockRouter{
+			matchFunc: func(req *http.Request) (*types.Route, error) {
+				return route, nil
+			},
+		},
+		LoadBalancer: &mockLoadBalancer{
+			selectFunc: func(ctx context.Context, req *http.Request, servers []*types.Server) (*types.Server, error) {
+				return servers[0], nil
+			},
+		},
+		Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			called = true
+			return nil, errors.New("unexpected request")
+		}),
+		Storage: storage,
+		Logger:  &testLogger{},
+	})
+
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest("GET", "http://example.com/", nil))
+	assert.Equal(t, http.StatusBadGateway, rec.Code)
+	assert.False(t, called)
+}
+
+// roundTripFunc adapts a function to http.RoundTripper
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
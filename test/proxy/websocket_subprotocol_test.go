@@ -0,0 +1,107 @@
+package proxy_test
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+
+	"discobox/internal/proxy"
+	"discobox/internal/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newSubprotocolBackend upgrades requests, choosing the first subprotocol the
+// client offers from those it supports and echoing it back
+func newSubprotocolBackend(t *testing.T, supported ...string) (*httptest.Server, chan []string) {
+	offered := make(chan []string, 1)
+	backend := createTestBackend(func(w http.ResponseWriter, r *http.Request) {
+		var protocols []string
+		for _, v := range r.Header.Values("Sec-WebSocket-Protocol") {
+			for _, p := range strings.Split(v, ",") {
+				protocols = append(protocols, strings.TrimSpace(p))
+			}
+		}
+		offered <- protocols
+
+		conn, brw, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			t.Errorf("backend hijack failed: %v", err)
+			return
+		}
+		defer conn.Close()
+
+		brw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n")
+		for _, p := range protocols {
+			if slices.Contains(supported, p) {
+				brw.WriteString("Sec-WebSocket-Protocol: " + p + "\r\n")
+				break
+			}
+		}
+		brw.WriteString("\r\n")
+		brw.Flush()
+		brw.ReadByte()
+	})
+	return backend, offered
+}
+
+// upgradeWithProtocols sends an upgrade request offering protocols and reads
+// the handshake response
+func upgradeWithProtocols(t *testing.T, addr string, protocols ...string) *http.Response {
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	fmt.Fprintf(conn, "GET /ws HTTP/1.1\r\nHost: example.com\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n")
+	for _, p := range protocols {
+		fmt.Fprintf(conn, "Sec-WebSocket-Protocol: %s\r\n", p)
+	}
+	fmt.Fprintf(conn, "\r\n")
+
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	require.NoError(t, err)
+	return resp
+}
+
+func TestWebSocketSubprotocolPassThrough(t *testing.T) {
+	t.Run("reverse proxy", func(t *testing.T) {
+		backend, offered := newSubprotocolBackend(t, "chat.v2")
+		defer backend.Close()
+
+		_, frontend := newUpgradeFrontend(backend)
+		defer frontend.Close()
+
+		resp := upgradeWithProtocols(t, frontend.Listener.Addr().String(), "chat.v1, chat.v2", "graphql-ws")
+		require.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+
+		assert.Equal(t, []string{"chat.v1", "chat.v2", "graphql-ws"}, <-offered)
+		assert.Equal(t, "chat.v2", resp.Header.Get("Sec-WebSocket-Protocol"))
+		assert.Equal(t, "websocket", resp.Header.Get("Upgrade"))
+	})
+
+	t.Run("websocket proxy", func(t *testing.T) {
+		backend, offered := newSubprotocolBackend(t, "graphql-ws")
+		defer backend.Close()
+
+		backendURL, _ := url.Parse(backend.URL)
+		server := &types.Server{ID: "backend-1", URL: backendURL, Healthy: true}
+		wp := proxy.NewWebSocketProxy(&testLogger{})
+		frontend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			wp.ServeHTTP(w, r, server)
+		}))
+		defer frontend.Close()
+
+		resp := upgradeWithProtocols(t, frontend.Listener.Addr().String(), "chat.v1, graphql-ws")
+		require.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+
+		assert.Equal(t, []string{"chat.v1", "graphql-ws"}, <-offered)
+		assert.Equal(t, "graphql-ws", resp.Header.Get("Sec-WebSocket-Protocol"))
+	})
+}
@@ -2,7 +2,11 @@ package router_test
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"fmt"
+	"net/http"
 	"net/http/httptest"
 	"sync"
 	"testing"
@@ -842,3 +846,459 @@ func TestRouterPerformance(t *testing.T) {
 	// Ensure reasonable performance (< 1ms per request)
 	assert.Less(t, perRequest, time.Millisecond)
 }
+
+func TestRouterTLSMatching(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemory()
+
+	for _, id := range []string{"partner-service", "internal-service", "tenant-service", "default-service"} {
+		require.NoError(t, store.CreateService(ctx, &types.Service{
+			ID:        id,
+			Name:      id,
+			Endpoints: []string{"http://" + id + ":8080"},
+			Active:    true,
+		}))
+	}
+
+	routes := []*types.Route{
+		{
+			ID:                "partner-route",
+			Priority:          100,
+			SNI:               "partner.example.com",
+			ClientCertSubject: "CN=partner-gateway,O=Partner Inc",
+			ServiceID:         "partner-service",
+		},
+		{
+			ID:                "internal-route",
+			Priority:          90,
+			ClientCertSubject: "~^CN=svc-[a-z]+$",
+			ServiceID:         "internal-service",
+		},
+		{
+			ID:        "tenant-route",
+			Priority:  80,
+			SNI:       `~^[a-z]+\.tenants\.example\.com$`,
+			ServiceID: "tenant-service",
+		},
+		{
+			ID:        "default-route",
+			Priority:  10,
+			ServiceID: "default-service",
+		},
+	}
+	for _, route := range routes {
+		require.NoError(t, store.CreateRoute(ctx, route))
+	}
+
+	r := router.NewRouter(store, &testLogger{})
+
+	tests := []struct {
+		name            string
+		sni             string
+		subject         *pkix.Name
+		plain           bool
+		expectedService string
+	}{
+		{
+			name:            "exact SNI and full subject DN",
+			sni:             "partner.example.com",
+			subject:         &pkix.Name{CommonName: "partner-gateway", Organization: []string{"Partner Inc"}},
+			expectedService: "partner-service",
+		},
+		{
+			name:            "right SNI but wrong subject",
+			sni:             "partner.example.com",
+			subject:         &pkix.Name{CommonName: "someone-else"},
+			expectedService: "default-service",
+		},
+		{
+			name:            "right SNI without a client certificate",
+			sni:             "partner.example.com",
+			expectedService: "default-service",
+		},
+		{
+			name:            "subject regex matches the DN",
+			sni:             "api.example.com",
+			subject:         &pkix.Name{CommonName: "svc-billing"},
+			expectedService: "internal-service",
+		},
+		{
+			name:            "SNI regex",
+			sni:             "acme.tenants.example.com",
+			expectedService: "tenant-service",
+		},
+		{
+			name:            "SNI regex does not match",
+			sni:             "acme.tenants.example.org",
+			expectedService: "default-service",
+		},
+		{
+			name:            "plain HTTP never matches TLS criteria",
+			plain:           true,
+			expectedService: "default-service",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "https://example.com/test", nil)
+			if tt.plain {
+				req.TLS = nil
+			} else {
+				req.TLS = &tls.ConnectionState{ServerName: tt.sni}
+				if tt.subject != nil {
+					req.TLS.PeerCertificates = []*x509.Certificate{{Subject: *tt.subject}}
+				}
+			}
+
+			route, err := r.Match(req)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedService, route.ServiceID)
+		})
+	}
+
+	t.Run("exact subject may name just the common name", func(t *testing.T) {
+		store := storage.NewMemory()
+		require.NoError(t, store.CreateService(ctx, &types.Service{ID: "svc", Name: "svc", Active: true}))
+		require.NoError(t, store.CreateRoute(ctx, &types.Route{ID: "cn-route", ClientCertSubject: "partner-gateway", ServiceID: "svc"}))
+		r := router.NewRouter(store, &testLogger{})
+
+		req := httptest.NewRequest("GET", "https://example.com/", nil)
+		req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{{
+			Subject: pkix.Name{CommonName: "partner-gateway", Organization: []string{"Partner Inc"}},
+		}}}
+		route, err := r.Match(req)
+		require.NoError(t, err)
+		assert.Equal(t, "cn-route", route.ID)
+	})
+}
+
+func TestRouterContentTypeMatching(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemory()
+
+	for _, id := range []string{"grpc-service", "json-service", "default-service"} {
+		require.NoError(t, store.CreateService(ctx, &types.Service{
+			ID:        id,
+			Name:      id,
+			Endpoints: []string{"http://" + id + ":8080"},
+			Active:    true,
+		}))
+	}
+
+	routes := []*types.Route{
+		{ID: "grpc-route", Priority: 100, PathPrefix: "/api", ContentType: "application/grpc", ServiceID: "grpc-service"},
+		{ID: "json-route", Priority: 90, PathPrefix: "/api", ContentType: "application/json", ServiceID: "json-service"},
+		{ID: "default-route", Priority: 10, ServiceID: "default-service"},
+	}
+	for _, route := range routes {
+		require.NoError(t, store.CreateRoute(ctx, route))
+	}
+
+	r := router.NewRouter(store, &testLogger{})
+
+	tests := []struct {
+		name            string
+		contentType     string
+		expectedService string
+	}{
+		{name: "exact media type", contentType: "application/grpc", expectedService: "grpc-service"},
+		{name: "media type with suffix", contentType: "application/grpc+proto", expectedService: "grpc-service"},
+		{name: "parameters are ignored", contentType: "application/json; charset=utf-8", expectedService: "json-service"},
+		{name: "case-insensitive", contentType: "Application/JSON", expectedService: "json-service"},
+		{name: "other media type falls through", contentType: "text/plain", expectedService: "default-service"},
+		{name: "missing content type falls through", contentType: "", expectedService: "default-service"},
+		{name: "prefix of the criterion does not match", contentType: "application/", expectedService: "default-service"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "http://example.com/api/call", nil)
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+
+			route, err := r.Match(req)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedService, route.ServiceID)
+		})
+	}
+}
+
+func TestRouterPathSuffixMatching(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemory()
+
+	for _, id := range []string{"cdn-origin", "api-service", "web-service"} {
+		require.NoError(t, store.CreateService(ctx, &types.Service{
+			ID:        id,
+			Name:      id,
+			Endpoints: []string{"http://" + id + ":8080"},
+			Active:    true,
+		}))
+	}
+
+	routes := []*types.Route{
+		{ID: "static-route", Priority: 100, PathSuffixes: []string{".js", ".css"}, ServiceID: "cdn-origin"},
+		{ID: "api-route", Priority: 50, PathPrefix: "/api", ServiceID: "api-service"},
+		{ID: "web-route", Priority: 10, PathPrefix: "/", ServiceID: "web-service"},
+	}
+	for _, route := range routes {
+		require.NoError(t, store.CreateRoute(ctx, route))
+	}
+
+	r := router.NewRouter(store, &testLogger{})
+
+	tests := []struct {
+		name            string
+		path            string
+		expectedService string
+	}{
+		{name: "script", path: "/static/app.js", expectedService: "cdn-origin"},
+		{name: "stylesheet", path: "/theme.css", expectedService: "cdn-origin"},
+		{name: "case-insensitive", path: "/static/APP.JS", expectedService: "cdn-origin"},
+		{name: "api path", path: "/api/users", expectedService: "api-service"},
+		{name: "suffix must end the path", path: "/api/app.json", expectedService: "api-service"},
+		{name: "other paths", path: "/index.html", expectedService: "web-service"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			route, err := r.Match(httptest.NewRequest("GET", "http://example.com"+tt.path, nil))
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedService, route.ServiceID)
+		})
+	}
+}
+
+func TestRouterHostNormalization(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemory()
+
+	for _, id := range []string{"api-service", "tenant-service", "ipv6-service"} {
+		require.NoError(t, store.CreateService(ctx, &types.Service{
+			ID:        id,
+			Name:      id,
+			Endpoints: []string{"http://" + id + ":8080"},
+			Active:    true,
+		}))
+	}
+
+	routes := []*types.Route{
+		{ID: "api-route", Priority: 100, Host: "API.example.com", ServiceID: "api-service"},
+		{ID: "tenant-route", Priority: 90, Host: "*.Tenants.example.com", ServiceID: "tenant-service"},
+		{ID: "ipv6-route", Priority: 80, Host: "::1", ServiceID: "ipv6-service"},
+	}
+	for _, route := range routes {
+		require.NoError(t, store.CreateRoute(ctx, route))
+	}
+
+	r := router.NewRouter(store, &testLogger{})
+
+	// http2Request builds a request as Go's HTTP/2 server hands it over: the
+	// host comes from :authority and there is no Host header
+	http2Request := func(authority, path string) *http.Request {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Proto, req.ProtoMajor, req.ProtoMinor = "HTTP/2.0", 2, 0
+		req.Host = authority
+		req.Header.Del("Host")
+		return req
+	}
+
+	tests := []struct {
+		name            string
+		req             *http.Request
+		expectedService string
+	}{
+		{"http/1.1 host header", httptest.NewRequest("GET", "http://api.example.com/users", nil), "api-service"},
+		{"http/2 authority", http2Request("api.example.com", "/users"), "api-service"},
+		{"authority case", http2Request("Api.EXAMPLE.com", "/users"), "api-service"},
+		{"authority port", http2Request("api.example.com:8443", "/users"), "api-service"},
+		{"trailing dot", http2Request("api.example.com.", "/users"), "api-service"},
+		{"wildcard case and port", http2Request("acme.TENANTS.example.com:443", "/"), "tenant-service"},
+		{"ipv6 with port", http2Request("[::1]:8443", "/"), "ipv6-service"},
+		{"ipv6 without port", http2Request("[::1]", "/"), "ipv6-service"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			route, err := r.Match(tt.req)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedService, route.ServiceID)
+		})
+	}
+
+	t.Run("absolute url without host", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "http://api.example.com/users", nil)
+		req.Host = ""
+		route, err := r.Match(req)
+		require.NoError(t, err)
+		assert.Equal(t, "api-service", route.ServiceID)
+	})
+
+	t.Run("over a real http/2 connection", func(t *testing.T) {
+		var matched string
+		var proto int
+		server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			proto = req.ProtoMajor
+			if route, err := r.Match(req); err == nil {
+				matched = route.ServiceID
+			}
+		}))
+		server.EnableHTTP2 = true
+		server.StartTLS()
+		defer server.Close()
+
+		req, err := http.NewRequest("GET", server.URL+"/users", nil)
+		require.NoError(t, err)
+		req.Host = "API.example.com:443" // Sent as :authority
+
+		resp, err := server.Client().Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Equal(t, 2, proto)
+		assert.Equal(t, "api-service", matched)
+	})
+}
+
+// newLargeRouter builds a router with n prefix routes spread over ten hosts,
+// plus a few routes every request has to be checked against
+func newLargeRouter(t testing.TB, n int) types.Router {
+	ctx := context.Background()
+	store := storage.NewMemory()
+
+	service := &types.Service{
+		ID:        "large-service",
+		Name:      "Large Service",
+		Endpoints: []string{"http://backend:8080"},
+		Active:    true,
+	}
+	require.NoError(t, store.CreateService(ctx, service))
+
+	for i := 0; i < n; i++ {
+		route := &types.Route{
+			ID:         fmt.Sprintf("route-%d", i),
+			Priority:   i % 100,
+			PathPrefix: fmt.Sprintf("/tenant%d/api", i),
+			ServiceID:  "large-service",
+		}
+		if i%2 == 0 {
+			route.Host = fmt.Sprintf("host%d.example.com", i%10)
+		}
+		require.NoError(t, store.CreateRoute(ctx, route))
+	}
+
+	for _, route := range []*types.Route{
+		{ID: "regex", Priority: 1000, PathRegex: `^/tenant\d+/admin`, ServiceID: "large-service"},
+		{ID: "canary", Priority: 1000, Headers: map[string]string{"X-Canary": "true"}, ServiceID: "large-service"},
+		{ID: "wildcard", Priority: 1000, Host: "*.internal.example.com", ServiceID: "large-service"},
+		{ID: "fallback", Priority: -1, PathPrefix: "/", ServiceID: "large-service"},
+	} {
+		require.NoError(t, store.CreateRoute(ctx, route))
+	}
+
+	return router.NewRouter(store, &testLogger{})
+}
+
+func TestRouterLargeRouteTable(t *testing.T) {
+	r := newLargeRouter(t, 10000)
+
+	tests := []struct {
+		name    string
+		host    string
+		path    string
+		headers map[string]string
+		routeID string
+	}{
+		{"Prefix route on its host", "host2.example.com", "/tenant42/api/users", nil, "route-42"},
+		{"Prefix route on another host", "host3.example.com", "/tenant42/api/users", nil, "fallback"},
+		{"Hostless prefix route", "anything.test", "/tenant43/api", nil, "route-43"},
+		{"Longer prefix is its own route", "host1.example.com", "/tenant4321/api", nil, "route-4321"},
+		{"Regex route", "host2.example.com", "/tenant42/admin", nil, "regex"},
+		{"Header route outranks prefix routes", "anything.test", "/tenant43/api", map[string]string{"X-Canary": "true"}, "canary"},
+		{"Wildcard host", "api.internal.example.com", "/tenant43/api", nil, "wildcard"},
+		{"No prefix matches", "anything.test", "/other", nil, "fallback"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "http://"+tt.host+tt.path, nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			route, err := r.Match(req)
+			require.NoError(t, err)
+			assert.Equal(t, tt.routeID, route.ID)
+		})
+	}
+}
+
+func TestRouterIndexedPriority(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemory()
+
+	service := &types.Service{
+		ID:        "test-service",
+		Name:      "Test Service",
+		Endpoints: []string{"http://backend:8080"},
+		Active:    true,
+	}
+	require.NoError(t, store.CreateService(ctx, service))
+
+	// A shorter prefix with a higher priority wins over a longer one
+	for _, route := range []*types.Route{
+		{ID: "api", Priority: 100, PathPrefix: "/api", ServiceID: "test-service"},
+		{ID: "api-v1", Priority: 50, PathPrefix: "/api/v1", ServiceID: "test-service"},
+		{ID: "api-v1-users", Priority: 200, PathPrefix: "/api/v1/users", ServiceID: "test-service"},
+		{ID: "deep-wildcard", Priority: 1, Host: "*.eu.example.com", ServiceID: "test-service"},
+		{ID: "wildcard", Priority: 100, Host: "*.example.com", ServiceID: "test-service"},
+	} {
+		require.NoError(t, store.CreateRoute(ctx, route))
+	}
+
+	r := router.NewRouter(store, &testLogger{})
+
+	tests := []struct {
+		host    string
+		path    string
+		routeID string
+	}{
+		{"any.test", "/api/v1/orders", "api"},
+		{"any.test", "/api/v1/users/7", "api-v1-users"},
+		{"any.test", "/api/v2", "api"},
+		{"shop.eu.example.com", "/", "deep-wildcard"},
+		{"shop.us.example.com", "/", "wildcard"},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "http://"+tt.host+tt.path, nil)
+		route, err := r.Match(req)
+		require.NoError(t, err, "%s%s", tt.host, tt.path)
+		assert.Equal(t, tt.routeID, route.ID, "%s%s", tt.host, tt.path)
+	}
+}
+
+// BenchmarkRouterMatch matches requests against a table of 10,000 routes
+func BenchmarkRouterMatch(b *testing.B) {
+	const numRoutes = 10000
+	r := newLargeRouter(b, numRoutes)
+
+	requests := make([]*http.Request, 1024)
+	for i := range requests {
+		n := (i * 7919) % numRoutes
+		host := "other.test"
+		if n%2 == 0 {
+			host = fmt.Sprintf("host%d.example.com", n%10)
+		}
+		requests[i] = httptest.NewRequest("GET", fmt.Sprintf("http://%s/tenant%d/api/items", host, n), nil)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := r.Match(requests[i%len(requests)]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package server_test
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"discobox/internal/server"
+	"discobox/internal/types"
+
+	"github.com/quic-go/quic-go/http3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTP3Proxy(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "backend saw %s", r.URL.Path)
+	}))
+	t.Cleanup(backend.Close)
+
+	backendURL, err := url.Parse(backend.URL)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	writeCert(t, filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key"), "h3.example.com")
+
+	cfg := &types.ProxyConfig{}
+	cfg.ListenAddr = "127.0.0.1:0"
+	cfg.TLS.Enabled = true
+	cfg.TLS.CertFile = filepath.Join(dir, "tls.crt")
+	cfg.TLS.KeyFile = filepath.Join(dir, "tls.key")
+	cfg.HTTP3.Enabled = true
+
+	tm, err := server.NewTLSManager(cfg, &testLogger{})
+	require.NoError(t, err)
+	t.Cleanup(func() { tm.Close() })
+
+	h3s, err := server.NewHTTP3Server(cfg, httputil.NewSingleHostReverseProxy(backendURL), tm, &testLogger{})
+	require.NoError(t, err)
+	require.NoError(t, h3s.Start(context.Background()))
+
+	transport := &http3.Transport{
+		TLSClientConfig: &tls.Config{
+			ServerName:         "h3.example.com",
+			InsecureSkipVerify: true,
+		},
+	}
+	t.Cleanup(func() { transport.Close() })
+	client := &http.Client{Transport: transport, Timeout: 5 * time.Second}
+
+	resp, err := client.Get(fmt.Sprintf("https://%s/hello", h3s.Addr()))
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, 3, resp.ProtoMajor)
+	assert.Equal(t, "backend saw /hello", string(body))
+
+	// Graceful shutdown stops accepting new QUIC connections
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	require.NoError(t, h3s.Stop(ctx))
+
+	transport.Close()
+	_, err = client.Get(fmt.Sprintf("https://%s/hello", h3s.Addr()))
+	assert.Error(t, err)
+}
+
+func TestAltSvcHeader(t *testing.T) {
+	cfg := &types.ProxyConfig{}
+	cfg.ListenAddr = ":8443"
+	cfg.TLS.Enabled = true
+	cfg.HTTP3.Enabled = true
+
+	handler := server.AltSvc(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, `h3=":8443"; ma=86400`, rec.Header().Get("Alt-Svc"))
+
+	cfg.HTTP3.AltSvc = `h3=":443"; ma=3600`
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, `h3=":443"; ma=3600`, rec.Header().Get("Alt-Svc"))
+}
@@ -0,0 +1,163 @@
+package server_test
+
+import (
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"discobox/internal/server"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// serveEcho echoes every connection accepted on a limited listener
+func serveEcho(t *testing.T, max int, mode string) string {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	limited := server.LimitListener(ln, max, mode)
+	t.Cleanup(func() { limited.Close() })
+
+	go func() {
+		for {
+			conn, err := limited.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+
+	return ln.Addr().String()
+}
+
+// echo sends a byte and reports whether it came back before the timeout
+func echo(t *testing.T, conn net.Conn, timeout time.Duration) error {
+	t.Helper()
+
+	conn.SetDeadline(time.Now().Add(timeout))
+	defer conn.SetDeadline(time.Time{})
+
+	if _, err := conn.Write([]byte("x")); err != nil {
+		return err
+	}
+	buf := make([]byte, 1)
+	_, err := io.ReadFull(conn, buf)
+	return err
+}
+
+func dial(t *testing.T, addr string) net.Conn {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+func TestLimitListener(t *testing.T) {
+	t.Run("wait queues connections over the limit", func(t *testing.T) {
+		addr := serveEcho(t, 2, server.LimitWait)
+
+		first := dial(t, addr)
+		second := dial(t, addr)
+		require.NoError(t, echo(t, first, time.Second))
+		require.NoError(t, echo(t, second, time.Second))
+
+		third := dial(t, addr)
+		err := echo(t, third, 100*time.Millisecond)
+		require.Error(t, err)
+		assert.True(t, isTimeout(err), "expected the third connection to be left waiting, got %v", err)
+
+		// Closing a connection frees its slot for the waiting one
+		first.Close()
+		assert.NoError(t, echo(t, third, time.Second))
+		assert.NoError(t, echo(t, second, time.Second))
+	})
+
+	t.Run("refuse closes connections over the limit", func(t *testing.T) {
+		addr := serveEcho(t, 2, server.LimitRefuse)
+
+		first := dial(t, addr)
+		second := dial(t, addr)
+		require.NoError(t, echo(t, first, time.Second))
+		require.NoError(t, echo(t, second, time.Second))
+
+		third := dial(t, addr)
+		err := echo(t, third, time.Second)
+		require.Error(t, err)
+		assert.False(t, isTimeout(err), "expected the third connection to be closed, got %v", err)
+
+		// Slots are returned once connections close
+		first.Close()
+		assert.Eventually(t, func() bool {
+			conn, err := net.Dial("tcp", addr)
+			if err != nil {
+				return false
+			}
+			defer conn.Close()
+			return echo(t, conn, time.Second) == nil
+		}, 2*time.Second, 20*time.Millisecond)
+		assert.NoError(t, echo(t, second, time.Second))
+	})
+
+	t.Run("listeners share the limit without holding slots while idle", func(t *testing.T) {
+		var addrs []string
+		var listeners []net.Listener
+		for i := 0; i < 2; i++ {
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			require.NoError(t, err)
+			listeners = append(listeners, ln)
+			addrs = append(addrs, ln.Addr().String())
+		}
+
+		for _, limited := range server.LimitListeners(listeners, 1, server.LimitWait) {
+			t.Cleanup(func() { limited.Close() })
+			go func(limited net.Listener) {
+				for {
+					conn, err := limited.Accept()
+					if err != nil {
+						return
+					}
+					go func() {
+						defer conn.Close()
+						io.Copy(conn, conn)
+					}()
+				}
+			}(limited)
+		}
+
+		// The single slot is free for whichever listener gets a connection
+		first := dial(t, addrs[1])
+		require.NoError(t, echo(t, first, time.Second))
+
+		second := dial(t, addrs[0])
+		err := echo(t, second, 100*time.Millisecond)
+		require.Error(t, err)
+		assert.True(t, isTimeout(err), "expected the second connection to be left waiting, got %v", err)
+
+		first.Close()
+		assert.NoError(t, echo(t, second, time.Second))
+	})
+
+	t.Run("zero disables the limit", func(t *testing.T) {
+		addr := serveEcho(t, 0, server.LimitRefuse)
+
+		for i := 0; i < 5; i++ {
+			assert.NoError(t, echo(t, dial(t, addr), time.Second))
+		}
+	})
+}
@@ -0,0 +1,94 @@
+package server_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"discobox/internal/server"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListenMultipleAddresses(t *testing.T) {
+	listeners, err := server.Listen([]string{"127.0.0.1:0", "127.0.0.1:0"}, 0, server.LimitWait)
+	require.NoError(t, err)
+	require.Len(t, listeners, 2)
+
+	// One server, as the proxy runs, serving both listeners
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "proxied "+r.URL.Path)
+	})}
+	served := make(chan error, len(listeners))
+	for _, l := range listeners {
+		go func() { served <- srv.Serve(l) }()
+	}
+
+	for _, l := range listeners {
+		resp, err := http.Get("http://" + l.Addr().String() + "/app")
+		require.NoError(t, err)
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "proxied /app", string(body))
+	}
+
+	// Shutdown stops every listener
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, srv.Shutdown(ctx))
+	for range listeners {
+		assert.ErrorIs(t, <-served, http.ErrServerClosed)
+	}
+	for _, l := range listeners {
+		_, err := http.Get("http://" + l.Addr().String() + "/app")
+		assert.Error(t, err)
+	}
+}
+
+func TestListenSharedLimit(t *testing.T) {
+	listeners, err := server.Listen([]string{"127.0.0.1:0", "127.0.0.1:0"}, 1, server.LimitRefuse)
+	require.NoError(t, err)
+	for _, l := range listeners {
+		t.Cleanup(func() { l.Close() })
+		go func() {
+			for {
+				conn, err := l.Accept()
+				if err != nil {
+					return
+				}
+				go func() {
+					defer conn.Close()
+					io.Copy(conn, conn)
+				}()
+			}
+		}()
+	}
+
+	// The one slot taken on the first address is gone from the second too
+	first := dial(t, listeners[0].Addr().String())
+	require.NoError(t, echo(t, first, time.Second))
+
+	second := dial(t, listeners[1].Addr().String())
+	assert.Error(t, echo(t, second, time.Second))
+
+	first.Close()
+	assert.Eventually(t, func() bool {
+		conn := dial(t, listeners[1].Addr().String())
+		defer conn.Close()
+		return echo(t, conn, 100*time.Millisecond) == nil
+	}, 2*time.Second, 20*time.Millisecond)
+}
+
+func TestListenUnavailableAddress(t *testing.T) {
+	taken, err := server.Listen([]string{"127.0.0.1:0"}, 0, server.LimitWait)
+	require.NoError(t, err)
+	defer taken[0].Close()
+
+	_, err = server.Listen([]string{"127.0.0.1:0", taken[0].Addr().String()}, 0, server.LimitWait)
+	assert.ErrorContains(t, err, taken[0].Addr().String())
+}
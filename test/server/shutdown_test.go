@@ -0,0 +1,194 @@
+package server_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"discobox/internal/server"
+	"discobox/internal/storage"
+	"discobox/internal/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// orderedStorage records when it is closed
+type orderedStorage struct {
+	types.Storage
+	record func(string)
+}
+
+func (s *orderedStorage) Close() error {
+	s.record("storage")
+	return s.Storage.Close()
+}
+
+func TestShutdownOrdering(t *testing.T) {
+	ctx := context.Background()
+
+	store, err := storage.NewSQLite(t.TempDir()+"/test.db", &testLogger{})
+	require.NoError(t, err)
+	require.NoError(t, store.CreateService(ctx, &types.Service{ID: "svc", Name: "svc", Endpoints: []string{"http://localhost:8080"}}))
+
+	var mu sync.Mutex
+	var order []string
+	record := func(step string) {
+		mu.Lock()
+		defer mu.Unlock()
+		order = append(order, step)
+	}
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+
+		service, err := store.GetService(r.Context(), "svc")
+		if err != nil {
+			record("request failed: " + err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		record("request")
+		w.Write([]byte(service.Name))
+	})}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go srv.Serve(ln)
+
+	type result struct {
+		status int
+		err    error
+	}
+	results := make(chan result, 1)
+	go func() {
+		resp, err := http.Get("http://" + ln.Addr().String())
+		if err != nil {
+			results <- result{err: err}
+			return
+		}
+		resp.Body.Close()
+		results <- result{status: resp.StatusCode}
+	}()
+	<-started
+
+	shutdown := &server.Shutdown{
+		Intake: []server.ShutdownStep{{Name: "proxy server", Stop: func(ctx context.Context) error {
+			record("intake")
+			return srv.Shutdown(ctx)
+		}}},
+		Workers: []func(){func() { record("workers") }},
+		Storage: &orderedStorage{Storage: store, record: record},
+		Logger:  &testLogger{},
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- shutdown.Run(shutdownCtx) }()
+
+	// New connections are refused while the in-flight request is still running
+	require.Eventually(t, func() bool {
+		conn, err := net.DialTimeout("tcp", ln.Addr().String(), 100*time.Millisecond)
+		if err != nil {
+			return true
+		}
+		conn.Close()
+		return false
+	}, 2*time.Second, 10*time.Millisecond)
+
+	select {
+	case err := <-done:
+		t.Fatalf("shutdown finished before the in-flight request: %v", err)
+	default:
+	}
+
+	close(release)
+
+	res := <-results
+	require.NoError(t, res.err)
+	assert.Equal(t, http.StatusOK, res.status)
+	require.NoError(t, <-done)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"intake", "request", "workers", "storage"}, order)
+
+	_, err = store.GetService(ctx, "svc")
+	assert.Error(t, err, "storage should be closed once shutdown completes")
+}
+
+func TestShutdownContinuesAfterErrors(t *testing.T) {
+	var stopped, closed bool
+	intakeErr := errors.New("intake failed")
+
+	shutdown := &server.Shutdown{
+		Intake: []server.ShutdownStep{{Name: "broken", Stop: func(ctx context.Context) error {
+			return intakeErr
+		}}},
+		Workers: []func(){func() { stopped = true }},
+		Storage: closerFunc(func() error {
+			closed = true
+			return nil
+		}),
+		Logger: &testLogger{},
+	}
+
+	err := shutdown.Run(context.Background())
+	assert.ErrorIs(t, err, intakeErr)
+	assert.True(t, stopped)
+	assert.True(t, closed)
+}
+
+func TestShutdownStopsIntakeConcurrently(t *testing.T) {
+	// The first server drains slowly; the second must stop accepting
+	// before it has finished
+	secondStopped := make(chan struct{})
+	var order []string
+	var mu sync.Mutex
+	record := func(step string) {
+		mu.Lock()
+		defer mu.Unlock()
+		order = append(order, step)
+	}
+
+	shutdown := &server.Shutdown{
+		Intake: []server.ShutdownStep{
+			{Name: "slow", Stop: func(ctx context.Context) error {
+				select {
+				case <-secondStopped:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+				record("slow")
+				return nil
+			}},
+			{Name: "fast", Stop: func(ctx context.Context) error {
+				record("fast")
+				close(secondStopped)
+				return nil
+			}},
+		},
+		Workers: []func(){func() { record("workers") }},
+		Logger:  &testLogger{},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	require.NoError(t, shutdown.Run(ctx))
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"fast", "slow", "workers"}, order)
+}
+
+type closerFunc func() error
+
+func (f closerFunc) Close() error { return f() }
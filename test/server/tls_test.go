@@ -0,0 +1,196 @@
+package server_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"discobox/internal/server"
+	"discobox/internal/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testLogger is a simple logger implementation for tests
+type testLogger struct{}
+
+func (l *testLogger) Debug(msg string, fields ...any) {}
+func (l *testLogger) Info(msg string, fields ...any)  {}
+func (l *testLogger) Warn(msg string, fields ...any)  {}
+func (l *testLogger) Error(msg string, fields ...any) {}
+func (l *testLogger) With(fields ...any) types.Logger { return l }
+
+// writeCert writes a self-signed certificate for the given names to certFile
+// and keyFile. The first name becomes the common name.
+func writeCert(t *testing.T, certFile, keyFile string, names ...string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: names[0]},
+		DNSNames:     names,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+}
+
+// startTLSListener serves TLS handshakes using the manager's TLS config
+func startTLSListener(t *testing.T, tm *server.TLSManager) string {
+	t.Helper()
+
+	tlsConfig, err := tm.CreateTLSConfig()
+	require.NoError(t, err)
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", tlsConfig)
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				conn.(*tls.Conn).Handshake()
+			}()
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+// servedCommonName connects with the given SNI and returns the common name of
+// the certificate the server presented
+func servedCommonName(t *testing.T, addr, serverName string) string {
+	t.Helper()
+
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: 2 * time.Second}, "tcp", addr, &tls.Config{
+		ServerName:         serverName,
+		InsecureSkipVerify: true,
+	})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	certs := conn.ConnectionState().PeerCertificates
+	require.NotEmpty(t, certs)
+	return certs[0].Subject.CommonName
+}
+
+func TestCertificateHotReload(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+	writeCert(t, certFile, keyFile, "old.example.com")
+
+	cfg := &types.ProxyConfig{}
+	cfg.TLS.Enabled = true
+	cfg.TLS.CertFile = certFile
+	cfg.TLS.KeyFile = keyFile
+
+	tm, err := server.NewTLSManager(cfg, &testLogger{})
+	require.NoError(t, err)
+	t.Cleanup(func() { tm.Close() })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	require.NoError(t, tm.WatchCertificates(ctx))
+
+	addr := startTLSListener(t, tm)
+	assert.Equal(t, "old.example.com", servedCommonName(t, addr, ""))
+
+	t.Run("new cert is picked up", func(t *testing.T) {
+		writeCert(t, certFile, keyFile, "new.example.com")
+
+		assert.Eventually(t, func() bool {
+			return servedCommonName(t, addr, "") == "new.example.com"
+		}, 5*time.Second, 50*time.Millisecond)
+	})
+
+	t.Run("invalid cert is rejected", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(certFile, []byte("not a certificate"), 0600))
+
+		assert.Error(t, tm.ReloadCertificates())
+		assert.Equal(t, "new.example.com", servedCommonName(t, addr, ""))
+
+		// Give the watcher time to attempt its own reload
+		time.Sleep(time.Second)
+		assert.Equal(t, "new.example.com", servedCommonName(t, addr, ""))
+	})
+}
+
+func TestSNICertificateSelection(t *testing.T) {
+	dir := t.TempDir()
+	writeCert(t, filepath.Join(dir, "default.crt"), filepath.Join(dir, "default.key"), "default.example.com", "Admin.Apps.Example.com")
+	writeCert(t, filepath.Join(dir, "api.crt"), filepath.Join(dir, "api.key"), "api.example.com")
+	writeCert(t, filepath.Join(dir, "wild.crt"), filepath.Join(dir, "wild.key"), "*.apps.example.com")
+	writeCert(t, filepath.Join(dir, "shop.crt"), filepath.Join(dir, "shop.key"), "shop-cert")
+
+	cfg := &types.ProxyConfig{}
+	cfg.TLS.Enabled = true
+	cfg.TLS.CertFile = filepath.Join(dir, "default.crt")
+	cfg.TLS.KeyFile = filepath.Join(dir, "default.key")
+	cfg.TLS.Certificates = []types.TLSCertificate{
+		{CertFile: filepath.Join(dir, "api.crt"), KeyFile: filepath.Join(dir, "api.key")},
+		{CertFile: filepath.Join(dir, "wild.crt"), KeyFile: filepath.Join(dir, "wild.key")},
+		{CertFile: filepath.Join(dir, "shop.crt"), KeyFile: filepath.Join(dir, "shop.key"), Hosts: []string{"shop.example.com"}},
+	}
+
+	tm, err := server.NewTLSManager(cfg, &testLogger{})
+	require.NoError(t, err)
+	t.Cleanup(func() { tm.Close() })
+
+	addr := startTLSListener(t, tm)
+
+	tests := []struct {
+		serverName string
+		want       string
+	}{
+		{"api.example.com", "api.example.com"},
+		{"API.Example.com", "api.example.com"},
+		{"foo.apps.example.com", "*.apps.example.com"},
+		{"shop.example.com", "shop-cert"},
+		{"default.example.com", "default.example.com"},
+		// The default certificate's names match regardless of case and
+		// win over the wildcard
+		{"admin.apps.example.com", "default.example.com"},
+		{"unknown.example.com", "default.example.com"},
+		{"", "default.example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.serverName, func(t *testing.T) {
+			assert.Equal(t, tt.want, servedCommonName(t, addr, tt.serverName))
+		})
+	}
+}
@@ -0,0 +1,348 @@
+package storage_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"discobox/internal/storage"
+	"discobox/internal/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingStorage counts the reads that reach the wrapped storage
+type countingStorage struct {
+	types.Storage
+	serviceReads atomic.Int32
+	routeReads   atomic.Int32
+}
+
+func (s *countingStorage) GetService(ctx context.Context, id string) (*types.Service, error) {
+	s.serviceReads.Add(1)
+	return s.Storage.GetService(ctx, id)
+}
+
+func (s *countingStorage) ListServices(ctx context.Context) ([]*types.Service, error) {
+	s.serviceReads.Add(1)
+	return s.Storage.ListServices(ctx)
+}
+
+func (s *countingStorage) GetRoute(ctx context.Context, id string) (*types.Route, error) {
+	s.routeReads.Add(1)
+	return s.Storage.GetRoute(ctx, id)
+}
+
+func (s *countingStorage) ListRoutes(ctx context.Context) ([]*types.Route, error) {
+	s.routeReads.Add(1)
+	return s.Storage.ListRoutes(ctx)
+}
+
+func setupCachedStorage(t *testing.T) (*countingStorage, types.Storage) {
+	backing := &countingStorage{Storage: storage.NewMemory()}
+	cached := storage.WithCache(backing)
+	t.Cleanup(func() { cached.Close() })
+	return backing, cached
+}
+
+func TestCachedStorageImplementation(t *testing.T) {
+	testStorageImplementations(t, "CachedSQLite", func(t *testing.T) types.Storage {
+		return storage.WithCache(setupSQLiteStorage(t))
+	})
+}
+
+func TestCacheServesRepeatReadsFromMemory(t *testing.T) {
+	ctx := context.Background()
+	backing, cached := setupCachedStorage(t)
+
+	require.NoError(t, cached.CreateService(ctx, &types.Service{ID: "svc", Name: "Service", Endpoints: []string{"http://a"}}))
+	require.NoError(t, cached.CreateRoute(ctx, &types.Route{ID: "route", PathPrefix: "/api", ServiceID: "svc"}))
+
+	for i := 0; i < 3; i++ {
+		service, err := cached.GetService(ctx, "svc")
+		require.NoError(t, err)
+		assert.Equal(t, "Service", service.Name)
+
+		route, err := cached.GetRoute(ctx, "route")
+		require.NoError(t, err)
+		assert.Equal(t, "/api", route.PathPrefix)
+
+		services, err := cached.ListServices(ctx)
+		require.NoError(t, err)
+		assert.Len(t, services, 1)
+
+		routes, err := cached.ListRoutes(ctx)
+		require.NoError(t, err)
+		assert.Len(t, routes, 1)
+	}
+
+	assert.Equal(t, int32(2), backing.serviceReads.Load(), "one GetService and one ListServices should reach storage")
+	assert.Equal(t, int32(2), backing.routeReads.Load(), "one GetRoute and one ListRoutes should reach storage")
+
+	// Callers get copies, so changing one doesn't change the cache
+	service, err := cached.GetService(ctx, "svc")
+	require.NoError(t, err)
+	service.Name = "Changed"
+	service, err = cached.GetService(ctx, "svc")
+	require.NoError(t, err)
+	assert.Equal(t, "Service", service.Name)
+}
+
+func TestCacheReturnsDeepCopies(t *testing.T) {
+	ctx := context.Background()
+	_, cached := setupCachedStorage(t)
+
+	require.NoError(t, cached.CreateService(ctx, &types.Service{
+		ID:           "svc",
+		Endpoints:    []string{"http://a"},
+		Metadata:     map[string]string{"team": "core"},
+		EndpointTags: map[string]map[string]string{"http://a": {"zone": "a"}},
+		HealthCheck:  &types.HealthCheckConfig{StatusCodes: []int{200}},
+	}))
+	require.NoError(t, cached.CreateRoute(ctx, &types.Route{
+		ID:          "route",
+		PathPrefix:  "/api",
+		ServiceID:   "svc",
+		Headers:     map[string]string{"X-Tenant": "acme"},
+		Middlewares: []string{"auth"},
+		Metadata:    map[string]any{"owner": map[string]any{"team": "core"}},
+	}))
+
+	// Change everything reachable from the values the cache hands out,
+	// both when it fills the cache and when it serves from it
+	for i := 0; i < 2; i++ {
+		service, err := cached.GetService(ctx, "svc")
+		require.NoError(t, err)
+		service.Endpoints[0] = "http://changed"
+		service.Metadata["team"] = "changed"
+		service.EndpointTags["http://a"]["zone"] = "changed"
+		service.HealthCheck.StatusCodes[0] = 500
+
+		services, err := cached.ListServices(ctx)
+		require.NoError(t, err)
+		require.Len(t, services, 1)
+		services[0].Endpoints[0] = "http://changed"
+		services[0].Metadata["team"] = "changed"
+
+		route, err := cached.GetRoute(ctx, "route")
+		require.NoError(t, err)
+		route.Headers["X-Tenant"] = "changed"
+		route.Middlewares[0] = "changed"
+		route.Metadata["owner"].(map[string]any)["team"] = "changed"
+
+		routes, err := cached.ListRoutes(ctx)
+		require.NoError(t, err)
+		require.Len(t, routes, 1)
+		routes[0].Headers["X-Tenant"] = "changed"
+		routes[0].Middlewares[0] = "changed"
+	}
+
+	service, err := cached.GetService(ctx, "svc")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"http://a"}, service.Endpoints)
+	assert.Equal(t, "core", service.Metadata["team"])
+	assert.Equal(t, "a", service.EndpointTags["http://a"]["zone"])
+	assert.Equal(t, []int{200}, service.HealthCheck.StatusCodes)
+
+	services, err := cached.ListServices(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"http://a"}, services[0].Endpoints)
+	assert.Equal(t, "core", services[0].Metadata["team"])
+
+	route, err := cached.GetRoute(ctx, "route")
+	require.NoError(t, err)
+	assert.Equal(t, "acme", route.Headers["X-Tenant"])
+	assert.Equal(t, []string{"auth"}, route.Middlewares)
+	assert.Equal(t, map[string]any{"team": "core"}, route.Metadata["owner"])
+
+	routes, err := cached.ListRoutes(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "acme", routes[0].Headers["X-Tenant"])
+	assert.Equal(t, []string{"auth"}, routes[0].Middlewares)
+}
+
+func TestCacheMissFallsThrough(t *testing.T) {
+	ctx := context.Background()
+	backing, cached := setupCachedStorage(t)
+
+	_, err := cached.GetService(ctx, "missing")
+	assert.ErrorIs(t, err, types.ErrServiceNotFound)
+
+	// Misses aren't cached, so a service created afterwards is found
+	require.NoError(t, backing.CreateService(ctx, &types.Service{ID: "missing", Endpoints: []string{"http://a"}}))
+	service, err := cached.GetService(ctx, "missing")
+	require.NoError(t, err)
+	assert.Equal(t, "missing", service.ID)
+}
+
+func TestCacheInvalidatesOnUpdate(t *testing.T) {
+	ctx := context.Background()
+	backing, cached := setupCachedStorage(t)
+
+	require.NoError(t, cached.CreateService(ctx, &types.Service{ID: "svc", Name: "v1", Endpoints: []string{"http://a"}}))
+	require.NoError(t, cached.CreateRoute(ctx, &types.Route{ID: "route", PathPrefix: "/v1", ServiceID: "svc"}))
+	_, err := cached.GetService(ctx, "svc")
+	require.NoError(t, err)
+	_, err = cached.ListRoutes(ctx)
+	require.NoError(t, err)
+
+	t.Run("through the cache", func(t *testing.T) {
+		service, err := cached.GetService(ctx, "svc")
+		require.NoError(t, err)
+		service.Name = "v2"
+		require.NoError(t, cached.UpdateService(ctx, service))
+
+		service, err = cached.GetService(ctx, "svc")
+		require.NoError(t, err)
+		assert.Equal(t, "v2", service.Name)
+	})
+
+	t.Run("directly on the wrapped storage", func(t *testing.T) {
+		// Only the watch event tells the cache about these
+		service, err := backing.GetService(ctx, "svc")
+		require.NoError(t, err)
+		service.Name = "v3"
+		require.NoError(t, backing.UpdateService(ctx, service))
+
+		route, err := backing.GetRoute(ctx, "route")
+		require.NoError(t, err)
+		route.PathPrefix = "/v3"
+		require.NoError(t, backing.UpdateRoute(ctx, route))
+
+		assert.Eventually(t, func() bool {
+			service, err := cached.GetService(ctx, "svc")
+			return err == nil && service.Name == "v3"
+		}, 2*time.Second, 10*time.Millisecond)
+		assert.Eventually(t, func() bool {
+			routes, err := cached.ListRoutes(ctx)
+			return err == nil && len(routes) == 1 && routes[0].PathPrefix == "/v3"
+		}, 2*time.Second, 10*time.Millisecond)
+	})
+}
+
+func TestCacheInvalidatesOnDelete(t *testing.T) {
+	ctx := context.Background()
+	backing, cached := setupCachedStorage(t)
+
+	require.NoError(t, cached.CreateService(ctx, &types.Service{ID: "a", Endpoints: []string{"http://a"}}))
+	require.NoError(t, cached.CreateService(ctx, &types.Service{ID: "b", Endpoints: []string{"http://b"}}))
+	require.NoError(t, cached.CreateRoute(ctx, &types.Route{ID: "route-a", PathPrefix: "/a", ServiceID: "a"}))
+	require.NoError(t, cached.CreateRoute(ctx, &types.Route{ID: "route-b", PathPrefix: "/b", ServiceID: "b"}))
+
+	for _, id := range []string{"a", "b"} {
+		_, err := cached.GetService(ctx, id)
+		require.NoError(t, err)
+		_, err = cached.GetRoute(ctx, "route-"+id)
+		require.NoError(t, err)
+	}
+	services, err := cached.ListServices(ctx)
+	require.NoError(t, err)
+	require.Len(t, services, 2)
+
+	t.Run("through the cache", func(t *testing.T) {
+		require.NoError(t, cached.DeleteRoute(ctx, "route-b"))
+		_, err := cached.GetRoute(ctx, "route-b")
+		assert.ErrorIs(t, err, types.ErrRouteNotFound)
+
+		require.NoError(t, cached.DeleteService(ctx, "a"))
+		_, err = cached.GetService(ctx, "a")
+		assert.ErrorIs(t, err, types.ErrServiceNotFound)
+		services, err := cached.ListServices(ctx)
+		require.NoError(t, err)
+		assert.Len(t, services, 1)
+
+		// The service's routes went with it
+		_, err = cached.GetRoute(ctx, "route-a")
+		assert.ErrorIs(t, err, types.ErrRouteNotFound)
+	})
+
+	t.Run("directly on the wrapped storage", func(t *testing.T) {
+		require.NoError(t, backing.DeleteService(ctx, "b"))
+
+		assert.Eventually(t, func() bool {
+			_, err := cached.GetService(ctx, "b")
+			return err == types.ErrServiceNotFound
+		}, 2*time.Second, 10*time.Millisecond)
+	})
+}
+
+func TestCacheWatchersSeeFreshData(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	backing, cached := setupCachedStorage(t)
+
+	require.NoError(t, cached.CreateService(ctx, &types.Service{ID: "svc", Name: "v1", Endpoints: []string{"http://a"}}))
+	// Let the create event pass before watching for the update
+	time.Sleep(50 * time.Millisecond)
+	_, err := cached.GetService(ctx, "svc")
+	require.NoError(t, err)
+
+	events := cached.Watch(ctx)
+
+	service, err := backing.GetService(ctx, "svc")
+	require.NoError(t, err)
+	service.Name = "v2"
+	require.NoError(t, backing.UpdateService(ctx, service))
+
+	select {
+	case event := <-events:
+		assert.Equal(t, "updated", event.Type)
+		assert.Equal(t, "svc", event.ID)
+
+		// By the time the event arrives the cache has dropped the old entry
+		service, err := cached.GetService(ctx, "svc")
+		require.NoError(t, err)
+		assert.Equal(t, "v2", service.Name)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timeout waiting for update event")
+	}
+
+	cancel()
+	select {
+	case _, ok := <-events:
+		assert.False(t, ok, "Channel should be closed after context cancellation")
+	case <-time.After(time.Second):
+		t.Fatal("Channel not closed after context cancellation")
+	}
+}
+
+func TestCacheConcurrentAccess(t *testing.T) {
+	ctx := context.Background()
+	_, cached := setupCachedStorage(t)
+
+	require.NoError(t, cached.CreateService(ctx, &types.Service{ID: "svc", Endpoints: []string{"http://a"}}))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				_, err := cached.GetService(ctx, "svc")
+				assert.NoError(t, err)
+				_, err = cached.ListServices(ctx)
+				assert.NoError(t, err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				service, err := cached.GetService(ctx, "svc")
+				if !assert.NoError(t, err) {
+					return
+				}
+				service.Version = 0
+				assert.NoError(t, cached.UpdateService(ctx, service))
+			}
+		}()
+	}
+	wg.Wait()
+
+	// After the writes settle the cache agrees with storage
+	service, err := cached.GetService(ctx, "svc")
+	require.NoError(t, err)
+	assert.Equal(t, int64(101), service.Version)
+}
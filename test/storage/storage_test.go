@@ -2,6 +2,7 @@ package storage_test
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"testing"
@@ -72,9 +73,13 @@ func testStorageImplementations(t *testing.T, name string, setupFunc func(*testi
 	t.Run(name, func(t *testing.T) {
 		t.Run("ServiceOperations", func(t *testing.T) { testServiceOperations(t, setupFunc) })
 		t.Run("RouteOperations", func(t *testing.T) { testRouteOperations(t, setupFunc) })
+		t.Run("RouteGroups", func(t *testing.T) { testRouteGroups(t, setupFunc) })
+		t.Run("Transactions", func(t *testing.T) { testTransactions(t, setupFunc) })
 		t.Run("UserOperations", func(t *testing.T) { testUserOperations(t, setupFunc) })
 		t.Run("APIKeyOperations", func(t *testing.T) { testAPIKeyOperations(t, setupFunc) })
+		t.Run("PasswordResetTokens", func(t *testing.T) { testPasswordResetTokens(t, setupFunc) })
 		t.Run("WatchOperations", func(t *testing.T) { testWatchOperations(t, setupFunc) })
+		t.Run("VersionConflicts", func(t *testing.T) { testVersionConflicts(t, setupFunc) })
 		t.Run("ConcurrentOperations", func(t *testing.T) { testConcurrentOperations(t, setupFunc) })
 	})
 }
@@ -108,6 +113,10 @@ func testServiceOperations(t *testing.T, setupFunc func(*testing.T) types.Storag
 		Metadata: map[string]string{
 			"env": "test",
 		},
+		UpstreamScheme: "https",
+		EndpointTags: map[string]map[string]string{
+			"http://localhost:8081": {"zone": "us-east-1b"},
+		},
 	}
 
 	err := s.CreateService(ctx, service1)
@@ -115,7 +124,7 @@ func testServiceOperations(t *testing.T, setupFunc func(*testing.T) types.Storag
 
 	// Test CreateService with duplicate ID
 	err = s.CreateService(ctx, service1)
-	assert.Error(t, err)
+	assert.ErrorIs(t, err, types.ErrAlreadyExists)
 
 	// Test GetService
 	retrieved, err := s.GetService(ctx, "service1")
@@ -125,6 +134,8 @@ func testServiceOperations(t *testing.T, setupFunc func(*testing.T) types.Storag
 	assert.Equal(t, service1.Endpoints, retrieved.Endpoints)
 	assert.Equal(t, service1.HealthPath, retrieved.HealthPath)
 	assert.Equal(t, service1.Weight, retrieved.Weight)
+	assert.Equal(t, service1.EndpointTags, retrieved.EndpointTags)
+	assert.Equal(t, service1.UpstreamScheme, retrieved.UpstreamScheme)
 	assert.NotNil(t, retrieved.CreatedAt)
 	assert.NotNil(t, retrieved.UpdatedAt)
 
@@ -161,10 +172,21 @@ func testServiceOperations(t *testing.T, setupFunc func(*testing.T) types.Storag
 	// Allow for timestamp precision differences (SQLite may have lower precision)
 	assert.True(t, updated.UpdatedAt.After(retrieved.UpdatedAt) || updated.UpdatedAt.Equal(retrieved.UpdatedAt))
 
+	// Test health check criteria persistence
+	updated.HealthCheck = &types.HealthCheckConfig{StatusCodes: []int{200, 204}, BodyContains: "ok"}
+	err = s.UpdateService(ctx, updated)
+	assert.NoError(t, err)
+
+	updated, err = s.GetService(ctx, "service1")
+	assert.NoError(t, err)
+	require.NotNil(t, updated.HealthCheck)
+	assert.Equal(t, []int{200, 204}, updated.HealthCheck.StatusCodes)
+	assert.Equal(t, "ok", updated.HealthCheck.BodyContains)
+
 	// Test UpdateService with non-existent ID
 	nonExistent := &types.Service{ID: "non-existent", Name: "Ghost"}
 	err = s.UpdateService(ctx, nonExistent)
-	assert.Error(t, err)
+	assert.ErrorIs(t, err, types.ErrServiceNotFound)
 
 	// Test DeleteService
 	err = s.DeleteService(ctx, "service2")
@@ -189,6 +211,69 @@ func testServiceOperations(t *testing.T, setupFunc func(*testing.T) types.Storag
 	assert.Len(t, svc2.Endpoints, originalEndpoints)
 }
 
+func testVersionConflicts(t *testing.T, setupFunc func(*testing.T) types.Storage) {
+	s := setupFunc(t)
+	if s == nil {
+		return // Storage setup was skipped
+	}
+	defer s.Close()
+
+	ctx := context.Background()
+
+	t.Run("Service", func(t *testing.T) {
+		require.NoError(t, s.CreateService(ctx, &types.Service{ID: "svc", Name: "svc", Endpoints: []string{"http://localhost:8080"}}))
+
+		// Two clients read the same version
+		first, err := s.GetService(ctx, "svc")
+		require.NoError(t, err)
+		second, err := s.GetService(ctx, "svc")
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), first.Version)
+
+		first.Name = "first"
+		require.NoError(t, s.UpdateService(ctx, first))
+		assert.Equal(t, int64(2), first.Version)
+
+		// The stale writer is rejected
+		second.Name = "second"
+		err = s.UpdateService(ctx, second)
+		assert.ErrorIs(t, err, types.ErrVersionConflict)
+
+		stored, err := s.GetService(ctx, "svc")
+		require.NoError(t, err)
+		assert.Equal(t, "first", stored.Name)
+		assert.Equal(t, int64(2), stored.Version)
+
+		// Version zero skips the check
+		stored.Version = 0
+		require.NoError(t, s.UpdateService(ctx, stored))
+		assert.Equal(t, int64(3), stored.Version)
+	})
+
+	t.Run("Route", func(t *testing.T) {
+		require.NoError(t, s.CreateRoute(ctx, &types.Route{ID: "route", ServiceID: "svc", PathPrefix: "/"}))
+
+		first, err := s.GetRoute(ctx, "route")
+		require.NoError(t, err)
+		second, err := s.GetRoute(ctx, "route")
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), first.Version)
+
+		first.Priority = 10
+		require.NoError(t, s.UpdateRoute(ctx, first))
+		assert.Equal(t, int64(2), first.Version)
+
+		second.Priority = 20
+		err = s.UpdateRoute(ctx, second)
+		assert.ErrorIs(t, err, types.ErrVersionConflict)
+
+		stored, err := s.GetRoute(ctx, "route")
+		require.NoError(t, err)
+		assert.Equal(t, 10, stored.Priority)
+		assert.Equal(t, int64(2), stored.Version)
+	})
+}
+
 func testRouteOperations(t *testing.T, setupFunc func(*testing.T) types.Storage) {
 	s := setupFunc(t)
 	if s == nil {
@@ -210,12 +295,17 @@ func testRouteOperations(t *testing.T, setupFunc func(*testing.T) types.Storage)
 
 	// Test CreateRoute
 	route1 := &types.Route{
-		ID:          "route1",
-		Priority:    100,
-		Host:        "example.com",
-		PathPrefix:  "/api",
-		ServiceID:   "service1",
-		Middlewares: []string{"auth", "ratelimit"},
+		ID:                  "route1",
+		Priority:            100,
+		Host:                "example.com",
+		PathPrefix:          "/api",
+		ServiceID:           "service1",
+		Middlewares:         []string{"auth", "ratelimit"},
+		DisabledMiddlewares: []string{"compression"},
+		ContentType:         "application/json",
+		PathSuffixes:        []string{".json"},
+		UpstreamScheme:      "https",
+		MaxResponseBytes:    1 << 20,
 		Headers: map[string]string{
 			"X-API-Version": "v1",
 		},
@@ -226,7 +316,7 @@ func testRouteOperations(t *testing.T, setupFunc func(*testing.T) types.Storage)
 
 	// Test CreateRoute with duplicate ID
 	err = s.CreateRoute(ctx, route1)
-	assert.Error(t, err)
+	assert.ErrorIs(t, err, types.ErrAlreadyExists)
 
 	// Test CreateRoute with non-existent service
 	invalidRoute := &types.Route{
@@ -246,6 +336,11 @@ func testRouteOperations(t *testing.T, setupFunc func(*testing.T) types.Storage)
 	assert.Equal(t, route1.PathPrefix, retrieved.PathPrefix)
 	assert.Equal(t, route1.ServiceID, retrieved.ServiceID)
 	assert.Equal(t, route1.Middlewares, retrieved.Middlewares)
+	assert.Equal(t, route1.DisabledMiddlewares, retrieved.DisabledMiddlewares)
+	assert.Equal(t, route1.ContentType, retrieved.ContentType)
+	assert.Equal(t, route1.PathSuffixes, retrieved.PathSuffixes)
+	assert.Equal(t, route1.UpstreamScheme, retrieved.UpstreamScheme)
+	assert.Equal(t, route1.MaxResponseBytes, retrieved.MaxResponseBytes)
 
 	// Test GetRoute with non-existent ID
 	_, err = s.GetRoute(ctx, "non-existent")
@@ -279,11 +374,71 @@ func testRouteOperations(t *testing.T, setupFunc func(*testing.T) types.Storage)
 	assert.NoError(t, err)
 	assert.Equal(t, 150, updated.Priority)
 	assert.Equal(t, "/v2", updated.PathPrefix)
+	assert.Nil(t, updated.Redirects)
+
+	// Test redirect policy persistence
+	updated.Redirects = &types.RedirectPolicy{Mode: types.RedirectFollow, MaxHops: 3}
+	err = s.UpdateRoute(ctx, updated)
+	assert.NoError(t, err)
+
+	updated, err = s.GetRoute(ctx, "route1")
+	assert.NoError(t, err)
+	assert.Equal(t, &types.RedirectPolicy{Mode: types.RedirectFollow, MaxHops: 3}, updated.Redirects)
+
+	// Test hedging policy persistence
+	assert.Nil(t, updated.Hedging)
+	updated.Hedging = &types.HedgePolicy{Delay: 50 * time.Millisecond}
+	err = s.UpdateRoute(ctx, updated)
+	assert.NoError(t, err)
+
+	updated, err = s.GetRoute(ctx, "route1")
+	assert.NoError(t, err)
+	assert.Equal(t, &types.HedgePolicy{Delay: 50 * time.Millisecond}, updated.Hedging)
+
+	// Test TLS match criteria persistence
+	updated.SNI = "partner.example.com"
+	updated.ClientCertSubject = "~^CN=partner-"
+	err = s.UpdateRoute(ctx, updated)
+	assert.NoError(t, err)
+
+	updated, err = s.GetRoute(ctx, "route1")
+	assert.NoError(t, err)
+	assert.Equal(t, "partner.example.com", updated.SNI)
+	assert.Equal(t, "~^CN=partner-", updated.ClientCertSubject)
+
+	// Test path prefix persistence
+	updated.StripPathPrefix = "/reports"
+	updated.AddPathPrefix = "/api/reports"
+	err = s.UpdateRoute(ctx, updated)
+	assert.NoError(t, err)
+
+	updated, err = s.GetRoute(ctx, "route1")
+	assert.NoError(t, err)
+	assert.Equal(t, "/reports", updated.StripPathPrefix)
+	assert.Equal(t, "/api/reports", updated.AddPathPrefix)
+
+	// Test early hints persistence
+	updated.EarlyHints = []string{"</app.css>; rel=preload; as=style"}
+	err = s.UpdateRoute(ctx, updated)
+	assert.NoError(t, err)
+
+	updated, err = s.GetRoute(ctx, "route1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"</app.css>; rel=preload; as=style"}, updated.EarlyHints)
+
+	// Test canary persistence
+	updated.Canary = &types.CanaryPolicy{ServiceID: "service2", Weight: 10}
+	err = s.UpdateRoute(ctx, updated)
+	assert.NoError(t, err)
+
+	updated, err = s.GetRoute(ctx, "route1")
+	assert.NoError(t, err)
+	assert.Equal(t, &types.CanaryPolicy{ServiceID: "service2", Weight: 10}, updated.Canary)
 
 	// Test UpdateRoute with non-existent ID
 	nonExistent := &types.Route{ID: "non-existent", ServiceID: "service1"}
 	err = s.UpdateRoute(ctx, nonExistent)
-	assert.Error(t, err)
+	assert.ErrorIs(t, err, types.ErrRouteNotFound)
 
 	// Test DeleteRoute
 	err = s.DeleteRoute(ctx, "route2")
@@ -306,6 +461,214 @@ func testRouteOperations(t *testing.T, setupFunc func(*testing.T) types.Storage)
 	assert.Len(t, routes, 0)
 }
 
+func testRouteGroups(t *testing.T, setupFunc func(*testing.T) types.Storage) {
+	s := setupFunc(t)
+	if s == nil {
+		return // Storage setup was skipped
+	}
+	defer s.Close()
+
+	ctx := context.Background()
+
+	require.NoError(t, s.CreateService(ctx, &types.Service{
+		ID:        "service1",
+		Name:      "Test Service",
+		Endpoints: []string{"http://localhost:8080"},
+		Active:    true,
+	}))
+
+	for _, route := range []*types.Route{
+		{ID: "billing-1", Group: "billing", PathPrefix: "/billing/a", ServiceID: "service1"},
+		{ID: "billing-2", Group: "billing", PathPrefix: "/billing/b", ServiceID: "service1"},
+		{ID: "users-1", Group: "users", PathPrefix: "/users", ServiceID: "service1"},
+		{ID: "loose", PathPrefix: "/loose", ServiceID: "service1"},
+	} {
+		require.NoError(t, s.CreateRoute(ctx, route))
+	}
+
+	routeIDs := func(routes []*types.Route) []string {
+		ids := make([]string, 0, len(routes))
+		for _, route := range routes {
+			ids = append(ids, route.ID)
+		}
+		return ids
+	}
+
+	// Group round-trips through storage
+	route, err := s.GetRoute(ctx, "billing-1")
+	require.NoError(t, err)
+	assert.Equal(t, "billing", route.Group)
+
+	billing, err := s.ListRoutesByGroup(ctx, "billing")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"billing-1", "billing-2"}, routeIDs(billing))
+
+	ungrouped, err := s.ListRoutesByGroup(ctx, "")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"loose"}, routeIDs(ungrouped))
+
+	// Moving a route between groups
+	route.Group = "users"
+	require.NoError(t, s.UpdateRoute(ctx, route))
+
+	users, err := s.ListRoutesByGroup(ctx, "users")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"billing-1", "users-1"}, routeIDs(users))
+
+	// Deleting a group removes only its routes
+	deleted, err := s.DeleteRouteGroup(ctx, "users")
+	require.NoError(t, err)
+	assert.Equal(t, 2, deleted)
+
+	remaining, err := s.ListRoutes(ctx)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"billing-2", "loose"}, routeIDs(remaining))
+
+	deleted, err = s.DeleteRouteGroup(ctx, "users")
+	require.NoError(t, err)
+	assert.Zero(t, deleted)
+
+	// The empty group can't be bulk deleted
+	_, err = s.DeleteRouteGroup(ctx, "")
+	assert.ErrorIs(t, err, types.ErrInvalidRequest)
+}
+
+func testTransactions(t *testing.T, setupFunc func(*testing.T) types.Storage) {
+	s := setupFunc(t)
+	if s == nil {
+		return // Storage setup was skipped
+	}
+	defer s.Close()
+
+	ctx := context.Background()
+	errStep := errors.New("step failed")
+
+	require.NoError(t, s.CreateService(ctx, &types.Service{
+		ID:        "service1",
+		Name:      "Original",
+		Endpoints: []string{"http://localhost:8080"},
+		Active:    true,
+	}))
+	require.NoError(t, s.CreateRoute(ctx, &types.Route{ID: "route1", Group: "api", PathPrefix: "/api", ServiceID: "service1"}))
+	require.NoError(t, s.CreateRoute(ctx, &types.Route{ID: "route2", Group: "api", PathPrefix: "/v2", ServiceID: "service1"}))
+
+	// Read first so a caching storage has something to go stale
+	_, err := s.GetService(ctx, "service1")
+	require.NoError(t, err)
+
+	assertUnchanged := func(t *testing.T) {
+		service, err := s.GetService(ctx, "service1")
+		require.NoError(t, err)
+		assert.Equal(t, "Original", service.Name)
+
+		routes, err := s.ListRoutes(ctx)
+		require.NoError(t, err)
+		assert.Len(t, routes, 2)
+
+		_, err = s.GetService(ctx, "service2")
+		assert.Error(t, err)
+	}
+
+	t.Run("failing step rolls back earlier writes", func(t *testing.T) {
+		err := s.Tx(ctx, func(tx types.Storage) error {
+			service, err := tx.GetService(ctx, "service1")
+			if err != nil {
+				return err
+			}
+			service.Name = "Renamed"
+			if err := tx.UpdateService(ctx, service); err != nil {
+				return err
+			}
+			if err := tx.CreateService(ctx, &types.Service{ID: "service2", Name: "New", Endpoints: []string{"http://localhost:9090"}}); err != nil {
+				return err
+			}
+			if err := tx.DeleteRoute(ctx, "route2"); err != nil {
+				return err
+			}
+
+			// Writes are visible inside the transaction
+			renamed, err := tx.GetService(ctx, "service1")
+			if err != nil {
+				return err
+			}
+			assert.Equal(t, "Renamed", renamed.Name)
+
+			return errStep
+		})
+		assert.ErrorIs(t, err, errStep)
+		assertUnchanged(t)
+	})
+
+	t.Run("cascading delete rolls back", func(t *testing.T) {
+		err := s.Tx(ctx, func(tx types.Storage) error {
+			if err := tx.DeleteService(ctx, "service1"); err != nil {
+				return err
+			}
+			if _, err := tx.DeleteRouteGroup(ctx, "api"); err != nil {
+				return err
+			}
+			return errStep
+		})
+		assert.ErrorIs(t, err, errStep)
+		assertUnchanged(t)
+	})
+
+	t.Run("nested transactions join the outer one", func(t *testing.T) {
+		err := s.Tx(ctx, func(tx types.Storage) error {
+			err := tx.Tx(ctx, func(inner types.Storage) error {
+				return inner.CreateService(ctx, &types.Service{ID: "service2", Name: "New", Endpoints: []string{"http://localhost:9090"}})
+			})
+			if err != nil {
+				return err
+			}
+			return errStep
+		})
+		assert.ErrorIs(t, err, errStep)
+		assertUnchanged(t)
+	})
+
+	t.Run("success commits every write", func(t *testing.T) {
+		watchCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		events := s.Watch(watchCtx)
+
+		err := s.Tx(ctx, func(tx types.Storage) error {
+			service, err := tx.GetService(ctx, "service1")
+			if err != nil {
+				return err
+			}
+			service.Name = "Renamed"
+			if err := tx.UpdateService(ctx, service); err != nil {
+				return err
+			}
+			return tx.CreateService(ctx, &types.Service{ID: "service2", Name: "New", Endpoints: []string{"http://localhost:9090"}})
+		})
+		require.NoError(t, err)
+
+		service, err := s.GetService(ctx, "service1")
+		require.NoError(t, err)
+		assert.Equal(t, "Renamed", service.Name)
+
+		_, err = s.GetService(ctx, "service2")
+		assert.NoError(t, err)
+
+		select {
+		case event := <-events:
+			assert.Equal(t, "service", event.Kind)
+		case <-time.After(2 * time.Second):
+			t.Fatal("Timeout waiting for events from the committed transaction")
+		}
+	})
+
+	t.Run("service delete cascades to its routes", func(t *testing.T) {
+		require.NoError(t, s.DeleteService(ctx, "service1"))
+
+		routes, err := s.ListRoutes(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, routes)
+	})
+}
+
 func testUserOperations(t *testing.T, setupFunc func(*testing.T) types.Storage) {
 	s := setupFunc(t)
 	if s == nil {
@@ -330,7 +693,7 @@ func testUserOperations(t *testing.T, setupFunc func(*testing.T) types.Storage)
 
 	// Test CreateUser with duplicate ID
 	err = s.CreateUser(ctx, user1)
-	assert.Error(t, err)
+	assert.ErrorIs(t, err, types.ErrAlreadyExists)
 
 	// Test CreateUser with duplicate username
 	duplicateUsername := &types.User{
@@ -340,7 +703,7 @@ func testUserOperations(t *testing.T, setupFunc func(*testing.T) types.Storage)
 		Email:        "test2@example.com",
 	}
 	err = s.CreateUser(ctx, duplicateUsername)
-	assert.Error(t, err)
+	assert.ErrorIs(t, err, types.ErrAlreadyExists)
 
 	// Test GetUser
 	retrieved, err := s.GetUser(ctx, "user1")
@@ -364,6 +727,24 @@ func testUserOperations(t *testing.T, setupFunc func(*testing.T) types.Storage)
 	_, err = s.GetUserByUsername(ctx, "non-existent")
 	assert.Error(t, err)
 
+	// Test GetUserByEmail
+	byEmail, err := s.GetUserByEmail(ctx, "test1@example.com")
+	assert.NoError(t, err)
+	if assert.NotNil(t, byEmail) {
+		assert.Equal(t, user1.ID, byEmail.ID)
+	}
+
+	// Test GetUserByEmail is case-insensitive
+	byEmail, err = s.GetUserByEmail(ctx, "Test1@Example.COM")
+	assert.NoError(t, err)
+	if assert.NotNil(t, byEmail) {
+		assert.Equal(t, user1.ID, byEmail.ID)
+	}
+
+	// Test GetUserByEmail with non-existent email
+	_, err = s.GetUserByEmail(ctx, "nobody@example.com")
+	assert.Error(t, err)
+
 	// Test ListUsers
 	user2 := &types.User{
 		ID:           "user2",
@@ -393,6 +774,15 @@ func testUserOperations(t *testing.T, setupFunc func(*testing.T) types.Storage)
 	// Allow for timestamp precision differences (SQLite may have lower precision)
 	assert.True(t, updated.UpdatedAt.After(retrieved.UpdatedAt) || updated.UpdatedAt.Equal(retrieved.UpdatedAt))
 
+	// Test GetUserByEmail follows email changes
+	_, err = s.GetUserByEmail(ctx, "test1@example.com")
+	assert.Error(t, err)
+	byEmail, err = s.GetUserByEmail(ctx, "updated@example.com")
+	assert.NoError(t, err)
+	if assert.NotNil(t, byEmail) {
+		assert.Equal(t, user1.ID, byEmail.ID)
+	}
+
 	// Test UpdateUser with non-existent ID
 	nonExistent := &types.User{ID: "non-existent", Username: "ghost"}
 	err = s.UpdateUser(ctx, nonExistent)
@@ -503,6 +893,53 @@ func testAPIKeyOperations(t *testing.T, setupFunc func(*testing.T) types.Storage
 	err = s.RevokeAPIKey(ctx, "non-existent")
 	assert.Error(t, err)
 
+	// Test RevokeAllAPIKeysByUser revokes session keys too and leaves other users alone
+	err = s.CreateAPIKey(ctx, &types.APIKey{
+		Key:      "test-session-key",
+		UserID:   "user1",
+		Name:     "session",
+		Active:   true,
+		Metadata: map[string]string{"type": "session"},
+	})
+	require.NoError(t, err)
+
+	err = s.CreateUser(ctx, &types.User{ID: "user2", Username: "otheruser", Email: "other@example.com", Active: true})
+	require.NoError(t, err)
+	err = s.CreateAPIKey(ctx, &types.APIKey{Key: "other-user-key", UserID: "user2", Name: "other", Active: true})
+	require.NoError(t, err)
+
+	count, err := s.RevokeAllAPIKeysByUser(ctx, "user1")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count) // key2 was already revoked
+
+	keys, err = s.ListAPIKeysByUser(ctx, "user1")
+	assert.NoError(t, err)
+	for _, key := range keys {
+		assert.False(t, key.Active, key.Key)
+	}
+
+	other, err := s.GetAPIKey(ctx, "other-user-key")
+	assert.NoError(t, err)
+	assert.True(t, other.Active)
+
+	count, err = s.RevokeAllAPIKeysByUser(ctx, "user1")
+	assert.NoError(t, err)
+	assert.Zero(t, count)
+
+	// Test ListAPIKeys covers every user's keys, revoked ones included
+	all, err := s.ListAPIKeys(ctx)
+	assert.NoError(t, err)
+	owners := make(map[string]string)
+	for _, key := range all {
+		owners[key.Key] = key.UserID
+	}
+	assert.Equal(t, map[string]string{
+		"test-api-key-1":   "user1",
+		"test-api-key-2":   "user1",
+		"test-session-key": "user1",
+		"other-user-key":   "user2",
+	}, owners)
+
 	// Test API key deletion when user is deleted
 	err = s.DeleteUser(ctx, "user1")
 	assert.NoError(t, err)
@@ -511,6 +948,73 @@ func testAPIKeyOperations(t *testing.T, setupFunc func(*testing.T) types.Storage
 	assert.Error(t, err) // Key should be deleted with user
 }
 
+func testPasswordResetTokens(t *testing.T, setupFunc func(*testing.T) types.Storage) {
+	s := setupFunc(t)
+	if s == nil {
+		return // Storage setup was skipped
+	}
+	defer s.Close()
+
+	ctx := context.Background()
+
+	user := &types.User{
+		ID:           "user1",
+		Username:     "testuser",
+		PasswordHash: "$2a$10$YMF3qRxtVk2u7qCYVayhOe7dN0jp0VQ5k5uF0x5xJxUJB1Y0xJzDm",
+		Email:        "test@example.com",
+		Active:       true,
+	}
+	require.NoError(t, s.CreateUser(ctx, user))
+
+	// Test CreatePasswordResetToken
+	token := &types.PasswordResetToken{
+		TokenHash: "hash-1",
+		UserID:    "user1",
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	require.NoError(t, s.CreatePasswordResetToken(ctx, token))
+
+	// Test ConsumePasswordResetToken
+	consumed, err := s.ConsumePasswordResetToken(ctx, "hash-1")
+	require.NoError(t, err)
+	assert.Equal(t, "user1", consumed.UserID)
+	assert.NotNil(t, consumed.UsedAt)
+
+	// Test reuse is rejected
+	_, err = s.ConsumePasswordResetToken(ctx, "hash-1")
+	assert.ErrorIs(t, err, types.ErrInvalidToken)
+
+	// Test unknown token
+	_, err = s.ConsumePasswordResetToken(ctx, "non-existent")
+	assert.ErrorIs(t, err, types.ErrInvalidToken)
+
+	// Test expired token
+	expired := &types.PasswordResetToken{
+		TokenHash: "hash-expired",
+		UserID:    "user1",
+		ExpiresAt: time.Now().Add(-time.Minute),
+	}
+	require.NoError(t, s.CreatePasswordResetToken(ctx, expired))
+	_, err = s.ConsumePasswordResetToken(ctx, "hash-expired")
+	assert.ErrorIs(t, err, types.ErrInvalidToken)
+
+	// Test a newer token invalidates older ones for the same user
+	require.NoError(t, s.CreatePasswordResetToken(ctx, &types.PasswordResetToken{
+		TokenHash: "hash-old",
+		UserID:    "user1",
+		ExpiresAt: time.Now().Add(time.Hour),
+	}))
+	require.NoError(t, s.CreatePasswordResetToken(ctx, &types.PasswordResetToken{
+		TokenHash: "hash-new",
+		UserID:    "user1",
+		ExpiresAt: time.Now().Add(time.Hour),
+	}))
+	_, err = s.ConsumePasswordResetToken(ctx, "hash-old")
+	assert.ErrorIs(t, err, types.ErrInvalidToken)
+	_, err = s.ConsumePasswordResetToken(ctx, "hash-new")
+	assert.NoError(t, err)
+}
+
 func testWatchOperations(t *testing.T, setupFunc func(*testing.T) types.Storage) {
 	s := setupFunc(t)
 	if s == nil {
@@ -728,3 +1232,132 @@ func testConcurrentOperations(t *testing.T, setupFunc func(*testing.T) types.Sto
 	routes, _ := s.ListRoutes(ctx)
 	assert.GreaterOrEqual(t, len(routes), 5) // At least some routes created
 }
+
+func TestEtcdRollbackKeepsOutsideWrites(t *testing.T) {
+	s := setupEtcdStorage(t)
+	if s == nil {
+		return // Storage setup was skipped
+	}
+	defer s.Close()
+
+	ctx := context.Background()
+	errStep := errors.New("step failed")
+
+	require.NoError(t, s.CreateService(ctx, &types.Service{ID: "shared", Name: "Original", Endpoints: []string{"http://localhost:8080"}}))
+
+	err := s.Tx(ctx, func(tx types.Storage) error {
+		if err := tx.CreateService(ctx, &types.Service{ID: "inside", Name: "Inside", Endpoints: []string{"http://localhost:8081"}}); err != nil {
+			return err
+		}
+		service, err := tx.GetService(ctx, "shared")
+		if err != nil {
+			return err
+		}
+		service.Name = "Inside"
+		if err := tx.UpdateService(ctx, service); err != nil {
+			return err
+		}
+
+		// Writes made outside the transaction while it runs, such as a
+		// login creating a session key or another instance editing a
+		// service the transaction also wrote
+		if err := s.CreateAPIKey(ctx, &types.APIKey{Key: "session", UserID: "user", Active: true, CreatedAt: time.Now()}); err != nil {
+			return err
+		}
+		if err := s.CreateService(ctx, &types.Service{ID: "outside", Name: "Outside", Endpoints: []string{"http://localhost:8082"}}); err != nil {
+			return err
+		}
+		service.Name = "Outside"
+		if err := s.UpdateService(ctx, service); err != nil {
+			return err
+		}
+		return errStep
+	})
+	require.ErrorIs(t, err, errStep)
+
+	// The transaction's own write is undone
+	_, err = s.GetService(ctx, "inside")
+	assert.ErrorIs(t, err, types.ErrServiceNotFound)
+
+	// Everything written outside it survives
+	_, err = s.GetAPIKey(ctx, "session")
+	assert.NoError(t, err)
+	_, err = s.GetService(ctx, "outside")
+	assert.NoError(t, err)
+	shared, err := s.GetService(ctx, "shared")
+	require.NoError(t, err)
+	assert.Equal(t, "Outside", shared.Name)
+}
+
+func TestSQLiteUpdateErrors(t *testing.T) {
+	s := setupSQLiteStorage(t)
+	ctx := context.Background()
+
+	require.NoError(t, s.CreateService(ctx, &types.Service{ID: "svc", Name: "svc", Endpoints: []string{"http://localhost:8080"}}))
+
+	t.Run("missing service", func(t *testing.T) {
+		err := s.UpdateService(ctx, &types.Service{ID: "missing", Name: "missing"})
+		assert.ErrorIs(t, err, types.ErrServiceNotFound)
+	})
+
+	t.Run("database errors are not masked", func(t *testing.T) {
+		require.NoError(t, s.Close())
+
+		err := s.UpdateService(ctx, &types.Service{ID: "svc", Name: "svc"})
+		require.Error(t, err)
+		assert.NotErrorIs(t, err, types.ErrServiceNotFound)
+		assert.NotErrorIs(t, err, types.ErrVersionConflict)
+
+		err = s.UpdateRoute(ctx, &types.Route{ID: "route", ServiceID: "svc"})
+		require.Error(t, err)
+		assert.NotErrorIs(t, err, types.ErrRouteNotFound)
+	})
+}
+
+func TestSQLiteConcurrentWrites(t *testing.T) {
+	dbPath := t.TempDir() + "/test.db"
+
+	// Two handles on one file contend for the write lock the way separate
+	// processes would
+	var stores []types.Storage
+	for i := 0; i < 2; i++ {
+		s, err := storage.NewSQLite(dbPath, &testLogger{})
+		require.NoError(t, err)
+		t.Cleanup(func() { s.Close() })
+		stores = append(stores, s)
+	}
+
+	ctx := context.Background()
+	const workers, iterations = 16, 50
+
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*iterations*4)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			s := stores[w%len(stores)]
+
+			for i := 0; i < iterations; i++ {
+				id := fmt.Sprintf("svc-%d-%d", w, i)
+				service := &types.Service{ID: id, Name: id, Endpoints: []string{"http://localhost:8080"}, Active: true}
+
+				errs <- s.CreateService(ctx, service)
+				errs <- s.CreateRoute(ctx, &types.Route{ID: id, PathPrefix: "/" + id, ServiceID: id})
+				service.Weight = 2
+				errs <- s.UpdateService(ctx, service)
+				errs <- s.DeleteService(ctx, id)
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	services, err := stores[0].ListServices(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, services)
+}
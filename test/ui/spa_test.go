@@ -0,0 +1,192 @@
+package ui_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	discobox_ui "discobox/pkg/ui/discobox"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUIFromDirectory(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "index.html"), []byte("<title>Acme Proxy</title>"), 0644))
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "assets"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "assets", "logo.svg"), []byte("<svg/>"), 0644))
+
+	fs, err := discobox_ui.FileSystem(dir)
+	require.NoError(t, err)
+	handler := discobox_ui.NewHandler(fs, "/")
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		return rec
+	}
+
+	t.Run("root serves the custom index", func(t *testing.T) {
+		rec := get("/")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "<title>Acme Proxy</title>", rec.Body.String())
+	})
+
+	t.Run("assets are served from the directory", func(t *testing.T) {
+		rec := get("/assets/logo.svg")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "<svg/>", rec.Body.String())
+	})
+
+	t.Run("client-side routes fall back to index", func(t *testing.T) {
+		rec := get("/services/users/edit")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "<title>Acme Proxy</title>", rec.Body.String())
+	})
+}
+
+func TestUIDirectoryValidation(t *testing.T) {
+	_, err := discobox_ui.FileSystem(filepath.Join(t.TempDir(), "missing"))
+	assert.Error(t, err)
+
+	// A directory without an index can't serve the app
+	_, err = discobox_ui.FileSystem(t.TempDir())
+	assert.Error(t, err)
+
+	// No directory uses the built-in files
+	fs, err := discobox_ui.FileSystem("")
+	require.NoError(t, err)
+	assert.NotNil(t, fs)
+}
+
+func TestUIUnderBasePath(t *testing.T) {
+	dir := t.TempDir()
+	index := `<html><head><link href="/assets/app.css" rel="stylesheet"><script src="/assets/app.js"></script><script src="//cdn.example.com/x.js"></script></head><body><a href="/">Home</a></body></html>`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "index.html"), []byte(index), 0644))
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "assets"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "assets", "app.js"), []byte(`fetch("/api/v1/services")`), 0644))
+
+	fs, err := discobox_ui.FileSystem(dir)
+	require.NoError(t, err)
+
+	api := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("api " + r.URL.Path))
+	})
+	handler := discobox_ui.Mount(api, discobox_ui.NewHandler(fs, "/admin/"), "/admin/")
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		return rec
+	}
+
+	assertIndex := func(t *testing.T, rec *httptest.ResponseRecorder) {
+		t.Helper()
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Header().Get("Content-Type"), "text/html")
+		body := rec.Body.String()
+		assert.Contains(t, body, `<head><base href="/admin/">`)
+		assert.Contains(t, body, `href="/admin/assets/app.css"`)
+		assert.Contains(t, body, `src="/admin/assets/app.js"`)
+		assert.Contains(t, body, `src="//cdn.example.com/x.js"`)
+		assert.Contains(t, body, `<a href="/admin/">`)
+	}
+
+	t.Run("base path serves the index", func(t *testing.T) {
+		assertIndex(t, get("/admin/"))
+	})
+
+	t.Run("deep client-side routes serve the index", func(t *testing.T) {
+		assertIndex(t, get("/admin/services/users/edit"))
+	})
+
+	t.Run("bare base path redirects", func(t *testing.T) {
+		rec := get("/admin")
+		assert.Equal(t, http.StatusMovedPermanently, rec.Code)
+		assert.Equal(t, "/admin/", rec.Header().Get("Location"))
+	})
+
+	t.Run("assets are served under the base path unchanged", func(t *testing.T) {
+		rec := get("/admin/assets/app.js")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, `fetch("/api/v1/services")`, rec.Body.String())
+	})
+
+	t.Run("api resolves under the base path", func(t *testing.T) {
+		rec := get("/admin/api/v1/services")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "api /api/v1/services", rec.Body.String())
+
+		rec = get("/admin/health")
+		assert.Equal(t, "api /health", rec.Body.String())
+	})
+
+	t.Run("paths outside the base path are not found", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, get("/").Code)
+		assert.Equal(t, http.StatusNotFound, get("/api/v1/services").Code)
+	})
+}
+
+func TestUINavigationUnderBasePath(t *testing.T) {
+	// The SPA fallback page SvelteKit builds, with the app at the root
+	dir := t.TempDir()
+	index := `<!doctype html>
+<html lang="en">
+	<head>
+		<link rel="modulepreload" href="/_app/immutable/entry/start.js">
+	</head>
+	<body>
+		<div style="display: contents">
+			<script>
+				{
+					__sveltekit_1abc2de = {
+						base: "",
+						env: {}
+					};
+
+					const element = document.currentScript.parentElement;
+
+					Promise.all([
+						import("/_app/immutable/entry/start.js"),
+						import("/_app/immutable/entry/app.js")
+					]).then(([kit, app]) => {
+						kit.start(app, element);
+					});
+				}
+			</script>
+		</div>
+	</body>
+</html>`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "index.html"), []byte(index), 0644))
+	entry := filepath.Join(dir, "_app", "immutable", "entry")
+	require.NoError(t, os.MkdirAll(entry, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(entry, "start.js"), []byte(`export function start() {}`), 0644))
+
+	fs, err := discobox_ui.FileSystem(dir)
+	require.NoError(t, err)
+	handler := discobox_ui.Mount(http.NotFoundHandler(), discobox_ui.NewHandler(fs, "/admin/"), "/admin/")
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		return rec
+	}
+
+	// Opening a client-side route directly boots the app with the base path,
+	// so its router matches /admin/services as /services
+	rec := get("/admin/services")
+	require.Equal(t, http.StatusOK, rec.Code)
+	body := rec.Body.String()
+	assert.Contains(t, body, `base: "/admin"`)
+	assert.Contains(t, body, `import("/admin/_app/immutable/entry/start.js")`)
+	assert.Contains(t, body, `import("/admin/_app/immutable/entry/app.js")`)
+	assert.Contains(t, body, `href="/admin/_app/immutable/entry/start.js"`)
+
+	// The entry module it imports is there
+	rec = get("/admin/_app/immutable/entry/start.js")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `export function start() {}`, rec.Body.String())
+}